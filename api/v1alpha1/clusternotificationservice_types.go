@@ -0,0 +1,84 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterNotificationServiceSpec defines the desired state of ClusterNotificationService
+type ClusterNotificationServiceSpec struct {
+	// Destinations are the targets notifications about the PipelineRuns of the selected namespaces are sent to
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=64
+	// +listType=map
+	// +listMapKey=name
+	Destinations []Destination `json:"destinations"`
+
+	// SecretNamespace is the namespace of the Secrets referenced by the destinations
+	// +kubebuilder:validation:MinLength=1
+	SecretNamespace string `json:"secretNamespace"`
+
+	// NamespaceSelector selects the namespaces whose PipelineRuns are sent to the destinations.
+	// If not set, the PipelineRuns of all namespaces are.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// AllowOverride lets the NotificationServices of a namespace replace a destination by declaring
+	// a destination with the same name. Otherwise the destinations of both are notified.
+	// +optional
+	AllowOverride bool `json:"allowOverride,omitempty"`
+
+	// BestEffort sends notifications to the destinations without holding PipelineRuns with a
+	// finalizer until they are delivered. Defaults to the --best-effort flag of the controller.
+	// +optional
+	BestEffort *bool `json:"bestEffort,omitempty"`
+}
+
+// ClusterNotificationServiceStatus defines the observed state of ClusterNotificationService
+type ClusterNotificationServiceStatus struct {
+	// Conditions represent the latest available observations of the ClusterNotificationService
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+
+// ClusterNotificationService is the Schema for the clusternotificationservices API.
+// It declares platform-wide destinations applying to the PipelineRuns of all, or the selected, namespaces.
+type ClusterNotificationService struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterNotificationServiceSpec   `json:"spec,omitempty"`
+	Status ClusterNotificationServiceStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterNotificationServiceList contains a list of ClusterNotificationService
+type ClusterNotificationServiceList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterNotificationService `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterNotificationService{}, &ClusterNotificationServiceList{})
+}
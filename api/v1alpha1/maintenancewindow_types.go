@@ -0,0 +1,162 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MaintenanceWindowSpec defines the desired state of MaintenanceWindow.
+// It declares either a one-off window with start and end, or recurring windows with schedule and duration.
+type MaintenanceWindowSpec struct {
+	// Start is the beginning of a one-off window
+	// +optional
+	Start *metav1.Time `json:"start,omitempty"`
+
+	// End is the end of a one-off window
+	// +optional
+	End *metav1.Time `json:"end,omitempty"`
+
+	// Schedule is the cron schedule of the beginning of recurring windows in the time zone, e.g. "0 22 * * 5"
+	// for every Friday at 22:00
+	// +optional
+	Schedule string `json:"schedule,omitempty"`
+
+	// TimeZone is the IANA time zone of the schedule, e.g. Europe/Berlin. Defaults to UTC.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+
+	// Duration is how long recurring windows last
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+
+	// Pipelines are glob patterns of the names of the pipelines whose PipelineRuns are silenced.
+	// If not set, the PipelineRuns of all pipelines are.
+	// +optional
+	Pipelines []string `json:"pipelines,omitempty"`
+
+	// PipelineRunSelector selects the PipelineRuns that are silenced by their labels.
+	// If not set, the PipelineRuns of the selected pipelines are.
+	// +optional
+	PipelineRunSelector *metav1.LabelSelector `json:"pipelineRunSelector,omitempty"`
+
+	// Destinations are glob patterns of the names of the destinations that are silenced, in the form
+	// <namespace>/<notificationservice>/<destination> or cluster/<clusternotificationservice>/<destination>.
+	// If not set, all destinations are.
+	// +optional
+	Destinations []string `json:"destinations,omitempty"`
+}
+
+// SuppressedNotifications counts the notifications a window suppressed for a destination
+type SuppressedNotifications struct {
+	// Namespace is the namespace of the suppressed PipelineRuns
+	Namespace string `json:"namespace"`
+
+	// Destination is the name of the destination
+	Destination string `json:"destination"`
+
+	// Count is the number of suppressed notifications
+	Count int `json:"count"`
+
+	// Summarized is set once the summary of the window was sent to the destination
+	// +optional
+	Summarized bool `json:"summarized,omitempty"`
+}
+
+// MaintenanceWindowStatus defines the observed state of MaintenanceWindow
+type MaintenanceWindowStatus struct {
+	// WindowStart is the beginning of the last window that suppressed notifications
+	// +optional
+	WindowStart *metav1.Time `json:"windowStart,omitempty"`
+
+	// WindowEnd is the end of the last window that suppressed notifications
+	// +optional
+	WindowEnd *metav1.Time `json:"windowEnd,omitempty"`
+
+	// Suppressed counts the notifications the last window suppressed, by namespace and destination
+	// +optional
+	Suppressed []SuppressedNotifications `json:"suppressed,omitempty"`
+
+	// LastSummaryTime is the end of the last window whose summary of suppressed notifications was sent
+	// +optional
+	LastSummaryTime *metav1.Time `json:"lastSummaryTime,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Schedule",type=string,JSONPath=`.spec.schedule`
+// +kubebuilder:printcolumn:name="Window End",type=date,JSONPath=`.status.windowEnd`
+
+// MaintenanceWindow is the Schema for the maintenancewindows API.
+// It silences the notifications about the selected PipelineRuns of its namespace to the selected
+// destinations during its windows, and sends a summary of the suppressed notifications when a window ends.
+type MaintenanceWindow struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MaintenanceWindowSpec   `json:"spec,omitempty"`
+	Status MaintenanceWindowStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// MaintenanceWindowList contains a list of MaintenanceWindow
+type MaintenanceWindowList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MaintenanceWindow `json:"items"`
+}
+
+// ClusterMaintenanceWindowSpec defines the desired state of ClusterMaintenanceWindow
+type ClusterMaintenanceWindowSpec struct {
+	MaintenanceWindowSpec `json:",inline"`
+
+	// NamespaceSelector selects the namespaces whose PipelineRuns are silenced.
+	// If not set, the PipelineRuns of all namespaces are.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Schedule",type=string,JSONPath=`.spec.schedule`
+// +kubebuilder:printcolumn:name="Window End",type=date,JSONPath=`.status.windowEnd`
+
+// ClusterMaintenanceWindow is the Schema for the clustermaintenancewindows API.
+// It declares platform-wide maintenance windows applying to all, or the selected, namespaces.
+type ClusterMaintenanceWindow struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterMaintenanceWindowSpec `json:"spec,omitempty"`
+	Status MaintenanceWindowStatus      `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterMaintenanceWindowList contains a list of ClusterMaintenanceWindow
+type ClusterMaintenanceWindowList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterMaintenanceWindow `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MaintenanceWindow{}, &MaintenanceWindowList{})
+	SchemeBuilder.Register(&ClusterMaintenanceWindow{}, &ClusterMaintenanceWindowList{})
+}
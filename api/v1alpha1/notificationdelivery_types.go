@@ -0,0 +1,85 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NotificationDeliverySpec describes a single attempt to deliver a notification
+type NotificationDeliverySpec struct {
+	// PipelineRun is the name of the PipelineRun the notification was sent for
+	PipelineRun string `json:"pipelineRun"`
+
+	// Destination identifies the destination the notification was sent to
+	Destination string `json:"destination"`
+
+	// PayloadHash is the sha256 hash of the JSON encoded notification
+	PayloadHash string `json:"payloadHash"`
+
+	// Payload is the JSON encoded notification, so it can be inspected and sent again
+	// +optional
+	Payload string `json:"payload,omitempty"`
+
+	// Succeeded is true if the destination accepted the notification
+	Succeeded bool `json:"succeeded"`
+
+	// ResponseCode is the status returned by the destination, if the destination reports one
+	// +optional
+	ResponseCode int `json:"responseCode,omitempty"`
+
+	// ResponseExcerpt is the beginning of the response body returned by the destination
+	// +optional
+	ResponseExcerpt string `json:"responseExcerpt,omitempty"`
+
+	// Latency is the duration of the delivery attempt
+	Latency metav1.Duration `json:"latency"`
+
+	// Error is the reason the delivery failed
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:printcolumn:name="PipelineRun",type=string,JSONPath=`.spec.pipelineRun`
+// +kubebuilder:printcolumn:name="Destination",type=string,JSONPath=`.spec.destination`
+// +kubebuilder:printcolumn:name="Succeeded",type=boolean,JSONPath=`.spec.succeeded`
+// +kubebuilder:printcolumn:name="Code",type=integer,JSONPath=`.spec.responseCode`
+// +kubebuilder:printcolumn:name="Latency",type=string,JSONPath=`.spec.latency`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// NotificationDelivery is the Schema for the notificationdeliveries API.
+// It records a single delivery attempt and is removed once its TTL expires.
+type NotificationDelivery struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec NotificationDeliverySpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NotificationDeliveryList contains a list of NotificationDelivery
+type NotificationDeliveryList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NotificationDelivery `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NotificationDelivery{}, &NotificationDeliveryList{})
+}
@@ -0,0 +1,791 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// WebhookContentType is the encoding of webhook request bodies
+// +kubebuilder:validation:Enum=json;form;xml;alertmanager
+type WebhookContentType string
+
+const (
+	// WebhookContentTypeJSON sends application/json bodies
+	WebhookContentTypeJSON WebhookContentType = "json"
+	// WebhookContentTypeForm sends application/x-www-form-urlencoded bodies
+	WebhookContentTypeForm WebhookContentType = "form"
+	// WebhookContentTypeXML sends application/xml bodies
+	WebhookContentTypeXML WebhookContentType = "xml"
+	// WebhookContentTypeAlertmanager sends the webhook messages of Prometheus Alertmanager
+	WebhookContentTypeAlertmanager WebhookContentType = "alertmanager"
+)
+
+// WebhookCompression is the encoding applied to webhook request bodies
+// +kubebuilder:validation:Enum=none;gzip
+type WebhookCompression string
+
+const (
+	// WebhookCompressionNone sends uncompressed bodies
+	WebhookCompressionNone WebhookCompression = "none"
+	// WebhookCompressionGzip compresses bodies with gzip and sets Content-Encoding
+	WebhookCompressionGzip WebhookCompression = "gzip"
+)
+
+// WebhookResultFormat is how array and object results are sent in webhook request bodies
+// +kubebuilder:validation:Enum=structured;string;flatten
+type WebhookResultFormat string
+
+const (
+	// WebhookResultFormatStructured sends array and object results as JSON arrays and objects
+	WebhookResultFormatStructured WebhookResultFormat = "structured"
+	// WebhookResultFormatString JSON encodes array and object results in the result value
+	WebhookResultFormatString WebhookResultFormat = "string"
+	// WebhookResultFormatFlatten sends a string result per item of array and object results
+	WebhookResultFormatFlatten WebhookResultFormat = "flatten"
+)
+
+// SlackThreadMode is how later notifications about a PipelineRun continue its first Slack message
+// +kubebuilder:validation:Enum=update;reply
+type SlackThreadMode string
+
+const (
+	// SlackThreadModeUpdate edits the first message with the latest status
+	SlackThreadModeUpdate SlackThreadMode = "update"
+	// SlackThreadModeReply posts later statuses as replies in the thread of the first message
+	SlackThreadModeReply SlackThreadMode = "reply"
+)
+
+// NotificationServiceSpec defines the desired state of NotificationService
+type NotificationServiceSpec struct {
+	// Destinations are the targets notifications are sent to
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=64
+	// +listType=map
+	// +listMapKey=name
+	Destinations []Destination `json:"destinations"`
+
+	// NotifyOnStart sends a notification to the destinations when a PipelineRun starts
+	// +optional
+	NotifyOnStart bool `json:"notifyOnStart,omitempty"`
+
+	// LongRunningThreshold sends a warning notification to the destinations, and a warning event
+	// on the PipelineRun, when a PipelineRun is still running after this duration
+	// +kubebuilder:validation:XValidation:rule="duration(self) > duration('0s')",message="must be positive"
+	// +optional
+	LongRunningThreshold *metav1.Duration `json:"longRunningThreshold,omitempty"`
+
+	// Summary sends periodic reports about the PipelineRuns of a namespace to the destinations
+	// +optional
+	Summary *SummarySpec `json:"summary,omitempty"`
+
+	// BestEffort sends notifications to the destinations without holding PipelineRuns with a
+	// finalizer until they are delivered. PipelineRuns deleted before they are handled are not
+	// notified about. Defaults to the --best-effort flag of the controller.
+	// +optional
+	BestEffort *bool `json:"bestEffort,omitempty"`
+
+	// Paused stops sending notifications and summaries to the destinations, e.g. to silence a
+	// channel during an incident. PipelineRuns completed while paused are marked as skipped
+	// for the destinations and are not notified about when the NotificationService is resumed.
+	// +optional
+	Paused bool `json:"paused,omitempty"`
+
+	// DefaultTemplate is a Go template inherited by the destinations that set neither an inline
+	// template nor a templateRef
+	// +optional
+	DefaultTemplate string `json:"defaultTemplate,omitempty"`
+
+	// DefaultTemplateRef names a NotificationTemplate inherited by the destinations that set neither
+	// an inline template nor a templateRef. It is ignored if defaultTemplate is set.
+	// +optional
+	DefaultTemplateRef *corev1.LocalObjectReference `json:"defaultTemplateRef,omitempty"`
+
+	// Watch makes the NotificationService notify its destinations about resources of another kind in its
+	// namespace instead of PipelineRuns, every time a condition over their status becomes true
+	// +optional
+	Watch *ResourceWatch `json:"watch,omitempty"`
+
+	// SLO is an objective of how quickly the notifications are delivered to the destinations.
+	// The DeliverySLOMet condition reports whether it is met.
+	// +optional
+	SLO *DeliverySLO `json:"slo,omitempty"`
+}
+
+// DeliverySLO is a service level objective of the latency of deliveries, measured from the
+// completion of the run to the delivery of its notification to a destination
+type DeliverySLO struct {
+	// Objective is the percentage of notifications delivered within the latency, e.g. "99" or "99.5"
+	// +kubebuilder:validation:Pattern=`^[0-9]{1,2}(\.[0-9]+)?$`
+	// +kubebuilder:validation:XValidation:rule="double(self) > 0.0",message="must be greater than 0"
+	Objective string `json:"objective"`
+
+	// Latency is how long after the completion of a run its notification may be delivered, e.g. 60s
+	// +kubebuilder:validation:XValidation:rule="duration(self) > duration('0s')",message="must be positive"
+	Latency metav1.Duration `json:"latency"`
+
+	// Window is the rolling time span the objective is evaluated over
+	// +kubebuilder:default="1h"
+	// +kubebuilder:validation:XValidation:rule="duration(self) > duration('0s')",message="must be positive"
+	// +optional
+	Window *metav1.Duration `json:"window,omitempty"`
+}
+
+// ResourceWatch selects the resources a NotificationService notifies about and when
+type ResourceWatch struct {
+	// APIVersion is the API version of the resources, e.g. apps/v1
+	// +kubebuilder:validation:MinLength=1
+	APIVersion string `json:"apiVersion"`
+
+	// Kind is the kind of the resources, e.g. Deployment
+	// +kubebuilder:validation:MinLength=1
+	Kind string `json:"kind"`
+
+	// Condition is a CEL expression over the resource, available as object, e.g.
+	// object.status.conditions.exists(c, c.type == 'Available' && c.status == 'False').
+	// A notification is sent every time it becomes true.
+	// +kubebuilder:validation:MinLength=1
+	Condition string `json:"condition"`
+
+	// Status is the status of the notifications. Defaults to Failed.
+	// +kubebuilder:validation:Enum=Succeeded;Failed
+	// +optional
+	Status string `json:"status,omitempty"`
+
+	// Results are sent as the results of the notifications
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Results []ResourceResult `json:"results,omitempty"`
+}
+
+// ResourceResult is a result of the notifications about a watched resource
+type ResourceResult struct {
+	// Name is the name of the result
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Expression is a CEL expression over the resource, available as object, e.g. object.status.replicas
+	// +kubebuilder:validation:MinLength=1
+	Expression string `json:"expression"`
+}
+
+// SummarySpec schedules periodic summary reports
+type SummarySpec struct {
+	// Schedule is a cron expression in the time zone, e.g. "0 9 * * 1" for every Monday at 9:00
+	// +kubebuilder:validation:MinLength=1
+	Schedule string `json:"schedule"`
+
+	// TimeZone is the IANA time zone of the schedule, e.g. Europe/Berlin.
+	// Defaults to the time zone of the controller.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+
+	// Period is the time span each summary covers, ending when it is sent
+	// +kubebuilder:default="168h"
+	// +kubebuilder:validation:XValidation:rule="duration(self) > duration('0s')",message="must be positive"
+	// +optional
+	Period *metav1.Duration `json:"period,omitempty"`
+
+	// Namespaces are the namespaces summarized. Summaries only cover the namespace of the
+	// NotificationService, other namespaces are rejected by the admission webhook and skipped.
+	// Deprecated: summaries always cover the namespace of the NotificationService.
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+// Destination is a single target notifications are sent to
+// +kubebuilder:validation:XValidation:rule="[has(self.webhook), has(self.slack), has(self.matrix), has(self.irc), has(self.xmpp), has(self.webPush), has(self.fcm), has(self.alertmanager)].exists_one(set, set)",message="exactly one of webhook, slack, matrix, irc, xmpp, webPush, fcm and alertmanager must be set"
+type Destination struct {
+	// Name identifies the destination within the NotificationService
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Webhook sends notifications as HTTP POST requests
+	// +optional
+	Webhook *WebhookDestination `json:"webhook,omitempty"`
+
+	// Slack posts notifications to a Slack channel
+	// +optional
+	Slack *SlackDestination `json:"slack,omitempty"`
+
+	// Matrix posts notifications to a Matrix room
+	// +optional
+	Matrix *MatrixDestination `json:"matrix,omitempty"`
+
+	// IRC posts one line summaries of notifications to an IRC channel
+	// +optional
+	IRC *IRCDestination `json:"irc,omitempty"`
+
+	// XMPP posts notifications to XMPP multi-user chat rooms
+	// +optional
+	XMPP *XMPPDestination `json:"xmpp,omitempty"`
+
+	// WebPush pushes notifications to the browsers subscribed to the namespace of the NotificationService
+	// +optional
+	WebPush *WebPushDestination `json:"webPush,omitempty"`
+
+	// FCM sends notifications as Firebase Cloud Messaging push notifications to mobile apps
+	// +optional
+	FCM *FCMDestination `json:"fcm,omitempty"`
+
+	// Alertmanager pushes notifications as alerts to a Prometheus Alertmanager
+	// +optional
+	Alertmanager *AlertmanagerDestination `json:"alertmanager,omitempty"`
+
+	// AcknowledgementTimeout enables two-phase delivery for destinations that process
+	// notifications asynchronously. The notification includes a callbackURL the destination
+	// must POST to once it processed the notification, and the PipelineRun is only released
+	// when the acknowledgement is received or the timeout passes.
+	// +kubebuilder:validation:XValidation:rule="duration(self) > duration('0s')",message="must be positive"
+	// +optional
+	AcknowledgementTimeout *metav1.Duration `json:"acknowledgementTimeout,omitempty"`
+
+	// EscalateAfterFailures makes this an escalation destination: it is only notified about failed
+	// PipelineRuns once their Pipeline failed at least this many times in a row
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	EscalateAfterFailures int32 `json:"escalateAfterFailures,omitempty"`
+
+	// MaxConcurrency limits the deliveries to the destination in flight at once, overriding the
+	// maxInFlightPerDestination throttling limit of the controller configuration. Deliveries over the
+	// limit are retried later without counting as failures.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxConcurrency int32 `json:"maxConcurrency,omitempty"`
+
+	// Ordered delivers the notifications to the destination one at a time, in the order of the events
+	// they are about, for receivers whose state requires strict ordering. A notification failing to be
+	// delivered holds back the later ones until it is delivered or for at most the ordering timeout.
+	// +optional
+	Ordered bool `json:"ordered,omitempty"`
+
+	// PolicyOutcomes restricts the destination to PipelineRuns whose Enterprise Contract or policy
+	// check results have one of these outcomes. PipelineRuns without policy results, and lifecycle
+	// notifications, are not sent to it.
+	// +optional
+	PolicyOutcomes []PolicyOutcome `json:"policyOutcomes,omitempty"`
+
+	// Events restricts the destination to these events of PipelineRuns, e.g. only failed. Listing
+	// started or running sends these notifications to the destination even if the NotificationService
+	// does not enable them, running notifications still require longRunningThreshold. Defaults to
+	// succeeded, failed and cancelled, plus the lifecycle events enabled by the NotificationService.
+	// +listType=set
+	// +optional
+	Events []EventType `json:"events,omitempty"`
+
+	// ResultConditions restricts the destination to PipelineRuns whose results meet all these
+	// conditions, e.g. only notify a security channel when VULNERABILITIES_HIGH is greater than 0.
+	// Lifecycle notifications are not sent to it.
+	// +kubebuilder:validation:MaxItems=16
+	// +optional
+	ResultConditions []ResultCondition `json:"resultConditions,omitempty"`
+
+	// TemplateRef names a NotificationTemplate in the namespace of the NotificationService rendering
+	// the notifications of the destination, unless its backend sets an inline template
+	// +optional
+	TemplateRef *corev1.LocalObjectReference `json:"templateRef,omitempty"`
+
+	// Locale is the language of the built-in messages of chat and push destinations, e.g. de or pt-BR.
+	// Templates are not translated. Defaults to en.
+	// +kubebuilder:validation:Pattern=`^(en|de|es|fr|ja|pt)([-_][A-Za-z0-9]+)*$`
+	// +optional
+	Locale string `json:"locale,omitempty"`
+}
+
+// EventType is an event of the lifecycle of a PipelineRun notifications are sent for
+// +kubebuilder:validation:Enum=started;running;succeeded;succeededWithRetries;failed;cancelled
+type EventType string
+
+const (
+	// EventTypeStarted is sent when a PipelineRun starts
+	EventTypeStarted EventType = "started"
+	// EventTypeRunning is sent when a PipelineRun is still running after the long running threshold
+	EventTypeRunning EventType = "running"
+	// EventTypeSucceeded is sent when a PipelineRun succeeds
+	EventTypeSucceeded EventType = "succeeded"
+	// EventTypeSucceededWithRetries is sent when a PipelineRun succeeds after some of its tasks were
+	// retried. These PipelineRuns are also sent for succeeded.
+	EventTypeSucceededWithRetries EventType = "succeededWithRetries"
+	// EventTypeFailed is sent when a PipelineRun fails, including when it times out
+	EventTypeFailed EventType = "failed"
+	// EventTypeCancelled is sent when a PipelineRun is cancelled
+	EventTypeCancelled EventType = "cancelled"
+)
+
+// ResultCondition is a condition over the value of a result of a PipelineRun
+// +kubebuilder:validation:XValidation:rule="!(self.operator in ['In', 'NotIn']) || (has(self.values) && size(self.values) > 0)",message="In and NotIn require values"
+// +kubebuilder:validation:XValidation:rule="!(self.operator in ['GreaterThan', 'LessThan']) || (has(self.value) && self.value.matches('^[-+]?([0-9]+([.][0-9]*)?|[.][0-9]+)([eE][-+]?[0-9]+)?$'))",message="GreaterThan and LessThan require a number value"
+type ResultCondition struct {
+	// Result is the name of the result
+	// +kubebuilder:validation:MinLength=1
+	Result string `json:"result"`
+
+	// Key selects the value of a key of an object result. Without it, object results only
+	// support Exists and DoesNotExist.
+	// +optional
+	Key string `json:"key,omitempty"`
+
+	// Operator compares the value of the result. GreaterThan and LessThan compare numbers, and the
+	// number of items of array results. Contains checks that a string result contains the value, or
+	// that an array result has it as an item.
+	Operator ResultOperator `json:"operator"`
+
+	// Value is compared to the value of the result by Equals, NotEquals, GreaterThan, LessThan and Contains
+	// +kubebuilder:validation:MaxLength=1024
+	// +optional
+	Value string `json:"value,omitempty"`
+
+	// Values are the values In and NotIn look the value of the result up in
+	// +optional
+	Values []string `json:"values,omitempty"`
+}
+
+// ResultOperator compares the value of a result in a ResultCondition
+// +kubebuilder:validation:Enum=Exists;DoesNotExist;Equals;NotEquals;In;NotIn;GreaterThan;LessThan;Contains
+type ResultOperator string
+
+const (
+	// ResultOperatorExists holds if the PipelineRun produced the result, or the key of an object result
+	ResultOperatorExists ResultOperator = "Exists"
+	// ResultOperatorDoesNotExist holds if the PipelineRun did not produce the result, or the key of an object result
+	ResultOperatorDoesNotExist ResultOperator = "DoesNotExist"
+	// ResultOperatorEquals holds if the value of the result is the value
+	ResultOperatorEquals ResultOperator = "Equals"
+	// ResultOperatorNotEquals holds if the value of the result is not the value, including for missing results
+	ResultOperatorNotEquals ResultOperator = "NotEquals"
+	// ResultOperatorIn holds if the value of the result is one of the values
+	ResultOperatorIn ResultOperator = "In"
+	// ResultOperatorNotIn holds if the value of the result is none of the values, including for missing results
+	ResultOperatorNotIn ResultOperator = "NotIn"
+	// ResultOperatorGreaterThan holds if the result is a number, or an array, greater than the value
+	ResultOperatorGreaterThan ResultOperator = "GreaterThan"
+	// ResultOperatorLessThan holds if the result is a number, or an array, less than the value
+	ResultOperatorLessThan ResultOperator = "LessThan"
+	// ResultOperatorContains holds if the string result contains the value, or the array result has it as an item
+	ResultOperatorContains ResultOperator = "Contains"
+)
+
+// PolicyOutcome is the outcome of the policy checks of a PipelineRun
+// +kubebuilder:validation:Enum=passed;warning;failed
+type PolicyOutcome string
+
+const (
+	// PolicyOutcomePassed is the outcome of PipelineRuns whose policy checks passed
+	PolicyOutcomePassed PolicyOutcome = "passed"
+	// PolicyOutcomeWarning is the outcome of PipelineRuns whose policy checks only warned
+	PolicyOutcomeWarning PolicyOutcome = "warning"
+	// PolicyOutcomeFailed is the outcome of PipelineRuns that violated a policy
+	PolicyOutcomeFailed PolicyOutcome = "failed"
+)
+
+// WebhookDestination sends notifications as HTTP POST requests
+// +kubebuilder:validation:XValidation:rule="has(self.url) != has(self.serviceRef)",message="exactly one of url and serviceRef must be set"
+// +kubebuilder:validation:XValidation:rule="!has(self.encryption) || !has(self.compression) || self.compression == 'none'",message="encryption cannot be combined with compression"
+type WebhookDestination struct {
+	// URL is the endpoint notifications are posted to. Exactly one of url and serviceRef must be set.
+	// +kubebuilder:validation:Pattern=`^https?://`
+	// +kubebuilder:validation:MaxLength=2048
+	// +kubebuilder:validation:XValidation:rule="isURL(self)",message="must be a valid URL"
+	// +optional
+	URL string `json:"url,omitempty"`
+
+	// ServiceRef is a Service of the cluster notifications are posted to instead of a URL
+	// +optional
+	ServiceRef *WebhookServiceReference `json:"serviceRef,omitempty"`
+
+	// ContentType is the encoding of the request body
+	// +kubebuilder:default=json
+	// +optional
+	ContentType WebhookContentType `json:"contentType,omitempty"`
+
+	// Template is a Go template rendering the request body from the notification.
+	// If not set, the whole notification is encoded according to the content type.
+	// +optional
+	Template string `json:"template,omitempty"`
+
+	// Compression is the encoding applied to the request body
+	// +kubebuilder:default=none
+	// +optional
+	Compression WebhookCompression `json:"compression,omitempty"`
+
+	// MaxPayloadBytes limits the size of the uncompressed request body.
+	// When exceeded, results are dropped, largest first, and their names are
+	// listed in truncatedResults. The notification fails if it still does not fit.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxPayloadBytes int `json:"maxPayloadBytes,omitempty"`
+
+	// Encryption encrypts request bodies for a recipient public key, for webhooks reached through
+	// untrusted relays. It cannot be combined with compression.
+	// +optional
+	Encryption *WebhookEncryption `json:"encryption,omitempty"`
+
+	// ResultFormat is how array and object results are sent: structured (default), string to JSON
+	// encode them in the result value, or flatten to send a result per item named <name>.<index>
+	// or <name>.<key>. XML bodies default to string.
+	// +optional
+	ResultFormat WebhookResultFormat `json:"resultFormat,omitempty"`
+
+	// Sign signs request bodies keyless with cosign and attaches the signature, certificate and
+	// Rekor bundle in headers. The controller must be started with --sigstore-token-file.
+	// +optional
+	Sign bool `json:"sign,omitempty"`
+}
+
+// WebhookServiceReference references the Service of a webhook. Plain HTTP requests are sent to the ready
+// endpoints of the Service in turn, HTTPS requests to the DNS name of the Service.
+type WebhookServiceReference struct {
+	// Name is the name of the Service
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Namespace is the namespace of the Service. Defaults to the namespace of the NotificationService.
+	// NotificationServices may only reference the namespaces the controller allows besides their own.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Port is the port of the Service. It can be omitted if the Service has a single port.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port int32 `json:"port,omitempty"`
+
+	// Path is the path of the endpoint on the Service
+	// +kubebuilder:validation:Pattern=`^/`
+	// +kubebuilder:default=/
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// Scheme is the scheme of the endpoint, http or https
+	// +kubebuilder:validation:Enum=http;https
+	// +kubebuilder:default=http
+	// +optional
+	Scheme string `json:"scheme,omitempty"`
+}
+
+// WebhookEncryption encrypts webhook request bodies as a JWE in compact serialization,
+// sent with the application/jose content type
+type WebhookEncryption struct {
+	// PublicKeySecretRef selects the key of a Secret in the namespace of the NotificationService
+	// holding the PEM or JWK public key of the recipient. RSA keys are used with RSA-OAEP-256 and
+	// EC keys with ECDH-ES+A256KW, the content is encrypted with A256GCM.
+	PublicKeySecretRef corev1.SecretKeySelector `json:"publicKeySecretRef"`
+}
+
+// SlackDestination posts notifications to a Slack channel.
+// All notifications about a PipelineRun are kept on the message of its first notification.
+type SlackDestination struct {
+	// Channel is the ID or name of the channel messages are posted to
+	// +kubebuilder:validation:MinLength=1
+	Channel string `json:"channel"`
+
+	// TokenSecretRef selects the key of a Secret in the namespace of the NotificationService
+	// holding the bot token used to post messages
+	TokenSecretRef corev1.SecretKeySelector `json:"tokenSecretRef"`
+
+	// Template is a Go template rendering the message text from the notification.
+	// If not set, a summary with the status and results of the PipelineRun is posted.
+	// +optional
+	Template string `json:"template,omitempty"`
+
+	// ThreadMode is how later notifications about a PipelineRun continue its first message
+	// +kubebuilder:default=update
+	// +optional
+	ThreadMode SlackThreadMode `json:"threadMode,omitempty"`
+
+	// RerunButton adds a button to messages about finished PipelineRuns that creates a new
+	// PipelineRun with the same spec. The interactivity URL of the Slack app must point to
+	// the /slack/actions endpoint of the controller.
+	// +optional
+	RerunButton bool `json:"rerunButton,omitempty"`
+
+	// APIURL is the base URL of the Slack Web API
+	// +kubebuilder:validation:Pattern=`^https?://`
+	// +kubebuilder:validation:MaxLength=2048
+	// +kubebuilder:validation:XValidation:rule="isURL(self)",message="must be a valid URL"
+	// +optional
+	APIURL string `json:"apiURL,omitempty"`
+}
+
+// MatrixDestination posts notifications as formatted messages to a Matrix room.
+// All notifications about a PipelineRun are kept on the message of its first notification.
+type MatrixDestination struct {
+	// HomeserverURL is the base URL of the Matrix homeserver
+	// +kubebuilder:validation:Pattern=`^https?://`
+	// +kubebuilder:validation:MaxLength=2048
+	// +kubebuilder:validation:XValidation:rule="isURL(self)",message="must be a valid URL"
+	HomeserverURL string `json:"homeserverURL"`
+
+	// RoomID is the ID of the room messages are posted to, e.g. !abc:example.com.
+	// The account of the access token must have joined it.
+	// +kubebuilder:validation:MinLength=1
+	RoomID string `json:"roomID"`
+
+	// AccessTokenSecretRef selects the key of a Secret in the namespace of the NotificationService
+	// holding the access token of the account posting messages
+	AccessTokenSecretRef corev1.SecretKeySelector `json:"accessTokenSecretRef"`
+
+	// Template is a Go template rendering the HTML body of messages from the notification.
+	// If not set, a summary with the status and results of the PipelineRun is posted.
+	// +optional
+	Template string `json:"template,omitempty"`
+}
+
+// IRCDestination posts one line summaries of notifications to an IRC channel
+// +kubebuilder:validation:XValidation:rule="!has(self.sasl) || !has(self.insecure) || !self.insecure",message="sasl cannot be used without TLS"
+type IRCDestination struct {
+	// Server is the host:port of the IRC server
+	// +kubebuilder:validation:MinLength=1
+	Server string `json:"server"`
+
+	// Channel is the channel messages are posted to, e.g. #builds
+	// +kubebuilder:validation:Pattern=`^[#&]`
+	Channel string `json:"channel"`
+
+	// Nick is the nick messages are posted with
+	// +kubebuilder:default=konflux-ci
+	// +optional
+	Nick string `json:"nick,omitempty"`
+
+	// Insecure connects to the server without TLS. SASL cannot be used without TLS.
+	// +optional
+	Insecure bool `json:"insecure,omitempty"`
+
+	// ChannelKeySecretRef selects the key of a Secret in the namespace of the NotificationService
+	// holding the key of the channel, if it requires one to join
+	// +optional
+	ChannelKeySecretRef *corev1.SecretKeySelector `json:"channelKeySecretRef,omitempty"`
+
+	// SASL authenticates the nick with SASL PLAIN
+	// +optional
+	SASL *IRCSASL `json:"sasl,omitempty"`
+
+	// Template is a Go template rendering the message from the notification, newlines are replaced by spaces.
+	// If not set, the status of the PipelineRun is posted.
+	// +optional
+	Template string `json:"template,omitempty"`
+}
+
+// IRCSASL are the SASL PLAIN credentials of an IRC destination
+type IRCSASL struct {
+	// Username is the account name to authenticate as
+	// +kubebuilder:validation:MinLength=1
+	Username string `json:"username"`
+
+	// PasswordSecretRef selects the key of a Secret in the namespace of the NotificationService
+	// holding the password of the account
+	PasswordSecretRef corev1.SecretKeySelector `json:"passwordSecretRef"`
+}
+
+// XMPPDestination posts notifications to XMPP multi-user chat rooms
+type XMPPDestination struct {
+	// JID is the bare JID of the account messages are posted with, e.g. ci@example.com
+	// +kubebuilder:validation:Pattern=`^[^@/]+@[^@/]+$`
+	JID string `json:"jid"`
+
+	// PasswordSecretRef selects the key of a Secret in the namespace of the NotificationService
+	// holding the password of the account
+	PasswordSecretRef corev1.SecretKeySelector `json:"passwordSecretRef"`
+
+	// Server is the host:port of the XMPP server. Defaults to the domain of the JID on port 5222.
+	// +optional
+	Server string `json:"server,omitempty"`
+
+	// Rooms are the bare JIDs of the multi-user chat rooms messages are posted to,
+	// e.g. builds@conference.example.com
+	// +kubebuilder:validation:MinItems=1
+	Rooms []string `json:"rooms"`
+
+	// Nick is the nick of the account in the rooms. Defaults to the local part of the JID.
+	// +optional
+	Nick string `json:"nick,omitempty"`
+
+	// Insecure connects to the server without STARTTLS, sending the password in clear text
+	// +optional
+	Insecure bool `json:"insecure,omitempty"`
+
+	// Template is a Go template rendering the message from the notification.
+	// If not set, the status and results of the PipelineRun are posted.
+	// +optional
+	Template string `json:"template,omitempty"`
+}
+
+// WebPushUrgency is the urgency of web push notifications
+// +kubebuilder:validation:Enum=very-low;low;normal;high
+type WebPushUrgency string
+
+// WebPushDestination pushes notifications to the browsers subscribed to the namespace through the REST API
+type WebPushDestination struct {
+	// Urgency tells push services whether to wake devices up for notifications
+	// +kubebuilder:default=normal
+	// +optional
+	Urgency WebPushUrgency `json:"urgency,omitempty"`
+
+	// TTL is how long push services keep notifications for browsers that are offline. Defaults to 24h.
+	// +kubebuilder:validation:XValidation:rule="duration(self) > duration('0s')",message="must be positive"
+	// +optional
+	TTL *metav1.Duration `json:"ttl,omitempty"`
+
+	// Template is a Go template rendering the body of notifications.
+	// If not set, the results of the PipelineRun are listed.
+	// +optional
+	Template string `json:"template,omitempty"`
+}
+
+// FCMDestination sends notifications as Firebase Cloud Messaging push notifications to the devices
+// of a mobile app, through a topic or device registration tokens
+// +kubebuilder:validation:XValidation:rule="has(self.topic) || has(self.deviceTokensSecretRef)",message="one of topic and deviceTokensSecretRef must be set"
+type FCMDestination struct {
+	// ServiceAccountKeySecretRef selects the key of a Secret in the namespace of the NotificationService
+	// holding the JSON key of a Google service account allowed to send messages
+	ServiceAccountKeySecretRef corev1.SecretKeySelector `json:"serviceAccountKeySecretRef"`
+
+	// ProjectID is the Firebase project of the app. Defaults to the project of the service account.
+	// +optional
+	ProjectID string `json:"projectID,omitempty"`
+
+	// Topic is the topic the app subscribes devices to
+	// +optional
+	Topic string `json:"topic,omitempty"`
+
+	// DeviceTokensSecretRef selects the key of a Secret in the namespace of the NotificationService
+	// holding the registration tokens of devices, one per line
+	// +optional
+	DeviceTokensSecretRef *corev1.SecretKeySelector `json:"deviceTokensSecretRef,omitempty"`
+
+	// Template is a Go template rendering the body of notifications.
+	// If not set, the results of the PipelineRun are listed.
+	// +optional
+	Template string `json:"template,omitempty"`
+
+	// APIURL overrides the base URL of the FCM API, e.g. for a proxy
+	// +kubebuilder:validation:Pattern=`^https?://`
+	// +kubebuilder:validation:MaxLength=2048
+	// +kubebuilder:validation:XValidation:rule="isURL(self)",message="must be a valid URL"
+	// +optional
+	APIURL string `json:"apiURL,omitempty"`
+}
+
+// AlertmanagerDestination pushes the notifications about failed and long running PipelineRuns as alerts to
+// the API of a Prometheus Alertmanager, which routes, groups and silences them
+type AlertmanagerDestination struct {
+	// URL is the base URL of the Alertmanager, e.g. http://alertmanager-operated.monitoring:9093
+	// +kubebuilder:validation:Pattern=`^https?://`
+	// +kubebuilder:validation:XValidation:rule="isURL(self)",message="must be a valid URL"
+	URL string `json:"url"`
+
+	// BearerTokenSecretRef selects the key of a Secret in the namespace of the NotificationService
+	// holding a bearer token authenticating requests, e.g. to a proxy in front of the Alertmanager
+	// +optional
+	BearerTokenSecretRef *corev1.SecretKeySelector `json:"bearerTokenSecretRef,omitempty"`
+
+	// Labels are added to the labels of the alerts, e.g. severity, to route them
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// ResolveAfter is how long alerts fire before Alertmanager resolves them
+	// +kubebuilder:default="24h"
+	// +kubebuilder:validation:XValidation:rule="duration(self) > duration('0s')",message="must be positive"
+	// +optional
+	ResolveAfter *metav1.Duration `json:"resolveAfter,omitempty"`
+
+	// Template is a Go template rendering the description annotation of the alerts.
+	// If not set, the results of the PipelineRun are listed.
+	// +optional
+	Template string `json:"template,omitempty"`
+}
+
+// NotificationServiceStatus defines the observed state of NotificationService
+type NotificationServiceStatus struct {
+	// Conditions represent the latest available observations of the NotificationService
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// LastSummaryTime is when the last summary report was sent
+	// +optional
+	LastSummaryTime *metav1.Time `json:"lastSummaryTime,omitempty"`
+
+	// Backoffs are the destinations that are not sent to until their backoff after consecutive network failures ends
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Backoffs []DestinationBackoff `json:"backoffs,omitempty"`
+
+	// Deliveries summarizes the deliveries to the destinations of the NotificationService.
+	// Its size does not depend on the number of deliveries.
+	// +optional
+	Deliveries *DeliverySummary `json:"deliveries,omitempty"`
+}
+
+// DeliverySummary summarizes the deliveries to the destinations of a NotificationService
+type DeliverySummary struct {
+	// Destinations is the number of destinations of the NotificationService
+	Destinations int32 `json:"destinations"`
+	// LastDeliveryTime is when a notification was last delivered successfully to one of the destinations
+	// +optional
+	LastDeliveryTime *metav1.Time `json:"lastDeliveryTime,omitempty"`
+	// FailedLast24h is the number of failed deliveries in the last 24 hours
+	FailedLast24h int32 `json:"failedLast24h"`
+	// DeliveredLast24h is the number of successful deliveries in the last 24 hours
+	DeliveredLast24h int32 `json:"deliveredLast24h"`
+}
+
+// DestinationBackoff is the backoff of a destination after consecutive network failures
+type DestinationBackoff struct {
+	// Name is the name of the destination
+	Name string `json:"name"`
+	// Failures is the number of consecutive network failures of the destination
+	Failures int32 `json:"failures"`
+	// BackoffUntil is when notifications are sent to the destination again
+	BackoffUntil metav1.Time `json:"backoffUntil"`
+	// LastError is the error of the last failure
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:storageversion
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Destinations",type=integer,JSONPath=`.status.deliveries.destinations`
+// +kubebuilder:printcolumn:name="LastDelivery",type=date,JSONPath=`.status.deliveries.lastDeliveryTime`
+// +kubebuilder:printcolumn:name="FailedLast24h",type=integer,JSONPath=`.status.deliveries.failedLast24h`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// NotificationService is the Schema for the notificationservices API
+type NotificationService struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NotificationServiceSpec   `json:"spec,omitempty"`
+	Status NotificationServiceStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NotificationServiceList contains a list of NotificationService
+type NotificationServiceList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NotificationService `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NotificationService{}, &NotificationServiceList{})
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NotificationStateSpec holds the notification state of a PipelineRun that does not fit in its annotations
+type NotificationStateSpec struct {
+	// PipelineRun is the name of the PipelineRun, which also owns the NotificationState
+	PipelineRun string `json:"pipelineRun"`
+
+	// Values maps the sha256 hashes the state annotations of the PipelineRun hold to the JSON encoded
+	// state they stand for
+	// +optional
+	Values map[string]string `json:"values,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:printcolumn:name="PipelineRun",type=string,JSONPath=`.spec.pipelineRun`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// NotificationState is the Schema for the notificationstates API.
+// It is a companion of a PipelineRun storing the delivery state that outgrew its annotations,
+// and is garbage collected with the PipelineRun.
+type NotificationState struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec NotificationStateSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NotificationStateList contains a list of NotificationState
+type NotificationStateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NotificationState `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NotificationState{}, &NotificationStateList{})
+}
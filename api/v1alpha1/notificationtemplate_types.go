@@ -0,0 +1,59 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NotificationTemplateSpec defines a Go template shared by the destinations of NotificationServices
+type NotificationTemplateSpec struct {
+	// Template is a Go template rendering a notification, e.g. the body of webhook requests or the
+	// text of chat messages, depending on the destinations using it
+	// +kubebuilder:validation:MinLength=1
+	Template string `json:"template"`
+
+	// Description tells template authors what the template renders and which destinations it suits
+	// +optional
+	Description string `json:"description,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:printcolumn:name="Description",type=string,JSONPath=`.spec.description`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// NotificationTemplate is the Schema for the notificationtemplates API.
+// Destinations of NotificationServices in the same namespace reference it with templateRef.
+type NotificationTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec NotificationTemplateSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NotificationTemplateList contains a list of NotificationTemplate
+type NotificationTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NotificationTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NotificationTemplate{}, &NotificationTemplateList{})
+}
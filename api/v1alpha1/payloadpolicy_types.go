@@ -0,0 +1,71 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PayloadPolicySpec defines which fields and results of notifications tenants may send to their destinations
+type PayloadPolicySpec struct {
+	// NamespaceSelector selects the namespaces whose NotificationServices and NotificationTemplates the
+	// policy applies to. If not set, it applies to all namespaces.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// DeniedFields are fields of notifications tenants may not send, named as in the JSON payloads
+	// of webhooks, e.g. author or provenance.materials
+	// +optional
+	DeniedFields []string `json:"deniedFields,omitempty"`
+
+	// AllowedResults restricts the results tenants may send to the ones whose name matches one of
+	// these glob patterns, e.g. IMAGE_*. If not set, all results are allowed.
+	// +optional
+	AllowedResults []string `json:"allowedResults,omitempty"`
+
+	// DeniedResults are glob patterns of the names of the results tenants may not send, e.g. *TOKEN*
+	// +optional
+	DeniedResults []string `json:"deniedResults,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// PayloadPolicy is the Schema for the payloadpolicies API.
+// It restricts the content of the notifications sent to the destinations of the NotificationServices of the
+// selected namespaces. Templates referencing denied content are rejected on admission, and denied content
+// is removed from notifications before they are rendered.
+type PayloadPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PayloadPolicySpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// PayloadPolicyList contains a list of PayloadPolicy
+type PayloadPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PayloadPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PayloadPolicy{}, &PayloadPolicyList{})
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"fmt"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+// ConvertTo converts the NotificationService to the v1alpha1 hub version
+func (src *NotificationService) ConvertTo(dstRaw conversion.Hub) error {
+	dst, ok := dstRaw.(*v1alpha1.NotificationService)
+	if !ok {
+		return fmt.Errorf("Unsupported conversion of NotificationService to %T", dstRaw)
+	}
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = v1alpha1.NotificationServiceSpec{
+		Destinations: src.Spec.Destinations,
+		Summary:      src.Spec.Summary,
+		Watch:        src.Spec.Watch,
+	}
+	if lifecycle := src.Spec.Lifecycle; lifecycle != nil {
+		dst.Spec.NotifyOnStart = lifecycle.OnStart
+		dst.Spec.LongRunningThreshold = lifecycle.LongRunningThreshold
+	}
+	if delivery := src.Spec.Delivery; delivery != nil {
+		dst.Spec.BestEffort = delivery.BestEffort
+		dst.Spec.Paused = delivery.Paused
+		dst.Spec.SLO = delivery.SLO
+	}
+	if template := src.Spec.DefaultTemplate; template != nil {
+		dst.Spec.DefaultTemplate = template.Inline
+		dst.Spec.DefaultTemplateRef = template.Ref
+	}
+	dst.Status = v1alpha1.NotificationServiceStatus(src.Status)
+	return nil
+}
+
+// ConvertFrom converts the v1alpha1 hub version to the NotificationService.
+// Groups of settings are left unset when none of their settings is.
+func (dst *NotificationService) ConvertFrom(srcRaw conversion.Hub) error {
+	src, ok := srcRaw.(*v1alpha1.NotificationService)
+	if !ok {
+		return fmt.Errorf("Unsupported conversion of NotificationService from %T", srcRaw)
+	}
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = NotificationServiceSpec{
+		Destinations: src.Spec.Destinations,
+		Summary:      src.Spec.Summary,
+		Watch:        src.Spec.Watch,
+	}
+	if src.Spec.NotifyOnStart || src.Spec.LongRunningThreshold != nil {
+		dst.Spec.Lifecycle = &LifecycleNotifications{
+			OnStart:              src.Spec.NotifyOnStart,
+			LongRunningThreshold: src.Spec.LongRunningThreshold,
+		}
+	}
+	if src.Spec.BestEffort != nil || src.Spec.Paused || src.Spec.SLO != nil {
+		dst.Spec.Delivery = &DeliveryPolicy{BestEffort: src.Spec.BestEffort, Paused: src.Spec.Paused, SLO: src.Spec.SLO}
+	}
+	if src.Spec.DefaultTemplate != "" || src.Spec.DefaultTemplateRef != nil {
+		dst.Spec.DefaultTemplate = &DefaultTemplate{Inline: src.Spec.DefaultTemplate, Ref: src.Spec.DefaultTemplateRef}
+	}
+	dst.Status = NotificationServiceStatus(src.Status)
+	return nil
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/conversion"
+)
+
+var _ = Describe("NotificationService conversion", func() {
+	bestEffort := true
+	hub := &v1alpha1.NotificationService{
+		ObjectMeta: metav1.ObjectMeta{Name: "builds", Namespace: "tenant"},
+		Spec: v1alpha1.NotificationServiceSpec{
+			Destinations:         []v1alpha1.Destination{{Name: "hook", Webhook: &v1alpha1.WebhookDestination{URL: "https://hooks.example.com"}}},
+			NotifyOnStart:        true,
+			LongRunningThreshold: &metav1.Duration{Duration: time.Hour},
+			BestEffort:           &bestEffort,
+			Paused:               true,
+			SLO:                  &v1alpha1.DeliverySLO{Objective: "99%", Latency: metav1.Duration{Duration: time.Minute}},
+			DefaultTemplate:      "{{ .PipelineRun }}",
+			DefaultTemplateRef:   &corev1.LocalObjectReference{Name: "house-style"},
+			Summary:              &v1alpha1.SummarySpec{Schedule: "0 9 * * 1"},
+		},
+		Status: v1alpha1.NotificationServiceStatus{
+			Conditions: []metav1.Condition{{Type: "DeliverySLOMet", Status: metav1.ConditionTrue}},
+			Backoffs:   []v1alpha1.DestinationBackoff{{Name: "hook", Failures: 2}},
+		},
+	}
+
+	It("should group the settings of v1alpha1", func() {
+		converted := &NotificationService{}
+		Expect(converted.ConvertFrom(hub.DeepCopy())).To(Succeed())
+		Expect(converted.Name).To(Equal("builds"))
+		Expect(converted.Spec.Lifecycle).To(Equal(&LifecycleNotifications{OnStart: true, LongRunningThreshold: &metav1.Duration{Duration: time.Hour}}))
+		Expect(converted.Spec.Delivery.BestEffort).To(Equal(&bestEffort))
+		Expect(converted.Spec.Delivery.Paused).To(BeTrue())
+		Expect(converted.Spec.Delivery.SLO.Objective).To(Equal("99%"))
+		Expect(converted.Spec.DefaultTemplate).To(Equal(&DefaultTemplate{Inline: "{{ .PipelineRun }}", Ref: &corev1.LocalObjectReference{Name: "house-style"}}))
+		Expect(converted.Status.Backoffs).To(HaveLen(1))
+	})
+
+	It("should convert back to the same v1alpha1 NotificationService", func() {
+		converted := &NotificationService{}
+		Expect(converted.ConvertFrom(hub.DeepCopy())).To(Succeed())
+		back := &v1alpha1.NotificationService{}
+		Expect(converted.ConvertTo(back)).To(Succeed())
+		Expect(back).To(Equal(hub))
+	})
+
+	It("should leave the groups of unset settings unset", func() {
+		converted := &NotificationService{}
+		Expect(converted.ConvertFrom(&v1alpha1.NotificationService{Spec: v1alpha1.NotificationServiceSpec{
+			Destinations: hub.Spec.Destinations,
+		}})).To(Succeed())
+		Expect(converted.Spec.Lifecycle).To(BeNil())
+		Expect(converted.Spec.Delivery).To(BeNil())
+		Expect(converted.Spec.DefaultTemplate).To(BeNil())
+	})
+
+	It("should be convertible by the conversion webhook", func() {
+		scheme := runtime.NewScheme()
+		Expect(v1alpha1.AddToScheme(scheme)).To(Succeed())
+		Expect(AddToScheme(scheme)).To(Succeed())
+		Expect(conversion.IsConvertible(scheme, &v1alpha1.NotificationService{})).To(BeTrue())
+	})
+})
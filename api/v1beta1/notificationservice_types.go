@@ -0,0 +1,151 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NotificationServiceSpec defines the desired state of NotificationService.
+// It groups the settings of v1alpha1 by concern, so they can evolve separately.
+type NotificationServiceSpec struct {
+	// Destinations are the targets notifications are sent to
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=64
+	// +listType=map
+	// +listMapKey=name
+	Destinations []v1alpha1.Destination `json:"destinations"`
+
+	// Lifecycle configures the notifications sent while PipelineRuns run
+	// +optional
+	Lifecycle *LifecycleNotifications `json:"lifecycle,omitempty"`
+
+	// Delivery configures how notifications are delivered to the destinations
+	// +optional
+	Delivery *DeliveryPolicy `json:"delivery,omitempty"`
+
+	// DefaultTemplate is inherited by the destinations that set neither an inline template nor a templateRef
+	// +optional
+	DefaultTemplate *DefaultTemplate `json:"defaultTemplate,omitempty"`
+
+	// Summary sends periodic reports about the PipelineRuns of a namespace to the destinations
+	// +optional
+	Summary *v1alpha1.SummarySpec `json:"summary,omitempty"`
+
+	// Watch makes the NotificationService notify its destinations about resources of another kind in its
+	// namespace instead of PipelineRuns, every time a condition over their status becomes true
+	// +optional
+	Watch *v1alpha1.ResourceWatch `json:"watch,omitempty"`
+}
+
+// LifecycleNotifications configures the notifications sent before PipelineRuns end
+type LifecycleNotifications struct {
+	// OnStart sends a notification to the destinations when a PipelineRun starts
+	// +optional
+	OnStart bool `json:"onStart,omitempty"`
+
+	// LongRunningThreshold sends a warning notification to the destinations, and a warning event
+	// on the PipelineRun, when a PipelineRun is still running after this duration
+	// +kubebuilder:validation:XValidation:rule="duration(self) > duration('0s')",message="must be positive"
+	// +optional
+	LongRunningThreshold *metav1.Duration `json:"longRunningThreshold,omitempty"`
+}
+
+// DeliveryPolicy configures how notifications are delivered to the destinations
+type DeliveryPolicy struct {
+	// BestEffort sends notifications to the destinations without holding PipelineRuns with a
+	// finalizer until they are delivered. PipelineRuns deleted before they are handled are not
+	// notified about. Defaults to the --best-effort flag of the controller.
+	// +optional
+	BestEffort *bool `json:"bestEffort,omitempty"`
+
+	// Paused stops sending notifications and summaries to the destinations, e.g. to silence a
+	// channel during an incident. PipelineRuns completed while paused are marked as skipped
+	// for the destinations and are not notified about when the NotificationService is resumed.
+	// +optional
+	Paused bool `json:"paused,omitempty"`
+
+	// SLO is an objective of how quickly the notifications are delivered to the destinations.
+	// The DeliverySLOMet condition reports whether it is met.
+	// +optional
+	SLO *v1alpha1.DeliverySLO `json:"slo,omitempty"`
+}
+
+// DefaultTemplate is the template of the destinations that set none, inline or referenced
+type DefaultTemplate struct {
+	// Inline is a Go template
+	// +optional
+	Inline string `json:"inline,omitempty"`
+
+	// Ref names a NotificationTemplate. It is ignored if inline is set.
+	// +optional
+	Ref *corev1.LocalObjectReference `json:"ref,omitempty"`
+}
+
+// NotificationServiceStatus defines the observed state of NotificationService
+type NotificationServiceStatus struct {
+	// Conditions represent the latest available observations of the NotificationService
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// LastSummaryTime is when the last summary report was sent
+	// +optional
+	LastSummaryTime *metav1.Time `json:"lastSummaryTime,omitempty"`
+
+	// Backoffs are the destinations that are not sent to until their backoff after consecutive network failures ends
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Backoffs []v1alpha1.DestinationBackoff `json:"backoffs,omitempty"`
+
+	// Deliveries summarizes the deliveries to the destinations of the NotificationService.
+	// Its size does not depend on the number of deliveries.
+	// +optional
+	Deliveries *v1alpha1.DeliverySummary `json:"deliveries,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Destinations",type=integer,JSONPath=`.status.deliveries.destinations`
+// +kubebuilder:printcolumn:name="LastDelivery",type=date,JSONPath=`.status.deliveries.lastDeliveryTime`
+// +kubebuilder:printcolumn:name="FailedLast24h",type=integer,JSONPath=`.status.deliveries.failedLast24h`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// NotificationService is the Schema for the notificationservices API
+type NotificationService struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NotificationServiceSpec   `json:"spec,omitempty"`
+	Status NotificationServiceStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NotificationServiceList contains a list of NotificationService
+type NotificationServiceList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NotificationService `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NotificationService{}, &NotificationServiceList{})
+}
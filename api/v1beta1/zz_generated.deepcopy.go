@@ -0,0 +1,237 @@
+//go:build !ignore_autogenerated
+
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by controller-gen. DO NOT EDIT.
+
+package v1beta1
+
+import (
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+)
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DefaultTemplate) DeepCopyInto(out *DefaultTemplate) {
+	*out = *in
+	if in.Ref != nil {
+		in, out := &in.Ref, &out.Ref
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DefaultTemplate.
+func (in *DefaultTemplate) DeepCopy() *DefaultTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(DefaultTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeliveryPolicy) DeepCopyInto(out *DeliveryPolicy) {
+	*out = *in
+	if in.BestEffort != nil {
+		in, out := &in.BestEffort, &out.BestEffort
+		*out = new(bool)
+		**out = **in
+	}
+	if in.SLO != nil {
+		in, out := &in.SLO, &out.SLO
+		*out = new(v1alpha1.DeliverySLO)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeliveryPolicy.
+func (in *DeliveryPolicy) DeepCopy() *DeliveryPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(DeliveryPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LifecycleNotifications) DeepCopyInto(out *LifecycleNotifications) {
+	*out = *in
+	if in.LongRunningThreshold != nil {
+		in, out := &in.LongRunningThreshold, &out.LongRunningThreshold
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LifecycleNotifications.
+func (in *LifecycleNotifications) DeepCopy() *LifecycleNotifications {
+	if in == nil {
+		return nil
+	}
+	out := new(LifecycleNotifications)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NotificationService) DeepCopyInto(out *NotificationService) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotificationService.
+func (in *NotificationService) DeepCopy() *NotificationService {
+	if in == nil {
+		return nil
+	}
+	out := new(NotificationService)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NotificationService) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NotificationServiceList) DeepCopyInto(out *NotificationServiceList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]NotificationService, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotificationServiceList.
+func (in *NotificationServiceList) DeepCopy() *NotificationServiceList {
+	if in == nil {
+		return nil
+	}
+	out := new(NotificationServiceList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NotificationServiceList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NotificationServiceSpec) DeepCopyInto(out *NotificationServiceSpec) {
+	*out = *in
+	if in.Destinations != nil {
+		in, out := &in.Destinations, &out.Destinations
+		*out = make([]v1alpha1.Destination, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Lifecycle != nil {
+		in, out := &in.Lifecycle, &out.Lifecycle
+		*out = new(LifecycleNotifications)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Delivery != nil {
+		in, out := &in.Delivery, &out.Delivery
+		*out = new(DeliveryPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DefaultTemplate != nil {
+		in, out := &in.DefaultTemplate, &out.DefaultTemplate
+		*out = new(DefaultTemplate)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Summary != nil {
+		in, out := &in.Summary, &out.Summary
+		*out = new(v1alpha1.SummarySpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Watch != nil {
+		in, out := &in.Watch, &out.Watch
+		*out = new(v1alpha1.ResourceWatch)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotificationServiceSpec.
+func (in *NotificationServiceSpec) DeepCopy() *NotificationServiceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NotificationServiceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NotificationServiceStatus) DeepCopyInto(out *NotificationServiceStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastSummaryTime != nil {
+		in, out := &in.LastSummaryTime, &out.LastSummaryTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Backoffs != nil {
+		in, out := &in.Backoffs, &out.Backoffs
+		*out = make([]v1alpha1.DestinationBackoff, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Deliveries != nil {
+		in, out := &in.Deliveries, &out.Deliveries
+		*out = new(v1alpha1.DeliverySummary)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotificationServiceStatus.
+func (in *NotificationServiceStatus) DeepCopy() *NotificationServiceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NotificationServiceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
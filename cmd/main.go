@@ -17,8 +17,9 @@ limitations under the License.
 package main
 
 import (
-	"crypto/tls"
+	"context"
 	"flag"
+	"fmt"
 	"os"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
@@ -26,15 +27,14 @@ import (
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	"github.com/konflux-ci/notification-service/internal/controller"
-	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"github.com/konflux-ci/notification-service/pkg/manager"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
-	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
 	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/controller-runtime/pkg/healthz"
-	"sigs.k8s.io/controller-runtime/pkg/log/zap"
-	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
-	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -44,105 +44,130 @@ var (
 )
 
 func init() {
-	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
-	utilruntime.Must(tektonv1.AddToScheme(scheme))
+	utilruntime.Must(manager.AddToScheme(scheme))
 
 	// +kubebuilder:scaffold:scheme
 }
 
 func main() {
-	var metricsAddr string
-	var enableLeaderElection bool
-	var probeAddr string
-	var secureMetrics bool
-	var enableHTTP2 bool
-	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metric endpoint binds to. "+
-		"Use the port :8080. If not set, it will be 0 in order to disable the metrics server")
-	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
-	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
-		"Enable leader election for controller manager. "+
-			"Enabling this will ensure there is only one active controller manager.")
-	flag.BoolVar(&secureMetrics, "metrics-secure", false,
-		"If set the metrics endpoint is served securely")
-	flag.BoolVar(&enableHTTP2, "enable-http2", false,
-		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
-	opts := zap.Options{
-		Development: true,
-	}
-	opts.BindFlags(flag.CommandLine)
-	flag.Parse()
-
-	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
-
-	// if the enable-http2 flag is false (the default), http/2 should be disabled
-	// due to its vulnerabilities. More specifically, disabling http/2 will
-	// prevent from being vulnerable to the HTTP/2 Stream Cancellation and
-	// Rapid Reset CVEs. For more information see:
-	// - https://github.com/advisories/GHSA-qppj-fm5r-hxr3
-	// - https://github.com/advisories/GHSA-4374-p667-p6c8
-	disableHTTP2 := func(c *tls.Config) {
-		setupLog.Info("disabling http/2")
-		c.NextProtos = []string{"http/1.1"}
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(runConfigCommand(os.Args[2:]))
 	}
 
-	tlsOpts := []func(*tls.Config){}
-	if !enableHTTP2 {
-		tlsOpts = append(tlsOpts, disableHTTP2)
+	opts := manager.NewOptions()
+	opts.BindFlags(flag.CommandLine)
+	if err := opts.Parse(flag.CommandLine, os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
-
-	webhookServer := webhook.NewServer(webhook.Options{
-		TLSOpts: tlsOpts,
-	})
-
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme: scheme,
-		Metrics: metricsserver.Options{
-			BindAddress:   metricsAddr,
-			SecureServing: secureMetrics,
-			TLSOpts:       tlsOpts,
-		},
-		WebhookServer:          webhookServer,
-		HealthProbeBindAddress: probeAddr,
-		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "75765374.konflux.ci",
-		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
-		// when the Manager ends. This requires the binary to immediately end when the
-		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
-		// speeds up voluntary leader transitions as the new leader don't have to wait
-		// LeaseDuration time first.
-		//
-		// In the default scaffold provided, the program ends immediately after
-		// the manager stops, so would be fine to enable this option. However,
-		// if you are doing or is intended to do any operation such as perform cleanups
-		// after the manager stops then its usage might be unsafe.
-		// LeaderElectionReleaseOnCancel: true,
-	})
-	if err != nil {
-		setupLog.Error(err, "unable to start manager")
-		os.Exit(1)
+	opts.Scheme = scheme
+	opts.Setup = func(mgr ctrl.Manager) error {
+		// +kubebuilder:scaffold:builder
+		return nil
 	}
 
-	if err = (&controller.NotificationServiceReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "NotificationService")
+	if err := manager.Run(ctrl.SetupSignalHandler(), opts); err != nil {
+		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
-	// +kubebuilder:scaffold:builder
+}
 
-	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
-		setupLog.Error(err, "unable to set up health check")
-		os.Exit(1)
-	}
-	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
-		setupLog.Error(err, "unable to set up ready check")
-		os.Exit(1)
+// runConfigCommand runs the config subcommand and returns its exit code:
+// "config export --namespace <namespace>" prints the effective notification configuration of the namespace
+// as YAML, read from the cluster of the kubeconfig, and "config validate <directory>..." checks the
+// notification configuration of the directories and the controller configuration file offline
+func runConfigCommand(args []string) int {
+	usage := "Usage: manager config export --namespace <namespace> [--config <file>] [--default-namespace <namespace>] [--kubeconfig <file>]\n" +
+		"       manager config validate [--config <file>] <directory>..."
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
 	}
-
-	setupLog.Info("starting manager")
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
-		setupLog.Error(err, "problem running manager")
-		os.Exit(1)
+	switch args[0] {
+	case "export":
+		flags := flag.NewFlagSet("config export", flag.ContinueOnError)
+		namespace := flags.String("namespace", "", "The namespace whose configuration is exported")
+		configFile := flags.String("config", "", "The controller configuration file, see --config of the manager")
+		defaultNamespace := flags.String("default-namespace", "", "The default namespace of the manager, see --default-namespace")
+		bestEffort := flags.Bool("best-effort", false, "Whether the manager runs with --best-effort")
+		kubeconfig := flags.String("kubeconfig", "", "The kubeconfig file, the in-cluster or default configuration if not set")
+		if err := flags.Parse(args[1:]); err != nil {
+			return 2
+		}
+		if *namespace == "" || flags.NArg() > 0 {
+			fmt.Fprintln(os.Stderr, usage)
+			return 2
+		}
+		var controllerConfig *controller.ConfigFile
+		var err error
+		if *configFile != "" {
+			controllerConfig, err = controller.NewConfigFile(*configFile)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+		}
+		var restConfig *rest.Config
+		if *kubeconfig != "" {
+			restConfig, err = clientcmd.BuildConfigFromFlags("", *kubeconfig)
+		} else {
+			restConfig, err = ctrl.GetConfig()
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		c, err := client.New(restConfig, client.Options{Scheme: scheme})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		exported, err := controller.ExportConfig(context.Background(), &controller.NotificationServiceReconciler{
+			Client:           c,
+			Log:              ctrl.Log.WithName("export"),
+			ConfigFile:       controllerConfig,
+			DefaultNamespace: *defaultNamespace,
+			BestEffort:       *bestEffort,
+		}, *namespace)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		data, err := yaml.Marshal(exported)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		_, _ = os.Stdout.Write(data)
+		return 0
+	case "validate":
+		flags := flag.NewFlagSet("config validate", flag.ContinueOnError)
+		configFile := flags.String("config", "", "The controller configuration file, see --config of the manager")
+		if err := flags.Parse(args[1:]); err != nil {
+			return 2
+		}
+		if flags.NArg() == 0 && *configFile == "" {
+			fmt.Fprintln(os.Stderr, usage)
+			return 2
+		}
+		failed := false
+		if *configFile != "" {
+			if _, err := controller.NewConfigFile(*configFile); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				failed = true
+			}
+		}
+		for _, dir := range flags.Args() {
+			if err := controller.ValidateConfigDirectory(dir); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				failed = true
+			}
+		}
+		if failed {
+			return 1
+		}
+		return 0
 	}
+	fmt.Fprintln(os.Stderr, usage)
+	return 2
 }
@@ -0,0 +1,118 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// notification-receiver-sim is a webhook receiver for local development and demos of the controller.
+// It accepts the notifications of webhook destinations, verifies their signatures, prints their payloads and
+// delays or fails requests on demand.
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/konflux-ci/notification-service/pkg/delivery"
+	"github.com/konflux-ci/notification-service/pkg/notifications"
+)
+
+func main() {
+	var bindAddress string
+	var fulcioRootsFile string
+	var identity string
+	var issuer string
+	var decryptionKeyFile string
+	var deduplicate bool
+	opts := notifications.SimulatorOptions{Output: os.Stdout}
+	flag.StringVar(&bindAddress, "bind-address", ":8090", "The address the receiver binds to")
+	flag.StringVar(&fulcioRootsFile, "fulcio-roots", "",
+		"A PEM file with the Fulcio root certificates. If set, requests whose signature does not verify are rejected")
+	flag.StringVar(&identity, "identity", "",
+		"The identity of the controller signatures must be issued to, e.g. "+
+			"https://kubernetes.io/namespaces/notification-service/serviceaccounts/notification-service-controller-manager")
+	flag.StringVar(&issuer, "issuer", "", "The OIDC issuer of the identity of the controller. If not set, any issuer is accepted")
+	flag.StringVar(&decryptionKeyFile, "decryption-key", "",
+		"A PEM file with the private key decrypting encrypted requests. If not set, encrypted requests are rejected")
+	flag.BoolVar(&deduplicate, "deduplicate", false,
+		"If set, deliveries are processed once, retries of processed deliveries are acknowledged without being printed")
+	flag.DurationVar(&opts.Latency, "latency", 0, "How long every response is delayed")
+	flag.DurationVar(&opts.Jitter, "jitter", 0, "The maximum random duration added to --latency")
+	flag.Float64Var(&opts.FailureRate, "failure-rate", 0, "The probability, from 0 to 1, of a request being failed")
+	flag.IntVar(&opts.FailFirst, "fail-first", 0, "The number of attempts of every delivery that are failed before it is accepted")
+	flag.IntVar(&opts.FailureStatus, "failure-status", http.StatusServiceUnavailable, "The status of failed requests")
+	flag.Parse()
+
+	if opts.FailureRate < 0 || opts.FailureRate > 1 {
+		log.Fatalf("Invalid failure rate %v, it must be between 0 and 1", opts.FailureRate)
+	}
+	if fulcioRootsFile != "" {
+		roots, err := os.ReadFile(fulcioRootsFile)
+		if err != nil {
+			log.Fatalf("Failed to read Fulcio roots: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(roots) {
+			log.Fatalf("No certificates found in Fulcio roots file %s", fulcioRootsFile)
+		}
+		opts.Verifier = &notifications.Verifier{Roots: pool, Identity: identity, Issuer: issuer}
+	}
+	if decryptionKeyFile != "" {
+		key, err := readPrivateKey(decryptionKeyFile)
+		if err != nil {
+			log.Fatalf("Failed to read decryption key: %v", err)
+		}
+		opts.DecryptionKey = key
+	}
+	if deduplicate {
+		opts.Store = &delivery.MemoryStore{}
+	}
+
+	server := &http.Server{
+		Addr:              bindAddress,
+		Handler:           notifications.NewSimulator(opts),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.Printf("Receiving notifications on %s", bindAddress)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+}
+
+// readPrivateKey reads a PEM encoded PKCS #8, PKCS #1 or EC private key
+func readPrivateKey(path string) (any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("No PEM block found in %s", path)
+	}
+	switch block.Type {
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	return nil, fmt.Errorf("Unsupported private key PEM block %s", block.Type)
+}
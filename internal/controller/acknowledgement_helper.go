@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/konflux-ci/operator-toolkit/metadata"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NotificationAwaitingAcknowledgementAnnotation holds a JSON map from destination to the
+// deadline of its acknowledgement, for destinations that acknowledge notifications asynchronously
+var NotificationAwaitingAcknowledgementAnnotation = DefaultMarkerPrefix + "/awaiting-acknowledgement"
+
+// CallbackPath is the path of the endpoint receiving acknowledgements, followed by the callback token
+const CallbackPath string = "/acknowledge/"
+
+// GetAcknowledgementDeadlines returns the destinations the pipelineRun awaits an acknowledgement from
+// Return error if the annotation is malformed
+func GetAcknowledgementDeadlines(pipelineRun *tektonv1.PipelineRun) (map[string]time.Time, error) {
+	value, ok := pipelineRun.GetAnnotations()[NotificationAwaitingAcknowledgementAnnotation]
+	if !ok || value == "" {
+		return map[string]time.Time{}, nil
+	}
+	deadlines := map[string]time.Time{}
+	err := json.Unmarshal([]byte(value), &deadlines)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode acknowledgement deadlines of pipelinerun %s: %w", pipelineRun.Name, err)
+	}
+	return deadlines, nil
+}
+
+// SetAcknowledgementDeadlines stores the acknowledgement deadlines in the pipelineRun
+// The annotation is removed if there are no deadlines.
+// If the annotation was not updated successfully, a non-nil error is returned.
+func SetAcknowledgementDeadlines(ctx context.Context, pipelineRun *tektonv1.PipelineRun, c client.Client, deadlines map[string]time.Time) error {
+	var value []byte
+	if len(deadlines) > 0 {
+		var err error
+		value, err = json.Marshal(deadlines)
+		if err != nil {
+			return fmt.Errorf("Failed to encode acknowledgement deadlines: %w", err)
+		}
+	}
+	err := applyPipelineRunMetadata(ctx, pipelineRun, c, true, func(applied *tektonv1.PipelineRun) {
+		if value == nil {
+			delete(applied.Annotations, NotificationAwaitingAcknowledgementAnnotation)
+			return
+		}
+		_ = metadata.SetAnnotation(&applied.ObjectMeta, NotificationAwaitingAcknowledgementAnnotation, string(value))
+	})
+	if err != nil {
+		return fmt.Errorf("Error occurred while patching the acknowledgement deadlines of pipelineRun: %w", err)
+	}
+	return nil
+}
+
+// PendingAcknowledgementTimeout returns how long the pipelineRun still has to wait for acknowledgements
+// Zero is returned if all acknowledgements were received or their deadlines passed
+func PendingAcknowledgementTimeout(deadlines map[string]time.Time, now time.Time) time.Duration {
+	var remaining time.Duration
+	for _, deadline := range deadlines {
+		if wait := deadline.Sub(now); wait > remaining {
+			remaining = wait
+		}
+	}
+	return remaining
+}
+
+// NewCallbackToken returns a token identifying the pipelineRun and destination, signed with the secret
+func NewCallbackToken(secret []byte, pipelineRun types.NamespacedName, destination string) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(pipelineRun.String() + "|" + destination))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(signCallback(secret, payload))
+}
+
+// ParseCallbackToken verifies the token and returns the pipelineRun and destination it identifies
+// Return error if the token is malformed or its signature does not match
+func ParseCallbackToken(secret []byte, token string) (types.NamespacedName, string, error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return types.NamespacedName{}, "", errors.New("malformed callback token")
+	}
+	decodedSignature, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(decodedSignature, signCallback(secret, payload)) {
+		return types.NamespacedName{}, "", errors.New("invalid callback token signature")
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return types.NamespacedName{}, "", errors.New("malformed callback token")
+	}
+	name, destination, ok := strings.Cut(string(decoded), "|")
+	namespace, name, hasNamespace := strings.Cut(name, "/")
+	if !ok || !hasNamespace {
+		return types.NamespacedName{}, "", errors.New("malformed callback token")
+	}
+	return types.NamespacedName{Namespace: namespace, Name: name}, destination, nil
+}
+
+func signCallback(secret []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
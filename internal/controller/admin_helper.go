@@ -0,0 +1,318 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// TestNotificationPipelineRun is the pipelinerun of test notifications that do not set one
+const TestNotificationPipelineRun string = "test-notification"
+
+var (
+	// ErrUnauthenticated is returned for bearer tokens that are missing or not valid
+	ErrUnauthenticated = errors.New("invalid bearer token")
+	// ErrForbidden is returned when the user of a bearer token is not allowed to perform a request
+	ErrForbidden = errors.New("forbidden")
+	// ErrNoPayload is returned when resending a delivery that was recorded without its payload
+	ErrNoPayload = errors.New("delivery has no recorded payload")
+)
+
+// DeliveryResult is the outcome of a notification sent through the admin APIs
+type DeliveryResult struct {
+	Destination     string `json:"destination"`
+	Succeeded       bool   `json:"succeeded"`
+	ResponseCode    int    `json:"responseCode,omitempty"`
+	ResponseExcerpt string `json:"responseExcerpt,omitempty"`
+	Error           string `json:"error,omitempty"`
+}
+
+// AuthorizeBearerToken authenticates the token and checks that its user can perform the verb
+// on the notificationdeliveries of the namespace.
+// Return ErrUnauthenticated or ErrForbidden if the request is rejected
+func AuthorizeBearerToken(ctx context.Context, c client.Client, token string, namespace string, verb string) error {
+	if token == "" {
+		return ErrUnauthenticated
+	}
+	tokenReview := &authenticationv1.TokenReview{Spec: authenticationv1.TokenReviewSpec{Token: token}}
+	err := c.Create(ctx, tokenReview)
+	if err != nil {
+		return fmt.Errorf("Failed to review token: %w", err)
+	}
+	if !tokenReview.Status.Authenticated {
+		return ErrUnauthenticated
+	}
+	user := tokenReview.Status.User
+	extra := map[string]authorizationv1.ExtraValue{}
+	for key, value := range user.Extra {
+		extra[key] = authorizationv1.ExtraValue(value)
+	}
+	accessReview := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   user.Username,
+			UID:    user.UID,
+			Groups: user.Groups,
+			Extra:  extra,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      verb,
+				Group:     v1alpha1.GroupVersion.Group,
+				Resource:  "notificationdeliveries",
+			},
+		},
+	}
+	err = c.Create(ctx, accessReview)
+	if err != nil {
+		return fmt.Errorf("Failed to review access: %w", err)
+	}
+	if !accessReview.Status.Allowed {
+		return fmt.Errorf("%w: %s cannot %s notificationdeliveries in %s", ErrForbidden, user.Username, verb, namespace)
+	}
+	return nil
+}
+
+// ListDeliveries lists the delivery records of the namespace, only those of the pipelinerun if it is set.
+// Payloads are omitted.
+func ListDeliveries(ctx context.Context, c client.Reader, namespace string, pipelineRun string) ([]v1alpha1.NotificationDelivery, error) {
+	deliveries := &v1alpha1.NotificationDeliveryList{}
+	err := c.List(ctx, deliveries, client.InNamespace(namespace))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list deliveries in %s: %w", namespace, err)
+	}
+	items := []v1alpha1.NotificationDelivery{}
+	for _, delivery := range deliveries.Items {
+		if pipelineRun != "" && delivery.Spec.PipelineRun != pipelineRun {
+			continue
+		}
+		delivery.Spec.Payload = ""
+		items = append(items, delivery)
+	}
+	return items, nil
+}
+
+// ResendDelivery sends the recorded payload of the delivery to its destination again and records the new attempt
+// Return ErrNoPayload if the payload was not recorded
+func ResendDelivery(ctx context.Context, r *NotificationServiceReconciler, delivery *v1alpha1.NotificationDelivery) (DeliveryResult, error) {
+	if delivery.Spec.Payload == "" {
+		return DeliveryResult{}, ErrNoPayload
+	}
+	notification := &notifier.Notification{}
+	err := json.Unmarshal([]byte(delivery.Spec.Payload), notification)
+	if err != nil {
+		return DeliveryResult{}, fmt.Errorf("%w: %s", ErrNoPayload, err)
+	}
+	destination, err := ResolveDestination(ctx, r, delivery.Namespace, delivery.Spec.Destination)
+	if err != nil {
+		return DeliveryResult{}, err
+	}
+	pipelineRun := &tektonv1.PipelineRun{}
+	err = r.Client.Get(ctx, types.NamespacedName{Namespace: delivery.Namespace, Name: delivery.Spec.PipelineRun}, pipelineRun)
+	if err != nil {
+		if !k8serrors.IsNotFound(err) {
+			return DeliveryResult{}, fmt.Errorf("Failed to get pipelinerun %s: %w", delivery.Spec.PipelineRun, err)
+		}
+		pipelineRun = &tektonv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: delivery.Namespace, Name: delivery.Spec.PipelineRun}}
+	}
+	err = LoadPipelineRunState(ctx, r.Client, pipelineRun)
+	if err != nil {
+		return DeliveryResult{}, err
+	}
+	return sendAdminNotification(ctx, r, pipelineRun, destination, notification, true), nil
+}
+
+// SendTestNotification sends the notification to a destination of a NotificationService of the namespace
+// A minimal notification is sent for empty fields. Test notifications are not recorded as deliveries.
+func SendTestNotification(ctx context.Context, r *NotificationServiceReconciler, namespace string,
+	notificationService string, destinationName string, notification *notifier.Notification) (DeliveryResult, error) {
+	if notification.PipelineRun == "" {
+		notification.PipelineRun = TestNotificationPipelineRun
+	}
+	if notification.Status == "" {
+		notification.Status = notifier.StatusSucceeded
+	}
+	notification.Namespace = namespace
+	destination, err := ResolveDestination(ctx, r, namespace, namespace+"/"+notificationService+"/"+destinationName)
+	if err != nil {
+		return DeliveryResult{}, err
+	}
+	pipelineRun := &tektonv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: notification.PipelineRun}}
+	return sendAdminNotification(ctx, r, pipelineRun, destination, notification, false), nil
+}
+
+// ResolveDestination returns the notifier of the destination with the given name as recorded in
+// deliveries: the default notifier, a default destination of the configuration, a destination of a
+// ClusterNotificationService selecting the namespace, a namespace route or a destination of a
+// NotificationService applying to the pipelineruns of the namespace, so deliveries cannot be sent
+// to the destinations of other namespaces.
+// Return a NotFound error if the destination does not exist or does not apply to the namespace
+func ResolveDestination(ctx context.Context, r *NotificationServiceReconciler, namespace string, name string) (DestinationNotifier, error) {
+	if name == DefaultDestinationName {
+		if r.Notifier == nil {
+			return DestinationNotifier{}, errDestinationNotFound(name)
+		}
+		return DestinationNotifier{Name: name, Notifier: r.Notifier}, nil
+	}
+	parts := strings.Split(name, "/")
+	if len(parts) == 2 && parts[0] == DefaultDestinationName {
+		for _, destination := range GetConfigDestinations(ctx, r.Client, r.Log, r.ConfigFile.Get()) {
+			if destination.Name == name {
+				return destination, nil
+			}
+		}
+		return DestinationNotifier{}, errDestinationNotFound(name)
+	}
+	if len(parts) != 3 {
+		return DestinationNotifier{}, errDestinationNotFound(name)
+	}
+	if parts[0] == ClusterDestinationPrefix {
+		clusterNotificationServices, err := GetClusterNotificationServices(ctx, r.Client, r.Log, namespace)
+		if err != nil {
+			return DestinationNotifier{}, err
+		}
+		for i := range clusterNotificationServices {
+			if clusterNotificationServices[i].Name != parts[1] {
+				continue
+			}
+			for _, destination := range GetClusterDestinations(ctx, r.Client, r.Log, &clusterNotificationServices[i], nil) {
+				if destination.Name == name {
+					return destination, nil
+				}
+			}
+		}
+		// A namespace may also be named like the prefix
+		if namespace != ClusterDestinationPrefix {
+			return DestinationNotifier{}, errDestinationNotFound(name)
+		}
+	}
+	if parts[0] != namespace {
+		applies, err := appliesDefaultNotificationService(ctx, r, namespace, types.NamespacedName{Namespace: parts[0], Name: parts[1]})
+		if err != nil {
+			return DestinationNotifier{}, err
+		}
+		if !applies {
+			return DestinationNotifier{}, errDestinationNotFound(name)
+		}
+	}
+	if parts[1] == "namespace" && r.NamespaceRouting {
+		_, routes, err := GetNamespaceRouting(ctx, r, &tektonv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: namespace}})
+		if err != nil {
+			return DestinationNotifier{}, err
+		}
+		for _, route := range routes {
+			if route.Name == name {
+				return route, nil
+			}
+		}
+	}
+	notificationService := &v1alpha1.NotificationService{}
+	err := r.Client.Get(ctx, types.NamespacedName{Namespace: parts[0], Name: parts[1]}, notificationService)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return DestinationNotifier{}, errDestinationNotFound(name)
+		}
+		return DestinationNotifier{}, fmt.Errorf("Failed to get NotificationService %s/%s: %w", parts[0], parts[1], err)
+	}
+	for _, destination := range notificationService.Spec.Destinations {
+		if destination.Name != parts[2] {
+			continue
+		}
+		destination = InheritDefaultTemplate(destination, &notificationService.Spec)
+		n, err := NewTenantNotifierForDestination(ctx, r.Client, notificationService.Namespace, destination)
+		if err != nil {
+			return DestinationNotifier{}, err
+		}
+		return DestinationNotifier{Name: name, Notifier: n,
+			NotificationService: types.NamespacedName{Namespace: notificationService.Namespace, Name: notificationService.Name}}, nil
+	}
+	return DestinationNotifier{}, errDestinationNotFound(name)
+}
+
+// appliesDefaultNotificationService returns a boolean indicating whether the NotificationService of another
+// namespace is one of the NotificationServices of the default namespace applying to the pipelineruns of the namespace
+func appliesDefaultNotificationService(ctx context.Context, r *NotificationServiceReconciler, namespace string, name types.NamespacedName) (bool, error) {
+	if r.DefaultNamespace == "" || name.Namespace != r.DefaultNamespace {
+		return false, nil
+	}
+	notificationServices, err := GetNamespaceNotificationServices(ctx, r, namespace)
+	if err != nil {
+		return false, err
+	}
+	for _, notificationService := range notificationServices {
+		if notificationService.Namespace == name.Namespace && notificationService.Name == name.Name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func errDestinationNotFound(name string) error {
+	return k8serrors.NewNotFound(v1alpha1.GroupVersion.WithResource("destinations").GroupResource(), name)
+}
+
+// sendAdminNotification delivers the notification to the destination, emits the delivery event on the
+// pipelinerun if it exists and records the attempt in the audit log and, if record is set, as a NotificationDelivery.
+func sendAdminNotification(ctx context.Context, r *NotificationServiceReconciler, pipelineRun *tektonv1.PipelineRun,
+	destination DestinationNotifier, notification *notifier.Notification, record bool) DeliveryResult {
+	logger := r.Log.WithValues("destination", destination.Name)
+	start := time.Now()
+	done := startDelivery()
+	restricted, err := r.restrictPayload(ctx, pipelineRun.Namespace, []DestinationNotifier{destination}, notification)
+	var response *notifier.Response
+	if err == nil {
+		notification = payloadFor(destination, notification, restricted)
+		var scanned *notifier.Notification
+		scanned, err = notifier.ScanNotification(notification)
+		if err == nil {
+			notification = scanned
+			response, err = notifier.Deliver(ctx, destination.Notifier, notification)
+		}
+	}
+	done(err)
+	if pipelineRun.UID != "" {
+		RecordDeliveryEvent(r, pipelineRun, destination.Name, response, err)
+	}
+	if record && r.RecordDeliveries {
+		recordErr := CreateDeliveryRecord(ctx, r, pipelineRun, destination.Name, notification, response, time.Since(start), err)
+		if recordErr != nil {
+			logger.Error(recordErr, "Failed to record delivery")
+		}
+	}
+	if r.AuditLog != nil {
+		auditErr := r.AuditLog.Append(ctx, destination.Name, notification, err)
+		if auditErr != nil {
+			logger.Error(auditErr, "Failed to append to audit log")
+		}
+	}
+	result := DeliveryResult{Destination: destination.Name, Succeeded: err == nil}
+	if response != nil {
+		result.ResponseCode = response.StatusCode
+		result.ResponseExcerpt = response.Excerpt
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// logAdminError logs errors that are not caused by the request
+func logAdminError(logger logr.Logger, err error, message string) {
+	if err == nil || k8serrors.IsNotFound(err) || errors.Is(err, ErrUnauthenticated) ||
+		errors.Is(err, ErrForbidden) || errors.Is(err, ErrNoPayload) {
+		return
+	}
+	logger.Error(err, message)
+}
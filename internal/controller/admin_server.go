@@ -0,0 +1,265 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	adminv1 "github.com/konflux-ci/notification-service/pkg/proto/admin/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// AdminServer serves the admin.v1.NotificationAdmin gRPC service, the typed equivalent of the REST API
+type AdminServer struct {
+	adminv1.UnimplementedNotificationAdminServer
+	// Reconciler provides the client, the default notifier and the delivery recording settings
+	Reconciler *NotificationServiceReconciler
+	// Watcher streams the delivery records of WatchDeliveries
+	Watcher client.WithWatch
+	Log     logr.Logger
+	// BindAddress is the address the admin service listens on
+	BindAddress string
+	// CertFile and KeyFile serve the admin service over TLS, if set
+	CertFile string
+	KeyFile  string
+}
+
+// NeedLeaderElection returns false so every replica serves the admin service
+func (s *AdminServer) NeedLeaderElection() bool {
+	return false
+}
+
+// Start serves the admin service until the context is cancelled
+func (s *AdminServer) Start(ctx context.Context) error {
+	var opts []grpc.ServerOption
+	if s.CertFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(s.CertFile, s.KeyFile)
+		if err != nil {
+			return fmt.Errorf("Failed to load admin service certificate: %w", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	listener, err := net.Listen("tcp", s.BindAddress)
+	if err != nil {
+		return fmt.Errorf("Failed to listen on %s: %w", s.BindAddress, err)
+	}
+	server := grpc.NewServer(opts...)
+	adminv1.RegisterNotificationAdminServer(server, s)
+	go func() {
+		<-ctx.Done()
+		server.GracefulStop()
+	}()
+	s.Log.Info("Serving admin service", "address", s.BindAddress)
+	err = server.Serve(listener)
+	if errors.Is(err, grpc.ErrServerStopped) {
+		return nil
+	}
+	return err
+}
+
+// ListDeliveries lists the delivery records of a namespace, without payloads
+func (s *AdminServer) ListDeliveries(ctx context.Context, req *adminv1.ListDeliveriesRequest) (*adminv1.ListDeliveriesResponse, error) {
+	err := s.authorize(ctx, req.GetNamespace(), "list")
+	if err != nil {
+		return nil, err
+	}
+	deliveries, err := ListDeliveries(ctx, s.Reconciler.Client, req.GetNamespace(), req.GetPipelineRun())
+	if err != nil {
+		return nil, s.status(err, "Failed to list deliveries")
+	}
+	response := &adminv1.ListDeliveriesResponse{}
+	for i := range deliveries {
+		response.Deliveries = append(response.Deliveries, deliveryToProto(&deliveries[i]))
+	}
+	return response, nil
+}
+
+// GetDelivery returns a delivery record, including its payload
+func (s *AdminServer) GetDelivery(ctx context.Context, req *adminv1.GetDeliveryRequest) (*adminv1.Delivery, error) {
+	delivery, err := s.delivery(ctx, req.GetNamespace(), req.GetName(), "get")
+	if err != nil {
+		return nil, err
+	}
+	return deliveryToProto(delivery), nil
+}
+
+// ResendDelivery sends the payload of a delivery to its destination again
+func (s *AdminServer) ResendDelivery(ctx context.Context, req *adminv1.ResendDeliveryRequest) (*adminv1.DeliveryResult, error) {
+	delivery, err := s.delivery(ctx, req.GetNamespace(), req.GetName(), "create")
+	if err != nil {
+		return nil, err
+	}
+	result, err := ResendDelivery(ctx, s.Reconciler, delivery)
+	if err != nil {
+		return nil, s.status(err, "Failed to resend delivery")
+	}
+	return resultToProto(result), nil
+}
+
+// SendTestNotification sends a notification to a destination of a NotificationService
+func (s *AdminServer) SendTestNotification(ctx context.Context, req *adminv1.SendTestNotificationRequest) (*adminv1.DeliveryResult, error) {
+	err := s.authorize(ctx, req.GetNamespace(), "create")
+	if err != nil {
+		return nil, err
+	}
+	notification := &notifier.Notification{}
+	if strings.TrimSpace(req.GetPayload()) != "" {
+		err = json.Unmarshal([]byte(req.GetPayload()), notification)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "payload is not a notification: %s", err)
+		}
+	}
+	result, err := SendTestNotification(ctx, s.Reconciler, req.GetNamespace(), req.GetNotificationService(), req.GetDestination(), notification)
+	if err != nil {
+		return nil, s.status(err, "Failed to send test notification")
+	}
+	return resultToProto(result), nil
+}
+
+// WatchDeliveries streams the delivery records created in a namespace until the client cancels the call
+func (s *AdminServer) WatchDeliveries(req *adminv1.WatchDeliveriesRequest, stream adminv1.NotificationAdmin_WatchDeliveriesServer) error {
+	ctx := stream.Context()
+	err := s.authorize(ctx, req.GetNamespace(), "watch")
+	if err != nil {
+		return err
+	}
+	watcher, err := s.Watcher.Watch(ctx, &v1alpha1.NotificationDeliveryList{}, client.InNamespace(req.GetNamespace()))
+	if err != nil {
+		return s.status(err, "Failed to watch deliveries")
+	}
+	defer watcher.Stop()
+	// Send the headers so clients know that deliveries created from now on are streamed
+	err = stream.SendHeader(metadata.MD{})
+	if err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return status.Error(codes.Unavailable, "watch closed, call WatchDeliveries again")
+			}
+			delivery, isDelivery := event.Object.(*v1alpha1.NotificationDelivery)
+			if event.Type != watch.Added || !isDelivery {
+				continue
+			}
+			if req.GetPipelineRun() != "" && delivery.Spec.PipelineRun != req.GetPipelineRun() {
+				continue
+			}
+			delivery.Spec.Payload = ""
+			err = stream.Send(deliveryToProto(delivery))
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// delivery authorizes the call for the verb and returns the delivery record
+func (s *AdminServer) delivery(ctx context.Context, namespace string, name string, verb string) (*v1alpha1.NotificationDelivery, error) {
+	err := s.authorize(ctx, namespace, verb)
+	if err != nil {
+		return nil, err
+	}
+	delivery := &v1alpha1.NotificationDelivery{}
+	err = s.Reconciler.Client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, delivery)
+	if err != nil {
+		return nil, s.status(err, "Failed to get delivery")
+	}
+	return delivery, nil
+}
+
+// authorize checks the bearer token in the authorization metadata of the call with AuthorizeBearerToken
+func (s *AdminServer) authorize(ctx context.Context, namespace string, verb string) error {
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, value := range md.Get("authorization") {
+			if bearer, ok := strings.CutPrefix(value, "Bearer "); ok {
+				token = bearer
+			}
+		}
+	}
+	err := AuthorizeBearerToken(ctx, s.Reconciler.Client, token, namespace, verb)
+	if err != nil {
+		return s.status(err, "Failed to authorize call")
+	}
+	return nil
+}
+
+// status converts the error to the matching gRPC status
+func (s *AdminServer) status(err error, message string) error {
+	logAdminError(s.Log, err, message)
+	switch {
+	case errors.Is(err, ErrUnauthenticated):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, ErrForbidden):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, ErrNoPayload):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case k8serrors.IsNotFound(err):
+		return status.Error(codes.NotFound, err.Error())
+	default:
+		return status.Error(codes.Internal, strings.ToLower(message[:1])+message[1:])
+	}
+}
+
+func deliveryToProto(delivery *v1alpha1.NotificationDelivery) *adminv1.Delivery {
+	return &adminv1.Delivery{
+		Name:            delivery.Name,
+		Namespace:       delivery.Namespace,
+		PipelineRun:     delivery.Spec.PipelineRun,
+		Destination:     delivery.Spec.Destination,
+		PayloadHash:     delivery.Spec.PayloadHash,
+		Payload:         delivery.Spec.Payload,
+		Succeeded:       delivery.Spec.Succeeded,
+		ResponseCode:    int32(delivery.Spec.ResponseCode),
+		ResponseExcerpt: delivery.Spec.ResponseExcerpt,
+		Latency:         durationpb.New(delivery.Spec.Latency.Duration),
+		Error:           delivery.Spec.Error,
+		CreateTime:      timestamppb.New(delivery.CreationTimestamp.Time),
+	}
+}
+
+func resultToProto(result DeliveryResult) *adminv1.DeliveryResult {
+	return &adminv1.DeliveryResult{
+		Destination:     result.Destination,
+		Succeeded:       result.Succeeded,
+		ResponseCode:    int32(result.ResponseCode),
+		ResponseExcerpt: result.ResponseExcerpt,
+		Error:           result.Error,
+	}
+}
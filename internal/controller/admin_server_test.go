@@ -0,0 +1,193 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	adminv1 "github.com/konflux-ci/notification-service/pkg/proto/admin/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Admin server", func() {
+	var (
+		admin    adminv1.NotificationAdminClient
+		received []*notifier.Notification
+		token    string
+	)
+
+	withToken := func(bearer string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+bearer)
+	}
+
+	BeforeEach(func() {
+		received = nil
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			notification := &notifier.Notification{}
+			Expect(json.NewDecoder(req.Body).Decode(notification)).To(Succeed())
+			received = append(received, notification)
+		}))
+		DeferCleanup(receiver.Close)
+		notificationService := &v1alpha1.NotificationService{
+			ObjectMeta: metav1.ObjectMeta{Name: "admin", Namespace: "default"},
+			Spec: v1alpha1.NotificationServiceSpec{
+				Destinations: []v1alpha1.Destination{{
+					Name:    "hook",
+					Webhook: &v1alpha1.WebhookDestination{URL: receiver.URL},
+				}},
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), notificationService)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), notificationService)
+
+		watcher, err := client.NewWithWatch(cfg, client.Options{Scheme: k8sClient.Scheme()})
+		Expect(err).NotTo(HaveOccurred())
+		listener := bufconn.Listen(1024 * 1024)
+		server := grpc.NewServer()
+		adminv1.RegisterNotificationAdminServer(server, &AdminServer{
+			Reconciler: &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), RecordDeliveries: true},
+			Watcher:    watcher,
+			Log:        ctrl.Log.WithName("admin"),
+		})
+		go func() {
+			_ = server.Serve(listener)
+		}()
+		DeferCleanup(server.Stop)
+		conn, err := grpc.NewClient("passthrough:///bufnet",
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+				return listener.DialContext(ctx)
+			}))
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(conn.Close)
+		admin = adminv1.NewNotificationAdminClient(conn)
+		token = createServiceAccountToken("admin-client", true)
+	})
+
+	It("should reject unauthenticated and unauthorized calls", func() {
+		_, err := admin.ListDeliveries(context.Background(), &adminv1.ListDeliveriesRequest{Namespace: "default"})
+		Expect(status.Code(err)).To(Equal(codes.Unauthenticated))
+		_, err = admin.ListDeliveries(withToken(createServiceAccountToken("admin-intruder", false)),
+			&adminv1.ListDeliveriesRequest{Namespace: "default"})
+		Expect(status.Code(err)).To(Equal(codes.PermissionDenied))
+	})
+
+	It("should list, get and resend deliveries", func() {
+		notification := &notifier.Notification{PipelineRun: "admin-build", Namespace: "default", Status: notifier.StatusFailed}
+		pipelineRun := createPipelineRun("admin-build", corev1.ConditionFalse)
+		Expect(CreateDeliveryRecord(context.Background(), &NotificationServiceReconciler{Client: k8sClient},
+			pipelineRun, "default/admin/hook", notification, nil, time.Second, nil)).To(Succeed())
+
+		list, err := admin.ListDeliveries(withToken(token), &adminv1.ListDeliveriesRequest{Namespace: "default", PipelineRun: "admin-build"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(list.Deliveries).To(HaveLen(1))
+		Expect(list.Deliveries[0].Destination).To(Equal("default/admin/hook"))
+		Expect(list.Deliveries[0].Latency.AsDuration()).To(Equal(time.Second))
+		Expect(list.Deliveries[0].Payload).To(BeEmpty())
+
+		delivery, err := admin.GetDelivery(withToken(token), &adminv1.GetDeliveryRequest{Namespace: "default", Name: list.Deliveries[0].Name})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(delivery.Payload).To(ContainSubstring(`"pipelineRun":"admin-build"`))
+
+		_, err = admin.GetDelivery(withToken(token), &adminv1.GetDeliveryRequest{Namespace: "default", Name: "missing"})
+		Expect(status.Code(err)).To(Equal(codes.NotFound))
+
+		result, err := admin.ResendDelivery(withToken(token), &adminv1.ResendDeliveryRequest{Namespace: "default", Name: delivery.Name})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Succeeded).To(BeTrue())
+		Expect(result.ResponseCode).To(Equal(int32(http.StatusOK)))
+		Expect(received).To(HaveLen(1))
+
+		deliveries := &v1alpha1.NotificationDeliveryList{}
+		Expect(k8sClient.List(context.Background(), deliveries, client.InNamespace("default"))).To(Succeed())
+		for i := range deliveries.Items {
+			if deliveries.Items[i].Spec.PipelineRun == "admin-build" {
+				DeferCleanup(k8sClient.Delete, context.Background(), &deliveries.Items[i])
+			}
+		}
+	})
+
+	It("should send test notifications", func() {
+		result, err := admin.SendTestNotification(withToken(token), &adminv1.SendTestNotificationRequest{
+			Namespace:           "default",
+			NotificationService: "admin",
+			Destination:         "hook",
+			Payload:             `{"pipelineRun": "custom"}`,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Succeeded).To(BeTrue())
+		Expect(received).To(HaveLen(1))
+		Expect(received[0].PipelineRun).To(Equal("custom"))
+
+		_, err = admin.SendTestNotification(withToken(token), &adminv1.SendTestNotificationRequest{
+			Namespace: "default", NotificationService: "admin", Destination: "hook", Payload: "not json",
+		})
+		Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+	})
+
+	It("should stream new deliveries", func() {
+		ctx, cancel := context.WithCancel(withToken(token))
+		DeferCleanup(cancel)
+		stream, err := admin.WatchDeliveries(ctx, &adminv1.WatchDeliveriesRequest{Namespace: "default", PipelineRun: "admin-watched"})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = stream.Header()
+		Expect(err).NotTo(HaveOccurred())
+
+		deliveries := make(chan *adminv1.Delivery)
+		go func() {
+			defer GinkgoRecover()
+			for {
+				delivery, err := stream.Recv()
+				if err != nil {
+					close(deliveries)
+					return
+				}
+				deliveries <- delivery
+			}
+		}()
+
+		r := &NotificationServiceReconciler{Client: k8sClient}
+		for _, name := range []string{"admin-ignored", "admin-watched"} {
+			pipelineRun := createPipelineRun(name, corev1.ConditionTrue)
+			Expect(CreateDeliveryRecord(context.Background(), r, pipelineRun, "default/admin/hook",
+				&notifier.Notification{PipelineRun: name, Namespace: "default"}, nil, 0, nil)).To(Succeed())
+		}
+		var delivery *adminv1.Delivery
+		Eventually(deliveries, 10*time.Second).Should(Receive(&delivery))
+		Expect(delivery.PipelineRun).To(Equal("admin-watched"))
+		Expect(delivery.Payload).To(BeEmpty())
+	})
+})
@@ -0,0 +1,171 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// +kubebuilder:webhook:path=/validate-konflux-ci-v1alpha1-notificationservice,mutating=false,failurePolicy=fail,sideEffects=None,groups=konflux.ci,resources=notificationservices,verbs=create;update,versions=v1alpha1,name=vnotificationservice.konflux.ci,admissionReviewVersions=v1
+// +kubebuilder:webhook:path=/validate-konflux-ci-v1alpha1-notificationtemplate,mutating=false,failurePolicy=fail,sideEffects=None,groups=konflux.ci,resources=notificationtemplates,verbs=create;update,versions=v1alpha1,name=vnotificationtemplate.konflux.ci,admissionReviewVersions=v1
+
+// AdmissionValidator rejects NotificationServices and NotificationTemplates whose templates reference
+// fields or results denied by the PayloadPolicies of their namespace, and NotificationServices with
+// destination hosts the Allowlist does not allow or the Guard blocks, with webhook Services that
+// CheckServiceReference does not allow, with summaries of other namespaces or with invalid watches
+type AdmissionValidator struct {
+	Client client.Reader
+	// WatchableKinds are the kinds of resources NotificationServices may watch, DefaultWatchableKinds if empty
+	WatchableKinds []schema.GroupKind
+	// Allowlist restricts the hosts of destinations, if set
+	Allowlist *notifier.HostAllowlist
+	// Guard blocks the internal addresses of destinations, if set
+	Guard *notifier.AddressGuard
+}
+
+// SetupWebhookWithManager registers the validating webhooks of NotificationServices and NotificationTemplates
+func (v *AdmissionValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	err := ctrl.NewWebhookManagedBy(mgr).For(&v1alpha1.NotificationService{}).WithValidator(v).Complete()
+	if err != nil {
+		return err
+	}
+	return ctrl.NewWebhookManagedBy(mgr).For(&v1alpha1.NotificationTemplate{}).WithValidator(v).Complete()
+}
+
+// ValidateCreate checks a created NotificationService or NotificationTemplate
+func (v *AdmissionValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, v.validate(ctx, obj)
+}
+
+// ValidateUpdate checks an updated NotificationService or NotificationTemplate
+func (v *AdmissionValidator) ValidateUpdate(ctx context.Context, _ runtime.Object, newObj runtime.Object) (admission.Warnings, error) {
+	return nil, v.validate(ctx, newObj)
+}
+
+// ValidateDelete accepts every deletion
+func (v *AdmissionValidator) ValidateDelete(context.Context, runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *AdmissionValidator) validate(ctx context.Context, obj runtime.Object) error {
+	err := validatePayloadPolicies(ctx, v.Client, obj)
+	notificationService, ok := obj.(*v1alpha1.NotificationService)
+	if ok {
+		err = errors.Join(err, CheckServiceReferences(notificationService.Namespace, notificationService.Spec.Destinations))
+	}
+	if ok {
+		err = errors.Join(err, CheckSummaryNamespaces(notificationService))
+	}
+	if ok && notificationService.Spec.Watch != nil {
+		if _, watchErr := ValidateResourceWatch(notificationService.Spec.Watch, v.WatchableKinds); watchErr != nil {
+			err = errors.Join(err, fmt.Errorf("Invalid watch: %w", watchErr))
+		}
+	}
+	if ok && v.Allowlist != nil {
+		err = errors.Join(err, CheckDestinationHosts(ctx, v.Allowlist, notificationService.Spec.Destinations))
+	}
+	if ok && v.Guard != nil {
+		err = errors.Join(err, CheckDestinationHosts(ctx, v.Guard, notificationService.Spec.Destinations))
+	}
+	return err
+}
+
+// CheckServiceReferences returns an error naming the destinations of a NotificationService of the namespace
+// whose webhook Services CheckServiceReference does not allow
+func CheckServiceReferences(namespace string, destinations []v1alpha1.Destination) error {
+	var errs []error
+	for _, destination := range destinations {
+		if destination.Webhook == nil || destination.Webhook.ServiceRef == nil {
+			continue
+		}
+		if err := CheckServiceReference(namespace, destination.Webhook.ServiceRef); err != nil {
+			errs = append(errs, fmt.Errorf("Destination %s: %w", destination.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// hostChecker is a notifier.HostAllowlist or a notifier.AddressGuard
+type hostChecker interface {
+	Check(ctx context.Context, host string) error
+}
+
+// CheckDestinationHosts returns an error naming the destinations whose hosts the allowlist does not allow
+// or the guard blocks. The hosts of webhook Services, web push subscriptions and the default APIs of Slack
+// and FCM are only checked when they are connected to.
+func CheckDestinationHosts(ctx context.Context, checker hostChecker, destinations []v1alpha1.Destination) error {
+	var errs []error
+	for _, destination := range destinations {
+		for _, host := range destinationHosts(destination) {
+			if err := checker.Check(ctx, host); err != nil {
+				errs = append(errs, fmt.Errorf("Destination %s: %w", destination.Name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// destinationHosts returns the hosts set in the destination
+func destinationHosts(destination v1alpha1.Destination) []string {
+	var hosts []string
+	addURL := func(rawURL string) {
+		if parsed, err := url.Parse(rawURL); err == nil && rawURL != "" {
+			hosts = append(hosts, parsed.Hostname())
+		}
+	}
+	addAddress := func(address string) {
+		if host, _, err := net.SplitHostPort(address); err == nil {
+			hosts = append(hosts, host)
+		} else if address != "" {
+			hosts = append(hosts, address)
+		}
+	}
+	switch {
+	case destination.Webhook != nil:
+		addURL(destination.Webhook.URL)
+	case destination.Slack != nil:
+		addURL(destination.Slack.APIURL)
+	case destination.Matrix != nil:
+		addURL(destination.Matrix.HomeserverURL)
+	case destination.IRC != nil:
+		addAddress(destination.IRC.Server)
+	case destination.XMPP != nil:
+		if destination.XMPP.Server != "" {
+			addAddress(destination.XMPP.Server)
+		} else if _, domain, ok := strings.Cut(destination.XMPP.JID, "@"); ok {
+			hosts = append(hosts, domain)
+		}
+	case destination.FCM != nil:
+		addURL(destination.FCM.APIURL)
+	case destination.Alertmanager != nil:
+		addURL(destination.Alertmanager.URL)
+	}
+	return hosts
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Admission webhook", func() {
+	It("should reject destinations whose hosts are not allowed", func() {
+		allowlist, err := notifier.ParseHostAllowlist([]string{"*.example.com", "192.0.2.0/24"})
+		Expect(err).NotTo(HaveOccurred())
+		validator := &AdmissionValidator{Client: k8sClient, Allowlist: allowlist}
+		notificationService := &v1alpha1.NotificationService{
+			ObjectMeta: metav1.ObjectMeta{Name: "egress", Namespace: "default"},
+			Spec: v1alpha1.NotificationServiceSpec{
+				Destinations: []v1alpha1.Destination{
+					{Name: "hook", Webhook: &v1alpha1.WebhookDestination{URL: "https://hooks.example.com/builds"}},
+					{Name: "chat", Matrix: &v1alpha1.MatrixDestination{HomeserverURL: "https://matrix.example.net"}},
+					{Name: "irc", IRC: &v1alpha1.IRCDestination{Server: "192.0.2.10:6697"}},
+					{Name: "xmpp", XMPP: &v1alpha1.XMPPDestination{JID: "ci@chat.example.org"}},
+					{Name: "slack", Slack: &v1alpha1.SlackDestination{Channel: "C1"}},
+				},
+			},
+		}
+		_, err = validator.ValidateCreate(context.Background(), notificationService)
+		Expect(err).To(MatchError(notifier.ErrHostNotAllowed))
+		Expect(err.Error()).To(ContainSubstring("Destination chat"))
+		Expect(err.Error()).To(ContainSubstring("Destination xmpp"))
+		Expect(err.Error()).NotTo(ContainSubstring("Destination hook"))
+		Expect(err.Error()).NotTo(ContainSubstring("Destination irc"))
+		Expect(err.Error()).NotTo(ContainSubstring("Destination slack"))
+
+		notificationService.Spec.Destinations = notificationService.Spec.Destinations[:1]
+		_, err = validator.ValidateUpdate(context.Background(), notificationService, notificationService)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = (&AdmissionValidator{Client: k8sClient}).ValidateCreate(context.Background(), &v1alpha1.NotificationService{
+			ObjectMeta: metav1.ObjectMeta{Name: "egress", Namespace: "default"},
+			Spec: v1alpha1.NotificationServiceSpec{Destinations: []v1alpha1.Destination{
+				{Name: "anywhere", Webhook: &v1alpha1.WebhookDestination{URL: "https://hooks.example.net"}},
+			}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should reject destinations with internal addresses", func() {
+		guard, err := notifier.NewAddressGuard(notifier.DefaultBlockedNetworks, nil)
+		Expect(err).NotTo(HaveOccurred())
+		validator := &AdmissionValidator{Client: k8sClient, Guard: guard}
+		_, err = validator.ValidateCreate(context.Background(), &v1alpha1.NotificationService{
+			ObjectMeta: metav1.ObjectMeta{Name: "ssrf", Namespace: "default"},
+			Spec: v1alpha1.NotificationServiceSpec{Destinations: []v1alpha1.Destination{
+				{Name: "metadata", Webhook: &v1alpha1.WebhookDestination{URL: "http://169.254.169.254/latest/meta-data"}},
+				{Name: "irc", IRC: &v1alpha1.IRCDestination{Server: "10.0.0.5:6667"}},
+				{Name: "public", Webhook: &v1alpha1.WebhookDestination{URL: "https://203.0.113.5/hooks"}},
+			}},
+		})
+		Expect(err).To(MatchError(notifier.ErrAddressBlocked))
+		Expect(err.Error()).To(ContainSubstring("Destination metadata"))
+		Expect(err.Error()).To(ContainSubstring("Destination irc"))
+		Expect(err.Error()).NotTo(ContainSubstring("Destination public"))
+	})
+
+	It("should reject webhook Services of other namespaces", func() {
+		validator := &AdmissionValidator{Client: k8sClient}
+		serviceRef := func(namespace string) *v1alpha1.WebhookDestination {
+			return &v1alpha1.WebhookDestination{ServiceRef: &v1alpha1.WebhookServiceReference{Name: "receiver", Namespace: namespace}}
+		}
+		notificationService := &v1alpha1.NotificationService{
+			ObjectMeta: metav1.ObjectMeta{Name: "services", Namespace: "default"},
+			Spec: v1alpha1.NotificationServiceSpec{Destinations: []v1alpha1.Destination{
+				{Name: "own", Webhook: serviceRef("")},
+				{Name: "same", Webhook: serviceRef("default")},
+				{Name: "system", Webhook: serviceRef("kube-system")},
+				{Name: "shared", Webhook: serviceRef("receivers")},
+			}},
+		}
+		_, err := validator.ValidateCreate(context.Background(), notificationService)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Destination system"))
+		Expect(err.Error()).To(ContainSubstring("Destination shared"))
+		Expect(err.Error()).NotTo(ContainSubstring("Destination own"))
+		Expect(err.Error()).NotTo(ContainSubstring("Destination same"))
+
+		WebhookServiceNamespaces = []string{"receivers"}
+		DeferCleanup(func() { WebhookServiceNamespaces = nil })
+		_, err = validator.ValidateUpdate(context.Background(), notificationService, notificationService)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).NotTo(ContainSubstring("Destination shared"))
+	})
+
+	It("should only block the internal addresses of tenant destinations when connecting", func() {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { requests++ }))
+		defer server.Close()
+		guard, err := notifier.NewAddressGuard(notifier.DefaultBlockedNetworks, nil)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(func() { notifier.TenantGuard = nil })
+		notifier.InstallTenantGuard(guard, http.DefaultTransport.(*http.Transport))
+
+		destination := v1alpha1.Destination{Name: "local", Webhook: &v1alpha1.WebhookDestination{URL: server.URL}}
+		tenant, err := NewTenantNotifierForDestination(context.Background(), k8sClient, "default", destination)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tenant.Notify(context.Background(), &notifier.Notification{PipelineRun: "build"})).To(MatchError(notifier.ErrAddressBlocked))
+		platform, err := NewNotifierForDestination(context.Background(), k8sClient, "default", destination)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(platform.Notify(context.Background(), &notifier.Notification{PipelineRun: "build"})).To(Succeed())
+		Expect(requests).To(Equal(1))
+	})
+})
@@ -0,0 +1,319 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// APIPrefix is the prefix of the paths of the REST API
+const APIPrefix string = "/api/v1/"
+
+// maxAPIRequestBytes bounds the body of API requests
+const maxAPIRequestBytes = 1 << 20
+
+// APIServer serves a REST API to query delivery records, send recorded notifications again, send
+// test notifications to destinations and manage the web push subscriptions of browsers. Requests are
+// authenticated with Kubernetes bearer tokens and authorized against the notificationdeliveries resource
+// of the namespace of the request.
+type APIServer struct {
+	// Reconciler provides the client, the default notifier and the delivery recording settings
+	Reconciler *NotificationServiceReconciler
+	Log        logr.Logger
+	// BindAddress is the address the API listens on
+	BindAddress string
+	// CertFile and KeyFile serve the API over TLS, if set
+	CertFile string
+	KeyFile  string
+}
+
+// NeedLeaderElection returns false so every replica serves the API
+func (s *APIServer) NeedLeaderElection() bool {
+	return false
+}
+
+// Start serves the API until the context is cancelled
+func (s *APIServer) Start(ctx context.Context) error {
+	server := &http.Server{
+		Addr:              s.BindAddress,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+	s.Log.Info("Serving API", "address", s.BindAddress)
+	var err error
+	if s.CertFile != "" {
+		err = server.ListenAndServeTLS(s.CertFile, s.KeyFile)
+	} else {
+		err = server.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Handler returns the handler of the API routes
+func (s *APIServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+APIPrefix+"namespaces/{namespace}/deliveries", s.listDeliveries)
+	mux.HandleFunc("GET "+APIPrefix+"namespaces/{namespace}/deliveries/{name}", s.getDelivery)
+	mux.HandleFunc("GET "+APIPrefix+"namespaces/{namespace}/deliveries/{name}/payload", s.getPayload)
+	mux.HandleFunc("POST "+APIPrefix+"namespaces/{namespace}/deliveries/{name}/resend", s.resendDelivery)
+	mux.HandleFunc("POST "+APIPrefix+"namespaces/{namespace}/notificationservices/{notificationService}/destinations/{destination}/test",
+		s.testDestination)
+	mux.HandleFunc("POST "+APIPrefix+"namespaces/{namespace}/notificationservices/{notificationService}/preview", s.previewTemplates)
+	mux.HandleFunc("GET "+APIPrefix+"webpush/vapid-public-key", s.getVAPIDPublicKey)
+	mux.HandleFunc("POST "+APIPrefix+"namespaces/{namespace}/webpush/subscriptions", s.subscribeWebPush)
+	mux.HandleFunc("DELETE "+APIPrefix+"namespaces/{namespace}/webpush/subscriptions", s.unsubscribeWebPush)
+	return mux
+}
+
+// listDeliveries lists the delivery records of the namespace, optionally of a single pipelinerun.
+// Payloads are omitted, they are returned by getDelivery and getPayload.
+func (s *APIServer) listDeliveries(w http.ResponseWriter, req *http.Request) {
+	namespace := req.PathValue("namespace")
+	if !s.authorize(w, req, namespace, "list") {
+		return
+	}
+	deliveries, err := ListDeliveries(req.Context(), s.Reconciler.Client, namespace, req.URL.Query().Get("pipelineRun"))
+	if err != nil {
+		s.fail(w, err, "Failed to list deliveries")
+		return
+	}
+	writeJSON(w, http.StatusOK, deliveries)
+}
+
+func (s *APIServer) getDelivery(w http.ResponseWriter, req *http.Request) {
+	delivery, ok := s.delivery(w, req, "get")
+	if ok {
+		writeJSON(w, http.StatusOK, delivery)
+	}
+}
+
+func (s *APIServer) getPayload(w http.ResponseWriter, req *http.Request) {
+	delivery, ok := s.delivery(w, req, "get")
+	if !ok {
+		return
+	}
+	if delivery.Spec.Payload == "" {
+		http.Error(w, ErrNoPayload.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = io.WriteString(w, delivery.Spec.Payload)
+}
+
+// resendDelivery sends the recorded payload of a delivery to its destination again
+func (s *APIServer) resendDelivery(w http.ResponseWriter, req *http.Request) {
+	delivery, ok := s.delivery(w, req, "create")
+	if !ok {
+		return
+	}
+	result, err := ResendDelivery(req.Context(), s.Reconciler, delivery)
+	if err != nil {
+		s.fail(w, err, "Failed to resend delivery")
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// testDestination sends the notification in the request body, or a minimal notification if the
+// body is empty, to a destination of a NotificationService. Test notifications are not recorded.
+func (s *APIServer) testDestination(w http.ResponseWriter, req *http.Request) {
+	namespace := req.PathValue("namespace")
+	if !s.authorize(w, req, namespace, "create") {
+		return
+	}
+	notification := &notifier.Notification{}
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxAPIRequestBytes))
+	if err != nil {
+		http.Error(w, "failed to read request", http.StatusBadRequest)
+		return
+	}
+	if len(strings.TrimSpace(string(body))) > 0 {
+		err = json.Unmarshal(body, notification)
+		if err != nil {
+			http.Error(w, "request body is not a notification: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	result, err := SendTestNotification(req.Context(), s.Reconciler, namespace,
+		req.PathValue("notificationService"), req.PathValue("destination"), notification)
+	if err != nil {
+		s.fail(w, err, "Failed to send test notification")
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// previewTemplates renders the templates of the destinations of a NotificationService against the
+// pipelineRun of the request body, or the most recent PipelineRun of the namespace, without sending them.
+// A template in the request body is rendered instead, so authors can iterate before applying it.
+func (s *APIServer) previewTemplates(w http.ResponseWriter, req *http.Request) {
+	namespace := req.PathValue("namespace")
+	if !s.authorize(w, req, namespace, "get") {
+		return
+	}
+	var request struct {
+		PipelineRun string `json:"pipelineRun"`
+		Template    string `json:"template"`
+	}
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxAPIRequestBytes))
+	if err != nil {
+		http.Error(w, "failed to read request", http.StatusBadRequest)
+		return
+	}
+	if len(strings.TrimSpace(string(body))) > 0 {
+		err = json.Unmarshal(body, &request)
+		if err != nil {
+			http.Error(w, "request body is not a preview request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	previews, err := PreviewTemplates(req.Context(), s.Reconciler, namespace, req.PathValue("notificationService"),
+		request.PipelineRun, request.Template)
+	if err != nil {
+		s.fail(w, err, "Failed to preview templates")
+		return
+	}
+	writeJSON(w, http.StatusOK, previews)
+}
+
+// getVAPIDPublicKey returns the applicationServerKey browsers subscribe with. It is public and
+// served without authentication.
+func (s *APIServer) getVAPIDPublicKey(w http.ResponseWriter, req *http.Request) {
+	if WebPushVAPIDKeys == nil {
+		http.Error(w, "web push is not configured", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"publicKey": WebPushVAPIDKeys.PublicKey})
+}
+
+// subscribeWebPush subscribes the browser push subscription in the request body to the web push
+// destinations of the namespace. Users reading the deliveries of the namespace may subscribe.
+func (s *APIServer) subscribeWebPush(w http.ResponseWriter, req *http.Request) {
+	namespace := req.PathValue("namespace")
+	if !s.authorize(w, req, namespace, "get") {
+		return
+	}
+	subscription := notifier.WebPushSubscription{}
+	err := json.NewDecoder(io.LimitReader(req.Body, maxAPIRequestBytes)).Decode(&subscription)
+	if err == nil {
+		err = subscription.Validate()
+	}
+	if err != nil {
+		http.Error(w, "request body is not a push subscription: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = AddWebPushSubscription(req.Context(), s.Reconciler.Client, namespace, subscription)
+	if err != nil {
+		s.fail(w, err, "Failed to add web push subscription")
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// unsubscribeWebPush removes the push subscription of the endpoint in the request body
+func (s *APIServer) unsubscribeWebPush(w http.ResponseWriter, req *http.Request) {
+	namespace := req.PathValue("namespace")
+	if !s.authorize(w, req, namespace, "get") {
+		return
+	}
+	subscription := notifier.WebPushSubscription{}
+	err := json.NewDecoder(io.LimitReader(req.Body, maxAPIRequestBytes)).Decode(&subscription)
+	if err != nil || subscription.Endpoint == "" {
+		http.Error(w, "request body has no push subscription endpoint", http.StatusBadRequest)
+		return
+	}
+	err = RemoveWebPushSubscription(req.Context(), s.Reconciler.Client, namespace, subscription.Endpoint)
+	if err != nil {
+		s.fail(w, err, "Failed to remove web push subscription")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// delivery authorizes the request for the verb and returns the delivery record in its path
+func (s *APIServer) delivery(w http.ResponseWriter, req *http.Request, verb string) (*v1alpha1.NotificationDelivery, bool) {
+	namespace := req.PathValue("namespace")
+	if !s.authorize(w, req, namespace, verb) {
+		return nil, false
+	}
+	delivery := &v1alpha1.NotificationDelivery{}
+	err := s.Reconciler.Client.Get(req.Context(), types.NamespacedName{Namespace: namespace, Name: req.PathValue("name")}, delivery)
+	if err != nil {
+		s.fail(w, err, "Failed to get delivery")
+		return nil, false
+	}
+	return delivery, true
+}
+
+// authorize checks the bearer token of the request with AuthorizeBearerToken.
+// Rejected requests are answered and false is returned.
+func (s *APIServer) authorize(w http.ResponseWriter, req *http.Request, namespace string, verb string) bool {
+	token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		token = ""
+	}
+	err := AuthorizeBearerToken(req.Context(), s.Reconciler.Client, token, namespace, verb)
+	if err != nil {
+		s.fail(w, err, "Failed to authorize request")
+		return false
+	}
+	return true
+}
+
+// fail answers the request with the status matching the error
+func (s *APIServer) fail(w http.ResponseWriter, err error, message string) {
+	logAdminError(s.Log, err, message)
+	switch {
+	case errors.Is(err, ErrUnauthenticated):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, ErrForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrNoPayload), errors.Is(err, ErrTooManyWebPushSubscriptions):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case k8serrors.IsNotFound(err):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, strings.ToLower(message[:1])+message[1:], http.StatusInternalServerError)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,260 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// createServiceAccountToken creates a service account, optionally allowed to read, watch and send deliveries, and returns its token
+func createServiceAccountToken(name string, allowed bool) string {
+	serviceAccount := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+	Expect(client.IgnoreAlreadyExists(k8sClient.Create(context.Background(), serviceAccount))).To(Succeed())
+	if allowed {
+		role := &rbacv1.Role{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Rules: []rbacv1.PolicyRule{{
+				APIGroups: []string{v1alpha1.GroupVersion.Group},
+				Resources: []string{"notificationdeliveries"},
+				Verbs:     []string{"get", "list", "watch", "create"},
+			}},
+		}
+		Expect(client.IgnoreAlreadyExists(k8sClient.Create(context.Background(), role))).To(Succeed())
+		binding := &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "Role", Name: role.Name},
+			Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: name, Namespace: "default"}},
+		}
+		Expect(client.IgnoreAlreadyExists(k8sClient.Create(context.Background(), binding))).To(Succeed())
+	}
+	tokenRequest := &authenticationv1.TokenRequest{}
+	Expect(k8sClient.SubResource("token").Create(context.Background(), serviceAccount, tokenRequest)).To(Succeed())
+	return tokenRequest.Status.Token
+}
+
+var _ = Describe("API server", func() {
+	var (
+		api      *httptest.Server
+		received []*notifier.Notification
+		token    string
+	)
+
+	do := func(method string, path string, bearer string, body string) (int, string) {
+		req, err := http.NewRequest(method, api.URL+APIPrefix+path, strings.NewReader(body))
+		Expect(err).NotTo(HaveOccurred())
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+		raw, err := io.ReadAll(resp.Body)
+		Expect(err).NotTo(HaveOccurred())
+		return resp.StatusCode, string(raw)
+	}
+
+	BeforeEach(func() {
+		received = nil
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			notification := &notifier.Notification{}
+			Expect(json.NewDecoder(req.Body).Decode(notification)).To(Succeed())
+			received = append(received, notification)
+		}))
+		DeferCleanup(receiver.Close)
+		notificationService := &v1alpha1.NotificationService{
+			ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"},
+			Spec: v1alpha1.NotificationServiceSpec{
+				Destinations: []v1alpha1.Destination{{
+					Name:    "hook",
+					Webhook: &v1alpha1.WebhookDestination{URL: receiver.URL},
+				}},
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), notificationService)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), notificationService)
+
+		r := &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), RecordDeliveries: true}
+		api = httptest.NewServer((&APIServer{Reconciler: r, Log: ctrl.Log.WithName("api")}).Handler())
+		DeferCleanup(api.Close)
+		token = createServiceAccountToken("api-client", true)
+	})
+
+	It("should reject unauthenticated and unauthorized requests", func() {
+		status, _ := do(http.MethodGet, "namespaces/default/deliveries", "", "")
+		Expect(status).To(Equal(http.StatusUnauthorized))
+		status, _ = do(http.MethodGet, "namespaces/default/deliveries", "not-a-token", "")
+		Expect(status).To(Equal(http.StatusUnauthorized))
+		status, _ = do(http.MethodGet, "namespaces/default/deliveries", createServiceAccountToken("api-intruder", false), "")
+		Expect(status).To(Equal(http.StatusForbidden))
+		status, _ = do(http.MethodGet, "namespaces/kube-system/deliveries", token, "")
+		Expect(status).To(Equal(http.StatusForbidden))
+	})
+
+	It("should list deliveries, return their payload and send them again", func() {
+		notification := &notifier.Notification{PipelineRun: "api-build", Namespace: "default", Status: notifier.StatusFailed}
+		r := &NotificationServiceReconciler{Client: k8sClient}
+		pipelineRun := createPipelineRun("api-build", corev1.ConditionFalse)
+		Expect(CreateDeliveryRecord(context.Background(), r, pipelineRun, "default/api/hook", notification, nil, 0, nil)).To(Succeed())
+
+		status, body := do(http.MethodGet, "namespaces/default/deliveries?pipelineRun=api-build", token, "")
+		Expect(status).To(Equal(http.StatusOK))
+		var deliveries []v1alpha1.NotificationDelivery
+		Expect(json.Unmarshal([]byte(body), &deliveries)).To(Succeed())
+		Expect(deliveries).To(HaveLen(1))
+		Expect(deliveries[0].Spec.Destination).To(Equal("default/api/hook"))
+		Expect(deliveries[0].Spec.Payload).To(BeEmpty())
+
+		status, body = do(http.MethodGet, "namespaces/default/deliveries/"+deliveries[0].Name+"/payload", token, "")
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(body).To(MatchJSON(`{"pipelineRun":"api-build","namespace":"default","status":"Failed","results":null}`))
+
+		status, body = do(http.MethodPost, "namespaces/default/deliveries/"+deliveries[0].Name+"/resend", token, "")
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(body).To(MatchJSON(`{"destination":"default/api/hook","succeeded":true,"responseCode":200}`))
+		Expect(received).To(HaveLen(1))
+		Expect(received[0].PipelineRun).To(Equal("api-build"))
+
+		// The resent notification is recorded as a new delivery
+		resent := &v1alpha1.NotificationDeliveryList{}
+		Expect(k8sClient.List(context.Background(), resent, client.InNamespace("default"))).To(Succeed())
+		count := 0
+		for i := range resent.Items {
+			if resent.Items[i].Spec.PipelineRun == "api-build" {
+				count++
+				DeferCleanup(k8sClient.Delete, context.Background(), &resent.Items[i])
+			}
+		}
+		Expect(count).To(Equal(2))
+	})
+
+	It("should not send deliveries to the destinations of other namespaces", func() {
+		victim := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "api-victim"}}
+		Expect(client.IgnoreAlreadyExists(k8sClient.Create(context.Background(), victim))).To(Succeed())
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			received = append(received, &notifier.Notification{})
+		}))
+		DeferCleanup(receiver.Close)
+		notificationService := &v1alpha1.NotificationService{
+			ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: victim.Name},
+			Spec: v1alpha1.NotificationServiceSpec{
+				Destinations: []v1alpha1.Destination{{
+					Name:    "hook",
+					Webhook: &v1alpha1.WebhookDestination{URL: receiver.URL},
+				}},
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), notificationService)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), notificationService)
+
+		notification := &notifier.Notification{PipelineRun: "api-intrusion", Namespace: "default", Status: notifier.StatusFailed}
+		r := &NotificationServiceReconciler{Client: k8sClient}
+		pipelineRun := createPipelineRun("api-intrusion", corev1.ConditionFalse)
+		Expect(CreateDeliveryRecord(context.Background(), r, pipelineRun, "api-victim/api/hook", notification, nil, 0, nil)).To(Succeed())
+		deliveries := &v1alpha1.NotificationDeliveryList{}
+		Expect(k8sClient.List(context.Background(), deliveries, client.InNamespace("default"))).To(Succeed())
+		var name string
+		for i := range deliveries.Items {
+			if deliveries.Items[i].Spec.PipelineRun == "api-intrusion" {
+				name = deliveries.Items[i].Name
+				DeferCleanup(k8sClient.Delete, context.Background(), &deliveries.Items[i])
+			}
+		}
+		Expect(name).NotTo(BeEmpty())
+
+		status, _ := do(http.MethodPost, "namespaces/default/deliveries/"+name+"/resend", token, "")
+		Expect(status).To(Equal(http.StatusNotFound))
+		Expect(received).To(BeEmpty())
+	})
+
+	It("should send test notifications", func() {
+		status, body := do(http.MethodPost, "namespaces/default/notificationservices/api/destinations/hook/test", token, "")
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(body).To(ContainSubstring(`"succeeded":true`))
+		Expect(received).To(HaveLen(1))
+		Expect(received[0].PipelineRun).To(Equal(TestNotificationPipelineRun))
+		Expect(received[0].Status).To(Equal(notifier.StatusSucceeded))
+
+		status, _ = do(http.MethodPost, "namespaces/default/notificationservices/api/destinations/hook/test", token,
+			`{"pipelineRun": "custom", "namespace": "other", "status": "Failed"}`)
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(received[1].PipelineRun).To(Equal("custom"))
+		Expect(received[1].Namespace).To(Equal("default"))
+
+		status, _ = do(http.MethodPost, "namespaces/default/notificationservices/api/destinations/missing/test", token, "")
+		Expect(status).To(Equal(http.StatusNotFound))
+	})
+
+	It("should manage web push subscriptions", func() {
+		status, _ := do(http.MethodGet, "webpush/vapid-public-key", "", "")
+		Expect(status).To(Equal(http.StatusNotFound))
+		keys, err := notifier.NewVAPIDKeys("yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw", "mailto:ci@example.com")
+		Expect(err).NotTo(HaveOccurred())
+		WebPushVAPIDKeys = keys
+		DeferCleanup(func() { WebPushVAPIDKeys = nil })
+		status, body := do(http.MethodGet, "webpush/vapid-public-key", "", "")
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(body).To(ContainSubstring(keys.PublicKey))
+
+		subscription := `{"endpoint": "https://push.example.com/send/1", "keys": {` +
+			`"p256dh": "BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4", ` +
+			`"auth": "BTBZMqHH6r4Tts7J_aSIgg"}}`
+		status, _ = do(http.MethodPost, "namespaces/default/webpush/subscriptions", "", subscription)
+		Expect(status).To(Equal(http.StatusUnauthorized))
+		status, _ = do(http.MethodPost, "namespaces/default/webpush/subscriptions", token, `{"endpoint": "https://push.example.com/send/1"}`)
+		Expect(status).To(Equal(http.StatusBadRequest))
+		status, _ = do(http.MethodPost, "namespaces/default/webpush/subscriptions", token, subscription)
+		Expect(status).To(Equal(http.StatusCreated))
+		DeferCleanup(k8sClient.Delete, context.Background(), &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: WebPushSubscriptionsSecret, Namespace: "default"},
+		})
+		status, _ = do(http.MethodPost, "namespaces/default/webpush/subscriptions", token, subscription)
+		Expect(status).To(Equal(http.StatusCreated))
+
+		subscriptions, err := GetWebPushSubscriptions(context.Background(), k8sClient, "default")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(subscriptions).To(HaveLen(1))
+		Expect(subscriptions[0].Endpoint).To(Equal("https://push.example.com/send/1"))
+		n, err := NewNotifierForDestination(context.Background(), k8sClient, "default", v1alpha1.Destination{
+			Name: "browsers", WebPush: &v1alpha1.WebPushDestination{Urgency: "high"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(BeAssignableToTypeOf(&notifier.WebPushNotifier{}))
+
+		status, _ = do(http.MethodDelete, "namespaces/default/webpush/subscriptions", token, subscription)
+		Expect(status).To(Equal(http.StatusNoContent))
+		subscriptions, err = GetWebPushSubscriptions(context.Background(), k8sClient, "default")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(subscriptions).To(BeEmpty())
+	})
+})
@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+)
+
+// PayloadArchive uploads every notification in full, as JSON, to the object storage of the audit trail, so
+// terse chat messages link to the complete data
+type PayloadArchive struct {
+	Store notifier.ObjectStore
+}
+
+// ArchiveKey returns the key the notification about the run identified by key, e.g. its UID, is archived under.
+// It only depends on the run and the event of the notification, so it is the same for every attempt to deliver it.
+// Restricted notifications, see RedactNotification, are archived under their own key.
+func ArchiveKey(key string, notification *notifier.Notification, restricted bool) string {
+	event := notification.Event
+	if event == "" {
+		event = notification.Status
+	}
+	archiveKey := fmt.Sprintf("%s/%s/%s", notification.Namespace, key, event)
+	if restricted {
+		archiveKey += "-restricted"
+	}
+	return archiveKey + ".json"
+}
+
+// Archive uploads the notification under its ArchiveKey and sets its PayloadRef and, unless the notification
+// already links to its overflowing results, its PayloadURL. The notification is scanned for secrets first, and
+// notifications the scanner blocks are not archived.
+// Return error if failed to upload the notification
+func (a *PayloadArchive) Archive(ctx context.Context, key string, notification *notifier.Notification, restricted bool) error {
+	scanned, err := notifier.ScanNotification(notification)
+	if errors.Is(err, notifier.ErrSecretDetected) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(scanned)
+	if err != nil {
+		return fmt.Errorf("Failed to encode notification for pipelinerun %s: %w", notification.PipelineRun, err)
+	}
+	archiveKey := ArchiveKey(key, notification, restricted)
+	url, err := a.Store.Put(ctx, archiveKey, body, "application/json")
+	if err != nil {
+		return fmt.Errorf("Failed to archive the payload of pipelinerun %s: %w", notification.PipelineRun, err)
+	}
+	notification.PayloadRef = archiveKey
+	if notification.PayloadURL == "" {
+		notification.PayloadURL = url
+	}
+	return nil
+}
+
+// archivePayloads archives the notification about the run identified by key if it is sent as is to one of the
+// destinations, and its restricted copy if it is sent to one of them, see payloadFor.
+// Notifications failing to be archived are logged and delivered without a link to the archive.
+func archivePayloads(ctx context.Context, archive *PayloadArchive, logger logr.Logger, key string,
+	destinations []DestinationNotifier, notification *notifier.Notification, restricted *notifier.Notification) {
+	if archive == nil || archive.Store == nil {
+		return
+	}
+	archived := map[*notifier.Notification]bool{}
+	for _, destination := range destinations {
+		payload := payloadFor(destination, notification, restricted)
+		if archived[payload] {
+			continue
+		}
+		archived[payload] = true
+		err := archive.Archive(ctx, key, payload, payload == restricted)
+		if err != nil {
+			logger.Error(err, "Failed to archive notification", "destination", destination.Name)
+		}
+	}
+}
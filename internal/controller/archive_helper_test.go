@@ -0,0 +1,95 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/go-logr/logr"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("Payload archive", func() {
+	var store *fakeObjectStore
+
+	BeforeEach(func() {
+		store = &fakeObjectStore{objects: map[string][]byte{}}
+	})
+
+	It("should archive the notification under a key stable across attempts", func() {
+		archive := &PayloadArchive{Store: store}
+		notification := &notifier.Notification{PipelineRun: "build", Namespace: "tenant", Event: notifier.EventFailed,
+			Results: []notifier.Result{{Name: "IMAGE_URL", Value: "quay.io/org/app"}}}
+		Expect(archive.Archive(context.Background(), "uid", notification, false)).To(Succeed())
+		Expect(notification.PayloadRef).To(Equal("tenant/uid/failed.json"))
+		Expect(notification.PayloadURL).To(Equal("https://bucket.example.com/tenant/uid/failed.json?X-Amz-Signature=abc"))
+
+		archived := &notifier.Notification{}
+		Expect(json.Unmarshal(store.objects["tenant/uid/failed.json"], archived)).To(Succeed())
+		Expect(archived.Results).To(Equal(notification.Results))
+		Expect(archived.PayloadURL).To(BeEmpty())
+
+		retried := &notifier.Notification{PipelineRun: "build", Namespace: "tenant", Event: notifier.EventFailed,
+			PayloadURL: "https://bucket.example.com/tenant/build/uid.json"}
+		Expect(archive.Archive(context.Background(), "uid", retried, true)).To(Succeed())
+		Expect(retried.PayloadRef).To(Equal("tenant/uid/failed-restricted.json"))
+		Expect(retried.PayloadURL).To(Equal("https://bucket.example.com/tenant/build/uid.json"))
+	})
+
+	It("should archive the restricted notification of tenant destinations on its own", func() {
+		notification := &notifier.Notification{Namespace: "tenant", Status: "Succeeded"}
+		restricted := &notifier.Notification{Namespace: "tenant", Status: "Succeeded"}
+		destinations := []DestinationNotifier{
+			{Name: "platform"},
+			{Name: "tenant/service/hook", NotificationService: types.NamespacedName{Namespace: "tenant", Name: "service"}},
+			{Name: "tenant/service/chat", NotificationService: types.NamespacedName{Namespace: "tenant", Name: "service"}},
+		}
+		archivePayloads(context.Background(), &PayloadArchive{Store: store}, logr.Discard(), "uid", destinations, notification, restricted)
+		Expect(store.objects).To(HaveLen(2))
+		Expect(notification.PayloadRef).To(Equal("tenant/uid/Succeeded.json"))
+		Expect(restricted.PayloadRef).To(Equal("tenant/uid/Succeeded-restricted.json"))
+	})
+
+	It("should deliver without a link when the archive is unavailable", func() {
+		store.err = errors.New("unavailable")
+		notification := &notifier.Notification{Namespace: "tenant", Status: "Succeeded"}
+		archivePayloads(context.Background(), &PayloadArchive{Store: store}, logr.Discard(), "uid",
+			[]DestinationNotifier{{Name: "platform"}}, notification, nil)
+		Expect(notification.PayloadRef).To(BeEmpty())
+		Expect(notification.PayloadURL).To(BeEmpty())
+	})
+
+	It("should link the notifications of pipelineruns to their archived payload", func() {
+		fake := &fakeNotifier{}
+		r := &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Notifier: fake,
+			Archive: &PayloadArchive{Store: store}}
+		pipelineRun := createPipelineRun("archived", corev1.ConditionTrue)
+		Expect(reconcilePipelineRun(r, pipelineRun)).To(Succeed())
+		Expect(fake.notifications).To(HaveLen(1))
+		key := "default/" + string(pipelineRun.UID) + "/" + fake.notifications[0].Event + ".json"
+		Expect(fake.notifications[0].PayloadRef).To(Equal(key))
+		Expect(fake.notifications[0].PayloadURL).To(ContainSubstring(key))
+		Expect(store.objects).To(HaveKey(key))
+	})
+})
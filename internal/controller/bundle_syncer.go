@@ -0,0 +1,254 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	apiyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// +kubebuilder:rbac:groups=konflux.ci,resources=notificationtemplates,verbs=create;update;patch;delete
+// +kubebuilder:rbac:groups=konflux.ci,resources=clusternotificationservices,verbs=create;update;patch;delete
+
+// DefaultBundleRefreshInterval is how often the BundleSyncer pulls the configuration bundles
+const DefaultBundleRefreshInterval = 5 * time.Minute
+
+// BundleLayerMediaType is the media type of the layer holding the objects of configuration bundles
+const BundleLayerMediaType types.MediaType = "application/vnd.konflux-ci.notification.bundle.layer.v1+yaml"
+
+// MaxBundleBytes is the maximum size of the objects of a configuration bundle
+const MaxBundleBytes = 4 << 20
+
+// BundleLabel labels the objects applied from a configuration bundle with the name of the bundle
+const BundleLabel string = "konflux.ci/bundle"
+
+// BundleDigestAnnotation is the digest of the configuration bundle the object was last applied from
+const BundleDigestAnnotation string = "konflux.ci/bundle-digest"
+
+// bundleKinds are the kinds of the objects configuration bundles may hold
+var bundleKinds = []string{"NotificationTemplate", "NotificationService", "ClusterNotificationService"}
+
+var bundleSyncs = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "notification_service_bundle_syncs_total",
+	Help: "Number of refreshes of configuration bundles, by bundle and result: applied, unchanged or failed",
+}, []string{"bundle", "result"})
+
+func init() {
+	metrics.Registry.MustRegister(bundleSyncs)
+}
+
+// BundleSyncer applies the NotificationTemplates, NotificationServices and ClusterNotificationServices of the
+// configuration bundles of the controller configuration, and deletes the objects removed from them.
+// Bundles are OCI artifacts whose layer with the BundleLayerMediaType media type, or only layer, is a
+// multi-document YAML stream of objects, optionally gzip compressed. They are pulled every interval, and
+// applied again when their digest changed, so bundles referenced by tag are updated in place while bundles
+// referenced by digest are pinned. Objects removed from a bundle are deleted, the objects of bundles removed
+// from the configuration are kept.
+type BundleSyncer struct {
+	Client     client.Client
+	Log        logr.Logger
+	ConfigFile *ConfigFile
+	// Interval is how often the bundles are pulled
+	Interval time.Duration
+	// Pull downloads the layer of the bundle image and returns it with the digest of the image, PullBundle by default
+	Pull func(ctx context.Context, image string) ([]byte, string, error)
+
+	// applied are the digests of the bundles last applied, by name
+	applied map[string]string
+}
+
+// NeedLeaderElection returns true so bundles are applied by a single replica
+func (s *BundleSyncer) NeedLeaderElection() bool {
+	return true
+}
+
+// Start applies the bundles now and every interval until the context is cancelled
+func (s *BundleSyncer) Start(ctx context.Context) error {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = DefaultBundleRefreshInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := s.RunOnce(ctx); err != nil {
+			s.Log.Error(err, "Failed to apply configuration bundles")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce pulls the configured bundles and applies those whose digest changed since they were last applied.
+// A bundle failing to be pulled or applied does not prevent the others from being applied.
+// Return error joining the errors of the bundles that failed
+func (s *BundleSyncer) RunOnce(ctx context.Context) error {
+	if s.applied == nil {
+		s.applied = map[string]string{}
+	}
+	pull := s.Pull
+	if pull == nil {
+		pull = PullBundle
+	}
+	var errs []error
+	for _, bundle := range s.ConfigFile.Get().Bundles {
+		data, digest, err := pull(ctx, bundle.Image)
+		if err == nil && digest == s.applied[bundle.Name] {
+			bundleSyncs.WithLabelValues(bundle.Name, "unchanged").Inc()
+			continue
+		}
+		if err == nil {
+			err = s.apply(ctx, bundle, data, digest)
+		}
+		if err != nil {
+			bundleSyncs.WithLabelValues(bundle.Name, "failed").Inc()
+			errs = append(errs, fmt.Errorf("Failed to apply bundle %s: %w", bundle.Name, err))
+			continue
+		}
+		s.applied[bundle.Name] = digest
+		bundleSyncs.WithLabelValues(bundle.Name, "applied").Inc()
+		s.Log.Info("Applied configuration bundle", "bundle", bundle.Name, "digest", digest)
+	}
+	return errors.Join(errs...)
+}
+
+// apply applies the objects of the bundle and deletes the objects of the bundle it no longer holds.
+// No object is applied if one of them is invalid.
+func (s *BundleSyncer) apply(ctx context.Context, bundle ConfigBundle, data []byte, digest string) error {
+	objects, err := ParseBundle(data, bundle.Namespace)
+	if err != nil {
+		return err
+	}
+	held := map[string]bool{}
+	for _, obj := range objects {
+		labels := obj.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[BundleLabel] = bundle.Name
+		obj.SetLabels(labels)
+		annotations := obj.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[BundleDigestAnnotation] = digest
+		obj.SetAnnotations(annotations)
+		err = s.Client.Patch(ctx, obj, client.Apply, client.FieldOwner(NotificationFieldManager+"-bundles"), client.ForceOwnership)
+		if err != nil {
+			return fmt.Errorf("Failed to apply %s %s: %w", obj.GetKind(), bundleObjectKey(obj), err)
+		}
+		held[obj.GetKind()+"/"+bundleObjectKey(obj)] = true
+	}
+	for _, kind := range bundleKinds {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(v1alpha1.GroupVersion.WithKind(kind + "List"))
+		err = s.Client.List(ctx, list, client.MatchingLabels{BundleLabel: bundle.Name})
+		if err != nil {
+			return fmt.Errorf("Failed to list the %ss of the bundle: %w", kind, err)
+		}
+		for i := range list.Items {
+			obj := &list.Items[i]
+			if held[kind+"/"+bundleObjectKey(obj)] {
+				continue
+			}
+			err = s.Client.Delete(ctx, obj)
+			if client.IgnoreNotFound(err) != nil {
+				return fmt.Errorf("Failed to delete %s %s removed from the bundle: %w", kind, bundleObjectKey(obj), err)
+			}
+			s.Log.Info("Deleted object removed from configuration bundle", "bundle", bundle.Name, "kind", kind, "name", bundleObjectKey(obj))
+		}
+	}
+	return nil
+}
+
+// ParseBundle returns the objects of the multi-document YAML or JSON stream of a bundle, optionally gzip
+// compressed. Namespaced objects without a namespace are set in the namespace.
+// Return error if an object is malformed, is not of a bundle kind, or has no namespace while one is required
+func ParseBundle(data []byte, namespace string) ([]*unstructured.Unstructured, error) {
+	data, err := decompressLayer(data, MaxBundleBytes)
+	if err != nil {
+		return nil, err
+	}
+	var objects []*unstructured.Unstructured
+	decoder := apiyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		err = decoder.Decode(&obj.Object)
+		if errors.Is(err, io.EOF) {
+			return objects, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse object %d: %w", len(objects)+1, err)
+		}
+		if len(obj.Object) == 0 {
+			continue
+		}
+		gvk := obj.GroupVersionKind()
+		if gvk.Group != v1alpha1.GroupVersion.Group || !slices.Contains(bundleKinds, gvk.Kind) {
+			return nil, fmt.Errorf("Object %d is a %s, bundles only hold %v", len(objects)+1, gvk.GroupKind(), bundleKinds)
+		}
+		if obj.GetName() == "" {
+			return nil, fmt.Errorf("%s %d has no name", gvk.Kind, len(objects)+1)
+		}
+		if gvk.Kind == "ClusterNotificationService" {
+			obj.SetNamespace("")
+		} else if obj.GetNamespace() == "" {
+			if namespace == "" {
+				return nil, fmt.Errorf("%s %s has no namespace and the bundle has no default namespace", gvk.Kind, obj.GetName())
+			}
+			obj.SetNamespace(namespace)
+		}
+		// Applied objects must not carry server-side metadata
+		obj.SetResourceVersion("")
+		obj.SetUID("")
+		obj.SetManagedFields(nil)
+		unstructured.RemoveNestedField(obj.Object, "status")
+		objects = append(objects, obj)
+	}
+}
+
+// PullBundle downloads the objects of the bundle image, the content of its layer with the BundleLayerMediaType
+// media type, or of its only layer, and returns them with the digest of the image.
+// Return error if the image cannot be pulled or does not hold a single bundle layer
+func PullBundle(ctx context.Context, image string) ([]byte, string, error) {
+	return pullLayer(ctx, image, BundleLayerMediaType, MaxBundleBytes)
+}
+
+// bundleObjectKey returns the namespace and name of a namespaced object, or the name of a cluster scoped object
+func bundleObjectKey(obj client.Object) string {
+	if obj.GetNamespace() == "" {
+		return obj.GetName()
+	}
+	return obj.GetNamespace() + "/" + obj.GetName()
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Bundle syncer", func() {
+	newSyncer := func(config string, images map[string][]string) *BundleSyncer {
+		path := filepath.Join(GinkgoT().TempDir(), "config.yaml")
+		Expect(os.WriteFile(path, []byte(config), 0o600)).To(Succeed())
+		configFile, err := NewConfigFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		return &BundleSyncer{
+			Client:     k8sClient,
+			Log:        ctrl.Log.WithName("bundles"),
+			ConfigFile: configFile,
+			// images hold the digest and content of the bundle of each image
+			Pull: func(ctx context.Context, image string) ([]byte, string, error) {
+				return []byte(images[image][1]), images[image][0], nil
+			},
+		}
+	}
+
+	getTemplate := func(name string) (*v1alpha1.NotificationTemplate, error) {
+		template := &v1alpha1.NotificationTemplate{}
+		err := k8sClient.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: name}, template)
+		return template, err
+	}
+
+	It("should apply the objects of bundles, update them when the digest changes and delete the removed ones", func() {
+		ctx := context.Background()
+		images := map[string][]string{"registry.example.com/org/bundle:v1": {"sha256:1", `
+apiVersion: konflux.ci/v1alpha1
+kind: NotificationTemplate
+metadata:
+  name: bundle-build
+spec:
+  template: "build {{ .PipelineRun }}"
+---
+apiVersion: konflux.ci/v1alpha1
+kind: NotificationTemplate
+metadata:
+  name: bundle-release
+spec:
+  template: "release {{ .PipelineRun }}"
+`}}
+		syncer := newSyncer(`
+bundles:
+- name: org
+  image: registry.example.com/org/bundle:v1
+  namespace: default
+`, images)
+		DeferCleanup(func() {
+			_ = k8sClient.DeleteAllOf(context.Background(), &v1alpha1.NotificationTemplate{},
+				client.InNamespace("default"), client.MatchingLabels{BundleLabel: "org"})
+		})
+
+		Expect(syncer.RunOnce(ctx)).To(Succeed())
+		template, err := getTemplate("bundle-build")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(template.Spec.Template).To(Equal("build {{ .PipelineRun }}"))
+		Expect(template.Labels).To(HaveKeyWithValue(BundleLabel, "org"))
+		Expect(template.Annotations).To(HaveKeyWithValue(BundleDigestAnnotation, "sha256:1"))
+		_, err = getTemplate("bundle-release")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(testutil.ToFloat64(bundleSyncs.WithLabelValues("org", "applied"))).To(Equal(1.0))
+
+		// An unchanged digest is not applied again
+		Expect(syncer.RunOnce(ctx)).To(Succeed())
+		Expect(testutil.ToFloat64(bundleSyncs.WithLabelValues("org", "unchanged"))).To(Equal(1.0))
+
+		images["registry.example.com/org/bundle:v1"] = []string{"sha256:2", `
+apiVersion: konflux.ci/v1alpha1
+kind: NotificationTemplate
+metadata:
+  name: bundle-build
+spec:
+  template: "build {{ .PipelineRun }} v2"
+`}
+		Expect(syncer.RunOnce(ctx)).To(Succeed())
+		template, err = getTemplate("bundle-build")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(template.Spec.Template).To(Equal("build {{ .PipelineRun }} v2"))
+		Expect(template.Annotations).To(HaveKeyWithValue(BundleDigestAnnotation, "sha256:2"))
+		_, err = getTemplate("bundle-release")
+		Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+		Expect(testutil.ToFloat64(bundleSyncs.WithLabelValues("org", "applied"))).To(Equal(2.0))
+	})
+
+	It("should apply none of the objects of a bundle holding an invalid object", func() {
+		syncer := newSyncer(`
+bundles:
+- name: invalid
+  image: registry.example.com/org/invalid@sha256:0000000000000000000000000000000000000000000000000000000000000000
+  namespace: default
+`, map[string][]string{
+			"registry.example.com/org/invalid@sha256:0000000000000000000000000000000000000000000000000000000000000000": {"sha256:0", `
+apiVersion: konflux.ci/v1alpha1
+kind: NotificationTemplate
+metadata:
+  name: bundle-invalid
+spec:
+  template: "build"
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: bundle-secret
+`}})
+		Expect(syncer.RunOnce(context.Background())).To(MatchError(ContainSubstring("Secret")))
+		_, err := getTemplate("bundle-invalid")
+		Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+		Expect(testutil.ToFloat64(bundleSyncs.WithLabelValues("invalid", "failed"))).To(Equal(1.0))
+	})
+
+	It("should set the default namespace of namespaced objects and require one", func() {
+		objects, err := ParseBundle([]byte(`
+apiVersion: konflux.ci/v1alpha1
+kind: NotificationTemplate
+metadata:
+  name: template
+---
+apiVersion: konflux.ci/v1alpha1
+kind: ClusterNotificationService
+metadata:
+  name: cluster
+  namespace: ignored
+`), "team")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(objects).To(HaveLen(2))
+		Expect(objects[0].GetNamespace()).To(Equal("team"))
+		Expect(objects[1].GetNamespace()).To(BeEmpty())
+
+		_, err = ParseBundle([]byte("apiVersion: konflux.ci/v1alpha1\nkind: NotificationService\nmetadata:\n  name: service\n"), "")
+		Expect(err).To(MatchError(ContainSubstring("no namespace")))
+	})
+
+	It("should reject bundles with invalid names or images", func() {
+		_, err := ParseControllerConfig([]byte("bundles:\n- name: org\n  image: registry.example.com/org/bundle:v1\n- name: org\n  image: registry.example.com/org/other:v1\n"))
+		Expect(err).To(MatchError(ContainSubstring("Invalid bundle name")))
+		_, err = ParseControllerConfig([]byte("bundles:\n- name: org\n  image: 'registry.example.com/Org/bundle:v1'\n"))
+		Expect(err).To(MatchError(ContainSubstring("Invalid image of bundle org")))
+	})
+})
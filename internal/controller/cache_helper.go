@@ -0,0 +1,88 @@
+package controller
+
+import (
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NewCacheOptions returns the cache options of the manager. The managed fields of all cached objects
+// are stripped, apart from those of other field managers holding the markers of the controller on
+// pipelineruns, as well as the resolved specs, apart from the matrices of pipeline tasks, provenance and
+// tracing data of cached pipelineruns and taskruns, which the controller never reads and which make up
+// most of their size.
+func NewCacheOptions() cache.Options {
+	return cache.Options{
+		DefaultTransform: cache.TransformStripManagedFields(),
+		ByObject: map[client.Object]cache.ByObject{
+			&tektonv1.PipelineRun{}: {Transform: StripPipelineRun},
+			&tektonv1.TaskRun{}:     {Transform: StripTaskRun},
+		},
+	}
+}
+
+// StripPipelineRun removes the data the controller does not use from a pipelinerun before it is cached
+func StripPipelineRun(obj any) (any, error) {
+	pipelineRun, ok := obj.(*tektonv1.PipelineRun)
+	if !ok {
+		return obj, nil
+	}
+	foreign := foreignMarkerEntries(pipelineRun)
+	stripObjectMeta(pipelineRun)
+	if foreign != nil {
+		pipelineRun.SetManagedFields(foreign)
+	}
+	pipelineRun.Status.PipelineSpec = stripPipelineSpec(pipelineRun.Status.PipelineSpec)
+	pipelineRun.Status.Provenance = nil
+	pipelineRun.Status.SpanContext = nil
+	return pipelineRun, nil
+}
+
+// stripPipelineSpec returns the tasks of the resolved pipeline spec that have a matrix, with only their
+// name and matrix, or nil if no task has a matrix
+func stripPipelineSpec(spec *tektonv1.PipelineSpec) *tektonv1.PipelineSpec {
+	if spec == nil {
+		return nil
+	}
+	stripped := &tektonv1.PipelineSpec{}
+	for _, task := range spec.Tasks {
+		if task.Matrix != nil {
+			stripped.Tasks = append(stripped.Tasks, tektonv1.PipelineTask{Name: task.Name, Matrix: task.Matrix})
+		}
+	}
+	for _, task := range spec.Finally {
+		if task.Matrix != nil {
+			stripped.Finally = append(stripped.Finally, tektonv1.PipelineTask{Name: task.Name, Matrix: task.Matrix})
+		}
+	}
+	if len(stripped.Tasks) == 0 && len(stripped.Finally) == 0 {
+		return nil
+	}
+	return stripped
+}
+
+// StripTaskRun removes the data the controller does not use from a taskrun before it is cached
+func StripTaskRun(obj any) (any, error) {
+	taskRun, ok := obj.(*tektonv1.TaskRun)
+	if !ok {
+		return obj, nil
+	}
+	stripObjectMeta(taskRun)
+	taskRun.Status.TaskSpec = nil
+	taskRun.Status.Provenance = nil
+	taskRun.Status.SpanContext = nil
+	return taskRun, nil
+}
+
+// stripObjectMeta removes the managed fields and the last applied configuration of the object
+func stripObjectMeta(obj client.Object) {
+	if obj.GetManagedFields() != nil {
+		obj.SetManagedFields(nil)
+	}
+	if _, ok := obj.GetAnnotations()[corev1.LastAppliedConfigAnnotation]; ok {
+		annotations := obj.GetAnnotations()
+		delete(annotations, corev1.LastAppliedConfigAnnotation)
+		obj.SetAnnotations(annotations)
+	}
+}
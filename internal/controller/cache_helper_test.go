@@ -0,0 +1,103 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Cache transforms", func() {
+	It("should strip unused data from pipelineruns", func() {
+		pipelineRun := &tektonv1.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:          "cached",
+				ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "tekton"}},
+				Annotations: map[string]string{
+					corev1.LastAppliedConfigAnnotation: "{}",
+					NotificationPipelineRunAnnotation:  NotificationPipelineRunAnnotationValue,
+				},
+			},
+			Spec: tektonv1.PipelineRunSpec{PipelineRef: &tektonv1.PipelineRef{Name: "build"}},
+		}
+		pipelineRun.Status.PipelineSpec = &tektonv1.PipelineSpec{Description: "resolved"}
+		pipelineRun.Status.SpanContext = map[string]string{"traceparent": "00"}
+
+		stripped, err := StripPipelineRun(pipelineRun)
+		Expect(err).NotTo(HaveOccurred())
+		pr := stripped.(*tektonv1.PipelineRun)
+		Expect(pr.ManagedFields).To(BeNil())
+		Expect(pr.Annotations).To(Equal(map[string]string{NotificationPipelineRunAnnotation: NotificationPipelineRunAnnotationValue}))
+		Expect(pr.Status.PipelineSpec).To(BeNil())
+		Expect(pr.Status.SpanContext).To(BeNil())
+		Expect(pr.Spec.PipelineRef.Name).To(Equal("build"))
+	})
+
+	It("should keep the managed fields of other controllers holding the markers", func() {
+		foreign := metav1.ManagedFieldsEntry{
+			Manager:  "manager",
+			FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:metadata":{"f:finalizers":{".":{},"v:\"` + NotificationPipelineRunFinalizer + `\"":{}}}}`)},
+		}
+		pipelineRun := &tektonv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{
+			ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "tekton"}, foreign},
+		}}
+
+		stripped, err := StripPipelineRun(pipelineRun)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stripped.(*tektonv1.PipelineRun).ManagedFields).To(Equal([]metav1.ManagedFieldsEntry{foreign}))
+	})
+
+	It("should keep the matrices of pipeline tasks", func() {
+		matrix := &tektonv1.Matrix{Params: tektonv1.Params{{Name: "PLATFORM", Value: *tektonv1.NewStructuredValues("linux/amd64")}}}
+		pipelineRun := &tektonv1.PipelineRun{}
+		pipelineRun.Status.PipelineSpec = &tektonv1.PipelineSpec{
+			Description: "resolved",
+			Tasks: []tektonv1.PipelineTask{
+				{Name: "clone", TaskRef: &tektonv1.TaskRef{Name: "git-clone"}},
+				{Name: "build", TaskRef: &tektonv1.TaskRef{Name: "buildah"}, Matrix: matrix},
+			},
+		}
+
+		stripped, err := StripPipelineRun(pipelineRun)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stripped.(*tektonv1.PipelineRun).Status.PipelineSpec).To(Equal(&tektonv1.PipelineSpec{
+			Tasks: []tektonv1.PipelineTask{{Name: "build", Matrix: matrix}},
+		}))
+	})
+
+	It("should keep the steps of taskruns", func() {
+		taskRun := &tektonv1.TaskRun{ObjectMeta: metav1.ObjectMeta{ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "tekton"}}}}
+		taskRun.Status.TaskSpec = &tektonv1.TaskSpec{Description: "resolved"}
+		taskRun.Status.Steps = []tektonv1.StepState{{Name: "build"}}
+
+		stripped, err := StripTaskRun(taskRun)
+		Expect(err).NotTo(HaveOccurred())
+		tr := stripped.(*tektonv1.TaskRun)
+		Expect(tr.ManagedFields).To(BeNil())
+		Expect(tr.Status.TaskSpec).To(BeNil())
+		Expect(tr.Status.Steps).To(HaveLen(1))
+	})
+
+	It("should ignore other objects", func() {
+		pod := &corev1.Pod{}
+		Expect(StripPipelineRun(pod)).To(BeIdenticalTo(pod))
+	})
+})
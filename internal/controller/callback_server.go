@@ -0,0 +1,120 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// CallbackServer receives acknowledgements from destinations that process notifications asynchronously
+// and releases the PipelineRuns waiting for them. It also serves the interactions of Slack messages.
+type CallbackServer struct {
+	Client client.Client
+	Log    logr.Logger
+	// BindAddress is the address the acknowledgement endpoint listens on
+	BindAddress string
+	// Secret verifies the callback tokens, it must match the secret of the reconciler
+	Secret []byte
+	// SlackActions handles Slack interactions on SlackActionsPath, if set
+	SlackActions http.Handler
+}
+
+// NeedLeaderElection returns false so every replica accepts acknowledgements
+func (s *CallbackServer) NeedLeaderElection() bool {
+	return false
+}
+
+// Start serves the acknowledgement endpoint until the context is cancelled
+func (s *CallbackServer) Start(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.Handle(CallbackPath, s)
+	if s.SlackActions != nil {
+		mux.Handle(SlackActionsPath, s.SlackActions)
+	}
+	server := &http.Server{
+		Addr:              s.BindAddress,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+	s.Log.Info("Serving acknowledgements", "address", s.BindAddress)
+	err := server.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// ServeHTTP acknowledges the notification identified by the token in the request path
+func (s *CallbackServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	pipelineRunName, destination, err := ParseCallbackToken(s.Secret, strings.TrimPrefix(req.URL.Path, CallbackPath))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	logger := s.Log.WithValues("pipelinerun", pipelineRunName, "destination", destination)
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		pipelineRun := &tektonv1.PipelineRun{}
+		err := s.Client.Get(req.Context(), pipelineRunName, pipelineRun)
+		if err != nil {
+			return err
+		}
+		err = LoadPipelineRunState(req.Context(), s.Client, pipelineRun)
+		if err != nil {
+			return err
+		}
+		deadlines, err := GetAcknowledgementDeadlines(pipelineRun)
+		if err != nil {
+			return err
+		}
+		if _, ok := deadlines[destination]; !ok {
+			return nil
+		}
+		delete(deadlines, destination)
+		return SetAcknowledgementDeadlines(req.Context(), pipelineRun, s.Client, deadlines)
+	})
+	if k8serrors.IsNotFound(err) {
+		http.Error(w, "pipelinerun not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logger.Error(err, "Failed to acknowledge notification")
+		http.Error(w, "failed to acknowledge notification", http.StatusInternalServerError)
+		return
+	}
+	logger.Info("Notification acknowledged")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Callback acknowledgements", func() {
+	secret := []byte("callback-secret")
+	var callbackURLs []string
+	var mu sync.Mutex
+
+	// createAcknowledgedDestination declares a webhook destination that has to acknowledge notifications
+	createAcknowledgedDestination := func(timeout time.Duration) {
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			notification := &notifier.Notification{}
+			Expect(json.NewDecoder(req.Body).Decode(notification)).To(Succeed())
+			mu.Lock()
+			defer mu.Unlock()
+			callbackURLs = append(callbackURLs, notification.CallbackURL)
+		}))
+		DeferCleanup(receiver.Close)
+		notificationService := &v1alpha1.NotificationService{
+			ObjectMeta: metav1.ObjectMeta{Name: "acknowledged", Namespace: "default"},
+			Spec: v1alpha1.NotificationServiceSpec{
+				Destinations: []v1alpha1.Destination{{
+					Name:                   "async",
+					Webhook:                &v1alpha1.WebhookDestination{URL: receiver.URL},
+					AcknowledgementTimeout: &metav1.Duration{Duration: timeout},
+				}},
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), notificationService)).To(Succeed())
+		DeferCleanup(func() {
+			Expect(k8sClient.Delete(context.Background(), notificationService)).To(Succeed())
+		})
+	}
+
+	BeforeEach(func() {
+		callbackURLs = nil
+	})
+
+	It("should keep the finalizer until the destination acknowledges", func() {
+		createAcknowledgedDestination(time.Hour)
+		pipelineRun := createPipelineRun("acknowledged", corev1.ConditionTrue)
+		r := &NotificationServiceReconciler{
+			Client:         k8sClient,
+			Scheme:         k8sClient.Scheme(),
+			CallbackURL:    "https://notifications.example.com/",
+			CallbackSecret: secret,
+		}
+		result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pipelineRun)})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(BeNumerically(">", 59*time.Minute))
+		Expect(getPipelineRun(pipelineRun).Finalizers).To(ContainElement(NotificationPipelineRunFinalizer))
+
+		Expect(callbackURLs).To(HaveLen(1))
+		callbackURL, err := url.Parse(callbackURLs[0])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(callbackURL.Host).To(Equal("notifications.example.com"))
+		Expect(callbackURL.Path).To(HavePrefix(CallbackPath))
+
+		server := &CallbackServer{Client: k8sClient, Secret: secret}
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, callbackURL.Path, nil))
+		Expect(recorder.Code).To(Equal(http.StatusNoContent))
+
+		Expect(reconcilePipelineRun(r, pipelineRun)).To(Succeed())
+		pr := getPipelineRun(pipelineRun)
+		Expect(pr.Finalizers).NotTo(ContainElement(NotificationPipelineRunFinalizer))
+		Expect(pr.Annotations).NotTo(HaveKey(NotificationAwaitingAcknowledgementAnnotation))
+	})
+
+	It("should release the pipelinerun when the acknowledgement times out", func() {
+		createAcknowledgedDestination(time.Second)
+		pipelineRun := createPipelineRun("unacknowledged", corev1.ConditionTrue)
+		r := &NotificationServiceReconciler{
+			Client:         k8sClient,
+			Scheme:         k8sClient.Scheme(),
+			CallbackURL:    "https://notifications.example.com",
+			CallbackSecret: secret,
+		}
+		Expect(reconcilePipelineRun(r, pipelineRun)).To(Succeed())
+		Expect(getPipelineRun(pipelineRun).Annotations).To(HaveKey(NotificationAwaitingAcknowledgementAnnotation))
+
+		Eventually(func() []string {
+			Expect(reconcilePipelineRun(r, pipelineRun)).To(Succeed())
+			return getPipelineRun(pipelineRun).Finalizers
+		}, 5*time.Second, 500*time.Millisecond).ShouldNot(ContainElement(NotificationPipelineRunFinalizer))
+	})
+
+	It("should not wait for acknowledgements without a callback URL", func() {
+		createAcknowledgedDestination(time.Hour)
+		pipelineRun := createPipelineRun("no-callback", corev1.ConditionTrue)
+		r := &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+		Expect(reconcilePipelineRun(r, pipelineRun)).To(Succeed())
+		Expect(callbackURLs).To(Equal([]string{""}))
+		Expect(getPipelineRun(pipelineRun).Finalizers).NotTo(ContainElement(NotificationPipelineRunFinalizer))
+	})
+
+	It("should reject forged callback tokens", func() {
+		pipelineRun := types.NamespacedName{Namespace: "default", Name: "forged"}
+		token := NewCallbackToken([]byte("other-secret"), pipelineRun, "default/acknowledged/async")
+		_, _, err := ParseCallbackToken(secret, token)
+		Expect(err).To(HaveOccurred())
+
+		server := &CallbackServer{Client: k8sClient, Secret: secret}
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, CallbackPath+token, nil))
+		Expect(recorder.Code).To(Equal(http.StatusForbidden))
+
+		name, destination, err := ParseCallbackToken(secret, NewCallbackToken(secret, pipelineRun, "default/acknowledged/async"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(name).To(Equal(pipelineRun))
+		Expect(destination).To(Equal("default/acknowledged/async"))
+	})
+})
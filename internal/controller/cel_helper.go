@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// celEnvironment declares the resource evaluated by CEL expressions as the object variable
+var celEnvironment = sync.OnceValues(func() (*cel.Env, error) {
+	return cel.NewEnv(cel.Variable("object", cel.DynType))
+})
+
+// celPrograms caches the compiled CEL programs by expression
+var celPrograms sync.Map
+
+// compileCELExpression returns the program of the CEL expression, compiled once
+// Return error if the expression is not valid
+func compileCELExpression(expression string) (cel.Program, error) {
+	if program, ok := celPrograms.Load(expression); ok {
+		return program.(cel.Program), nil
+	}
+	env, err := celEnvironment()
+	if err != nil {
+		return nil, err
+	}
+	ast, issues := env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("Failed to compile expression %q: %w", expression, issues.Err())
+	}
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to compile expression %q: %w", expression, err)
+	}
+	celPrograms.Store(expression, program)
+	return program, nil
+}
+
+// EvaluateCELCondition returns whether the CEL expression is true for the object.
+// Return error if the expression is not valid, fails or is not a boolean
+func EvaluateCELCondition(expression string, object map[string]any) (bool, error) {
+	program, err := compileCELExpression(expression)
+	if err != nil {
+		return false, err
+	}
+	out, _, err := program.Eval(map[string]any{"object": object})
+	if err != nil {
+		return false, fmt.Errorf("Failed to evaluate expression %q: %w", expression, err)
+	}
+	value, ok := out.Value().(bool)
+	if !ok || out.Type() != types.BoolType {
+		return false, fmt.Errorf("Expression %q is not a boolean, got %s", expression, out.Type().TypeName())
+	}
+	return value, nil
+}
+
+// EvaluateCELString returns the value of the CEL expression for the object, as is for strings and
+// encoded as JSON otherwise.
+// Return error if the expression is not valid or fails
+func EvaluateCELString(expression string, object map[string]any) (string, error) {
+	program, err := compileCELExpression(expression)
+	if err != nil {
+		return "", err
+	}
+	out, _, err := program.Eval(map[string]any{"object": object})
+	if err != nil {
+		return "", fmt.Errorf("Failed to evaluate expression %q: %w", expression, err)
+	}
+	if value, ok := out.Value().(string); ok {
+		return value, nil
+	}
+	native, err := out.ConvertToNative(reflect.TypeOf(&structpb.Value{}))
+	if err != nil {
+		return "", fmt.Errorf("Failed to convert the value of expression %q: %w", expression, err)
+	}
+	encoded, err := protojson.Marshal(native.(*structpb.Value))
+	if err != nil {
+		return "", fmt.Errorf("Failed to encode the value of expression %q: %w", expression, err)
+	}
+	return string(encoded), nil
+}
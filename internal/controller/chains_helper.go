@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+)
+
+const (
+	// ChainsSignedAnnotation is set by Tekton Chains to "true" once it signed the pipelinerun, or to "failed"
+	ChainsSignedAnnotation string = "chains.tekton.dev/signed"
+	// ChainsTransparencyAnnotation is set by Tekton Chains to the transparency log entry of the signature
+	ChainsTransparencyAnnotation string = "chains.tekton.dev/transparency"
+)
+
+// GetChainsSignature returns the Tekton Chains signature status of the pipelineRun,
+// or nil if the pipelinerun is not handled by Chains and required is not set
+func GetChainsSignature(pipelineRun *tektonv1.PipelineRun, required bool) *notifier.ChainsSignature {
+	signed, ok := pipelineRun.Annotations[ChainsSignedAnnotation]
+	if !ok && !required {
+		return nil
+	}
+	signature := &notifier.ChainsSignature{
+		Status:       notifier.ChainsUnsigned,
+		Transparency: pipelineRun.Annotations[ChainsTransparencyAnnotation],
+	}
+	switch signed {
+	case "true":
+		signature.Status = notifier.ChainsSigned
+	case "failed":
+		signature.Status = notifier.ChainsFailed
+	}
+	return signature
+}
+
+// ChainsPendingTimeout returns how long the pipelineRun still has to wait for Tekton Chains to sign it,
+// or zero if Chains signed it, failed to, or did not within the timeout after the pipelinerun completed
+func ChainsPendingTimeout(pipelineRun *tektonv1.PipelineRun, timeout time.Duration, now time.Time) time.Duration {
+	if pipelineRun.Annotations[ChainsSignedAnnotation] != "" {
+		return 0
+	}
+	if pipelineRun.Status.CompletionTime == nil {
+		return 0
+	}
+	remaining := pipelineRun.Status.CompletionTime.Add(timeout).Sub(now)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ClusterDestinationPrefix prefixes the names of the destinations of ClusterNotificationServices
+const ClusterDestinationPrefix string = "cluster"
+
+// GetClusterNotificationServices returns the ClusterNotificationServices whose namespace selector selects the namespace
+// ClusterNotificationServices with an invalid selector are skipped and reported in the log
+// Return error if failed to list the ClusterNotificationServices or to get the namespace
+func GetClusterNotificationServices(ctx context.Context, c client.Reader, logger logr.Logger, namespace string) ([]v1alpha1.ClusterNotificationService, error) {
+	clusterNotificationServices := &v1alpha1.ClusterNotificationServiceList{}
+	err := c.List(ctx, clusterNotificationServices)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list ClusterNotificationServices: %w", err)
+	}
+	var namespaceLabels labels.Set
+	var selected []v1alpha1.ClusterNotificationService
+	for _, clusterNotificationService := range clusterNotificationServices.Items {
+		if clusterNotificationService.Spec.NamespaceSelector == nil {
+			selected = append(selected, clusterNotificationService)
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(clusterNotificationService.Spec.NamespaceSelector)
+		if err != nil {
+			logger.Error(err, "Skipping ClusterNotificationService with an invalid namespace selector",
+				"clusterNotificationService", clusterNotificationService.Name)
+			continue
+		}
+		if namespaceLabels == nil {
+			ns := &corev1.Namespace{}
+			err = c.Get(ctx, types.NamespacedName{Name: namespace}, ns)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to get namespace %s: %w", namespace, err)
+			}
+			namespaceLabels = labels.Set(ns.Labels)
+			if namespaceLabels == nil {
+				namespaceLabels = labels.Set{}
+			}
+		}
+		if selector.Matches(namespaceLabels) {
+			selected = append(selected, clusterNotificationService)
+		}
+	}
+	return selected, nil
+}
+
+// GetClusterDestinations returns the notifiers of the destinations of a ClusterNotificationService, identified as
+// cluster/<clusternotificationservice>/<destination>. If the ClusterNotificationService allows overrides, the
+// destinations whose name is declared by the NotificationServices of the namespace are skipped.
+// Destinations that are not valid are skipped and reported in the log
+func GetClusterDestinations(ctx context.Context, c client.Reader, logger logr.Logger, clusterNotificationService *v1alpha1.ClusterNotificationService, declared map[string]bool) []DestinationNotifier {
+	var destinations []v1alpha1.Destination
+	for _, destination := range clusterNotificationService.Spec.Destinations {
+		if clusterNotificationService.Spec.AllowOverride && declared[destination.Name] {
+			continue
+		}
+		destinations = append(destinations, destination)
+	}
+	return newDestinationNotifiers(ctx, c, logger, clusterNotificationService.Spec.SecretNamespace,
+		ClusterDestinationPrefix+"/"+clusterNotificationService.Name, destinations)
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("ClusterNotificationService destinations", func() {
+	hook := func(name string) v1alpha1.Destination {
+		return v1alpha1.Destination{Name: name, Webhook: &v1alpha1.WebhookDestination{URL: "http://localhost"}}
+	}
+
+	createClusterNotificationService := func(name string, spec v1alpha1.ClusterNotificationServiceSpec) {
+		clusterNotificationService := &v1alpha1.ClusterNotificationService{ObjectMeta: metav1.ObjectMeta{Name: name}, Spec: spec}
+		Expect(k8sClient.Create(context.Background(), clusterNotificationService)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), clusterNotificationService)
+	}
+
+	BeforeEach(func() {
+		namespaces := map[string]map[string]string{
+			"cluster-platform": {"team": "platform"},
+			"cluster-other":    nil,
+		}
+		for name, namespaceLabels := range namespaces {
+			namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: namespaceLabels}}
+			if k8sClient.Get(context.Background(), client.ObjectKeyFromObject(namespace), &corev1.Namespace{}) != nil {
+				Expect(k8sClient.Create(context.Background(), namespace)).To(Succeed())
+			}
+		}
+	})
+
+	It("should merge the destinations of the selecting ClusterNotificationServices", func() {
+		createClusterNotificationService("platform", v1alpha1.ClusterNotificationServiceSpec{
+			Destinations:      []v1alpha1.Destination{hook("audit"), hook("chat")},
+			SecretNamespace:   "default",
+			NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "platform"}},
+			AllowOverride:     true,
+		})
+		createClusterNotificationService("everyone", v1alpha1.ClusterNotificationServiceSpec{
+			Destinations:    []v1alpha1.Destination{hook("chat")},
+			SecretNamespace: "default",
+		})
+		notificationService := &v1alpha1.NotificationService{
+			ObjectMeta: metav1.ObjectMeta{Name: "tenant", Namespace: "cluster-platform"},
+			Spec:       v1alpha1.NotificationServiceSpec{Destinations: []v1alpha1.Destination{hook("chat")}},
+		}
+		Expect(k8sClient.Create(context.Background(), notificationService)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), notificationService)
+
+		r := &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+		destinations, err := GetDestinationNotifiers(context.Background(), r, "cluster-platform")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(destinations).To(ConsistOf(
+			HaveField("Name", "cluster-platform/tenant/chat"),
+			HaveField("Name", "cluster/platform/audit"),
+			HaveField("Name", "cluster/everyone/chat"),
+		))
+		destinations, err = GetDestinationNotifiers(context.Background(), r, "cluster-other")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(destinations).To(ConsistOf(HaveField("Name", "cluster/everyone/chat")))
+	})
+
+	It("should resolve the destinations of the ClusterNotificationServices selecting the namespace", func() {
+		createClusterNotificationService("resolved", v1alpha1.ClusterNotificationServiceSpec{
+			Destinations:      []v1alpha1.Destination{hook("audit")},
+			SecretNamespace:   "default",
+			NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "platform"}},
+		})
+		r := &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+		destination, err := ResolveDestination(context.Background(), r, "cluster-platform", "cluster/resolved/audit")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(destination.Name).To(Equal("cluster/resolved/audit"))
+		Expect(destination.Notifier).NotTo(BeNil())
+
+		_, err = ResolveDestination(context.Background(), r, "cluster-other", "cluster/resolved/audit")
+		Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+		_, err = ResolveDestination(context.Background(), r, "cluster-platform", "cluster/resolved/missing")
+		Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should map ClusterNotificationServices to the running pipelineruns of the selected namespaces", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		DeferCleanup(cancel)
+		informers, err := cache.New(cfg, cache.Options{Scheme: k8sClient.Scheme()})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(IndexRunningPipelineRuns(ctx, informers)).To(Succeed())
+		go func() {
+			defer GinkgoRecover()
+			Expect(informers.Start(ctx)).To(Succeed())
+		}()
+		Expect(informers.WaitForCacheSync(ctx)).To(BeTrue())
+		cached, err := client.New(cfg, client.Options{Scheme: k8sClient.Scheme(), Cache: &client.CacheOptions{Reader: informers}})
+		Expect(err).NotTo(HaveOccurred())
+
+		for _, namespace := range []string{"cluster-platform", "cluster-other"} {
+			pipelineRun := &tektonv1.PipelineRun{
+				ObjectMeta: metav1.ObjectMeta{Name: "cluster-running", Namespace: namespace},
+				Spec:       tektonv1.PipelineRunSpec{PipelineRef: &tektonv1.PipelineRef{Name: "build"}},
+			}
+			Expect(k8sClient.Create(ctx, pipelineRun)).To(Succeed())
+			DeferCleanup(k8sClient.Delete, context.Background(), pipelineRun)
+		}
+		ended := &tektonv1.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-ended", Namespace: "cluster-platform"},
+			Spec:       tektonv1.PipelineRunSpec{PipelineRef: &tektonv1.PipelineRef{Name: "build"}},
+		}
+		Expect(k8sClient.Create(ctx, ended)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), ended)
+		ended.Status.Conditions = duckv1.Conditions{{Type: apis.ConditionSucceeded, Status: corev1.ConditionTrue}}
+		Expect(k8sClient.Status().Update(ctx, ended)).To(Succeed())
+
+		r := &NotificationServiceReconciler{Client: cached, Scheme: k8sClient.Scheme()}
+		mapped := func(namespaceSelector *metav1.LabelSelector) func() []reconcile.Request {
+			return func() []reconcile.Request {
+				var requests []reconcile.Request
+				for _, request := range r.mapClusterNotificationService(ctx, &v1alpha1.ClusterNotificationService{
+					ObjectMeta: metav1.ObjectMeta{Name: "mapped"},
+					Spec:       v1alpha1.ClusterNotificationServiceSpec{NamespaceSelector: namespaceSelector},
+				}) {
+					// Ignore the pipelineruns left running by other tests
+					if strings.HasPrefix(request.Name, "cluster-") {
+						requests = append(requests, request)
+					}
+				}
+				return requests
+			}
+		}
+		Eventually(mapped(&metav1.LabelSelector{MatchLabels: map[string]string{"team": "platform"}})).Should(ConsistOf(
+			reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "cluster-platform", Name: "cluster-running"}},
+		))
+		Eventually(mapped(nil)).Should(ConsistOf(
+			reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "cluster-platform", Name: "cluster-running"}},
+			reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "cluster-other", Name: "cluster-running"}},
+		))
+	})
+
+	It("should hold pipelineruns for ClusterNotificationServices that are not best-effort", func() {
+		bestEffort := false
+		createClusterNotificationService("guaranteed", v1alpha1.ClusterNotificationServiceSpec{
+			Destinations:    []v1alpha1.Destination{hook("audit")},
+			SecretNamespace: "default",
+			BestEffort:      &bestEffort,
+		})
+		r := &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), BestEffort: true}
+		Expect(NeedsFinalizer(context.Background(), r, "cluster-other")).To(BeTrue())
+	})
+})
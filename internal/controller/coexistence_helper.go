@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DuplicateControllerCondition reports whether another controller sets the finalizer or notified annotation of
+// the controller on the pipelineruns of the namespace of a NotificationService
+const DuplicateControllerCondition string = "DuplicateController"
+
+// DuplicateControllerReason is the reason of the events of the pipelineruns handed off to another controller
+const DuplicateControllerReason string = "DuplicateController"
+
+// markerFields is the part of the fields of a managed fields entry covering the markers of the controller
+type markerFields struct {
+	Metadata struct {
+		Finalizers  map[string]json.RawMessage `json:"f:finalizers"`
+		Annotations map[string]json.RawMessage `json:"f:annotations"`
+	} `json:"f:metadata"`
+}
+
+// ownsMarkers returns a boolean indicating whether the managed fields entry owns the finalizer or the notified
+// annotation of the controller. The other annotations may be carried over from earlier versions and tools.
+func ownsMarkers(entry metav1.ManagedFieldsEntry) bool {
+	if entry.FieldsV1 == nil {
+		return false
+	}
+	fields := markerFields{}
+	if json.Unmarshal(entry.FieldsV1.Raw, &fields) != nil {
+		return false
+	}
+	if _, ok := fields.Metadata.Finalizers[`v:"`+NotificationPipelineRunFinalizer+`"`]; ok {
+		return true
+	}
+	_, ok := fields.Metadata.Annotations["f:"+NotificationPipelineRunAnnotation]
+	return ok
+}
+
+// foreignMarkerEntries returns the managed fields entries of other field managers than NotificationFieldManager
+// owning the markers of the controller
+func foreignMarkerEntries(obj metav1.Object) []metav1.ManagedFieldsEntry {
+	var entries []metav1.ManagedFieldsEntry
+	for _, entry := range obj.GetManagedFields() {
+		if entry.Manager != NotificationFieldManager && ownsMarkers(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// ForeignMarkerManagers returns the other field managers than NotificationFieldManager that set the finalizer
+// or notified annotation of the controller on the object since the time, e.g. an older deployment of the controller
+// using the same marker prefix. Markers set before the time, e.g. by the previous version of the controller
+// before an upgrade, are adopted. Managers are sorted.
+func ForeignMarkerManagers(obj metav1.Object, since time.Time) []string {
+	var managers []string
+	for _, entry := range foreignMarkerEntries(obj) {
+		if entry.Time != nil && entry.Time.Time.Before(since.Truncate(time.Second)) {
+			continue
+		}
+		if !slices.Contains(managers, entry.Manager) {
+			managers = append(managers, entry.Manager)
+		}
+	}
+	slices.Sort(managers)
+	return managers
+}
+
+// setDuplicateControllerCondition sets the DuplicateController condition of the NotificationServices of the
+// namespace, to true if the markers of the pipelinerun are held by the managers, and otherwise to false on
+// those where it is true
+func (r *NotificationServiceReconciler) setDuplicateControllerCondition(ctx context.Context, namespace string,
+	pipelineRun string, managers []string) error {
+	notificationServices := &v1alpha1.NotificationServiceList{}
+	err := r.List(ctx, notificationServices, client.InNamespace(namespace))
+	if err != nil {
+		return fmt.Errorf("Failed to list NotificationServices of namespace %s: %w", namespace, err)
+	}
+	var errs []error
+	for i := range notificationServices.Items {
+		notificationService := &notificationServices.Items[i]
+		condition := metav1.Condition{
+			Type:               DuplicateControllerCondition,
+			Status:             metav1.ConditionFalse,
+			Reason:             "NoDuplicateController",
+			Message:            fmt.Sprintf("PipelineRun %s was handled by %s alone", pipelineRun, NotificationFieldManager),
+			ObservedGeneration: notificationService.Generation,
+		}
+		if len(managers) > 0 {
+			condition.Status = metav1.ConditionTrue
+			condition.Reason = "ForeignMarkers"
+			condition.Message = fmt.Sprintf("PipelineRun %s carries notification markers set by %s, which is left to handle it: "+
+				"only one controller should use the marker prefix %s", pipelineRun, strings.Join(managers, ", "),
+				strings.TrimSuffix(NotificationPipelineRunFinalizer, "/notification"))
+		} else if !meta.IsStatusConditionTrue(notificationService.Status.Conditions, DuplicateControllerCondition) {
+			continue
+		}
+		patch := client.MergeFrom(notificationService.DeepCopy())
+		if !meta.SetStatusCondition(&notificationService.Status.Conditions, condition) {
+			continue
+		}
+		err = r.Status().Patch(ctx, notificationService, patch)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Failed to set the %s condition of NotificationService %s/%s: %w",
+				DuplicateControllerCondition, notificationService.Namespace, notificationService.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+var _ = Describe("Duplicate controllers", func() {
+	var notificationService *v1alpha1.NotificationService
+
+	BeforeEach(func() {
+		receiver := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+		DeferCleanup(receiver.Close)
+		notificationService = &v1alpha1.NotificationService{
+			ObjectMeta: metav1.ObjectMeta{Name: "coexistence", Namespace: "default"},
+			Spec: v1alpha1.NotificationServiceSpec{
+				Destinations: []v1alpha1.Destination{{Name: "hook", Webhook: &v1alpha1.WebhookDestination{URL: receiver.URL}}},
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), notificationService)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), notificationService)
+	})
+
+	// markByOlderController adds the finalizer of the controller to the pipelinerun with a merge patch
+	// of another field manager, like versions of the controller before server-side apply
+	markByOlderController := func(pipelineRun *tektonv1.PipelineRun) {
+		pr := getPipelineRun(pipelineRun)
+		patch := client.MergeFrom(pr.DeepCopy())
+		controllerutil.AddFinalizer(pr, NotificationPipelineRunFinalizer)
+		Expect(k8sClient.Patch(context.Background(), pr, patch, client.FieldOwner("manager"))).To(Succeed())
+	}
+
+	getCondition := func() *metav1.Condition {
+		ns := &v1alpha1.NotificationService{}
+		Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(notificationService), ns)).To(Succeed())
+		return meta.FindStatusCondition(ns.Status.Conditions, DuplicateControllerCondition)
+	}
+
+	It("should hand off pipelineruns whose markers are held by another controller", func() {
+		pipelineRun := createPipelineRun("held-elsewhere", corev1.ConditionTrue)
+		markByOlderController(pipelineRun)
+		fake := &fakeNotifier{}
+		r := &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Notifier: fake}
+		Expect(reconcilePipelineRun(r, pipelineRun)).To(Succeed())
+
+		Expect(fake.notifications).To(BeEmpty())
+		pr := getPipelineRun(pipelineRun)
+		Expect(pr.Annotations).NotTo(HaveKey(NotificationPipelineRunAnnotation))
+		Expect(ForeignMarkerManagers(pr, time.Time{})).To(Equal([]string{"manager"}))
+		condition := getCondition()
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Message).To(ContainSubstring("PipelineRun held-elsewhere carries notification markers set by manager"))
+
+		handled := createPipelineRun("handled-alone", corev1.ConditionTrue)
+		Expect(reconcilePipelineRun(r, handled)).To(Succeed())
+		Expect(fake.notifications).To(HaveLen(1))
+		Expect(getCondition().Status).To(Equal(metav1.ConditionFalse))
+	})
+
+	It("should adopt the markers set before the controller started", func() {
+		pipelineRun := createPipelineRun("marked-before-upgrade", corev1.ConditionTrue)
+		markByOlderController(pipelineRun)
+		fake := &fakeNotifier{}
+		r := &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Notifier: fake,
+			Started: time.Now().Add(time.Minute)}
+		Expect(reconcilePipelineRun(r, pipelineRun)).To(Succeed())
+
+		Expect(fake.notifications).To(HaveLen(1))
+		Expect(getCondition()).To(BeNil())
+	})
+
+	It("should not consider the markers applied by the controller", func() {
+		pipelineRun := createPipelineRun("marked-by-controller", "")
+		r := &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Notifier: &fakeNotifier{}}
+		Expect(reconcilePipelineRun(r, pipelineRun)).To(Succeed())
+		pr := getPipelineRun(pipelineRun)
+		Expect(pr.Finalizers).To(ContainElement(NotificationPipelineRunFinalizer))
+		Expect(ForeignMarkerManagers(pr, time.Time{})).To(BeEmpty())
+	})
+})
@@ -0,0 +1,186 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// DefaultCompanionCollectionInterval is how often the CompanionCollector looks for companion objects to delete
+const DefaultCompanionCollectionInterval = time.Hour
+
+var companionsCollected = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "notification_service_companions_collected_total",
+	Help: "Number of companion objects of pipelineruns deleted by the garbage collector, by kind",
+}, []string{"kind"})
+
+func init() {
+	metrics.Registry.MustRegister(companionsCollected)
+}
+
+// CompanionCollector deletes the objects the controller creates for pipelineruns, reports and notification
+// states, once they are no longer needed. Companion objects are owned by their pipelinerun and deleted with
+// it by the Kubernetes garbage collector; the collector deletes the orphans created before they had owner
+// references or whose pipelinerun was recreated, and the objects older than their TTL.
+// Notification deliveries are deleted by the NotificationDeliveryReconciler, after their own TTL.
+type CompanionCollector struct {
+	Client client.Client
+	// APIReader lists the companion objects from the API server in pages of PageSize objects, if set.
+	// They are otherwise listed at once with Client.
+	APIReader client.Reader
+	// PageSize is the number of companion objects listed per request with APIReader, all at once if zero
+	PageSize int64
+	Log      logr.Logger
+	// Interval is how often companion objects are collected
+	Interval time.Duration
+	// ReportTTL is how long report ConfigMaps are kept, they are kept with their pipelinerun if zero
+	ReportTTL time.Duration
+	// StateTTL is how long the notification states of handled pipelineruns are kept,
+	// they are kept with their pipelinerun if zero
+	StateTTL time.Duration
+}
+
+// NeedLeaderElection returns true so companion objects are collected by a single replica
+func (c *CompanionCollector) NeedLeaderElection() bool {
+	return true
+}
+
+// Start collects companion objects now and every interval until the context is cancelled
+func (c *CompanionCollector) Start(ctx context.Context) error {
+	interval := c.Interval
+	if interval <= 0 {
+		interval = DefaultCompanionCollectionInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		collected, err := c.RunOnce(ctx)
+		if err != nil {
+			c.Log.Error(err, "Failed to collect companion objects")
+		} else if collected > 0 {
+			c.Log.Info("Collected companion objects", "count", collected)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce deletes the orphaned and expired reports and notification states and returns how many were deleted
+func (c *CompanionCollector) RunOnce(ctx context.Context) (int, error) {
+	now := time.Now()
+	collected := 0
+
+	reader, pageSize := c.APIReader, c.PageSize
+	if reader == nil {
+		reader, pageSize = c.Client, 0
+	}
+
+	configMaps := &corev1.ConfigMapList{}
+	err := ListPages(ctx, reader, configMaps, pageSize, func() error {
+		for i := range configMaps.Items {
+			configMap := &configMaps.Items[i]
+			pipelineRun, err := c.getOwner(ctx, configMap, configMap.Labels[ReportPipelineRunLabel])
+			if err != nil {
+				return err
+			}
+			if pipelineRun != nil && !isExpired(configMap, c.ReportTTL, now) {
+				continue
+			}
+			err = c.Client.Delete(ctx, configMap)
+			if client.IgnoreNotFound(err) != nil {
+				return fmt.Errorf("Failed to delete report ConfigMap %s/%s: %w", configMap.Namespace, configMap.Name, err)
+			}
+			companionsCollected.WithLabelValues("report").Inc()
+			collected++
+		}
+		return nil
+	}, client.HasLabels{ReportPipelineRunLabel})
+	if err != nil {
+		return collected, fmt.Errorf("Failed to collect report ConfigMaps: %w", err)
+	}
+
+	states := &v1alpha1.NotificationStateList{}
+	err = ListPages(ctx, reader, states, pageSize, func() error {
+		for i := range states.Items {
+			state := &states.Items[i]
+			pipelineRun, err := c.getOwner(ctx, state, state.Spec.PipelineRun)
+			if err != nil {
+				return err
+			}
+			// The state of a pipelinerun is read until it is handled
+			if pipelineRun != nil && (!isExpired(state, c.StateTTL, now) ||
+				!IsAnnotationExistInPipelineRun(pipelineRun, NotificationPipelineRunAnnotation, NotificationPipelineRunAnnotationValue) ||
+				IsFinalizerExistInPipelineRun(pipelineRun, NotificationPipelineRunFinalizer)) {
+				continue
+			}
+			err = c.Client.Delete(ctx, state)
+			if client.IgnoreNotFound(err) != nil {
+				return fmt.Errorf("Failed to delete notification state %s/%s: %w", state.Namespace, state.Name, err)
+			}
+			companionsCollected.WithLabelValues("state").Inc()
+			collected++
+		}
+		return nil
+	})
+	if err != nil {
+		return collected, fmt.Errorf("Failed to collect notification states: %w", err)
+	}
+	return collected, nil
+}
+
+// getOwner returns the pipelinerun the companion object was created for, or nil if it no longer exists,
+// including if it was recreated with the same name after the object was created
+func (c *CompanionCollector) getOwner(ctx context.Context, obj client.Object, name string) (*tektonv1.PipelineRun, error) {
+	if name == "" {
+		return nil, nil
+	}
+	pipelineRun := &tektonv1.PipelineRun{}
+	err := c.Client.Get(ctx, types.NamespacedName{Namespace: obj.GetNamespace(), Name: name}, pipelineRun)
+	if k8serrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get pipelinerun %s/%s: %w", obj.GetNamespace(), name, err)
+	}
+	for _, owner := range obj.GetOwnerReferences() {
+		if owner.Kind == "PipelineRun" && owner.UID != pipelineRun.UID {
+			return nil, nil
+		}
+	}
+	return pipelineRun, nil
+}
+
+// isExpired returns a boolean indicating whether the object was created more than ttl ago, objects never expire if ttl is zero
+func isExpired(obj metav1.Object, ttl time.Duration, now time.Time) bool {
+	return ttl > 0 && now.Sub(obj.GetCreationTimestamp().Time) > ttl
+}
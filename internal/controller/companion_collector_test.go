@@ -0,0 +1,107 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Companion collector", func() {
+	exists := func(obj client.Object) bool {
+		return k8sClient.Get(context.Background(), client.ObjectKeyFromObject(obj), obj) == nil
+	}
+
+	createState := func(name string, owner *tektonv1.PipelineRun) *v1alpha1.NotificationState {
+		state := &v1alpha1.NotificationState{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       v1alpha1.NotificationStateSpec{PipelineRun: name},
+		}
+		if owner != nil {
+			state.OwnerReferences = pipelineRunOwnerReferences(owner)
+		}
+		Expect(k8sClient.Create(context.Background(), state)).To(Succeed())
+		DeferCleanup(func() { _ = k8sClient.Delete(context.Background(), state) })
+		return state
+	}
+
+	It("should own reports by their pipelinerun and collect orphaned and expired reports", func() {
+		ctx := context.Background()
+		pipelineRun := createPipelineRun("collect-report", corev1.ConditionFalse)
+		store := &ConfigMapReportStore{Client: k8sClient}
+		_, err := store.StoreReport(ctx, "default", pipelineRun.Name, "collect-report.md", "text/markdown", []byte("# report"))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = store.StoreReport(ctx, "default", "collect-orphan", "collect-orphan.md", "text/markdown", []byte("# report"))
+		Expect(err).NotTo(HaveOccurred())
+		report := &corev1.ConfigMap{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: "collect-report-report"}, report)).To(Succeed())
+		Expect(report.OwnerReferences).To(HaveLen(1))
+		Expect(report.OwnerReferences[0].UID).To(Equal(pipelineRun.UID))
+		orphan := &corev1.ConfigMap{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: "collect-orphan-report"}, orphan)).To(Succeed())
+		Expect(orphan.OwnerReferences).To(BeEmpty())
+
+		collector := &CompanionCollector{Client: k8sClient, Log: ctrl.Log.WithName("collector")}
+		_, err = collector.RunOnce(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exists(report)).To(BeTrue())
+		Expect(exists(orphan)).To(BeFalse())
+
+		collector.ReportTTL = time.Nanosecond
+		_, err = collector.RunOnce(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exists(report)).To(BeFalse())
+	})
+
+	It("should only collect expired notification states of handled pipelineruns", func() {
+		ctx := context.Background()
+		r := &NotificationServiceReconciler{Client: k8sClient}
+		pending := createPipelineRun("collect-state-pending", corev1.ConditionTrue)
+		pendingState := createState(pending.Name, pending)
+		handled := createPipelineRun("collect-state-handled", corev1.ConditionTrue)
+		Expect(AddAnnotationToPipelineRun(ctx, handled, r, NotificationPipelineRunAnnotation, NotificationPipelineRunAnnotationValue)).To(Succeed())
+		handledState := createState(handled.Name, handled)
+		recreated := createPipelineRun("collect-state-recreated", "")
+		previous := recreated.DeepCopy()
+		previous.UID = "0b7c6a4e-5e3e-4c47-9d2b-3c8c1f0f1e11"
+		recreatedState := createState(recreated.Name, previous)
+
+		collector := &CompanionCollector{Client: k8sClient, APIReader: k8sClient, PageSize: 1, Log: ctrl.Log.WithName("collector")}
+		_, err := collector.RunOnce(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exists(pendingState)).To(BeTrue())
+		Expect(exists(handledState)).To(BeTrue())
+		Expect(exists(recreatedState)).To(BeFalse())
+
+		collector.StateTTL = time.Nanosecond
+		_, err = collector.RunOnce(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exists(pendingState)).To(BeTrue())
+		Expect(exists(handledState)).To(BeFalse())
+	})
+})
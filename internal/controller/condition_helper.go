@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+)
+
+// ValidateResultConditions checks that the operators of the conditions are known and have the values they compare to
+// Return error describing the first invalid condition
+func ValidateResultConditions(conditions []v1alpha1.ResultCondition) error {
+	for _, condition := range conditions {
+		switch condition.Operator {
+		case v1alpha1.ResultOperatorExists, v1alpha1.ResultOperatorDoesNotExist,
+			v1alpha1.ResultOperatorEquals, v1alpha1.ResultOperatorNotEquals, v1alpha1.ResultOperatorContains:
+		case v1alpha1.ResultOperatorIn, v1alpha1.ResultOperatorNotIn:
+			if len(condition.Values) == 0 {
+				return fmt.Errorf("Condition %s on result %s requires values", condition.Operator, condition.Result)
+			}
+		case v1alpha1.ResultOperatorGreaterThan, v1alpha1.ResultOperatorLessThan:
+			if _, err := strconv.ParseFloat(condition.Value, 64); err != nil {
+				return fmt.Errorf("Condition %s on result %s requires a number, got %q", condition.Operator, condition.Result, condition.Value)
+			}
+		default:
+			return fmt.Errorf("Unknown operator %s of condition on result %s", condition.Operator, condition.Result)
+		}
+	}
+	return nil
+}
+
+// MatchResultConditions returns a boolean indicating whether the results of the notification meet all the conditions
+func MatchResultConditions(conditions []v1alpha1.ResultCondition, notification *notifier.Notification) bool {
+	for _, condition := range conditions {
+		if !matchResultCondition(condition, notification) {
+			return false
+		}
+	}
+	return true
+}
+
+// FilterResultDestinations removes the destinations whose result conditions the notification does not meet
+func FilterResultDestinations(destinations []DestinationNotifier, notification *notifier.Notification) []DestinationNotifier {
+	var filtered []DestinationNotifier
+	for _, destination := range destinations {
+		if !MatchResultConditions(destination.ResultConditions, notification) {
+			continue
+		}
+		filtered = append(filtered, destination)
+	}
+	return filtered
+}
+
+func matchResultCondition(condition v1alpha1.ResultCondition, notification *notifier.Notification) bool {
+	index := slices.IndexFunc(notification.Results, func(result notifier.Result) bool { return result.Name == condition.Result })
+	exists := index >= 0
+	var value string
+	var items []string
+	if exists {
+		result := notification.Results[index]
+		switch {
+		case result.Type == notifier.ResultTypeObject && condition.Key != "":
+			value, exists = result.Object[condition.Key]
+		case result.Type == notifier.ResultTypeArray:
+			items = result.Array
+			value = result.String()
+		default:
+			value = result.String()
+		}
+	}
+
+	switch condition.Operator {
+	case v1alpha1.ResultOperatorExists:
+		return exists
+	case v1alpha1.ResultOperatorDoesNotExist:
+		return !exists
+	case v1alpha1.ResultOperatorNotEquals:
+		return !exists || value != condition.Value
+	case v1alpha1.ResultOperatorNotIn:
+		return !exists || !slices.Contains(condition.Values, value)
+	}
+	if !exists {
+		return false
+	}
+	switch condition.Operator {
+	case v1alpha1.ResultOperatorEquals:
+		return value == condition.Value
+	case v1alpha1.ResultOperatorIn:
+		return slices.Contains(condition.Values, value)
+	case v1alpha1.ResultOperatorContains:
+		if items != nil {
+			return slices.Contains(items, condition.Value)
+		}
+		return strings.Contains(value, condition.Value)
+	case v1alpha1.ResultOperatorGreaterThan, v1alpha1.ResultOperatorLessThan:
+		threshold, err := strconv.ParseFloat(condition.Value, 64)
+		if err != nil {
+			return false
+		}
+		number := float64(len(items))
+		if items == nil {
+			number, err = strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				return false
+			}
+		}
+		if condition.Operator == v1alpha1.ResultOperatorGreaterThan {
+			return number > threshold
+		}
+		return number < threshold
+	}
+	return false
+}
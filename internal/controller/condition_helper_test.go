@@ -0,0 +1,84 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+)
+
+var _ = Describe("Result conditions", func() {
+	notification := &notifier.Notification{Results: []notifier.Result{
+		{Name: "VULNERABILITIES_HIGH", Value: "3\n"},
+		{Name: "IMAGE_URL", Value: "quay.io/tenant/app"},
+		{Name: "TAGS", Type: notifier.ResultTypeArray, Array: []string{"v1", "latest"}},
+		{Name: "SCAN", Type: notifier.ResultTypeObject, Object: map[string]string{"critical": "0", "high": "2"}},
+	}}
+
+	DescribeTable("should evaluate conditions over typed results",
+		func(condition v1alpha1.ResultCondition, matches bool) {
+			Expect(ValidateResultConditions([]v1alpha1.ResultCondition{condition})).To(Succeed())
+			Expect(MatchResultConditions([]v1alpha1.ResultCondition{condition}, notification)).To(Equal(matches))
+		},
+		Entry("numbers greater than", v1alpha1.ResultCondition{Result: "VULNERABILITIES_HIGH", Operator: "GreaterThan", Value: "0"}, true),
+		Entry("numbers less than", v1alpha1.ResultCondition{Result: "VULNERABILITIES_HIGH", Operator: "LessThan", Value: "3"}, false),
+		Entry("strings that are not numbers", v1alpha1.ResultCondition{Result: "IMAGE_URL", Operator: "GreaterThan", Value: "0"}, false),
+		Entry("missing results", v1alpha1.ResultCondition{Result: "MISSING", Operator: "GreaterThan", Value: "0"}, false),
+		Entry("existing results", v1alpha1.ResultCondition{Result: "IMAGE_URL", Operator: "Exists"}, true),
+		Entry("missing results that must not exist", v1alpha1.ResultCondition{Result: "MISSING", Operator: "DoesNotExist"}, true),
+		Entry("equal strings", v1alpha1.ResultCondition{Result: "IMAGE_URL", Operator: "Equals", Value: "quay.io/tenant/app"}, true),
+		Entry("missing results not equal", v1alpha1.ResultCondition{Result: "MISSING", Operator: "NotEquals", Value: "x"}, true),
+		Entry("strings in values", v1alpha1.ResultCondition{Result: "IMAGE_URL", Operator: "In", Values: []string{"quay.io/tenant/app"}}, true),
+		Entry("strings not in values", v1alpha1.ResultCondition{Result: "IMAGE_URL", Operator: "NotIn", Values: []string{"quay.io/tenant/app"}}, false),
+		Entry("strings containing the value", v1alpha1.ResultCondition{Result: "IMAGE_URL", Operator: "Contains", Value: "tenant"}, true),
+		Entry("arrays having the item", v1alpha1.ResultCondition{Result: "TAGS", Operator: "Contains", Value: "latest"}, true),
+		Entry("arrays not having the item", v1alpha1.ResultCondition{Result: "TAGS", Operator: "Contains", Value: "lat"}, false),
+		Entry("the number of items of arrays", v1alpha1.ResultCondition{Result: "TAGS", Operator: "GreaterThan", Value: "1"}, true),
+		Entry("keys of objects", v1alpha1.ResultCondition{Result: "SCAN", Key: "high", Operator: "GreaterThan", Value: "1"}, true),
+		Entry("missing keys of objects", v1alpha1.ResultCondition{Result: "SCAN", Key: "low", Operator: "Exists"}, false),
+	)
+
+	It("should require all conditions to hold", func() {
+		Expect(MatchResultConditions(nil, notification)).To(BeTrue())
+		Expect(MatchResultConditions([]v1alpha1.ResultCondition{
+			{Result: "SCAN", Key: "critical", Operator: "Equals", Value: "0"},
+			{Result: "VULNERABILITIES_HIGH", Operator: "GreaterThan", Value: "5"},
+		}, notification)).To(BeFalse())
+	})
+
+	It("should reject conditions without the values they compare to", func() {
+		Expect(ValidateResultConditions([]v1alpha1.ResultCondition{{Result: "SCAN", Operator: "In"}})).To(
+			MatchError("Condition In on result SCAN requires values"))
+		Expect(ValidateResultConditions([]v1alpha1.ResultCondition{{Result: "SCAN", Operator: "GreaterThan", Value: "many"}})).To(
+			MatchError(`Condition GreaterThan on result SCAN requires a number, got "many"`))
+		Expect(ValidateResultConditions([]v1alpha1.ResultCondition{{Result: "SCAN", Operator: "Matches"}})).To(HaveOccurred())
+	})
+
+	It("should only notify destinations whose conditions hold", func() {
+		destinations := []DestinationNotifier{
+			{Name: "all"},
+			{Name: "security", ResultConditions: []v1alpha1.ResultCondition{
+				{Result: "VULNERABILITIES_HIGH", Operator: "GreaterThan", Value: "0"},
+			}},
+		}
+		Expect(FilterResultDestinations(destinations, notification)).To(HaveLen(2))
+		Expect(FilterResultDestinations(destinations, &notifier.Notification{})).To(Equal([]DestinationNotifier{{Name: "all"}}))
+	})
+})
@@ -0,0 +1,276 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"github.com/konflux-ci/notification-service/api/v1beta1"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apiyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// ExportedConfig is the effective notification configuration of the pipelineruns of a namespace
+type ExportedConfig struct {
+	Namespace    string                `json:"namespace"`
+	Destinations []ExportedDestination `json:"destinations"`
+}
+
+// ExportedDestination is a destination notified about the pipelineruns of a namespace
+type ExportedDestination struct {
+	// Name identifies the destination in delivery records and metrics
+	Name string `json:"name"`
+	// Source is the object declaring the destination, a NotificationService, a ClusterNotificationService
+	// or the controller configuration
+	Source      string               `json:"source"`
+	BestEffort  bool                 `json:"bestEffort,omitempty"`
+	Paused      bool                 `json:"paused,omitempty"`
+	Destination v1alpha1.Destination `json:"destination"`
+}
+
+// ExportConfig returns the destinations notified about the pipelineruns of the namespace, as merged by
+// GetDestinationNotifiers: the destinations of the NotificationServices applying to the namespace with their
+// default template, of the ClusterNotificationServices selecting it except the overridden ones, and the
+// default destinations of the controller configuration. Secrets are only referenced, never read.
+// Return error if failed to list the NotificationServices or ClusterNotificationServices
+func ExportConfig(ctx context.Context, r *NotificationServiceReconciler, namespace string) (*ExportedConfig, error) {
+	notificationServices, err := GetNamespaceNotificationServices(ctx, r, namespace)
+	if err != nil {
+		return nil, err
+	}
+	clusterNotificationServices, err := GetClusterNotificationServices(ctx, r.Client, r.Log, namespace)
+	if err != nil {
+		return nil, err
+	}
+	exported := &ExportedConfig{Namespace: namespace, Destinations: []ExportedDestination{}}
+	declared := map[string]bool{}
+	for _, notificationService := range notificationServices {
+		for _, destination := range notificationService.Spec.Destinations {
+			declared[destination.Name] = true
+			exported.Destinations = append(exported.Destinations, ExportedDestination{
+				Name:        notificationService.Namespace + "/" + notificationService.Name + "/" + destination.Name,
+				Source:      "NotificationService " + notificationService.Namespace + "/" + notificationService.Name,
+				BestEffort:  IsBestEffort(&notificationService, r.BestEffort),
+				Paused:      notificationService.Spec.Paused,
+				Destination: InheritDefaultTemplate(destination, &notificationService.Spec),
+			})
+		}
+	}
+	for _, clusterNotificationService := range clusterNotificationServices {
+		for _, destination := range clusterNotificationService.Spec.Destinations {
+			if clusterNotificationService.Spec.AllowOverride && declared[destination.Name] {
+				continue
+			}
+			exported.Destinations = append(exported.Destinations, ExportedDestination{
+				Name:        ClusterDestinationPrefix + "/" + clusterNotificationService.Name + "/" + destination.Name,
+				Source:      "ClusterNotificationService " + clusterNotificationService.Name,
+				BestEffort:  isBestEffort(clusterNotificationService.Spec.BestEffort, r.BestEffort),
+				Destination: destination,
+			})
+		}
+	}
+	for _, destination := range r.ConfigFile.Get().DefaultDestinations {
+		exported.Destinations = append(exported.Destinations, ExportedDestination{
+			Name:        DefaultDestinationName + "/" + destination.Name,
+			Source:      "configuration",
+			BestEffort:  r.BestEffort,
+			Destination: destination,
+		})
+	}
+	return exported, nil
+}
+
+// ValidateConfigDirectory checks the YAML and JSON files of the directory and its subdirectories without
+// connecting to a cluster. NotificationServices, NotificationTemplates and ClusterNotificationServices must
+// only set known fields, have destinations with unique names and a single backend, and valid templates, CEL
+// expressions and schedules. Other documents, e.g. kustomizations, are ignored.
+// Return error joining the problems found, each prefixed with its file and document
+func ValidateConfigDirectory(dir string) error {
+	var errs []error
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !slices.Contains([]string{".yaml", ".yml", ".json"}, strings.ToLower(filepath.Ext(path))) {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		errs = append(errs, validateConfigFile(path, data)...)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to read directory %s: %w", dir, err)
+	}
+	return errors.Join(errs...)
+}
+
+// validateConfigFile checks the documents of a YAML or JSON stream
+func validateConfigFile(path string, data []byte) []error {
+	var errs []error
+	reader := apiyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
+	for document := 1; ; document++ {
+		raw, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return errs
+		}
+		if err != nil {
+			return append(errs, fmt.Errorf("%s: Failed to read document %d: %w", path, document, err))
+		}
+		if err = validateConfigDocument(raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s: document %d: %w", path, document, err))
+		}
+	}
+}
+
+// validateConfigDocument checks a document, see ValidateConfigDirectory
+func validateConfigDocument(raw []byte) error {
+	typeMeta := metav1.TypeMeta{}
+	if err := yaml.Unmarshal(raw, &typeMeta); err != nil {
+		return fmt.Errorf("Failed to parse: %w", err)
+	}
+	switch {
+	case typeMeta.APIVersion == v1beta1.GroupVersion.String() && typeMeta.Kind == "NotificationService":
+		notificationService := &v1beta1.NotificationService{}
+		if err := yaml.UnmarshalStrict(raw, notificationService); err != nil {
+			return fmt.Errorf("Invalid NotificationService: %w", err)
+		}
+		hub := &v1alpha1.NotificationService{}
+		if err := notificationService.ConvertTo(hub); err != nil {
+			return fmt.Errorf("Failed to convert NotificationService %s: %w", notificationService.Name, err)
+		}
+		return validateNotificationService(hub)
+	case typeMeta.APIVersion != v1alpha1.GroupVersion.String():
+		return nil
+	}
+	switch typeMeta.Kind {
+	case "NotificationService":
+		notificationService := &v1alpha1.NotificationService{}
+		if err := yaml.UnmarshalStrict(raw, notificationService); err != nil {
+			return fmt.Errorf("Invalid NotificationService: %w", err)
+		}
+		return validateNotificationService(notificationService)
+	case "ClusterNotificationService":
+		clusterNotificationService := &v1alpha1.ClusterNotificationService{}
+		if err := yaml.UnmarshalStrict(raw, clusterNotificationService); err != nil {
+			return fmt.Errorf("Invalid ClusterNotificationService: %w", err)
+		}
+		spec := clusterNotificationService.Spec
+		errs := validateDestinations(spec.Destinations)
+		if spec.SecretNamespace == "" {
+			errs = append(errs, fmt.Errorf("The secret namespace is not set"))
+		}
+		if spec.NamespaceSelector != nil {
+			if _, err := metav1.LabelSelectorAsSelector(spec.NamespaceSelector); err != nil {
+				errs = append(errs, fmt.Errorf("Invalid namespace selector: %w", err))
+			}
+		}
+		if err := errors.Join(errs...); err != nil {
+			return fmt.Errorf("Invalid ClusterNotificationService %s: %w", clusterNotificationService.Name, err)
+		}
+	case "NotificationTemplate":
+		notificationTemplate := &v1alpha1.NotificationTemplate{}
+		if err := yaml.UnmarshalStrict(raw, notificationTemplate); err != nil {
+			return fmt.Errorf("Invalid NotificationTemplate: %w", err)
+		}
+		if notificationTemplate.Spec.Template == "" {
+			return fmt.Errorf("NotificationTemplate %s has no template", notificationTemplate.Name)
+		}
+		if _, err := notifier.NewTemplate(notificationTemplate.Name, notificationTemplate.Spec.Template); err != nil {
+			return fmt.Errorf("Invalid template of NotificationTemplate %s: %w", notificationTemplate.Name, err)
+		}
+	}
+	return nil
+}
+
+// validateNotificationService checks the destinations, default template, watch and summary of the NotificationService
+func validateNotificationService(notificationService *v1alpha1.NotificationService) error {
+	spec := notificationService.Spec
+	errs := validateDestinations(spec.Destinations)
+	if spec.DefaultTemplate != "" {
+		if _, err := notifier.NewTemplate("default", spec.DefaultTemplate); err != nil {
+			errs = append(errs, fmt.Errorf("Invalid default template: %w", err))
+		}
+	}
+	if spec.Watch != nil {
+		if _, err := compileCELExpression(spec.Watch.Condition); err != nil {
+			errs = append(errs, fmt.Errorf("Invalid watch condition: %w", err))
+		}
+		for _, result := range spec.Watch.Results {
+			if _, err := compileCELExpression(result.Expression); err != nil {
+				errs = append(errs, fmt.Errorf("Invalid expression of watch result %s: %w", result.Name, err))
+			}
+		}
+	}
+	if spec.Summary != nil {
+		if _, err := ParseSummarySchedule(spec.Summary); err != nil {
+			errs = append(errs, fmt.Errorf("Invalid summary schedule: %w", err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("Invalid NotificationService %s: %w", notificationService.Name, err)
+	}
+	return nil
+}
+
+// validateDestinations checks that the destinations have unique names, a single backend and valid inline templates
+func validateDestinations(destinations []v1alpha1.Destination) []error {
+	var errs []error
+	if len(destinations) == 0 {
+		errs = append(errs, fmt.Errorf("No destinations"))
+	}
+	names := map[string]bool{}
+	for _, destination := range destinations {
+		if destination.Name == "" || names[destination.Name] {
+			errs = append(errs, fmt.Errorf("Invalid destination name %q, names must be set and unique", destination.Name))
+		}
+		names[destination.Name] = true
+		backends := 0
+		for _, set := range []bool{destination.Webhook != nil, destination.Slack != nil, destination.Matrix != nil,
+			destination.IRC != nil, destination.XMPP != nil, destination.WebPush != nil, destination.FCM != nil,
+			destination.Alertmanager != nil} {
+			if set {
+				backends++
+			}
+		}
+		if backends != 1 {
+			errs = append(errs, fmt.Errorf("Destination %s must set exactly one backend", destination.Name))
+			continue
+		}
+		if template := destinationTemplate(&destination); *template != "" {
+			if _, err := notifier.NewTemplate(destination.Name, *template); err != nil {
+				errs = append(errs, fmt.Errorf("Invalid template of destination %s: %w", destination.Name, err))
+			}
+		}
+	}
+	return errs
+}
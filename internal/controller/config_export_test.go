@@ -0,0 +1,162 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Config export", func() {
+	hook := func(name string) v1alpha1.Destination {
+		return v1alpha1.Destination{Name: name, Webhook: &v1alpha1.WebhookDestination{URL: "http://localhost"}}
+	}
+
+	It("should export the destinations of the namespace merged with the cluster defaults", func() {
+		ctx := context.Background()
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "export-team", Labels: map[string]string{"export": "true"}}}
+		Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+		clusterNotificationService := &v1alpha1.ClusterNotificationService{
+			ObjectMeta: metav1.ObjectMeta{Name: "export-platform"},
+			Spec: v1alpha1.ClusterNotificationServiceSpec{
+				Destinations:      []v1alpha1.Destination{hook("audit"), hook("chat")},
+				SecretNamespace:   "default",
+				NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"export": "true"}},
+				AllowOverride:     true,
+			},
+		}
+		Expect(k8sClient.Create(ctx, clusterNotificationService)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, ctx, clusterNotificationService)
+		notificationService := &v1alpha1.NotificationService{
+			ObjectMeta: metav1.ObjectMeta{Name: "tenant", Namespace: "export-team"},
+			Spec: v1alpha1.NotificationServiceSpec{
+				Destinations:    []v1alpha1.Destination{hook("chat")},
+				DefaultTemplate: "{{ .PipelineRun }}",
+				Paused:          true,
+			},
+		}
+		Expect(k8sClient.Create(ctx, notificationService)).To(Succeed())
+		path := filepath.Join(GinkgoT().TempDir(), "config.yaml")
+		Expect(os.WriteFile(path, []byte(`
+secretNamespace: default
+defaultDestinations:
+- name: archive
+  webhook:
+    url: http://localhost
+`), 0o600)).To(Succeed())
+		configFile, err := NewConfigFile(path)
+		Expect(err).NotTo(HaveOccurred())
+
+		r := &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), ConfigFile: configFile}
+		exported, err := ExportConfig(ctx, r, "export-team")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exported.Namespace).To(Equal("export-team"))
+		Expect(exported.Destinations).To(HaveLen(3))
+		Expect(exported.Destinations[0].Name).To(Equal("export-team/tenant/chat"))
+		Expect(exported.Destinations[0].Source).To(Equal("NotificationService export-team/tenant"))
+		Expect(exported.Destinations[0].Paused).To(BeTrue())
+		Expect(exported.Destinations[0].Destination.Webhook.Template).To(Equal("{{ .PipelineRun }}"))
+		Expect(exported.Destinations[1].Name).To(Equal("cluster/export-platform/audit"))
+		Expect(exported.Destinations[1].Source).To(Equal("ClusterNotificationService export-platform"))
+		Expect(exported.Destinations[2].Name).To(Equal("default/archive"))
+		Expect(exported.Destinations[2].Source).To(Equal("configuration"))
+
+		Expect(k8sClient.Delete(ctx, notificationService)).To(Succeed())
+		Eventually(func() error {
+			return k8sClient.Get(ctx, client.ObjectKeyFromObject(notificationService), &v1alpha1.NotificationService{})
+		}).ShouldNot(Succeed())
+		exported, err = ExportConfig(ctx, r, "export-team")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exported.Destinations).To(ConsistOf(
+			HaveField("Name", "cluster/export-platform/audit"),
+			HaveField("Name", "cluster/export-platform/chat"),
+			HaveField("Name", "default/archive"),
+		))
+	})
+
+	It("should validate a directory of configuration offline", func() {
+		dir := GinkgoT().TempDir()
+		Expect(os.MkdirAll(filepath.Join(dir, "team"), 0o700)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte("resources:\n- team\n"), 0o600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "team", "services.yaml"), []byte(`
+apiVersion: konflux.ci/v1alpha1
+kind: NotificationTemplate
+metadata:
+  name: build
+spec:
+  template: "{{ .PipelineRun }}"
+---
+apiVersion: konflux.ci/v1alpha1
+kind: NotificationService
+metadata:
+  name: valid
+spec:
+  destinations:
+  - name: chat
+    webhook:
+      url: http://localhost
+      template: "{{ .PipelineRun }}"
+`), 0o600)).To(Succeed())
+		Expect(ValidateConfigDirectory(dir)).To(Succeed())
+
+		Expect(os.WriteFile(filepath.Join(dir, "team", "invalid.yaml"), []byte(`
+apiVersion: konflux.ci/v1alpha1
+kind: NotificationService
+metadata:
+  name: invalid
+spec:
+  destinations:
+  - name: chat
+    webhook:
+      url: http://localhost
+      template: "{{ .PipelineRun"
+  - name: chat
+    slack:
+      channel: builds
+      tokenSecretRef:
+        name: slack
+        key: token
+  summary:
+    schedule: every monday
+---
+apiVersion: konflux.ci/v1alpha1
+kind: NotificationTemplate
+metadata:
+  name: unknown
+spec:
+  template: "{{ .PipelineRun }}"
+  colour: red
+`), 0o600)).To(Succeed())
+		err := ValidateConfigDirectory(dir)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("invalid.yaml: document 1: Invalid NotificationService invalid"))
+		Expect(err.Error()).To(ContainSubstring("Invalid template of destination chat"))
+		Expect(err.Error()).To(ContainSubstring(`Invalid destination name "chat"`))
+		Expect(err.Error()).To(ContainSubstring("Invalid summary schedule"))
+		Expect(err.Error()).To(ContainSubstring(`invalid.yaml: document 2: Invalid NotificationTemplate`))
+		Expect(err.Error()).To(ContainSubstring(`unknown field "colour"`))
+	})
+})
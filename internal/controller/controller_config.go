@@ -0,0 +1,411 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultConfigReloadInterval is how often the configuration file is checked for changes
+const DefaultConfigReloadInterval = 10 * time.Second
+
+// DefaultRetryBaseDelay and DefaultRetryMaxDelay bound the backoff of failed notifications,
+// matching the default backoff of controller-runtime
+const (
+	DefaultRetryBaseDelay = 5 * time.Millisecond
+	DefaultRetryMaxDelay  = 1000 * time.Second
+)
+
+// DefaultDestinationBackoffBaseDelay and DefaultDestinationBackoffMaxDelay bound the backoff of destinations
+// after consecutive network failures
+const (
+	DefaultDestinationBackoffBaseDelay = 10 * time.Second
+	DefaultDestinationBackoffMaxDelay  = 10 * time.Minute
+)
+
+// DefaultThrottleRetryAfter is how long pipelineruns whose deliveries were throttled wait before they are retried
+const DefaultThrottleRetryAfter = 5 * time.Second
+
+// ControllerConfig is the content of the configuration file of the controller,
+// usually mounted from a ConfigMap
+type ControllerConfig struct {
+	// Concurrency is the number of pipelineruns reconciled in parallel.
+	// It is only read on startup.
+	Concurrency int `json:"concurrency,omitempty"`
+	// PipelineRunSelector is a label selector restricting the pipelineruns that are notified about
+	PipelineRunSelector string `json:"pipelineRunSelector,omitempty"`
+	// DefaultDestinations are notified about every pipelinerun, in addition to the NotificationServices
+	DefaultDestinations []v1alpha1.Destination `json:"defaultDestinations,omitempty"`
+	// SecretNamespace is the namespace of the secrets referenced by the default destinations
+	SecretNamespace string `json:"secretNamespace,omitempty"`
+	// Retry is the backoff of pipelineruns whose notifications failed
+	Retry RetryPolicy `json:"retry,omitempty"`
+	// Throttling limits the deliveries in flight
+	Throttling ThrottlingPolicy `json:"throttling,omitempty"`
+	// DeadLetter parks the notifications that keep failing to be delivered
+	DeadLetter DeadLetterPolicy `json:"deadLetter,omitempty"`
+	// DestinationBackoff is the backoff of destinations after consecutive network failures, shared by all pipelineruns
+	DestinationBackoff RetryPolicy `json:"destinationBackoff,omitempty"`
+	// Enrichment are the hooks adding context to notifications before they are rendered, called in order
+	Enrichment []EnrichmentHook `json:"enrichment,omitempty"`
+	// Transforms are the WebAssembly modules transforming or filtering notifications before they are delivered, run in order
+	Transforms []TransformModule `json:"transforms,omitempty"`
+	// Bundles are the OCI artifacts of NotificationTemplates and NotificationServices applied to the cluster, see BundleSyncer
+	Bundles []ConfigBundle `json:"bundles,omitempty"`
+	// LogLevels replace the log levels of the controller when set, keyed by logger name, see LogLevels
+	LogLevels map[string]string `json:"logLevels,omitempty"`
+
+	selector labels.Selector
+}
+
+// RetryPolicy is an exponential backoff, doubling from BaseDelay up to MaxDelay
+type RetryPolicy struct {
+	BaseDelay *metav1.Duration `json:"baseDelay,omitempty"`
+	MaxDelay  *metav1.Duration `json:"maxDelay,omitempty"`
+}
+
+// ThrottlingPolicy limits the concurrent deliveries, zero meaning no limit. Throttled deliveries are
+// retried after RetryAfter without counting as failures.
+type ThrottlingPolicy struct {
+	// MaxInFlightPerNamespace limits the concurrent deliveries about the pipelineruns of a namespace
+	MaxInFlightPerNamespace int `json:"maxInFlightPerNamespace,omitempty"`
+	// MaxInFlightPerDestination limits the concurrent deliveries to a destination
+	MaxInFlightPerDestination int `json:"maxInFlightPerDestination,omitempty"`
+	// RetryAfter is how long pipelineruns whose deliveries were throttled wait before they are retried
+	RetryAfter *metav1.Duration `json:"retryAfter,omitempty"`
+}
+
+// DeadLetterPolicy parks the notification about the end of a pipelinerun in the dead-letter store once it failed
+// to be delivered to a destination MaxAttempts times, so the pipelinerun is released. Zero retries forever.
+type DeadLetterPolicy struct {
+	// MaxAttempts is the number of failed attempts after which the notification to a destination is parked
+	MaxAttempts int `json:"maxAttempts,omitempty"`
+}
+
+// EnrichmentHook is an HTTP endpoint notifications are posted to before they are rendered, which responds
+// with a JSON object of values to add to them, see notifier.WebhookEnricher
+type EnrichmentHook struct {
+	// Name identifies the hook in logs and metrics
+	Name string `json:"name"`
+	// URL is the endpoint of the hook
+	URL string `json:"url"`
+	// Timeout is the deadline of a request to the hook, notifier.DefaultEnrichmentTimeout by default
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// TransformModule is a WebAssembly module transforming or filtering notifications, see package transform.
+// It is loaded from a ConfigMap or from an OCI artifact.
+type TransformModule struct {
+	// Name identifies the transform in logs and metrics
+	Name string `json:"name"`
+	// ConfigMap holds the module
+	ConfigMap *ModuleConfigMap `json:"configMap,omitempty"`
+	// Image is the reference of the OCI artifact holding the module. It is pulled once, so the reference
+	// should include the digest of the artifact.
+	Image string `json:"image,omitempty"`
+	// Timeout is the deadline of a run of the module, transform.DefaultTimeout by default
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// ModuleConfigMap is the key of a ConfigMap holding a WebAssembly module in its binary data
+type ModuleConfigMap struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Key       string `json:"key"`
+}
+
+// ConfigBundle is an OCI artifact holding NotificationTemplates, NotificationServices and ClusterNotificationServices,
+// distributing notification configuration from a central registry
+type ConfigBundle struct {
+	// Name identifies the bundle in logs and metrics and labels the objects it holds
+	Name string `json:"name"`
+	// Image is the reference of the OCI artifact. A reference with a digest pins the bundle,
+	// a tag is resolved again on every refresh.
+	Image string `json:"image"`
+	// Namespace is the namespace of the namespaced objects of the bundle that do not set their own
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// ParseControllerConfig parses and validates the content of a configuration file
+func ParseControllerConfig(data []byte) (*ControllerConfig, error) {
+	config := &ControllerConfig{}
+	err := yaml.UnmarshalStrict(data, config)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse controller configuration: %w", err)
+	}
+	if config.Concurrency < 0 {
+		return nil, fmt.Errorf("Invalid concurrency %d", config.Concurrency)
+	}
+	config.selector, err = labels.Parse(config.PipelineRunSelector)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid pipelinerun selector %s: %w", config.PipelineRunSelector, err)
+	}
+	if len(config.DefaultDestinations) > 0 && config.SecretNamespace == "" {
+		return nil, fmt.Errorf("The secret namespace of the default destinations is not set")
+	}
+	if config.Throttling.MaxInFlightPerNamespace < 0 || config.Throttling.MaxInFlightPerDestination < 0 {
+		return nil, fmt.Errorf("Invalid throttling limits %d and %d",
+			config.Throttling.MaxInFlightPerNamespace, config.Throttling.MaxInFlightPerDestination)
+	}
+	if config.DeadLetter.MaxAttempts < 0 {
+		return nil, fmt.Errorf("Invalid dead letter max attempts %d", config.DeadLetter.MaxAttempts)
+	}
+	hooks := map[string]bool{}
+	for _, hook := range config.Enrichment {
+		if hook.Name == "" || hooks[hook.Name] {
+			return nil, fmt.Errorf("Invalid enrichment hook name %q, names must be set and unique", hook.Name)
+		}
+		hooks[hook.Name] = true
+		parsed, err := url.Parse(hook.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return nil, fmt.Errorf("The URL %s of enrichment hook %s must be an absolute http or https URL", hook.URL, hook.Name)
+		}
+	}
+	transforms := map[string]bool{}
+	for _, module := range config.Transforms {
+		if module.Name == "" || transforms[module.Name] {
+			return nil, fmt.Errorf("Invalid transform name %q, names must be set and unique", module.Name)
+		}
+		transforms[module.Name] = true
+		if (module.ConfigMap == nil) == (module.Image == "") {
+			return nil, fmt.Errorf("Transform %s must be loaded from either a ConfigMap or an image", module.Name)
+		}
+		if module.ConfigMap != nil && (module.ConfigMap.Namespace == "" || module.ConfigMap.Name == "" || module.ConfigMap.Key == "") {
+			return nil, fmt.Errorf("The ConfigMap of transform %s must set its namespace, name and key", module.Name)
+		}
+		if module.Image != "" {
+			if _, err := name.ParseReference(module.Image); err != nil {
+				return nil, fmt.Errorf("Invalid image of transform %s: %w", module.Name, err)
+			}
+		}
+	}
+	bundles := map[string]bool{}
+	for _, bundle := range config.Bundles {
+		if errs := validation.IsValidLabelValue(bundle.Name); bundle.Name == "" || len(errs) > 0 || bundles[bundle.Name] {
+			return nil, fmt.Errorf("Invalid bundle name %q, names must be unique label values", bundle.Name)
+		}
+		bundles[bundle.Name] = true
+		if _, err := name.ParseReference(bundle.Image); err != nil {
+			return nil, fmt.Errorf("Invalid image of bundle %s: %w", bundle.Name, err)
+		}
+		if errs := validation.IsDNS1123Label(bundle.Namespace); bundle.Namespace != "" && len(errs) > 0 {
+			return nil, fmt.Errorf("Invalid namespace of bundle %s: %s", bundle.Name, strings.Join(errs, ", "))
+		}
+	}
+	for name, level := range config.LogLevels {
+		if _, err := ParseLogLevel(level); err != nil {
+			return nil, fmt.Errorf("Invalid log level of logger %s: %w", name, err)
+		}
+	}
+	if config.BaseDelay() > config.MaxDelay() {
+		return nil, fmt.Errorf("The retry base delay %s is longer than the max delay %s", config.BaseDelay(), config.MaxDelay())
+	}
+	if config.DestinationBackoffBaseDelay() > config.DestinationBackoffMaxDelay() {
+		return nil, fmt.Errorf("The destination backoff base delay %s is longer than the max delay %s",
+			config.DestinationBackoffBaseDelay(), config.DestinationBackoffMaxDelay())
+	}
+	return config, nil
+}
+
+// MatchesPipelineRun returns a boolean indicating whether the pipelinerun matches the pipelinerun selector
+func (c *ControllerConfig) MatchesPipelineRun(pipelineRun *tektonv1.PipelineRun) bool {
+	if c.selector == nil {
+		return true
+	}
+	return c.selector.Matches(labels.Set(pipelineRun.Labels))
+}
+
+// BaseDelay returns the delay before the first retry of a pipelinerun whose notifications failed
+func (c *ControllerConfig) BaseDelay() time.Duration {
+	if c.Retry.BaseDelay == nil {
+		return DefaultRetryBaseDelay
+	}
+	return c.Retry.BaseDelay.Duration
+}
+
+// MaxDelay returns the longest delay between retries of a pipelinerun whose notifications failed
+func (c *ControllerConfig) MaxDelay() time.Duration {
+	if c.Retry.MaxDelay == nil {
+		return DefaultRetryMaxDelay
+	}
+	return c.Retry.MaxDelay.Duration
+}
+
+// DestinationBackoffBaseDelay returns how long a destination is backed off after its first network failure
+func (c *ControllerConfig) DestinationBackoffBaseDelay() time.Duration {
+	if c.DestinationBackoff.BaseDelay == nil {
+		return DefaultDestinationBackoffBaseDelay
+	}
+	return c.DestinationBackoff.BaseDelay.Duration
+}
+
+// DestinationBackoffMaxDelay returns the longest a destination is backed off after consecutive network failures
+func (c *ControllerConfig) DestinationBackoffMaxDelay() time.Duration {
+	if c.DestinationBackoff.MaxDelay == nil {
+		return DefaultDestinationBackoffMaxDelay
+	}
+	return c.DestinationBackoff.MaxDelay.Duration
+}
+
+// ThrottleRetryAfter returns how long pipelineruns whose deliveries were throttled wait before they are retried
+func (c *ControllerConfig) ThrottleRetryAfter() time.Duration {
+	if c.Throttling.RetryAfter == nil {
+		return DefaultThrottleRetryAfter
+	}
+	return c.Throttling.RetryAfter.Duration
+}
+
+// ConfigFile holds the latest valid content of the configuration file and reloads it when it changes.
+// ConfigMaps mounted as volumes are updated in place by the kubelet, so changes to the ConfigMap
+// are applied without restarting the controller, except for the concurrency.
+type ConfigFile struct {
+	Path string
+	Log  logr.Logger
+	// Interval is how often the file is checked for changes
+	Interval time.Duration
+	// LogLevels are replaced by the log levels of the configuration when it is reloaded with some, if set
+	LogLevels *LogLevels
+
+	config atomic.Pointer[ControllerConfig]
+	data   []byte
+}
+
+// NewConfigFile loads the configuration file
+// Return error if the file cannot be read or is not valid
+func NewConfigFile(path string) (*ConfigFile, error) {
+	file := &ConfigFile{Path: path, Interval: DefaultConfigReloadInterval}
+	_, err := file.Reload()
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// Get returns the latest valid configuration, which is empty if there is no configuration file
+func (f *ConfigFile) Get() *ControllerConfig {
+	if f == nil || f.config.Load() == nil {
+		return &ControllerConfig{}
+	}
+	return f.config.Load()
+}
+
+// Reload loads the configuration file again if its content changed, and returns a boolean
+// indicating whether it did. The previous configuration is kept if the new one is not valid.
+func (f *ConfigFile) Reload() (bool, error) {
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return false, fmt.Errorf("Failed to read controller configuration %s: %w", f.Path, err)
+	}
+	if f.config.Load() != nil && bytes.Equal(data, f.data) {
+		return false, nil
+	}
+	config, err := ParseControllerConfig(data)
+	if err != nil {
+		return false, err
+	}
+	f.data = data
+	f.config.Store(config)
+	if f.LogLevels != nil && len(config.LogLevels) > 0 {
+		err = f.LogLevels.Set(config.LogLevels)
+		if err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// NeedLeaderElection returns false so every replica reloads its configuration
+func (f *ConfigFile) NeedLeaderElection() bool {
+	return false
+}
+
+// Start reloads the configuration file every interval until the context is cancelled
+func (f *ConfigFile) Start(ctx context.Context) error {
+	ticker := time.NewTicker(f.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		reloaded, err := f.Reload()
+		if err != nil {
+			f.Log.Error(err, "Keeping the previous controller configuration")
+		} else if reloaded {
+			f.Log.Info("Reloaded controller configuration", "path", f.Path)
+		}
+	}
+}
+
+// ConfigRateLimiter backs off pipelineruns exponentially with the retry policy of the
+// current configuration, so changes to the policy apply to the next retry
+type ConfigRateLimiter struct {
+	Config func() *ControllerConfig
+
+	mu       sync.Mutex
+	failures map[any]int
+}
+
+// When returns how long to wait before the next retry of the item
+func (l *ConfigRateLimiter) When(item any) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.failures == nil {
+		l.failures = map[any]int{}
+	}
+	failures := l.failures[item]
+	l.failures[item] = failures + 1
+	config := l.Config()
+	backoff := float64(config.BaseDelay().Nanoseconds()) * math.Pow(2, float64(failures))
+	if backoff > float64(config.MaxDelay().Nanoseconds()) {
+		return config.MaxDelay()
+	}
+	return time.Duration(backoff)
+}
+
+// Forget resets the backoff of the item
+func (l *ConfigRateLimiter) Forget(item any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.failures, item)
+}
+
+// NumRequeues returns the number of retries of the item
+func (l *ConfigRateLimiter) NumRequeues(item any) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.failures[item]
+}
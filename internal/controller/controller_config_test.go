@@ -0,0 +1,143 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+var _ = Describe("Controller configuration", func() {
+	var path string
+
+	writeConfig := func(content string) {
+		Expect(os.WriteFile(path, []byte(content), 0o600)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		path = filepath.Join(GinkgoT().TempDir(), "config.yaml")
+	})
+
+	It("should reload changes and keep the previous configuration when they are not valid", func() {
+		writeConfig("concurrency: 4\npipelineRunSelector: team=build\n")
+		file, err := NewConfigFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(file.Get().Concurrency).To(Equal(4))
+
+		reloaded, err := file.Reload()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reloaded).To(BeFalse())
+
+		writeConfig("pipelineRunSelector: team in (\n")
+		_, err = file.Reload()
+		Expect(err).To(HaveOccurred())
+		Expect(file.Get().PipelineRunSelector).To(Equal("team=build"))
+
+		writeConfig("concurrency: 2\nretry:\n  baseDelay: 1s\n  maxDelay: 3s\n")
+		reloaded, err = file.Reload()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reloaded).To(BeTrue())
+		Expect(file.Get().PipelineRunSelector).To(BeEmpty())
+		Expect(file.Get().MaxDelay()).To(Equal(3 * time.Second))
+
+		writeConfig("unknown: true\n")
+		_, err = file.Reload()
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should back off with the current retry policy", func() {
+		writeConfig("retry:\n  baseDelay: 1s\n  maxDelay: 3s\n")
+		file, err := NewConfigFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		limiter := &ConfigRateLimiter{Config: file.Get}
+		Expect(limiter.When("item")).To(Equal(time.Second))
+		Expect(limiter.When("item")).To(Equal(2 * time.Second))
+		Expect(limiter.When("item")).To(Equal(3 * time.Second))
+		Expect(limiter.NumRequeues("item")).To(Equal(3))
+		limiter.Forget("item")
+		Expect(limiter.NumRequeues("item")).To(Equal(0))
+
+		writeConfig("retry:\n  baseDelay: 10s\n  maxDelay: 1m\n")
+		_, err = file.Reload()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(limiter.When("item")).To(Equal(10 * time.Second))
+	})
+
+	It("should notify the default destinations about the pipelineruns matching the selector", func() {
+		received := 0
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			received++
+		}))
+		DeferCleanup(receiver.Close)
+		writeConfig("pipelineRunSelector: team=build\nsecretNamespace: default\n" +
+			"defaultDestinations:\n- name: hook\n  webhook:\n    url: " + receiver.URL + "\n")
+		file, err := NewConfigFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		r := &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), ConfigFile: file}
+
+		ignored := createPipelineRun("config-ignored", corev1.ConditionTrue)
+		Expect(reconcilePipelineRun(r, ignored)).To(Succeed())
+		Expect(getPipelineRun(ignored).Finalizers).To(BeEmpty())
+		Expect(received).To(Equal(0))
+
+		selected := createPipelineRun("config-selected", corev1.ConditionTrue)
+		selected = getPipelineRun(selected)
+		selected.Labels = map[string]string{"team": "build"}
+		Expect(k8sClient.Update(context.Background(), selected)).To(Succeed())
+		Expect(reconcilePipelineRun(r, selected)).To(Succeed())
+		Expect(received).To(Equal(1))
+		delivered, err := GetDeliveredDestinations(getPipelineRun(selected))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(delivered).To(HaveKey("default/hook"))
+
+		destination, err := ResolveDestination(context.Background(), r, selected.Namespace, "default/hook")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(destination.Name).To(Equal("default/hook"))
+		_, err = ResolveDestination(context.Background(), r, selected.Namespace, "default/missing")
+		Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should fall back to the pipelinerun selector of the reconciler when the configuration sets none", func() {
+		writeConfig("concurrency: 2\n")
+		file, err := NewConfigFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		r := &NotificationServiceReconciler{ConfigFile: file, PipelineRunSelector: labels.SelectorFromSet(labels.Set{"team": "build"})}
+		build := &tektonv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"team": "build"}}}
+		release := &tektonv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"team": "release"}}}
+		Expect(r.selectsPipelineRun(build)).To(BeTrue())
+		Expect(r.selectsPipelineRun(release)).To(BeFalse())
+
+		writeConfig("pipelineRunSelector: team=release\n")
+		_, err = file.Reload()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(r.selectsPipelineRun(build)).To(BeFalse())
+		Expect(r.selectsPipelineRun(release)).To(BeTrue())
+	})
+})
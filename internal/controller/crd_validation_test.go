@@ -0,0 +1,128 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"github.com/konflux-ci/notification-service/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("CRD validation", func() {
+	secret := corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "credentials"}, Key: "token"}
+	webhook := func() *v1alpha1.WebhookDestination {
+		return &v1alpha1.WebhookDestination{URL: "https://hooks.example.com/builds"}
+	}
+
+	create := func(mutate func(spec *v1alpha1.NotificationServiceSpec)) error {
+		notificationService := &v1alpha1.NotificationService{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: "validated-", Namespace: "default"},
+			Spec: v1alpha1.NotificationServiceSpec{
+				Destinations: []v1alpha1.Destination{{Name: "hook", Webhook: webhook()}},
+			},
+		}
+		mutate(&notificationService.Spec)
+		err := k8sClient.Create(context.Background(), notificationService)
+		if err == nil {
+			DeferCleanup(k8sClient.Delete, context.Background(), notificationService)
+		}
+		return err
+	}
+
+	It("should accept valid specs", func() {
+		Expect(create(func(spec *v1alpha1.NotificationServiceSpec) {
+			spec.LongRunningThreshold = &metav1.Duration{Duration: time.Hour}
+			spec.Destinations[0].ResultConditions = []v1alpha1.ResultCondition{
+				{Result: "VULNERABILITIES", Operator: v1alpha1.ResultOperatorGreaterThan, Value: "1.5e2"},
+			}
+		})).To(Succeed())
+	})
+
+	DescribeTable("should reject invalid specs",
+		func(mutate func(spec *v1alpha1.NotificationServiceSpec), message string) {
+			Expect(create(mutate)).To(MatchError(ContainSubstring(message)))
+		},
+		Entry("without backend", func(spec *v1alpha1.NotificationServiceSpec) {
+			spec.Destinations[0].Webhook = nil
+		}, "exactly one of webhook, slack, matrix, irc, xmpp, webPush, fcm and alertmanager must be set"),
+		Entry("with several backends", func(spec *v1alpha1.NotificationServiceSpec) {
+			spec.Destinations[0].Slack = &v1alpha1.SlackDestination{Channel: "C1", TokenSecretRef: secret}
+		}, "exactly one of webhook, slack, matrix, irc, xmpp, webPush, fcm and alertmanager must be set"),
+		Entry("with both a webhook URL and Service", func(spec *v1alpha1.NotificationServiceSpec) {
+			spec.Destinations[0].Webhook.ServiceRef = &v1alpha1.WebhookServiceReference{Name: "receiver"}
+		}, "exactly one of url and serviceRef must be set"),
+		Entry("with a compressed and encrypted webhook", func(spec *v1alpha1.NotificationServiceSpec) {
+			spec.Destinations[0].Webhook.Compression = v1alpha1.WebhookCompressionGzip
+			spec.Destinations[0].Webhook.Encryption = &v1alpha1.WebhookEncryption{PublicKeySecretRef: secret}
+		}, "encryption cannot be combined with compression"),
+		Entry("with a malformed URL", func(spec *v1alpha1.NotificationServiceSpec) {
+			spec.Destinations[0].Webhook.URL = "https://hooks example.com/%zz"
+		}, "must be a valid URL"),
+		Entry("with SASL without TLS", func(spec *v1alpha1.NotificationServiceSpec) {
+			spec.Destinations[0].Webhook = nil
+			spec.Destinations[0].IRC = &v1alpha1.IRCDestination{Server: "irc.example.com:6667", Channel: "#builds", Insecure: true,
+				SASL: &v1alpha1.IRCSASL{Username: "ci", PasswordSecretRef: secret}}
+		}, "sasl cannot be used without TLS"),
+		Entry("with FCM without recipients", func(spec *v1alpha1.NotificationServiceSpec) {
+			spec.Destinations[0].Webhook = nil
+			spec.Destinations[0].FCM = &v1alpha1.FCMDestination{ServiceAccountKeySecretRef: secret}
+		}, "one of topic and deviceTokensSecretRef must be set"),
+		Entry("with a negative timeout", func(spec *v1alpha1.NotificationServiceSpec) {
+			spec.Destinations[0].AcknowledgementTimeout = &metav1.Duration{Duration: -time.Minute}
+		}, "must be positive"),
+		Entry("with a zero threshold", func(spec *v1alpha1.NotificationServiceSpec) {
+			spec.LongRunningThreshold = &metav1.Duration{}
+		}, "must be positive"),
+		Entry("with a zero objective", func(spec *v1alpha1.NotificationServiceSpec) {
+			spec.SLO = &v1alpha1.DeliverySLO{Objective: "0", Latency: metav1.Duration{Duration: time.Minute}}
+		}, "must be greater than 0"),
+		Entry("with In without values", func(spec *v1alpha1.NotificationServiceSpec) {
+			spec.Destinations[0].ResultConditions = []v1alpha1.ResultCondition{{Result: "TAG", Operator: v1alpha1.ResultOperatorIn}}
+		}, "In and NotIn require values"),
+		Entry("with GreaterThan a string", func(spec *v1alpha1.NotificationServiceSpec) {
+			spec.Destinations[0].ResultConditions = []v1alpha1.ResultCondition{
+				{Result: "VULNERABILITIES", Operator: v1alpha1.ResultOperatorGreaterThan, Value: "many"},
+			}
+		}, "GreaterThan and LessThan require a number value"),
+	)
+
+	DescribeTable("should reject invalid v1beta1 lifecycle thresholds",
+		func(threshold time.Duration) {
+			notificationService := &v1beta1.NotificationService{
+				ObjectMeta: metav1.ObjectMeta{GenerateName: "validated-", Namespace: "default"},
+				Spec: v1beta1.NotificationServiceSpec{
+					Destinations: []v1alpha1.Destination{{Name: "hook", Webhook: webhook()}},
+					Lifecycle:    &v1beta1.LifecycleNotifications{LongRunningThreshold: &metav1.Duration{Duration: threshold}},
+				},
+			}
+			err := k8sClient.Create(context.Background(), notificationService)
+			if err == nil {
+				DeferCleanup(k8sClient.Delete, context.Background(), notificationService)
+			}
+			Expect(err).To(MatchError(ContainSubstring("must be positive")))
+		},
+		Entry("with a zero threshold", time.Duration(0)),
+		Entry("with a negative threshold", -time.Minute),
+	)
+})
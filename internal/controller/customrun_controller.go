@@ -0,0 +1,87 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	tektonv1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"knative.dev/pkg/apis"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// CustomRunReconciler notifies about the end of Tekton CustomRuns, e.g. of pipelines in pipelines or
+// approvals, with the same lifecycle as pipelineruns: CustomRuns are held with NotificationPipelineRunFinalizer
+// until they are notified about, and are then marked with NotificationPipelineRunAnnotation.
+// Their notifications have the KindCustomRun kind, the name of the CustomRun as PipelineRun, and its
+// status, timing and results. They are sent to the destinations of the NotificationServices and to the
+// default notifier; lifecycle notifications, acknowledgements, threads and delivery records only apply to
+// pipelineruns.
+type CustomRunReconciler struct {
+	// Reconciler provides the client, the notifiers and the delivery settings
+	Reconciler *NotificationServiceReconciler
+}
+
+// +kubebuilder:rbac:groups=tekton.dev,resources=customruns,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups=tekton.dev,resources=customruns/finalizers,verbs=update
+
+// Reconcile adds the finalizer to running CustomRuns and, once they are done, sends their notification,
+// marks them as notified and removes the finalizer
+func (r *CustomRunReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	customRun := &tektonv1beta1.CustomRun{}
+	err := r.Reconciler.Get(ctx, req.NamespacedName, customRun)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		r.Reconciler.Log.Error(err, "Failed to get customrun", "customrun", req.NamespacedName)
+		return ctrl.Result{}, err
+	}
+	return r.Reconciler.reconcileRun(ctx, customRun, tektonv1beta1.SchemeGroupVersion.WithKind("CustomRun"), customRun.IsDone(),
+		func() *notifier.Notification { return GetNotificationFromCustomRun(customRun, time.Now()) })
+}
+
+// GetNotificationFromCustomRun builds the notification that is sent for the customRun.
+// Durations of CustomRuns that did not complete yet are measured up to now.
+func GetNotificationFromCustomRun(customRun *tektonv1beta1.CustomRun, now time.Time) *notifier.Notification {
+	condition := customRun.Status.GetCondition(apis.ConditionSucceeded)
+	results := make([]notifier.Result, 0, len(customRun.Status.Results))
+	for _, result := range customRun.Status.Results {
+		results = append(results, notifier.Result{Name: result.Name, Value: result.Value})
+	}
+	notification := &notifier.Notification{
+		PipelineRun: customRun.Name,
+		Namespace:   customRun.Namespace,
+		Kind:        notifier.KindCustomRun,
+		Status:      getConditionStatus(condition),
+		Event:       getConditionEvent(condition, tektonv1beta1.CustomRunReasonCancelled.String()),
+		Results:     results,
+	}
+	notification.StartTime, notification.CompletionTime, notification.DurationSeconds =
+		getTiming(customRun.Status.StartTime, customRun.Status.CompletionTime, now)
+	return notification
+}
+
+// SetupWithManager sets up the controller of CustomRuns with the Manager
+func (r *CustomRunReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&tektonv1beta1.CustomRun{}).
+		Complete(r)
+}
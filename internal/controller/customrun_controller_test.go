@@ -0,0 +1,114 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	tektonv1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("CustomRun controller", func() {
+	createCustomRun := func(name string) *tektonv1beta1.CustomRun {
+		customRun := &tektonv1beta1.CustomRun{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: tektonv1beta1.CustomRunSpec{
+				CustomRef: &tektonv1beta1.TaskRef{APIVersion: "example.dev/v1", Kind: "Approval"},
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), customRun)).To(Succeed())
+		DeferCleanup(func() {
+			cr := &tektonv1beta1.CustomRun{}
+			if k8sClient.Get(context.Background(), client.ObjectKeyFromObject(customRun), cr) == nil {
+				cr.Finalizers = nil
+				_ = k8sClient.Update(context.Background(), cr)
+				_ = k8sClient.Delete(context.Background(), cr)
+			}
+		})
+		return customRun
+	}
+
+	completeCustomRun := func(customRun *tektonv1beta1.CustomRun, status corev1.ConditionStatus, reason string) {
+		Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(customRun), customRun)).To(Succeed())
+		customRun.Status.Conditions = duckv1.Conditions{{Type: apis.ConditionSucceeded, Status: status, Reason: reason}}
+		customRun.Status.Results = []tektonv1beta1.CustomRunResult{{Name: "approver", Value: "jdoe"}}
+		Expect(k8sClient.Status().Update(context.Background(), customRun)).To(Succeed())
+	}
+
+	reconcileCustomRun := func(r *CustomRunReconciler, customRun *tektonv1beta1.CustomRun) *tektonv1beta1.CustomRun {
+		_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(customRun)})
+		Expect(err).NotTo(HaveOccurred())
+		reconciled := &tektonv1beta1.CustomRun{}
+		Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(customRun), reconciled)).To(Succeed())
+		return reconciled
+	}
+
+	It("should hold customruns until they are notified about", func() {
+		fake := &fakeNotifier{}
+		r := &CustomRunReconciler{Reconciler: &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Notifier: fake}}
+		customRun := createCustomRun("customrun-approval")
+
+		reconciled := reconcileCustomRun(r, customRun)
+		Expect(reconciled.Finalizers).To(ContainElement(NotificationPipelineRunFinalizer))
+		Expect(fake.notifications).To(BeEmpty())
+
+		completeCustomRun(customRun, corev1.ConditionTrue, "Approved")
+		reconciled = reconcileCustomRun(r, customRun)
+		Expect(reconciled.Finalizers).NotTo(ContainElement(NotificationPipelineRunFinalizer))
+		Expect(reconciled.Annotations).To(HaveKeyWithValue(NotificationPipelineRunAnnotation, NotificationPipelineRunAnnotationValue))
+		Expect(reconciled.Annotations).To(HaveKey(NotificationDeliveredAnnotation))
+		Expect(fake.notifications).To(HaveLen(1))
+		Expect(fake.notifications[0].Kind).To(Equal(notifier.KindCustomRun))
+		Expect(fake.notifications[0].PipelineRun).To(Equal("customrun-approval"))
+		Expect(fake.notifications[0].Status).To(Equal(notifier.StatusSucceeded))
+		Expect(fake.notifications[0].Results).To(Equal([]notifier.Result{{Name: "approver", Value: "jdoe"}}))
+
+		reconcileCustomRun(r, customRun)
+		Expect(fake.notifications).To(HaveLen(1))
+	})
+
+	It("should keep the finalizer and retry when the notification fails", func() {
+		fake := &fakeNotifier{err: errors.New("unavailable")}
+		r := &CustomRunReconciler{Reconciler: &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Notifier: fake}}
+		customRun := createCustomRun("customrun-cancelled")
+		reconcileCustomRun(r, customRun)
+		completeCustomRun(customRun, corev1.ConditionFalse, tektonv1beta1.CustomRunReasonCancelled.String())
+
+		_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(customRun)})
+		Expect(err).To(HaveOccurred())
+		Expect(fake.notifications).To(HaveLen(1))
+		Expect(fake.notifications[0].Event).To(Equal(notifier.EventCancelled))
+		Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(customRun), customRun)).To(Succeed())
+		Expect(customRun.Finalizers).To(ContainElement(NotificationPipelineRunFinalizer))
+
+		fake.err = nil
+		reconciled := reconcileCustomRun(r, customRun)
+		Expect(reconciled.Finalizers).NotTo(ContainElement(NotificationPipelineRunFinalizer))
+		Expect(fake.notifications).To(HaveLen(2))
+	})
+})
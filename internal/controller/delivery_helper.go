@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// NotificationDeliveredReason is the reason of events emitted for delivered notifications
+	NotificationDeliveredReason string = "NotificationDelivered"
+	// NotificationFailedReason is the reason of events emitted for failed notifications
+	NotificationFailedReason string = "NotificationFailed"
+	// NotificationSkippedReason is the reason of events emitted for notifications skipped by paused destinations or maintenance windows
+	NotificationSkippedReason string = "NotificationSkipped"
+	// NotificationBlockedReason is the reason of events emitted for notifications not sent because secrets were detected in them
+	NotificationBlockedReason string = "NotificationBlocked"
+	// NotificationDeadLetteredReason is the reason of events emitted for notifications parked in the dead-letter store
+	NotificationDeadLetteredReason string = "NotificationDeadLettered"
+)
+
+// DeadLetterLabel marks the NotificationDeliveries holding the notifications that exhausted their delivery attempts
+const DeadLetterLabel string = "konflux.ci/dead-letter"
+
+// DeadLetterLabelValue is the value of DeadLetterLabel
+const DeadLetterLabelValue string = "true"
+
+// CreateDeliveryRecord records a delivery attempt as a NotificationDelivery in the namespace of the pipelineRun
+// If the record was not created successfully, a non-nil error is returned.
+func CreateDeliveryRecord(ctx context.Context, r *NotificationServiceReconciler, pipelineRun *tektonv1.PipelineRun,
+	destination string, notification *notifier.Notification, response *notifier.Response,
+	latency time.Duration, deliveryErr error) error {
+	hash, err := notification.Hash()
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("Failed to encode notification for pipelinerun %s: %w", pipelineRun.Name, err)
+	}
+	delivery := newDeliveryRecord(pipelineRun, destination, hash, payload, response, deliveryErr)
+	delivery.OwnerReferences = pipelineRunOwnerReferences(pipelineRun)
+	delivery.Spec.Latency = metav1.Duration{Duration: latency}
+	err = r.Client.Create(ctx, delivery)
+	if err != nil {
+		return fmt.Errorf("Error occurred while creating delivery record for pipelinerun %s: %w", pipelineRun.Name, err)
+	}
+	return nil
+}
+
+// CreateDeadLetter parks the notification that exhausted its delivery attempts to the destination as a NotificationDelivery
+// labeled with DeadLetterLabel in the namespace of the pipelineRun, so it can be inspected and sent again. Unlike delivery
+// records, it is not owned by the pipelineRun, so it outlives it until its TTL expires.
+// It returns the name of the NotificationDelivery.
+// If the record was not created successfully, a non-nil error is returned.
+func CreateDeadLetter(ctx context.Context, r *NotificationServiceReconciler, pipelineRun *tektonv1.PipelineRun,
+	destination string, notification *notifier.Notification, deliveryErr error) (string, error) {
+	hash, err := notification.Hash()
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return "", fmt.Errorf("Failed to encode notification for pipelinerun %s: %w", pipelineRun.Name, err)
+	}
+	delivery := newDeliveryRecord(pipelineRun, destination, hash, payload, nil, deliveryErr)
+	delivery.Labels = map[string]string{DeadLetterLabel: DeadLetterLabelValue}
+	err = r.Client.Create(ctx, delivery)
+	if err != nil {
+		return "", fmt.Errorf("Error occurred while parking the notification of pipelinerun %s: %w", pipelineRun.Name, err)
+	}
+	return delivery.Name, nil
+}
+
+// newDeliveryRecord returns the NotificationDelivery recording the delivery of the encoded notification to the destination
+func newDeliveryRecord(pipelineRun *tektonv1.PipelineRun, destination string, hash string, payload []byte,
+	response *notifier.Response, deliveryErr error) *v1alpha1.NotificationDelivery {
+	delivery := &v1alpha1.NotificationDelivery{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: pipelineRun.Name + "-",
+			Namespace:    pipelineRun.Namespace,
+		},
+		Spec: v1alpha1.NotificationDeliverySpec{
+			PipelineRun: pipelineRun.Name,
+			Destination: destination,
+			PayloadHash: hash,
+			Payload:     string(payload),
+			Succeeded:   deliveryErr == nil,
+		},
+	}
+	if response != nil {
+		delivery.Spec.ResponseCode = response.StatusCode
+		delivery.Spec.ResponseExcerpt = response.Excerpt
+	}
+	if deliveryErr != nil {
+		delivery.Spec.Error = deliveryErr.Error()
+	}
+	return delivery
+}
+
+// RecordDeliveryEvent emits an event on the pipelinerun or customrun describing the outcome of a delivery attempt,
+// including the response of the destination if it was reported
+func RecordDeliveryEvent(r *NotificationServiceReconciler, run client.Object, destination string,
+	response *notifier.Response, deliveryErr error) {
+	if r.Recorder == nil {
+		return
+	}
+	eventType, reason, message := corev1.EventTypeNormal, NotificationDeliveredReason, "Notification delivered to "+destination
+	switch {
+	case errors.Is(deliveryErr, notifier.ErrSecretDetected):
+		eventType, reason, message = corev1.EventTypeWarning, NotificationBlockedReason, "Notification to "+destination+" blocked"
+	case deliveryErr != nil:
+		eventType, reason, message = corev1.EventTypeWarning, NotificationFailedReason, "Notification to "+destination+" failed"
+	}
+	if response != nil {
+		message = fmt.Sprintf("%s with status %d", message, response.StatusCode)
+		if response.Excerpt != "" {
+			message = fmt.Sprintf("%s: %s", message, response.Excerpt)
+		}
+	} else if deliveryErr != nil {
+		message = fmt.Sprintf("%s: %s", message, deliveryErr)
+	}
+	r.Recorder.Event(run, eventType, reason, message)
+}
@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NotificationDeliveredAnnotation holds a JSON map from destination to the time the notification about the
+// end of the pipelinerun was delivered to it, or blocked for it because secrets were detected in it, so failed
+// deliveries are retried only for their destination
+var NotificationDeliveredAnnotation = DefaultMarkerPrefix + "/delivered-destinations"
+
+// NotificationSkippedAnnotation holds a JSON map from destination to the time the notification about the
+// end of the pipelinerun was skipped because the destination was paused
+var NotificationSkippedAnnotation = DefaultMarkerPrefix + "/skipped-destinations"
+
+// GetDeliveredDestinations returns the destinations the end of the pipelineRun was already notified to
+// Return error if the annotation is malformed
+func GetDeliveredDestinations(pipelineRun *tektonv1.PipelineRun) (map[string]time.Time, error) {
+	delivered := map[string]time.Time{}
+	err := getJSONAnnotation(pipelineRun, NotificationDeliveredAnnotation, &delivered)
+	if err != nil {
+		return map[string]time.Time{}, err
+	}
+	return delivered, nil
+}
+
+// SetDeliveredDestinations stores the destinations the end of the pipelineRun was notified to
+// If the annotation was not updated successfully, a non-nil error is returned.
+func SetDeliveredDestinations(ctx context.Context, pipelineRun *tektonv1.PipelineRun, c client.Client, delivered map[string]time.Time) error {
+	return setJSONAnnotation(ctx, pipelineRun, c, NotificationDeliveredAnnotation, delivered)
+}
+
+// FilterDeliveredDestinations removes the destinations that were already notified
+func FilterDeliveredDestinations(destinations []DestinationNotifier, delivered map[string]time.Time) []DestinationNotifier {
+	var filtered []DestinationNotifier
+	for _, destination := range destinations {
+		if _, ok := delivered[destination.Name]; !ok {
+			filtered = append(filtered, destination)
+		}
+	}
+	return filtered
+}
+
+// GetSkippedDestinations returns the destinations that skipped the notification about the end of the pipelineRun
+// Return error if the annotation is malformed
+func GetSkippedDestinations(pipelineRun *tektonv1.PipelineRun) (map[string]time.Time, error) {
+	skipped := map[string]time.Time{}
+	err := getJSONAnnotation(pipelineRun, NotificationSkippedAnnotation, &skipped)
+	if err != nil {
+		return map[string]time.Time{}, err
+	}
+	return skipped, nil
+}
+
+// SetSkippedDestinations stores the destinations that skipped the notification about the end of the pipelineRun
+// If the annotation was not updated successfully, a non-nil error is returned.
+func SetSkippedDestinations(ctx context.Context, pipelineRun *tektonv1.PipelineRun, c client.Client, skipped map[string]time.Time) error {
+	return setJSONAnnotation(ctx, pipelineRun, c, NotificationSkippedAnnotation, skipped)
+}
+
+// SplitPausedDestinations separates the destinations of paused NotificationServices from the active ones
+func SplitPausedDestinations(destinations []DestinationNotifier) ([]DestinationNotifier, []DestinationNotifier) {
+	var active, paused []DestinationNotifier
+	for _, destination := range destinations {
+		if destination.Paused {
+			paused = append(paused, destination)
+		} else {
+			active = append(active, destination)
+		}
+	}
+	return active, paused
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"sync"
+	"time"
+
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+)
+
+// DefaultOrderingTimeout is how long a notification failing to be delivered to an ordered destination holds back
+// the later ones, measured from its last attempt
+const DefaultOrderingTimeout = 10 * time.Minute
+
+// sequencedDelivery is a notification waiting to be delivered to an ordered destination
+type sequencedDelivery struct {
+	id string
+	// at is the time of the event the notification is about, which orders the deliveries
+	at time.Time
+	// attempted is when the notification was last attempted
+	attempted time.Time
+}
+
+// DeliverySequencer orders the deliveries to the destinations that require it. Notifications are delivered to an
+// ordered destination one at a time, by increasing time of the event they are about, e.g. the completion of the run,
+// and by delivery ID for the same time. Pending notifications are kept in memory, so a restart of the controller
+// forgets the order of the notifications that were not delivered yet.
+type DeliverySequencer struct {
+	// Timeout is how long a pending notification holds back the later ones after its last attempt,
+	// defaults to DefaultOrderingTimeout
+	Timeout time.Duration
+
+	mu      sync.Mutex
+	pending map[string][]sequencedDelivery
+}
+
+// Ready registers the delivery identified by id of the notification to the destination, and returns a boolean
+// indicating whether it is the next one to deliver. Pending deliveries that were not attempted again within
+// the timeout are forgotten.
+func (s *DeliverySequencer) Ready(destination string, id string, notification *notifier.Notification, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending == nil {
+		s.pending = map[string][]sequencedDelivery{}
+	}
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = DefaultOrderingTimeout
+	}
+	var pending []sequencedDelivery
+	registered := false
+	for _, delivery := range s.pending[destination] {
+		if delivery.id == id {
+			delivery.attempted = now
+			registered = true
+		} else if now.Sub(delivery.attempted) > timeout {
+			continue
+		}
+		pending = append(pending, delivery)
+	}
+	if !registered {
+		pending = append(pending, sequencedDelivery{id: id, at: eventTime(notification, now), attempted: now})
+	}
+	s.pending[destination] = pending
+	next := pending[0]
+	for _, delivery := range pending[1:] {
+		if delivery.at.Before(next.at) || (delivery.at.Equal(next.at) && delivery.id < next.id) {
+			next = delivery
+		}
+	}
+	return next.id == id
+}
+
+// Done forgets the delivery identified by id to the destination, once it was delivered or will not be retried
+func (s *DeliverySequencer) Done(destination string, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pending := s.pending[destination]
+	for i, delivery := range pending {
+		if delivery.id == id {
+			pending = append(pending[:i], pending[i+1:]...)
+			break
+		}
+	}
+	if len(pending) == 0 {
+		delete(s.pending, destination)
+		return
+	}
+	s.pending[destination] = pending
+}
+
+// eventTime returns the time of the event the notification is about: the completion of the run, or its start
+// for lifecycle notifications, or now if the notification has neither
+func eventTime(notification *notifier.Notification, now time.Time) time.Time {
+	switch {
+	case notification.CompletionTime != nil:
+		return *notification.CompletionTime
+	case notification.StartTime != nil:
+		return *notification.StartTime
+	}
+	return now
+}
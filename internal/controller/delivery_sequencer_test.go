@@ -0,0 +1,107 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Delivery sequencer", func() {
+	completedAt := func(at time.Time) *notifier.Notification {
+		return &notifier.Notification{CompletionTime: &at}
+	}
+
+	It("should deliver the notifications in the order of their events", func() {
+		sequencer := &DeliverySequencer{}
+		now := time.Now()
+		Expect(sequencer.Ready("hook", "later", completedAt(now), now)).To(BeTrue())
+		Expect(sequencer.Ready("hook", "earlier", completedAt(now.Add(-time.Minute)), now)).To(BeTrue())
+		Expect(sequencer.Ready("hook", "later", completedAt(now), now)).To(BeFalse())
+		Expect(sequencer.Ready("chat", "later", completedAt(now), now)).To(BeTrue())
+
+		sequencer.Done("hook", "earlier")
+		Expect(sequencer.Ready("hook", "later", completedAt(now), now)).To(BeTrue())
+		sequencer.Done("hook", "later")
+		Expect(sequencer.pending).NotTo(HaveKey("hook"))
+	})
+
+	It("should forget the notifications that are not retried within the timeout", func() {
+		sequencer := &DeliverySequencer{Timeout: time.Minute}
+		now := time.Now()
+		Expect(sequencer.Ready("hook", "abandoned", completedAt(now.Add(-time.Hour)), now)).To(BeTrue())
+		Expect(sequencer.Ready("hook", "later", completedAt(now), now.Add(30*time.Second))).To(BeFalse())
+		Expect(sequencer.Ready("hook", "later", completedAt(now), now.Add(2*time.Minute))).To(BeTrue())
+	})
+
+	It("should replace the concurrency limit of the throttling policy", func() {
+		policy := ThrottlingPolicy{MaxInFlightPerNamespace: 5, MaxInFlightPerDestination: 2}
+		Expect(destinationPolicy(DestinationNotifier{}, policy)).To(Equal(policy))
+		Expect(destinationPolicy(DestinationNotifier{MaxConcurrency: 10}, policy).MaxInFlightPerDestination).To(Equal(10))
+		Expect(destinationPolicy(DestinationNotifier{MaxConcurrency: 10, Ordered: true}, policy).MaxInFlightPerDestination).To(Equal(1))
+	})
+
+	It("should hold back the notifications of ordered destinations until the earlier ones are delivered", func() {
+		received := 0
+		failing := true
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			if failing {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			received++
+		}))
+		DeferCleanup(receiver.Close)
+		notificationService := &v1alpha1.NotificationService{
+			ObjectMeta: metav1.ObjectMeta{Name: "ordered", Namespace: "default"},
+			Spec: v1alpha1.NotificationServiceSpec{
+				Destinations: []v1alpha1.Destination{
+					{Name: "hook", Ordered: true, Webhook: &v1alpha1.WebhookDestination{URL: receiver.URL}},
+				},
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), notificationService)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), notificationService)
+		r := &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+
+		first := createPipelineRun("ordered-first", corev1.ConditionFalse)
+		second := createPipelineRun("ordered-second", corev1.ConditionTrue)
+		Expect(reconcilePipelineRun(r, first)).NotTo(Succeed())
+		failing = false
+		result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(second)})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(Equal(DefaultThrottleRetryAfter))
+		Expect(received).To(BeZero())
+
+		Expect(reconcilePipelineRun(r, first)).To(Succeed())
+		Expect(reconcilePipelineRun(r, second)).To(Succeed())
+		Expect(received).To(Equal(2))
+		Expect(getPipelineRun(second).Annotations).To(HaveKeyWithValue(NotificationPipelineRunAnnotation, NotificationPipelineRunAnnotationValue))
+	})
+})
@@ -0,0 +1,77 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrDeliveryThrottled is returned when notifications were not delivered to some destinations
+// because the throttling policy of the controller configuration was reached
+var ErrDeliveryThrottled = errors.New("Delivery throttled")
+
+// DeliveryThrottle counts the deliveries in flight per namespace and per destination,
+// so a namespace launching many pipelineruns cannot overwhelm shared destinations
+type DeliveryThrottle struct {
+	mu           sync.Mutex
+	namespaces   map[string]int
+	destinations map[string]int
+}
+
+// Acquire reserves a delivery to the destination for a pipelinerun of the namespace, and returns
+// the function releasing it and a boolean indicating whether the policy allows the delivery
+func (t *DeliveryThrottle) Acquire(namespace string, destination string, policy ThrottlingPolicy) (func(), bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.namespaces == nil {
+		t.namespaces = map[string]int{}
+		t.destinations = map[string]int{}
+	}
+	if policy.MaxInFlightPerNamespace > 0 && t.namespaces[namespace] >= policy.MaxInFlightPerNamespace {
+		return nil, false
+	}
+	if policy.MaxInFlightPerDestination > 0 && t.destinations[destination] >= policy.MaxInFlightPerDestination {
+		return nil, false
+	}
+	t.namespaces[namespace]++
+	t.destinations[destination]++
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.namespaces[namespace]--
+		if t.namespaces[namespace] == 0 {
+			delete(t.namespaces, namespace)
+		}
+		t.destinations[destination]--
+		if t.destinations[destination] == 0 {
+			delete(t.destinations, destination)
+		}
+	}, true
+}
+
+// destinationPolicy returns the throttling policy of the deliveries to the destination: its max concurrency
+// replaces the limit per destination of the policy, and ordered destinations get one delivery at a time
+func destinationPolicy(destination DestinationNotifier, policy ThrottlingPolicy) ThrottlingPolicy {
+	switch {
+	case destination.Ordered:
+		policy.MaxInFlightPerDestination = 1
+	case destination.MaxConcurrency > 0:
+		policy.MaxInFlightPerDestination = destination.MaxConcurrency
+	}
+	return policy
+}
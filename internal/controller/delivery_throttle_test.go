@@ -0,0 +1,94 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Delivery throttle", func() {
+	It("should limit the deliveries in flight per namespace and per destination", func() {
+		throttle := &DeliveryThrottle{}
+		policy := ThrottlingPolicy{MaxInFlightPerNamespace: 2, MaxInFlightPerDestination: 1}
+		release, ok := throttle.Acquire("tenant", "tenant/service/hook", policy)
+		Expect(ok).To(BeTrue())
+		_, ok = throttle.Acquire("other", "tenant/service/hook", policy)
+		Expect(ok).To(BeFalse())
+		releaseChat, ok := throttle.Acquire("tenant", "tenant/service/chat", policy)
+		Expect(ok).To(BeTrue())
+		_, ok = throttle.Acquire("tenant", "tenant/service/mail", policy)
+		Expect(ok).To(BeFalse())
+
+		release()
+		releaseChat()
+		_, ok = throttle.Acquire("other", "tenant/service/hook", policy)
+		Expect(ok).To(BeTrue())
+		_, ok = throttle.Acquire("tenant", "tenant/service/mail", ThrottlingPolicy{})
+		Expect(ok).To(BeTrue())
+	})
+
+	It("should retry throttled pipelineruns without failing them", func() {
+		received := 0
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			received++
+		}))
+		DeferCleanup(receiver.Close)
+		path := filepath.Join(GinkgoT().TempDir(), "config.yaml")
+		Expect(os.WriteFile(path, []byte("throttling:\n  maxInFlightPerDestination: 1\n  retryAfter: 30s\n"), 0o600)).To(Succeed())
+		file, err := NewConfigFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		notificationService := &v1alpha1.NotificationService{
+			ObjectMeta: metav1.ObjectMeta{Name: "throttled", Namespace: "default"},
+			Spec: v1alpha1.NotificationServiceSpec{
+				Destinations: []v1alpha1.Destination{{Name: "hook", Webhook: &v1alpha1.WebhookDestination{URL: receiver.URL}}},
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), notificationService)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), notificationService)
+		r := &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), ConfigFile: file}
+		release, ok := r.throttle.Acquire("other", "default/throttled/hook", file.Get().Throttling)
+		Expect(ok).To(BeTrue())
+
+		pipelineRun := createPipelineRun("throttled", corev1.ConditionTrue)
+		request := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pipelineRun)}
+		result, err := r.Reconcile(context.Background(), request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(Equal(30 * time.Second))
+		Expect(received).To(Equal(0))
+		Expect(getPipelineRun(pipelineRun).Annotations).NotTo(HaveKey(NotificationPipelineRunAnnotation))
+
+		release()
+		_, err = r.Reconcile(context.Background(), request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(received).To(Equal(1))
+		Expect(getPipelineRun(pipelineRun).Annotations).To(HaveKeyWithValue(NotificationPipelineRunAnnotation, NotificationPipelineRunAnnotationValue))
+	})
+})
@@ -0,0 +1,175 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+)
+
+// ErrDestinationBackingOff is returned when notifications were not delivered to some destinations
+// because they are backing off after consecutive network failures
+var ErrDestinationBackingOff = errors.New("Destination backing off")
+
+// BackoffError is an ErrDestinationBackingOff with the earliest end of the backoffs of the destinations
+type BackoffError struct {
+	Until time.Time
+}
+
+func (e *BackoffError) Error() string {
+	return fmt.Sprintf("%s until %s", ErrDestinationBackingOff, e.Until.UTC().Format(time.RFC3339))
+}
+
+// Is matches ErrDestinationBackingOff
+func (e *BackoffError) Is(target error) bool {
+	return target == ErrDestinationBackingOff
+}
+
+// DestinationBackoff backs off destinations exponentially after consecutive network failures. It is shared
+// by the reconciles of all pipelineruns, so a destination that is down is not sent to by each of them in turn.
+type DestinationBackoff struct {
+	mu           sync.Mutex
+	destinations map[string]v1alpha1.DestinationBackoff
+}
+
+// Until returns the end of the backoff of the destination and a boolean indicating whether it is backing off at now
+func (b *DestinationBackoff) Until(destination string, now time.Time) (time.Time, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state, ok := b.destinations[destination]
+	if !ok {
+		return time.Time{}, false
+	}
+	return state.BackoffUntil.Time, now.Before(state.BackoffUntil.Time)
+}
+
+// Record updates the backoff of the destination with the outcome of a delivery at now: network failures
+// double it from the base delay of the config up to its max delay, and successful deliveries end it.
+// Other failures, which the destination answered, leave it unchanged.
+// It returns a boolean indicating whether the backoff changed.
+func (b *DestinationBackoff) Record(destination string, err error, config *ControllerConfig, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		if _, ok := b.destinations[destination]; !ok {
+			return false
+		}
+		delete(b.destinations, destination)
+		return true
+	}
+	if !IsNetworkFailure(err) {
+		return false
+	}
+	if b.destinations == nil {
+		b.destinations = map[string]v1alpha1.DestinationBackoff{}
+	}
+	state := b.destinations[destination]
+	delay := float64(config.DestinationBackoffBaseDelay().Nanoseconds()) * math.Pow(2, float64(state.Failures))
+	delay = math.Min(delay, float64(config.DestinationBackoffMaxDelay().Nanoseconds()))
+	state.Name = destination
+	state.Failures++
+	state.BackoffUntil = metav1.NewTime(now.Add(time.Duration(delay)).UTC().Truncate(time.Second))
+	state.LastError = err.Error()
+	b.destinations[destination] = state
+	return true
+}
+
+// Status returns the backoffs of the destinations whose names start with the prefix, named without it
+// and sorted by name
+func (b *DestinationBackoff) Status(prefix string) []v1alpha1.DestinationBackoff {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var backoffs []v1alpha1.DestinationBackoff
+	for name, state := range b.destinations {
+		if strings.HasPrefix(name, prefix) {
+			state.Name = strings.TrimPrefix(name, prefix)
+			backoffs = append(backoffs, state)
+		}
+	}
+	sort.Slice(backoffs, func(i, j int) bool { return backoffs[i].Name < backoffs[j].Name })
+	return backoffs
+}
+
+// earliest returns the earliest of the times, ignoring zero times
+func earliest(a time.Time, b time.Time) time.Time {
+	if a.IsZero() || (!b.IsZero() && b.Before(a)) {
+		return b
+	}
+	return a
+}
+
+// IsNetworkFailure returns a boolean indicating whether the error is a failure to resolve, connect to or
+// get an answer in time from a destination, rather than an answer of the destination
+func IsNetworkFailure(err error) bool {
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	return errors.As(err, &opErr) || errors.As(err, &dnsErr) || (errors.As(err, &netErr) && netErr.Timeout()) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
+
+// recordBackoff updates the backoff of the destination with the outcome of its delivery and, if it changed,
+// the backoffs reported in the status of the NotificationService of the destination
+func (r *NotificationServiceReconciler) recordBackoff(ctx context.Context, destination DestinationNotifier, err error) {
+	if !r.backoff.Record(destination.Name, err, r.ConfigFile.Get(), time.Now()) {
+		return
+	}
+	if until, ok := r.backoff.Until(destination.Name, time.Now()); ok {
+		r.Log.Info("Backing off destination after network failure", "destination", destination.Name, "until", until)
+	}
+	if destination.NotificationService.Name == "" {
+		return
+	}
+	statusErr := r.setBackoffStatus(ctx, destination.NotificationService)
+	if statusErr != nil {
+		r.Log.Error(statusErr, "Failed to report destination backoffs", "destination", destination.Name)
+	}
+}
+
+// setBackoffStatus sets the backoffs of the destinations of the NotificationService in its status
+func (r *NotificationServiceReconciler) setBackoffStatus(ctx context.Context, key types.NamespacedName) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		notificationService := &v1alpha1.NotificationService{}
+		err := r.Get(ctx, key, notificationService)
+		if err != nil {
+			return err
+		}
+		backoffs := r.backoff.Status(key.Namespace + "/" + key.Name + "/")
+		if equality.Semantic.DeepEqual(backoffs, notificationService.Status.Backoffs) {
+			return nil
+		}
+		notificationService.Status.Backoffs = backoffs
+		return r.Status().Update(ctx, notificationService)
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to update the backoffs of NotificationService %s: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Destination backoff", func() {
+	It("should back off destinations exponentially after network failures", func() {
+		backoff := &DestinationBackoff{}
+		config := &ControllerConfig{DestinationBackoff: RetryPolicy{
+			BaseDelay: &metav1.Duration{Duration: 10 * time.Second},
+			MaxDelay:  &metav1.Duration{Duration: 30 * time.Second},
+		}}
+		now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+		refused := fmt.Errorf("Failed to send: %w", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")})
+
+		Expect(backoff.Record("tenant/service/hook", errors.New("Webhook returned status 400"), config, now)).To(BeFalse())
+		_, ok := backoff.Until("tenant/service/hook", now)
+		Expect(ok).To(BeFalse())
+		for _, delay := range []time.Duration{10 * time.Second, 20 * time.Second, 30 * time.Second, 30 * time.Second} {
+			Expect(backoff.Record("tenant/service/hook", refused, config, now)).To(BeTrue())
+			until, ok := backoff.Until("tenant/service/hook", now)
+			Expect(ok).To(BeTrue())
+			Expect(until).To(Equal(now.Add(delay)))
+		}
+		_, ok = backoff.Until("tenant/service/hook", now.Add(time.Minute))
+		Expect(ok).To(BeFalse())
+		Expect(backoff.Record("tenant/other/hook", context.DeadlineExceeded, config, now)).To(BeTrue())
+		Expect(backoff.Status("tenant/service/")).To(Equal([]v1alpha1.DestinationBackoff{{
+			Name: "hook", Failures: 4, BackoffUntil: metav1.NewTime(now.Add(30 * time.Second)), LastError: refused.Error(),
+		}}))
+
+		Expect(backoff.Record("tenant/service/hook", nil, config, now)).To(BeTrue())
+		Expect(backoff.Record("tenant/service/hook", nil, config, now)).To(BeFalse())
+		Expect(backoff.Status("tenant/service/")).To(BeEmpty())
+		Expect(backoff.Status("tenant/other/")).To(HaveLen(1))
+	})
+
+	It("should not send to destinations backing off from other pipelineruns", func() {
+		receiver := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+		receiver.Close()
+		notificationService := &v1alpha1.NotificationService{
+			ObjectMeta: metav1.ObjectMeta{Name: "backoff", Namespace: "default"},
+			Spec: v1alpha1.NotificationServiceSpec{
+				Destinations: []v1alpha1.Destination{{Name: "hook", Webhook: &v1alpha1.WebhookDestination{URL: receiver.URL}}},
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), notificationService)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), notificationService)
+		r := &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+
+		first := createPipelineRun("backoff-first", corev1.ConditionTrue)
+		Expect(reconcilePipelineRun(r, first)).To(MatchError(ContainSubstring("connection refused")))
+		updated := &v1alpha1.NotificationService{}
+		Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(notificationService), updated)).To(Succeed())
+		Expect(updated.Status.Backoffs).To(HaveLen(1))
+		Expect(updated.Status.Backoffs[0].Name).To(Equal("hook"))
+		Expect(updated.Status.Backoffs[0].Failures).To(BeEquivalentTo(1))
+		Expect(updated.Status.Backoffs[0].BackoffUntil.Time).To(BeTemporally(">", time.Now()))
+
+		second := createPipelineRun("backoff-second", corev1.ConditionTrue)
+		result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(second)})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(BeNumerically("~", DefaultDestinationBackoffBaseDelay, 2*time.Second))
+		Expect(getPipelineRun(second).Annotations).NotTo(HaveKey(NotificationPipelineRunAnnotation))
+		Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(notificationService), updated)).To(Succeed())
+		Expect(updated.Status.Backoffs[0].Failures).To(BeEquivalentTo(1))
+	})
+})
@@ -0,0 +1,556 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// PayloadSigner signs the bodies of the webhook destinations that enable signing, if set
+var PayloadSigner notifier.PayloadSigner
+
+// StatusStyles override the default emoji, icon and color of statuses in the messages of Slack and Matrix destinations
+var StatusStyles map[string]notifier.StatusStyle
+
+// ParseStatusStyles parses a YAML mapping of statuses, e.g. Failed, to their style
+// Return error if the mapping is malformed or overrides unknown statuses
+func ParseStatusStyles(data []byte) (map[string]notifier.StatusStyle, error) {
+	styles := map[string]notifier.StatusStyle{}
+	err := yaml.UnmarshalStrict(data, &styles)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse status styles: %w", err)
+	}
+	err = notifier.ValidateStatusStyles(styles)
+	if err != nil {
+		return nil, err
+	}
+	return styles, nil
+}
+
+// ParseSecretPatterns parses a YAML map from the names of secret detection rules to the regular expressions
+// of the secrets they detect, see notifier.SecretScannerOptions
+func ParseSecretPatterns(data []byte) (map[string]string, error) {
+	patterns := map[string]string{}
+	err := yaml.UnmarshalStrict(data, &patterns)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse secret patterns: %w", err)
+	}
+	return patterns, nil
+}
+
+// NewNotifierForDestination creates the notifier that delivers notifications to the destination
+// Secrets and the NotificationTemplate referenced by the destination are read from the namespace of its NotificationService
+// Return error if the destination is not valid
+func NewNotifierForDestination(ctx context.Context, c client.Reader, namespace string, destination v1alpha1.Destination) (notifier.Notifier, error) {
+	return newNotifierForDestination(ctx, c, namespace, destination, false)
+}
+
+// NewTenantNotifierForDestination creates the notifier of a destination declared by a tenant in a NotificationService,
+// whose connections are restricted by the notifier.TenantGuard
+// Return error if the destination is not valid
+func NewTenantNotifierForDestination(ctx context.Context, c client.Reader, namespace string, destination v1alpha1.Destination) (notifier.Notifier, error) {
+	return newNotifierForDestination(ctx, c, namespace, destination, true)
+}
+
+func newNotifierForDestination(ctx context.Context, c client.Reader, namespace string, destination v1alpha1.Destination, tenant bool) (notifier.Notifier, error) {
+	var httpClient *http.Client
+	var guard *notifier.AddressGuard
+	if tenant {
+		httpClient = notifier.NewTenantHTTPClient(notifier.DefaultWebhookTimeout)
+		guard = notifier.TenantGuard
+	}
+	destination, err := resolveTemplateRef(ctx, c, namespace, destination)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateResultConditions(destination.ResultConditions); err != nil {
+		return nil, fmt.Errorf("Invalid result conditions of destination %s: %w", destination.Name, err)
+	}
+	if destination.Webhook != nil {
+		url := destination.Webhook.URL
+		if (url == "") == (destination.Webhook.ServiceRef == nil) {
+			return nil, fmt.Errorf("Webhook of destination %s requires exactly one of url and serviceRef", destination.Name)
+		}
+		if destination.Webhook.ServiceRef != nil {
+			if tenant {
+				if err := CheckServiceReference(namespace, destination.Webhook.ServiceRef); err != nil {
+					return nil, fmt.Errorf("Invalid webhook of destination %s: %w", destination.Name, err)
+				}
+			}
+			// The tenant guard checks the DNS name of the Service against its exceptions, e.g. *.svc,
+			// which the addresses of its endpoints would not match
+			url, err = ResolveServiceURL(ctx, c, namespace, destination.Webhook.ServiceRef, guard != nil)
+			if err != nil {
+				return nil, err
+			}
+		}
+		compression := ""
+		if destination.Webhook.Compression == v1alpha1.WebhookCompressionGzip {
+			compression = notifier.CompressionGzip
+		}
+		var encryptionKey []byte
+		if destination.Webhook.Encryption != nil {
+			key, err := GetSecretValue(ctx, c, namespace, destination.Webhook.Encryption.PublicKeySecretRef)
+			if err != nil {
+				return nil, err
+			}
+			encryptionKey = []byte(key)
+		}
+		var signer notifier.PayloadSigner
+		if destination.Webhook.Sign {
+			if PayloadSigner == nil {
+				return nil, fmt.Errorf("Destination %s requires signing, which is not configured", destination.Name)
+			}
+			signer = PayloadSigner
+		}
+		return notifier.NewWebhookNotifier(notifier.WebhookOptions{
+			URL:             url,
+			ContentType:     string(destination.Webhook.ContentType),
+			Template:        destination.Webhook.Template,
+			Compression:     compression,
+			MaxPayloadBytes: destination.Webhook.MaxPayloadBytes,
+			EncryptionKey:   encryptionKey,
+			Signer:          signer,
+			ResultFormat:    string(destination.Webhook.ResultFormat),
+			HTTPClient:      httpClient,
+		})
+	}
+	if destination.Slack != nil {
+		token, err := GetSecretValue(ctx, c, namespace, destination.Slack.TokenSecretRef)
+		if err != nil {
+			return nil, err
+		}
+		return notifier.NewSlackNotifier(notifier.SlackOptions{
+			Token:       token,
+			Channel:     destination.Slack.Channel,
+			Template:    destination.Slack.Template,
+			ThreadMode:  string(destination.Slack.ThreadMode),
+			RerunButton: destination.Slack.RerunButton,
+			APIURL:      destination.Slack.APIURL,
+			Locale:      destination.Locale,
+			Styles:      StatusStyles,
+			HTTPClient:  httpClient,
+		})
+	}
+	if destination.Matrix != nil {
+		token, err := GetSecretValue(ctx, c, namespace, destination.Matrix.AccessTokenSecretRef)
+		if err != nil {
+			return nil, err
+		}
+		return notifier.NewMatrixNotifier(notifier.MatrixOptions{
+			HomeserverURL: destination.Matrix.HomeserverURL,
+			AccessToken:   token,
+			RoomID:        destination.Matrix.RoomID,
+			Template:      destination.Matrix.Template,
+			Locale:        destination.Locale,
+			Styles:        StatusStyles,
+			HTTPClient:    httpClient,
+		})
+	}
+	if destination.IRC != nil {
+		opts := notifier.IRCOptions{
+			Server:   destination.IRC.Server,
+			Insecure: destination.IRC.Insecure,
+			Nick:     destination.IRC.Nick,
+			Channel:  destination.IRC.Channel,
+			Template: destination.IRC.Template,
+			Locale:   destination.Locale,
+			Guard:    guard,
+		}
+		if destination.IRC.ChannelKeySecretRef != nil {
+			key, err := GetSecretValue(ctx, c, namespace, *destination.IRC.ChannelKeySecretRef)
+			if err != nil {
+				return nil, err
+			}
+			opts.ChannelKey = key
+		}
+		if destination.IRC.SASL != nil {
+			password, err := GetSecretValue(ctx, c, namespace, destination.IRC.SASL.PasswordSecretRef)
+			if err != nil {
+				return nil, err
+			}
+			opts.SASLUsername, opts.SASLPassword = destination.IRC.SASL.Username, password
+		}
+		return notifier.NewIRCNotifier(opts)
+	}
+	if destination.XMPP != nil {
+		password, err := GetSecretValue(ctx, c, namespace, destination.XMPP.PasswordSecretRef)
+		if err != nil {
+			return nil, err
+		}
+		return notifier.NewXMPPNotifier(notifier.XMPPOptions{
+			JID:      destination.XMPP.JID,
+			Password: password,
+			Server:   destination.XMPP.Server,
+			Rooms:    destination.XMPP.Rooms,
+			Nick:     destination.XMPP.Nick,
+			Insecure: destination.XMPP.Insecure,
+			Template: destination.XMPP.Template,
+			Locale:   destination.Locale,
+			Guard:    guard,
+		})
+	}
+	if destination.WebPush != nil {
+		if WebPushVAPIDKeys == nil {
+			return nil, fmt.Errorf("Destination %s requires web push, which is not configured", destination.Name)
+		}
+		subscriptions, err := GetWebPushSubscriptions(ctx, c, namespace)
+		if err != nil {
+			return nil, err
+		}
+		opts := notifier.WebPushOptions{
+			VAPID:         WebPushVAPIDKeys,
+			Subscriptions: subscriptions,
+			Urgency:       string(destination.WebPush.Urgency),
+			Template:      destination.WebPush.Template,
+			Locale:        destination.Locale,
+			HTTPClient:    httpClient,
+		}
+		if destination.WebPush.TTL != nil {
+			opts.TTL = destination.WebPush.TTL.Duration
+		}
+		return notifier.NewWebPushNotifier(opts)
+	}
+	if destination.FCM != nil {
+		key, err := GetSecretValue(ctx, c, namespace, destination.FCM.ServiceAccountKeySecretRef)
+		if err != nil {
+			return nil, err
+		}
+		opts := notifier.FCMOptions{
+			ServiceAccountKey: []byte(key),
+			ProjectID:         destination.FCM.ProjectID,
+			Topic:             destination.FCM.Topic,
+			Template:          destination.FCM.Template,
+			APIURL:            destination.FCM.APIURL,
+			Locale:            destination.Locale,
+			HTTPClient:        httpClient,
+		}
+		if destination.FCM.DeviceTokensSecretRef != nil {
+			tokens, err := GetSecretValue(ctx, c, namespace, *destination.FCM.DeviceTokensSecretRef)
+			if err != nil {
+				return nil, err
+			}
+			opts.DeviceTokens = strings.Fields(tokens)
+		}
+		return notifier.NewFCMNotifier(opts)
+	}
+	if destination.Alertmanager != nil {
+		opts := notifier.AlertmanagerOptions{
+			URL:        destination.Alertmanager.URL,
+			Labels:     destination.Alertmanager.Labels,
+			Template:   destination.Alertmanager.Template,
+			Locale:     destination.Locale,
+			HTTPClient: httpClient,
+		}
+		if destination.Alertmanager.BearerTokenSecretRef != nil {
+			token, err := GetSecretValue(ctx, c, namespace, *destination.Alertmanager.BearerTokenSecretRef)
+			if err != nil {
+				return nil, err
+			}
+			opts.BearerToken = strings.TrimSpace(token)
+		}
+		if destination.Alertmanager.ResolveAfter != nil {
+			opts.ResolveAfter = destination.Alertmanager.ResolveAfter.Duration
+		}
+		return notifier.NewAlertmanagerNotifier(opts)
+	}
+	return nil, fmt.Errorf("Destination %s has no backend configured", destination.Name)
+}
+
+// GetSecretValue returns the value of the selected key of a Secret
+// Return error if the Secret or the key does not exist
+func GetSecretValue(ctx context.Context, c client.Reader, namespace string, selector corev1.SecretKeySelector) (string, error) {
+	secret := &corev1.Secret{}
+	err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: selector.Name}, secret)
+	if err != nil {
+		return "", fmt.Errorf("Failed to get secret %s/%s: %w", namespace, selector.Name, err)
+	}
+	value, ok := secret.Data[selector.Key]
+	if !ok {
+		return "", fmt.Errorf("Secret %s/%s has no key %s", namespace, selector.Name, selector.Key)
+	}
+	return strings.TrimSpace(string(value)), nil
+}
+
+// DefaultDestinationName identifies the notifier configured with the controller flags
+const DefaultDestinationName string = "default"
+
+// DestinationNotifier is the notifier of a single destination
+type DestinationNotifier struct {
+	// Name identifies the destination as <namespace>/<notificationservice>/<destination>
+	Name string
+	notifier.Notifier
+	// AcknowledgementTimeout is how long the pipelinerun waits for the destination to acknowledge
+	// the notification through its callback URL. Zero means the destination is not waited for.
+	AcknowledgementTimeout time.Duration
+	// NotifyOnStart sends a notification to the destination when the pipelinerun starts
+	NotifyOnStart bool
+	// LongRunningThreshold sends a notification to the destination when the pipelinerun
+	// is still running after it. Zero disables the notification.
+	LongRunningThreshold time.Duration
+	// EscalateAfterFailures restricts the destination to failed pipelineruns whose
+	// failure streak reached it. Zero notifies the destination about every pipelinerun.
+	EscalateAfterFailures int
+	// Paused skips the destination until its NotificationService is resumed
+	Paused bool
+	// PolicyOutcomes restricts the destination to pipelineruns whose policy checks have one of these
+	// outcomes. Empty notifies the destination about every pipelinerun.
+	PolicyOutcomes []string
+	// Events restricts the destination to these events of pipelineruns. Empty notifies the destination
+	// about the end of every pipelinerun and about the lifecycle events enabled above.
+	Events []string
+	// ResultConditions restricts the destination to pipelineruns whose results meet all of them.
+	// Empty notifies the destination about every pipelinerun.
+	ResultConditions []v1alpha1.ResultCondition
+	// MaxConcurrency limits the deliveries to the destination in flight, overriding the throttling policy.
+	// Zero applies the throttling policy.
+	MaxConcurrency int
+	// Ordered delivers the notifications to the destination one at a time, in the order of their events
+	Ordered bool
+	// SLO is the delivery SLO of the NotificationService of the destination, if it has one
+	SLO *v1alpha1.DeliverySLO
+	// NotificationService is the NotificationService declaring the destination, if any
+	NotificationService types.NamespacedName
+}
+
+// GetDestinationNotifiers returns the notifiers of the destinations applying to the pipelineruns of the namespace:
+// the destinations of its NotificationServices, see GetNamespaceNotificationServices, of the ClusterNotificationServices
+// selecting it, except the ones overridden by the NotificationServices, and the default destinations of the controller configuration
+// Destinations that are not valid are skipped and reported in the log
+func GetDestinationNotifiers(ctx context.Context, r *NotificationServiceReconciler, namespace string) ([]DestinationNotifier, error) {
+	notificationServices, err := GetNamespaceNotificationServices(ctx, r, namespace)
+	if err != nil {
+		return nil, err
+	}
+	clusterNotificationServices, err := GetClusterNotificationServices(ctx, r.Client, r.Log, namespace)
+	if err != nil {
+		return nil, err
+	}
+	var notifiers []DestinationNotifier
+	declared := map[string]bool{}
+	for i := range notificationServices {
+		for _, destination := range notificationServices[i].Spec.Destinations {
+			declared[destination.Name] = true
+		}
+		notifiers = append(notifiers, GetNotificationServiceDestinations(ctx, r.Client, r.Log, &notificationServices[i])...)
+	}
+	for i := range clusterNotificationServices {
+		notifiers = append(notifiers, GetClusterDestinations(ctx, r.Client, r.Log, &clusterNotificationServices[i], declared)...)
+	}
+	return append(notifiers, GetConfigDestinations(ctx, r.Client, r.Log, r.ConfigFile.Get())...), nil
+}
+
+// GetNamespaceNotificationServices returns the NotificationServices applying to the pipelineruns of a namespace,
+// which are the NotificationServices of the namespace, or the ones of the default namespace of the reconciler
+// if the namespace has none, so tenants only control the notifications of their own pipelineruns.
+// NotificationServices watching other resources do not apply to pipelineruns.
+// Return error if failed to list the NotificationServices
+func GetNamespaceNotificationServices(ctx context.Context, r *NotificationServiceReconciler, namespace string) ([]v1alpha1.NotificationService, error) {
+	notificationServices := &v1alpha1.NotificationServiceList{}
+	err := r.Client.List(ctx, notificationServices, client.InNamespace(namespace))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list NotificationServices in namespace %s: %w", namespace, err)
+	}
+	items := withoutResourceWatches(notificationServices.Items)
+	if len(items) > 0 || r.DefaultNamespace == "" || r.DefaultNamespace == namespace {
+		return items, nil
+	}
+	err = r.Client.List(ctx, notificationServices, client.InNamespace(r.DefaultNamespace))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list NotificationServices in default namespace %s: %w", r.DefaultNamespace, err)
+	}
+	return withoutResourceWatches(notificationServices.Items), nil
+}
+
+// withoutResourceWatches returns the NotificationServices that do not watch other resources than pipelineruns
+func withoutResourceWatches(notificationServices []v1alpha1.NotificationService) []v1alpha1.NotificationService {
+	var items []v1alpha1.NotificationService
+	for _, notificationService := range notificationServices {
+		if notificationService.Spec.Watch == nil {
+			items = append(items, notificationService)
+		}
+	}
+	return items
+}
+
+// GetConfigDestinations returns the notifiers of the default destinations of the controller configuration,
+// identified as default/<destination>
+// Destinations that are not valid are skipped and reported in the log
+func GetConfigDestinations(ctx context.Context, c client.Reader, logger logr.Logger, config *ControllerConfig) []DestinationNotifier {
+	return newDestinationNotifiers(ctx, c, logger.WithValues("source", "configuration"),
+		config.SecretNamespace, DefaultDestinationName, config.DefaultDestinations)
+}
+
+// newDestinationNotifiers returns the notifiers of destinations declared outside of NotificationServices,
+// identified as <prefix>/<destination>, whose secrets are read from the namespace
+func newDestinationNotifiers(ctx context.Context, c client.Reader, logger logr.Logger, namespace string, prefix string, destinations []v1alpha1.Destination) []DestinationNotifier {
+	var notifiers []DestinationNotifier
+	for _, destination := range destinations {
+		n, err := NewNotifierForDestination(ctx, c, namespace, destination)
+		if err != nil {
+			logger.Error(err, "Skipping invalid destination", "destination", prefix+"/"+destination.Name)
+			continue
+		}
+		destinationNotifier := DestinationNotifier{
+			Name:                  prefix + "/" + destination.Name,
+			Notifier:              n,
+			EscalateAfterFailures: int(destination.EscalateAfterFailures),
+			MaxConcurrency:        int(destination.MaxConcurrency),
+			Ordered:               destination.Ordered,
+			PolicyOutcomes:        policyOutcomes(destination.PolicyOutcomes),
+			Events:                eventTypes(destination.Events),
+			ResultConditions:      destination.ResultConditions,
+		}
+		if destination.AcknowledgementTimeout != nil {
+			destinationNotifier.AcknowledgementTimeout = destination.AcknowledgementTimeout.Duration
+		}
+		notifiers = append(notifiers, destinationNotifier)
+	}
+	return notifiers
+}
+
+// NeedsFinalizer returns a boolean indicating whether pipelineruns have to be held with a finalizer
+// until they are handled, which is the case unless every destination is best-effort.
+// Without destinations, pipelineruns are held unless the reconciler is best-effort.
+// Return error if failed to list the NotificationServices or ClusterNotificationServices applying to the namespace
+func NeedsFinalizer(ctx context.Context, r *NotificationServiceReconciler, namespace string) (bool, error) {
+	if !r.BestEffort && (r.Notifier != nil || r.NamespaceRouting || len(r.ConfigFile.Get().DefaultDestinations) > 0) {
+		return true, nil
+	}
+	notificationServices, err := GetNamespaceNotificationServices(ctx, r, namespace)
+	if err != nil {
+		return false, err
+	}
+	clusterNotificationServices, err := GetClusterNotificationServices(ctx, r.Client, r.Log, namespace)
+	if err != nil {
+		return false, err
+	}
+	if len(notificationServices) == 0 && len(clusterNotificationServices) == 0 {
+		return !r.BestEffort, nil
+	}
+	for i := range notificationServices {
+		if !IsBestEffort(&notificationServices[i], r.BestEffort) {
+			return true, nil
+		}
+	}
+	for i := range clusterNotificationServices {
+		if !isBestEffort(clusterNotificationServices[i].Spec.BestEffort, r.BestEffort) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// IsBestEffort returns a boolean indicating whether the NotificationService is best-effort,
+// defaulting to the best-effort mode of the controller
+func IsBestEffort(notificationService *v1alpha1.NotificationService, defaultBestEffort bool) bool {
+	return isBestEffort(notificationService.Spec.BestEffort, defaultBestEffort)
+}
+
+func policyOutcomes(outcomes []v1alpha1.PolicyOutcome) []string {
+	var converted []string
+	for _, outcome := range outcomes {
+		converted = append(converted, string(outcome))
+	}
+	return converted
+}
+
+func eventTypes(events []v1alpha1.EventType) []string {
+	var converted []string
+	for _, event := range events {
+		converted = append(converted, string(event))
+	}
+	return converted
+}
+
+func isBestEffort(bestEffort *bool, defaultBestEffort bool) bool {
+	if bestEffort == nil {
+		return defaultBestEffort
+	}
+	return *bestEffort
+}
+
+// GetNotificationServiceDestinations returns the notifiers of the destinations of a NotificationService
+// Destinations that are not valid are skipped and reported in the log
+func GetNotificationServiceDestinations(ctx context.Context, c client.Reader, logger logr.Logger, notificationService *v1alpha1.NotificationService) []DestinationNotifier {
+	var notifiers []DestinationNotifier
+	for _, destination := range notificationService.Spec.Destinations {
+		destination = InheritDefaultTemplate(destination, &notificationService.Spec)
+		n, err := NewTenantNotifierForDestination(ctx, c, notificationService.Namespace, destination)
+		if err != nil {
+			logger.Error(err, "Skipping invalid destination", "notificationService", notificationService.Name,
+				"namespace", notificationService.Namespace, "destination", destination.Name)
+			continue
+		}
+		destinationNotifier := DestinationNotifier{
+			Name:                  notificationService.Namespace + "/" + notificationService.Name + "/" + destination.Name,
+			Notifier:              n,
+			NotifyOnStart:         notificationService.Spec.NotifyOnStart,
+			EscalateAfterFailures: int(destination.EscalateAfterFailures),
+			MaxConcurrency:        int(destination.MaxConcurrency),
+			Ordered:               destination.Ordered,
+			PolicyOutcomes:        policyOutcomes(destination.PolicyOutcomes),
+			Events:                eventTypes(destination.Events),
+			ResultConditions:      destination.ResultConditions,
+			Paused:                notificationService.Spec.Paused,
+			SLO:                   notificationService.Spec.SLO,
+			NotificationService:   client.ObjectKeyFromObject(notificationService),
+		}
+		if notificationService.Spec.LongRunningThreshold != nil {
+			destinationNotifier.LongRunningThreshold = notificationService.Spec.LongRunningThreshold.Duration
+		}
+		if destination.AcknowledgementTimeout != nil {
+			destinationNotifier.AcknowledgementTimeout = destination.AcknowledgementTimeout.Duration
+		}
+		notifiers = append(notifiers, destinationNotifier)
+	}
+	return notifiers
+}
+
+// FilterEventDestinations removes the destinations restricted to events the notification is not sent for,
+// neither as its event nor as its subtype
+func FilterEventDestinations(destinations []DestinationNotifier, notification *notifier.Notification) []DestinationNotifier {
+	var filtered []DestinationNotifier
+	for _, destination := range destinations {
+		if !destination.subscribes(notification.Event) &&
+			(notification.Subtype == "" || !destination.subscribes(notification.Subtype)) {
+			continue
+		}
+		filtered = append(filtered, destination)
+	}
+	return filtered
+}
+
+// subscribes returns a boolean indicating whether the destination is notified about the event of
+// pipelineruns, not counting the lifecycle events it did not list but enabled through its NotificationService
+func (d *DestinationNotifier) subscribes(event string) bool {
+	if len(d.Events) == 0 {
+		return event != notifier.EventStarted && event != notifier.EventRunning
+	}
+	return slices.Contains(d.Events, event)
+}
+
+// FilterEscalationDestinations removes the escalation destinations whose failure streak
+// was not reached by the notification
+func FilterEscalationDestinations(destinations []DestinationNotifier, notification *notifier.Notification) []DestinationNotifier {
+	var filtered []DestinationNotifier
+	for _, destination := range destinations {
+		if destination.EscalateAfterFailures > 0 &&
+			(notification.Status != notifier.StatusFailed || notification.FailureStreak < destination.EscalateAfterFailures) {
+			continue
+		}
+		filtered = append(filtered, destination)
+	}
+	return filtered
+}
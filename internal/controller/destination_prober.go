@@ -0,0 +1,254 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DestinationHealthPath is the path of the endpoint reporting the latest destination probes
+const DestinationHealthPath string = "/healthz/destinations"
+
+// DestinationsReachableCondition reports whether the destinations of a NotificationService answered their last probe
+const DestinationsReachableCondition string = "DestinationsReachable"
+
+// DefaultDestinationProbeTimeout bounds a single destination probe
+const DefaultDestinationProbeTimeout = 10 * time.Second
+
+// DestinationHealth is the outcome of the latest probe of a destination
+type DestinationHealth struct {
+	Destination   string    `json:"destination"`
+	Reachable     bool      `json:"reachable"`
+	Error         string    `json:"error,omitempty"`
+	LastProbeTime time.Time `json:"lastProbeTime"`
+}
+
+// DestinationProber periodically checks that the destinations of the NotificationServices and the
+// default notifier are reachable, without notifying them. Only destinations supporting probes, e.g.
+// webhooks (HEAD request) and Slack (token check), are probed. The outcomes are exported as metrics,
+// served at DestinationHealthPath and, by the leader, set as the DestinationsReachable condition
+// of the NotificationServices.
+type DestinationProber struct {
+	Client client.Client
+	Log    logr.Logger
+	// Notifier is the default notifier, probed as the default destination if set
+	Notifier notifier.Notifier
+	// Interval is how often destinations are probed
+	Interval time.Duration
+	// Timeout bounds a single probe
+	Timeout time.Duration
+	// BindAddress is the address DestinationHealthPath is served on, if set
+	BindAddress string
+	// Elected is closed once this replica is the leader, conditions are only updated afterwards
+	Elected <-chan struct{}
+
+	mu     sync.RWMutex
+	health []DestinationHealth
+}
+
+// NeedLeaderElection returns false so every replica probes destinations and serves their health
+func (p *DestinationProber) NeedLeaderElection() bool {
+	return false
+}
+
+// Start probes the destinations every interval, and serves their health, until the context is cancelled
+func (p *DestinationProber) Start(ctx context.Context) error {
+	if p.BindAddress != "" {
+		server := &http.Server{Addr: p.BindAddress, Handler: p, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			_ = server.Shutdown(shutdownCtx)
+		}()
+		go func() {
+			p.Log.Info("Serving destination health", "address", p.BindAddress)
+			err := server.ListenAndServe()
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				p.Log.Error(err, "Failed to serve destination health")
+			}
+		}()
+	}
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+	for {
+		err := p.RunOnce(ctx)
+		if err != nil {
+			p.Log.Error(err, "Failed to probe destinations")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce probes every destination and records the outcomes
+func (p *DestinationProber) RunOnce(ctx context.Context) error {
+	notificationServices := &v1alpha1.NotificationServiceList{}
+	err := p.Client.List(ctx, notificationServices)
+	if err != nil {
+		return fmt.Errorf("Failed to list NotificationServices: %w", err)
+	}
+	var health []DestinationHealth
+	if p.Notifier != nil {
+		if result, probed := p.probe(ctx, DestinationNotifier{Name: DefaultDestinationName, Notifier: p.Notifier}); probed {
+			health = append(health, result)
+		}
+	}
+	var errs []error
+	for i := range notificationServices.Items {
+		notificationService := &notificationServices.Items[i]
+		var serviceHealth []DestinationHealth
+		for _, destination := range GetNotificationServiceDestinations(ctx, p.Client, p.Log, notificationService) {
+			if result, probed := p.probe(ctx, destination); probed {
+				serviceHealth = append(serviceHealth, result)
+			}
+		}
+		health = append(health, serviceHealth...)
+		if p.isLeader() {
+			err = p.setCondition(ctx, notificationService, serviceHealth)
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	p.mu.Lock()
+	previous := p.health
+	p.health = health
+	p.mu.Unlock()
+	// Destinations that were removed or renamed, or whose NotificationService was deleted, are no longer
+	// probed and their series are deleted so they do not report a stale reachability
+	for _, result := range previous {
+		if !slices.ContainsFunc(health, func(current DestinationHealth) bool {
+			return current.Destination == result.Destination
+		}) {
+			destinationReachable.DeleteLabelValues(result.Destination)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Health returns the outcomes of the latest probes
+func (p *DestinationProber) Health() []DestinationHealth {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return append([]DestinationHealth{}, p.health...)
+}
+
+// ServeHTTP serves the latest probes as JSON, with status 503 if a destination is unreachable
+func (p *DestinationProber) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != DestinationHealthPath {
+		http.NotFound(w, req)
+		return
+	}
+	health := p.Health()
+	status := http.StatusOK
+	for _, result := range health {
+		if !result.Reachable {
+			status = http.StatusServiceUnavailable
+		}
+	}
+	writeJSON(w, status, health)
+}
+
+func (p *DestinationProber) probe(ctx context.Context, destination DestinationNotifier) (DestinationHealth, bool) {
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = DefaultDestinationProbeTimeout
+	}
+	probeCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	probed, err := notifier.Probe(probeCtx, destination.Notifier)
+	if !probed {
+		return DestinationHealth{}, false
+	}
+	result := DestinationHealth{Destination: destination.Name, Reachable: err == nil, LastProbeTime: time.Now().UTC()}
+	reachable := 1.0
+	if err != nil {
+		result.Error = err.Error()
+		reachable = 0
+		p.Log.Info("Destination is unreachable", "destination", destination.Name, "error", err.Error())
+	}
+	destinationReachable.WithLabelValues(destination.Name).Set(reachable)
+	return result, true
+}
+
+// setCondition sets the DestinationsReachable condition of the NotificationService from the probes of its destinations
+func (p *DestinationProber) setCondition(ctx context.Context, notificationService *v1alpha1.NotificationService, health []DestinationHealth) error {
+	if len(health) == 0 {
+		return nil
+	}
+	var unreachable []string
+	for _, result := range health {
+		if !result.Reachable {
+			unreachable = append(unreachable, strings.TrimPrefix(result.Destination,
+				notificationService.Namespace+"/"+notificationService.Name+"/")+": "+result.Error)
+		}
+	}
+	sort.Strings(unreachable)
+	condition := metav1.Condition{
+		Type:               DestinationsReachableCondition,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Reachable",
+		Message:            "All probed destinations are reachable",
+		ObservedGeneration: notificationService.Generation,
+	}
+	if len(unreachable) > 0 {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "Unreachable"
+		condition.Message = strings.Join(unreachable, "; ")
+	}
+	patch := client.MergeFrom(notificationService.DeepCopy())
+	if !meta.SetStatusCondition(&notificationService.Status.Conditions, condition) {
+		return nil
+	}
+	err := p.Client.Status().Patch(ctx, notificationService, patch)
+	if err != nil {
+		return fmt.Errorf("Failed to set the %s condition of NotificationService %s/%s: %w",
+			DestinationsReachableCondition, notificationService.Namespace, notificationService.Name, err)
+	}
+	return nil
+}
+
+func (p *DestinationProber) isLeader() bool {
+	if p.Elected == nil {
+		return true
+	}
+	select {
+	case <-p.Elected:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("Destination prober", func() {
+	It("should report unreachable destinations in conditions, metrics and the health endpoint", func() {
+		reachable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+		DeferCleanup(reachable.Close)
+		unreachable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		DeferCleanup(unreachable.Close)
+		notificationService := &v1alpha1.NotificationService{
+			ObjectMeta: metav1.ObjectMeta{Name: "probed", Namespace: "default"},
+			Spec: v1alpha1.NotificationServiceSpec{
+				Destinations: []v1alpha1.Destination{
+					{Name: "up", Webhook: &v1alpha1.WebhookDestination{URL: reachable.URL}},
+					{Name: "down", Webhook: &v1alpha1.WebhookDestination{URL: unreachable.URL}},
+				},
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), notificationService)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), notificationService)
+
+		prober := &DestinationProber{Client: k8sClient}
+		Expect(prober.RunOnce(context.Background())).To(Succeed())
+		Expect(testutil.ToFloat64(destinationReachable.WithLabelValues("default/probed/up"))).To(Equal(1.0))
+		Expect(testutil.ToFloat64(destinationReachable.WithLabelValues("default/probed/down"))).To(Equal(0.0))
+
+		Expect(k8sClient.Get(context.Background(), types.NamespacedName{Name: "probed", Namespace: "default"}, notificationService)).To(Succeed())
+		condition := meta.FindStatusCondition(notificationService.Status.Conditions, DestinationsReachableCondition)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal("Unreachable"))
+		Expect(condition.Message).To(HavePrefix("down: "))
+
+		recorder := httptest.NewRecorder()
+		prober.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, DestinationHealthPath, nil))
+		Expect(recorder.Code).To(Equal(http.StatusServiceUnavailable))
+		var health []DestinationHealth
+		Expect(json.NewDecoder(recorder.Body).Decode(&health)).To(Succeed())
+		Expect(health).To(ConsistOf(
+			HaveField("Destination", "default/probed/up"),
+			HaveField("Destination", "default/probed/down"),
+		))
+	})
+
+	It("should delete the metrics of destinations that no longer exist", func() {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+		DeferCleanup(upstream.Close)
+		notificationService := &v1alpha1.NotificationService{
+			ObjectMeta: metav1.ObjectMeta{Name: "probed-removed", Namespace: "default"},
+			Spec: v1alpha1.NotificationServiceSpec{
+				Destinations: []v1alpha1.Destination{
+					{Name: "kept", Webhook: &v1alpha1.WebhookDestination{URL: upstream.URL}},
+					{Name: "removed", Webhook: &v1alpha1.WebhookDestination{URL: upstream.URL}},
+				},
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), notificationService)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), notificationService)
+
+		prober := &DestinationProber{Client: k8sClient}
+		Expect(prober.RunOnce(context.Background())).To(Succeed())
+		Expect(testutil.ToFloat64(destinationReachable.WithLabelValues("default/probed-removed/removed"))).To(Equal(1.0))
+
+		Expect(k8sClient.Get(context.Background(), types.NamespacedName{Name: "probed-removed", Namespace: "default"}, notificationService)).To(Succeed())
+		notificationService.Spec.Destinations = notificationService.Spec.Destinations[:1]
+		Expect(k8sClient.Update(context.Background(), notificationService)).To(Succeed())
+		Expect(prober.RunOnce(context.Background())).To(Succeed())
+		// DeleteLabelValues reports whether the series still existed
+		Expect(destinationReachable.DeleteLabelValues("default/probed-removed/removed")).To(BeFalse())
+		Expect(testutil.ToFloat64(destinationReachable.WithLabelValues("default/probed-removed/kept"))).To(Equal(1.0))
+	})
+
+	It("should not update conditions before being elected", func() {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+		DeferCleanup(upstream.Close)
+		notificationService := &v1alpha1.NotificationService{
+			ObjectMeta: metav1.ObjectMeta{Name: "probed-follower", Namespace: "default"},
+			Spec: v1alpha1.NotificationServiceSpec{
+				Destinations: []v1alpha1.Destination{{Name: "up", Webhook: &v1alpha1.WebhookDestination{URL: upstream.URL}}},
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), notificationService)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), notificationService)
+
+		prober := &DestinationProber{Client: k8sClient, Elected: make(chan struct{})}
+		Expect(prober.RunOnce(context.Background())).To(Succeed())
+		Expect(prober.Health()).To(ContainElement(HaveField("Destination", "default/probed-follower/up")))
+		Expect(k8sClient.Get(context.Background(), types.NamespacedName{Name: "probed-follower", Namespace: "default"}, notificationService)).To(Succeed())
+		Expect(meta.FindStatusCondition(notificationService.Status.Conditions, DestinationsReachableCondition)).To(BeNil())
+	})
+})
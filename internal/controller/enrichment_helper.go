@@ -0,0 +1,36 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+)
+
+// pluginHookName is the hook label of the metrics of the in-process Enrichers
+const pluginHookName string = "plugin"
+
+// enrich adds the values of the in-process Enrichers, then of the enrichment hooks of the configuration file,
+// to the notification. Failed enrichers are logged and counted, and the notification is sent without their values.
+func (r *NotificationServiceReconciler) enrich(ctx context.Context, notification *notifier.Notification) {
+	for _, enricher := range r.Enrichers {
+		err := notifier.Enrich(ctx, notification, enricher)
+		if err != nil {
+			enrichmentFailures.WithLabelValues(pluginHookName).Inc()
+			r.Log.Error(err, "Failed to enrich notification", "hook", pluginHookName)
+		}
+	}
+	for _, hook := range r.ConfigFile.Get().Enrichment {
+		opts := notifier.EnrichmentOptions{URL: hook.URL}
+		if hook.Timeout != nil {
+			opts.Timeout = hook.Timeout.Duration
+		}
+		enricher, err := notifier.NewWebhookEnricher(opts)
+		if err == nil {
+			err = notifier.Enrich(ctx, notification, enricher)
+		}
+		if err != nil {
+			enrichmentFailures.WithLabelValues(hook.Name).Inc()
+			r.Log.Error(err, "Failed to enrich notification", "hook", hook.Name)
+		}
+	}
+}
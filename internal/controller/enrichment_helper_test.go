@@ -0,0 +1,66 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("Notification enrichment", func() {
+	It("should add the values of the plugins and hooks to notifications", func() {
+		hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`{"owner":"build-team"}`))
+		}))
+		DeferCleanup(hook.Close)
+		path := filepath.Join(GinkgoT().TempDir(), "config.yaml")
+		config := "enrichment:\n- name: cmdb\n  url: " + hook.URL + "\n- name: down\n  url: http://127.0.0.1:1\n"
+		Expect(os.WriteFile(path, []byte(config), 0o600)).To(Succeed())
+		file, err := NewConfigFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		plugin := notifier.EnricherFunc(func(_ context.Context, notification *notifier.Notification) (map[string]any, error) {
+			return map[string]any{"ticket": "BUILD-" + notification.PipelineRun}, nil
+		})
+		fake := &fakeNotifier{}
+		r := &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Notifier: fake,
+			ConfigFile: file, Enrichers: []notifier.Enricher{plugin}}
+		failures := testutil.ToFloat64(enrichmentFailures.WithLabelValues("down"))
+
+		pipelineRun := createPipelineRun("enriched", corev1.ConditionTrue)
+		Expect(reconcilePipelineRun(r, pipelineRun)).To(Succeed())
+		Expect(fake.notifications).To(HaveLen(1))
+		Expect(fake.notifications[0].Enrichment).To(Equal(map[string]any{"ticket": "BUILD-enriched", "owner": "build-team"}))
+		Expect(testutil.ToFloat64(enrichmentFailures.WithLabelValues("down"))).To(Equal(failures + 1))
+	})
+
+	It("should reject hooks without a name or an absolute URL", func() {
+		_, err := ParseControllerConfig([]byte("enrichment:\n- url: https://cmdb.example.com\n"))
+		Expect(err).To(HaveOccurred())
+		_, err = ParseControllerConfig([]byte("enrichment:\n- name: cmdb\n  url: cmdb.example.com\n"))
+		Expect(err).To(HaveOccurred())
+	})
+})
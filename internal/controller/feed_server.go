@@ -0,0 +1,212 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/apis"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// FeedPath serves the Atom feeds as /feeds/<namespace> and /feeds/<namespace>/<pipeline>
+const FeedPath string = "/feeds/"
+
+// NamespaceFeedAnnotation publishes the feed of a namespace when set to "true" on the namespace
+const NamespaceFeedAnnotation string = "konflux.ci/feed"
+
+// DefaultFeedEntries is the maximum number of PipelineRuns in a feed
+const DefaultFeedEntries int = 50
+
+// FeedServer serves Atom feeds of the outcomes of the recent PipelineRuns of the namespaces
+// that published their feed
+type FeedServer struct {
+	Client client.Reader
+	Log    logr.Logger
+	// BindAddress is the address the feed endpoint listens on
+	BindAddress string
+	// Entries is the maximum number of PipelineRuns in a feed, defaults to DefaultFeedEntries
+	Entries int
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID       string       `xml:"id"`
+	Title    string       `xml:"title"`
+	Updated  string       `xml:"updated"`
+	Category atomCategory `xml:"category"`
+	Summary  string       `xml:"summary"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// NeedLeaderElection returns false so every replica serves the feeds
+func (s *FeedServer) NeedLeaderElection() bool {
+	return false
+}
+
+// Start serves the feed endpoint until the context is cancelled
+func (s *FeedServer) Start(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.Handle(FeedPath, s)
+	server := &http.Server{
+		Addr:              s.BindAddress,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+	s.Log.Info("Serving feeds", "address", s.BindAddress)
+	err := server.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// ServeHTTP serves the feed of the namespace, or of a single pipeline, in the request path
+func (s *FeedServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	namespace, pipeline, _ := strings.Cut(strings.Trim(strings.TrimPrefix(req.URL.Path, FeedPath), "/"), "/")
+	if namespace == "" || strings.Contains(pipeline, "/") {
+		http.Error(w, "feed not found", http.StatusNotFound)
+		return
+	}
+	feed, err := s.buildFeed(req.Context(), namespace, pipeline)
+	if k8serrors.IsNotFound(err) {
+		http.Error(w, "feed not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.Log.Error(err, "Failed to build feed", "namespace", namespace, "pipeline", pipeline)
+		http.Error(w, "failed to build feed", http.StatusInternalServerError)
+		return
+	}
+	body, err := xml.Marshal(feed)
+	if err != nil {
+		s.Log.Error(err, "Failed to encode feed", "namespace", namespace, "pipeline", pipeline)
+		http.Error(w, "failed to encode feed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	_, _ = w.Write(append([]byte(xml.Header), body...))
+}
+
+// buildFeed builds the feed of the latest completed PipelineRuns of the namespace, restricted to
+// the pipeline if it is not empty. A NotFound error is returned if the namespace did not publish its feed.
+func (s *FeedServer) buildFeed(ctx context.Context, namespace string, pipeline string) (*atomFeed, error) {
+	ns := &corev1.Namespace{}
+	err := s.Client.Get(ctx, types.NamespacedName{Name: namespace}, ns)
+	if err != nil {
+		return nil, err
+	}
+	if ns.Annotations[NamespaceFeedAnnotation] != "true" {
+		return nil, k8serrors.NewNotFound(corev1.Resource("namespaces"), namespace)
+	}
+	pipelineRuns := &tektonv1.PipelineRunList{}
+	err = s.Client.List(ctx, pipelineRuns, client.InNamespace(namespace))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list pipelineruns in %s: %w", namespace, err)
+	}
+
+	var completed []*tektonv1.PipelineRun
+	for i := range pipelineRuns.Items {
+		pipelineRun := &pipelineRuns.Items[i]
+		if !IsPipelineRunEnded(pipelineRun) || pipelineRun.Status.CompletionTime == nil {
+			continue
+		}
+		if pipeline != "" && PipelineName(pipelineRun) != pipeline {
+			continue
+		}
+		completed = append(completed, pipelineRun)
+	}
+	sort.Slice(completed, func(i, j int) bool {
+		return completed[j].Status.CompletionTime.Before(completed[i].Status.CompletionTime)
+	})
+	entries := s.Entries
+	if entries <= 0 {
+		entries = DefaultFeedEntries
+	}
+	completed = completed[:min(len(completed), entries)]
+
+	feed := &atomFeed{
+		ID:      "urn:konflux-ci:feed:" + namespace,
+		Title:   "PipelineRuns in " + namespace,
+		Updated: ns.CreationTimestamp.UTC().Format(time.RFC3339),
+		Author:  atomAuthor{Name: "notification-service"},
+		Entries: []atomEntry{},
+	}
+	if pipeline != "" {
+		feed.ID += ":" + pipeline
+		feed.Title = "Pipeline " + pipeline + " in " + namespace
+	}
+	for _, pipelineRun := range completed {
+		status := GetPipelineRunStatus(pipelineRun)
+		summary := fmt.Sprintf("PipelineRun %s %s", pipelineRun.Name, strings.ToLower(status))
+		if pipelineRun.Status.StartTime != nil {
+			duration := pipelineRun.Status.CompletionTime.Sub(pipelineRun.Status.StartTime.Time).Round(time.Second)
+			summary += fmt.Sprintf(" after %s", duration)
+		}
+		if condition := pipelineRun.Status.GetCondition(apis.ConditionSucceeded); condition != nil && condition.Message != "" {
+			summary += ": " + condition.Message
+		}
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:       "urn:uuid:" + string(pipelineRun.UID),
+			Title:    status + ": " + pipelineRun.Name,
+			Updated:  pipelineRun.Status.CompletionTime.UTC().Format(time.RFC3339),
+			Category: atomCategory{Term: status},
+			Summary:  summary,
+		})
+	}
+	if len(feed.Entries) > 0 {
+		feed.Updated = feed.Entries[0].Updated
+	}
+	return feed, nil
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("Feed server", func() {
+	now := time.Now().UTC().Truncate(time.Second)
+	var server *FeedServer
+
+	// createFeedPipelineRun creates a pipelinerun of the pipeline that completed at the given time
+	createFeedPipelineRun := func(namespace string, name string, pipeline string, succeeded corev1.ConditionStatus, completion time.Time) {
+		pipelineRun := &tektonv1.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec:       tektonv1.PipelineRunSpec{PipelineRef: &tektonv1.PipelineRef{Name: pipeline}},
+		}
+		Expect(k8sClient.Create(context.Background(), pipelineRun)).To(Succeed())
+		pipelineRun.Status.Conditions = duckv1.Conditions{{Type: apis.ConditionSucceeded, Status: succeeded, Message: "Tasks Completed: 1"}}
+		pipelineRun.Status.StartTime = &metav1.Time{Time: completion.Add(-time.Minute)}
+		pipelineRun.Status.CompletionTime = &metav1.Time{Time: completion}
+		Expect(k8sClient.Status().Update(context.Background(), pipelineRun)).To(Succeed())
+	}
+
+	get := func(path string) (*httptest.ResponseRecorder, *atomFeed) {
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		feed := &atomFeed{}
+		if recorder.Code == http.StatusOK {
+			Expect(xml.Unmarshal(recorder.Body.Bytes(), feed)).To(Succeed())
+		}
+		return recorder, feed
+	}
+
+	created := false
+	BeforeEach(func() {
+		server = &FeedServer{Client: k8sClient, Log: ctrl.Log.WithName("feed"), Entries: 2}
+		if created {
+			return
+		}
+		created = true
+		for name, annotations := range map[string]map[string]string{
+			"feed-published": {NamespaceFeedAnnotation: "true"},
+			"feed-private":   nil,
+		} {
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: annotations}}
+			Expect(k8sClient.Create(context.Background(), ns)).To(Succeed())
+			createFeedPipelineRun(name, "build-1", "build", corev1.ConditionTrue, now.Add(-3*time.Hour))
+			createFeedPipelineRun(name, "build-2", "build", corev1.ConditionFalse, now.Add(-time.Hour))
+			createFeedPipelineRun(name, "test-1", "test", corev1.ConditionTrue, now.Add(-2*time.Hour))
+		}
+	})
+
+	It("should serve the latest pipelineruns of the namespace", func() {
+		recorder, feed := get("/feeds/feed-published")
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Content-Type")).To(HavePrefix("application/atom+xml"))
+		Expect(feed.Title).To(Equal("PipelineRuns in feed-published"))
+		Expect(feed.Updated).To(Equal(now.Add(-time.Hour).Format(time.RFC3339)))
+		Expect(feed.Entries).To(HaveLen(2))
+		Expect(feed.Entries[0].Title).To(Equal("Failed: build-2"))
+		Expect(feed.Entries[0].Summary).To(Equal("PipelineRun build-2 failed after 1m0s: Tasks Completed: 1"))
+		Expect(feed.Entries[1].Title).To(Equal("Succeeded: test-1"))
+	})
+
+	It("should serve the pipelineruns of a pipeline", func() {
+		recorder, feed := get("/feeds/feed-published/build")
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(feed.Title).To(Equal("Pipeline build in feed-published"))
+		Expect(feed.Entries).To(HaveLen(2))
+		Expect(feed.Entries[0].Title).To(Equal("Failed: build-2"))
+		Expect(feed.Entries[1].Title).To(Equal("Succeeded: build-1"))
+	})
+
+	It("should not serve the feeds of namespaces that did not publish them", func() {
+		recorder, _ := get("/feeds/feed-private")
+		Expect(recorder.Code).To(Equal(http.StatusNotFound))
+		recorder, _ = get("/feeds/feed-missing")
+		Expect(recorder.Code).To(Equal(http.StatusNotFound))
+	})
+})
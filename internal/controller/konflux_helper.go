@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Labels set by Konflux on the pipelineruns of integration tests and releases, and on Releases
+const (
+	KonfluxApplicationLabel      string = "appstudio.openshift.io/application"
+	KonfluxComponentLabel        string = "appstudio.openshift.io/component"
+	KonfluxSnapshotLabel         string = "appstudio.openshift.io/snapshot"
+	KonfluxEnvironmentLabel      string = "appstudio.openshift.io/environment"
+	KonfluxScenarioLabel         string = "test.appstudio.openshift.io/scenario"
+	KonfluxReleaseNameLabel      string = "release.appstudio.openshift.io/name"
+	KonfluxReleaseNamespaceLabel string = "release.appstudio.openshift.io/namespace"
+)
+
+// GetKonfluxContext returns the Konflux context of an integration test or release pipelinerun, read from its
+// labels, or nil if the pipelinerun is neither
+func GetKonfluxContext(pipelineRun client.Object) *notifier.KonfluxContext {
+	labels := pipelineRun.GetLabels()
+	scenario := labels[KonfluxScenarioLabel]
+	release := labels[KonfluxReleaseNameLabel]
+	if scenario == "" && release == "" {
+		return nil
+	}
+	if release != "" && labels[KonfluxReleaseNamespaceLabel] != "" {
+		release = labels[KonfluxReleaseNamespaceLabel] + "/" + release
+	}
+	return &notifier.KonfluxContext{
+		Application: labels[KonfluxApplicationLabel],
+		Component:   labels[KonfluxComponentLabel],
+		Snapshot:    labels[KonfluxSnapshotLabel],
+		Scenario:    scenario,
+		Release:     release,
+		Environment: labels[KonfluxEnvironmentLabel],
+	}
+}
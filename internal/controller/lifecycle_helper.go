@@ -0,0 +1,32 @@
+package controller
+
+import (
+	"context"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NotificationLifecycleAnnotation holds a JSON map from destination to the last status
+// it was notified about while the pipelinerun was running
+var NotificationLifecycleAnnotation = DefaultMarkerPrefix + "/lifecycle-notifications"
+
+// PipelineRunLongRunningReason is the reason of the warning event of pipelineruns running longer than a threshold
+const PipelineRunLongRunningReason string = "PipelineRunLongRunning"
+
+// GetLifecycleNotifications returns the last status each destination was notified about while the pipelineRun was running
+// Return error if the annotation is malformed
+func GetLifecycleNotifications(pipelineRun *tektonv1.PipelineRun) (map[string]string, error) {
+	sent := map[string]string{}
+	err := getJSONAnnotation(pipelineRun, NotificationLifecycleAnnotation, &sent)
+	if err != nil {
+		return map[string]string{}, err
+	}
+	return sent, nil
+}
+
+// SetLifecycleNotifications stores the last status each destination was notified about in the pipelineRun
+// If the annotation was not updated successfully, a non-nil error is returned.
+func SetLifecycleNotifications(ctx context.Context, pipelineRun *tektonv1.PipelineRun, c client.Client, sent map[string]string) error {
+	return setJSONAnnotation(ctx, pipelineRun, c, NotificationLifecycleAnnotation, sent)
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Lifecycle notifications", func() {
+	var (
+		statuses []string
+		recorder *record.FakeRecorder
+		r        *NotificationServiceReconciler
+	)
+
+	// startPipelineRun creates a running pipelinerun that started the given time ago
+	startPipelineRun := func(name string, ago time.Duration) *tektonv1.PipelineRun {
+		pipelineRun := createPipelineRun(name, corev1.ConditionUnknown)
+		pipelineRun.Status.StartTime = &metav1.Time{Time: time.Now().Add(-ago)}
+		Expect(k8sClient.Status().Update(context.Background(), pipelineRun)).To(Succeed())
+		return pipelineRun
+	}
+
+	reconcile := func(pipelineRun *tektonv1.PipelineRun) ctrl.Result {
+		result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pipelineRun)})
+		Expect(err).NotTo(HaveOccurred())
+		return result
+	}
+
+	BeforeEach(func() {
+		statuses = nil
+		recorder = record.NewFakeRecorder(10)
+		r = &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Recorder: recorder}
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			notification := &notifier.Notification{}
+			Expect(json.NewDecoder(req.Body).Decode(notification)).To(Succeed())
+			statuses = append(statuses, notification.Status)
+		}))
+		DeferCleanup(receiver.Close)
+		notificationService := &v1alpha1.NotificationService{
+			ObjectMeta: metav1.ObjectMeta{Name: "lifecycle", Namespace: "default"},
+			Spec: v1alpha1.NotificationServiceSpec{
+				Destinations:         []v1alpha1.Destination{{Name: "hook", Webhook: &v1alpha1.WebhookDestination{URL: receiver.URL}}},
+				NotifyOnStart:        true,
+				LongRunningThreshold: &metav1.Duration{Duration: time.Hour},
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), notificationService)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), notificationService)
+	})
+
+	It("should notify once when a pipelinerun starts", func() {
+		pipelineRun := startPipelineRun("started", time.Minute)
+		result := reconcile(pipelineRun)
+		Expect(statuses).To(Equal([]string{notifier.StatusStarted}))
+		Expect(result.RequeueAfter).To(BeNumerically("~", 59*time.Minute, time.Minute))
+
+		reconcile(pipelineRun)
+		Expect(statuses).To(HaveLen(1))
+		Expect(getPipelineRun(pipelineRun).Annotations).To(HaveKeyWithValue(NotificationLifecycleAnnotation,
+			`{"default/lifecycle/hook":"Started"}`))
+	})
+
+	It("should warn about pipelineruns running longer than the threshold", func() {
+		pipelineRun := startPipelineRun("stuck", 2*time.Hour)
+		Expect(reconcile(pipelineRun).RequeueAfter).To(BeZero())
+		Expect(statuses).To(Equal([]string{notifier.StatusStarted, notifier.StatusRunning}))
+		var events []string
+		for len(recorder.Events) > 0 {
+			events = append(events, <-recorder.Events)
+		}
+		Expect(events).To(ContainElement("Warning PipelineRunLongRunning PipelineRun is still running after 1h0m0s"))
+
+		reconcile(pipelineRun)
+		Expect(statuses).To(HaveLen(2))
+	})
+
+	It("should not send lifecycle notifications for pipelineruns that did not start", func() {
+		reconcile(createPipelineRun("pending", ""))
+		Expect(statuses).To(BeEmpty())
+	})
+})
+
+var _ = Describe("Event subscriptions", func() {
+	var (
+		events   map[string][]string
+		subtypes map[string][]string
+		r        *NotificationServiceReconciler
+	)
+
+	BeforeEach(func() {
+		events = map[string][]string{}
+		subtypes = map[string][]string{}
+		r = &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			notification := &notifier.Notification{}
+			Expect(json.NewDecoder(req.Body).Decode(notification)).To(Succeed())
+			events[req.URL.Path] = append(events[req.URL.Path], notification.Event)
+			if notification.Subtype != "" {
+				subtypes[req.URL.Path] = append(subtypes[req.URL.Path], notification.Subtype)
+			}
+		}))
+		DeferCleanup(receiver.Close)
+		notificationService := &v1alpha1.NotificationService{
+			ObjectMeta: metav1.ObjectMeta{Name: "events", Namespace: "default"},
+			Spec: v1alpha1.NotificationServiceSpec{
+				Destinations: []v1alpha1.Destination{
+					{Name: "audit", Webhook: &v1alpha1.WebhookDestination{URL: receiver.URL + "/audit"}, Events: []v1alpha1.EventType{
+						v1alpha1.EventTypeStarted, v1alpha1.EventTypeSucceeded, v1alpha1.EventTypeFailed, v1alpha1.EventTypeCancelled,
+					}},
+					{Name: "failures", Webhook: &v1alpha1.WebhookDestination{URL: receiver.URL + "/failures"},
+						Events: []v1alpha1.EventType{v1alpha1.EventTypeFailed}},
+					{Name: "retries", Webhook: &v1alpha1.WebhookDestination{URL: receiver.URL + "/retries"},
+						Events: []v1alpha1.EventType{v1alpha1.EventTypeSucceededWithRetries}},
+					{Name: "default", Webhook: &v1alpha1.WebhookDestination{URL: receiver.URL + "/default"}},
+				},
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), notificationService)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), notificationService)
+	})
+
+	It("should only notify destinations about the events they subscribe to", func() {
+		pipelineRun := createPipelineRun("subscribed", corev1.ConditionUnknown)
+		pipelineRun.Status.StartTime = &metav1.Time{Time: time.Now()}
+		Expect(k8sClient.Status().Update(context.Background(), pipelineRun)).To(Succeed())
+		Expect(reconcilePipelineRun(r, pipelineRun)).To(Succeed())
+		Expect(events).To(Equal(map[string][]string{"/audit": {notifier.EventStarted}}))
+
+		pipelineRun = getPipelineRun(pipelineRun)
+		pipelineRun.Status.MarkFailed(tektonv1.PipelineRunReasonCancelled.String(), "PipelineRun was cancelled")
+		Expect(k8sClient.Status().Update(context.Background(), pipelineRun)).To(Succeed())
+		Expect(reconcilePipelineRun(r, pipelineRun)).To(Succeed())
+		Expect(events).To(Equal(map[string][]string{
+			"/audit":   {notifier.EventStarted, notifier.EventCancelled},
+			"/default": {notifier.EventCancelled},
+		}))
+
+		pipelineRun = createPipelineRun("subscribed-failure", corev1.ConditionFalse)
+		Expect(reconcilePipelineRun(r, pipelineRun)).To(Succeed())
+		Expect(events["/failures"]).To(Equal([]string{notifier.EventFailed}))
+	})
+
+	It("should flag pipelineruns that succeeded after retries", func() {
+		Expect(reconcilePipelineRun(r, createPipelineRun("subscribed-success", corev1.ConditionTrue))).To(Succeed())
+		Expect(events).To(Equal(map[string][]string{"/audit": {notifier.EventSucceeded}, "/default": {notifier.EventSucceeded}}))
+
+		taskRun := &tektonv1.TaskRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "subscribed-retried-build", Namespace: "default"},
+			Spec:       tektonv1.TaskRunSpec{TaskRef: &tektonv1.TaskRef{Name: "build"}},
+		}
+		Expect(k8sClient.Create(context.Background(), taskRun)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), taskRun)
+		taskRun.Status.RetriesStatus = []tektonv1.TaskRunStatus{{}, {}}
+		Expect(k8sClient.Status().Update(context.Background(), taskRun)).To(Succeed())
+		pipelineRun := createPipelineRun("subscribed-retried", corev1.ConditionTrue)
+		pipelineRun.Status.ChildReferences = []tektonv1.ChildStatusReference{
+			{TypeMeta: runtime.TypeMeta{Kind: "TaskRun"}, Name: "subscribed-retried-build", PipelineTaskName: "build"},
+		}
+		Expect(k8sClient.Status().Update(context.Background(), pipelineRun)).To(Succeed())
+		Expect(reconcilePipelineRun(r, pipelineRun)).To(Succeed())
+		Expect(events["/retries"]).To(Equal([]string{notifier.EventSucceeded}))
+		Expect(events["/audit"]).To(HaveLen(2))
+		Expect(subtypes).To(Equal(map[string][]string{
+			"/audit":   {notifier.EventSucceededWithRetries},
+			"/retries": {notifier.EventSucceededWithRetries},
+			"/default": {notifier.EventSucceededWithRetries},
+		}))
+	})
+})
@@ -0,0 +1,35 @@
+package controller
+
+import (
+	"context"
+	"slices"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DefaultListPageSize is the number of objects read per request by the lists the controller sends to the API server
+const DefaultListPageSize int64 = 500
+
+// ListPages lists the objects matching the options into list, pageSize objects at a time, and calls each after
+// every page, so large lists are neither read in a single request nor held in memory at once.
+// The reader must read from the API server, caches do not paginate. A pageSize of 0 lists all objects at once.
+// Return error if a page cannot be listed or each fails
+func ListPages(ctx context.Context, c client.Reader, list client.ObjectList, pageSize int64, each func() error, opts ...client.ListOption) error {
+	opts = slices.Clip(opts)
+	pageOpts := append(opts, client.Limit(pageSize))
+	for {
+		err := c.List(ctx, list, pageOpts...)
+		if err != nil {
+			return err
+		}
+		err = each()
+		if err != nil {
+			return err
+		}
+		token := list.GetContinue()
+		if token == "" || pageSize <= 0 {
+			return nil
+		}
+		pageOpts = append(opts, client.Limit(pageSize), client.Continue(token))
+	}
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("List pages", func() {
+	It("should list the objects page by page", func() {
+		for i := range 3 {
+			configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+				Name: fmt.Sprintf("paged-%d", i), Namespace: "default", Labels: map[string]string{"paged": "true"},
+			}}
+			Expect(k8sClient.Create(context.Background(), configMap)).To(Succeed())
+			DeferCleanup(k8sClient.Delete, context.Background(), configMap)
+		}
+		pages := func(pageSize int64) [][]string {
+			var pages [][]string
+			configMaps := &corev1.ConfigMapList{}
+			Expect(ListPages(context.Background(), k8sClient, configMaps, pageSize, func() error {
+				var names []string
+				for _, configMap := range configMaps.Items {
+					names = append(names, configMap.Name)
+				}
+				pages = append(pages, names)
+				return nil
+			}, client.InNamespace("default"), client.MatchingLabels{"paged": "true"})).To(Succeed())
+			return pages
+		}
+		Expect(pages(2)).To(Equal([][]string{{"paged-0", "paged-1"}, {"paged-2"}}))
+		Expect(pages(0)).To(Equal([][]string{{"paged-0", "paged-1", "paged-2"}}))
+
+		err := ListPages(context.Background(), k8sClient, &corev1.ConfigMapList{}, 1, func() error {
+			return fmt.Errorf("Stop")
+		}, client.InNamespace("default"), client.MatchingLabels{"paged": "true"})
+		Expect(err).To(MatchError("Stop"))
+	})
+})
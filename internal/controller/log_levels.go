@@ -0,0 +1,258 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"go.uber.org/zap/zapcore"
+)
+
+// LogLevelsPath is the path of the endpoint reading and changing the log levels
+const LogLevelsPath string = "/debug/loglevels"
+
+// DefaultLoggerName sets the level of the loggers without a level of their own
+const DefaultLoggerName string = "default"
+
+// LogLevels holds the default log level of the controller and the levels of named loggers, e.g. prober
+// or controller-runtime.cache, which also apply to their descendants. Levels are changed at runtime,
+// from the logLevels of the configuration file or by a PUT request to LogLevelsPath.
+type LogLevels struct {
+	Log logr.Logger
+	// BindAddress is the address LogLevelsPath is served on, if set
+	BindAddress string
+
+	mu       sync.RWMutex
+	fallback zapcore.Level
+	named    map[string]zapcore.Level
+}
+
+// NewLogLevels returns the log levels with the default level, the levels of named loggers given as
+// <logger>=<level> pairs separated by commas
+// Return error if a level is not valid
+func NewLogLevels(fallback zapcore.Level, named string) (*LogLevels, error) {
+	l := &LogLevels{fallback: fallback}
+	levels := map[string]string{}
+	for _, pair := range strings.Split(named, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, level, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("Invalid log level %s, expected <logger>=<level>", pair)
+		}
+		levels[strings.TrimSpace(name)] = strings.TrimSpace(level)
+	}
+	err := l.Set(levels)
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// ParseLogLevel parses a zap level name, e.g. debug or error, or a logr verbosity, e.g. 2 for V(2)
+// Return error if the level is not valid
+func ParseLogLevel(level string) (zapcore.Level, error) {
+	if verbosity, err := strconv.Atoi(level); err == nil {
+		if verbosity < 0 || verbosity > 127 {
+			return 0, fmt.Errorf("Invalid log verbosity %d", verbosity)
+		}
+		return zapcore.Level(-verbosity), nil
+	}
+	parsed, err := zapcore.ParseLevel(level)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid log level %s: %w", level, err)
+	}
+	return parsed, nil
+}
+
+// Set replaces the levels of the named loggers, keyed by logger name, with DefaultLoggerName
+// changing the default level. Named loggers not in levels go back to the default level.
+// Return error if a level is not valid, without changing any level
+func (l *LogLevels) Set(levels map[string]string) error {
+	fallback := l.Default()
+	named := map[string]zapcore.Level{}
+	for name, level := range levels {
+		parsed, err := ParseLogLevel(level)
+		if err != nil {
+			return fmt.Errorf("Invalid log level of logger %s: %w", name, err)
+		}
+		if name == DefaultLoggerName {
+			fallback = parsed
+			continue
+		}
+		named[name] = parsed
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.fallback = fallback
+	l.named = named
+	return nil
+}
+
+// Levels returns the levels keyed by logger name, including DefaultLoggerName
+func (l *LogLevels) Levels() map[string]string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	levels := map[string]string{DefaultLoggerName: formatLogLevel(l.fallback)}
+	for name, level := range l.named {
+		levels[name] = formatLogLevel(level)
+	}
+	return levels
+}
+
+// Default returns the level of the loggers without a level of their own
+func (l *LogLevels) Default() zapcore.Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.fallback
+}
+
+// Enabled returns a boolean indicating whether any logger logs entries of the level
+func (l *LogLevels) Enabled(level zapcore.Level) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.fallback.Enabled(level) {
+		return true
+	}
+	for _, named := range l.named {
+		if named.Enabled(level) {
+			return true
+		}
+	}
+	return false
+}
+
+// EnabledFor returns a boolean indicating whether the logger logs entries of the level, according to
+// the level of the logger or of its closest named ancestor, or to the default level
+func (l *LogLevels) EnabledFor(logger string, level zapcore.Level) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for name := logger; name != ""; {
+		if named, ok := l.named[name]; ok {
+			return named.Enabled(level)
+		}
+		i := strings.LastIndex(name, ".")
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	return l.fallback.Enabled(level)
+}
+
+// WrapCore returns the core filtering the entries of the core by the level of their logger,
+// to be set as a zap option. The core must enable every level enabled by the log levels.
+func (l *LogLevels) WrapCore(core zapcore.Core) zapcore.Core {
+	return &levelCore{Core: core, levels: l}
+}
+
+// levelCore drops the entries of loggers below their level
+type levelCore struct {
+	zapcore.Core
+	levels *LogLevels
+}
+
+func (c *levelCore) Enabled(level zapcore.Level) bool {
+	return c.levels.Enabled(level)
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), levels: c.levels}
+}
+
+func (c *levelCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.levels.EnabledFor(entry.LoggerName, entry.Level) {
+		return checked
+	}
+	return c.Core.Check(entry, checked)
+}
+
+// NeedLeaderElection returns false so the log levels of every replica are served
+func (l *LogLevels) NeedLeaderElection() bool {
+	return false
+}
+
+// Start serves LogLevelsPath until the context is cancelled
+func (l *LogLevels) Start(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.Handle(LogLevelsPath, l)
+	server := &http.Server{
+		Addr:              l.BindAddress,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+	l.Log.Info("Serving log levels", "address", l.BindAddress)
+	err := server.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// ServeHTTP serves the log levels as JSON on GET, and replaces them with the JSON object of the request on PUT
+func (l *LogLevels) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		levels := map[string]string{}
+		err := json.NewDecoder(http.MaxBytesReader(w, req.Body, 1<<16)).Decode(&levels)
+		if err != nil {
+			http.Error(w, "request body is not an object of log levels: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = l.Set(levels)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		names := make([]string, 0, len(levels))
+		for name := range levels {
+			names = append(names, name+"="+levels[name])
+		}
+		sort.Strings(names)
+		l.Log.Info("Changed log levels", "levels", strings.Join(names, ","))
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, l.Levels())
+}
+
+// formatLogLevel returns the name of a zap level, or the logr verbosity of levels below debug
+func formatLogLevel(level zapcore.Level) string {
+	if level < zapcore.DebugLevel {
+		return strconv.Itoa(-int(level))
+	}
+	return level.String()
+}
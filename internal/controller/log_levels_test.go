@@ -0,0 +1,111 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+var _ = Describe("Log levels", func() {
+	It("should filter the entries of named loggers and their descendants by their level", func() {
+		levels, err := NewLogLevels(zapcore.InfoLevel, "prober=debug, controller-runtime=error")
+		Expect(err).NotTo(HaveOccurred())
+		core, logs := observer.New(levels)
+		logger := zap.New(core, zap.WrapCore(levels.WrapCore))
+
+		logger.Named("prober").Debug("probing")
+		logger.Named("prober").Named("webhook").Debug("probing webhook")
+		logger.Named("controller-runtime").Named("cache").Info("syncing")
+		logger.Named("controller-runtime").Error("failing")
+		logger.Named("sweeper").Debug("sweeping")
+		logger.Named("sweeper").Info("swept")
+		Expect(logs.All()).To(HaveLen(4))
+		Expect(logs.FilterMessage("syncing").Len()).To(BeZero())
+		Expect(logs.FilterMessage("sweeping").Len()).To(BeZero())
+
+		Expect(levels.Set(map[string]string{DefaultLoggerName: "2"})).To(Succeed())
+		logger.Named("controller-runtime").Named("cache").Info("syncing")
+		logger.Named("sweeper").Log(zapcore.Level(-2), "sweeping")
+		Expect(logs.FilterMessage("syncing").Len()).To(Equal(1))
+		Expect(logs.FilterMessage("sweeping").Len()).To(Equal(1))
+		Expect(levels.Levels()).To(Equal(map[string]string{DefaultLoggerName: "2"}))
+	})
+
+	It("should reject levels that are not valid", func() {
+		_, err := NewLogLevels(zapcore.InfoLevel, "prober")
+		Expect(err).To(HaveOccurred())
+		_, err = NewLogLevels(zapcore.InfoLevel, "prober=loud")
+		Expect(err).To(HaveOccurred())
+		levels, err := NewLogLevels(zapcore.InfoLevel, "prober=debug")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(levels.Set(map[string]string{"sweeper": "debug", "prober": "-1"})).NotTo(Succeed())
+		Expect(levels.Levels()).To(HaveKeyWithValue("prober", "debug"))
+	})
+
+	It("should read and change the log levels over HTTP", func() {
+		levels, err := NewLogLevels(zapcore.InfoLevel, "")
+		Expect(err).NotTo(HaveOccurred())
+		levels.Log = logr.Discard()
+
+		recorder := httptest.NewRecorder()
+		levels.ServeHTTP(recorder, httptest.NewRequest(http.MethodPut, LogLevelsPath, strings.NewReader(`{"prober": "debug"}`)))
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(levels.EnabledFor("prober", zapcore.DebugLevel)).To(BeTrue())
+
+		recorder = httptest.NewRecorder()
+		levels.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, LogLevelsPath, nil))
+		served := map[string]string{}
+		Expect(json.NewDecoder(recorder.Body).Decode(&served)).To(Succeed())
+		Expect(served).To(Equal(map[string]string{DefaultLoggerName: "info", "prober": "debug"}))
+
+		recorder = httptest.NewRecorder()
+		levels.ServeHTTP(recorder, httptest.NewRequest(http.MethodPut, LogLevelsPath, strings.NewReader(`{"prober": "loud"}`)))
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("should apply the log levels of the reloaded configuration file", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "config.yaml")
+		Expect(os.WriteFile(path, []byte("concurrency: 1\n"), 0o600)).To(Succeed())
+		file, err := NewConfigFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		file.LogLevels, err = NewLogLevels(zapcore.InfoLevel, "")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(os.WriteFile(path, []byte("logLevels:\n  default: error\n  sweeper: debug\n"), 0o600)).To(Succeed())
+		Expect(file.Reload()).To(BeTrue())
+		Expect(file.LogLevels.EnabledFor("sweeper.pipelineruns", zapcore.DebugLevel)).To(BeTrue())
+		Expect(file.LogLevels.EnabledFor("prober", zapcore.InfoLevel)).To(BeFalse())
+
+		Expect(os.WriteFile(path, []byte("logLevels:\n  sweeper: loud\n"), 0o600)).To(Succeed())
+		_, err = file.Reload()
+		Expect(err).To(HaveOccurred())
+		Expect(file.LogLevels.EnabledFor("sweeper", zapcore.DebugLevel)).To(BeTrue())
+	})
+})
@@ -0,0 +1,423 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	"github.com/konflux-ci/notification-service/pkg/schedule"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// +kubebuilder:rbac:groups=konflux.ci,resources=maintenancewindows;clustermaintenancewindows,verbs=get;list;watch
+// +kubebuilder:rbac:groups=konflux.ci,resources=maintenancewindows/status;clustermaintenancewindows/status,verbs=get;update;patch
+
+// ActiveMaintenanceWindow is a MaintenanceWindow or ClusterMaintenanceWindow with a window in progress
+type ActiveMaintenanceWindow struct {
+	// Object is the MaintenanceWindow or ClusterMaintenanceWindow
+	Object client.Object
+	Spec   *v1alpha1.MaintenanceWindowSpec
+	// Start and End are the bounds of the window in progress
+	Start time.Time
+	End   time.Time
+
+	pipelineRunSelector labels.Selector
+}
+
+// GetMaintenanceWindowBounds returns the bounds of the window of the spec that is in progress at now,
+// and false if none is. Recurring windows are scheduled in UTC unless the spec sets a time zone.
+// Return error if the spec declares neither a valid one-off window nor valid recurring windows
+func GetMaintenanceWindowBounds(spec *v1alpha1.MaintenanceWindowSpec, now time.Time) (time.Time, time.Time, bool, error) {
+	oneOff := spec.Start != nil || spec.End != nil
+	recurring := spec.Schedule != "" || spec.Duration != nil
+	switch {
+	case !oneOff && !recurring:
+		return time.Time{}, time.Time{}, false, errors.New("Either start and end or schedule and duration must be set")
+	case oneOff && (spec.Start == nil || spec.End == nil):
+		return time.Time{}, time.Time{}, false, errors.New("Start and end must be set together")
+	case recurring && (spec.Schedule == "" || spec.Duration == nil || spec.Duration.Duration <= 0):
+		return time.Time{}, time.Time{}, false, errors.New("Schedule and a positive duration must be set together")
+	}
+	if oneOff && !now.Before(spec.Start.Time) && now.Before(spec.End.Time) {
+		return spec.Start.Time, spec.End.Time, true, nil
+	}
+	if recurring {
+		location, err := schedule.LoadLocation(spec.TimeZone, time.UTC)
+		if err != nil {
+			return time.Time{}, time.Time{}, false, err
+		}
+		windows, err := schedule.Parse(spec.Schedule, location)
+		if err != nil {
+			return time.Time{}, time.Time{}, false, err
+		}
+		if start, ok := windows.Window(now, spec.Duration.Duration); ok {
+			return start, start.Add(spec.Duration.Duration), true, nil
+		}
+	}
+	return time.Time{}, time.Time{}, false, nil
+}
+
+// GetActiveMaintenanceWindows returns the MaintenanceWindows of the namespace and the ClusterMaintenanceWindows
+// selecting it with a window in progress at now, by kind and name.
+// Windows that are not valid are skipped and reported in the log.
+// Return error if failed to list the windows or to get the namespace
+func GetActiveMaintenanceWindows(ctx context.Context, c client.Reader, logger logr.Logger, namespace string, now time.Time) ([]ActiveMaintenanceWindow, error) {
+	maintenanceWindows := &v1alpha1.MaintenanceWindowList{}
+	err := c.List(ctx, maintenanceWindows, client.InNamespace(namespace))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list MaintenanceWindows in %s: %w", namespace, err)
+	}
+	clusterMaintenanceWindows := &v1alpha1.ClusterMaintenanceWindowList{}
+	err = c.List(ctx, clusterMaintenanceWindows)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list ClusterMaintenanceWindows: %w", err)
+	}
+
+	var active []ActiveMaintenanceWindow
+	add := func(obj client.Object, spec *v1alpha1.MaintenanceWindowSpec) {
+		start, end, ok, err := GetMaintenanceWindowBounds(spec, now)
+		if err == nil && ok {
+			window := ActiveMaintenanceWindow{Object: obj, Spec: spec, Start: start, End: end}
+			if spec.PipelineRunSelector != nil {
+				window.pipelineRunSelector, err = metav1.LabelSelectorAsSelector(spec.PipelineRunSelector)
+			}
+			if err == nil {
+				active = append(active, window)
+			}
+		}
+		if err != nil {
+			logger.Error(err, "Skipping invalid maintenance window", "kind", obj.GetObjectKind().GroupVersionKind().Kind,
+				"namespace", obj.GetNamespace(), "name", obj.GetName())
+		}
+	}
+	sort.Slice(maintenanceWindows.Items, func(i, j int) bool {
+		return maintenanceWindows.Items[i].Name < maintenanceWindows.Items[j].Name
+	})
+	for i := range maintenanceWindows.Items {
+		maintenanceWindow := &maintenanceWindows.Items[i]
+		maintenanceWindow.SetGroupVersionKind(v1alpha1.GroupVersion.WithKind("MaintenanceWindow"))
+		add(maintenanceWindow, &maintenanceWindow.Spec)
+	}
+
+	sort.Slice(clusterMaintenanceWindows.Items, func(i, j int) bool {
+		return clusterMaintenanceWindows.Items[i].Name < clusterMaintenanceWindows.Items[j].Name
+	})
+	var namespaceLabels labels.Set
+	for i := range clusterMaintenanceWindows.Items {
+		clusterMaintenanceWindow := &clusterMaintenanceWindows.Items[i]
+		clusterMaintenanceWindow.SetGroupVersionKind(v1alpha1.GroupVersion.WithKind("ClusterMaintenanceWindow"))
+		if clusterMaintenanceWindow.Spec.NamespaceSelector != nil {
+			selector, err := metav1.LabelSelectorAsSelector(clusterMaintenanceWindow.Spec.NamespaceSelector)
+			if err != nil {
+				logger.Error(err, "Skipping ClusterMaintenanceWindow with an invalid namespace selector",
+					"clusterMaintenanceWindow", clusterMaintenanceWindow.Name)
+				continue
+			}
+			if namespaceLabels == nil {
+				ns := &corev1.Namespace{}
+				err = c.Get(ctx, types.NamespacedName{Name: namespace}, ns)
+				if err != nil {
+					return nil, fmt.Errorf("Failed to get namespace %s: %w", namespace, err)
+				}
+				namespaceLabels = labels.Set(ns.Labels)
+				if namespaceLabels == nil {
+					namespaceLabels = labels.Set{}
+				}
+			}
+			if !selector.Matches(namespaceLabels) {
+				continue
+			}
+		}
+		add(clusterMaintenanceWindow, &clusterMaintenanceWindow.Spec.MaintenanceWindowSpec)
+	}
+	return active, nil
+}
+
+// SelectsPipelineRun returns a boolean indicating whether the window silences the notifications about the pipelineRun
+func (w *ActiveMaintenanceWindow) SelectsPipelineRun(pipelineRun *tektonv1.PipelineRun) bool {
+	if len(w.Spec.Pipelines) > 0 && !matchesPattern(w.Spec.Pipelines, PipelineName(pipelineRun)) {
+		return false
+	}
+	return w.pipelineRunSelector == nil || w.pipelineRunSelector.Matches(labels.Set(pipelineRun.Labels))
+}
+
+// SelectsDestination returns a boolean indicating whether the window silences the destination
+func (w *ActiveMaintenanceWindow) SelectsDestination(name string) bool {
+	return len(w.Spec.Destinations) == 0 || matchesPattern(w.Spec.Destinations, name)
+}
+
+// SplitSuppressedDestinations separates the destinations the windows silence for the pipelineRun from the others.
+// It also returns the window silencing each suppressed destination, by destination name, which is the first
+// window silencing it.
+func SplitSuppressedDestinations(destinations []DestinationNotifier, windows []ActiveMaintenanceWindow,
+	pipelineRun *tektonv1.PipelineRun) ([]DestinationNotifier, []DestinationNotifier, map[string]*ActiveMaintenanceWindow) {
+	var selecting []*ActiveMaintenanceWindow
+	for i := range windows {
+		if windows[i].SelectsPipelineRun(pipelineRun) {
+			selecting = append(selecting, &windows[i])
+		}
+	}
+	if len(selecting) == 0 {
+		return destinations, nil, nil
+	}
+	var active, suppressed []DestinationNotifier
+	by := map[string]*ActiveMaintenanceWindow{}
+	for _, destination := range destinations {
+		for _, window := range selecting {
+			if window.SelectsDestination(destination.Name) {
+				by[destination.Name] = window
+				break
+			}
+		}
+		if by[destination.Name] != nil {
+			suppressed = append(suppressed, destination)
+		} else {
+			active = append(active, destination)
+		}
+	}
+	return active, suppressed, by
+}
+
+// RecordSuppressedNotifications adds the notifications suppressed for the destinations about a pipelinerun of
+// the namespace to the counts of the windows silencing them. The counts of a window are reset when it
+// suppresses the first notification of another window.
+// Return error if failed to update the status of a window
+func RecordSuppressedNotifications(ctx context.Context, c client.Client, namespace string, destinations []string,
+	windows map[string]*ActiveMaintenanceWindow) error {
+	byWindow := map[*ActiveMaintenanceWindow][]string{}
+	var order []*ActiveMaintenanceWindow
+	for _, destination := range destinations {
+		window := windows[destination]
+		if window == nil {
+			continue
+		}
+		if _, ok := byWindow[window]; !ok {
+			order = append(order, window)
+		}
+		byWindow[window] = append(byWindow[window], destination)
+	}
+	var errs []error
+	for _, window := range order {
+		obj := window.Object.DeepCopyObject().(client.Object)
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			err := c.Get(ctx, client.ObjectKeyFromObject(window.Object), obj)
+			if err != nil {
+				return err
+			}
+			status := maintenanceWindowStatus(obj)
+			if status.WindowStart == nil || !status.WindowStart.Time.Equal(window.Start) {
+				*status = v1alpha1.MaintenanceWindowStatus{
+					WindowStart:     &metav1.Time{Time: window.Start},
+					WindowEnd:       &metav1.Time{Time: window.End},
+					LastSummaryTime: status.LastSummaryTime,
+				}
+			}
+			for _, destination := range byWindow[window] {
+				i := 0
+				for ; i < len(status.Suppressed); i++ {
+					if status.Suppressed[i].Namespace == namespace && status.Suppressed[i].Destination == destination {
+						break
+					}
+				}
+				if i == len(status.Suppressed) {
+					status.Suppressed = append(status.Suppressed, v1alpha1.SuppressedNotifications{Namespace: namespace, Destination: destination})
+				}
+				status.Suppressed[i].Count++
+			}
+			return c.Status().Update(ctx, obj)
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Failed to record suppressed notifications of maintenance window %s: %w",
+				maintenanceWindowName(window.Object), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// maintenanceWindowStatus returns the status of a MaintenanceWindow or ClusterMaintenanceWindow
+func maintenanceWindowStatus(obj client.Object) *v1alpha1.MaintenanceWindowStatus {
+	switch window := obj.(type) {
+	case *v1alpha1.MaintenanceWindow:
+		return &window.Status
+	case *v1alpha1.ClusterMaintenanceWindow:
+		return &window.Status
+	}
+	return nil
+}
+
+// maintenanceWindowName returns <namespace>/<name> for a MaintenanceWindow and the name of a ClusterMaintenanceWindow
+func maintenanceWindowName(obj client.Object) string {
+	if obj.GetNamespace() == "" {
+		return obj.GetName()
+	}
+	return obj.GetNamespace() + "/" + obj.GetName()
+}
+
+// MaintenanceWindowSummarizer sends the summaries of the notifications maintenance windows suppressed when
+// their window ends
+type MaintenanceWindowSummarizer struct {
+	Client client.Client
+	Log    logr.Logger
+	// Reconciler resolves the destinations of the suppressed notifications
+	Reconciler *NotificationServiceReconciler
+	// Interval is how often the summarizer checks for windows that ended
+	Interval time.Duration
+}
+
+// NeedLeaderElection returns true so summaries are sent by a single replica
+func (s *MaintenanceWindowSummarizer) NeedLeaderElection() bool {
+	return true
+}
+
+// Start sends the summaries of the windows that ended every interval until the context is cancelled
+func (s *MaintenanceWindowSummarizer) Start(ctx context.Context) error {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = DefaultSummaryInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			err := s.RunOnce(ctx, now)
+			if err != nil {
+				s.Log.Error(err, "Failed to send maintenance window summaries")
+			}
+		}
+	}
+}
+
+// RunOnce sends the summaries of the windows that ended by now and suppressed notifications.
+// The destinations a summary was sent to are recorded in the status of the window, and only the
+// summaries that failed are tried again at the next interval.
+func (s *MaintenanceWindowSummarizer) RunOnce(ctx context.Context, now time.Time) error {
+	maintenanceWindows := &v1alpha1.MaintenanceWindowList{}
+	err := s.Client.List(ctx, maintenanceWindows)
+	if err != nil {
+		return fmt.Errorf("Failed to list MaintenanceWindows: %w", err)
+	}
+	clusterMaintenanceWindows := &v1alpha1.ClusterMaintenanceWindowList{}
+	err = s.Client.List(ctx, clusterMaintenanceWindows)
+	if err != nil {
+		return fmt.Errorf("Failed to list ClusterMaintenanceWindows: %w", err)
+	}
+	var windows []client.Object
+	for i := range maintenanceWindows.Items {
+		windows = append(windows, &maintenanceWindows.Items[i])
+	}
+	for i := range clusterMaintenanceWindows.Items {
+		windows = append(windows, &clusterMaintenanceWindows.Items[i])
+	}
+
+	var errs []error
+	for _, window := range windows {
+		status := maintenanceWindowStatus(window)
+		if status.WindowEnd == nil || status.WindowEnd.Time.After(now) || len(status.Suppressed) == 0 ||
+			(status.LastSummaryTime != nil && !status.LastSummaryTime.Time.Before(status.WindowEnd.Time)) {
+			continue
+		}
+		sent, sendErr := s.sendSummaries(ctx, maintenanceWindowName(window), status)
+		if sendErr != nil {
+			errs = append(errs, sendErr)
+		} else {
+			status.LastSummaryTime = status.WindowEnd.DeepCopy()
+		}
+		if !sent && sendErr != nil {
+			continue
+		}
+		err = s.Client.Status().Update(ctx, window)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Failed to update the summaries of maintenance window %s: %w",
+				maintenanceWindowName(window), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sendSummaries sends the summary of the PipelineRuns that completed during the window to each destination
+// supporting summaries that had notifications suppressed and was not sent the summary yet, with the number of
+// its suppressed notifications. The suppressed notifications of the destinations that were sent their summary,
+// or that no longer exist or support summaries, are marked as summarized. It returns a boolean indicating whether
+// any was marked.
+func (s *MaintenanceWindowSummarizer) sendSummaries(ctx context.Context, name string, status *v1alpha1.MaintenanceWindowStatus) (bool, error) {
+	pending := map[string][]*v1alpha1.SuppressedNotifications{}
+	var namespaces []string
+	for i := range status.Suppressed {
+		suppressed := &status.Suppressed[i]
+		if suppressed.Summarized {
+			continue
+		}
+		if pending[suppressed.Namespace] == nil {
+			namespaces = append(namespaces, suppressed.Namespace)
+		}
+		pending[suppressed.Namespace] = append(pending[suppressed.Namespace], suppressed)
+	}
+
+	marked := false
+	var errs []error
+	for _, namespace := range namespaces {
+		destinations, err := GetDestinationNotifiers(ctx, s.Reconciler, namespace)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		summary, err := CompileSummary(ctx, s.Client, namespace, status.WindowStart.Time, status.WindowEnd.Time)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		summary.MaintenanceWindow = name
+		for _, suppressed := range pending[namespace] {
+			i := slices.IndexFunc(destinations, func(destination DestinationNotifier) bool {
+				return destination.Name == suppressed.Destination
+			})
+			var summaryNotifier notifier.SummaryNotifier
+			if i >= 0 {
+				summaryNotifier, _ = destinations[i].Notifier.(notifier.SummaryNotifier)
+			}
+			if summaryNotifier != nil {
+				destinationSummary := *summary
+				destinationSummary.Suppressed = suppressed.Count
+				err = summaryNotifier.NotifySummary(ctx, &destinationSummary)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("Failed to send summary of maintenance window %s to %s: %w", name, suppressed.Destination, err))
+					continue
+				}
+			}
+			suppressed.Summarized = true
+			marked = true
+		}
+	}
+	return marked, errors.Join(errs...)
+}
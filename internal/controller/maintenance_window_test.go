@@ -0,0 +1,235 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Maintenance windows", func() {
+	now := time.Date(2024, 5, 10, 23, 0, 0, 0, time.UTC)
+
+	DescribeTable("should find the window in progress",
+		func(spec v1alpha1.MaintenanceWindowSpec, active bool, start time.Time) {
+			windowStart, _, ok, err := GetMaintenanceWindowBounds(&spec, now)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(Equal(active))
+			if active {
+				Expect(windowStart).To(BeTemporally("==", start))
+			}
+		},
+		Entry("one-off window in progress", v1alpha1.MaintenanceWindowSpec{
+			Start: &metav1.Time{Time: now.Add(-time.Hour)}, End: &metav1.Time{Time: now.Add(time.Hour)},
+		}, true, now.Add(-time.Hour)),
+		Entry("one-off window that ended", v1alpha1.MaintenanceWindowSpec{
+			Start: &metav1.Time{Time: now.Add(-2 * time.Hour)}, End: &metav1.Time{Time: now},
+		}, false, time.Time{}),
+		Entry("recurring window in progress", v1alpha1.MaintenanceWindowSpec{
+			Schedule: "0 22 * * 5", Duration: &metav1.Duration{Duration: 8 * time.Hour},
+		}, true, now.Add(-time.Hour)),
+		Entry("recurring window in another time zone", v1alpha1.MaintenanceWindowSpec{
+			Schedule: "CRON_TZ=Europe/Berlin 0 22 * * 5", Duration: &metav1.Duration{Duration: 8 * time.Hour},
+		}, true, now.Add(-3*time.Hour)),
+		Entry("recurring window in the time zone of the spec", v1alpha1.MaintenanceWindowSpec{
+			Schedule: "0 22 * * 5", TimeZone: "Europe/Berlin", Duration: &metav1.Duration{Duration: 8 * time.Hour},
+		}, true, now.Add(-3*time.Hour)),
+		Entry("recurring window on another day", v1alpha1.MaintenanceWindowSpec{
+			Schedule: "0 22 * * 1", Duration: &metav1.Duration{Duration: 8 * time.Hour},
+		}, false, time.Time{}),
+	)
+
+	It("should reject windows that are not valid", func() {
+		for _, spec := range []v1alpha1.MaintenanceWindowSpec{
+			{},
+			{Start: &metav1.Time{Time: now}},
+			{Schedule: "0 22 * * 5"},
+			{Schedule: "not a schedule", Duration: &metav1.Duration{Duration: time.Hour}},
+			{Schedule: "0 22 * * 5", TimeZone: "Nowhere", Duration: &metav1.Duration{Duration: time.Hour}},
+		} {
+			_, _, _, err := GetMaintenanceWindowBounds(&spec, now)
+			Expect(err).To(HaveOccurred())
+		}
+	})
+
+	It("should suppress the notifications to the selected destinations during the window", func() {
+		received := 0
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			received++
+		}))
+		DeferCleanup(receiver.Close)
+		notificationService := &v1alpha1.NotificationService{
+			ObjectMeta: metav1.ObjectMeta{Name: "maintained", Namespace: "default"},
+			Spec: v1alpha1.NotificationServiceSpec{
+				Destinations: []v1alpha1.Destination{{Name: "hook", Webhook: &v1alpha1.WebhookDestination{URL: receiver.URL}}},
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), notificationService)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), notificationService)
+		maintenanceWindow := &v1alpha1.MaintenanceWindow{
+			ObjectMeta: metav1.ObjectMeta{Name: "always", Namespace: "default"},
+			Spec: v1alpha1.MaintenanceWindowSpec{
+				Schedule:     "0 * * * *",
+				Duration:     &metav1.Duration{Duration: time.Hour},
+				Pipelines:    []string{"bui*"},
+				Destinations: []string{"default/*/hook"},
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), maintenanceWindow)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), maintenanceWindow)
+
+		fake := &fakeNotifier{}
+		r := &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Notifier: fake, MaintenanceWindows: true}
+		for _, name := range []string{"maintained-1", "maintained-2"} {
+			pipelineRun := createPipelineRun(name, corev1.ConditionTrue)
+			Expect(reconcilePipelineRun(r, pipelineRun)).To(Succeed())
+			skipped, err := GetSkippedDestinations(getPipelineRun(pipelineRun))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(skipped).To(HaveKey("default/maintained/hook"))
+		}
+
+		Expect(received).To(Equal(0))
+		Expect(fake.notifications).To(HaveLen(2))
+		Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(maintenanceWindow), maintenanceWindow)).To(Succeed())
+		Expect(maintenanceWindow.Status.WindowStart).NotTo(BeNil())
+		Expect(maintenanceWindow.Status.WindowEnd.Sub(maintenanceWindow.Status.WindowStart.Time)).To(Equal(time.Hour))
+		Expect(maintenanceWindow.Status.Suppressed).To(Equal([]v1alpha1.SuppressedNotifications{
+			{Namespace: "default", Destination: "default/maintained/hook", Count: 2},
+		}))
+	})
+
+	It("should send the summary of the suppressed notifications when the window ends", func() {
+		var received []notifier.Summary
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			summary := notifier.Summary{}
+			Expect(json.NewDecoder(req.Body).Decode(&summary)).To(Succeed())
+			received = append(received, summary)
+		}))
+		DeferCleanup(receiver.Close)
+		notificationService := &v1alpha1.NotificationService{
+			ObjectMeta: metav1.ObjectMeta{Name: "summarized", Namespace: "default"},
+			Spec: v1alpha1.NotificationServiceSpec{
+				Destinations: []v1alpha1.Destination{
+					{Name: "hook", Webhook: &v1alpha1.WebhookDestination{URL: receiver.URL}},
+					{Name: "other", Webhook: &v1alpha1.WebhookDestination{URL: receiver.URL + "/other"}},
+				},
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), notificationService)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), notificationService)
+		clusterMaintenanceWindow := &v1alpha1.ClusterMaintenanceWindow{
+			ObjectMeta: metav1.ObjectMeta{Name: "freeze"},
+			Spec: v1alpha1.ClusterMaintenanceWindowSpec{MaintenanceWindowSpec: v1alpha1.MaintenanceWindowSpec{
+				Start: &metav1.Time{Time: now.Add(-2 * time.Hour)}, End: &metav1.Time{Time: now.Add(-time.Hour)},
+			}},
+		}
+		Expect(k8sClient.Create(context.Background(), clusterMaintenanceWindow)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), clusterMaintenanceWindow)
+		clusterMaintenanceWindow.Status = v1alpha1.MaintenanceWindowStatus{
+			WindowStart: clusterMaintenanceWindow.Spec.Start,
+			WindowEnd:   clusterMaintenanceWindow.Spec.End,
+			Suppressed:  []v1alpha1.SuppressedNotifications{{Namespace: "default", Destination: "default/summarized/hook", Count: 3}},
+		}
+		Expect(k8sClient.Status().Update(context.Background(), clusterMaintenanceWindow)).To(Succeed())
+
+		summarizer := &MaintenanceWindowSummarizer{
+			Client:     k8sClient,
+			Reconciler: &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()},
+		}
+		Expect(summarizer.RunOnce(context.Background(), now.Add(-90*time.Minute))).To(Succeed())
+		Expect(received).To(BeEmpty())
+
+		Expect(summarizer.RunOnce(context.Background(), now)).To(Succeed())
+		Expect(received).To(HaveLen(1))
+		Expect(received[0].MaintenanceWindow).To(Equal("freeze"))
+		Expect(received[0].Suppressed).To(Equal(3))
+		Expect(received[0].Namespace).To(Equal("default"))
+
+		Expect(summarizer.RunOnce(context.Background(), now.Add(time.Minute))).To(Succeed())
+		Expect(received).To(HaveLen(1))
+		Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(clusterMaintenanceWindow), clusterMaintenanceWindow)).To(Succeed())
+		Expect(clusterMaintenanceWindow.Status.LastSummaryTime.Time).To(BeTemporally("==", now.Add(-time.Hour)))
+	})
+
+	It("should retry the summary only to the destinations it failed to be sent to", func() {
+		var received []string
+		failing := true
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Path == "/failing" && failing {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			received = append(received, req.URL.Path)
+		}))
+		DeferCleanup(receiver.Close)
+		notificationService := &v1alpha1.NotificationService{
+			ObjectMeta: metav1.ObjectMeta{Name: "retried", Namespace: "default"},
+			Spec: v1alpha1.NotificationServiceSpec{
+				Destinations: []v1alpha1.Destination{
+					{Name: "hook", Webhook: &v1alpha1.WebhookDestination{URL: receiver.URL + "/hook"}},
+					{Name: "failing", Webhook: &v1alpha1.WebhookDestination{URL: receiver.URL + "/failing"}},
+				},
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), notificationService)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), notificationService)
+		clusterMaintenanceWindow := &v1alpha1.ClusterMaintenanceWindow{
+			ObjectMeta: metav1.ObjectMeta{Name: "retried-freeze"},
+			Spec: v1alpha1.ClusterMaintenanceWindowSpec{MaintenanceWindowSpec: v1alpha1.MaintenanceWindowSpec{
+				Start: &metav1.Time{Time: now.Add(-2 * time.Hour)}, End: &metav1.Time{Time: now.Add(-time.Hour)},
+			}},
+		}
+		Expect(k8sClient.Create(context.Background(), clusterMaintenanceWindow)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), clusterMaintenanceWindow)
+		clusterMaintenanceWindow.Status = v1alpha1.MaintenanceWindowStatus{
+			WindowStart: clusterMaintenanceWindow.Spec.Start,
+			WindowEnd:   clusterMaintenanceWindow.Spec.End,
+			Suppressed: []v1alpha1.SuppressedNotifications{
+				{Namespace: "default", Destination: "default/retried/hook", Count: 1},
+				{Namespace: "default", Destination: "default/retried/failing", Count: 2},
+			},
+		}
+		Expect(k8sClient.Status().Update(context.Background(), clusterMaintenanceWindow)).To(Succeed())
+
+		summarizer := &MaintenanceWindowSummarizer{
+			Client:     k8sClient,
+			Reconciler: &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()},
+		}
+		Expect(summarizer.RunOnce(context.Background(), now)).NotTo(Succeed())
+		Expect(received).To(Equal([]string{"/hook"}))
+		Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(clusterMaintenanceWindow), clusterMaintenanceWindow)).To(Succeed())
+		Expect(clusterMaintenanceWindow.Status.LastSummaryTime).To(BeNil())
+
+		failing = false
+		Expect(summarizer.RunOnce(context.Background(), now.Add(time.Minute))).To(Succeed())
+		Expect(received).To(Equal([]string{"/hook", "/failing"}))
+		Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(clusterMaintenanceWindow), clusterMaintenanceWindow)).To(Succeed())
+		Expect(clusterMaintenanceWindow.Status.LastSummaryTime.Time).To(BeTemporally("==", now.Add(-time.Hour)))
+	})
+})
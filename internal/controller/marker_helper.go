@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// DefaultMarkerPrefix is the default prefix of the finalizer and annotations the controller sets on pipelineruns
+const DefaultMarkerPrefix string = "konflux.ci"
+
+// DefaultFieldManager is the field manager of the controller using the default marker prefix
+const DefaultFieldManager string = "notification-service"
+
+// ConfigureMarkers sets the prefix of the finalizer and annotations the controller sets on pipelineruns,
+// e.g. staging.konflux.ci, so several instances of the controller handle the same pipelineruns without
+// interfering. Instances with another prefix than DefaultMarkerPrefix also use their own field manager.
+// It must be called before the controller starts.
+// Return error if the prefix is not a DNS subdomain
+func ConfigureMarkers(prefix string) error {
+	if errs := validation.IsDNS1123Subdomain(prefix); len(errs) > 0 {
+		return fmt.Errorf("Invalid marker prefix %s: %s", prefix, strings.Join(errs, ", "))
+	}
+	NotificationPipelineRunFinalizer = prefix + "/notification"
+	NotificationPipelineRunAnnotation = prefix + "/notified"
+	NotificationThreadsAnnotation = prefix + "/notification-threads"
+	NotificationLifecycleAnnotation = prefix + "/lifecycle-notifications"
+	NotificationAwaitingAcknowledgementAnnotation = prefix + "/awaiting-acknowledgement"
+	NotificationDeliveredAnnotation = prefix + "/delivered-destinations"
+	NotificationSkippedAnnotation = prefix + "/skipped-destinations"
+	NotificationOutboxAnnotation = prefix + "/notification-outbox"
+	NotificationConditionsAnnotation = prefix + "/notified-conditions"
+	RerunOfAnnotation = prefix + "/rerun-of"
+	RerunByAnnotation = prefix + "/rerun-by"
+	NotificationCanaryLabel = prefix + "/notification-canary"
+	NotificationFieldManager = DefaultFieldManager
+	if prefix != DefaultMarkerPrefix {
+		NotificationFieldManager = DefaultFieldManager + "-" + prefix
+	}
+	return nil
+}
+
+// LeaderElectionID returns the name of the leader election lease of the instances of the controller using
+// the marker prefix, so instances with different prefixes elect their leaders independently.
+// The lease of the default prefix keeps its scaffolded name.
+func LeaderElectionID(prefix string) string {
+	return "75765374." + prefix
+}
+
+// ownedPipelineRunAnnotations returns the pipelinerun annotations applied with the NotificationFieldManager field manager
+func ownedPipelineRunAnnotations() []string {
+	return []string{
+		NotificationPipelineRunAnnotation,
+		NotificationThreadsAnnotation,
+		NotificationLifecycleAnnotation,
+		NotificationAwaitingAcknowledgementAnnotation,
+		NotificationDeliveredAnnotation,
+		NotificationSkippedAnnotation,
+		NotificationOutboxAnnotation,
+	}
+}
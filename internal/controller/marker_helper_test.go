@@ -0,0 +1,59 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Markers", func() {
+	It("should reject prefixes that are not DNS subdomains", func() {
+		Expect(ConfigureMarkers("Not A Prefix")).NotTo(Succeed())
+		Expect(NotificationPipelineRunFinalizer).To(Equal(DefaultMarkerPrefix + "/notification"))
+	})
+
+	It("should let instances with different prefixes handle the same pipelineruns", func() {
+		DeferCleanup(ConfigureMarkers, DefaultMarkerPrefix)
+		pipelineRun := createPipelineRun("marked-twice", "")
+		fake := &fakeNotifier{}
+		r := &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Notifier: fake}
+		Expect(reconcilePipelineRun(r, pipelineRun)).To(Succeed())
+
+		Expect(ConfigureMarkers("staging.konflux.ci")).To(Succeed())
+		Expect(NotificationFieldManager).To(Equal(DefaultFieldManager + "-staging.konflux.ci"))
+		Expect(reconcilePipelineRun(r, pipelineRun)).To(Succeed())
+		Expect(getPipelineRun(pipelineRun).Finalizers).To(ConsistOf(DefaultMarkerPrefix+"/notification", "staging.konflux.ci/notification"))
+
+		pr := getPipelineRun(pipelineRun)
+		pr.Status.MarkSucceeded("Succeeded", "")
+		Expect(k8sClient.Status().Update(context.Background(), pr)).To(Succeed())
+		Expect(reconcilePipelineRun(r, pipelineRun)).To(Succeed())
+		pr = getPipelineRun(pipelineRun)
+		Expect(pr.Finalizers).To(ConsistOf(DefaultMarkerPrefix + "/notification"))
+		Expect(pr.Annotations).To(HaveKeyWithValue("staging.konflux.ci/notified", NotificationPipelineRunAnnotationValue))
+		Expect(pr.Annotations).NotTo(HaveKey(DefaultMarkerPrefix + "/notified"))
+		Expect(fake.notifications).To(HaveLen(1))
+	})
+
+	It("should elect the leaders of instances with different prefixes independently", func() {
+		Expect(LeaderElectionID(DefaultMarkerPrefix)).To(Equal("75765374.konflux.ci"))
+		Expect(LeaderElectionID("staging.konflux.ci")).To(Equal("75765374.staging.konflux.ci"))
+	})
+})
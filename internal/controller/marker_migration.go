@@ -0,0 +1,292 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/konflux-ci/operator-toolkit/metadata"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// markerMigrationRequeue is how long reconciliations wait for the markers to be migrated
+const markerMigrationRequeue = 5 * time.Second
+
+// MarkerMigration rewrites, on startup, the markers earlier versions of the controller set on the existing
+// pipelineruns, so upgrades neither notify pipelineruns again nor strand their finalizer:
+//   - the finalizer and annotations of the legacy marker prefixes are renamed to those of the current prefix
+//   - the finalizer and annotations set by other field managers before the controller started, e.g. by
+//     versions using merge patches, are handed over to NotificationFieldManager. Server-side apply does not
+//     remove the fields other managers also own, so the controller could neither release these pipelineruns
+//     nor remove their state annotations.
+//   - state annotations exceeding MaxStateAnnotationBytes are moved to the NotificationState of the pipelinerun
+//
+// Pipelineruns whose markers are held by another controller since the controller started are left to it,
+// see ForeignMarkerManagers.
+type MarkerMigration struct {
+	Client client.Client
+	// Reader lists the pipelineruns with their managed fields, which the cache strips, e.g. the API reader
+	// of the manager
+	Reader client.Reader
+	// PageSize is the number of pipelineruns listed per request, all at once if zero
+	PageSize int64
+	// LegacyPrefixes are the marker prefixes earlier versions of the controller used, see ConfigureMarkers
+	LegacyPrefixes []string
+	// Started is when the controller started
+	Started time.Time
+	Log     logr.Logger
+	// Done is closed once the markers are migrated, it is the channel set as MarkersMigrated of the reconciler
+	Done chan<- struct{}
+}
+
+// NeedLeaderElection returns true so only the replica reconciling pipelineruns migrates their markers
+func (m *MarkerMigration) NeedLeaderElection() bool {
+	return true
+}
+
+// Start migrates the markers of the existing pipelineruns and closes Done.
+// Failures are logged, the pipelineruns that could not be migrated are reconciled with their markers as is.
+func (m *MarkerMigration) Start(ctx context.Context) error {
+	defer close(m.Done)
+	migrated, err := m.RunOnce(ctx)
+	if err != nil {
+		m.Log.Error(err, "Failed to migrate the markers of pipelineruns")
+	}
+	if migrated > 0 {
+		m.Log.Info("Migrated the markers of pipelineruns", "count", migrated)
+	}
+	return nil
+}
+
+// RunOnce migrates the markers of all pipelineruns and returns how many were migrated.
+// Return error if the pipelineruns cannot be listed or one of them cannot be migrated
+func (m *MarkerMigration) RunOnce(ctx context.Context) (int, error) {
+	migrated := 0
+	var errs []error
+	pipelineRuns := &tektonv1.PipelineRunList{}
+	err := ListPages(ctx, m.Reader, pipelineRuns, m.PageSize, func() error {
+		for i := range pipelineRuns.Items {
+			ok, err := m.migrate(ctx, &pipelineRuns.Items[i])
+			if err != nil {
+				errs = append(errs, err)
+			} else if ok {
+				migrated++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return migrated, fmt.Errorf("Failed to list pipelineruns: %w", err)
+	}
+	if len(errs) > 0 {
+		return migrated, fmt.Errorf("Failed to migrate the markers of %d pipelineruns, first error: %w", len(errs), errs[0])
+	}
+	return migrated, nil
+}
+
+// migrate migrates the markers of the pipelineRun, reading it again on conflicts, and returns a boolean
+// indicating whether they needed to be migrated
+func (m *MarkerMigration) migrate(ctx context.Context, pipelineRun *tektonv1.PipelineRun) (bool, error) {
+	migrated := false
+	attempt := 0
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		attempt++
+		if attempt > 1 {
+			err := m.Reader.Get(ctx, client.ObjectKeyFromObject(pipelineRun), pipelineRun)
+			if err != nil {
+				return err
+			}
+		}
+		var err error
+		migrated, err = MigratePipelineRunMarkers(ctx, m.Client, pipelineRun, m.LegacyPrefixes, m.Started)
+		return err
+	})
+	if err != nil {
+		return false, fmt.Errorf("Failed to migrate the markers of pipelinerun %s/%s: %w", pipelineRun.Namespace, pipelineRun.Name, err)
+	}
+	return migrated, nil
+}
+
+// MigratePipelineRunMarkers rewrites the markers of the pipelineRun set by earlier versions of the controller,
+// see MarkerMigration, and returns a boolean indicating whether they were rewritten. The pipelineRun must be
+// read with its managed fields. The renamed markers are applied with NotificationFieldManager, and then the
+// legacy markers and the ownership of the markers by other field managers are removed with a merge patch.
+// Return error if the markers could not be rewritten, e.g. on conflicts with other changes of the pipelineRun
+func MigratePipelineRunMarkers(ctx context.Context, c client.Client, pipelineRun *tektonv1.PipelineRun,
+	legacyPrefixes []string, since time.Time) (bool, error) {
+	if len(ForeignMarkerManagers(pipelineRun, since)) > 0 {
+		return false, nil
+	}
+	legacyFinalizers, legacyAnnotations := legacyMarkers(pipelineRun, legacyPrefixes)
+	coOwned := slices.ContainsFunc(pipelineRun.ManagedFields, func(entry metav1.ManagedFieldsEntry) bool {
+		return entry.Manager != NotificationFieldManager && ownsAnyMarker(entry)
+	})
+	if len(legacyFinalizers) == 0 && len(legacyAnnotations) == 0 && !coOwned && !NeedsStateMigration(pipelineRun) {
+		return false, nil
+	}
+
+	renamed := map[string]string{}
+	for legacy, annotation := range legacyAnnotations {
+		if _, ok := pipelineRun.Annotations[annotation]; !ok {
+			renamed[annotation] = pipelineRun.Annotations[legacy]
+		}
+	}
+	err := LoadPipelineRunState(ctx, c, pipelineRun)
+	if err != nil {
+		return false, err
+	}
+	err = applyPipelineRunMetadata(ctx, pipelineRun, c, true, func(applied *tektonv1.PipelineRun) {
+		if len(legacyFinalizers) > 0 {
+			controllerutil.AddFinalizer(applied, NotificationPipelineRunFinalizer)
+		}
+		for annotation, value := range renamed {
+			_ = metadata.SetAnnotation(&applied.ObjectMeta, annotation, value)
+		}
+	})
+	if err != nil {
+		return false, err
+	}
+
+	patch := map[string]any{"resourceVersion": pipelineRun.ResourceVersion}
+	if len(legacyFinalizers) > 0 {
+		patch["finalizers"] = slices.DeleteFunc(slices.Clone(pipelineRun.Finalizers), func(finalizer string) bool {
+			return slices.Contains(legacyFinalizers, finalizer)
+		})
+	}
+	if len(legacyAnnotations) > 0 {
+		removed := map[string]any{}
+		for legacy := range legacyAnnotations {
+			removed[legacy] = nil
+		}
+		patch["annotations"] = removed
+	}
+	if managedFields, changed := disownMarkers(pipelineRun.ManagedFields); changed {
+		patch["managedFields"] = managedFields
+	}
+	encoded, err := json.Marshal(map[string]any{"metadata": patch})
+	if err != nil {
+		return false, fmt.Errorf("Failed to encode marker migration of pipelinerun %s: %w", pipelineRun.Name, err)
+	}
+	err = c.Patch(ctx, pipelineRun, client.RawPatch(types.MergePatchType, encoded), client.FieldOwner(NotificationFieldManager))
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// legacyMarkers returns the finalizers of the legacy prefixes set on the pipelineRun, and its annotations of
+// the legacy prefixes mapped to the annotations of the current prefix they are renamed to
+func legacyMarkers(pipelineRun *tektonv1.PipelineRun, legacyPrefixes []string) ([]string, map[string]string) {
+	prefix := strings.TrimSuffix(NotificationPipelineRunFinalizer, "/notification")
+	var finalizers []string
+	annotations := map[string]string{}
+	for _, legacy := range legacyPrefixes {
+		if legacy == prefix {
+			continue
+		}
+		if controllerutil.ContainsFinalizer(pipelineRun, legacy+"/notification") {
+			finalizers = append(finalizers, legacy+"/notification")
+		}
+		for _, annotation := range ownedPipelineRunAnnotations() {
+			name := legacy + strings.TrimPrefix(annotation, prefix)
+			if _, ok := pipelineRun.Annotations[name]; ok {
+				annotations[name] = annotation
+			}
+		}
+	}
+	return finalizers, annotations
+}
+
+// ownsAnyMarker returns a boolean indicating whether the managed fields entry owns the finalizer or any of the
+// annotations applied with NotificationFieldManager, which server-side apply would not remove while it does
+func ownsAnyMarker(entry metav1.ManagedFieldsEntry) bool {
+	if ownsMarkers(entry) {
+		return true
+	}
+	fields := markerFields{}
+	if entry.FieldsV1 == nil || json.Unmarshal(entry.FieldsV1.Raw, &fields) != nil {
+		return false
+	}
+	return slices.ContainsFunc(ownedPipelineRunAnnotations(), func(annotation string) bool {
+		_, ok := fields.Metadata.Annotations["f:"+annotation]
+		return ok
+	})
+}
+
+// disownMarkers returns the managed fields without the ownership of the finalizer and of the annotations applied
+// with NotificationFieldManager by other field managers, and a boolean indicating whether they changed
+func disownMarkers(managedFields []metav1.ManagedFieldsEntry) ([]metav1.ManagedFieldsEntry, bool) {
+	changed := false
+	disowned := make([]metav1.ManagedFieldsEntry, 0, len(managedFields))
+	for _, entry := range managedFields {
+		if entry.Manager == NotificationFieldManager || !ownsAnyMarker(entry) {
+			disowned = append(disowned, entry)
+			continue
+		}
+		fields := map[string]any{}
+		if json.Unmarshal(entry.FieldsV1.Raw, &fields) != nil {
+			disowned = append(disowned, entry)
+			continue
+		}
+		meta, _ := fields["f:metadata"].(map[string]any)
+		if finalizers, ok := meta["f:finalizers"].(map[string]any); ok {
+			delete(finalizers, `v:"`+NotificationPipelineRunFinalizer+`"`)
+			pruneFields(meta, "f:finalizers")
+		}
+		if annotations, ok := meta["f:annotations"].(map[string]any); ok {
+			for _, annotation := range ownedPipelineRunAnnotations() {
+				delete(annotations, "f:"+annotation)
+			}
+			pruneFields(meta, "f:annotations")
+		}
+		pruneFields(fields, "f:metadata")
+		changed = true
+		if len(fields) == 0 {
+			continue
+		}
+		raw, err := json.Marshal(fields)
+		if err != nil {
+			disowned = append(disowned, entry)
+			continue
+		}
+		entry.FieldsV1 = &metav1.FieldsV1{Raw: raw}
+		disowned = append(disowned, entry)
+	}
+	return disowned, changed
+}
+
+// pruneFields removes the field set from the fields if it only holds the ownership of the set itself
+func pruneFields(fields map[string]any, name string) {
+	set, ok := fields[name].(map[string]any)
+	if !ok {
+		return
+	}
+	if _, self := set["."]; len(set) == 0 || (self && len(set) == 1) {
+		delete(fields, name)
+	}
+}
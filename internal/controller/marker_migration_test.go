@@ -0,0 +1,140 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+var _ = Describe("Marker migration", func() {
+	// markWithMergePatch sets the finalizer and annotations on the pipelinerun with a merge patch of another
+	// field manager, like versions of the controller before server-side apply
+	markWithMergePatch := func(pipelineRun *tektonv1.PipelineRun, finalizer string, annotations map[string]string) {
+		pr := getPipelineRun(pipelineRun)
+		patch := client.MergeFrom(pr.DeepCopy())
+		controllerutil.AddFinalizer(pr, finalizer)
+		pr.Annotations = annotations
+		Expect(k8sClient.Patch(context.Background(), pr, patch, client.FieldOwner("manager"))).To(Succeed())
+	}
+
+	newMigration := func(legacyPrefixes ...string) *MarkerMigration {
+		return &MarkerMigration{
+			Client:         k8sClient,
+			Reader:         k8sClient,
+			LegacyPrefixes: legacyPrefixes,
+			Started:        time.Now().Add(time.Minute),
+			Done:           make(chan struct{}),
+		}
+	}
+
+	It("should rename the markers of legacy prefixes", func() {
+		pipelineRun := createPipelineRun("legacy-prefix", corev1.ConditionTrue)
+		markWithMergePatch(pipelineRun, "old.konflux.ci/notification", map[string]string{"old.konflux.ci/notified": "true"})
+		migrated, err := newMigration("old.konflux.ci").RunOnce(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(migrated).To(BeNumerically(">=", 1))
+
+		pr := getPipelineRun(pipelineRun)
+		Expect(pr.Finalizers).To(Equal([]string{NotificationPipelineRunFinalizer}))
+		Expect(pr.Annotations).To(Equal(map[string]string{NotificationPipelineRunAnnotation: NotificationPipelineRunAnnotationValue}))
+
+		fake := &fakeNotifier{}
+		r := &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Notifier: fake}
+		Expect(reconcilePipelineRun(r, pipelineRun)).To(Succeed())
+		Expect(fake.notifications).To(BeEmpty())
+		Expect(getPipelineRun(pipelineRun).Finalizers).To(BeEmpty())
+	})
+
+	It("should hand over the markers set by other field managers before the controller started", func() {
+		pipelineRun := createPipelineRun("legacy-manager", "")
+		markWithMergePatch(pipelineRun, NotificationPipelineRunFinalizer, nil)
+		_, err := newMigration().RunOnce(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ForeignMarkerManagers(getPipelineRun(pipelineRun), time.Time{})).To(BeEmpty())
+
+		pr := getPipelineRun(pipelineRun)
+		pr.Status.MarkSucceeded("Succeeded", "")
+		Expect(k8sClient.Status().Update(context.Background(), pr)).To(Succeed())
+		fake := &fakeNotifier{}
+		r := &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Notifier: fake}
+		Expect(reconcilePipelineRun(r, pipelineRun)).To(Succeed())
+		Expect(fake.notifications).To(HaveLen(1))
+		Expect(getPipelineRun(pipelineRun).Finalizers).To(BeEmpty())
+	})
+
+	It("should hand over the state annotations set by other field managers", func() {
+		pipelineRun := createPipelineRun("legacy-state", "")
+		markWithMergePatch(pipelineRun, NotificationPipelineRunFinalizer, map[string]string{
+			NotificationThreadsAnnotation:                 `{"default/hook":"1"}`,
+			NotificationAwaitingAcknowledgementAnnotation: "default/hook",
+			NotificationDeliveredAnnotation:               "default/hook",
+		})
+		_, err := newMigration().RunOnce(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		pr := getPipelineRun(pipelineRun)
+		for _, entry := range pr.ManagedFields {
+			if entry.Manager != NotificationFieldManager {
+				Expect(ownsAnyMarker(entry)).To(BeFalse(), "owned by %s", entry.Manager)
+			}
+		}
+
+		Expect(applyPipelineRunMetadata(context.Background(), pr, k8sClient, false, func(applied *tektonv1.PipelineRun) {
+			applied.Finalizers = nil
+			delete(applied.Annotations, NotificationAwaitingAcknowledgementAnnotation)
+			delete(applied.Annotations, NotificationDeliveredAnnotation)
+		})).To(Succeed())
+		pr = getPipelineRun(pipelineRun)
+		Expect(pr.Finalizers).To(BeEmpty())
+		Expect(pr.Annotations).NotTo(HaveKey(NotificationAwaitingAcknowledgementAnnotation))
+		Expect(pr.Annotations).NotTo(HaveKey(NotificationDeliveredAnnotation))
+		Expect(pr.Annotations).To(HaveKey(NotificationThreadsAnnotation))
+	})
+
+	It("should leave the pipelineruns held by another controller to it", func() {
+		pipelineRun := createPipelineRun("held-by-duplicate", "")
+		markWithMergePatch(pipelineRun, NotificationPipelineRunFinalizer, nil)
+		migration := newMigration()
+		migration.Started = time.Time{}
+		_, err := migration.RunOnce(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ForeignMarkerManagers(getPipelineRun(pipelineRun), time.Time{})).To(Equal([]string{"manager"}))
+	})
+
+	It("should not reconcile pipelineruns before the markers are migrated", func() {
+		pipelineRun := createPipelineRun("awaiting-migration", corev1.ConditionTrue)
+		migrated := make(chan struct{})
+		migration := newMigration()
+		migration.Done = migrated
+		fake := &fakeNotifier{}
+		r := &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Notifier: fake, MarkersMigrated: migrated}
+		Expect(reconcilePipelineRun(r, pipelineRun)).To(Succeed())
+		Expect(fake.notifications).To(BeEmpty())
+
+		Expect(migration.Start(context.Background())).To(Succeed())
+		Expect(reconcilePipelineRun(r, pipelineRun)).To(Succeed())
+		Expect(fake.notifications).To(HaveLen(1))
+	})
+})
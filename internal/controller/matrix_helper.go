@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/apis"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// GetMatrixSummary groups the TaskRuns of the pipeline tasks of the pipelineRun that have a matrix by task,
+// with the values of the matrix parameters, the status and the results of every combination, so receivers
+// do not have to tell the combinations apart in the results of the pipelineRun. The matrices are read from
+// the resolved pipeline spec of the pipelineRun. TaskRuns that no longer exist are skipped.
+// It returns nil if no pipeline task has a matrix.
+// Return error if failed to get a TaskRun
+func GetMatrixSummary(ctx context.Context, c client.Reader, pipelineRun *tektonv1.PipelineRun) ([]notifier.MatrixTask, error) {
+	if pipelineRun.Status.PipelineSpec == nil {
+		return nil, nil
+	}
+	var tasks []notifier.MatrixTask
+	params := map[string][]string{}
+	for _, task := range append(slices.Clone(pipelineRun.Status.PipelineSpec.Tasks), pipelineRun.Status.PipelineSpec.Finally...) {
+		if task.Matrix == nil {
+			continue
+		}
+		var names []string
+		for _, param := range task.Matrix.Params {
+			names = append(names, param.Name)
+		}
+		for _, include := range task.Matrix.Include {
+			for _, param := range include.Params {
+				if !slices.Contains(names, param.Name) {
+					names = append(names, param.Name)
+				}
+			}
+		}
+		params[task.Name] = names
+		tasks = append(tasks, notifier.MatrixTask{Name: task.Name})
+	}
+	for _, child := range pipelineRun.Status.ChildReferences {
+		index := slices.IndexFunc(tasks, func(task notifier.MatrixTask) bool { return task.Name == child.PipelineTaskName })
+		if child.Kind != "TaskRun" || index < 0 {
+			continue
+		}
+		taskRun := &tektonv1.TaskRun{}
+		err := c.Get(ctx, types.NamespacedName{Namespace: pipelineRun.Namespace, Name: child.Name}, taskRun)
+		if k8serrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get taskrun %s of pipelinerun %s: %w", child.Name, pipelineRun.Name, err)
+		}
+		combination := notifier.MatrixCombination{
+			TaskRun: child.Name,
+			Params:  []notifier.MatrixParam{},
+			Status:  getConditionStatus(taskRun.Status.GetCondition(apis.ConditionSucceeded)),
+		}
+		for _, name := range params[child.PipelineTaskName] {
+			for _, param := range taskRun.Spec.Params {
+				if param.Name == name {
+					combination.Params = append(combination.Params, notifier.MatrixParam{Name: name, Value: param.Value.StringVal})
+				}
+			}
+		}
+		for _, result := range taskRun.Status.Results {
+			combination.Results = append(combination.Results, getResult(result.Name, result.Value))
+		}
+		tasks[index].Combinations = append(tasks[index].Combinations, combination)
+	}
+	// Tasks that did not run, e.g. because they were skipped, have no combinations
+	tasks = slices.DeleteFunc(tasks, func(task notifier.MatrixTask) bool { return len(task.Combinations) == 0 })
+	if len(tasks) == 0 {
+		return nil, nil
+	}
+	return tasks, nil
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+)
+
+var _ = Describe("Matrix results", func() {
+	createTaskRun := func(name string, platform string, status corev1.ConditionStatus, image string) {
+		taskRun := &tektonv1.TaskRun{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: tektonv1.TaskRunSpec{
+				TaskRef: &tektonv1.TaskRef{Name: "build"},
+				Params: tektonv1.Params{
+					{Name: "PLATFORM", Value: *tektonv1.NewStructuredValues(platform)},
+					{Name: "CONTEXT", Value: *tektonv1.NewStructuredValues(".")},
+				},
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), taskRun)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), taskRun)
+		taskRun.Status.Conditions = duckv1.Conditions{{Type: apis.ConditionSucceeded, Status: status}}
+		if image != "" {
+			taskRun.Status.Results = []tektonv1.TaskRunResult{{Name: "IMAGE_URL", Type: tektonv1.ResultsTypeString, Value: *tektonv1.NewStructuredValues(image)}}
+		}
+		Expect(k8sClient.Status().Update(context.Background(), taskRun)).To(Succeed())
+	}
+
+	It("should group the results of matrix taskruns by combination", func() {
+		createTaskRun("matrix-build-0", "linux/amd64", corev1.ConditionTrue, "quay.io/org/app:amd64")
+		createTaskRun("matrix-build-1", "linux/arm64", corev1.ConditionFalse, "")
+		pipelineRun := &tektonv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "matrix", Namespace: "default"}}
+		pipelineRun.Status.PipelineSpec = &tektonv1.PipelineSpec{Tasks: []tektonv1.PipelineTask{
+			{Name: "clone"},
+			{Name: "build", Matrix: &tektonv1.Matrix{Params: tektonv1.Params{
+				{Name: "PLATFORM", Value: *tektonv1.NewStructuredValues("linux/amd64", "linux/arm64")},
+			}}},
+			{Name: "skipped", Matrix: &tektonv1.Matrix{Params: tektonv1.Params{
+				{Name: "PLATFORM", Value: *tektonv1.NewStructuredValues("linux/s390x")},
+			}}},
+		}}
+		pipelineRun.Status.ChildReferences = []tektonv1.ChildStatusReference{
+			{TypeMeta: runtime.TypeMeta{Kind: "TaskRun"}, Name: "matrix-clone", PipelineTaskName: "clone"},
+			{TypeMeta: runtime.TypeMeta{Kind: "TaskRun"}, Name: "matrix-build-0", PipelineTaskName: "build"},
+			{TypeMeta: runtime.TypeMeta{Kind: "TaskRun"}, Name: "matrix-build-1", PipelineTaskName: "build"},
+			{TypeMeta: runtime.TypeMeta{Kind: "TaskRun"}, Name: "matrix-build-2", PipelineTaskName: "build"},
+		}
+
+		matrix, err := GetMatrixSummary(context.Background(), k8sClient, pipelineRun)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(matrix).To(Equal([]notifier.MatrixTask{{
+			Name: "build",
+			Combinations: []notifier.MatrixCombination{{
+				TaskRun: "matrix-build-0",
+				Params:  []notifier.MatrixParam{{Name: "PLATFORM", Value: "linux/amd64"}},
+				Status:  notifier.StatusSucceeded,
+				Results: []notifier.Result{{Name: "IMAGE_URL", Value: "quay.io/org/app:amd64"}},
+			}, {
+				TaskRun: "matrix-build-1",
+				Params:  []notifier.MatrixParam{{Name: "PLATFORM", Value: "linux/arm64"}},
+				Status:  notifier.StatusFailed,
+			}},
+		}}))
+	})
+
+	It("should ignore pipelineruns without matrix", func() {
+		pipelineRun := &tektonv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "no-matrix", Namespace: "default"}}
+		Expect(GetMatrixSummary(context.Background(), k8sClient, pipelineRun)).To(BeNil())
+		pipelineRun.Status.PipelineSpec = &tektonv1.PipelineSpec{Tasks: []tektonv1.PipelineTask{{Name: "build"}}}
+		Expect(GetMatrixSummary(context.Background(), k8sClient, pipelineRun)).To(BeNil())
+	})
+})
@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// PipelinesAsCodeSenderAnnotation is set by Pipelines as Code to the git user who triggered the pipelinerun
+const PipelinesAsCodeSenderAnnotation string = "pipelinesascode.tekton.dev/sender"
+
+// NamespaceOwnerAnnotation identifies the owner of a namespace, who is mentioned for pipelineruns without a sender
+const NamespaceOwnerAnnotation string = "konflux.ci/owner"
+
+// MentionDirectoryKey is the key of the mention directory ConfigMap holding its entries
+const MentionDirectoryKey string = "directory.yaml"
+
+// DirectoryEntry maps the identities of a person to their contact details
+type DirectoryEntry struct {
+	// Identities are the git user names, git author emails and Kubernetes user names of the person
+	Identities []string `json:"identities"`
+	// Slack is the Slack member ID of the person
+	Slack string `json:"slack,omitempty"`
+	// Email is the email address of the person
+	Email string `json:"email,omitempty"`
+}
+
+// GetPipelineRunAuthor returns the person who triggered the pipelineRun: the Pipelines as Code sender,
+// or the owner of its namespace. The contact details are looked up in the mention directory, if set.
+// Nil is returned if the pipelineRun has no known author.
+func GetPipelineRunAuthor(ctx context.Context, c client.Reader, directory types.NamespacedName, pipelineRun *tektonv1.PipelineRun) (*notifier.Contact, error) {
+	identity := pipelineRun.GetAnnotations()[PipelinesAsCodeSenderAnnotation]
+	if identity == "" {
+		namespace := &corev1.Namespace{}
+		err := c.Get(ctx, types.NamespacedName{Name: pipelineRun.Namespace}, namespace)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get namespace %s: %w", pipelineRun.Namespace, err)
+		}
+		identity = namespace.Annotations[NamespaceOwnerAnnotation]
+	}
+	if identity == "" {
+		return nil, nil
+	}
+	contact := &notifier.Contact{Name: identity}
+	if directory.Name == "" {
+		return contact, nil
+	}
+
+	entries, err := GetMentionDirectory(ctx, c, directory)
+	if err != nil {
+		return contact, err
+	}
+	for _, entry := range entries {
+		for _, entryIdentity := range entry.Identities {
+			if strings.EqualFold(entryIdentity, identity) {
+				contact.Slack = entry.Slack
+				contact.Email = entry.Email
+				return contact, nil
+			}
+		}
+	}
+	return contact, nil
+}
+
+// GetMentionDirectory reads the entries of the mention directory ConfigMap
+// Return error if the ConfigMap does not exist or its entries are malformed
+func GetMentionDirectory(ctx context.Context, c client.Reader, directory types.NamespacedName) ([]DirectoryEntry, error) {
+	configMap := &corev1.ConfigMap{}
+	err := c.Get(ctx, directory, configMap)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get mention directory %s: %w", directory, err)
+	}
+	var entries []DirectoryEntry
+	err = yaml.Unmarshal([]byte(configMap.Data[MentionDirectoryKey]), &entries)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse mention directory %s: %w", directory, err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("Mention directory", func() {
+	directory := types.NamespacedName{Namespace: "default", Name: "mentions"}
+
+	BeforeEach(func() {
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: directory.Name, Namespace: directory.Namespace},
+			Data: map[string]string{MentionDirectoryKey: `
+- identities: [jane, jane@example.com]
+  slack: U123
+  email: jane@example.com
+- identities: [team-owner]
+  email: owners@example.com
+`},
+		}
+		Expect(k8sClient.Create(context.Background(), configMap)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), configMap)
+	})
+
+	It("should resolve the sender of the pipelinerun", func() {
+		pipelineRun := createPipelineRun("sent", "")
+		pipelineRun.Annotations = map[string]string{PipelinesAsCodeSenderAnnotation: "Jane"}
+		author, err := GetPipelineRunAuthor(context.Background(), k8sClient, directory, pipelineRun)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(author).To(Equal(&notifier.Contact{Name: "Jane", Slack: "U123", Email: "jane@example.com"}))
+	})
+
+	It("should fall back to the namespace owner", func() {
+		namespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "owned",
+				Annotations: map[string]string{NamespaceOwnerAnnotation: "team-owner"},
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), namespace)).To(Succeed())
+		pipelineRun := createPipelineRun("unsent", "")
+		pipelineRun.Namespace = "owned"
+		author, err := GetPipelineRunAuthor(context.Background(), k8sClient, directory, pipelineRun)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(author).To(Equal(&notifier.Contact{Name: "team-owner", Email: "owners@example.com"}))
+	})
+
+	It("should return unknown authors without contact details", func() {
+		pipelineRun := createPipelineRun("stranger", "")
+		pipelineRun.Annotations = map[string]string{PipelinesAsCodeSenderAnnotation: "stranger"}
+		author, err := GetPipelineRunAuthor(context.Background(), k8sClient, directory, pipelineRun)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(author).To(Equal(&notifier.Contact{Name: "stranger"}))
+	})
+
+	It("should not resolve pipelineruns without an author", func() {
+		pipelineRun := createPipelineRun("anonymous", "")
+		author, err := GetPipelineRunAuthor(context.Background(), k8sClient, directory, pipelineRun)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(author).To(BeNil())
+	})
+})
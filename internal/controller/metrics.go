@@ -0,0 +1,195 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	"github.com/prometheus/client_golang/prometheus"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Outcomes of the branches of the pipelinerun reconciliation, counted by reconcileOutcomes
+const (
+	outcomeAddedFinalizer   = "added_finalizer"
+	outcomeSkipped          = "skipped"
+	outcomeExtractedResults = "extracted_results"
+	outcomeRemovedFinalizer = "removed_finalizer"
+	outcomeError            = "error"
+	outcomeHandedOff        = "handed_off"
+)
+
+var (
+	reconcileOutcomes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "notification_service_reconcile_outcomes_total",
+		Help: "Number of pipelinerun reconciliations taking each branch, by outcome: added_finalizer, skipped, " +
+			"extracted_results, removed_finalizer, error or handed_off",
+	}, []string{"outcome"})
+	queueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "notification_service_queue_depth",
+		Help: "Number of pipelineruns waiting in the priority queue of the controller, by priority",
+	}, []string{"priority"})
+	deliveriesInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "notification_service_deliveries_in_flight",
+		Help: "Number of notifications being delivered",
+	})
+	deliveriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "notification_service_deliveries_total",
+		Help: "Number of notification deliveries, by result",
+	}, []string{"result"})
+	deliveryDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "notification_service_delivery_duration_seconds",
+		Help:    "Time taken to deliver a notification to a destination",
+		Buckets: prometheus.ExponentialBuckets(0.01, 2, 12),
+	})
+	deliveriesThrottled = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "notification_service_deliveries_throttled_total",
+		Help: "Number of deliveries postponed by the throttling policy",
+	})
+	deliveriesBackedOff = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "notification_service_deliveries_backed_off_total",
+		Help: "Number of deliveries postponed because their destination is backing off after network failures",
+	})
+	enrichmentFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "notification_service_enrichment_failures_total",
+		Help: "Number of failed calls to enrichment hooks, by hook",
+	}, []string{"hook"})
+	transformFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "notification_service_transform_failures_total",
+		Help: "Number of notifications that failed to be loaded or run through a WebAssembly transform, by transform",
+	}, []string{"transform"})
+	notificationsDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "notification_service_notifications_dropped_total",
+		Help: "Number of notifications dropped by a WebAssembly transform, by transform",
+	}, []string{"transform"})
+	deliveriesDeadLettered = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "notification_service_deliveries_dead_lettered_total",
+		Help: "Number of notifications parked in the dead-letter store after exhausting their delivery attempts",
+	})
+	deliveriesHeldBack = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "notification_service_deliveries_held_back_total",
+		Help: "Number of deliveries to ordered destinations postponed until the earlier notifications are delivered",
+	})
+	secretsDetected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "notification_service_secrets_detected_total",
+		Help: "Number of payloads secrets were detected in, by detection rule and action",
+	}, []string{"rule", "action"})
+	destinationReachable = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "notification_service_destination_reachable",
+		Help: "Whether the destination answered its last probe, 1 if it did and 0 otherwise",
+	}, []string{"destination"})
+	dnsLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "notification_service_dns_lookups_total",
+		Help: "Number of resolutions of destination hosts by the DNS cache, by result: hit, miss or error",
+	}, []string{"result"})
+	dnsResolutionErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "notification_service_dns_resolution_errors_total",
+		Help: "Number of failed resolutions of destination hosts, by host",
+	}, []string{"host"})
+	sloDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "notification_service_slo_deliveries_total",
+		Help: "Number of deliveries accounted for by the delivery SLO of a NotificationService, by result: good or bad",
+	}, []string{"notificationservice", "result"})
+	sloBurnRate = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "notification_service_slo_burn_rate",
+		Help: "How fast a NotificationService consumes the error budget of its delivery SLO over its window, 1 consuming it exactly",
+	}, []string{"notificationservice"})
+)
+
+func init() {
+	for _, outcome := range []string{outcomeAddedFinalizer, outcomeSkipped, outcomeExtractedResults, outcomeRemovedFinalizer, outcomeError} {
+		reconcileOutcomes.WithLabelValues(outcome)
+	}
+	metrics.Registry.MustRegister(reconcileOutcomes, queueDepth, deliveriesInFlight, deliveriesTotal, deliveryDuration, deliveriesThrottled,
+		deliveriesBackedOff, deliveriesHeldBack, deliveriesDeadLettered, enrichmentFailures, transformFailures, notificationsDropped,
+		secretsDetected, destinationReachable, dnsLookups, dnsResolutionErrors, sloDeliveries, sloBurnRate)
+}
+
+// ObserveDNSResolution counts a resolution of a destination host by the DNS cache, see notifier.DNSCacheOptions
+func ObserveDNSResolution(host string, cached bool, err error) {
+	switch {
+	case err != nil:
+		dnsLookups.WithLabelValues("error").Inc()
+		if !cached {
+			dnsResolutionErrors.WithLabelValues(host).Inc()
+		}
+	case cached:
+		dnsLookups.WithLabelValues("hit").Inc()
+	default:
+		dnsLookups.WithLabelValues("miss").Inc()
+	}
+}
+
+// ObserveSecretDetection counts a payload a secret was detected in by the rule, see notifier.SecretScannerOptions
+func ObserveSecretDetection(rule string, blocked bool) {
+	action := notifier.SecretActionRedact
+	if blocked {
+		action = notifier.SecretActionBlock
+	}
+	secretsDetected.WithLabelValues(rule, action).Inc()
+}
+
+// RegisterBacklogMetric registers the notification_service_pending_pipelineruns gauge, the number of
+// pipelineruns that ended and are not handled yet, counted through PendingPipelineRunIndex of the cache.
+// Only the pipelineruns selects returns true for are counted, all of them if it is nil.
+// Return error if the gauge was already registered
+func RegisterBacklogMetric(c client.Reader, selects func(*tektonv1.PipelineRun) bool) error {
+	err := metrics.Registry.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "notification_service_pending_pipelineruns",
+		Help: "Number of pipelineruns that ended and were not notified about or released yet",
+	}, func() float64 {
+		pipelineRuns := &tektonv1.PipelineRunList{}
+		err := c.List(context.Background(), pipelineRuns, client.MatchingFields{PendingPipelineRunIndex: PendingPipelineRunIndexValue})
+		if err != nil {
+			return 0
+		}
+		if selects == nil {
+			return float64(len(pipelineRuns.Items))
+		}
+		count := 0
+		for i := range pipelineRuns.Items {
+			if selects(&pipelineRuns.Items[i]) {
+				count++
+			}
+		}
+		return float64(count)
+	}))
+	if err != nil {
+		return fmt.Errorf("Failed to register the pending pipelineruns metric: %w", err)
+	}
+	return nil
+}
+
+// startDelivery counts a delivery in flight until the returned function is called with its outcome
+func startDelivery() func(err error) {
+	start := time.Now()
+	deliveriesInFlight.Inc()
+	return func(err error) {
+		deliveriesInFlight.Dec()
+		deliveryDuration.Observe(time.Since(start).Seconds())
+		result := "success"
+		if err != nil {
+			result = "failure"
+		}
+		deliveriesTotal.WithLabelValues(result).Inc()
+	}
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+)
+
+var _ = Describe("Metrics", func() {
+	It("should count deliveries by result", func() {
+		succeeded := testutil.ToFloat64(deliveriesTotal.WithLabelValues("success"))
+		failed := testutil.ToFloat64(deliveriesTotal.WithLabelValues("failure"))
+		done := startDelivery()
+		Expect(testutil.ToFloat64(deliveriesInFlight)).To(BeNumerically(">=", 1))
+		done(nil)
+		startDelivery()(errors.New("receiver is down"))
+		Expect(testutil.ToFloat64(deliveriesTotal.WithLabelValues("success"))).To(Equal(succeeded + 1))
+		Expect(testutil.ToFloat64(deliveriesTotal.WithLabelValues("failure"))).To(Equal(failed + 1))
+	})
+
+	It("should count the outcomes of the reconciliation branches", func() {
+		outcomes := map[string]float64{}
+		for _, outcome := range []string{outcomeAddedFinalizer, outcomeSkipped, outcomeExtractedResults, outcomeRemovedFinalizer, outcomeError} {
+			outcomes[outcome] = testutil.ToFloat64(reconcileOutcomes.WithLabelValues(outcome))
+		}
+		fake := &fakeNotifier{err: errors.New("receiver is down")}
+		pipelineRun := createPipelineRun("outcomes", "")
+		r := &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Notifier: fake}
+		Expect(reconcilePipelineRun(r, pipelineRun)).To(Succeed())
+		Expect(testutil.ToFloat64(reconcileOutcomes.WithLabelValues(outcomeAddedFinalizer))).To(Equal(outcomes[outcomeAddedFinalizer] + 1))
+
+		pr := getPipelineRun(pipelineRun)
+		pr.Status.Conditions = duckv1.Conditions{{Type: apis.ConditionSucceeded, Status: corev1.ConditionTrue}}
+		Expect(k8sClient.Status().Update(context.Background(), pr)).To(Succeed())
+		Expect(reconcilePipelineRun(r, pipelineRun)).NotTo(Succeed())
+		Expect(testutil.ToFloat64(reconcileOutcomes.WithLabelValues(outcomeError))).To(Equal(outcomes[outcomeError] + 1))
+
+		fake.err = nil
+		Expect(reconcilePipelineRun(r, pipelineRun)).To(Succeed())
+		Expect(reconcilePipelineRun(r, pipelineRun)).To(Succeed())
+		Expect(testutil.ToFloat64(reconcileOutcomes.WithLabelValues(outcomeExtractedResults))).To(Equal(outcomes[outcomeExtractedResults] + 1))
+		Expect(testutil.ToFloat64(reconcileOutcomes.WithLabelValues(outcomeRemovedFinalizer))).To(Equal(outcomes[outcomeRemovedFinalizer] + 1))
+		Expect(testutil.ToFloat64(reconcileOutcomes.WithLabelValues(outcomeSkipped))).To(Equal(outcomes[outcomeSkipped] + 1))
+	})
+
+	It("should report the depth of the priority queue", func() {
+		queue := NewPriorityQueue(func(item any) bool { return item == "failed" })
+		queue.Add("failed")
+		queue.Add("succeeded")
+		Expect(testutil.ToFloat64(queueDepth.WithLabelValues("high"))).To(Equal(1.0))
+		Expect(testutil.ToFloat64(queueDepth.WithLabelValues("normal"))).To(Equal(1.0))
+		item, _ := queue.Get()
+		queue.Done(item)
+		Expect(testutil.ToFloat64(queueDepth.WithLabelValues("high"))).To(BeZero())
+	})
+
+	It("should count DNS resolutions and their errors by host", func() {
+		hits := testutil.ToFloat64(dnsLookups.WithLabelValues("hit"))
+		errs := testutil.ToFloat64(dnsResolutionErrors.WithLabelValues("missing.example.com"))
+		ObserveDNSResolution("hooks.example.com", true, nil)
+		ObserveDNSResolution("missing.example.com", false, errors.New("no such host"))
+		ObserveDNSResolution("missing.example.com", true, errors.New("no such host"))
+		Expect(testutil.ToFloat64(dnsLookups.WithLabelValues("hit"))).To(Equal(hits + 1))
+		Expect(testutil.ToFloat64(dnsResolutionErrors.WithLabelValues("missing.example.com"))).To(Equal(errs + 1))
+	})
+})
@@ -0,0 +1,74 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DefaultDeliveryRecordTTL is the default time NotificationDelivery records are kept for
+const DefaultDeliveryRecordTTL = 7 * 24 * time.Hour
+
+// NotificationDeliveryReconciler removes NotificationDelivery records once their TTL expires
+type NotificationDeliveryReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+	// TTL is the time records are kept for, measured from their creation
+	TTL time.Duration
+}
+
+// +kubebuilder:rbac:groups=konflux.ci,resources=notificationdeliveries,verbs=get;list;watch;create;delete
+
+// Reconcile deletes the NotificationDelivery if it is older than the TTL,
+// otherwise it is requeued for the time it expires
+func (r *NotificationDeliveryReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := r.Log.WithValues("notificationdelivery", req.NamespacedName)
+	delivery := &v1alpha1.NotificationDelivery{}
+
+	err := r.Get(ctx, req.NamespacedName, delivery)
+	if err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	expiry := delivery.CreationTimestamp.Add(r.TTL)
+	if remaining := time.Until(expiry); remaining > 0 {
+		return ctrl.Result{RequeueAfter: remaining}, nil
+	}
+
+	logger.Info("Deleting expired delivery record")
+	err = r.Delete(ctx, delivery)
+	if err != nil {
+		logger.Error(err, "Failed to delete expired delivery record")
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *NotificationDeliveryReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.NotificationDelivery{}).
+		Complete(r)
+}
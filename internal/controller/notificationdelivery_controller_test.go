@@ -0,0 +1,90 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("NotificationDelivery Controller", func() {
+	Context("When reconciling a delivery record", func() {
+		var delivery *v1alpha1.NotificationDelivery
+
+		BeforeEach(func() {
+			delivery = &v1alpha1.NotificationDelivery{
+				ObjectMeta: metav1.ObjectMeta{
+					GenerateName: "build-",
+					Namespace:    "default",
+				},
+				Spec: v1alpha1.NotificationDeliverySpec{
+					PipelineRun: "build",
+					Destination: "default/notifications/webhook",
+					PayloadHash: "sha256:0",
+					Succeeded:   true,
+				},
+			}
+			Expect(k8sClient.Create(context.Background(), delivery)).To(Succeed())
+			DeferCleanup(func() {
+				_ = k8sClient.Delete(context.Background(), delivery)
+			})
+		})
+
+		reconcile := func(ttl time.Duration) ctrl.Result {
+			r := &NotificationDeliveryReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), TTL: ttl}
+			result, err := r.Reconcile(context.Background(), ctrl.Request{
+				NamespacedName: types.NamespacedName{Name: delivery.Name, Namespace: delivery.Namespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			return result
+		}
+
+		It("should keep records until their TTL expires", func() {
+			result := reconcile(time.Hour)
+			Expect(result.RequeueAfter).To(BeNumerically(">", 59*time.Minute))
+			Expect(k8sClient.Get(context.Background(), types.NamespacedName{
+				Name: delivery.Name, Namespace: delivery.Namespace,
+			}, &v1alpha1.NotificationDelivery{})).To(Succeed())
+		})
+
+		It("should delete expired records", func() {
+			result := reconcile(0)
+			Expect(result.RequeueAfter).To(BeZero())
+			err := k8sClient.Get(context.Background(), types.NamespacedName{
+				Name: delivery.Name, Namespace: delivery.Namespace,
+			}, &v1alpha1.NotificationDelivery{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should ignore records that no longer exist", func() {
+			r := &NotificationDeliveryReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+			_, err := r.Reconcile(context.Background(), ctrl.Request{
+				NamespacedName: types.NamespacedName{Name: "missing", Namespace: "default"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+})
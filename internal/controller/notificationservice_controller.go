@@ -18,92 +18,791 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"github.com/konflux-ci/notification-service/pkg/audit"
+	"github.com/konflux-ci/notification-service/pkg/delivery"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
 	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlbuilder "sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 // NotificationServiceReconciler reconciles a NotificationService object
 type NotificationServiceReconciler struct {
 	client.Client
-	Log    logr.Logger
-	Scheme *runtime.Scheme
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Notifier notifier.Notifier
+	// Recorder emits an event on the PipelineRun for every delivery attempt, if set
+	Recorder record.EventRecorder
+	// RecordDeliveries enables recording every delivery attempt as a NotificationDelivery
+	RecordDeliveries bool
+	// AuditLog records every outbound notification in a tamper-evident log, if set
+	AuditLog *audit.Log
+	// SLOTracker accounts for the deliveries to the destinations of NotificationServices with a delivery SLO, if set
+	SLOTracker *SLOTracker
+	// StatusSummarizer counts the deliveries to the destinations of NotificationServices for their status, if set
+	StatusSummarizer *StatusSummarizer
+	// CallbackURL is the external URL of the CallbackServer. If it is not set,
+	// destinations are not waited for to acknowledge notifications.
+	CallbackURL string
+	// CallbackSecret signs the callback tokens sent to destinations
+	CallbackSecret []byte
+	// MentionDirectory is the ConfigMap mapping the authors of pipelineruns to their contact details, if set
+	MentionDirectory types.NamespacedName
+	// NamespaceRouting sends notifications to the destinations declared in the annotations
+	// of the pipelinerun namespace, in addition to the NotificationServices
+	NamespaceRouting bool
+	// TenantDirectory is the ConfigMap registering the contact channels of Konflux tenants, if set.
+	// With NamespaceRouting, namespaces without routing annotations are routed to the channels of their tenant.
+	TenantDirectory types.NamespacedName
+	// MaintenanceWindows silences the destinations selected by the MaintenanceWindows and ClusterMaintenanceWindows
+	// in progress, see GetActiveMaintenanceWindows
+	MaintenanceWindows bool
+	// SlackToken is the bot token used for Slack channels declared in namespace annotations
+	SlackToken string
+	// History tracks the outcomes of pipelines to detect failure streaks and flaky pipelines, if set
+	History *PipelineHistory
+	// Sweeps delivers the pending pipelineruns found by the PipelineRunSweeper, if set
+	Sweeps <-chan event.GenericEvent
+	// BestEffort sends notifications without holding pipelineruns with a finalizer, for the
+	// default notifier, the namespace routes and the NotificationServices that do not set bestEffort
+	BestEffort bool
+	// PrioritizeFailures reconciles the pipelineruns that failed before the other queued pipelineruns
+	PrioritizeFailures bool
+	// DefaultNamespace holds the NotificationServices applying to the pipelineruns of the namespaces
+	// without NotificationServices, if set
+	DefaultNamespace string
+	// ConfigFile provides the configuration reloaded from the configuration file, if set
+	ConfigFile *ConfigFile
+	// Concurrency is the number of pipelineruns reconciled in parallel when the configuration does not set it
+	Concurrency int
+	// PipelineRunSelector restricts the pipelineruns that are notified about when the configuration does not
+	// set a selector, if set
+	PipelineRunSelector labels.Selector
+	// WaitForChains is how long ended pipelineruns wait for Tekton Chains to sign them before they are
+	// notified about. Zero disables waiting.
+	WaitForChains time.Duration
+	// Overflow uploads notifications whose results are too large to object storage, if set
+	Overflow *PayloadOverflow
+	// Archive uploads every notification to the object storage of the audit trail and links to it, if set
+	Archive *PayloadArchive
+	// Enrichers are in-process plugins adding context to notifications before they are rendered, called
+	// before the enrichment hooks of the configuration file
+	Enrichers []notifier.Enricher
+	// Transforms runs the WebAssembly transforms of the configuration file on notifications before they are delivered
+	Transforms *WasmTransforms
+	// ProvenanceBuilderID is the builder ID of the provenance of pipelineruns without Tekton Chains attestation
+	ProvenanceBuilderID string
+	// ListPageSize is the number of objects read per request by the lists sent to the API server, e.g. of
+	// watched resources, all at once if zero
+	ListPageSize int64
+	// Started is when the controller started. Pipelineruns whose finalizer or notified annotation were set by other
+	// field managers since then are handed off to them, see ForeignMarkerManagers.
+	Started time.Time
+	// MarkersMigrated is closed once the markers of existing pipelineruns are migrated, see MarkerMigration.
+	// Pipelineruns are not reconciled before, if set.
+	MarkersMigrated <-chan struct{}
+
+	throttle  DeliveryThrottle
+	sequencer DeliverySequencer
+	backoff   DestinationBackoff
 }
 
-// +kubebuilder:rbac:groups=konflux-ci.com,resources=notificationservices,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups=konflux-ci.com,resources=notificationservices/status,verbs=get;update;patch
-// +kubebuilder:rbac:groups=konflux-ci.com,resources=notificationservices/finalizers,verbs=update
+// +kubebuilder:rbac:groups=konflux.ci,resources=notificationservices,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=konflux.ci,resources=notificationservices/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=konflux.ci,resources=notificationservices/finalizers,verbs=update
+// +kubebuilder:rbac:groups=konflux.ci,resources=clusternotificationservices,verbs=get;list;watch
+// +kubebuilder:rbac:groups=konflux.ci,resources=notificationdeliveries,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups=konflux.ci,resources=notificationtemplates,verbs=get;list;watch
+// +kubebuilder:rbac:groups=konflux.ci,resources=notificationstates,verbs=get;list;watch;create;update;delete
 // +kubebuilder:rbac:groups=tekton.dev,resources=pipelineruns,verbs=get;list;watch;create;update;patch;delete;deletecollection
 // +kubebuilder:rbac:groups=tekton.dev,resources=pipelineruns/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=tekton.dev,resources=pipelineruns/finalizers,verbs=update
-// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=tekton.dev,resources=taskruns,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update
+// +kubebuilder:rbac:groups="",resources=configmaps;namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=create;patch;delete
+// +kubebuilder:rbac:groups="",resources=pods/log,verbs=get
+// +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch
+// +kubebuilder:rbac:groups=discovery.k8s.io,resources=endpointslices,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+// +kubebuilder:rbac:groups=authentication.k8s.io,resources=tokenreviews,verbs=create
 
 // Reconcile will monitor the pipelinerun, extract its result and send it as a webhook
 // When a pipelinerun is created, it will add a finalizer to it so we will be able to extract the results
-// After a pipelinerun ends successfully, the results will be extracted from it and will be sent as a webhook,
+// After a pipelinerun ends, the results will be extracted from it and will be sent as a webhook,
 // An annotation will be added to mark this pipelinerun as handled and the finalizer will be rmoved
 // to allow the deletion of this pipelinerun
 func (r *NotificationServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 
 	logger := r.Log.WithValues("pipelinerun", req.NamespacedName)
+	if r.MarkersMigrated != nil {
+		select {
+		case <-r.MarkersMigrated:
+		default:
+			return ctrl.Result{RequeueAfter: markerMigrationRequeue}, nil
+		}
+	}
 	pipelineRun := &tektonv1.PipelineRun{}
 
 	err := r.Get(ctx, req.NamespacedName, pipelineRun)
 	if err != nil {
 		logger.Error(err, "Failed to get pipelineRun for", "req", req.NamespacedName)
-		if errors.IsNotFound(err) {
+		if k8serrors.IsNotFound(err) {
 			return ctrl.Result{}, nil
 		}
+		reconcileOutcomes.WithLabelValues(outcomeError).Inc()
 		return ctrl.Result{}, err
 	}
-
 	if IsAnnotationExistInPipelineRun(pipelineRun, NotificationPipelineRunAnnotation, NotificationPipelineRunAnnotationValue) &&
 		!IsFinalizerExistInPipelineRun(pipelineRun, NotificationPipelineRunFinalizer) {
 		logger.Info("No need to reconcile pipelinerun %s", pipelineRun.Name)
+		reconcileOutcomes.WithLabelValues(outcomeSkipped).Inc()
+		return ctrl.Result{}, nil
+	}
+	if managers := ForeignMarkerManagers(pipelineRun, r.Started); len(managers) > 0 {
+		logger.Info("Another controller holds the notification markers of pipelinerun, handing it off", "managers", managers)
+		reconcileOutcomes.WithLabelValues(outcomeHandedOff).Inc()
+		if r.Recorder != nil {
+			r.Recorder.Eventf(pipelineRun, corev1.EventTypeWarning, DuplicateControllerReason,
+				"Handed off to %s, which set the notification markers", strings.Join(managers, ", "))
+		}
+		err = r.setDuplicateControllerCondition(ctx, pipelineRun.Namespace, pipelineRun.Name, managers)
+		if err != nil {
+			logger.Error(err, "Failed to report the duplicate controller")
+		}
+		return ctrl.Result{}, nil
+	}
+	// The state of handled pipelineruns is not read, so their NotificationState may be collected
+	err = LoadPipelineRunState(ctx, r.Client, pipelineRun)
+	if err != nil {
+		logger.Error(err, "Failed to load the notification state of pipelinerun")
+		reconcileOutcomes.WithLabelValues(outcomeError).Inc()
+		return ctrl.Result{}, err
+	}
+	if NeedsStateMigration(pipelineRun) {
+		err = applyPipelineRunMetadata(ctx, pipelineRun, r.Client, false, func(*tektonv1.PipelineRun) {})
+		if err != nil {
+			logger.Error(err, "Failed to move the notification state of pipelinerun out of its annotations")
+			reconcileOutcomes.WithLabelValues(outcomeError).Inc()
+			return ctrl.Result{}, err
+		}
+	}
+
+	if !r.ReconcilesPendingPipelineRun(pipelineRun) {
+		reconcileOutcomes.WithLabelValues(outcomeSkipped).Inc()
 		return ctrl.Result{}, nil
 	}
 
 	logger.Info("Reconciling PipelineRun", "Name", pipelineRun.Name)
 	if !IsFinalizerExistInPipelineRun(pipelineRun, NotificationPipelineRunFinalizer) &&
 		!IsAnnotationExistInPipelineRun(pipelineRun, NotificationPipelineRunAnnotation, NotificationPipelineRunAnnotationValue) {
-		err = AddFinalizerToPipelineRun(ctx, pipelineRun, r, NotificationPipelineRunFinalizer)
+		needsFinalizer, err := NeedsFinalizer(ctx, r, pipelineRun.Namespace)
+		if err != nil {
+			logger.Error(err, "Failed to check whether pipelinerun needs a finalizer ", pipelineRun.Name)
+			reconcileOutcomes.WithLabelValues(outcomeError).Inc()
+		}
+		if needsFinalizer || err != nil {
+			err = AddFinalizerToPipelineRun(ctx, pipelineRun, r, NotificationPipelineRunFinalizer)
+			if err != nil {
+				logger.Error(err, "Failed to add finalizer to pipelinerun ", pipelineRun.Name)
+				reconcileOutcomes.WithLabelValues(outcomeError).Inc()
+			} else {
+				reconcileOutcomes.WithLabelValues(outcomeAddedFinalizer).Inc()
+			}
+		}
+	}
+
+	if IsPipelineRunRunning(pipelineRun) {
+		requeueAfter, err := r.notifyLifecycle(ctx, pipelineRun)
 		if err != nil {
-			logger.Error(err, "Failed to add finalizer to pipelinerun ", pipelineRun.Name)
+			logger.Error(err, "Failed to send lifecycle notifications for pipelinerun ", pipelineRun.Name)
 		}
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
 	}
 
-	if IsPipelineRunEndedSuccessfully(pipelineRun) &&
+	if IsPipelineRunEnded(pipelineRun) &&
 		!IsAnnotationExistInPipelineRun(pipelineRun, NotificationPipelineRunAnnotation, NotificationPipelineRunAnnotationValue) {
+		if remaining := ChainsPendingTimeout(pipelineRun, r.WaitForChains, time.Now()); remaining > 0 {
+			logger.Info("Waiting for Tekton Chains to sign pipelinerun", "timeout", remaining)
+			return ctrl.Result{RequeueAfter: remaining}, nil
+		}
 		results, err := GetResultsFromPipelineRun(pipelineRun)
 		if err != nil {
 			logger.Error(err, "Failed to get results for pipelineRun ", pipelineRun.Name)
+			reconcileOutcomes.WithLabelValues(outcomeError).Inc()
 		} else {
 			fmt.Printf("Results for pipelinerun %s are: %s\n", pipelineRun.Name, results)
-			err = AddAnnotationToPipelineRun(ctx, pipelineRun, r, NotificationPipelineRunAnnotation, NotificationPipelineRunAnnotationValue)
+			notifyErr := r.notify(ctx, pipelineRun)
+			if errors.Is(notifyErr, ErrDeliveryThrottled) {
+				retryAfter := r.ConfigFile.Get().ThrottleRetryAfter()
+				logger.Info("Deliveries are throttled", "retryAfter", retryAfter)
+				return ctrl.Result{RequeueAfter: retryAfter}, nil
+			}
+			if backoff := (*BackoffError)(nil); errors.As(notifyErr, &backoff) {
+				logger.Info("Destinations are backing off", "until", backoff.Until)
+				return ctrl.Result{RequeueAfter: time.Until(backoff.Until)}, nil
+			}
+			if notifyErr != nil {
+				logger.Error(notifyErr, "Failed to send notification for pipelinerun ", pipelineRun.Name)
+				reconcileOutcomes.WithLabelValues(outcomeError).Inc()
+				return ctrl.Result{}, notifyErr
+			}
+			reconcileOutcomes.WithLabelValues(outcomeExtractedResults).Inc()
+			err = r.setDuplicateControllerCondition(ctx, pipelineRun.Namespace, pipelineRun.Name, nil)
 			if err != nil {
-				logger.Error(err, "Failed to add annotation")
+				logger.Error(err, "Failed to clear the duplicate controller condition")
 			}
 		}
 	}
 
-	if IsPipelineRunEndedSuccessfully(pipelineRun) &&
+	if IsPipelineRunEnded(pipelineRun) &&
 		IsAnnotationExistInPipelineRun(pipelineRun, NotificationPipelineRunAnnotation, NotificationPipelineRunAnnotationValue) {
+		deadlines, err := GetAcknowledgementDeadlines(pipelineRun)
+		if err != nil {
+			logger.Error(err, "Failed to get acknowledgement deadlines")
+		}
+		if remaining := PendingAcknowledgementTimeout(deadlines, time.Now()); remaining > 0 {
+			logger.Info("Waiting for notification acknowledgements", "destinations", len(deadlines), "timeout", remaining)
+			return ctrl.Result{RequeueAfter: remaining}, nil
+		}
+		for destination := range deadlines {
+			logger.Info("Notification was not acknowledged in time", "destination", destination)
+		}
+		held := IsFinalizerExistInPipelineRun(pipelineRun, NotificationPipelineRunFinalizer)
 		err = RemoveFinalizerFromPipelineRun(ctx, pipelineRun, r, NotificationPipelineRunFinalizer)
 		if err != nil {
 			logger.Error(err, "Failed to remove finalizer to pipelinerun ", pipelineRun.Name)
+			reconcileOutcomes.WithLabelValues(outcomeError).Inc()
+		} else if held {
+			reconcileOutcomes.WithLabelValues(outcomeRemovedFinalizer).Inc()
 		}
 	}
 	return ctrl.Result{}, nil
 }
 
+// notify sends the notification for the pipelinerun to the configured notifier
+// and to the destinations of all NotificationServices.
+// Destinations the notification was already delivered to are skipped. The pending destinations are written to
+// the outbox of the pipelinerun before the notification is sent, and the outcome of the deliveries is committed
+// in a single update: the destinations it was delivered to, so a failed delivery is retried only for its
+// destination, the acknowledgement deadlines, and the notified annotation once every destination was delivered
+// to or parked in the dead-letter store.
+func (r *NotificationServiceReconciler) notify(ctx context.Context, pipelineRun *tektonv1.PipelineRun) error {
+	team, destinations, err := r.getDestinations(ctx, pipelineRun)
+	if err != nil {
+		return err
+	}
+	delivered, err := GetDeliveredDestinations(pipelineRun)
+	if err != nil {
+		r.Log.Error(err, "Ignoring malformed delivered destinations")
+	}
+	outbox, err := GetNotificationOutbox(pipelineRun)
+	if err != nil {
+		r.Log.Error(err, "Ignoring malformed notification outbox")
+	}
+	if len(destinations) == 0 {
+		return CommitNotification(ctx, pipelineRun, r.Client, delivered, outbox, nil, true)
+	}
+	notification, err := r.buildNotification(ctx, pipelineRun)
+	if err != nil {
+		return err
+	}
+	notification.Team = team
+	if key := PipelineKey(pipelineRun); r.History != nil && key != "" {
+		notification.FailureStreak, notification.Flaky =
+			r.History.Record(key, pipelineRun.UID, notification.Status == notifier.StatusSucceeded)
+	}
+	destinations = FilterEventDestinations(destinations, notification)
+	destinations = FilterEscalationDestinations(destinations, notification)
+	destinations = FilterPolicyDestinations(destinations, notification)
+	destinations = FilterResultDestinations(destinations, notification)
+	destinations = FilterDeliveredDestinations(destinations, delivered)
+	destinations = FilterDeadLetteredDestinations(destinations, outbox)
+	destinations, paused := SplitPausedDestinations(destinations)
+	if len(paused) > 0 {
+		_, err = r.skip(ctx, pipelineRun, paused, "paused destinations")
+		if err != nil {
+			return err
+		}
+	}
+	if r.MaintenanceWindows {
+		windows, err := GetActiveMaintenanceWindows(ctx, r.Client, r.Log, pipelineRun.Namespace, time.Now())
+		if err != nil {
+			return err
+		}
+		var suppressed []DestinationNotifier
+		var suppressing map[string]*ActiveMaintenanceWindow
+		destinations, suppressed, suppressing = SplitSuppressedDestinations(destinations, windows, pipelineRun)
+		if len(suppressed) > 0 {
+			names, err := r.skip(ctx, pipelineRun, suppressed, "maintenance windows")
+			if err != nil {
+				return err
+			}
+			// The counts only feed the summaries of the windows, so failing to record them does not fail the notification
+			err = RecordSuppressedNotifications(ctx, r.Client, pipelineRun.Namespace, names, suppressing)
+			if err != nil {
+				r.Log.Error(err, "Failed to record suppressed notifications", "name", pipelineRun.Name)
+			}
+		}
+	}
+	if len(destinations) == 0 {
+		return CommitNotification(ctx, pipelineRun, r.Client, delivered, outbox, nil, true)
+	}
+	err = r.Overflow.Apply(ctx, pipelineRun, notification)
+	if err != nil {
+		return err
+	}
+	intent := false
+	for _, destination := range destinations {
+		if _, ok := outbox[destination.Name]; !ok {
+			outbox[destination.Name] = OutboxEntry{}
+			intent = true
+		}
+	}
+	if intent {
+		err = SetNotificationOutbox(ctx, pipelineRun, r.Client, outbox)
+		if err != nil {
+			return err
+		}
+	}
+	deadlines, completed, err := r.deliver(ctx, pipelineRun, destinations, notification, true, outbox)
+	now := time.Now().UTC().Truncate(time.Second)
+	for _, destination := range completed {
+		delivered[destination] = now
+		delete(outbox, destination)
+	}
+	commitErr := CommitNotification(ctx, pipelineRun, r.Client, delivered, outbox, deadlines, err == nil)
+	return errors.Join(err, commitErr)
+}
+
+// getDestinations returns the team of the pipelineRun, set with namespace routing, and the destinations notified
+// about it: the destinations of its namespace, see GetDestinationNotifiers, its namespace routes and the default notifier
+func (r *NotificationServiceReconciler) getDestinations(ctx context.Context, pipelineRun *tektonv1.PipelineRun) (string, []DestinationNotifier, error) {
+	destinations, err := GetDestinationNotifiers(ctx, r, pipelineRun.Namespace)
+	if err != nil {
+		return "", nil, err
+	}
+	var team string
+	if r.NamespaceRouting {
+		var namespaceDestinations []DestinationNotifier
+		team, namespaceDestinations, err = GetNamespaceRouting(ctx, r, pipelineRun)
+		if err != nil {
+			return "", nil, err
+		}
+		destinations = append(destinations, namespaceDestinations...)
+	}
+	if r.Notifier != nil {
+		destinations = append(destinations, DestinationNotifier{Name: DefaultDestinationName, Notifier: r.Notifier})
+	}
+	return team, destinations, nil
+}
+
+// buildNotification builds the notification for the pipelinerun, including its author, timing, signature status, provenance
+// and matrix results, and the values of the enrichment hooks. Failures to resolve the author, the timing, the provenance,
+// the matrix results or the enrichment are logged and the notification is sent without them.
+func (r *NotificationServiceReconciler) buildNotification(ctx context.Context, pipelineRun *tektonv1.PipelineRun) (*notifier.Notification, error) {
+	notification, err := GetNotificationFromPipelineRun(pipelineRun)
+	if err != nil {
+		return nil, err
+	}
+	notification.Chains = GetChainsSignature(pipelineRun, r.WaitForChains > 0)
+	notification.Provenance, err = GetProvenanceSummary(pipelineRun, r.ProvenanceBuilderID)
+	if err != nil {
+		r.Log.Error(err, "Failed to get the provenance of pipelinerun", "name", pipelineRun.Name)
+	}
+	notification.Konflux = GetKonfluxContext(pipelineRun)
+	notification.Matrix, err = GetMatrixSummary(ctx, r.Client, pipelineRun)
+	if err != nil {
+		r.Log.Error(err, "Failed to get the matrix results of pipelinerun", "name", pipelineRun.Name)
+	}
+	notification.Author, err = GetPipelineRunAuthor(ctx, r.Client, r.MentionDirectory, pipelineRun)
+	if err != nil {
+		r.Log.Error(err, "Failed to resolve the author of pipelinerun", "name", pipelineRun.Name)
+	}
+	err = SetNotificationTiming(ctx, r.Client, pipelineRun, notification, time.Now())
+	if err != nil {
+		r.Log.Error(err, "Failed to get the timing of pipelinerun", "name", pipelineRun.Name)
+	}
+	r.enrich(ctx, notification)
+	return notification, nil
+}
+
+// skip marks the pipelinerun as skipped by the destinations it was not marked for yet, given the cause of
+// the skip, and returns the names of these destinations
+func (r *NotificationServiceReconciler) skip(ctx context.Context, pipelineRun *tektonv1.PipelineRun, destinations []DestinationNotifier, cause string) ([]string, error) {
+	skipped, err := GetSkippedDestinations(pipelineRun)
+	if err != nil {
+		r.Log.Error(err, "Ignoring malformed skipped destinations")
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	var names []string
+	for _, destination := range destinations {
+		if _, ok := skipped[destination.Name]; !ok {
+			skipped[destination.Name] = now
+			names = append(names, destination.Name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	err = SetSkippedDestinations(ctx, pipelineRun, r.Client, skipped)
+	if err != nil {
+		return nil, err
+	}
+	if r.Recorder != nil {
+		r.Recorder.Event(pipelineRun, corev1.EventTypeNormal, NotificationSkippedReason,
+			"Notification skipped by "+cause+" "+strings.Join(names, ", "))
+	}
+	return names, nil
+}
+
+// notifyLifecycle sends the started and long running notifications of a running pipelinerun to the
+// destinations that enabled them. These notifications are best effort and are not retried.
+// It returns when the pipelinerun has to be reconciled again for the next long running notification.
+func (r *NotificationServiceReconciler) notifyLifecycle(ctx context.Context, pipelineRun *tektonv1.PipelineRun) (time.Duration, error) {
+	destinations, err := GetDestinationNotifiers(ctx, r, pipelineRun.Namespace)
+	if err != nil {
+		return 0, err
+	}
+	destinations, _ = SplitPausedDestinations(destinations)
+	if r.MaintenanceWindows {
+		windows, err := GetActiveMaintenanceWindows(ctx, r.Client, r.Log, pipelineRun.Namespace, time.Now())
+		if err != nil {
+			return 0, err
+		}
+		destinations, _, _ = SplitSuppressedDestinations(destinations, windows, pipelineRun)
+	}
+	sent, err := GetLifecycleNotifications(pipelineRun)
+	if err != nil {
+		r.Log.Error(err, "Ignoring malformed lifecycle notifications")
+	}
+
+	elapsed := time.Since(pipelineRun.Status.StartTime.Time)
+	var started, running []DestinationNotifier
+	var requeueAfter, threshold time.Duration
+	for _, destination := range destinations {
+		if destination.EscalateAfterFailures > 0 || len(destination.PolicyOutcomes) > 0 || len(destination.ResultConditions) > 0 {
+			continue
+		}
+		if (destination.NotifyOnStart && len(destination.Events) == 0 || destination.subscribes(notifier.EventStarted)) &&
+			sent[destination.Name] == "" {
+			started = append(started, destination)
+		}
+		if destination.LongRunningThreshold <= 0 || sent[destination.Name] == notifier.StatusRunning ||
+			len(destination.Events) > 0 && !destination.subscribes(notifier.EventRunning) {
+			continue
+		}
+		if elapsed >= destination.LongRunningThreshold {
+			running = append(running, destination)
+			if threshold == 0 || destination.LongRunningThreshold < threshold {
+				threshold = destination.LongRunningThreshold
+			}
+		} else if wait := destination.LongRunningThreshold - elapsed; requeueAfter == 0 || wait < requeueAfter {
+			requeueAfter = wait
+		}
+	}
+	if len(started) == 0 && len(running) == 0 {
+		return requeueAfter, nil
+	}
+
+	notification, err := r.buildNotification(ctx, pipelineRun)
+	if err != nil {
+		return requeueAfter, err
+	}
+	var errs []error
+	for _, lifecycle := range []struct {
+		status       string
+		event        string
+		destinations []DestinationNotifier
+	}{{notifier.StatusStarted, notifier.EventStarted, started}, {notifier.StatusRunning, notifier.EventRunning, running}} {
+		if len(lifecycle.destinations) == 0 {
+			continue
+		}
+		lifecycleNotification := *notification
+		lifecycleNotification.Status = lifecycle.status
+		lifecycleNotification.Event = lifecycle.event
+		_, _, err = r.deliver(ctx, pipelineRun, lifecycle.destinations, &lifecycleNotification, false, nil)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for _, destination := range lifecycle.destinations {
+			sent[destination.Name] = lifecycle.status
+		}
+	}
+	if len(running) > 0 && r.Recorder != nil {
+		r.Recorder.Eventf(pipelineRun, corev1.EventTypeWarning, PipelineRunLongRunningReason,
+			"PipelineRun is still running after %s", threshold)
+	}
+	err = SetLifecycleNotifications(ctx, pipelineRun, r.Client, sent)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	return requeueAfter, errors.Join(errs...)
+}
+
+// deliver sends the notification to every destination and records the attempts.
+// The notification is run through the transforms of the configuration file first.
+// If acknowledge is set, destinations with an acknowledgement timeout receive a callback URL
+// and their acknowledgement deadlines are returned, along with the completed destinations, which the notification
+// was delivered to, blocked for because secrets were detected in it, or dropped for by a transform, so they are
+// not sent to again.
+// Destinations whose throttling limits are reached are not sent to, and ErrDeliveryThrottled is returned
+// if no delivery failed. Destinations backing off are not sent to either, and a BackoffError is returned
+// if no delivery failed nor was throttled.
+// If outbox is set, the failed deliveries are counted in it, and the notifications that exhausted the attempts of
+// the dead-letter policy are parked in the dead-letter store rather than failing the delivery.
+func (r *NotificationServiceReconciler) deliver(ctx context.Context, pipelineRun *tektonv1.PipelineRun,
+	destinations []DestinationNotifier, baseNotification *notifier.Notification, acknowledge bool,
+	outbox map[string]OutboxEntry) (map[string]time.Time, []string, error) {
+	baseNotification, err := r.transform(ctx, baseNotification)
+	if err != nil {
+		return nil, nil, err
+	}
+	if baseNotification == nil {
+		return nil, destinationNames(destinations), nil
+	}
+	threads, err := GetNotificationThreads(pipelineRun)
+	if err != nil {
+		r.Log.Error(err, "Ignoring malformed notification threads")
+	}
+	restricted, err := r.restrictPayload(ctx, pipelineRun.Namespace, destinations, baseNotification)
+	if err != nil {
+		return nil, nil, err
+	}
+	archivePayloads(ctx, r.Archive, r.Log, string(pipelineRun.UID), destinations, baseNotification, restricted)
+	threadsChanged := false
+	deadlines := map[string]time.Time{}
+	var completed []string
+	var errs []error
+	policy := r.ConfigFile.Get().Throttling
+	throttled := false
+	var backoffUntil time.Time
+	for _, destination := range destinations {
+		if until, ok := r.backoff.Until(destination.Name, time.Now()); ok {
+			deliveriesBackedOff.Inc()
+			backoffUntil = earliest(backoffUntil, until)
+			continue
+		}
+		deliveryID := delivery.NewID(string(pipelineRun.UID), destination.Name, baseNotification.Status)
+		if destination.Ordered && !r.sequencer.Ready(destination.Name, deliveryID, baseNotification, time.Now()) {
+			deliveriesHeldBack.Inc()
+			throttled = true
+			continue
+		}
+		release, ok := r.throttle.Acquire(pipelineRun.Namespace, destination.Name, destinationPolicy(destination, policy))
+		if !ok {
+			deliveriesThrottled.Inc()
+			throttled = true
+			continue
+		}
+		notification := &notifier.Notification{}
+		*notification = *payloadFor(destination, baseNotification, restricted)
+		notification.DeliveryID = deliveryID
+		if acknowledge && destination.AcknowledgementTimeout > 0 {
+			if r.CallbackURL == "" {
+				r.Log.Info("Callback URL is not configured, not waiting for acknowledgement", "destination", destination.Name)
+			} else {
+				notification.CallbackURL = strings.TrimSuffix(r.CallbackURL, "/") + CallbackPath +
+					NewCallbackToken(r.CallbackSecret, client.ObjectKeyFromObject(pipelineRun), destination.Name)
+			}
+		}
+		start := time.Now()
+		done := startDelivery()
+		var response *notifier.Response
+		var scanned *notifier.Notification
+		scanned, err = notifier.ScanNotification(notification)
+		if err == nil {
+			notification = scanned
+			if threadNotifier, ok := destination.Notifier.(notifier.ThreadNotifier); ok {
+				var thread string
+				thread, err = threadNotifier.NotifyInThread(ctx, notification, threads[destination.Name])
+				if err == nil && thread != threads[destination.Name] {
+					threads[destination.Name] = thread
+					threadsChanged = true
+				}
+			} else {
+				response, err = notifier.Deliver(ctx, destination.Notifier, notification)
+			}
+		}
+		done(err)
+		release()
+		r.recordBackoff(ctx, destination, err)
+		if r.SLOTracker != nil {
+			r.SLOTracker.Record(destination, string(pipelineRun.UID), notification, err)
+		}
+		if r.StatusSummarizer != nil {
+			r.StatusSummarizer.Record(destination, err)
+		}
+		RecordDeliveryEvent(r, pipelineRun, destination.Name, response, err)
+		if r.RecordDeliveries {
+			recordErr := CreateDeliveryRecord(ctx, r, pipelineRun, destination.Name, notification, response, time.Since(start), err)
+			if recordErr != nil {
+				r.Log.Error(recordErr, "Failed to record delivery", "destination", destination.Name)
+			}
+		}
+		if r.AuditLog != nil {
+			auditErr := r.AuditLog.Append(ctx, destination.Name, notification, err)
+			if auditErr != nil {
+				r.Log.Error(auditErr, "Failed to append to audit log", "destination", destination.Name)
+			}
+		}
+		if destination.Ordered && (err == nil || errors.Is(err, notifier.ErrSecretDetected)) {
+			r.sequencer.Done(destination.Name, deliveryID)
+		}
+		if errors.Is(err, notifier.ErrSecretDetected) {
+			// Blocked notifications would be blocked again, they are not retried
+			completed = append(completed, destination.Name)
+			continue
+		}
+		if err != nil {
+			if outbox != nil && r.park(ctx, pipelineRun, destination.Name, notification, err, outbox) {
+				continue
+			}
+			errs = append(errs, err)
+			continue
+		}
+		completed = append(completed, destination.Name)
+		if notification.CallbackURL != "" {
+			deadlines[destination.Name] = time.Now().Add(destination.AcknowledgementTimeout).UTC().Truncate(time.Second)
+		}
+	}
+	if threadsChanged {
+		err = SetNotificationThreads(ctx, pipelineRun, r.Client, threads)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if throttled && len(errs) == 0 {
+		return deadlines, completed, ErrDeliveryThrottled
+	}
+	if !backoffUntil.IsZero() && len(errs) == 0 {
+		return deadlines, completed, &BackoffError{Until: backoffUntil}
+	}
+	return deadlines, completed, errors.Join(errs...)
+}
+
+// selectsPipelineRun returns a boolean indicating whether the pipelinerun matches the pipelinerun selector
+// of the configuration, or the PipelineRunSelector of the reconciler if the configuration sets none
+func (r *NotificationServiceReconciler) selectsPipelineRun(pipelineRun *tektonv1.PipelineRun) bool {
+	config := r.ConfigFile.Get()
+	if config.PipelineRunSelector == "" && r.PipelineRunSelector != nil {
+		return r.PipelineRunSelector.Matches(labels.Set(pipelineRun.Labels))
+	}
+	return config.MatchesPipelineRun(pipelineRun)
+}
+
+// ReconcilesPendingPipelineRun returns a boolean indicating whether the pending pipelinerun is reconciled,
+// which is the case if it is held with the finalizer or selected. Pending pipelineruns that are not selected
+// are left alone, so they are neither swept nor counted in the backlog.
+func (r *NotificationServiceReconciler) ReconcilesPendingPipelineRun(pipelineRun *tektonv1.PipelineRun) bool {
+	return IsFinalizerExistInPipelineRun(pipelineRun, NotificationPipelineRunFinalizer) || r.selectsPipelineRun(pipelineRun)
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *NotificationServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	err := IndexPendingPipelineRuns(context.Background(), mgr.GetFieldIndexer())
+	if err != nil {
+		return err
+	}
+	err = IndexRunningPipelineRuns(context.Background(), mgr.GetFieldIndexer())
+	if err != nil {
+		return err
+	}
+	concurrency := r.ConfigFile.Get().Concurrency
+	if concurrency == 0 {
+		concurrency = r.Concurrency
+	}
+	options := controller.Options{
+		MaxConcurrentReconciles: concurrency,
+		RateLimiter: workqueue.NewMaxOfRateLimiter(
+			&ConfigRateLimiter{Config: r.ConfigFile.Get},
+			&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(10), 100)},
+		),
+	}
+	if r.PrioritizeFailures {
+		options.NewQueue = NewPriorityRateLimitingQueue(FailedPipelineRunPriority(mgr.GetClient()))
+	}
+	builder := ctrl.NewControllerManagedBy(mgr).
 		For(&tektonv1.PipelineRun{}).
-		Complete(r)
+		WithOptions(options)
+	if r.Sweeps != nil {
+		builder = builder.WatchesRawSource(source.Channel(r.Sweeps, &handler.EnqueueRequestForObject{}))
+	}
+	// Running pipelineruns are evaluated again when the spec of the ClusterNotificationServices selecting them
+	// changes, e.g. to hold them with a finalizer for a ClusterNotificationService that is not best-effort
+	builder = builder.Watches(&v1alpha1.ClusterNotificationService{},
+		handler.EnqueueRequestsFromMapFunc(r.mapClusterNotificationService),
+		ctrlbuilder.WithPredicates(predicate.GenerationChangedPredicate{}))
+	return builder.Complete(r)
+}
+
+// mapClusterNotificationService returns the running pipelineruns of the namespaces the ClusterNotificationService
+// selects, listed through RunningPipelineRunIndex
+func (r *NotificationServiceReconciler) mapClusterNotificationService(ctx context.Context, obj client.Object) []reconcile.Request {
+	clusterNotificationService, ok := obj.(*v1alpha1.ClusterNotificationService)
+	if !ok {
+		return nil
+	}
+	running := client.MatchingFields{RunningPipelineRunIndex: RunningPipelineRunIndexValue}
+	if clusterNotificationService.Spec.NamespaceSelector == nil {
+		return r.mapRunningPipelineRuns(ctx, running)
+	}
+	selector, err := metav1.LabelSelectorAsSelector(clusterNotificationService.Spec.NamespaceSelector)
+	if err != nil {
+		r.Log.Error(err, "Skipping ClusterNotificationService with an invalid namespace selector",
+			"clusterNotificationService", clusterNotificationService.Name)
+		return nil
+	}
+	namespaces := &corev1.NamespaceList{}
+	err = r.List(ctx, namespaces, client.MatchingLabelsSelector{Selector: selector})
+	if err != nil {
+		r.Log.Error(err, "Failed to list the namespaces of ClusterNotificationService",
+			"clusterNotificationService", clusterNotificationService.Name)
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, namespace := range namespaces.Items {
+		requests = append(requests, r.mapRunningPipelineRuns(ctx, running, client.InNamespace(namespace.Name))...)
+	}
+	return requests
+}
+
+// mapRunningPipelineRuns returns the requests of the pipelineruns listed with the options
+func (r *NotificationServiceReconciler) mapRunningPipelineRuns(ctx context.Context, opts ...client.ListOption) []reconcile.Request {
+	pipelineRuns := &tektonv1.PipelineRunList{}
+	err := r.List(ctx, pipelineRuns, opts...)
+	if err != nil {
+		r.Log.Error(err, "Failed to list running pipelineruns")
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(pipelineRuns.Items))
+	for i := range pipelineRuns.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&pipelineRuns.Items[i])})
+	}
+	return requests
 }
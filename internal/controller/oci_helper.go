@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+)
+
+// pullLayer downloads the content of the layer of the OCI artifact with the media type, or of its only layer,
+// up to maxBytes, and returns it along with the digest of the artifact. Requests are sent anonymously with
+// the notifier.EgressTransport.
+// Return error if the artifact cannot be pulled or does not hold a single such layer
+func pullLayer(ctx context.Context, image string, mediaType types.MediaType, maxBytes int) ([]byte, string, error) {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return nil, "", fmt.Errorf("Invalid image %s: %w", image, err)
+	}
+	options := []remote.Option{remote.WithContext(ctx)}
+	if notifier.EgressTransport != nil {
+		options = append(options, remote.WithTransport(notifier.EgressTransport))
+	}
+	artifact, err := remote.Image(ref, options...)
+	if err != nil {
+		return nil, "", fmt.Errorf("Failed to pull image %s: %w", image, err)
+	}
+	digest, err := artifact.Digest()
+	if err != nil {
+		return nil, "", fmt.Errorf("Failed to get the digest of image %s: %w", image, err)
+	}
+	layers, err := artifact.Layers()
+	if err != nil {
+		return nil, "", fmt.Errorf("Failed to read the layers of image %s: %w", image, err)
+	}
+	var matching []int
+	for i, layer := range layers {
+		if layerType, err := layer.MediaType(); err == nil && layerType == mediaType {
+			matching = append(matching, i)
+		}
+	}
+	if len(matching) == 0 && len(layers) == 1 {
+		matching = []int{0}
+	}
+	if len(matching) != 1 {
+		return nil, "", fmt.Errorf("Image %s does not hold a single layer of type %s", image, mediaType)
+	}
+	// The content is stored as is, with the media type as its only encoding
+	content, err := layers[matching[0]].Compressed()
+	if err != nil {
+		return nil, "", fmt.Errorf("Failed to download the layer of image %s: %w", image, err)
+	}
+	defer content.Close()
+	data, err := io.ReadAll(io.LimitReader(content, int64(maxBytes)+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("Failed to download the layer of image %s: %w", image, err)
+	}
+	if len(data) > maxBytes {
+		return nil, "", fmt.Errorf("The layer of image %s exceeds %d bytes", image, maxBytes)
+	}
+	return data, digest.String(), nil
+}
+
+// decompressLayer returns the data, decompressed up to maxBytes if it is gzip compressed, as the content of
+// ConfigMaps and layers usually is to fit in their size limit
+func decompressLayer(data []byte, maxBytes int) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		return data, nil
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decompress: %w", err)
+	}
+	decompressed, err := io.ReadAll(io.LimitReader(reader, int64(maxBytes)+1))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decompress: %w", err)
+	}
+	if len(decompressed) > maxBytes {
+		return nil, fmt.Errorf("The decompressed content exceeds %d bytes", maxBytes)
+	}
+	return decompressed, nil
+}
@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	"github.com/konflux-ci/operator-toolkit/metadata"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NotificationOutboxAnnotation holds a JSON map from destination to the OutboxEntry of the notification about the
+// end of the pipelinerun. The entries are written before the notification is sent, and the notified annotation is
+// only set once every entry was delivered or parked in the dead-letter store. Parked entries are kept as a record.
+var NotificationOutboxAnnotation = DefaultMarkerPrefix + "/notification-outbox"
+
+// OutboxEntry is the delivery of the notification about the end of a pipelinerun to a destination
+type OutboxEntry struct {
+	// Attempts is the number of failed delivery attempts
+	Attempts int `json:"attempts,omitempty"`
+	// DeadLetter is the name of the NotificationDelivery the notification was parked as, once it exhausted its attempts
+	DeadLetter string `json:"deadLetter,omitempty"`
+}
+
+// GetNotificationOutbox returns the outbox of the notification about the end of the pipelineRun
+// Return error if the annotation is malformed
+func GetNotificationOutbox(pipelineRun *tektonv1.PipelineRun) (map[string]OutboxEntry, error) {
+	outbox := map[string]OutboxEntry{}
+	err := getJSONAnnotation(pipelineRun, NotificationOutboxAnnotation, &outbox)
+	if err != nil {
+		return map[string]OutboxEntry{}, err
+	}
+	return outbox, nil
+}
+
+// SetNotificationOutbox stores the outbox of the notification about the end of the pipelineRun
+// If the annotation was not updated successfully, a non-nil error is returned.
+func SetNotificationOutbox(ctx context.Context, pipelineRun *tektonv1.PipelineRun, c client.Client, outbox map[string]OutboxEntry) error {
+	return setJSONAnnotation(ctx, pipelineRun, c, NotificationOutboxAnnotation, outbox)
+}
+
+// FilterDeadLetteredDestinations removes the destinations the notification was parked in the dead-letter store for
+func FilterDeadLetteredDestinations(destinations []DestinationNotifier, outbox map[string]OutboxEntry) []DestinationNotifier {
+	var filtered []DestinationNotifier
+	for _, destination := range destinations {
+		if outbox[destination.Name].DeadLetter == "" {
+			filtered = append(filtered, destination)
+		}
+	}
+	return filtered
+}
+
+// CommitNotification stores the destinations the end of the pipelineRun was notified to, its outbox and the
+// acknowledgement deadlines of the destinations it was just delivered to, along with the pending deadlines of
+// previous attempts, in a single apply, as well as the notified annotation if notified is set.
+// The outbox annotation is removed if the outbox is empty, and the deadlines are left unchanged if there are none.
+// New deadlines are applied with an optimistic lock, as the callback server removes the deadlines of acknowledged
+// notifications concurrently, and are merged again with the pending deadlines of the pipelineRun read again on
+// conflicts, so the destinations that were delivered to are recorded and not sent to again.
+// If the annotations were not updated successfully, a non-nil error is returned.
+func CommitNotification(ctx context.Context, pipelineRun *tektonv1.PipelineRun, c client.Client, delivered map[string]time.Time,
+	outbox map[string]OutboxEntry, deadlines map[string]time.Time, notified bool) error {
+	annotations := map[string]string{}
+	for annotation, value := range map[string]any{NotificationDeliveredAnnotation: delivered, NotificationOutboxAnnotation: outbox} {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("Failed to encode annotation %s: %w", annotation, err)
+		}
+		annotations[annotation] = string(encoded)
+	}
+	if notified {
+		annotations[NotificationPipelineRunAnnotation] = NotificationPipelineRunAnnotationValue
+	}
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var encodedDeadlines []byte
+		if len(deadlines) > 0 {
+			// Malformed pending deadlines are replaced
+			pending, err := GetAcknowledgementDeadlines(pipelineRun)
+			if err != nil {
+				pending = map[string]time.Time{}
+			}
+			for destination, deadline := range deadlines {
+				pending[destination] = deadline
+			}
+			encodedDeadlines, err = json.Marshal(pending)
+			if err != nil {
+				return fmt.Errorf("Failed to encode acknowledgement deadlines: %w", err)
+			}
+		}
+		err := applyPipelineRunMetadata(ctx, pipelineRun, c, len(deadlines) > 0, func(applied *tektonv1.PipelineRun) {
+			for annotation, value := range annotations {
+				_ = metadata.SetAnnotation(&applied.ObjectMeta, annotation, value)
+			}
+			if len(outbox) == 0 {
+				delete(applied.Annotations, NotificationOutboxAnnotation)
+			}
+			if encodedDeadlines != nil {
+				_ = metadata.SetAnnotation(&applied.ObjectMeta, NotificationAwaitingAcknowledgementAnnotation, string(encodedDeadlines))
+			}
+		})
+		if k8serrors.IsConflict(err) {
+			refreshErr := c.Get(ctx, client.ObjectKeyFromObject(pipelineRun), pipelineRun)
+			if refreshErr == nil {
+				refreshErr = LoadPipelineRunState(ctx, c, pipelineRun)
+			}
+			if refreshErr != nil {
+				return refreshErr
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("Error occurred while committing the notification of pipelineRun: %w", err)
+	}
+	return nil
+}
+
+// park counts the failed delivery of the notification to the destination in the outbox, and parks the notification
+// in the dead-letter store once it failed as many times as the dead-letter policy allows.
+// It returns whether the notification was parked.
+func (r *NotificationServiceReconciler) park(ctx context.Context, pipelineRun *tektonv1.PipelineRun, destination string,
+	notification *notifier.Notification, deliveryErr error, outbox map[string]OutboxEntry) bool {
+	entry := outbox[destination]
+	entry.Attempts++
+	outbox[destination] = entry
+	maxAttempts := r.ConfigFile.Get().DeadLetter.MaxAttempts
+	if maxAttempts == 0 || entry.Attempts < maxAttempts {
+		return false
+	}
+	name, err := CreateDeadLetter(ctx, r, pipelineRun, destination, notification, deliveryErr)
+	if err != nil {
+		r.Log.Error(err, "Failed to park notification", "destination", destination)
+		return false
+	}
+	entry.DeadLetter = name
+	outbox[destination] = entry
+	deliveriesDeadLettered.Inc()
+	if r.Recorder != nil {
+		r.Recorder.Eventf(pipelineRun, corev1.EventTypeWarning, NotificationDeadLetteredReason,
+			"Parked the notification to %s as %s after %d failed attempts: %v", destination, name, entry.Attempts, deliveryErr)
+	}
+	return true
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Notification outbox", func() {
+	It("should only mark the pipelinerun as notified once the notification is delivered", func() {
+		fake := &fakeNotifier{err: errors.New("unavailable")}
+		r := &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Notifier: fake}
+		pipelineRun := createPipelineRun("outbox-pending", corev1.ConditionTrue)
+
+		Expect(reconcilePipelineRun(r, pipelineRun)).NotTo(Succeed())
+		pending := getPipelineRun(pipelineRun)
+		Expect(pending.Annotations).NotTo(HaveKey(NotificationPipelineRunAnnotation))
+		outbox, err := GetNotificationOutbox(pending)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(outbox).To(Equal(map[string]OutboxEntry{DefaultDestinationName: {Attempts: 1}}))
+
+		fake.err = nil
+		Expect(reconcilePipelineRun(r, pipelineRun)).To(Succeed())
+		notified := getPipelineRun(pipelineRun)
+		Expect(notified.Annotations).To(HaveKeyWithValue(NotificationPipelineRunAnnotation, NotificationPipelineRunAnnotationValue))
+		Expect(notified.Annotations).NotTo(HaveKey(NotificationOutboxAnnotation))
+		delivered, err := GetDeliveredDestinations(notified)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(delivered).To(HaveKey(DefaultDestinationName))
+	})
+
+	It("should park the notification in the dead-letter store once it exhausted its attempts", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "config.yaml")
+		Expect(os.WriteFile(path, []byte("deadLetter:\n  maxAttempts: 2\n"), 0o600)).To(Succeed())
+		file, err := NewConfigFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		fake := &fakeNotifier{err: errors.New("unavailable")}
+		r := &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Notifier: fake, ConfigFile: file}
+		pipelineRun := createPipelineRun("outbox-dead-letter", corev1.ConditionTrue)
+
+		Expect(reconcilePipelineRun(r, pipelineRun)).NotTo(Succeed())
+		Expect(getPipelineRun(pipelineRun).Annotations).NotTo(HaveKey(NotificationPipelineRunAnnotation))
+		Expect(reconcilePipelineRun(r, pipelineRun)).To(Succeed())
+		parked := getPipelineRun(pipelineRun)
+		Expect(parked.Annotations).To(HaveKeyWithValue(NotificationPipelineRunAnnotation, NotificationPipelineRunAnnotationValue))
+		outbox, err := GetNotificationOutbox(parked)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(outbox[DefaultDestinationName].Attempts).To(Equal(2))
+		Expect(outbox[DefaultDestinationName].DeadLetter).NotTo(BeEmpty())
+
+		deadLetter := &v1alpha1.NotificationDelivery{}
+		key := client.ObjectKey{Namespace: "default", Name: outbox[DefaultDestinationName].DeadLetter}
+		Expect(k8sClient.Get(context.Background(), key, deadLetter)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), deadLetter)
+		Expect(deadLetter.Labels).To(HaveKeyWithValue(DeadLetterLabel, DeadLetterLabelValue))
+		Expect(deadLetter.OwnerReferences).To(BeEmpty())
+		Expect(deadLetter.Spec.Destination).To(Equal(DefaultDestinationName))
+		Expect(deadLetter.Spec.Succeeded).To(BeFalse())
+		Expect(deadLetter.Spec.Error).To(Equal("unavailable"))
+
+		Expect(reconcilePipelineRun(r, pipelineRun)).To(Succeed())
+		Expect(fake.notifications).To(HaveLen(2))
+	})
+
+	It("should not park notifications without a dead-letter policy", func() {
+		fake := &fakeNotifier{err: errors.New("unavailable")}
+		r := &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Notifier: fake}
+		pipelineRun := createPipelineRun("outbox-retry", corev1.ConditionTrue)
+		for range 3 {
+			Expect(reconcilePipelineRun(r, pipelineRun)).NotTo(Succeed())
+		}
+		pending := getPipelineRun(pipelineRun)
+		Expect(pending.Annotations).NotTo(HaveKey(NotificationPipelineRunAnnotation))
+		outbox, err := GetNotificationOutbox(pending)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(outbox).To(Equal(map[string]OutboxEntry{DefaultDestinationName: {Attempts: 3}}))
+	})
+
+	It("should merge the new acknowledgement deadlines with the ones acknowledged concurrently", func() {
+		pipelineRun := createPipelineRun("outbox-conflict", corev1.ConditionTrue)
+		deadline := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+		Expect(SetAcknowledgementDeadlines(context.Background(), pipelineRun, k8sClient,
+			map[string]time.Time{"acknowledged": deadline, "pending": deadline})).To(Succeed())
+		stale := pipelineRun.DeepCopy()
+		Expect(SetAcknowledgementDeadlines(context.Background(), pipelineRun, k8sClient,
+			map[string]time.Time{"pending": deadline})).To(Succeed())
+
+		delivered := map[string]time.Time{"new": deadline}
+		Expect(CommitNotification(context.Background(), stale, k8sClient, delivered, nil,
+			map[string]time.Time{"new": deadline}, true)).To(Succeed())
+		committed := getPipelineRun(pipelineRun)
+		Expect(committed.Annotations).To(HaveKeyWithValue(NotificationPipelineRunAnnotation, NotificationPipelineRunAnnotationValue))
+		deadlines, err := GetAcknowledgementDeadlines(committed)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(deadlines).To(Equal(map[string]time.Time{"pending": deadline, "new": deadline}))
+		Expect(GetDeliveredDestinations(committed)).To(HaveKey("new"))
+	})
+})
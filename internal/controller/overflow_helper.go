@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+)
+
+// PayloadOverflow uploads the full notification to object storage when the combined size of its results
+// exceeds ThresholdBytes, and sends the notification without its largest results and with a signed URL instead
+type PayloadOverflow struct {
+	Store          notifier.ObjectStore
+	ThresholdBytes int
+}
+
+// Apply uploads the notification and drops its results, largest first, until their combined size fits
+// in the threshold. Notifications whose results fit are left untouched.
+// Return error if failed to upload the notification
+func (o *PayloadOverflow) Apply(ctx context.Context, pipelineRun *tektonv1.PipelineRun, notification *notifier.Notification) error {
+	if o == nil || o.Store == nil || o.ThresholdBytes <= 0 {
+		return nil
+	}
+	size := 0
+	for _, result := range notification.Results {
+		size += len(result.String())
+	}
+	if size <= o.ThresholdBytes {
+		return nil
+	}
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("Failed to encode notification for pipelinerun %s: %w", pipelineRun.Name, err)
+	}
+	key := fmt.Sprintf("%s/%s/%s.json", pipelineRun.Namespace, pipelineRun.Name, pipelineRun.UID)
+	notification.PayloadURL, err = o.Store.Put(ctx, key, body, "application/json")
+	if err != nil {
+		return fmt.Errorf("Failed to upload the payload of pipelinerun %s: %w", pipelineRun.Name, err)
+	}
+
+	order := make([]notifier.Result, len(notification.Results))
+	copy(order, notification.Results)
+	sort.SliceStable(order, func(i, j int) bool {
+		if len(order[i].String()) != len(order[j].String()) {
+			return len(order[i].String()) > len(order[j].String())
+		}
+		return order[i].Name < order[j].Name
+	})
+	dropped := map[string]bool{}
+	for _, result := range order {
+		if size <= o.ThresholdBytes {
+			break
+		}
+		dropped[result.Name] = true
+		size -= len(result.String())
+		notification.TruncatedResults = append(notification.TruncatedResults, result.Name)
+	}
+	kept := make([]notifier.Result, 0, len(notification.Results)-len(dropped))
+	for _, result := range notification.Results {
+		if !dropped[result.Name] {
+			kept = append(kept, result)
+		}
+	}
+	notification.Results = kept
+	return nil
+}
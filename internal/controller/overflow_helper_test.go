@@ -0,0 +1,98 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeObjectStore records the objects uploaded to it
+type fakeObjectStore struct {
+	objects map[string][]byte
+	err     error
+}
+
+func (s *fakeObjectStore) Put(_ context.Context, key string, body []byte, _ string) (string, error) {
+	if s.err != nil {
+		return "", s.err
+	}
+	s.objects[key] = body
+	return "https://bucket.example.com/" + key + "?X-Amz-Signature=abc", nil
+}
+
+var _ = Describe("Payload overflow", func() {
+	var (
+		store       *fakeObjectStore
+		pipelineRun *tektonv1.PipelineRun
+	)
+
+	newNotification := func() *notifier.Notification {
+		return &notifier.Notification{PipelineRun: "build", Namespace: "tenant", Results: []notifier.Result{
+			{Name: "IMAGE_URL", Value: "quay.io/org/app"},
+			{Name: "SBOM", Value: strings.Repeat("a", 100)},
+			{Name: "LOGS", Value: strings.Repeat("b", 50)},
+		}}
+	}
+
+	BeforeEach(func() {
+		store = &fakeObjectStore{objects: map[string][]byte{}}
+		pipelineRun = &tektonv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "build", Namespace: "tenant", UID: "uid"}}
+	})
+
+	It("should upload the full notification and drop the largest results", func() {
+		overflow := &PayloadOverflow{Store: store, ThresholdBytes: 80}
+		notification := newNotification()
+		Expect(overflow.Apply(context.Background(), pipelineRun, notification)).To(Succeed())
+		Expect(notification.PayloadURL).To(Equal("https://bucket.example.com/tenant/build/uid.json?X-Amz-Signature=abc"))
+		Expect(notification.TruncatedResults).To(Equal([]string{"SBOM"}))
+		Expect(notification.Results).To(HaveLen(2))
+
+		uploaded := &notifier.Notification{}
+		Expect(json.Unmarshal(store.objects["tenant/build/uid.json"], uploaded)).To(Succeed())
+		Expect(uploaded.Results).To(HaveLen(3))
+		Expect(uploaded.PayloadURL).To(BeEmpty())
+	})
+
+	It("should leave notifications within the threshold untouched", func() {
+		overflow := &PayloadOverflow{Store: store, ThresholdBytes: 1000}
+		notification := newNotification()
+		Expect(overflow.Apply(context.Background(), pipelineRun, notification)).To(Succeed())
+		Expect(notification).To(Equal(newNotification()))
+		Expect(store.objects).To(BeEmpty())
+
+		var disabled *PayloadOverflow
+		Expect(disabled.Apply(context.Background(), pipelineRun, notification)).To(Succeed())
+	})
+
+	It("should fail when the upload fails", func() {
+		store.err = errors.New("unavailable")
+		overflow := &PayloadOverflow{Store: store, ThresholdBytes: 10}
+		notification := newNotification()
+		Expect(overflow.Apply(context.Background(), pipelineRun, notification)).To(MatchError(ContainSubstring("unavailable")))
+		Expect(notification.Results).To(HaveLen(3))
+	})
+})
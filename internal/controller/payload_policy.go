@@ -0,0 +1,452 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"text/template/parse"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// +kubebuilder:rbac:groups=konflux.ci,resources=payloadpolicies,verbs=get;list;watch
+
+// GetPayloadPolicies returns the PayloadPolicies whose namespace selector selects the namespace
+// Return error if failed to list the PayloadPolicies, to get the namespace or if a selector is not valid
+func GetPayloadPolicies(ctx context.Context, c client.Reader, namespace string) ([]v1alpha1.PayloadPolicy, error) {
+	policies := &v1alpha1.PayloadPolicyList{}
+	err := c.List(ctx, policies)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list PayloadPolicies: %w", err)
+	}
+	var namespaceLabels labels.Set
+	var selected []v1alpha1.PayloadPolicy
+	for _, policy := range policies.Items {
+		if policy.Spec.NamespaceSelector == nil {
+			selected = append(selected, policy)
+			continue
+		}
+		// Policies are enforced, so an invalid selector fails instead of leaving payloads unrestricted
+		selector, err := metav1.LabelSelectorAsSelector(policy.Spec.NamespaceSelector)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid namespace selector of PayloadPolicy %s: %w", policy.Name, err)
+		}
+		if namespaceLabels == nil {
+			ns := &corev1.Namespace{}
+			err = c.Get(ctx, types.NamespacedName{Name: namespace}, ns)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to get namespace %s: %w", namespace, err)
+			}
+			namespaceLabels = labels.Set(ns.Labels)
+			if namespaceLabels == nil {
+				namespaceLabels = labels.Set{}
+			}
+		}
+		if selector.Matches(namespaceLabels) {
+			selected = append(selected, policy)
+		}
+	}
+	return selected, nil
+}
+
+// RedactNotification returns a copy of the notification without the fields and results denied by the policies,
+// or the notification itself if there are no policies
+// Return error if the notification cannot be copied
+func RedactNotification(notification *notifier.Notification, policies []v1alpha1.PayloadPolicy) (*notifier.Notification, error) {
+	if len(policies) == 0 {
+		return notification, nil
+	}
+	redacted := *notification
+	redacted.Results = nil
+	for _, result := range notification.Results {
+		if resultAllowed(result.Name, policies) {
+			redacted.Results = append(redacted.Results, result)
+		}
+	}
+	redacted.TruncatedResults = nil
+	for _, name := range notification.TruncatedResults {
+		if resultAllowed(name, policies) {
+			redacted.TruncatedResults = append(redacted.TruncatedResults, name)
+		}
+	}
+	redacted.Matrix = nil
+	for _, task := range notification.Matrix {
+		task.Combinations = slices.Clone(task.Combinations)
+		for i, combination := range task.Combinations {
+			var results []notifier.Result
+			for _, result := range combination.Results {
+				if resultAllowed(result.Name, policies) {
+					results = append(results, result)
+				}
+			}
+			task.Combinations[i].Results = results
+		}
+		redacted.Matrix = append(redacted.Matrix, task)
+	}
+	var denied [][]string
+	for _, policy := range policies {
+		for _, field := range policy.Spec.DeniedFields {
+			denied = append(denied, strings.Split(field, "."))
+		}
+	}
+	if len(denied) == 0 {
+		return &redacted, nil
+	}
+	// Fields are denied by their JSON names, so the notification is redacted in its JSON form
+	data, err := json.Marshal(&redacted)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to redact notification: %w", err)
+	}
+	var value any
+	err = json.Unmarshal(data, &value)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to redact notification: %w", err)
+	}
+	for _, field := range denied {
+		deleteJSONPath(value, field)
+	}
+	data, err = json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to redact notification: %w", err)
+	}
+	result := &notifier.Notification{}
+	err = json.Unmarshal(data, result)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to redact notification: %w", err)
+	}
+	return result, nil
+}
+
+// restrictPayload returns the notification redacted by the PayloadPolicies of the namespace, which apply
+// to the destinations of NotificationServices, or nil if none of the destinations is declared by one
+// Return error if failed to get the PayloadPolicies, so restricted content is never sent
+func (r *NotificationServiceReconciler) restrictPayload(ctx context.Context, namespace string,
+	destinations []DestinationNotifier, notification *notifier.Notification) (*notifier.Notification, error) {
+	if !slices.ContainsFunc(destinations, isTenantDestination) {
+		return nil, nil
+	}
+	policies, err := GetPayloadPolicies(ctx, r.Client, namespace)
+	if err != nil {
+		return nil, err
+	}
+	return RedactNotification(notification, policies)
+}
+
+// payloadFor returns the restricted notification for the destinations of NotificationServices,
+// see restrictPayload, and the notification for the other destinations
+func payloadFor(destination DestinationNotifier, notification *notifier.Notification, restricted *notifier.Notification) *notifier.Notification {
+	if restricted != nil && isTenantDestination(destination) {
+		return restricted
+	}
+	return notification
+}
+
+// isTenantDestination returns a boolean indicating whether the destination is declared by a NotificationService,
+// rather than by the platform, e.g. ClusterNotificationServices or the controller configuration
+func isTenantDestination(destination DestinationNotifier) bool {
+	return destination.NotificationService.Name != ""
+}
+
+// deleteJSONPath deletes the field at the path from the decoded JSON value, from every element of the arrays on the path
+func deleteJSONPath(value any, field []string) {
+	switch value := value.(type) {
+	case map[string]any:
+		if len(field) == 1 {
+			delete(value, field[0])
+			return
+		}
+		deleteJSONPath(value[field[0]], field[1:])
+	case []any:
+		for _, element := range value {
+			deleteJSONPath(element, field)
+		}
+	}
+}
+
+// resultAllowed returns a boolean indicating whether the result is allowed by every policy
+func resultAllowed(name string, policies []v1alpha1.PayloadPolicy) bool {
+	for _, policy := range policies {
+		if len(policy.Spec.AllowedResults) > 0 && !matchesPattern(policy.Spec.AllowedResults, name) {
+			return false
+		}
+		if matchesPattern(policy.Spec.DeniedResults, name) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesPattern returns a boolean indicating whether the name matches one of the glob patterns.
+// Patterns that are not valid globs only match themselves.
+func matchesPattern(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		matched, err := path.Match(pattern, name)
+		if matched || (err != nil && pattern == name) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckTemplatePolicies returns an error naming the denied fields and results the template references.
+// Fields are resolved from the notification, including within range and with blocks over its fields,
+// and results from the result function and method called with a literal name.
+// Return error if the template cannot be parsed
+func CheckTemplatePolicies(name string, text string, policies []v1alpha1.PayloadPolicy) error {
+	if len(policies) == 0 || text == "" {
+		return nil
+	}
+	tmpl, err := notifier.NewTemplate(name, text)
+	if err != nil {
+		return err
+	}
+	references := &templateReferences{fields: map[string]bool{}, results: map[string]bool{}}
+	root := &templateScope{typ: reflect.TypeOf(notifier.Notification{})}
+	for _, defined := range tmpl.Templates() {
+		if defined.Tree != nil {
+			references.walk(defined.Tree.Root, root, root)
+		}
+	}
+	var violations []string
+	for _, policy := range policies {
+		for _, denied := range policy.Spec.DeniedFields {
+			for field := range references.fields {
+				if field == denied || strings.HasPrefix(field, denied+".") {
+					violations = append(violations, fmt.Sprintf("field %s is denied by PayloadPolicy %s", field, policy.Name))
+				}
+			}
+		}
+	}
+	for result := range references.results {
+		if !resultAllowed(result, policies) {
+			violations = append(violations, fmt.Sprintf("result %s is denied", result))
+		}
+	}
+	if len(violations) > 0 {
+		sort.Strings(violations)
+		return fmt.Errorf("Template %s references denied content: %s", name, strings.Join(violations, ", "))
+	}
+	return nil
+}
+
+// templateScope is the type of the dot of a template and its JSON path from the notification, with a nil type if unknown
+type templateScope struct {
+	typ  reflect.Type
+	path string
+}
+
+// resolve returns the scope of the fields of the scope named in the template, with their JSON path
+func (s *templateScope) resolve(fields []string) *templateScope {
+	resolved := s
+	for _, name := range fields {
+		if resolved.typ == nil {
+			return &templateScope{}
+		}
+		typ := resolved.typ
+		for typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
+		}
+		if typ.Kind() != reflect.Struct {
+			return &templateScope{}
+		}
+		field, ok := typ.FieldByName(name)
+		if !ok {
+			return &templateScope{}
+		}
+		jsonName, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if jsonName == "" || jsonName == "-" {
+			return &templateScope{}
+		}
+		resolvedPath := jsonName
+		if resolved.path != "" {
+			resolvedPath = resolved.path + "." + jsonName
+		}
+		resolved = &templateScope{typ: field.Type, path: resolvedPath}
+	}
+	return resolved
+}
+
+// element returns the scope of the elements ranged over in the scope
+func (s *templateScope) element() *templateScope {
+	if s.typ == nil {
+		return &templateScope{}
+	}
+	typ := s.typ
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Slice && typ.Kind() != reflect.Array && typ.Kind() != reflect.Map {
+		return &templateScope{}
+	}
+	return &templateScope{typ: typ.Elem(), path: s.path}
+}
+
+// templateReferences are the JSON paths of the fields and the names of the results referenced by a template
+type templateReferences struct {
+	fields  map[string]bool
+	results map[string]bool
+}
+
+func (r *templateReferences) walk(node parse.Node, dot *templateScope, root *templateScope) {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return
+		}
+		for _, child := range node.Nodes {
+			r.walk(child, dot, root)
+		}
+	case *parse.ActionNode:
+		r.walk(node.Pipe, dot, root)
+	case *parse.TemplateNode:
+		r.walk(node.Pipe, dot, root)
+	case *parse.IfNode:
+		r.walkBranch(&node.BranchNode, dot, dot, root)
+	case *parse.WithNode:
+		r.walkBranch(&node.BranchNode, r.pipeScope(node.Pipe, dot, root), dot, root)
+	case *parse.RangeNode:
+		r.walkBranch(&node.BranchNode, r.pipeScope(node.Pipe, dot, root).element(), dot, root)
+	case *parse.PipeNode:
+		if node == nil {
+			return
+		}
+		for _, command := range node.Cmds {
+			r.walk(command, dot, root)
+		}
+	case *parse.CommandNode:
+		r.walkCommand(node, dot, root)
+	case *parse.FieldNode:
+		r.reference(dot.resolve(node.Ident))
+	case *parse.VariableNode:
+		if len(node.Ident) > 1 && node.Ident[0] == "$" {
+			r.reference(root.resolve(node.Ident[1:]))
+		}
+	case *parse.ChainNode:
+		r.walk(node.Node, dot, root)
+	}
+}
+
+func (r *templateReferences) walkBranch(branch *parse.BranchNode, inner *templateScope, dot *templateScope, root *templateScope) {
+	r.walk(branch.Pipe, dot, root)
+	r.walk(branch.List, inner, root)
+	r.walk(branch.ElseList, dot, root)
+}
+
+// walkCommand records the references of the arguments of the command, and the results it reads by name
+func (r *templateReferences) walkCommand(command *parse.CommandNode, dot *templateScope, root *templateScope) {
+	for _, arg := range command.Args {
+		r.walk(arg, dot, root)
+	}
+	if len(command.Args) == 0 {
+		return
+	}
+	switch function := command.Args[0].(type) {
+	case *parse.IdentifierNode:
+		if function.Ident == "mention" {
+			r.reference(root.resolve([]string{"Author"}))
+		}
+		if function.Ident != "result" {
+			return
+		}
+	case *parse.FieldNode:
+		if dot.typ != root.typ || !slices.Equal(function.Ident, []string{"Result"}) {
+			return
+		}
+	default:
+		return
+	}
+	if len(command.Args) > 1 {
+		if name, ok := command.Args[1].(*parse.StringNode); ok {
+			r.results[name.Text] = true
+		}
+	}
+}
+
+// pipeScope returns the scope of the value of a pipeline made of a single field, or an unknown scope
+func (r *templateReferences) pipeScope(pipe *parse.PipeNode, dot *templateScope, root *templateScope) *templateScope {
+	if pipe == nil || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return &templateScope{}
+	}
+	switch arg := pipe.Cmds[0].Args[0].(type) {
+	case *parse.FieldNode:
+		return dot.resolve(arg.Ident)
+	case *parse.VariableNode:
+		if len(arg.Ident) > 1 && arg.Ident[0] == "$" {
+			return root.resolve(arg.Ident[1:])
+		}
+	case *parse.DotNode:
+		return dot
+	}
+	return &templateScope{}
+}
+
+func (r *templateReferences) reference(scope *templateScope) {
+	if scope.path != "" {
+		r.fields[scope.path] = true
+	}
+}
+
+// validatePayloadPolicies checks the templates of a NotificationService or NotificationTemplate against
+// the PayloadPolicies of its namespace, see CheckTemplatePolicies
+func validatePayloadPolicies(ctx context.Context, c client.Reader, obj runtime.Object) error {
+	templates := map[string]string{}
+	var namespace string
+	switch obj := obj.(type) {
+	case *v1alpha1.NotificationService:
+		namespace = obj.Namespace
+		templates["defaultTemplate"] = obj.Spec.DefaultTemplate
+		for i := range obj.Spec.Destinations {
+			if template := destinationTemplate(&obj.Spec.Destinations[i]); template != nil {
+				templates["destination "+obj.Spec.Destinations[i].Name] = *template
+			}
+		}
+	case *v1alpha1.NotificationTemplate:
+		namespace = obj.Namespace
+		templates[obj.Name] = obj.Spec.Template
+	default:
+		return fmt.Errorf("Unexpected object %T", obj)
+	}
+	policies, err := GetPayloadPolicies(ctx, c, namespace)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var errs []error
+	for _, name := range names {
+		errs = append(errs, CheckTemplatePolicies(name, templates[name], policies))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/go-logr/logr"
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("Payload policies", func() {
+	policies := []v1alpha1.PayloadPolicy{{Spec: v1alpha1.PayloadPolicySpec{
+		DeniedFields:   []string{"author.email", "matrix.combinations.params"},
+		AllowedResults: []string{"IMAGE_*", "*TOKEN"},
+		DeniedResults:  []string{"*TOKEN"},
+	}}}
+
+	It("should remove the denied fields and results from notifications", func() {
+		notification := &notifier.Notification{
+			PipelineRun: "run",
+			Author:      &notifier.Contact{Name: "jdoe", Email: "jdoe@example.com"},
+			Results: []notifier.Result{
+				{Name: "IMAGE_URL", Value: "quay.io/image"},
+				{Name: "PUSH_TOKEN", Value: "secret"},
+				{Name: "CHAINS-GIT_URL", Value: "https://github.com/org/repo"},
+			},
+			TruncatedResults: []string{"IMAGE_DIGEST", "SBOM"},
+			Matrix: []notifier.MatrixTask{{Name: "build", Combinations: []notifier.MatrixCombination{{
+				TaskRun: "run-build-0",
+				Params:  []notifier.MatrixParam{{Name: "platform", Value: "linux/arm64"}},
+				Results: []notifier.Result{{Name: "IMAGE_URL"}, {Name: "API_TOKEN"}},
+			}}}},
+		}
+		redacted, err := RedactNotification(notification, policies)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(redacted.PipelineRun).To(Equal("run"))
+		Expect(redacted.Author).To(Equal(&notifier.Contact{Name: "jdoe"}))
+		Expect(redacted.Results).To(Equal([]notifier.Result{{Name: "IMAGE_URL", Value: "quay.io/image"}}))
+		Expect(redacted.TruncatedResults).To(Equal([]string{"IMAGE_DIGEST"}))
+		Expect(redacted.Matrix[0].Combinations[0].Params).To(BeEmpty())
+		Expect(redacted.Matrix[0].Combinations[0].Results).To(Equal([]notifier.Result{{Name: "IMAGE_URL"}}))
+		By("leaving the notification unchanged")
+		Expect(notification.Author.Email).To(Equal("jdoe@example.com"))
+		Expect(notification.Results).To(HaveLen(3))
+		Expect(notification.Matrix[0].Combinations[0].Params).To(HaveLen(1))
+
+		unrestricted, err := RedactNotification(notification, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(unrestricted).To(BeIdenticalTo(notification))
+	})
+
+	It("should reject templates referencing denied fields and results", func() {
+		for _, template := range []string{
+			`{{ .Author.Email }}`,
+			`{{ with .Author }}{{ .Email }}{{ end }}`,
+			`{{ range .Matrix }}{{ range .Combinations }}{{ .Params }}{{ end }}{{ end }}`,
+			`{{ range .Matrix }}{{ $.Author.Email }}{{ end }}`,
+			`{{ result "PUSH_TOKEN" }}`,
+			`{{ .Result "SBOM" }}`,
+		} {
+			Expect(CheckTemplatePolicies("tenant", template, policies)).To(HaveOccurred(), template)
+		}
+		for _, template := range []string{
+			`{{ .PipelineRun }} by {{ .Author.Name }}`,
+			`{{ range .Results }}{{ .Name }}{{ end }}`,
+			`{{ range .Matrix }}{{ range .Combinations }}{{ .TaskRun }}{{ end }}{{ end }}`,
+			`{{ result "IMAGE_URL" }}`,
+		} {
+			Expect(CheckTemplatePolicies("tenant", template, policies)).To(Succeed(), template)
+		}
+		authorDenied := []v1alpha1.PayloadPolicy{{Spec: v1alpha1.PayloadPolicySpec{DeniedFields: []string{"author"}}}}
+		Expect(CheckTemplatePolicies("tenant", `{{ mention .Author }}`, authorDenied)).NotTo(Succeed())
+		Expect(CheckTemplatePolicies("tenant", `{{ .Author.Email }}`, nil)).To(Succeed())
+		Expect(CheckTemplatePolicies("tenant", `{{ .Author`, policies)).NotTo(Succeed())
+	})
+
+	Context("with a PayloadPolicy of the namespace", func() {
+		BeforeEach(func() {
+			policy := &v1alpha1.PayloadPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "tenants"},
+				Spec: v1alpha1.PayloadPolicySpec{
+					NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"kubernetes.io/metadata.name": "default"}},
+					DeniedFields:      []string{"author"},
+					DeniedResults:     []string{"*TOKEN*"},
+				},
+			}
+			Expect(k8sClient.Create(context.Background(), policy)).To(Succeed())
+			DeferCleanup(k8sClient.Delete, context.Background(), policy)
+			other := &v1alpha1.PayloadPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "other-tenants"},
+				Spec: v1alpha1.PayloadPolicySpec{
+					NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"kubernetes.io/metadata.name": "kube-system"}},
+					DeniedFields:      []string{"pipelineRun"},
+				},
+			}
+			Expect(k8sClient.Create(context.Background(), other)).To(Succeed())
+			DeferCleanup(k8sClient.Delete, context.Background(), other)
+		})
+
+		It("should select the policies by namespace", func() {
+			selected, err := GetPayloadPolicies(context.Background(), k8sClient, "default")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(selected).To(HaveLen(1))
+			Expect(selected[0].Name).To(Equal("tenants"))
+		})
+
+		It("should reject NotificationServices and NotificationTemplates with denied templates", func() {
+			validator := &AdmissionValidator{Client: k8sClient}
+			notificationService := &v1alpha1.NotificationService{
+				ObjectMeta: metav1.ObjectMeta{Name: "tenant", Namespace: "default"},
+				Spec: v1alpha1.NotificationServiceSpec{
+					DefaultTemplate: `{{ .PipelineRun }}`,
+					Destinations: []v1alpha1.Destination{{
+						Name:    "hook",
+						Webhook: &v1alpha1.WebhookDestination{URL: "https://example.com", Template: `{{ result "API_TOKEN" }}`},
+					}},
+				},
+			}
+			_, err := validator.ValidateCreate(context.Background(), notificationService)
+			Expect(err).To(MatchError(ContainSubstring("API_TOKEN")))
+			notificationService.Spec.Destinations[0].Webhook.Template = ""
+			_, err = validator.ValidateUpdate(context.Background(), notificationService, notificationService)
+			Expect(err).NotTo(HaveOccurred())
+
+			notificationTemplate := &v1alpha1.NotificationTemplate{
+				ObjectMeta: metav1.ObjectMeta{Name: "tenant", Namespace: "default"},
+				Spec:       v1alpha1.NotificationTemplateSpec{Template: `{{ .Author.Name }}`},
+			}
+			_, err = validator.ValidateCreate(context.Background(), notificationTemplate)
+			Expect(err).To(MatchError(ContainSubstring("author")))
+			notificationTemplate.Namespace = "kube-system"
+			_, err = validator.ValidateCreate(context.Background(), notificationTemplate)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should send restricted notifications to the destinations of NotificationServices only", func() {
+			pipelineRun := &tektonv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "payload-policy", Namespace: "default"}}
+			platform := &fakeNotifier{}
+			tenant := &fakeNotifier{}
+			r := &NotificationServiceReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Log:      logr.Discard(),
+				Notifier: platform,
+			}
+			destination := DestinationNotifier{
+				Name:                "default/tenant/hook",
+				Notifier:            tenant,
+				NotificationService: types.NamespacedName{Namespace: "default", Name: "tenant"},
+			}
+			notification := &notifier.Notification{
+				PipelineRun: pipelineRun.Name,
+				Namespace:   pipelineRun.Namespace,
+				Author:      &notifier.Contact{Name: "jdoe"},
+				Results:     []notifier.Result{{Name: "IMAGE_URL"}, {Name: "API_TOKEN"}},
+			}
+			restricted, err := r.restrictPayload(context.Background(), "default",
+				[]DestinationNotifier{{Name: DefaultDestinationName, Notifier: platform}, destination}, notification)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(payloadFor(destination, notification, restricted).Author).To(BeNil())
+			Expect(payloadFor(destination, notification, restricted).Results).To(Equal([]notifier.Result{{Name: "IMAGE_URL"}}))
+			Expect(payloadFor(DestinationNotifier{Name: DefaultDestinationName}, notification, restricted)).To(BeIdenticalTo(notification))
+
+			restricted, err = r.restrictPayload(context.Background(), "default",
+				[]DestinationNotifier{{Name: DefaultDestinationName, Notifier: platform}}, notification)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(restricted).To(BeNil())
+
+			sendAdminNotification(context.Background(), r, pipelineRun, destination, notification, false)
+			Expect(tenant.notifications).To(HaveLen(1))
+			Expect(tenant.notifications[0].Author).To(BeNil())
+			Expect(tenant.notifications[0].Results).To(Equal([]notifier.Result{{Name: "IMAGE_URL"}}))
+		})
+	})
+})
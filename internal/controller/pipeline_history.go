@@ -0,0 +1,105 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"sync"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// PipelineHistoryWindow is the number of recent outcomes kept for each pipeline
+const PipelineHistoryWindow = 10
+
+// FlakyTransitions is the number of changes between success and failure within the
+// history window from which a pipeline is considered flaky
+const FlakyTransitions = 4
+
+// pipelineOutcome is the outcome of a single pipelinerun
+type pipelineOutcome struct {
+	uid       types.UID
+	succeeded bool
+}
+
+// PipelineHistory keeps the recent outcomes of every pipeline in memory, to detect failure streaks
+// and flaky pipelines. The history starts empty whenever the controller restarts.
+type PipelineHistory struct {
+	mu       sync.Mutex
+	outcomes map[string][]pipelineOutcome
+}
+
+// NewPipelineHistory creates an empty PipelineHistory
+func NewPipelineHistory() *PipelineHistory {
+	return &PipelineHistory{outcomes: map[string][]pipelineOutcome{}}
+}
+
+// PipelineKey identifies the pipeline a pipelinerun belongs to as <namespace>/<pipeline>
+// An empty key is returned if the pipeline is not known, e.g. for embedded pipeline specs
+func PipelineKey(pipelineRun *tektonv1.PipelineRun) string {
+	pipeline := PipelineName(pipelineRun)
+	if pipeline == "" {
+		return ""
+	}
+	return pipelineRun.Namespace + "/" + pipeline
+}
+
+// PipelineName returns the name of the pipeline a pipelinerun belongs to
+// An empty name is returned if the pipeline is not known, e.g. for embedded pipeline specs
+func PipelineName(pipelineRun *tektonv1.PipelineRun) string {
+	pipeline := pipelineRun.Labels["tekton.dev/pipeline"]
+	if pipeline == "" && pipelineRun.Spec.PipelineRef != nil {
+		pipeline = pipelineRun.Spec.PipelineRef.Name
+	}
+	return pipeline
+}
+
+// Record adds the outcome of the pipelinerun to the history of its pipeline and returns the
+// number of consecutive failures, including this one, and whether the pipeline is flaky.
+// Recording the same pipelinerun again does not change the history.
+func (h *PipelineHistory) Record(key string, uid types.UID, succeeded bool) (int, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	outcomes := h.outcomes[key]
+	recorded := false
+	for _, outcome := range outcomes {
+		if outcome.uid == uid {
+			recorded = true
+			break
+		}
+	}
+	if !recorded {
+		outcomes = append(outcomes, pipelineOutcome{uid: uid, succeeded: succeeded})
+		if len(outcomes) > PipelineHistoryWindow {
+			outcomes = outcomes[len(outcomes)-PipelineHistoryWindow:]
+		}
+		h.outcomes[key] = outcomes
+	}
+
+	streak := 0
+	for i := len(outcomes) - 1; i >= 0 && !outcomes[i].succeeded; i-- {
+		streak++
+	}
+	transitions := 0
+	for i := 1; i < len(outcomes); i++ {
+		if outcomes[i].succeeded != outcomes[i-1].succeeded {
+			transitions++
+		}
+	}
+	return streak, transitions >= FlakyTransitions
+}
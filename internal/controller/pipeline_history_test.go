@@ -0,0 +1,94 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("Pipeline history", func() {
+	var history *PipelineHistory
+	run := 0
+
+	record := func(succeeded bool) (int, bool) {
+		run++
+		return history.Record("default/build", types.UID(fmt.Sprint(run)), succeeded)
+	}
+
+	BeforeEach(func() {
+		history = NewPipelineHistory()
+	})
+
+	It("should count consecutive failures", func() {
+		streak, _ := record(false)
+		Expect(streak).To(Equal(1))
+		streak, _ = record(false)
+		Expect(streak).To(Equal(2))
+		streak, _ = record(true)
+		Expect(streak).To(Equal(0))
+	})
+
+	It("should not count the same pipelinerun twice", func() {
+		Expect(history.Record("default/build", "retried", false)).To(Equal(1))
+		streak, _ := history.Record("default/build", "retried", false)
+		Expect(streak).To(Equal(1))
+	})
+
+	It("should detect pipelines alternating between success and failure", func() {
+		var flaky bool
+		for _, succeeded := range []bool{true, false, true, false} {
+			_, flaky = record(succeeded)
+			Expect(flaky).To(BeFalse())
+		}
+		_, flaky = record(true)
+		Expect(flaky).To(BeTrue())
+	})
+
+	It("should notify escalation destinations once the streak is reached", func() {
+		destinations := []DestinationNotifier{{Name: "team"}, {Name: "lead", EscalateAfterFailures: 3}}
+		names := func(notification *notifier.Notification) []string {
+			var names []string
+			for _, destination := range FilterEscalationDestinations(destinations, notification) {
+				names = append(names, destination.Name)
+			}
+			return names
+		}
+		Expect(names(&notifier.Notification{Status: notifier.StatusFailed, FailureStreak: 2})).To(Equal([]string{"team"}))
+		Expect(names(&notifier.Notification{Status: notifier.StatusFailed, FailureStreak: 3})).To(Equal([]string{"team", "lead"}))
+		Expect(names(&notifier.Notification{Status: notifier.StatusSucceeded})).To(Equal([]string{"team"}))
+	})
+
+	It("should add the failure streak to notifications of failed pipelineruns", func() {
+		fake := &fakeNotifier{}
+		r := &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Notifier: fake, History: history}
+		for _, name := range []string{"failed-1", "failed-2"} {
+			pipelineRun := createPipelineRun(name, corev1.ConditionFalse)
+			Expect(reconcilePipelineRun(r, pipelineRun)).To(Succeed())
+			Expect(getPipelineRun(pipelineRun).Finalizers).To(BeEmpty())
+		}
+		Expect(fake.notifications).To(HaveLen(2))
+		Expect(fake.notifications[1].Status).To(Equal(notifier.StatusFailed))
+		Expect(fake.notifications[1].FailureStreak).To(Equal(2))
+	})
+})
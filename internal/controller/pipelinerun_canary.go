@@ -0,0 +1,234 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/yaml"
+
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+)
+
+// NotificationCanaryLabel marks the synthetic pipelineruns created by the PipelineRunCanary
+var NotificationCanaryLabel = DefaultMarkerPrefix + "/notification-canary"
+
+// DefaultCanaryTimeout bounds how long a canary pipelinerun has to run and be delivered to every destination
+const DefaultCanaryTimeout = 10 * time.Minute
+
+// DefaultCanaryImage is the image of the single step of the default canary pipelinerun
+const DefaultCanaryImage string = "registry.access.redhat.com/ubi9/ubi-minimal:latest"
+
+// defaultCanaryPollInterval is how often the canary checks whether its pipelinerun was delivered
+const defaultCanaryPollInterval = 5 * time.Second
+
+var canarySuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "notification_canary_success",
+	Help: "Whether the notification about the last canary pipelinerun was delivered to the destination, 1 if it was and 0 otherwise",
+}, []string{"destination"})
+
+func init() {
+	metrics.Registry.MustRegister(canarySuccess)
+}
+
+// PipelineRunCanary continuously proves that notifications flow end to end: at every interval it creates
+// a tiny synthetic pipelinerun in its namespace, waits until the notification about its end was delivered
+// to every destination of the namespace and exports the outcome per destination as the
+// notification_canary_success metric, then deletes the pipelinerun.
+type PipelineRunCanary struct {
+	// Reconciler resolves the destinations of the canary namespace, including its default notifier
+	Reconciler *NotificationServiceReconciler
+	Log        logr.Logger
+	// Namespace is where the canary pipelineruns are created
+	Namespace string
+	// PipelineRun is the template of the canary pipelineruns, DefaultCanaryPipelineRun if not set
+	PipelineRun *tektonv1.PipelineRun
+	// Interval is how often a canary pipelinerun is created
+	Interval time.Duration
+	// Timeout bounds how long a canary pipelinerun has to be delivered, DefaultCanaryTimeout if not set
+	Timeout time.Duration
+
+	pollInterval time.Duration
+}
+
+// DefaultCanaryPipelineRun returns a pipelinerun of a single task whose single step succeeds immediately
+func DefaultCanaryPipelineRun() *tektonv1.PipelineRun {
+	return &tektonv1.PipelineRun{
+		Spec: tektonv1.PipelineRunSpec{
+			PipelineSpec: &tektonv1.PipelineSpec{
+				Tasks: []tektonv1.PipelineTask{{
+					Name: "canary",
+					TaskSpec: &tektonv1.EmbeddedTask{TaskSpec: tektonv1.TaskSpec{
+						Steps: []tektonv1.Step{{Name: "canary", Image: DefaultCanaryImage, Command: []string{"true"}}},
+					}},
+				}},
+			},
+		},
+	}
+}
+
+// ParseCanaryPipelineRun parses the YAML template of the canary pipelineruns
+// Return error if the template is malformed or has no pipeline
+func ParseCanaryPipelineRun(data []byte) (*tektonv1.PipelineRun, error) {
+	pipelineRun := &tektonv1.PipelineRun{}
+	if err := yaml.UnmarshalStrict(data, pipelineRun); err != nil {
+		return nil, fmt.Errorf("Failed to parse canary pipelinerun: %w", err)
+	}
+	if pipelineRun.Spec.PipelineRef == nil && pipelineRun.Spec.PipelineSpec == nil {
+		return nil, fmt.Errorf("Canary pipelinerun has neither pipelineRef nor pipelineSpec")
+	}
+	return pipelineRun, nil
+}
+
+// NeedLeaderElection returns true so only the replica reconciling pipelineruns creates canaries
+func (c *PipelineRunCanary) NeedLeaderElection() bool {
+	return true
+}
+
+// Start runs a canary every interval until the context is cancelled
+func (c *PipelineRunCanary) Start(ctx context.Context) error {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	for {
+		err := c.RunOnce(ctx)
+		if err != nil {
+			c.Log.Error(err, "Canary failed")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce creates a canary pipelinerun, waits until it was delivered to every active destination of the
+// namespace and records the outcome of every destination. Namespaces without destinations are not canaried.
+// Return error if the pipelinerun could not be created or was not delivered to a destination in time
+func (c *PipelineRunCanary) RunOnce(ctx context.Context) error {
+	expected, err := c.destinations(ctx)
+	if err != nil {
+		return err
+	}
+	if len(expected) == 0 {
+		c.Log.Info("Skipping canary of namespace without destinations", "namespace", c.Namespace)
+		return nil
+	}
+
+	template := c.PipelineRun
+	if template == nil {
+		template = DefaultCanaryPipelineRun()
+	}
+	pipelineRun := template.DeepCopy()
+	pipelineRun.Namespace = c.Namespace
+	if pipelineRun.Name == "" && pipelineRun.GenerateName == "" {
+		pipelineRun.GenerateName = "notification-canary-"
+	}
+	if pipelineRun.Labels == nil {
+		pipelineRun.Labels = map[string]string{}
+	}
+	pipelineRun.Labels[NotificationCanaryLabel] = "true"
+	err = c.Reconciler.Create(ctx, pipelineRun)
+	if err != nil {
+		return fmt.Errorf("Failed to create canary pipelinerun: %w", err)
+	}
+	defer func() {
+		err := c.Reconciler.Delete(context.Background(), pipelineRun)
+		if client.IgnoreNotFound(err) != nil {
+			c.Log.Error(err, "Failed to delete canary pipelinerun", "name", pipelineRun.Name)
+		}
+	}()
+
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultCanaryTimeout
+	}
+	pollInterval := c.pollInterval
+	if pollInterval <= 0 {
+		pollInterval = defaultCanaryPollInterval
+	}
+	delivered := map[string]time.Time{}
+	_ = wait.PollUntilContextTimeout(ctx, pollInterval, timeout, true, func(ctx context.Context) (bool, error) {
+		current := &tektonv1.PipelineRun{}
+		if err := c.Reconciler.Get(ctx, client.ObjectKeyFromObject(pipelineRun), current); err != nil {
+			return false, nil
+		}
+		if err := LoadPipelineRunState(ctx, c.Reconciler, current); err != nil {
+			return false, nil
+		}
+		delivered, err = GetDeliveredDestinations(current)
+		if err != nil {
+			return false, nil
+		}
+		for _, destination := range expected {
+			if _, ok := delivered[destination]; !ok {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+
+	canarySuccess.Reset()
+	var missing []string
+	for _, destination := range expected {
+		success := 1.0
+		if _, ok := delivered[destination]; !ok {
+			success = 0
+			missing = append(missing, destination)
+		}
+		canarySuccess.WithLabelValues(destination).Set(success)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("Canary pipelinerun %s was not delivered to %s within %s", pipelineRun.Name, strings.Join(missing, ", "), timeout)
+	}
+	c.Log.Info("Canary succeeded", "name", pipelineRun.Name, "destinations", expected)
+	return nil
+}
+
+// destinations returns the names of the destinations expected to receive the notification about a
+// canary pipelinerun, excluding the paused ones and the ones restricted to other events, failures, policy outcomes or results
+func (c *PipelineRunCanary) destinations(ctx context.Context) ([]string, error) {
+	destinations, err := GetDestinationNotifiers(ctx, c.Reconciler, c.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	if c.Reconciler.Notifier != nil {
+		destinations = append(destinations, DestinationNotifier{Name: DefaultDestinationName, Notifier: c.Reconciler.Notifier})
+	}
+	notification := &notifier.Notification{Status: notifier.StatusSucceeded, Event: notifier.EventSucceeded}
+	destinations = FilterEventDestinations(destinations, notification)
+	destinations = FilterEscalationDestinations(destinations, notification)
+	destinations = FilterPolicyDestinations(destinations, notification)
+	destinations = FilterResultDestinations(destinations, notification)
+	destinations, _ = SplitPausedDestinations(destinations)
+	names := make([]string, 0, len(destinations))
+	for _, destination := range destinations {
+		names = append(names, destination.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
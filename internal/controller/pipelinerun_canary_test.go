@@ -0,0 +1,95 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("PipelineRunCanary", func() {
+	var (
+		fake   *fakeNotifier
+		r      *NotificationServiceReconciler
+		canary *PipelineRunCanary
+	)
+
+	listCanaries := func() []tektonv1.PipelineRun {
+		pipelineRuns := &tektonv1.PipelineRunList{}
+		Expect(k8sClient.List(context.Background(), pipelineRuns, client.InNamespace("default"),
+			client.HasLabels{NotificationCanaryLabel})).To(Succeed())
+		return pipelineRuns.Items
+	}
+
+	BeforeEach(func() {
+		fake = &fakeNotifier{}
+		r = &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Notifier: fake}
+		canary = &PipelineRunCanary{Reconciler: r, Namespace: "default", Timeout: 10 * time.Second, pollInterval: 50 * time.Millisecond}
+	})
+
+	It("should succeed once the canary pipelinerun was delivered to every destination", func() {
+		done := make(chan error, 1)
+		go func() {
+			defer GinkgoRecover()
+			done <- canary.RunOnce(context.Background())
+		}()
+		Eventually(listCanaries, "5s").Should(HaveLen(1))
+		pipelineRun := &listCanaries()[0]
+		Expect(pipelineRun.GenerateName).To(Equal("notification-canary-"))
+		Expect(pipelineRun.Spec.PipelineSpec.Tasks[0].TaskSpec.Steps[0].Image).To(Equal(DefaultCanaryImage))
+		Expect(reconcilePipelineRun(r, pipelineRun)).To(Succeed())
+		pipelineRun = getPipelineRun(pipelineRun)
+		pipelineRun.Status.MarkSucceeded("Succeeded", "")
+		Expect(k8sClient.Status().Update(context.Background(), pipelineRun)).To(Succeed())
+		Expect(reconcilePipelineRun(r, pipelineRun)).To(Succeed())
+
+		Eventually(done, "5s").Should(Receive(BeNil()))
+		Expect(fake.notifications).To(HaveLen(1))
+		Expect(testutil.ToFloat64(canarySuccess.WithLabelValues(DefaultDestinationName))).To(Equal(1.0))
+		Eventually(listCanaries).Should(BeEmpty())
+	})
+
+	It("should fail when the canary pipelinerun is not delivered in time", func() {
+		canary.Timeout = 200 * time.Millisecond
+		Expect(canary.RunOnce(context.Background())).To(MatchError(ContainSubstring("was not delivered to " + DefaultDestinationName)))
+		Expect(testutil.ToFloat64(canarySuccess.WithLabelValues(DefaultDestinationName))).To(BeZero())
+		Eventually(listCanaries).Should(BeEmpty())
+	})
+
+	It("should not run without destinations", func() {
+		r.Notifier = nil
+		Expect(canary.RunOnce(context.Background())).To(Succeed())
+		Expect(listCanaries()).To(BeEmpty())
+	})
+
+	It("should parse canary pipelinerun templates", func() {
+		pipelineRun, err := ParseCanaryPipelineRun([]byte("metadata:\n  generateName: canary-\nspec:\n  pipelineRef:\n    name: smoke\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pipelineRun.Spec.PipelineRef.Name).To(Equal("smoke"))
+		_, err = ParseCanaryPipelineRun([]byte("metadata:\n  name: canary\n"))
+		Expect(err).To(MatchError(ContainSubstring("neither pipelineRef nor pipelineSpec")))
+		_, err = ParseCanaryPipelineRun([]byte("spec:\n  pipelineReference: {}\n"))
+		Expect(err).To(HaveOccurred())
+	})
+})
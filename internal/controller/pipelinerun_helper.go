@@ -4,30 +4,47 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
+	"time"
 
+	"github.com/konflux-ci/notification-service/pkg/notifier"
 	"github.com/konflux-ci/operator-toolkit/metadata"
 	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"knative.dev/pkg/apis"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
-const NotificationPipelineRunFinalizer string = "konflux.ci/notification"
-const NotificationPipelineRunAnnotation string = "konflux.ci/notified"
+// NotificationPipelineRunFinalizer holds pipelineruns until they are handled
+var NotificationPipelineRunFinalizer = DefaultMarkerPrefix + "/notification"
+
+// NotificationPipelineRunAnnotation marks the pipelineruns that were notified about
+var NotificationPipelineRunAnnotation = DefaultMarkerPrefix + "/notified"
+
 const NotificationPipelineRunAnnotationValue string = "true"
 
+// NotificationFieldManager is the field manager owning the finalizer and annotations set by the controller on pipelineruns
+var NotificationFieldManager = DefaultFieldManager
+
 // AddFinalizerToPipelineRun adds the finalizer to the PipelineRun.
 // If finalizer was not added successfully, a non-nil error is returned.
 func AddFinalizerToPipelineRun(ctx context.Context, pipelineRun *tektonv1.PipelineRun, r *NotificationServiceReconciler, finalizer string) error {
 	r.Log.Info("Adding finalizer")
-	patch := client.MergeFrom(pipelineRun.DeepCopy())
-	if ok := controllerutil.AddFinalizer(pipelineRun, finalizer); ok {
-		err := r.Client.Patch(ctx, pipelineRun, patch)
-		if err != nil {
-			return fmt.Errorf("Error occurred while patching the updated PipelineRun after finalizer addition: %w", err)
-		}
-		r.Log.Info("Finalizer was added to PipelineRun %s", pipelineRun.Name)
+	if controllerutil.ContainsFinalizer(pipelineRun, finalizer) {
+		return nil
+	}
+	err := applyPipelineRunMetadata(ctx, pipelineRun, r.Client, false, func(applied *tektonv1.PipelineRun) {
+		controllerutil.AddFinalizer(applied, finalizer)
+	})
+	if err != nil {
+		return fmt.Errorf("Error occurred while patching the updated PipelineRun after finalizer addition: %w", err)
 	}
+	r.Log.Info("Finalizer was added to PipelineRun %s", pipelineRun.Name)
 	return nil
 }
 
@@ -35,14 +52,16 @@ func AddFinalizerToPipelineRun(ctx context.Context, pipelineRun *tektonv1.Pipeli
 // If finalizer was not removed successfully, a non-nil error is returned.
 func RemoveFinalizerFromPipelineRun(ctx context.Context, pipelineRun *tektonv1.PipelineRun, r *NotificationServiceReconciler, finalizer string) error {
 	r.Log.Info("Removing finalizer")
-	patch := client.MergeFrom(pipelineRun.DeepCopy())
-	if ok := controllerutil.RemoveFinalizer(pipelineRun, finalizer); ok {
-		err := r.Client.Patch(ctx, pipelineRun, patch)
-		if err != nil {
-			return fmt.Errorf("Error occurred while patching the updated PipelineRun after finalizer removal: %w", err)
-		}
-		r.Log.Info("Finalizer was removed from %s", pipelineRun.Name)
+	if !controllerutil.ContainsFinalizer(pipelineRun, finalizer) {
+		return nil
 	}
+	err := applyPipelineRunMetadata(ctx, pipelineRun, r.Client, false, func(applied *tektonv1.PipelineRun) {
+		controllerutil.RemoveFinalizer(applied, finalizer)
+	})
+	if err != nil {
+		return fmt.Errorf("Error occurred while patching the updated PipelineRun after finalizer removal: %w", err)
+	}
+	r.Log.Info("Finalizer was removed from %s", pipelineRun.Name)
 	return nil
 }
 
@@ -56,16 +75,131 @@ func GetResultsFromPipelineRun(pipelineRun *tektonv1.PipelineRun) ([]byte, error
 	return results, nil
 }
 
+// GetNotificationFromPipelineRun builds the notification that is sent for the pipelinerun
+// Policy results are summarized, see GetPolicySummary, instead of being included as is.
+// Return error if failed to extract results
+func GetNotificationFromPipelineRun(pipelineRun *tektonv1.PipelineRun) (*notifier.Notification, error) {
+	policy := GetPolicySummary(pipelineRun)
+	results := make([]notifier.Result, 0, len(pipelineRun.Status.Results))
+	for _, result := range pipelineRun.Status.Results {
+		if policy != nil && slices.Contains(policy.Results, result.Name) {
+			continue
+		}
+		results = append(results, getResult(result.Name, result.Value))
+	}
+	return &notifier.Notification{
+		PipelineRun: pipelineRun.Name,
+		Namespace:   pipelineRun.Namespace,
+		Status:      GetPipelineRunStatus(pipelineRun),
+		Event:       GetPipelineRunEvent(pipelineRun),
+		Results:     results,
+		Policy:      policy,
+	}, nil
+}
+
+// getResult returns the notification result of a pipelinerun or taskrun result
+func getResult(name string, value tektonv1.ResultValue) notifier.Result {
+	switch value.Type {
+	case tektonv1.ParamTypeArray:
+		return notifier.Result{Name: name, Type: notifier.ResultTypeArray, Array: value.ArrayVal}
+	case tektonv1.ParamTypeObject:
+		return notifier.Result{Name: name, Type: notifier.ResultTypeObject, Object: value.ObjectVal}
+	default:
+		return notifier.Result{Name: name, Value: value.StringVal}
+	}
+}
+
+// SetNotificationTiming adds the timestamps and duration of the pipelineRun and of its TaskRuns to the notification,
+// along with how many times the TaskRuns were retried. Succeeded notifications about pipelineRuns whose TaskRuns
+// were retried get the EventSucceededWithRetries subtype.
+// Durations of runs that did not complete yet are measured up to now. TaskRuns that no longer exist are skipped.
+// Return error if failed to get a TaskRun
+func SetNotificationTiming(ctx context.Context, c client.Reader, pipelineRun *tektonv1.PipelineRun, notification *notifier.Notification, now time.Time) error {
+	notification.StartTime, notification.CompletionTime, notification.DurationSeconds =
+		getTiming(pipelineRun.Status.StartTime, pipelineRun.Status.CompletionTime, now)
+	for _, child := range pipelineRun.Status.ChildReferences {
+		if child.Kind != "TaskRun" {
+			continue
+		}
+		taskRun := &tektonv1.TaskRun{}
+		err := c.Get(ctx, types.NamespacedName{Namespace: pipelineRun.Namespace, Name: child.Name}, taskRun)
+		if k8serrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to get taskrun %s of pipelinerun %s: %w", child.Name, pipelineRun.Name, err)
+		}
+		timing := notifier.TaskTiming{Name: child.PipelineTaskName, TaskRun: child.Name}
+		timing.StartTime, timing.CompletionTime, timing.DurationSeconds =
+			getTiming(taskRun.Status.StartTime, taskRun.Status.CompletionTime, now)
+		timing.Retries = len(taskRun.Status.RetriesStatus)
+		notification.Retries += timing.Retries
+		notification.Tasks = append(notification.Tasks, timing)
+	}
+	if notification.Event == notifier.EventSucceeded && notification.Retries > 0 {
+		notification.Subtype = notifier.EventSucceededWithRetries
+	}
+	return nil
+}
+
+// getTiming returns the timestamps of a run and its duration in seconds, up to now if it did not complete
+func getTiming(startTime *metav1.Time, completionTime *metav1.Time, now time.Time) (*time.Time, *time.Time, float64) {
+	if startTime == nil {
+		return nil, nil, 0
+	}
+	start := startTime.Time.UTC()
+	if completionTime == nil {
+		return &start, nil, now.Sub(start).Seconds()
+	}
+	completion := completionTime.Time.UTC()
+	return &start, &completion, completion.Sub(start).Seconds()
+}
+
+// GetPipelineRunStatus returns the notification status matching the Succeeded condition of the pipelinerun
+func GetPipelineRunStatus(pipelineRun *tektonv1.PipelineRun) string {
+	return getConditionStatus(pipelineRun.Status.GetCondition(apis.ConditionSucceeded))
+}
+
+// getConditionStatus returns the notification status matching the Succeeded condition of a run
+func getConditionStatus(condition *apis.Condition) string {
+	switch {
+	case condition.IsTrue():
+		return notifier.StatusSucceeded
+	case condition.IsFalse():
+		return notifier.StatusFailed
+	default:
+		return notifier.StatusStarted
+	}
+}
+
+// GetPipelineRunEvent returns the event matching the Succeeded condition of the pipelinerun,
+// telling cancelled pipelineruns from the ones that failed
+func GetPipelineRunEvent(pipelineRun *tektonv1.PipelineRun) string {
+	return getConditionEvent(pipelineRun.Status.GetCondition(apis.ConditionSucceeded), tektonv1.PipelineRunReasonCancelled.String())
+}
+
+// getConditionEvent returns the event matching the Succeeded condition of a run, which is cancelled
+// if the condition has the cancelledReason
+func getConditionEvent(condition *apis.Condition, cancelledReason string) string {
+	switch {
+	case condition.IsTrue():
+		return notifier.EventSucceeded
+	case condition.IsFalse() && condition.Reason == cancelledReason:
+		return notifier.EventCancelled
+	case condition.IsFalse():
+		return notifier.EventFailed
+	default:
+		return notifier.EventStarted
+	}
+}
+
 // AddNotificationAnnotationToPipelineRun adds an annotation to the PipelineRun.
 // If annotation was not added successfully, a non-nil error is returned.
 func AddAnnotationToPipelineRun(ctx context.Context, pipelineRun *tektonv1.PipelineRun, r *NotificationServiceReconciler, annotation string, annotationValue string) error {
 	r.Log.Info("Adding annotation")
-	patch := client.MergeFrom(pipelineRun.DeepCopy())
-	err := metadata.SetAnnotation(&pipelineRun.ObjectMeta, annotation, annotationValue)
-	if err != nil {
-		return fmt.Errorf("Error occurred while setting the annotation: %w", err)
-	}
-	err = r.Client.Patch(ctx, pipelineRun, patch)
+	err := applyPipelineRunMetadata(ctx, pipelineRun, r.Client, false, func(applied *tektonv1.PipelineRun) {
+		_ = metadata.SetAnnotation(&applied.ObjectMeta, annotation, annotationValue)
+	})
 	if err != nil {
 		r.Log.Info("Error in update annotation client: %s", err)
 		return fmt.Errorf("Error occurred while patching the updated pipelineRun after annotation addition: %w", err)
@@ -83,6 +217,16 @@ func IsFinalizerExistInPipelineRun(pipelineRun *tektonv1.PipelineRun, finalizer
 	return false
 }
 
+// IsPipelineRunRunning returns a boolean indicating whether the PipelineRun started and did not end yet.
+func IsPipelineRunRunning(pipelineRun *tektonv1.PipelineRun) bool {
+	return pipelineRun.Status.StartTime != nil && pipelineRun.Status.GetCondition(apis.ConditionSucceeded).IsUnknown()
+}
+
+// IsPipelineRunEnded returns a boolean indicating whether the PipelineRun ended, successfully or not.
+func IsPipelineRunEnded(pipelineRun *tektonv1.PipelineRun) bool {
+	return !pipelineRun.Status.GetCondition(apis.ConditionSucceeded).IsUnknown()
+}
+
 // IsPipelineRunEndedSuccessfully returns a boolean indicating whether the PipelineRun succeeded or not.
 func IsPipelineRunEndedSuccessfully(pipelineRun *tektonv1.PipelineRun) bool {
 	return pipelineRun.Status.GetCondition(apis.ConditionSucceeded).IsTrue()
@@ -96,3 +240,96 @@ func IsAnnotationExistInPipelineRun(pipelineRun *tektonv1.PipelineRun, annotatio
 	}
 	return false
 }
+
+// pipelineRunOwnerReferences returns the owner references of the objects created for the pipelineRun,
+// so they are deleted with it. It returns nil if the pipelineRun has no UID.
+func pipelineRunOwnerReferences(pipelineRun *tektonv1.PipelineRun) []metav1.OwnerReference {
+	if pipelineRun.UID == "" {
+		return nil
+	}
+	return []metav1.OwnerReference{{
+		APIVersion: tektonv1.SchemeGroupVersion.String(),
+		Kind:       "PipelineRun",
+		Name:       pipelineRun.Name,
+		UID:        pipelineRun.UID,
+	}}
+}
+
+// getJSONAnnotation decodes the JSON value of the annotation of the pipelineRun into value
+// value is left unchanged if the annotation does not exist
+func getJSONAnnotation(pipelineRun *tektonv1.PipelineRun, annotation string, value any) error {
+	encoded, ok := pipelineRun.GetAnnotations()[annotation]
+	if !ok || encoded == "" {
+		return nil
+	}
+	err := json.Unmarshal([]byte(encoded), value)
+	if err != nil {
+		return fmt.Errorf("Failed to decode annotation %s of pipelinerun %s: %w", annotation, pipelineRun.Name, err)
+	}
+	return nil
+}
+
+// setJSONAnnotation stores the JSON encoded value in the annotation of the pipelineRun
+// If the annotation was not updated successfully, a non-nil error is returned.
+func setJSONAnnotation(ctx context.Context, pipelineRun *tektonv1.PipelineRun, c client.Client, annotation string, value any) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("Failed to encode annotation %s: %w", annotation, err)
+	}
+	err = applyPipelineRunMetadata(ctx, pipelineRun, c, false, func(applied *tektonv1.PipelineRun) {
+		_ = metadata.SetAnnotation(&applied.ObjectMeta, annotation, string(encoded))
+	})
+	if err != nil {
+		return fmt.Errorf("Error occurred while patching annotation %s of pipelineRun: %w", annotation, err)
+	}
+	return nil
+}
+
+// applyPipelineRunMetadata server-side applies the finalizer and annotations owned by the controller,
+// as changed by mutate, with the NotificationFieldManager field manager. Server-side apply removes the
+// owned fields that are not applied again, so the owned fields currently set on the pipelineRun are
+// applied along with the change. With optimisticLock, the apply fails with a conflict if the
+// pipelineRun changed since it was read. State annotations exceeding MaxStateAnnotationBytes are stored in
+// the NotificationState of the pipelineRun. The pipelineRun is updated with the applied object, keeping
+// the stored state in its annotations.
+func applyPipelineRunMetadata(ctx context.Context, pipelineRun *tektonv1.PipelineRun, c client.Client,
+	optimisticLock bool, mutate func(applied *tektonv1.PipelineRun)) error {
+	applied := &tektonv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: pipelineRun.Name, Namespace: pipelineRun.Namespace}}
+	if controllerutil.ContainsFinalizer(pipelineRun, NotificationPipelineRunFinalizer) {
+		controllerutil.AddFinalizer(applied, NotificationPipelineRunFinalizer)
+	}
+	for _, annotation := range ownedPipelineRunAnnotations() {
+		if value, ok := pipelineRun.GetAnnotations()[annotation]; ok {
+			_ = metadata.SetAnnotation(&applied.ObjectMeta, annotation, value)
+		}
+	}
+	mutate(applied)
+	stored, err := storePipelineRunState(ctx, c, pipelineRun, applied)
+	if err != nil {
+		return err
+	}
+
+	configuration := &unstructured.Unstructured{}
+	configuration.SetGroupVersionKind(tektonv1.SchemeGroupVersion.WithKind("PipelineRun"))
+	configuration.SetName(applied.Name)
+	configuration.SetNamespace(applied.Namespace)
+	configuration.SetFinalizers(applied.Finalizers)
+	configuration.SetAnnotations(applied.Annotations)
+	if optimisticLock {
+		configuration.SetResourceVersion(pipelineRun.ResourceVersion)
+	}
+	err = c.Patch(ctx, configuration, client.Apply, client.FieldOwner(NotificationFieldManager), client.ForceOwnership)
+	if err != nil {
+		return err
+	}
+	updated := &tektonv1.PipelineRun{}
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(configuration.UnstructuredContent(), updated)
+	if err != nil {
+		return fmt.Errorf("Failed to decode applied pipelinerun %s: %w", pipelineRun.Name, err)
+	}
+	for annotation, value := range stored {
+		updated.Annotations[annotation] = value
+	}
+	*pipelineRun = *updated
+	return nil
+}
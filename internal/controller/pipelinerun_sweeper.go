@@ -0,0 +1,170 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+// PendingPipelineRunIndex indexes the pipelineruns that ended and are not handled yet
+const PendingPipelineRunIndex string = "notification.konflux.ci/pending"
+
+// PendingPipelineRunIndexValue is the value of PendingPipelineRunIndex for pending pipelineruns
+const PendingPipelineRunIndexValue string = "true"
+
+// RunningPipelineRunIndex indexes the pipelineruns that did not end yet
+const RunningPipelineRunIndex string = "notification.konflux.ci/running"
+
+// RunningPipelineRunIndexValue is the value of RunningPipelineRunIndex for running pipelineruns
+const RunningPipelineRunIndexValue string = "true"
+
+// DefaultSweepInterval is how often the PipelineRunSweeper looks for pending pipelineruns
+const DefaultSweepInterval = 10 * time.Minute
+
+// IndexPendingPipelineRuns registers PendingPipelineRunIndex with the field indexer of the cache
+// Return error if the index was not registered
+func IndexPendingPipelineRuns(ctx context.Context, indexer client.FieldIndexer) error {
+	err := indexer.IndexField(ctx, &tektonv1.PipelineRun{}, PendingPipelineRunIndex, func(obj client.Object) []string {
+		pipelineRun, ok := obj.(*tektonv1.PipelineRun)
+		if !ok || !IsPipelineRunPending(pipelineRun) {
+			return nil
+		}
+		return []string{PendingPipelineRunIndexValue}
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to index pending pipelineruns: %w", err)
+	}
+	return nil
+}
+
+// IndexRunningPipelineRuns registers RunningPipelineRunIndex with the field indexer of the cache
+// Return error if the index was not registered
+func IndexRunningPipelineRuns(ctx context.Context, indexer client.FieldIndexer) error {
+	err := indexer.IndexField(ctx, &tektonv1.PipelineRun{}, RunningPipelineRunIndex, func(obj client.Object) []string {
+		pipelineRun, ok := obj.(*tektonv1.PipelineRun)
+		if !ok || IsPipelineRunEnded(pipelineRun) {
+			return nil
+		}
+		return []string{RunningPipelineRunIndexValue}
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to index running pipelineruns: %w", err)
+	}
+	return nil
+}
+
+// IsPipelineRunPending returns a boolean indicating whether the PipelineRun ended and
+// was not notified about yet or still has to be released.
+func IsPipelineRunPending(pipelineRun *tektonv1.PipelineRun) bool {
+	if !IsPipelineRunEnded(pipelineRun) {
+		return false
+	}
+	return !IsAnnotationExistInPipelineRun(pipelineRun, NotificationPipelineRunAnnotation, NotificationPipelineRunAnnotationValue) ||
+		IsFinalizerExistInPipelineRun(pipelineRun, NotificationPipelineRunFinalizer)
+}
+
+// PipelineRunSweeper reconciles again the pipelineruns that ended without being handled, e.g. because
+// the controller was down when they ended or their notification failed. It sweeps on startup and then
+// at every interval, listing only the pending pipelineruns through PendingPipelineRunIndex.
+type PipelineRunSweeper struct {
+	// Client must be backed by a cache with PendingPipelineRunIndex
+	Client client.Reader
+	Log    logr.Logger
+	// Interval is how often pending pipelineruns are swept
+	Interval time.Duration
+	// Selects restricts the pending pipelineruns that are queued, e.g. to those matching the pipelinerun selector
+	// of the reconciler, so pipelineruns that are never handled do not fill the batches. All are queued if nil.
+	Selects func(*tektonv1.PipelineRun) bool
+	// BatchSize is the maximum number of pending pipelineruns queued per sweep, those that ended first,
+	// so a large backlog is reconciled over several sweeps. All of them are queued if zero.
+	BatchSize int
+	// Events receives the pending pipelineruns, it is the source set as Sweeps of the reconciler
+	Events chan<- event.GenericEvent
+}
+
+// NeedLeaderElection returns true so only the replica reconciling pipelineruns sweeps them
+func (s *PipelineRunSweeper) NeedLeaderElection() bool {
+	return true
+}
+
+// Start sweeps the pending pipelineruns now and every interval until the context is cancelled
+func (s *PipelineRunSweeper) Start(ctx context.Context) error {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = DefaultSweepInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		swept, err := s.RunOnce(ctx)
+		if err != nil {
+			s.Log.Error(err, "Failed to sweep pipelineruns")
+		} else if swept > 0 {
+			s.Log.Info("Swept pending pipelineruns", "count", swept)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce queues the pending pipelineruns it selects for reconciliation, up to BatchSize of them, and returns how many were queued
+func (s *PipelineRunSweeper) RunOnce(ctx context.Context) (int, error) {
+	pipelineRuns := &tektonv1.PipelineRunList{}
+	err := s.Client.List(ctx, pipelineRuns, client.MatchingFields{PendingPipelineRunIndex: PendingPipelineRunIndexValue})
+	if err != nil {
+		return 0, fmt.Errorf("Failed to list pending pipelineruns: %w", err)
+	}
+	if s.Selects != nil {
+		pipelineRuns.Items = slices.DeleteFunc(pipelineRuns.Items, func(pipelineRun tektonv1.PipelineRun) bool {
+			return !s.Selects(&pipelineRun)
+		})
+	}
+	if s.BatchSize > 0 && len(pipelineRuns.Items) > s.BatchSize {
+		sort.Slice(pipelineRuns.Items, func(i, j int) bool {
+			return endTime(&pipelineRuns.Items[i]).Before(endTime(&pipelineRuns.Items[j]))
+		})
+		pipelineRuns.Items = pipelineRuns.Items[:s.BatchSize]
+	}
+	for i := range pipelineRuns.Items {
+		select {
+		case <-ctx.Done():
+			return i, nil
+		case s.Events <- event.GenericEvent{Object: &pipelineRuns.Items[i]}:
+		}
+	}
+	return len(pipelineRuns.Items), nil
+}
+
+// endTime returns when the pipelinerun ended, or when it was created if its completion time is not set
+func endTime(pipelineRun *tektonv1.PipelineRun) time.Time {
+	if pipelineRun.Status.CompletionTime != nil {
+		return pipelineRun.Status.CompletionTime.Time
+	}
+	return pipelineRun.CreationTimestamp.Time
+}
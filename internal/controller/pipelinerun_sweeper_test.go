@@ -0,0 +1,137 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+var _ = Describe("PipelineRun sweeper", func() {
+	It("should only queue the pipelineruns that ended and are not handled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		DeferCleanup(cancel)
+		informers, err := cache.New(cfg, cache.Options{Scheme: k8sClient.Scheme()})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(IndexPendingPipelineRuns(ctx, informers)).To(Succeed())
+		go func() {
+			defer GinkgoRecover()
+			Expect(informers.Start(ctx)).To(Succeed())
+		}()
+		Expect(informers.WaitForCacheSync(ctx)).To(BeTrue())
+
+		createPipelineRun("sweep-running", "")
+		pending := createPipelineRun("sweep-pending", corev1.ConditionFalse)
+		releasing := createPipelineRun("sweep-releasing", corev1.ConditionTrue)
+		r := &NotificationServiceReconciler{Client: k8sClient}
+		Expect(AddFinalizerToPipelineRun(ctx, releasing, r, NotificationPipelineRunFinalizer)).To(Succeed())
+		Expect(AddAnnotationToPipelineRun(ctx, releasing, r, NotificationPipelineRunAnnotation, NotificationPipelineRunAnnotationValue)).To(Succeed())
+		handled := createPipelineRun("sweep-handled", corev1.ConditionTrue)
+		Expect(AddAnnotationToPipelineRun(ctx, handled, r, NotificationPipelineRunAnnotation, NotificationPipelineRunAnnotationValue)).To(Succeed())
+
+		events := make(chan event.GenericEvent, 100)
+		sweeper := &PipelineRunSweeper{Client: informers, Log: ctrl.Log.WithName("sweeper"), Events: events}
+		Eventually(func() []string {
+			for len(events) > 0 {
+				<-events
+			}
+			_, err := sweeper.RunOnce(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			var names []string
+			for len(events) > 0 {
+				// Ignore the pipelineruns left pending by other tests
+				if name := (<-events).Object.GetName(); strings.HasPrefix(name, "sweep-") {
+					names = append(names, name)
+				}
+			}
+			return names
+		}).Should(ConsistOf(pending.Name, releasing.Name))
+
+		pipelineRun := &tektonv1.PipelineRun{}
+		Expect(informers.Get(ctx, client.ObjectKeyFromObject(handled), pipelineRun)).To(Succeed())
+		Expect(IsPipelineRunPending(pipelineRun)).To(BeFalse())
+
+		sweeper.BatchSize = 1
+		for len(events) > 0 {
+			<-events
+		}
+		swept, err := sweeper.RunOnce(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(swept).To(Equal(1))
+		Expect(events).To(HaveLen(1))
+	})
+	It("should not queue the pending pipelineruns that are not selected", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		DeferCleanup(cancel)
+		informers, err := cache.New(cfg, cache.Options{Scheme: k8sClient.Scheme()})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(IndexPendingPipelineRuns(ctx, informers)).To(Succeed())
+		go func() {
+			defer GinkgoRecover()
+			Expect(informers.Start(ctx)).To(Succeed())
+		}()
+		Expect(informers.WaitForCacheSync(ctx)).To(BeTrue())
+
+		// The unselected pipelinerun ended first, so it would take the only slot of the batch
+		unselected := createPipelineRun("sweep-unselected", corev1.ConditionFalse)
+		unselected.Labels = map[string]string{"notify": "false"}
+		Expect(k8sClient.Update(ctx, unselected)).To(Succeed())
+		selected := createPipelineRun("sweep-selected", corev1.ConditionFalse)
+		selector, err := labels.Parse("notify!=false")
+		Expect(err).NotTo(HaveOccurred())
+		r := &NotificationServiceReconciler{Client: k8sClient, PipelineRunSelector: selector}
+
+		events := make(chan event.GenericEvent, 100)
+		sweeper := &PipelineRunSweeper{Client: informers, Log: ctrl.Log.WithName("sweeper"), Events: events, BatchSize: 1,
+			Selects: func(pipelineRun *tektonv1.PipelineRun) bool {
+				// Ignore the pipelineruns left pending by other tests
+				return strings.HasPrefix(pipelineRun.Name, "sweep-") && r.ReconcilesPendingPipelineRun(pipelineRun)
+			}}
+		Eventually(func() []string {
+			for len(events) > 0 {
+				<-events
+			}
+			_, err := sweeper.RunOnce(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			var names []string
+			for len(events) > 0 {
+				names = append(names, (<-events).Object.GetName())
+			}
+			return names
+		}).Should(Equal([]string{selected.Name}))
+		Consistently(func() []string {
+			_, err := sweeper.RunOnce(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			var names []string
+			for len(events) > 0 {
+				names = append(names, (<-events).Object.GetName())
+			}
+			return names
+		}, "1s").ShouldNot(ContainElement(unselected.Name))
+	})
+})
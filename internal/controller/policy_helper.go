@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+)
+
+// policySeverities are the severities of policy rules, from the worst to the least severe
+var policySeverities = []string{"critical", "high", "medium", "low"}
+
+// policyRule is a violation or warning of an Enterprise Contract or conftest report
+type policyRule struct {
+	Metadata struct {
+		Code     string `json:"code"`
+		Severity string `json:"severity"`
+	} `json:"metadata"`
+}
+
+// enterpriseContractReport is the JSON report of the Enterprise Contract CLI
+type enterpriseContractReport struct {
+	Components *[]struct {
+		Violations []policyRule `json:"violations"`
+		Warnings   []policyRule `json:"warnings"`
+	} `json:"components"`
+}
+
+// conftestResult is the result of a conftest policy check of a file
+type conftestResult struct {
+	Filename *string      `json:"filename"`
+	Failures []policyRule `json:"failures"`
+	Warnings []policyRule `json:"warnings"`
+}
+
+// testOutput is the TEST_OUTPUT result format of the Konflux policy and test tasks
+type testOutput struct {
+	Result   *string `json:"result"`
+	Failures *int    `json:"failures"`
+	Warnings int     `json:"warnings"`
+}
+
+// GetPolicySummary summarizes the results of the pipelineRun that are Enterprise Contract reports,
+// conftest reports or TEST_OUTPUT results of policy checks, including the names of these results.
+// Other results are ignored. It returns nil if the pipelineRun has no policy results.
+func GetPolicySummary(pipelineRun *tektonv1.PipelineRun) *notifier.PolicySummary {
+	summary := &notifier.PolicySummary{}
+	var violations, warnings []policyRule
+	for _, result := range pipelineRun.Status.Results {
+		if result.Value.Type != tektonv1.ParamTypeString {
+			continue
+		}
+		value := []byte(result.Value.StringVal)
+		report := enterpriseContractReport{}
+		if json.Unmarshal(value, &report) == nil && report.Components != nil {
+			for _, component := range *report.Components {
+				violations = append(violations, component.Violations...)
+				warnings = append(warnings, component.Warnings...)
+			}
+			summary.Results = append(summary.Results, result.Name)
+			continue
+		}
+		var conftest []conftestResult
+		if json.Unmarshal(value, &conftest) == nil && len(conftest) > 0 && conftest[0].Filename != nil {
+			for _, file := range conftest {
+				violations = append(violations, file.Failures...)
+				warnings = append(warnings, file.Warnings...)
+			}
+			summary.Results = append(summary.Results, result.Name)
+			continue
+		}
+		output := testOutput{}
+		if json.Unmarshal(value, &output) == nil && output.Result != nil && output.Failures != nil {
+			// TEST_OUTPUT results only count the rules, which have no ID nor severity
+			failures := max(*output.Failures, 0)
+			if *output.Result == "FAILURE" && failures == 0 {
+				failures = 1
+			}
+			violations = append(violations, make([]policyRule, failures)...)
+			warnings = append(warnings, make([]policyRule, max(output.Warnings, 0))...)
+			summary.Results = append(summary.Results, result.Name)
+		}
+	}
+	if len(summary.Results) == 0 {
+		return nil
+	}
+	summary.Violations = len(violations)
+	summary.Warnings = len(warnings)
+	worst := len(policySeverities)
+	for i, rules := range [][]policyRule{violations, warnings} {
+		for _, rule := range rules {
+			if rule.Metadata.Code != "" && !slices.Contains(summary.Rules, rule.Metadata.Code) {
+				summary.Rules = append(summary.Rules, rule.Metadata.Code)
+			}
+			severity := slices.Index(policySeverities, strings.ToLower(rule.Metadata.Severity))
+			if severity < 0 {
+				// Rules without a known severity default to high for violations and low for warnings
+				severity = 1 + 2*i
+			}
+			worst = min(worst, severity)
+		}
+	}
+	switch {
+	case summary.Violations > 0:
+		summary.Outcome = notifier.PolicyFailed
+	case summary.Warnings > 0:
+		summary.Outcome = notifier.PolicyWarning
+	default:
+		summary.Outcome = notifier.PolicyPassed
+	}
+	if worst < len(policySeverities) {
+		summary.Severity = policySeverities[worst]
+	}
+	return summary
+}
+
+// FilterPolicyDestinations removes the destinations restricted to policy outcomes the notification does not have.
+// Notifications without policy results are not sent to these destinations.
+func FilterPolicyDestinations(destinations []DestinationNotifier, notification *notifier.Notification) []DestinationNotifier {
+	var filtered []DestinationNotifier
+	for _, destination := range destinations {
+		if len(destination.PolicyOutcomes) > 0 &&
+			(notification.Policy == nil || !slices.Contains(destination.PolicyOutcomes, notification.Policy.Outcome)) {
+			continue
+		}
+		filtered = append(filtered, destination)
+	}
+	return filtered
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+)
+
+var _ = Describe("Policy results", func() {
+	pipelineRunWithResults := func(results map[string]string) *tektonv1.PipelineRun {
+		pipelineRun := &tektonv1.PipelineRun{}
+		for name, value := range results {
+			pipelineRun.Status.Results = append(pipelineRun.Status.Results,
+				tektonv1.PipelineRunResult{Name: name, Value: *tektonv1.NewStructuredValues(value)})
+		}
+		return pipelineRun
+	}
+
+	It("should summarize Enterprise Contract reports", func() {
+		pipelineRun := pipelineRunWithResults(map[string]string{
+			"EC_REPORT": `{"success": false, "components": [{"name": "app",
+				"violations": [{"msg": "unsigned", "metadata": {"code": "attestation.signed"}},
+					{"msg": "cve", "metadata": {"code": "cve.found", "severity": "critical"}}],
+				"warnings": [{"msg": "old", "metadata": {"code": "base_image.recent"}}]}]}`,
+			"IMAGE_DIGEST": "sha256:abc",
+		})
+		notification, err := GetNotificationFromPipelineRun(pipelineRun)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(notification.Policy).To(Equal(&notifier.PolicySummary{
+			Outcome:    notifier.PolicyFailed,
+			Violations: 2,
+			Warnings:   1,
+			Rules:      []string{"attestation.signed", "cve.found", "base_image.recent"},
+			Severity:   "critical",
+			Results:    []string{"EC_REPORT"},
+		}))
+		Expect(notification.Results).To(Equal([]notifier.Result{{Name: "IMAGE_DIGEST", Value: "sha256:abc"}}))
+	})
+
+	It("should summarize conftest and TEST_OUTPUT results", func() {
+		policy := GetPolicySummary(pipelineRunWithResults(map[string]string{
+			"CONFTEST":    `[{"filename": "deploy.yaml", "successes": 3, "warnings": [{"msg": "latest tag", "metadata": {"code": "tags.pinned"}}]}]`,
+			"TEST_OUTPUT": `{"result": "WARNING", "successes": 4, "failures": 0, "warnings": 1}`,
+		}))
+		Expect(policy.Outcome).To(Equal(notifier.PolicyWarning))
+		Expect(policy.Warnings).To(Equal(2))
+		Expect(policy.Rules).To(Equal([]string{"tags.pinned"}))
+		Expect(policy.Severity).To(Equal("low"))
+		Expect(policy.Results).To(ConsistOf("CONFTEST", "TEST_OUTPUT"))
+
+		policy = GetPolicySummary(pipelineRunWithResults(map[string]string{
+			"TEST_OUTPUT": `{"result": "SUCCESS", "successes": 4, "failures": 0, "warnings": 0}`,
+		}))
+		Expect(policy.Outcome).To(Equal(notifier.PolicyPassed))
+		Expect(policy.Severity).To(BeEmpty())
+	})
+
+	It("should ignore other results", func() {
+		Expect(GetPolicySummary(pipelineRunWithResults(map[string]string{
+			"IMAGE_URL": "quay.io/app", "METADATA": `{"result": "ok"}`, "LIST": `[1, 2]`,
+		}))).To(BeNil())
+	})
+
+	It("should filter destinations by policy outcome", func() {
+		destinations := []DestinationNotifier{
+			{Name: "all"},
+			{Name: "failed", PolicyOutcomes: []string{notifier.PolicyFailed}},
+		}
+		passed := &notifier.Notification{Policy: &notifier.PolicySummary{Outcome: notifier.PolicyPassed}}
+		failed := &notifier.Notification{Policy: &notifier.PolicySummary{Outcome: notifier.PolicyFailed}}
+		Expect(FilterPolicyDestinations(destinations, passed)).To(HaveLen(1))
+		Expect(FilterPolicyDestinations(destinations, failed)).To(HaveLen(2))
+		Expect(FilterPolicyDestinations(destinations, &notifier.Notification{})).To(HaveLen(1))
+	})
+})
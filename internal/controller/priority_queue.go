@@ -0,0 +1,210 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"sync"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/ratelimiter"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// PriorityQueue is a work queue with two tiers: items for which the priority function returns true
+// are handed out before the other items, in the order they were added. Like the client-go work queue,
+// an item is queued at most once and is not processed concurrently; an item added again while it is
+// processed is queued when it is done.
+type PriorityQueue struct {
+	priority func(item any) bool
+	cond     *sync.Cond
+	high     []any
+	normal   []any
+	// dirty holds the items to process and whether they have priority
+	dirty map[any]bool
+	// processing holds the items handed out and not done yet
+	processing   map[any]struct{}
+	shuttingDown bool
+	drain        bool
+}
+
+var _ workqueue.Interface = &PriorityQueue{}
+
+// NewPriorityQueue returns an empty queue prioritizing the items for which priority returns true
+func NewPriorityQueue(priority func(item any) bool) *PriorityQueue {
+	return &PriorityQueue{
+		priority:   priority,
+		cond:       sync.NewCond(&sync.Mutex{}),
+		dirty:      map[any]bool{},
+		processing: map[any]struct{}{},
+	}
+}
+
+// NewPriorityRateLimitingQueue returns the rate limiting queue of a controller on top of a PriorityQueue,
+// to be set as the NewQueue option of the controller
+func NewPriorityRateLimitingQueue(priority func(item any) bool) func(string, ratelimiter.RateLimiter) workqueue.RateLimitingInterface {
+	return func(controllerName string, rateLimiter ratelimiter.RateLimiter) workqueue.RateLimitingInterface {
+		return workqueue.NewRateLimitingQueueWithConfig(rateLimiter, workqueue.RateLimitingQueueConfig{
+			Name: controllerName,
+			DelayingQueue: workqueue.NewDelayingQueueWithConfig(workqueue.DelayingQueueConfig{
+				Name:  controllerName,
+				Queue: NewPriorityQueue(priority),
+			}),
+		})
+	}
+}
+
+// Add queues the item, or moves it ahead if it is queued without priority and now has priority
+func (q *PriorityQueue) Add(item any) {
+	high := q.priority(item)
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	if q.shuttingDown {
+		return
+	}
+	if queuedHigh, ok := q.dirty[item]; ok {
+		if !high || queuedHigh {
+			return
+		}
+		q.dirty[item] = true
+		if _, ok := q.processing[item]; !ok {
+			q.normal = removeItem(q.normal, item)
+			q.push(item, true)
+		}
+		return
+	}
+	q.dirty[item] = high
+	if _, ok := q.processing[item]; ok {
+		return
+	}
+	q.push(item, high)
+	q.cond.Signal()
+}
+
+// Len returns the number of queued items
+func (q *PriorityQueue) Len() int {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	return len(q.high) + len(q.normal)
+}
+
+// Get blocks until an item can be processed and returns it, items with priority first.
+// shutdown is true once the queue is shut down and empty.
+func (q *PriorityQueue) Get() (any, bool) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	for len(q.high)+len(q.normal) == 0 && !q.shuttingDown {
+		q.cond.Wait()
+	}
+	var item any
+	switch {
+	case len(q.high) > 0:
+		item, q.high = q.high[0], q.high[1:]
+	case len(q.normal) > 0:
+		item, q.normal = q.normal[0], q.normal[1:]
+	default:
+		return nil, true
+	}
+	q.updateDepth()
+	q.processing[item] = struct{}{}
+	delete(q.dirty, item)
+	return item, false
+}
+
+// Done marks the item as processed, it is queued again if it was added while it was processed
+func (q *PriorityQueue) Done(item any) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	delete(q.processing, item)
+	if high, ok := q.dirty[item]; ok {
+		q.push(item, high)
+		q.cond.Signal()
+	} else if len(q.processing) == 0 {
+		q.cond.Broadcast()
+	}
+}
+
+// ShutDown stops accepting items and releases the callers waiting in Get
+func (q *PriorityQueue) ShutDown() {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	q.drain = false
+	q.shuttingDown = true
+	q.cond.Broadcast()
+}
+
+// ShutDownWithDrain stops accepting items and waits until the items being processed are done
+func (q *PriorityQueue) ShutDownWithDrain() {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	q.drain = true
+	q.shuttingDown = true
+	q.cond.Broadcast()
+	for len(q.processing) > 0 && q.drain {
+		q.cond.Wait()
+	}
+}
+
+// ShuttingDown returns a boolean indicating whether the queue is shut down
+func (q *PriorityQueue) ShuttingDown() bool {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	return q.shuttingDown
+}
+
+func (q *PriorityQueue) push(item any, high bool) {
+	if high {
+		q.high = append(q.high, item)
+	} else {
+		q.normal = append(q.normal, item)
+	}
+	q.updateDepth()
+}
+
+func (q *PriorityQueue) updateDepth() {
+	queueDepth.WithLabelValues("high").Set(float64(len(q.high)))
+	queueDepth.WithLabelValues("normal").Set(float64(len(q.normal)))
+}
+
+func removeItem(items []any, item any) []any {
+	for i := range items {
+		if items[i] == item {
+			return append(items[:i], items[i+1:]...)
+		}
+	}
+	return items
+}
+
+// FailedPipelineRunPriority returns a priority function giving priority to the requests of
+// pipelineruns that failed and were not notified about yet, read from the client cache
+func FailedPipelineRunPriority(c client.Reader) func(item any) bool {
+	return func(item any) bool {
+		req, ok := item.(reconcile.Request)
+		if !ok {
+			return false
+		}
+		pipelineRun := &tektonv1.PipelineRun{}
+		err := c.Get(context.Background(), req.NamespacedName, pipelineRun)
+		if err != nil {
+			return false
+		}
+		return IsPipelineRunEnded(pipelineRun) && !IsPipelineRunEndedSuccessfully(pipelineRun) &&
+			!IsAnnotationExistInPipelineRun(pipelineRun, NotificationPipelineRunAnnotation, NotificationPipelineRunAnnotationValue)
+	}
+}
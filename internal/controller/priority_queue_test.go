@@ -0,0 +1,98 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("Priority queue", func() {
+	var (
+		failed map[string]bool
+		queue  *PriorityQueue
+	)
+
+	BeforeEach(func() {
+		failed = map[string]bool{}
+		queue = NewPriorityQueue(func(item any) bool {
+			return failed[item.(string)]
+		})
+	})
+
+	get := func() any {
+		item, shutdown := queue.Get()
+		Expect(shutdown).To(BeFalse())
+		queue.Done(item)
+		return item
+	}
+
+	It("should hand out items with priority first", func() {
+		failed["failed-1"], failed["failed-2"] = true, true
+		for _, item := range []string{"succeeded-1", "failed-1", "succeeded-2", "failed-2"} {
+			queue.Add(item)
+		}
+		queue.Add("succeeded-1")
+		Expect(queue.Len()).To(Equal(4))
+		Expect([]any{get(), get(), get(), get()}).To(Equal([]any{"failed-1", "failed-2", "succeeded-1", "succeeded-2"}))
+	})
+
+	It("should move queued items ahead when they get priority", func() {
+		queue.Add("first")
+		queue.Add("second")
+		failed["second"] = true
+		queue.Add("second")
+		Expect(queue.Len()).To(Equal(2))
+		Expect(get()).To(Equal("second"))
+	})
+
+	It("should queue items added while they are processed when they are done", func() {
+		queue.Add("item")
+		item, _ := queue.Get()
+		queue.Add("item")
+		Expect(queue.Len()).To(BeZero())
+		queue.Done(item)
+		Expect(queue.Len()).To(Equal(1))
+		queue.ShutDown()
+		Expect(get()).To(Equal("item"))
+		_, shutdown := queue.Get()
+		Expect(shutdown).To(BeTrue())
+	})
+
+	It("should give priority to failed pipelineruns that were not notified about", func() {
+		priority := FailedPipelineRunPriority(k8sClient)
+		request := func(name string) reconcile.Request {
+			return reconcile.Request{NamespacedName: client.ObjectKey{Namespace: "default", Name: name}}
+		}
+		createPipelineRun("priority-failed", corev1.ConditionFalse)
+		createPipelineRun("priority-succeeded", corev1.ConditionTrue)
+		notified := createPipelineRun("priority-notified", corev1.ConditionFalse)
+		Expect(AddAnnotationToPipelineRun(context.Background(), notified, &NotificationServiceReconciler{Client: k8sClient},
+			NotificationPipelineRunAnnotation, NotificationPipelineRunAnnotationValue)).To(Succeed())
+
+		Expect(priority(request("priority-failed"))).To(BeTrue())
+		Expect(priority(request("priority-succeeded"))).To(BeFalse())
+		Expect(priority(request("priority-notified"))).To(BeFalse())
+		Expect(priority(request("missing"))).To(BeFalse())
+	})
+})
@@ -0,0 +1,156 @@
+package controller
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+)
+
+// ChainsPayloadAnnotationPrefix prefixes the annotation holding the base64 encoded attestation of the
+// pipelinerun, followed by its UID, when Tekton Chains stores attestations in the tekton backend
+const ChainsPayloadAnnotationPrefix string = "chains.tekton.dev/payload-pipelinerun-"
+
+// DefaultProvenanceBuilderID is the builder ID of Tekton Chains attestations, unless configured otherwise
+const DefaultProvenanceBuilderID string = "https://tekton.dev/chains/v2"
+
+// inTotoStatement is an in-toto attestation of SLSA provenance v0.2 or v1
+type inTotoStatement struct {
+	Subject []struct {
+		Name   string            `json:"name"`
+		Digest map[string]string `json:"digest"`
+	} `json:"subject"`
+	Predicate struct {
+		// SLSA provenance v0.2
+		Builder struct {
+			ID string `json:"id"`
+		} `json:"builder"`
+		Materials []inTotoArtifact `json:"materials"`
+		// SLSA provenance v1
+		RunDetails struct {
+			Builder struct {
+				ID string `json:"id"`
+			} `json:"builder"`
+		} `json:"runDetails"`
+		BuildDefinition struct {
+			ResolvedDependencies []inTotoArtifact `json:"resolvedDependencies"`
+		} `json:"buildDefinition"`
+	} `json:"predicate"`
+}
+
+type inTotoArtifact struct {
+	URI    string            `json:"uri"`
+	Digest map[string]string `json:"digest"`
+}
+
+// GetProvenanceSummary returns the provenance of the pipelineRun, read from its Tekton Chains attestation
+// if it is stored in the pipelinerun annotations, and otherwise from its type hinted results, built by builderID:
+// IMAGE_URL and IMAGE_DIGEST results, *ARTIFACT_OUTPUTS and *ARTIFACT_INPUTS object results and
+// CHAINS-GIT_URL and CHAINS-GIT_COMMIT results.
+// It returns nil if the pipelineRun built nothing.
+// Return error if the attestation is malformed
+func GetProvenanceSummary(pipelineRun *tektonv1.PipelineRun, builderID string) (*notifier.Provenance, error) {
+	if payload, ok := pipelineRun.Annotations[ChainsPayloadAnnotationPrefix+string(pipelineRun.UID)]; ok {
+		provenance, err := parseAttestation(payload)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse attestation of pipelinerun %s: %w", pipelineRun.Name, err)
+		}
+		return provenance, nil
+	}
+
+	provenance := &notifier.Provenance{BuilderID: builderID}
+	images := map[string]*notifier.Artifact{}
+	var git notifier.Artifact
+	for _, result := range pipelineRun.Status.Results {
+		switch {
+		case strings.HasSuffix(result.Name, "IMAGE_URL") || strings.HasSuffix(result.Name, "IMAGE_DIGEST"):
+			prefix := strings.TrimSuffix(strings.TrimSuffix(result.Name, "IMAGE_URL"), "IMAGE_DIGEST")
+			if images[prefix] == nil {
+				images[prefix] = &notifier.Artifact{}
+			}
+			if strings.HasSuffix(result.Name, "IMAGE_URL") {
+				images[prefix].URI = result.Value.StringVal
+			} else {
+				images[prefix].Digest = result.Value.StringVal
+			}
+		case strings.HasSuffix(result.Name, "ARTIFACT_OUTPUTS") && result.Value.Type == tektonv1.ParamTypeObject:
+			provenance.Subjects = append(provenance.Subjects, notifier.Artifact{
+				URI: result.Value.ObjectVal["uri"], Digest: result.Value.ObjectVal["digest"],
+			})
+		case strings.HasSuffix(result.Name, "ARTIFACT_INPUTS") && result.Value.Type == tektonv1.ParamTypeObject:
+			provenance.Materials = append(provenance.Materials, notifier.Artifact{
+				URI: result.Value.ObjectVal["uri"], Digest: result.Value.ObjectVal["digest"],
+			})
+		case result.Name == "CHAINS-GIT_URL":
+			git.URI = result.Value.StringVal
+		case result.Name == "CHAINS-GIT_COMMIT":
+			git.Digest = "sha1:" + result.Value.StringVal
+		}
+	}
+	prefixes := make([]string, 0, len(images))
+	for prefix := range images {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	for _, prefix := range prefixes {
+		if images[prefix].URI != "" && images[prefix].Digest != "" {
+			provenance.Subjects = append(provenance.Subjects, *images[prefix])
+		}
+	}
+	if git.URI != "" {
+		provenance.Materials = append(provenance.Materials, git)
+	}
+	if len(provenance.Subjects) == 0 {
+		return nil, nil
+	}
+	return provenance, nil
+}
+
+// parseAttestation returns the provenance of a base64 encoded in-toto statement
+func parseAttestation(payload string) (*notifier.Provenance, error) {
+	decoded, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, err
+	}
+	statement := &inTotoStatement{}
+	err = json.Unmarshal(decoded, statement)
+	if err != nil {
+		return nil, err
+	}
+	provenance := &notifier.Provenance{BuilderID: statement.Predicate.Builder.ID}
+	if provenance.BuilderID == "" {
+		provenance.BuilderID = statement.Predicate.RunDetails.Builder.ID
+	}
+	for _, subject := range statement.Subject {
+		provenance.Subjects = append(provenance.Subjects, notifier.Artifact{URI: subject.Name, Digest: formatDigest(subject.Digest)})
+	}
+	materials := statement.Predicate.Materials
+	if len(materials) == 0 {
+		materials = statement.Predicate.BuildDefinition.ResolvedDependencies
+	}
+	for _, material := range materials {
+		provenance.Materials = append(provenance.Materials, notifier.Artifact{URI: material.URI, Digest: formatDigest(material.Digest)})
+	}
+	return provenance, nil
+}
+
+// formatDigest returns the digest in the form <algorithm>:<hex>, preferring sha256
+func formatDigest(digest map[string]string) string {
+	algorithms := make([]string, 0, len(digest))
+	for algorithm := range digest {
+		algorithms = append(algorithms, algorithm)
+	}
+	sort.Strings(algorithms)
+	if i := slices.Index(algorithms, "sha256"); i > 0 {
+		algorithms[0], algorithms[i] = algorithms[i], algorithms[0]
+	}
+	if len(algorithms) == 0 {
+		return ""
+	}
+	return algorithms[0] + ":" + digest[algorithms[0]]
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/base64"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Provenance", func() {
+	It("should summarize the type hinted results of the pipelinerun", func() {
+		pipelineRun := &tektonv1.PipelineRun{}
+		pipelineRun.Status.Results = []tektonv1.PipelineRunResult{
+			{Name: "IMAGE_URL", Value: *tektonv1.NewStructuredValues("quay.io/org/app:v1")},
+			{Name: "IMAGE_DIGEST", Value: *tektonv1.NewStructuredValues("sha256:abc")},
+			{Name: "BUNDLE_IMAGE_URL", Value: *tektonv1.NewStructuredValues("quay.io/org/bundle:v1")},
+			{Name: "CHAINS-GIT_URL", Value: *tektonv1.NewStructuredValues("https://github.com/org/app")},
+			{Name: "CHAINS-GIT_COMMIT", Value: *tektonv1.NewStructuredValues("0123abcd")},
+			{Name: "SBOM_ARTIFACT_OUTPUTS", Value: *tektonv1.NewObject(map[string]string{
+				"uri": "quay.io/org/app:sbom", "digest": "sha256:def",
+			})},
+		}
+		provenance, err := GetProvenanceSummary(pipelineRun, DefaultProvenanceBuilderID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(provenance).To(Equal(&notifier.Provenance{
+			BuilderID: DefaultProvenanceBuilderID,
+			Subjects: []notifier.Artifact{
+				{URI: "quay.io/org/app:sbom", Digest: "sha256:def"},
+				{URI: "quay.io/org/app:v1", Digest: "sha256:abc"},
+			},
+			Materials: []notifier.Artifact{{URI: "https://github.com/org/app", Digest: "sha1:0123abcd"}},
+		}))
+
+		pipelineRun.Status.Results = pipelineRun.Status.Results[3:5]
+		Expect(GetProvenanceSummary(pipelineRun, DefaultProvenanceBuilderID)).To(BeNil())
+	})
+
+	It("should prefer the Tekton Chains attestation of the pipelinerun", func() {
+		statement := `{"_type": "https://in-toto.io/Statement/v0.1", "predicateType": "https://slsa.dev/provenance/v0.2",
+			"subject": [{"name": "quay.io/org/app", "digest": {"sha256": "abc"}}],
+			"predicate": {"builder": {"id": "https://konflux.dev/builder"},
+				"materials": [{"uri": "git+https://github.com/org/app", "digest": {"sha1": "0123abcd"}}]}}`
+		pipelineRun := &tektonv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{UID: "uid", Annotations: map[string]string{
+			ChainsPayloadAnnotationPrefix + "uid": base64.StdEncoding.EncodeToString([]byte(statement)),
+		}}}
+		provenance, err := GetProvenanceSummary(pipelineRun, DefaultProvenanceBuilderID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(provenance).To(Equal(&notifier.Provenance{
+			BuilderID: "https://konflux.dev/builder",
+			Subjects:  []notifier.Artifact{{URI: "quay.io/org/app", Digest: "sha256:abc"}},
+			Materials: []notifier.Artifact{{URI: "git+https://github.com/org/app", Digest: "sha1:0123abcd"}},
+		}))
+
+		statement = `{"subject": [{"name": "quay.io/org/app", "digest": {"sha512": "fed", "sha256": "abc"}}],
+			"predicate": {"runDetails": {"builder": {"id": "https://tekton.dev/chains/v2"}},
+				"buildDefinition": {"resolvedDependencies": [{"uri": "oci://quay.io/org/task", "digest": {"sha256": "123"}}]}}}`
+		pipelineRun.Annotations[ChainsPayloadAnnotationPrefix+"uid"] = base64.StdEncoding.EncodeToString([]byte(statement))
+		provenance, err = GetProvenanceSummary(pipelineRun, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(provenance.BuilderID).To(Equal("https://tekton.dev/chains/v2"))
+		Expect(provenance.Subjects[0].Digest).To(Equal("sha256:abc"))
+		Expect(provenance.Materials).To(Equal([]notifier.Artifact{{URI: "oci://quay.io/org/task", Digest: "sha256:123"}}))
+
+		pipelineRun.Annotations[ChainsPayloadAnnotationPrefix+"uid"] = "not base64"
+		_, err = GetProvenanceSummary(pipelineRun, "")
+		Expect(err).To(HaveOccurred())
+	})
+})
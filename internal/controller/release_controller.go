@@ -0,0 +1,155 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ReleaseGroupVersionKind is the kind of Konflux Releases
+var ReleaseGroupVersionKind = schema.GroupVersionKind{Group: "appstudio.redhat.com", Version: "v1alpha1", Kind: "Release"}
+
+// ReleasePlanGroupVersionKind is the kind of the Konflux ReleasePlans Releases are created for
+var ReleasePlanGroupVersionKind = schema.GroupVersionKind{Group: "appstudio.redhat.com", Version: "v1alpha1", Kind: "ReleasePlan"}
+
+// Released condition of Konflux Releases and its reasons once the Release completed
+const (
+	releasedCondition       = "Released"
+	releasedReasonSucceeded = "Succeeded"
+	releasedReasonFailed    = "Failed"
+)
+
+// ReleaseReconciler notifies about the end of Konflux Releases with the same lifecycle and destinations as
+// CustomRuns, so release managers are told what was released where rather than which pipelinerun ran.
+// Releases are read as unstructured objects, the Konflux release API is not a dependency of the controller.
+// Their notifications have the KindRelease kind, the name of the Release as PipelineRun, and its
+// ReleasePlan, snapshot, application and target in their Konflux context.
+type ReleaseReconciler struct {
+	// Reconciler reads the Releases and their ReleasePlans, and holds, notifies about and releases
+	// them like pipelineruns through reconcileRun
+	Reconciler *NotificationServiceReconciler
+}
+
+// +kubebuilder:rbac:groups=appstudio.redhat.com,resources=releases,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups=appstudio.redhat.com,resources=releases/finalizers,verbs=update
+// +kubebuilder:rbac:groups=appstudio.redhat.com,resources=releaseplans,verbs=get
+
+// Reconcile adds the finalizer to Releases in progress and, once they completed, sends their notification,
+// marks them as notified and removes the finalizer
+func (r *ReleaseReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	release := &unstructured.Unstructured{}
+	release.SetGroupVersionKind(ReleaseGroupVersionKind)
+	err := r.Reconciler.Get(ctx, req.NamespacedName, release)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		r.Reconciler.Log.Error(err, "Failed to get release", "release", req.NamespacedName)
+		return ctrl.Result{}, err
+	}
+	return r.Reconciler.reconcileRun(ctx, release, ReleaseGroupVersionKind, IsReleaseCompleted(release),
+		func() *notifier.Notification {
+			notification := GetNotificationFromRelease(release, time.Now())
+			if notification.Konflux.Application == "" {
+				application, err := getReleasePlanApplication(ctx, r.Reconciler.Client, release)
+				if err != nil {
+					r.Reconciler.Log.Error(err, "Failed to get the application of release", "release", req.NamespacedName)
+				}
+				notification.Konflux.Application = application
+			}
+			return notification
+		})
+}
+
+// IsReleaseCompleted returns a boolean indicating whether the Release succeeded or failed
+func IsReleaseCompleted(release *unstructured.Unstructured) bool {
+	_, reason := getStatusCondition(release, releasedCondition)
+	return reason == releasedReasonSucceeded || reason == releasedReasonFailed
+}
+
+// GetNotificationFromRelease builds the notification that is sent for the Release. Durations of Releases
+// that did not complete yet are measured up to now.
+func GetNotificationFromRelease(release *unstructured.Unstructured, now time.Time) *notifier.Notification {
+	notification := &notifier.Notification{
+		PipelineRun: release.GetName(),
+		Namespace:   release.GetNamespace(),
+		Kind:        notifier.KindRelease,
+		Status:      notifier.StatusStarted,
+		Event:       notifier.EventStarted,
+		Results:     []notifier.Result{},
+	}
+	status, reason := getStatusCondition(release, releasedCondition)
+	switch {
+	case status == string(metav1.ConditionTrue) && reason == releasedReasonSucceeded:
+		notification.Status, notification.Event = notifier.StatusSucceeded, notifier.EventSucceeded
+	case reason == releasedReasonFailed:
+		notification.Status, notification.Event = notifier.StatusFailed, notifier.EventFailed
+	}
+
+	releasePlan, _, _ := unstructured.NestedString(release.Object, "spec", "releasePlan")
+	snapshot, _, _ := unstructured.NestedString(release.Object, "spec", "snapshot")
+	target, _, _ := unstructured.NestedString(release.Object, "status", "target")
+	notification.Konflux = &notifier.KonfluxContext{
+		Application: release.GetLabels()[KonfluxApplicationLabel],
+		Snapshot:    snapshot,
+		ReleasePlan: releasePlan,
+		Environment: target,
+	}
+	if managed, _, _ := unstructured.NestedString(release.Object, "status", "managedProcessing", "pipelineRun"); managed != "" {
+		notification.Results = append(notification.Results, notifier.Result{Name: "MANAGED_PIPELINERUN", Value: managed})
+	}
+
+	notification.StartTime, notification.CompletionTime, notification.DurationSeconds =
+		getTiming(getStatusTime(release, "startTime"), getStatusTime(release, "completionTime"), now)
+	return notification
+}
+
+// getReleasePlanApplication returns the application of the ReleasePlan of the Release
+// Return error if failed to get the ReleasePlan
+func getReleasePlanApplication(ctx context.Context, c client.Reader, release *unstructured.Unstructured) (string, error) {
+	name, _, _ := unstructured.NestedString(release.Object, "spec", "releasePlan")
+	if name == "" {
+		return "", nil
+	}
+	releasePlan := &unstructured.Unstructured{}
+	releasePlan.SetGroupVersionKind(ReleasePlanGroupVersionKind)
+	err := c.Get(ctx, client.ObjectKey{Namespace: release.GetNamespace(), Name: name}, releasePlan)
+	if err != nil {
+		return "", fmt.Errorf("Failed to get ReleasePlan %s: %w", name, err)
+	}
+	application, _, _ := unstructured.NestedString(releasePlan.Object, "spec", "application")
+	return application, nil
+}
+
+// SetupWithManager sets up the controller of Konflux Releases with the Manager
+func (r *ReleaseReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	release := &unstructured.Unstructured{}
+	release.SetGroupVersionKind(ReleaseGroupVersionKind)
+	return ctrl.NewControllerManagedBy(mgr).
+		For(release).
+		Complete(r)
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Release controller", func() {
+	setStatus := func(release *unstructured.Unstructured, status map[string]any) {
+		Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(release), release)).To(Succeed())
+		release.Object["status"] = status
+		Expect(k8sClient.Status().Update(context.Background(), release)).To(Succeed())
+	}
+
+	reconcileRelease := func(r *ReleaseReconciler, release *unstructured.Unstructured) *unstructured.Unstructured {
+		_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(release)})
+		Expect(err).NotTo(HaveOccurred())
+		reconciled := &unstructured.Unstructured{}
+		reconciled.SetGroupVersionKind(ReleaseGroupVersionKind)
+		Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(release), reconciled)).To(Succeed())
+		return reconciled
+	}
+
+	It("should notify about completed releases with their plan and target", func() {
+		releasePlan := &unstructured.Unstructured{}
+		releasePlan.SetGroupVersionKind(ReleasePlanGroupVersionKind)
+		releasePlan.SetName("app-to-prod")
+		releasePlan.SetNamespace("default")
+		Expect(unstructured.SetNestedField(releasePlan.Object, "app", "spec", "application")).To(Succeed())
+		Expect(k8sClient.Create(context.Background(), releasePlan)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), releasePlan)
+
+		release := &unstructured.Unstructured{}
+		release.SetGroupVersionKind(ReleaseGroupVersionKind)
+		release.SetName("app-release-1")
+		release.SetNamespace("default")
+		Expect(unstructured.SetNestedField(release.Object, "app-to-prod", "spec", "releasePlan")).To(Succeed())
+		Expect(unstructured.SetNestedField(release.Object, "app-snapshot-1", "spec", "snapshot")).To(Succeed())
+		Expect(k8sClient.Create(context.Background(), release)).To(Succeed())
+		DeferCleanup(func() {
+			release := release.DeepCopy()
+			if k8sClient.Get(context.Background(), client.ObjectKeyFromObject(release), release) == nil {
+				release.SetFinalizers(nil)
+				_ = k8sClient.Update(context.Background(), release)
+				_ = k8sClient.Delete(context.Background(), release)
+			}
+		})
+
+		fake := &fakeNotifier{}
+		r := &ReleaseReconciler{Reconciler: &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Notifier: fake}}
+		setStatus(release, map[string]any{
+			"startTime":  "2024-05-01T10:00:00Z",
+			"conditions": []any{map[string]any{"type": "Released", "status": "False", "reason": "Progressing"}},
+		})
+		reconciled := reconcileRelease(r, release)
+		Expect(reconciled.GetFinalizers()).To(ContainElement(NotificationPipelineRunFinalizer))
+		Expect(fake.notifications).To(BeEmpty())
+
+		setStatus(release, map[string]any{
+			"startTime":         "2024-05-01T10:00:00Z",
+			"completionTime":    "2024-05-01T10:05:00Z",
+			"target":            "managed-prod",
+			"managedProcessing": map[string]any{"pipelineRun": "managed-prod/release-run"},
+			"conditions":        []any{map[string]any{"type": "Released", "status": "False", "reason": "Failed"}},
+		})
+		reconciled = reconcileRelease(r, release)
+		Expect(reconciled.GetFinalizers()).NotTo(ContainElement(NotificationPipelineRunFinalizer))
+		Expect(fake.notifications).To(HaveLen(1))
+		notification := fake.notifications[0]
+		Expect(notification.Kind).To(Equal(notifier.KindRelease))
+		Expect(notification.Status).To(Equal(notifier.StatusFailed))
+		Expect(notification.DurationSeconds).To(Equal(300.0))
+		Expect(notification.Konflux).To(Equal(&notifier.KonfluxContext{
+			Application: "app", Snapshot: "app-snapshot-1", ReleasePlan: "app-to-prod", Environment: "managed-prod",
+		}))
+		Expect(notification.Results).To(Equal([]notifier.Result{{Name: "MANAGED_PIPELINERUN", Value: "managed-prod/release-run"}}))
+	})
+
+	It("should tell successful releases", func() {
+		release := &unstructured.Unstructured{Object: map[string]any{
+			"status": map[string]any{"conditions": []any{map[string]any{"type": "Released", "status": "True", "reason": "Succeeded"}}},
+		}}
+		Expect(IsReleaseCompleted(release)).To(BeTrue())
+		Expect(GetNotificationFromRelease(release, time.Now()).Status).To(Equal(notifier.StatusSucceeded))
+	})
+
+	It("should describe integration test and release pipelineruns", func() {
+		pipelineRun := &tektonv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{
+			KonfluxApplicationLabel: "app",
+			KonfluxComponentLabel:   "backend",
+			KonfluxSnapshotLabel:    "app-snapshot-1",
+			KonfluxScenarioLabel:    "e2e",
+		}}}
+		Expect(GetKonfluxContext(pipelineRun)).To(Equal(&notifier.KonfluxContext{
+			Application: "app", Component: "backend", Snapshot: "app-snapshot-1", Scenario: "e2e",
+		}))
+
+		pipelineRun.Labels = map[string]string{KonfluxReleaseNameLabel: "app-release-1", KonfluxReleaseNamespaceLabel: "tenant"}
+		Expect(GetKonfluxContext(pipelineRun).Release).To(Equal("tenant/app-release-1"))
+
+		pipelineRun.Labels = map[string]string{KonfluxApplicationLabel: "app"}
+		Expect(GetKonfluxContext(pipelineRun)).To(BeNil())
+	})
+})
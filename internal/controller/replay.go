@@ -0,0 +1,147 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ReplayOptions restricts the notifications replayed by ReplayPipelineRuns
+type ReplayOptions struct {
+	// Destinations are the names of the destinations notified, all of them if empty
+	Destinations []string
+	// DryRun logs the notifications that would be sent without sending them
+	DryRun bool
+}
+
+// ReplaySummary counts the outcomes of a replay
+type ReplaySummary struct {
+	// PipelineRuns is the number of ended pipelineruns replayed
+	PipelineRuns int
+	// Delivered and Failed are the numbers of deliveries that succeeded and failed, or would be attempted for a dry run
+	Delivered int
+	Failed    int
+	// Skipped is the number of pipelineruns that could not be replayed, or whose notification was dropped by a transform
+	Skipped int
+}
+
+// ReplayPipelineRuns sends the notifications about the end of the pipelineRuns, e.g. read from Tekton Results,
+// to the destinations currently configured for their namespace, as the reconciler would: the notifications are
+// built, enriched, filtered and transformed the same way. Paused destinations are skipped, and so are the
+// destinations a pipelinerun that still exists was already delivered to. Deliveries are recorded like resends:
+// as delivery events and records of the pipelinerun if it still exists, and in the audit log, without
+// changing the markers of the pipelinerun.
+func ReplayPipelineRuns(ctx context.Context, r *NotificationServiceReconciler, pipelineRuns []tektonv1.PipelineRun, opts ReplayOptions) ReplaySummary {
+	summary := ReplaySummary{}
+	for i := range pipelineRuns {
+		pipelineRun := &pipelineRuns[i]
+		if !IsPipelineRunEnded(pipelineRun) {
+			continue
+		}
+		summary.PipelineRuns++
+		logger := r.Log.WithValues("namespace", pipelineRun.Namespace, "pipelineRun", pipelineRun.Name)
+		results, err := r.replay(ctx, pipelineRun, opts)
+		if err != nil {
+			logger.Error(err, "Failed to replay notification")
+			summary.Skipped++
+			continue
+		}
+		if results == nil {
+			summary.Skipped++
+			continue
+		}
+		for _, result := range results {
+			if result.Succeeded {
+				summary.Delivered++
+				logger.Info("Replayed notification", "destination", result.Destination, "dryRun", opts.DryRun)
+			} else {
+				summary.Failed++
+				logger.Info("Failed to replay notification", "destination", result.Destination, "error", result.Error)
+			}
+		}
+	}
+	return summary
+}
+
+// replay sends the notification about the end of the pipelineRun and returns the results of the deliveries,
+// or nil if a transform dropped the notification
+func (r *NotificationServiceReconciler) replay(ctx context.Context, pipelineRun *tektonv1.PipelineRun, opts ReplayOptions) ([]DeliveryResult, error) {
+	// Events and delivery records are only attached to the pipelinerun if it still exists
+	target := pipelineRun.DeepCopy()
+	target.UID = ""
+	var delivered map[string]time.Time
+	live := &tektonv1.PipelineRun{}
+	err := r.Client.Get(ctx, client.ObjectKeyFromObject(pipelineRun), live)
+	switch {
+	case err == nil && live.UID == pipelineRun.UID:
+		err = LoadPipelineRunState(ctx, r.Client, live)
+		if err != nil {
+			return nil, err
+		}
+		target = live
+		delivered, err = GetDeliveredDestinations(live)
+		if err != nil {
+			r.Log.Error(err, "Ignoring malformed delivered destinations")
+		}
+	case err != nil && !k8serrors.IsNotFound(err):
+		return nil, fmt.Errorf("Failed to get pipelinerun %s/%s: %w", pipelineRun.Namespace, pipelineRun.Name, err)
+	}
+
+	team, destinations, err := r.getDestinations(ctx, pipelineRun)
+	if err != nil {
+		return nil, err
+	}
+	notification, err := r.buildNotification(ctx, pipelineRun)
+	if err != nil {
+		return nil, err
+	}
+	notification.Team = team
+	destinations = FilterEventDestinations(destinations, notification)
+	destinations = FilterEscalationDestinations(destinations, notification)
+	destinations = FilterPolicyDestinations(destinations, notification)
+	destinations = FilterResultDestinations(destinations, notification)
+	destinations = FilterDeliveredDestinations(destinations, delivered)
+	destinations, _ = SplitPausedDestinations(destinations)
+	if len(opts.Destinations) > 0 {
+		destinations = slices.DeleteFunc(destinations, func(destination DestinationNotifier) bool {
+			return !slices.Contains(opts.Destinations, destination.Name)
+		})
+	}
+	notification, err = r.transform(ctx, notification)
+	if err != nil {
+		return nil, err
+	}
+	if notification == nil {
+		return nil, nil
+	}
+	results := []DeliveryResult{}
+	for _, destination := range destinations {
+		if opts.DryRun {
+			results = append(results, DeliveryResult{Destination: destination.Name, Succeeded: true})
+			continue
+		}
+		results = append(results, sendAdminNotification(ctx, r, target, destination, notification, true))
+	}
+	return results, nil
+}
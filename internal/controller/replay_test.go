@@ -0,0 +1,150 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	tektonv1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("Replay", func() {
+	ended := func(status corev1.ConditionStatus) duckv1.Conditions {
+		return duckv1.Conditions{{Type: apis.ConditionSucceeded, Status: status}}
+	}
+
+	It("should list the pipelineruns archived in Tekton Results page by page", func() {
+		archived, err := json.Marshal(&tektonv1.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "archived", Namespace: "team", UID: "archived-uid"},
+			Status:     tektonv1.PipelineRunStatus{Status: duckv1.Status{Conditions: ended(corev1.ConditionTrue)}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		legacy, err := json.Marshal(&tektonv1beta1.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "legacy", Namespace: "team"},
+			Spec:       tektonv1beta1.PipelineRunSpec{PipelineRef: &tektonv1beta1.PipelineRef{Name: "build"}},
+			Status:     tektonv1beta1.PipelineRunStatus{Status: duckv1.Status{Conditions: ended(corev1.ConditionFalse)}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		pages := []map[string]any{
+			{"records": []map[string]any{
+				{"name": "team/results/1/records/1", "data": map[string]any{"type": "tekton.dev/v1.PipelineRun", "value": archived}},
+				{"name": "team/results/1/records/2", "data": map[string]any{"type": "tekton.dev/v1.TaskRun", "value": []byte("{}")}},
+			}, "nextPageToken": "second"},
+			{"records": []map[string]any{
+				{"name": "team/results/2/records/1", "data": map[string]any{"type": "tekton.dev/v1beta1.PipelineRun", "value": legacy}},
+			}},
+		}
+		var requests []*http.Request
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			requests = append(requests, req)
+			page := pages[0]
+			if req.URL.Query().Get("page_token") == "second" {
+				page = pages[1]
+			}
+			_ = json.NewEncoder(w).Encode(page)
+		}))
+		DeferCleanup(server.Close)
+		tokenFile := filepath.Join(GinkgoT().TempDir(), "token")
+		Expect(os.WriteFile(tokenFile, []byte("secret\n"), 0o600)).To(Succeed())
+
+		results, err := NewTektonResultsClient(TektonResultsOptions{URL: server.URL, TokenFile: tokenFile})
+		Expect(err).NotTo(HaveOccurred())
+		since := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+		pipelineRuns, err := results.ListPipelineRuns(context.Background(), "team", since, since.Add(24*time.Hour))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pipelineRuns).To(HaveLen(2))
+		Expect(pipelineRuns[0].Name).To(Equal("archived"))
+		Expect(pipelineRuns[1].Name).To(Equal("legacy"))
+		Expect(pipelineRuns[1].Spec.PipelineRef.Name).To(Equal("build"))
+
+		Expect(requests).To(HaveLen(2))
+		Expect(requests[0].URL.Path).To(Equal("/apis/results.tekton.dev/v1alpha2/parents/team/results/-/records"))
+		Expect(requests[0].Header.Get("Authorization")).To(Equal("Bearer secret"))
+		Expect(requests[0].URL.Query().Get("filter")).To(ContainSubstring(`data.status.completionTime >= timestamp("2026-10-01T00:00:00Z")`))
+		Expect(requests[0].URL.Query().Get("filter")).To(ContainSubstring(`data.status.completionTime < timestamp("2026-10-02T00:00:00Z")`))
+	})
+
+	It("should fail to list pipelineruns when Tekton Results responds with an error", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			http.Error(w, "permission denied", http.StatusForbidden)
+		}))
+		DeferCleanup(server.Close)
+		results, err := NewTektonResultsClient(TektonResultsOptions{URL: server.URL})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = results.ListPipelineRuns(context.Background(), "", time.Now().Add(-time.Hour), time.Now())
+		Expect(err).To(MatchError(ContainSubstring("status 403: permission denied")))
+	})
+
+	It("should replay the notifications about archived pipelineruns to the destinations not yet delivered to", func() {
+		ctx := context.Background()
+		live := createPipelineRun("replay-live", corev1.ConditionFalse)
+		delivered := createPipelineRun("replay-delivered", corev1.ConditionTrue)
+		Expect(SetDeliveredDestinations(ctx, delivered, k8sClient, map[string]time.Time{DefaultDestinationName: time.Now()})).To(Succeed())
+		pruned := tektonv1.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "replay-pruned", Namespace: "default", UID: "pruned-uid"},
+			Status:     tektonv1.PipelineRunStatus{Status: duckv1.Status{Conditions: ended(corev1.ConditionTrue)}},
+		}
+		running := tektonv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "replay-running", Namespace: "default"}}
+
+		fake := &fakeNotifier{}
+		r := &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Notifier: fake,
+			RecordDeliveries: true, Log: ctrl.Log.WithName("replay")}
+		summary := ReplayPipelineRuns(ctx, r, []tektonv1.PipelineRun{*getPipelineRun(live), *getPipelineRun(delivered), pruned, running},
+			ReplayOptions{DryRun: true})
+		Expect(summary).To(Equal(ReplaySummary{PipelineRuns: 3, Delivered: 2}))
+		Expect(fake.notifications).To(BeEmpty())
+
+		summary = ReplayPipelineRuns(ctx, r, []tektonv1.PipelineRun{*getPipelineRun(live), *getPipelineRun(delivered), pruned, running},
+			ReplayOptions{})
+		Expect(summary).To(Equal(ReplaySummary{PipelineRuns: 3, Delivered: 2}))
+		Expect(fake.notifications).To(HaveLen(2))
+		Expect(fake.notifications[0].PipelineRun).To(Equal("replay-live"))
+		Expect(fake.notifications[0].Status).To(Equal("Failed"))
+		Expect(fake.notifications[1].PipelineRun).To(Equal("replay-pruned"))
+
+		// Deliveries are recorded for the pipelineruns that still exist, without changing their markers
+		records := listDeliveries("replay-live")
+		Expect(records).To(HaveLen(1))
+		Expect(records[0].OwnerReferences[0].UID).To(Equal(live.UID))
+		Expect(getPipelineRun(live).Annotations).NotTo(HaveKey(NotificationDeliveredAnnotation))
+		records = listDeliveries("replay-pruned")
+		Expect(records).To(HaveLen(1))
+		Expect(records[0].OwnerReferences).To(BeEmpty())
+		Expect(k8sClient.Delete(ctx, &records[0])).To(Succeed())
+
+		summary = ReplayPipelineRuns(ctx, r, []tektonv1.PipelineRun{pruned}, ReplayOptions{Destinations: []string{"default/other"}})
+		Expect(summary).To(Equal(ReplaySummary{PipelineRuns: 1}))
+		Expect(fake.notifications).To(HaveLen(2))
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: "replay-pruned"}, &tektonv1.PipelineRun{})).NotTo(Succeed())
+	})
+})
@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ReportPipelineRunLabel is set on report ConfigMaps to the name of their pipelinerun
+const ReportPipelineRunLabel string = "konflux.ci/report-for"
+
+// DefaultReportLogLines is how many lines of the log of each failed step are included in reports
+const DefaultReportLogLines int64 = 50
+
+// ConfigMapReportStore stores reports in a ConfigMap named <pipelinerun>-report in the pipelinerun namespace
+type ConfigMapReportStore struct {
+	Client client.Client
+}
+
+// StoreReport creates or updates the report ConfigMap and returns its name as configmap/<namespace>/<name>
+// An existing ConfigMap is only updated if it is labeled as the report of the pipelinerun, so ConfigMaps
+// that were not created by the store are never overwritten
+func (s *ConfigMapReportStore) StoreReport(ctx context.Context, namespace string, name string, file string, mediaType string, body []byte) (string, error) {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name + "-report",
+			Labels:    map[string]string{ReportPipelineRunLabel: name},
+		},
+		Data: map[string]string{file: string(body)},
+	}
+	// The report is owned by its pipelinerun so it is deleted with it, reports of pipelineruns
+	// that were already deleted are left to the CompanionCollector
+	pipelineRun := &tektonv1.PipelineRun{}
+	err := s.Client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, pipelineRun)
+	if err == nil {
+		configMap.OwnerReferences = pipelineRunOwnerReferences(pipelineRun)
+	} else if !k8serrors.IsNotFound(err) {
+		return "", fmt.Errorf("Failed to get pipelinerun %s/%s of report: %w", namespace, name, err)
+	}
+	location := "configmap/" + namespace + "/" + configMap.Name
+	err = s.Client.Create(ctx, configMap)
+	if err == nil {
+		return location, nil
+	}
+	if !k8serrors.IsAlreadyExists(err) {
+		return "", fmt.Errorf("Failed to create report ConfigMap %s/%s: %w", namespace, configMap.Name, err)
+	}
+	existing := &corev1.ConfigMap{}
+	err = s.Client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: configMap.Name}, existing)
+	if err != nil {
+		return "", fmt.Errorf("Failed to get report ConfigMap %s/%s: %w", namespace, configMap.Name, err)
+	}
+	if existing.Labels[ReportPipelineRunLabel] != name {
+		return "", fmt.Errorf("Failed to update report ConfigMap %s/%s: it is not labeled %s=%s",
+			namespace, configMap.Name, ReportPipelineRunLabel, name)
+	}
+	patch := client.MergeFrom(existing.DeepCopy())
+	existing.Data = configMap.Data
+	err = s.Client.Patch(ctx, existing, patch)
+	if err != nil {
+		return "", fmt.Errorf("Failed to update report ConfigMap %s/%s: %w", namespace, configMap.Name, err)
+	}
+	return location, nil
+}
+
+// PodLogSource reads the last lines of the logs of the failed steps of the TaskRuns of a notification
+type PodLogSource struct {
+	Client client.Reader
+	Pods   corev1client.PodsGetter
+	// Lines is how many lines are kept of every log, defaults to DefaultReportLogLines
+	Lines int64
+}
+
+// LogExcerpts returns the log excerpts of the steps that terminated with a non zero exit code
+// TaskRuns and pods that no longer exist are skipped
+func (s *PodLogSource) LogExcerpts(ctx context.Context, notification *notifier.Notification) ([]notifier.LogExcerpt, error) {
+	lines := s.Lines
+	if lines <= 0 {
+		lines = DefaultReportLogLines
+	}
+	var excerpts []notifier.LogExcerpt
+	var errs []error
+	for _, task := range notification.Tasks {
+		taskRun := &tektonv1.TaskRun{}
+		err := s.Client.Get(ctx, types.NamespacedName{Namespace: notification.Namespace, Name: task.TaskRun}, taskRun)
+		if err != nil {
+			if !k8serrors.IsNotFound(err) {
+				errs = append(errs, fmt.Errorf("Failed to get taskrun %s: %w", task.TaskRun, err))
+			}
+			continue
+		}
+		if taskRun.Status.PodName == "" {
+			continue
+		}
+		for _, step := range taskRun.Status.Steps {
+			if step.Terminated == nil || step.Terminated.ExitCode == 0 {
+				continue
+			}
+			logs, err := s.Pods.Pods(notification.Namespace).GetLogs(taskRun.Status.PodName, &corev1.PodLogOptions{
+				Container: step.Container,
+				TailLines: &lines,
+			}).DoRaw(ctx)
+			if err != nil {
+				if !k8serrors.IsNotFound(err) {
+					errs = append(errs, fmt.Errorf("Failed to get logs of step %s of taskrun %s: %w", step.Name, task.TaskRun, err))
+				}
+				continue
+			}
+			excerpts = append(excerpts, notifier.LogExcerpt{TaskRun: task.TaskRun, Step: step.Name, Lines: string(logs)})
+		}
+	}
+	return excerpts, errors.Join(errs...)
+}
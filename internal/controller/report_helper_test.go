@@ -0,0 +1,90 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var _ = Describe("Reports", func() {
+	It("should store reports in ConfigMaps", func() {
+		store := &ConfigMapReportStore{Client: k8sClient}
+		location, err := store.StoreReport(context.Background(), "default", "report-build", "report-build.md", "text/markdown", []byte("# first"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(location).To(Equal("configmap/default/report-build-report"))
+
+		_, err = store.StoreReport(context.Background(), "default", "report-build", "report-build.md", "text/markdown", []byte("# second"))
+		Expect(err).NotTo(HaveOccurred())
+		configMap := &corev1.ConfigMap{}
+		Expect(k8sClient.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "report-build-report"}, configMap)).To(Succeed())
+		Expect(configMap.Labels).To(HaveKeyWithValue(ReportPipelineRunLabel, "report-build"))
+		Expect(configMap.Data).To(Equal(map[string]string{"report-build.md": "# second"}))
+	})
+
+	It("should not overwrite ConfigMaps that are not reports", func() {
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "report-user-report", Namespace: "default"},
+			Data:       map[string]string{"settings": "kept"},
+		}
+		Expect(k8sClient.Create(context.Background(), configMap)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), configMap)
+
+		store := &ConfigMapReportStore{Client: k8sClient}
+		_, err := store.StoreReport(context.Background(), "default", "report-user", "report-user.md", "text/markdown", []byte("# report"))
+		Expect(err).To(HaveOccurred())
+		Expect(k8sClient.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "report-user-report"}, configMap)).To(Succeed())
+		Expect(configMap.Data).To(Equal(map[string]string{"settings": "kept"}))
+	})
+
+	It("should read the logs of failed steps", func() {
+		taskRun := &tektonv1.TaskRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "report-logs-build", Namespace: "default"},
+			Spec:       tektonv1.TaskRunSpec{TaskRef: &tektonv1.TaskRef{Name: "build"}},
+		}
+		Expect(k8sClient.Create(context.Background(), taskRun)).To(Succeed())
+		taskRun.Status.PodName = "report-logs-build-pod"
+		taskRun.Status.Steps = []tektonv1.StepState{
+			{Name: "build", Container: "step-build", ContainerState: corev1.ContainerState{
+				Terminated: &corev1.ContainerStateTerminated{ExitCode: 0}}},
+			{Name: "push", Container: "step-push", ContainerState: corev1.ContainerState{
+				Terminated: &corev1.ContainerStateTerminated{ExitCode: 1}}},
+		}
+		Expect(k8sClient.Status().Update(context.Background(), taskRun)).To(Succeed())
+
+		source := &PodLogSource{Client: k8sClient, Pods: fake.NewSimpleClientset().CoreV1()}
+		excerpts, err := source.LogExcerpts(context.Background(), &notifier.Notification{
+			Namespace: "default",
+			Tasks: []notifier.TaskTiming{
+				{Name: "build", TaskRun: "report-logs-build"},
+				{Name: "deleted", TaskRun: "report-logs-deleted"},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		// The fake clientset returns the same logs for every container
+		Expect(excerpts).To(Equal([]notifier.LogExcerpt{{TaskRun: "report-logs-build", Step: "push", Lines: "fake logs"}}))
+	})
+})
@@ -0,0 +1,401 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// ResourceWatchedCondition is the condition of NotificationServices with a watch, which is true once the
+// resources of the watch are watched
+const ResourceWatchedCondition string = "ResourceWatched"
+
+// NotificationConditionsAnnotation holds, as JSON, the destinations notified about the watched resource,
+// with the time they were notified, while the condition of their watch holds
+var NotificationConditionsAnnotation = DefaultMarkerPrefix + "/notified-conditions"
+
+// DefaultWatchableKinds are the kinds of resources, as <kind>.<group>, NotificationServices may watch unless the
+// controller is configured otherwise. Secrets are left out: a watch would have the controller read them for tenants
+// who may not, and send their data to their destinations.
+var DefaultWatchableKinds = []string{
+	"ConfigMap", "Pod", "Service", "PersistentVolumeClaim",
+	"Deployment.apps", "StatefulSet.apps", "DaemonSet.apps", "ReplicaSet.apps",
+	"Job.batch", "CronJob.batch",
+}
+
+// ParseWatchableKinds parses kinds of resources in the form <kind>.<group>, or <kind> for the core group
+// Return error if a kind is empty
+func ParseWatchableKinds(kinds []string) ([]schema.GroupKind, error) {
+	var parsed []schema.GroupKind
+	for _, kind := range kinds {
+		groupKind := schema.ParseGroupKind(strings.TrimSpace(kind))
+		if groupKind.Kind == "" {
+			return nil, fmt.Errorf("Invalid watchable kind %q: must be <kind>.<group> or <kind>", kind)
+		}
+		parsed = append(parsed, groupKind)
+	}
+	return parsed, nil
+}
+
+// ResourceWatcher watches the resources selected by the watches of NotificationServices. Each kind gets its
+// own controller, started the first time a NotificationService watches it, which notifies the destinations
+// of the matching NotificationServices in the namespace of a resource every time their condition becomes true.
+// Resources are read as unstructured objects, and the controller must be granted access to them.
+type ResourceWatcher struct {
+	// Reconciler reads the NotificationServices and sets their ResourceWatched condition, and is
+	// handed to the ResourceReconciler of every watched kind
+	Reconciler *NotificationServiceReconciler
+	// Kinds are the kinds of resources NotificationServices may watch, DefaultWatchableKinds if empty
+	Kinds []schema.GroupKind
+
+	manager ctrl.Manager
+	mu      sync.Mutex
+	watched map[schema.GroupVersionKind]bool
+}
+
+// Reconcile validates the watch of the NotificationService, starts watching its resources and reports it
+// in the ResourceWatched condition
+func (w *ResourceWatcher) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	notificationService := &v1alpha1.NotificationService{}
+	err := w.Reconciler.Get(ctx, req.NamespacedName, notificationService)
+	if err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if notificationService.Spec.Watch == nil {
+		return ctrl.Result{}, nil
+	}
+
+	condition := metav1.Condition{
+		Type:               ResourceWatchedCondition,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Watching",
+		ObservedGeneration: notificationService.Generation,
+	}
+	gvk, err := ValidateResourceWatch(notificationService.Spec.Watch, w.Kinds)
+	if err == nil {
+		_, err = w.manager.GetRESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	}
+	if err != nil {
+		condition.Status, condition.Reason, condition.Message = metav1.ConditionFalse, "InvalidWatch", err.Error()
+		return ctrl.Result{}, w.setCondition(ctx, notificationService, condition)
+	}
+	err = w.watch(gvk)
+	if err != nil {
+		condition.Status, condition.Reason, condition.Message = metav1.ConditionFalse, "WatchFailed", err.Error()
+		return ctrl.Result{}, errors.Join(err, w.setCondition(ctx, notificationService, condition))
+	}
+	condition.Message = "Watching " + gvk.Kind + " resources"
+	return ctrl.Result{}, w.setCondition(ctx, notificationService, condition)
+}
+
+// ValidateResourceWatch returns the kind of the resources of the watch, after checking that it is one of
+// the kinds, DefaultWatchableKinds if empty, and that its condition and result expressions compile
+// Return error describing the first invalid field
+func ValidateResourceWatch(watch *v1alpha1.ResourceWatch, kinds []schema.GroupKind) (schema.GroupVersionKind, error) {
+	gv, err := schema.ParseGroupVersion(watch.APIVersion)
+	if err != nil {
+		return schema.GroupVersionKind{}, fmt.Errorf("Invalid API version %s: %w", watch.APIVersion, err)
+	}
+	if watch.Kind == "" {
+		return schema.GroupVersionKind{}, fmt.Errorf("Watch requires a kind")
+	}
+	if len(kinds) == 0 {
+		kinds, err = ParseWatchableKinds(DefaultWatchableKinds)
+		if err != nil {
+			return schema.GroupVersionKind{}, err
+		}
+	}
+	if !slices.Contains(kinds, gv.WithKind(watch.Kind).GroupKind()) {
+		return schema.GroupVersionKind{}, fmt.Errorf("Kind %s of API version %s may not be watched", watch.Kind, watch.APIVersion)
+	}
+	_, err = compileCELExpression(watch.Condition)
+	if err != nil {
+		return schema.GroupVersionKind{}, err
+	}
+	for _, result := range watch.Results {
+		_, err = compileCELExpression(result.Expression)
+		if err != nil {
+			return schema.GroupVersionKind{}, fmt.Errorf("Invalid result %s: %w", result.Name, err)
+		}
+	}
+	return gv.WithKind(watch.Kind), nil
+}
+
+// watch starts the controller of the resources of the kind, unless it is already started
+func (w *ResourceWatcher) watch(gvk schema.GroupVersionKind) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.watched[gvk] {
+		return nil
+	}
+	reconciler := &ResourceReconciler{Reconciler: w.Reconciler, GroupVersionKind: gvk}
+	name := "resourcewatch-" + strings.ToLower(gvk.Kind)
+	if gvk.Group != "" {
+		name += "." + gvk.Group
+	}
+	c, err := controller.New(name+"-"+gvk.Version, w.manager, controller.Options{Reconciler: reconciler})
+	if err != nil {
+		return fmt.Errorf("Failed to create controller of %s: %w", gvk, err)
+	}
+	resource := &unstructured.Unstructured{}
+	resource.SetGroupVersionKind(gvk)
+	err = c.Watch(source.Kind(w.manager.GetCache(), resource, &handler.TypedEnqueueRequestForObject[*unstructured.Unstructured]{}))
+	if err != nil {
+		return fmt.Errorf("Failed to watch %s: %w", gvk, err)
+	}
+	// Resources are evaluated again when the watches of their namespace change
+	err = c.Watch(source.Kind(w.manager.GetCache(), &v1alpha1.NotificationService{},
+		handler.TypedEnqueueRequestsFromMapFunc(reconciler.mapNotificationService)))
+	if err != nil {
+		return fmt.Errorf("Failed to watch NotificationServices for %s: %w", gvk, err)
+	}
+	w.watched[gvk] = true
+	return nil
+}
+
+// setCondition sets the condition of the NotificationService, if it changed
+func (w *ResourceWatcher) setCondition(ctx context.Context, notificationService *v1alpha1.NotificationService, condition metav1.Condition) error {
+	patch := client.MergeFrom(notificationService.DeepCopy())
+	if !meta.SetStatusCondition(&notificationService.Status.Conditions, condition) {
+		return nil
+	}
+	err := w.Reconciler.Status().Patch(ctx, notificationService, patch)
+	if err != nil {
+		return fmt.Errorf("Failed to set the %s condition of NotificationService %s/%s: %w",
+			ResourceWatchedCondition, notificationService.Namespace, notificationService.Name, err)
+	}
+	return nil
+}
+
+// SetupWithManager sets up the controller of the watches of NotificationServices with the Manager
+func (w *ResourceWatcher) SetupWithManager(mgr ctrl.Manager) error {
+	w.manager = mgr
+	w.watched = map[schema.GroupVersionKind]bool{}
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("resourcewatch").
+		For(&v1alpha1.NotificationService{}).
+		Complete(w)
+}
+
+// ResourceReconciler notifies about the resources of a kind watched by NotificationServices
+type ResourceReconciler struct {
+	// Reconciler lists the resources and the NotificationServices watching them, delivers to their
+	// destinations, and applies the notified conditions with the throttling of the configuration file
+	Reconciler *NotificationServiceReconciler
+	// GroupVersionKind is the kind of the resources
+	GroupVersionKind schema.GroupVersionKind
+}
+
+// Reconcile evaluates the conditions of the watches of the resource in its namespace and notifies the
+// destinations of the NotificationServices whose condition became true. The notified destinations are
+// recorded in NotificationConditionsAnnotation until the condition is false again.
+func (r *ResourceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := r.Reconciler.Log.WithValues(r.GroupVersionKind.Kind, req.NamespacedName)
+	resource := &unstructured.Unstructured{}
+	resource.SetGroupVersionKind(r.GroupVersionKind)
+	err := r.Reconciler.Get(ctx, req.NamespacedName, resource)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		logger.Error(err, "Failed to get resource")
+		return ctrl.Result{}, err
+	}
+	notificationServices, err := r.getWatchingNotificationServices(ctx, resource.GetNamespace())
+	if err != nil {
+		logger.Error(err, "Failed to get watches of resource")
+		return ctrl.Result{}, err
+	}
+
+	notified := map[string]time.Time{}
+	if encoded := resource.GetAnnotations()[NotificationConditionsAnnotation]; encoded != "" {
+		err = json.Unmarshal([]byte(encoded), &notified)
+		if err != nil {
+			logger.Error(err, "Ignoring malformed notified conditions")
+			notified = map[string]time.Time{}
+		}
+	}
+	updated := maps.Clone(notified)
+	var errs []error
+	for i := range notificationServices {
+		notificationService := &notificationServices[i]
+		prefix := notificationService.Namespace + "/" + notificationService.Name + "/"
+		matched, err := EvaluateCELCondition(notificationService.Spec.Watch.Condition, resource.Object)
+		if err != nil {
+			logger.Error(err, "Failed to evaluate condition", "notificationService", notificationService.Name)
+			continue
+		}
+		if !matched {
+			maps.DeleteFunc(updated, func(destination string, _ time.Time) bool { return strings.HasPrefix(destination, prefix) })
+			continue
+		}
+		destinations := GetNotificationServiceDestinations(ctx, r.Reconciler.Client, logger, notificationService)
+		destinations = FilterDeliveredDestinations(destinations, updated)
+		destinations, _ = SplitPausedDestinations(destinations)
+		if len(destinations) == 0 {
+			continue
+		}
+		notification, err := GetNotificationFromResource(resource, notificationService.Spec.Watch)
+		if err != nil {
+			logger.Error(err, "Failed to evaluate results", "notificationService", notificationService.Name)
+		}
+		destinations = FilterEventDestinations(destinations, notification)
+		destinations = FilterResultDestinations(destinations, notification)
+		succeeded, err := r.Reconciler.deliverRun(ctx, resource, string(resource.GetUID())+"/"+resource.GetResourceVersion(),
+			destinations, notification)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		now := time.Now().UTC().Truncate(time.Second)
+		for _, destination := range succeeded {
+			updated[destination] = now
+		}
+	}
+
+	if !maps.Equal(notified, updated) {
+		err = r.applyNotifiedConditions(ctx, resource, updated)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Error occurred while patching annotation %s of resource: %w", NotificationConditionsAnnotation, err))
+		}
+	}
+	err = errors.Join(errs...)
+	if errors.Is(err, ErrDeliveryThrottled) {
+		retryAfter := r.Reconciler.ConfigFile.Get().ThrottleRetryAfter()
+		logger.Info("Deliveries are throttled", "retryAfter", retryAfter)
+		return ctrl.Result{RequeueAfter: retryAfter}, nil
+	}
+	if backoff := (*BackoffError)(nil); errors.As(err, &backoff) {
+		logger.Info("Destinations are backing off", "until", backoff.Until)
+		return ctrl.Result{RequeueAfter: time.Until(backoff.Until)}, nil
+	}
+	if err != nil {
+		logger.Error(err, "Failed to send notification for resource")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// GetNotificationFromResource builds the notification that is sent for the resource when the condition of
+// the watch becomes true. It has the kind of the resource, its name as PipelineRun, the status of the watch
+// and the values of its result expressions as results.
+// Return error if a result expression fails, the other results are still set
+func GetNotificationFromResource(resource *unstructured.Unstructured, watch *v1alpha1.ResourceWatch) (*notifier.Notification, error) {
+	notification := &notifier.Notification{
+		PipelineRun: resource.GetName(),
+		Namespace:   resource.GetNamespace(),
+		Kind:        resource.GetKind(),
+		Status:      notifier.StatusFailed,
+		Event:       notifier.EventFailed,
+		Results:     []notifier.Result{},
+	}
+	if watch.Status == notifier.StatusSucceeded {
+		notification.Status, notification.Event = notifier.StatusSucceeded, notifier.EventSucceeded
+	}
+	var errs []error
+	for _, result := range watch.Results {
+		value, err := EvaluateCELString(result.Expression, resource.Object)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Failed to evaluate result %s: %w", result.Name, err))
+			continue
+		}
+		notification.Results = append(notification.Results, notifier.Result{Name: result.Name, Value: value})
+	}
+	return notification, errors.Join(errs...)
+}
+
+// getWatchingNotificationServices returns the NotificationServices of the namespace watching the kind of the reconciler
+func (r *ResourceReconciler) getWatchingNotificationServices(ctx context.Context, namespace string) ([]v1alpha1.NotificationService, error) {
+	notificationServices := &v1alpha1.NotificationServiceList{}
+	err := r.Reconciler.List(ctx, notificationServices, client.InNamespace(namespace))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list NotificationServices in namespace %s: %w", namespace, err)
+	}
+	var watching []v1alpha1.NotificationService
+	for _, notificationService := range notificationServices.Items {
+		if r.watches(&notificationService) {
+			watching = append(watching, notificationService)
+		}
+	}
+	return watching, nil
+}
+
+// watches returns a boolean indicating whether the NotificationService watches the kind of the reconciler
+func (r *ResourceReconciler) watches(notificationService *v1alpha1.NotificationService) bool {
+	watch := notificationService.Spec.Watch
+	return watch != nil && watch.APIVersion == r.GroupVersionKind.GroupVersion().String() && watch.Kind == r.GroupVersionKind.Kind
+}
+
+// mapNotificationService returns the resources of the namespace of the NotificationService, if it watches them
+func (r *ResourceReconciler) mapNotificationService(ctx context.Context, notificationService *v1alpha1.NotificationService) []reconcile.Request {
+	if !r.watches(notificationService) {
+		return nil
+	}
+	// Unstructured resources are not read from the cache, they are listed from the API server
+	resources := &unstructured.UnstructuredList{}
+	resources.SetGroupVersionKind(r.GroupVersionKind.GroupVersion().WithKind(r.GroupVersionKind.Kind + "List"))
+	var requests []reconcile.Request
+	err := ListPages(ctx, r.Reconciler, resources, r.Reconciler.ListPageSize, func() error {
+		for _, resource := range resources.Items {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&resource)})
+		}
+		return nil
+	}, client.InNamespace(notificationService.Namespace))
+	if err != nil {
+		r.Reconciler.Log.Error(err, "Failed to list watched resources", "namespace", notificationService.Namespace)
+		return nil
+	}
+	return requests
+}
+
+// applyNotifiedConditions server-side applies NotificationConditionsAnnotation with the notified destinations
+// on the resource, or removes it if there are none
+func (r *ResourceReconciler) applyNotifiedConditions(ctx context.Context, resource *unstructured.Unstructured, notified map[string]time.Time) error {
+	applied := &unstructured.Unstructured{}
+	applied.SetGroupVersionKind(r.GroupVersionKind)
+	applied.SetName(resource.GetName())
+	applied.SetNamespace(resource.GetNamespace())
+	if len(notified) > 0 {
+		encoded, err := json.Marshal(notified)
+		if err != nil {
+			return err
+		}
+		setRunAnnotation(applied, NotificationConditionsAnnotation, string(encoded))
+	}
+	return r.Reconciler.Patch(ctx, applied, client.Apply, client.FieldOwner(NotificationFieldManager), client.ForceOwnership)
+}
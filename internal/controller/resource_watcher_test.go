@@ -0,0 +1,172 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Resource watcher", func() {
+	var (
+		received            []*notifier.Notification
+		notificationService *v1alpha1.NotificationService
+	)
+
+	BeforeEach(func() {
+		received = nil
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			notification := &notifier.Notification{}
+			Expect(json.NewDecoder(req.Body).Decode(notification)).To(Succeed())
+			received = append(received, notification)
+		}))
+		DeferCleanup(receiver.Close)
+		notificationService = &v1alpha1.NotificationService{
+			ObjectMeta: metav1.ObjectMeta{Name: "watch-configmaps", Namespace: "default"},
+			Spec: v1alpha1.NotificationServiceSpec{
+				Destinations: []v1alpha1.Destination{{Name: "hook", Webhook: &v1alpha1.WebhookDestination{URL: receiver.URL}}},
+				Watch: &v1alpha1.ResourceWatch{
+					APIVersion: "v1",
+					Kind:       "ConfigMap",
+					Condition:  "has(object.data) && object.data.state == 'broken'",
+					Results: []v1alpha1.ResourceResult{
+						{Name: "state", Expression: "object.data.state"},
+						{Name: "keys", Expression: "size(object.data)"},
+					},
+				},
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), notificationService)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), notificationService)
+	})
+
+	setState := func(configMap *corev1.ConfigMap, state string) {
+		Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap)).To(Succeed())
+		configMap.Data = map[string]string{"state": state}
+		Expect(k8sClient.Update(context.Background(), configMap)).To(Succeed())
+	}
+
+	reconcileConfigMap := func(r *ResourceReconciler, configMap *corev1.ConfigMap) *corev1.ConfigMap {
+		_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(configMap)})
+		Expect(err).NotTo(HaveOccurred())
+		reconciled := &corev1.ConfigMap{}
+		Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), reconciled)).To(Succeed())
+		return reconciled
+	}
+
+	It("should notify every time the condition becomes true", func() {
+		r := &ResourceReconciler{
+			Reconciler:       &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()},
+			GroupVersionKind: corev1.SchemeGroupVersion.WithKind("ConfigMap"),
+		}
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "watched-config", Namespace: "default"},
+			Data:       map[string]string{"state": "ok"},
+		}
+		Expect(k8sClient.Create(context.Background(), configMap)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), configMap)
+
+		reconcileConfigMap(r, configMap)
+		Expect(received).To(BeEmpty())
+
+		setState(configMap, "broken")
+		reconciled := reconcileConfigMap(r, configMap)
+		Expect(reconciled.Annotations).To(HaveKey(NotificationConditionsAnnotation))
+		Expect(received).To(HaveLen(1))
+		Expect(received[0].Kind).To(Equal("ConfigMap"))
+		Expect(received[0].PipelineRun).To(Equal("watched-config"))
+		Expect(received[0].Status).To(Equal(notifier.StatusFailed))
+		Expect(received[0].Results).To(Equal([]notifier.Result{{Name: "state", Value: "broken"}, {Name: "keys", Value: "1"}}))
+
+		reconcileConfigMap(r, configMap)
+		Expect(received).To(HaveLen(1))
+
+		setState(configMap, "ok")
+		reconciled = reconcileConfigMap(r, configMap)
+		Expect(reconciled.Annotations).NotTo(HaveKey(NotificationConditionsAnnotation))
+
+		setState(configMap, "broken")
+		reconcileConfigMap(r, configMap)
+		Expect(received).To(HaveLen(2))
+	})
+
+	It("should not apply watches to pipelineruns", func() {
+		r := &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+		notificationServices, err := GetNamespaceNotificationServices(context.Background(), r, "default")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(notificationServices).NotTo(ContainElement(HaveField("Name", notificationService.Name)))
+	})
+
+	It("should report invalid watches", func() {
+		Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(notificationService), notificationService)).To(Succeed())
+		notificationService.Spec.Watch.Condition = "object.status.("
+		Expect(k8sClient.Update(context.Background(), notificationService)).To(Succeed())
+		w := &ResourceWatcher{Reconciler: &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}}
+		_, err := w.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(notificationService)})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(notificationService), notificationService)).To(Succeed())
+		condition := meta.FindStatusCondition(notificationService.Status.Conditions, ResourceWatchedCondition)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal("InvalidWatch"))
+	})
+
+	It("should only watch the allowed kinds", func() {
+		watch := &v1alpha1.ResourceWatch{APIVersion: "v1", Kind: "Secret", Condition: "true",
+			Results: []v1alpha1.ResourceResult{{Name: "token", Expression: "object.data.token"}}}
+		_, err := ValidateResourceWatch(watch, nil)
+		Expect(err).To(MatchError(ContainSubstring("Kind Secret of API version v1 may not be watched")))
+		_, err = (&AdmissionValidator{Client: k8sClient}).ValidateCreate(context.Background(), &v1alpha1.NotificationService{
+			ObjectMeta: metav1.ObjectMeta{Name: "watch-secrets", Namespace: "default"},
+			Spec:       v1alpha1.NotificationServiceSpec{Watch: watch},
+		})
+		Expect(err).To(MatchError(ContainSubstring("Invalid watch")))
+
+		kinds, err := ParseWatchableKinds([]string{"Secret", "Deployment.apps"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ValidateResourceWatch(watch, kinds)).To(Equal(corev1.SchemeGroupVersion.WithKind("Secret")))
+		_, err = ValidateResourceWatch(&v1alpha1.ResourceWatch{APIVersion: "v1", Kind: "ConfigMap", Condition: "true"}, kinds)
+		Expect(err).To(HaveOccurred())
+		_, err = ParseWatchableKinds([]string{".apps"})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should evaluate results as strings or JSON", func() {
+		object := map[string]any{
+			"status": map[string]any{"replicas": int64(3), "ready": true, "images": []any{"quay.io/org/app:v1"}},
+		}
+		Expect(EvaluateCELString("object.status.replicas", object)).To(Equal("3"))
+		Expect(EvaluateCELString("object.status.images", object)).To(Equal(`["quay.io/org/app:v1"]`))
+		Expect(EvaluateCELCondition("object.status.ready", object)).To(BeTrue())
+		_, err := EvaluateCELCondition("object.status.replicas", object)
+		Expect(err).To(HaveOccurred())
+	})
+})
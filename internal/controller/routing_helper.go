@@ -0,0 +1,152 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// Namespace annotations that route the notifications of all pipelineruns in the namespace
+const (
+	// NamespaceTeamAnnotation names the team owning the namespace, it is included in notifications
+	NamespaceTeamAnnotation string = "konflux.ci/team"
+	// NamespaceSlackChannelAnnotation is the Slack channel notifications are posted to
+	NamespaceSlackChannelAnnotation string = "konflux.ci/slack-channel"
+	// NamespaceWebhookURLAnnotation is the URL notifications are posted to as JSON
+	NamespaceWebhookURLAnnotation string = "konflux.ci/webhook-url"
+)
+
+// Namespace labels identifying the Konflux tenant owning a namespace
+const (
+	// WorkspaceSpaceLabel is set by the Konflux workspace API to the workspace (Space) provisioning the namespace
+	WorkspaceSpaceLabel string = "toolchain.dev.openshift.com/space"
+	// WorkspaceOwnerLabel is set by the Konflux workspace API to the owner of the workspace
+	WorkspaceOwnerLabel string = "toolchain.dev.openshift.com/owner"
+	// KonfluxNamespaceTypeLabel is set to KonfluxTenantNamespaceType on Konflux tenant namespaces,
+	// which are their own tenant
+	KonfluxNamespaceTypeLabel string = "konflux-ci.dev/type"
+	// KonfluxTenantNamespaceType is the KonfluxNamespaceTypeLabel value of tenant namespaces
+	KonfluxTenantNamespaceType string = "tenant"
+)
+
+// TenantDirectoryKey is the key of the tenant directory ConfigMap holding its entries
+const TenantDirectoryKey string = "tenants.yaml"
+
+// TenantEntry is the contact channel registered for a Konflux tenant
+type TenantEntry struct {
+	// Tenant is the workspace or tenant namespace name
+	Tenant string `json:"tenant"`
+	// Team names the team of the tenant, it is included in notifications. Defaults to the tenant.
+	Team string `json:"team,omitempty"`
+	// SlackChannel is the Slack channel notifications are posted to
+	SlackChannel string `json:"slackChannel,omitempty"`
+	// WebhookURL is the URL notifications are posted to as JSON
+	WebhookURL string `json:"webhookURL,omitempty"`
+}
+
+// GetNamespaceRouting returns the team of the pipelineRun namespace and the notifiers
+// of the destinations declared in its annotations. Namespaces that declare no destination are routed
+// to the contact channel registered for their tenant in the tenant directory, if the reconciler has one.
+// Destinations that are not valid are skipped and reported in the log
+func GetNamespaceRouting(ctx context.Context, r *NotificationServiceReconciler, pipelineRun *tektonv1.PipelineRun) (string, []DestinationNotifier, error) {
+	namespace := &corev1.Namespace{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: pipelineRun.Namespace}, namespace)
+	if err != nil {
+		return "", nil, fmt.Errorf("Failed to get namespace %s: %w", pipelineRun.Namespace, err)
+	}
+	annotations := namespace.GetAnnotations()
+	team := annotations[NamespaceTeamAnnotation]
+	notifiers := newRouteNotifiers(r, namespace.Name, "", annotations[NamespaceSlackChannelAnnotation],
+		annotations[NamespaceWebhookURLAnnotation])
+	if len(notifiers) > 0 || r.TenantDirectory.Name == "" {
+		return team, notifiers, nil
+	}
+
+	tenant := GetNamespaceTenant(namespace)
+	if tenant == "" {
+		return team, nil, nil
+	}
+	entries, err := GetTenantDirectory(ctx, r.Client, r.TenantDirectory)
+	if err != nil {
+		r.Log.Error(err, "Skipping tenant routing", "namespace", namespace.Name, "tenant", tenant)
+		return team, nil, nil
+	}
+	for _, entry := range entries {
+		if entry.Tenant != tenant {
+			continue
+		}
+		if team == "" {
+			team = entry.Team
+		}
+		if team == "" {
+			team = tenant
+		}
+		return team, newRouteNotifiers(r, namespace.Name, "tenant-", entry.SlackChannel, entry.WebhookURL), nil
+	}
+	return team, nil, nil
+}
+
+// GetNamespaceTenant returns the Konflux tenant owning the namespace: the workspace it was provisioned for
+// by the workspace API, the namespace itself if it is a tenant namespace, or empty if it is neither
+func GetNamespaceTenant(namespace *corev1.Namespace) string {
+	labels := namespace.GetLabels()
+	switch {
+	case labels[WorkspaceSpaceLabel] != "":
+		return labels[WorkspaceSpaceLabel]
+	case labels[WorkspaceOwnerLabel] != "":
+		return labels[WorkspaceOwnerLabel]
+	case labels[KonfluxNamespaceTypeLabel] == KonfluxTenantNamespaceType:
+		return namespace.Name
+	}
+	return ""
+}
+
+// GetTenantDirectory reads the entries of the tenant directory ConfigMap
+// Return error if the ConfigMap does not exist or its entries are malformed
+func GetTenantDirectory(ctx context.Context, c client.Reader, directory types.NamespacedName) ([]TenantEntry, error) {
+	configMap := &corev1.ConfigMap{}
+	err := c.Get(ctx, directory, configMap)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get tenant directory %s: %w", directory, err)
+	}
+	var entries []TenantEntry
+	err = yaml.Unmarshal([]byte(configMap.Data[TenantDirectoryKey]), &entries)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse tenant directory %s: %w", directory, err)
+	}
+	return entries, nil
+}
+
+// newRouteNotifiers returns the notifiers of the Slack channel and webhook URL routing the notifications of
+// the namespace, identified as <namespace>/namespace/<prefix>slack and <namespace>/namespace/<prefix>webhook
+// Routes that are not valid are skipped and reported in the log
+func newRouteNotifiers(r *NotificationServiceReconciler, namespace string, prefix string, channel string, url string) []DestinationNotifier {
+	var notifiers []DestinationNotifier
+	if channel != "" {
+		if r.SlackToken == "" {
+			r.Log.Info("Slack token is not configured, skipping namespace Slack channel", "namespace", namespace)
+		} else {
+			n, err := notifier.NewSlackNotifier(notifier.SlackOptions{Token: r.SlackToken, Channel: channel})
+			if err != nil {
+				r.Log.Error(err, "Skipping invalid namespace Slack channel", "namespace", namespace)
+			} else {
+				notifiers = append(notifiers, DestinationNotifier{Name: namespace + "/namespace/" + prefix + "slack", Notifier: n})
+			}
+		}
+	}
+	if url != "" {
+		n, err := notifier.NewWebhookNotifier(notifier.WebhookOptions{URL: url})
+		if err != nil {
+			r.Log.Error(err, "Skipping invalid namespace webhook", "namespace", namespace)
+		} else {
+			notifiers = append(notifiers, DestinationNotifier{Name: namespace + "/namespace/" + prefix + "webhook", Notifier: n})
+		}
+	}
+	return notifiers
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("Namespace routing", func() {
+	var received []*notifier.Notification
+
+	// createRoutedNamespace creates a namespace whose annotations route notifications to a webhook
+	createRoutedNamespace := func(name string) {
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			notification := &notifier.Notification{}
+			Expect(json.NewDecoder(req.Body).Decode(notification)).To(Succeed())
+			received = append(received, notification)
+		}))
+		DeferCleanup(receiver.Close)
+		namespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+				Annotations: map[string]string{
+					NamespaceTeamAnnotation:         "build-team",
+					NamespaceWebhookURLAnnotation:   receiver.URL,
+					NamespaceSlackChannelAnnotation: "#build-team",
+				},
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), namespace)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		received = nil
+	})
+
+	It("should notify the destinations declared in the namespace annotations", func() {
+		createRoutedNamespace("routed")
+		pipelineRun := createPipelineRun("routed", corev1.ConditionTrue)
+		pipelineRun.Namespace = "routed"
+		r := &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), NamespaceRouting: true}
+		team, destinations, err := GetNamespaceRouting(context.Background(), r, pipelineRun)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(team).To(Equal("build-team"))
+		// The Slack channel is skipped without a Slack token
+		Expect(destinations).To(HaveLen(1))
+		Expect(destinations[0].Name).To(Equal("routed/namespace/webhook"))
+
+		r.SlackToken = "xoxb-test"
+		_, destinations, err = GetNamespaceRouting(context.Background(), r, pipelineRun)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(destinations).To(HaveLen(2))
+	})
+
+	It("should include the team in notifications", func() {
+		createRoutedNamespace("routed-team")
+		pipelineRun := createPipelineRun("routed-team", corev1.ConditionTrue)
+		r := &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), NamespaceRouting: true}
+		pipelineRun.Namespace = "routed-team"
+		err := r.notify(context.Background(), pipelineRun)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(received).To(HaveLen(1))
+		Expect(received[0].Team).To(Equal("build-team"))
+	})
+
+	It("should not route notifications when disabled", func() {
+		pipelineRun := createPipelineRun("unrouted", corev1.ConditionTrue)
+		fake := &fakeNotifier{}
+		r := &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Notifier: fake}
+		Expect(reconcilePipelineRun(r, pipelineRun)).To(Succeed())
+		Expect(fake.notifications[0].Team).To(BeEmpty())
+	})
+
+	It("should route namespaces to the channel of their tenant", func() {
+		directory := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "tenant-directory", Namespace: "default"},
+			Data: map[string]string{TenantDirectoryKey: `
+- tenant: payments
+  team: payments-team
+  webhookURL: http://payments.example.com/hooks
+- tenant: tenant-checkout
+  webhookURL: http://checkout.example.com/hooks
+`},
+		}
+		Expect(k8sClient.Create(context.Background(), directory)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), directory)
+		workspace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name: "payments-tenant", Labels: map[string]string{WorkspaceSpaceLabel: "payments"},
+		}}
+		Expect(k8sClient.Create(context.Background(), workspace)).To(Succeed())
+		tenant := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name: "tenant-checkout", Labels: map[string]string{KonfluxNamespaceTypeLabel: KonfluxTenantNamespaceType},
+		}}
+		Expect(k8sClient.Create(context.Background(), tenant)).To(Succeed())
+
+		r := &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), NamespaceRouting: true,
+			TenantDirectory: types.NamespacedName{Namespace: "default", Name: "tenant-directory"}}
+		pipelineRun := &tektonv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "payments-tenant"}}
+		team, destinations, err := GetNamespaceRouting(context.Background(), r, pipelineRun)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(team).To(Equal("payments-team"))
+		Expect(destinations).To(HaveLen(1))
+		Expect(destinations[0].Name).To(Equal("payments-tenant/namespace/tenant-webhook"))
+
+		pipelineRun.Namespace = "tenant-checkout"
+		team, destinations, err = GetNamespaceRouting(context.Background(), r, pipelineRun)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(team).To(Equal("tenant-checkout"))
+		Expect(destinations).To(HaveLen(1))
+
+		pipelineRun.Namespace = "default"
+		_, destinations, err = GetNamespaceRouting(context.Background(), r, pipelineRun)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(destinations).To(BeEmpty())
+	})
+})
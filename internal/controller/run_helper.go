@@ -0,0 +1,284 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/konflux-ci/notification-service/pkg/delivery"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	"github.com/konflux-ci/operator-toolkit/metadata"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// reconcileRun handles a run of another kind than pipelinerun, e.g. a CustomRun, with the same lifecycle as
+// pipelineruns: runs that are not done are held with NotificationPipelineRunFinalizer, and once they are
+// done, the notification returned by build is sent to the default notifier and to the destinations of the
+// NotificationServices, and the run is marked with NotificationPipelineRunAnnotation and released.
+// Lifecycle notifications, acknowledgements, threads and delivery records only apply to pipelineruns.
+func (r *NotificationServiceReconciler) reconcileRun(ctx context.Context, run client.Object, gvk schema.GroupVersionKind,
+	done bool, build func() *notifier.Notification) (ctrl.Result, error) {
+	logger := r.Log.WithValues(gvk.Kind, client.ObjectKeyFromObject(run))
+	notified := metadata.HasAnnotationWithValue(run, NotificationPipelineRunAnnotation, NotificationPipelineRunAnnotationValue)
+	finalized := controllerutil.ContainsFinalizer(run, NotificationPipelineRunFinalizer)
+	if notified && !finalized {
+		return ctrl.Result{}, nil
+	}
+
+	if !done {
+		if finalized || notified {
+			return ctrl.Result{}, nil
+		}
+		needsFinalizer, err := NeedsFinalizer(ctx, r, run.GetNamespace())
+		if err != nil {
+			logger.Error(err, "Failed to check whether run needs a finalizer")
+		}
+		if needsFinalizer || err != nil {
+			err = applyRunMetadata(ctx, r.Client, run, gvk, func(applied *unstructured.Unstructured) {
+				controllerutil.AddFinalizer(applied, NotificationPipelineRunFinalizer)
+			})
+			if err != nil {
+				logger.Error(err, "Failed to add finalizer to run")
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if !notified {
+		err := r.notifyRun(ctx, run, gvk, build())
+		if errors.Is(err, ErrDeliveryThrottled) {
+			retryAfter := r.ConfigFile.Get().ThrottleRetryAfter()
+			logger.Info("Deliveries are throttled", "retryAfter", retryAfter)
+			return ctrl.Result{RequeueAfter: retryAfter}, nil
+		}
+		if backoff := (*BackoffError)(nil); errors.As(err, &backoff) {
+			logger.Info("Destinations are backing off", "until", backoff.Until)
+			return ctrl.Result{RequeueAfter: time.Until(backoff.Until)}, nil
+		}
+		if err != nil {
+			logger.Error(err, "Failed to send notification for run")
+			return ctrl.Result{}, err
+		}
+	}
+	err := applyRunMetadata(ctx, r.Client, run, gvk, func(applied *unstructured.Unstructured) {
+		setRunAnnotation(applied, NotificationPipelineRunAnnotation, NotificationPipelineRunAnnotationValue)
+		controllerutil.RemoveFinalizer(applied, NotificationPipelineRunFinalizer)
+	})
+	if err != nil {
+		logger.Error(err, "Failed to mark run as notified")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// notifyRun enriches the notification for the run and sends it to the default notifier and to the destinations of all
+// NotificationServices it was not delivered to yet, and records the destinations it is delivered to
+func (r *NotificationServiceReconciler) notifyRun(ctx context.Context, run client.Object, gvk schema.GroupVersionKind,
+	notification *notifier.Notification) error {
+	r.enrich(ctx, notification)
+	destinations, err := GetDestinationNotifiers(ctx, r, run.GetNamespace())
+	if err != nil {
+		return err
+	}
+	if r.Notifier != nil {
+		destinations = append(destinations, DestinationNotifier{Name: DefaultDestinationName, Notifier: r.Notifier})
+	}
+	destinations = FilterEventDestinations(destinations, notification)
+	destinations = FilterEscalationDestinations(destinations, notification)
+	destinations = FilterPolicyDestinations(destinations, notification)
+	destinations = FilterResultDestinations(destinations, notification)
+	delivered := map[string]time.Time{}
+	if encoded := run.GetAnnotations()[NotificationDeliveredAnnotation]; encoded != "" {
+		err = json.Unmarshal([]byte(encoded), &delivered)
+		if err != nil {
+			r.Log.Error(err, "Ignoring malformed delivered destinations")
+			delivered = map[string]time.Time{}
+		}
+	}
+	destinations = FilterDeliveredDestinations(destinations, delivered)
+	destinations, _ = SplitPausedDestinations(destinations)
+
+	completed, err := r.deliverRun(ctx, run, string(run.GetUID()), destinations, notification)
+	if len(completed) > 0 {
+		now := time.Now().UTC().Truncate(time.Second)
+		for _, destination := range completed {
+			delivered[destination] = now
+		}
+		encoded, encodeErr := json.Marshal(delivered)
+		if encodeErr != nil {
+			return errors.Join(err, fmt.Errorf("Failed to encode annotation %s: %w", NotificationDeliveredAnnotation, encodeErr))
+		}
+		applyErr := applyRunMetadata(ctx, r.Client, run, gvk, func(applied *unstructured.Unstructured) {
+			setRunAnnotation(applied, NotificationDeliveredAnnotation, string(encoded))
+		})
+		if applyErr != nil {
+			err = errors.Join(err, fmt.Errorf("Error occurred while patching annotation %s of run: %w", NotificationDeliveredAnnotation, applyErr))
+		}
+	}
+	return err
+}
+
+// deliverRun sends the notification about the run to every destination, with delivery IDs derived from key,
+// records the attempts as events on the run and in the audit log, and returns the completed destinations, which
+// it was delivered to, blocked for because secrets were detected in it, or dropped for by a transform.
+// Destinations whose throttling limits are reached are not sent to, and ErrDeliveryThrottled is returned
+// if no delivery failed. Destinations backing off are not sent to either, and a BackoffError is returned
+// if no delivery failed nor was throttled.
+func (r *NotificationServiceReconciler) deliverRun(ctx context.Context, run client.Object, key string,
+	destinations []DestinationNotifier, notification *notifier.Notification) ([]string, error) {
+	notification, err := r.transform(ctx, notification)
+	if err != nil {
+		return nil, err
+	}
+	if notification == nil {
+		return destinationNames(destinations), nil
+	}
+	restricted, err := r.restrictPayload(ctx, run.GetNamespace(), destinations, notification)
+	if err != nil {
+		return nil, err
+	}
+	archivePayloads(ctx, r.Archive, r.Log, key, destinations, notification, restricted)
+	policy := r.ConfigFile.Get().Throttling
+	throttled := false
+	var backoffUntil time.Time
+	var completed []string
+	var errs []error
+	for _, destination := range destinations {
+		if until, ok := r.backoff.Until(destination.Name, time.Now()); ok {
+			deliveriesBackedOff.Inc()
+			backoffUntil = earliest(backoffUntil, until)
+			continue
+		}
+		deliveryID := delivery.NewID(key, destination.Name, notification.Status)
+		if destination.Ordered && !r.sequencer.Ready(destination.Name, deliveryID, notification, time.Now()) {
+			deliveriesHeldBack.Inc()
+			throttled = true
+			continue
+		}
+		release, ok := r.throttle.Acquire(run.GetNamespace(), destination.Name, destinationPolicy(destination, policy))
+		if !ok {
+			deliveriesThrottled.Inc()
+			throttled = true
+			continue
+		}
+		destinationNotification := *payloadFor(destination, notification, restricted)
+		destinationNotification.DeliveryID = deliveryID
+		done := startDelivery()
+		var response *notifier.Response
+		scanned, err := notifier.ScanNotification(&destinationNotification)
+		if err == nil {
+			destinationNotification = *scanned
+			response, err = notifier.Deliver(ctx, destination.Notifier, &destinationNotification)
+		}
+		done(err)
+		release()
+		r.recordBackoff(ctx, destination, err)
+		if r.SLOTracker != nil {
+			r.SLOTracker.Record(destination, key, &destinationNotification, err)
+		}
+		if r.StatusSummarizer != nil {
+			r.StatusSummarizer.Record(destination, err)
+		}
+		RecordDeliveryEvent(r, run, destination.Name, response, err)
+		if r.AuditLog != nil {
+			auditErr := r.AuditLog.Append(ctx, destination.Name, &destinationNotification, err)
+			if auditErr != nil {
+				r.Log.Error(auditErr, "Failed to append to audit log", "destination", destination.Name)
+			}
+		}
+		if destination.Ordered && (err == nil || errors.Is(err, notifier.ErrSecretDetected)) {
+			r.sequencer.Done(destination.Name, deliveryID)
+		}
+		if errors.Is(err, notifier.ErrSecretDetected) {
+			// Blocked notifications would be blocked again, they are not retried
+			completed = append(completed, destination.Name)
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		completed = append(completed, destination.Name)
+	}
+	if throttled && len(errs) == 0 {
+		return completed, ErrDeliveryThrottled
+	}
+	if !backoffUntil.IsZero() && len(errs) == 0 {
+		return completed, &BackoffError{Until: backoffUntil}
+	}
+	return completed, errors.Join(errs...)
+}
+
+// applyRunMetadata server-side applies the finalizer and the notified and delivered annotations owned
+// by the controller on the run of the kind, as changed by mutate, with the NotificationFieldManager field
+// manager, the same way applyPipelineRunMetadata does for pipelineruns. The run is updated with the
+// applied metadata.
+func applyRunMetadata(ctx context.Context, c client.Client, run client.Object, gvk schema.GroupVersionKind,
+	mutate func(applied *unstructured.Unstructured)) error {
+	applied := &unstructured.Unstructured{}
+	applied.SetGroupVersionKind(gvk)
+	applied.SetName(run.GetName())
+	applied.SetNamespace(run.GetNamespace())
+	if controllerutil.ContainsFinalizer(run, NotificationPipelineRunFinalizer) {
+		controllerutil.AddFinalizer(applied, NotificationPipelineRunFinalizer)
+	}
+	for _, annotation := range []string{NotificationPipelineRunAnnotation, NotificationDeliveredAnnotation} {
+		if value, ok := run.GetAnnotations()[annotation]; ok {
+			setRunAnnotation(applied, annotation, value)
+		}
+	}
+	mutate(applied)
+	err := c.Patch(ctx, applied, client.Apply, client.FieldOwner(NotificationFieldManager), client.ForceOwnership)
+	if err != nil {
+		return err
+	}
+	run.SetFinalizers(applied.GetFinalizers())
+	run.SetAnnotations(applied.GetAnnotations())
+	return nil
+}
+
+// setRunAnnotation sets the annotation of the applied run
+func setRunAnnotation(applied *unstructured.Unstructured, annotation string, value string) {
+	annotations := applied.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[annotation] = value
+	applied.SetAnnotations(annotations)
+}
+
+// getStatusTime returns the timestamp of the status field of the run, or nil if it is not set or malformed
+func getStatusTime(run *unstructured.Unstructured, field string) *metav1.Time {
+	value, _, _ := unstructured.NestedString(run.Object, "status", field)
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil
+	}
+	return &metav1.Time{Time: parsed}
+}
+
+// getStatusCondition returns the status and reason of the condition of the run, which are empty if the
+// run does not have the condition
+func getStatusCondition(run *unstructured.Unstructured, conditionType string) (string, string) {
+	conditions, _, _ := unstructured.NestedSlice(run.Object, "status", "conditions")
+	for _, condition := range conditions {
+		fields, ok := condition.(map[string]any)
+		if !ok {
+			continue
+		}
+		if name, _, _ := unstructured.NestedString(fields, "type"); name == conditionType {
+			status, _, _ := unstructured.NestedString(fields, "status")
+			reason, _, _ := unstructured.NestedString(fields, "reason")
+			return status, reason
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// serviceRotation counts the requests sent to each Service, to send them to its endpoints in turn
+var serviceRotation = struct {
+	sync.Mutex
+	next map[types.NamespacedName]int
+}{next: map[types.NamespacedName]int{}}
+
+// WebhookServiceNamespaces are the namespaces, besides their own, whose Services the webhook destinations of
+// NotificationServices may reference. It must be set before the controller starts.
+var WebhookServiceNamespaces []string
+
+// CheckServiceReference returns an error if the webhook Service referenced by a destination of a NotificationService
+// of the namespace is in another namespace that is not one of the WebhookServiceNamespaces, so tenants cannot have
+// the controller send requests to the Services of other tenants or of the cluster
+func CheckServiceReference(namespace string, ref *v1alpha1.WebhookServiceReference) error {
+	if ref.Namespace == "" || ref.Namespace == namespace || slices.Contains(WebhookServiceNamespaces, ref.Namespace) {
+		return nil
+	}
+	return fmt.Errorf("Webhook Service %s/%s is not in namespace %s nor in an allowed webhook Service namespace",
+		ref.Namespace, ref.Name, namespace)
+}
+
+// ResolveServiceURL returns the URL of the webhook Service referenced by a destination of a NotificationService
+// of the namespace. Plain HTTP requests are sent to the ready endpoints of the Service in turn, so the controller
+// spreads them without depending on the cluster DNS, unless dns is set. HTTPS requests, and plain HTTP requests
+// if dns is set, are sent to the DNS name of the Service, which the certificate of the webhook is issued for.
+// Return error if the Service does not exist or has no ready endpoint
+func ResolveServiceURL(ctx context.Context, c client.Reader, namespace string, ref *v1alpha1.WebhookServiceReference, dns bool) (string, error) {
+	key := types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}
+	if key.Namespace == "" {
+		key.Namespace = namespace
+	}
+	scheme, path := ref.Scheme, ref.Path
+	if scheme == "" {
+		scheme = "http"
+	}
+	if path == "" {
+		path = "/"
+	}
+	service := &corev1.Service{}
+	err := c.Get(ctx, key, service)
+	if err != nil {
+		return "", fmt.Errorf("Failed to get webhook Service %s: %w", key, err)
+	}
+	if service.Spec.Type == corev1.ServiceTypeExternalName {
+		if ref.Port == 0 {
+			return "", fmt.Errorf("Webhook Service %s is an ExternalName Service, which requires a port", key)
+		}
+		return scheme + "://" + net.JoinHostPort(service.Spec.ExternalName, strconv.Itoa(int(ref.Port))) + path, nil
+	}
+	var port *corev1.ServicePort
+	for i := range service.Spec.Ports {
+		if (ref.Port == 0 && len(service.Spec.Ports) == 1) || service.Spec.Ports[i].Port == ref.Port {
+			port = &service.Spec.Ports[i]
+		}
+	}
+	if port == nil {
+		return "", fmt.Errorf("Webhook Service %s has no port %d", key, ref.Port)
+	}
+	if scheme == "https" || dns {
+		host := key.Name + "." + key.Namespace + ".svc"
+		return scheme + "://" + net.JoinHostPort(host, strconv.Itoa(int(port.Port))) + path, nil
+	}
+
+	endpoints, err := getReadyEndpoints(ctx, c, key, port.Name)
+	if err != nil {
+		return "", err
+	}
+	if len(endpoints) == 0 {
+		return "", fmt.Errorf("Webhook Service %s has no ready endpoint", key)
+	}
+	serviceRotation.Lock()
+	endpoint := endpoints[serviceRotation.next[key]%len(endpoints)]
+	serviceRotation.next[key]++
+	serviceRotation.Unlock()
+	return scheme + "://" + endpoint + path, nil
+}
+
+// getReadyEndpoints returns the host:port of the ready endpoints of the named port of the Service, sorted
+// Return error if failed to list the EndpointSlices of the Service
+func getReadyEndpoints(ctx context.Context, c client.Reader, service types.NamespacedName, portName string) ([]string, error) {
+	endpointSlices := &discoveryv1.EndpointSliceList{}
+	err := c.List(ctx, endpointSlices, client.InNamespace(service.Namespace),
+		client.MatchingLabels{discoveryv1.LabelServiceName: service.Name})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list the endpoints of webhook Service %s: %w", service, err)
+	}
+	var endpoints []string
+	for _, slice := range endpointSlices.Items {
+		index := slices.IndexFunc(slice.Ports, func(port discoveryv1.EndpointPort) bool {
+			return port.Name != nil && *port.Name == portName && port.Port != nil
+		})
+		if index < 0 {
+			continue
+		}
+		port := strconv.Itoa(int(*slice.Ports[index].Port))
+		for _, endpoint := range slice.Endpoints {
+			if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+				continue
+			}
+			for _, address := range endpoint.Addresses {
+				endpoints = append(endpoints, net.JoinHostPort(address, port))
+			}
+		}
+	}
+	slices.Sort(endpoints)
+	return slices.Compact(endpoints), nil
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Webhook Services", func() {
+	BeforeEach(func() {
+		service := &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "receiver", Namespace: "default"},
+			Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{
+				{Name: "http", Port: 80},
+				{Name: "metrics", Port: 9090},
+			}},
+		}
+		Expect(k8sClient.Create(context.Background(), service)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), service)
+		httpName, metricsName := "http", "metrics"
+		httpPort, metricsPort := int32(8080), int32(9090)
+		ready, notReady := true, false
+		endpointSlice := &discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "receiver-abc", Namespace: "default",
+				Labels: map[string]string{discoveryv1.LabelServiceName: "receiver"},
+			},
+			AddressType: discoveryv1.AddressTypeIPv4,
+			Ports: []discoveryv1.EndpointPort{
+				{Name: &httpName, Port: &httpPort},
+				{Name: &metricsName, Port: &metricsPort},
+			},
+			Endpoints: []discoveryv1.Endpoint{
+				{Addresses: []string{"10.0.0.2"}, Conditions: discoveryv1.EndpointConditions{Ready: &ready}},
+				{Addresses: []string{"10.0.0.1"}, Conditions: discoveryv1.EndpointConditions{Ready: &ready}},
+				{Addresses: []string{"10.0.0.3"}, Conditions: discoveryv1.EndpointConditions{Ready: &notReady}},
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), endpointSlice)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), endpointSlice)
+	})
+
+	It("should send requests to the ready endpoints in turn", func() {
+		ref := &v1alpha1.WebhookServiceReference{Name: "receiver", Port: 80, Path: "/hooks"}
+		var urls []string
+		for range 3 {
+			url, err := ResolveServiceURL(context.Background(), k8sClient, "default", ref, false)
+			Expect(err).NotTo(HaveOccurred())
+			urls = append(urls, url)
+		}
+		Expect(urls).To(ContainElements("http://10.0.0.1:8080/hooks", "http://10.0.0.2:8080/hooks"))
+		Expect(urls).NotTo(ContainElement(ContainSubstring("10.0.0.3")))
+		Expect(urls[0]).To(Equal(urls[2]))
+	})
+
+	It("should send HTTPS requests to the Service DNS name", func() {
+		ref := &v1alpha1.WebhookServiceReference{Name: "receiver", Port: 80, Scheme: "https"}
+		Expect(ResolveServiceURL(context.Background(), k8sClient, "default", ref, false)).To(Equal("https://receiver.default.svc:80/"))
+	})
+
+	It("should send plain HTTP requests to the Service DNS name if requested", func() {
+		ref := &v1alpha1.WebhookServiceReference{Name: "receiver", Port: 80}
+		Expect(ResolveServiceURL(context.Background(), k8sClient, "default", ref, true)).To(Equal("http://receiver.default.svc:80/"))
+	})
+
+	It("should restrict the Services of tenants to their namespace and the allowed namespaces", func() {
+		destination := v1alpha1.Destination{
+			Name:    "other",
+			Webhook: &v1alpha1.WebhookDestination{ServiceRef: &v1alpha1.WebhookServiceReference{Name: "receiver", Namespace: "default", Port: 80}},
+		}
+		_, err := NewTenantNotifierForDestination(context.Background(), k8sClient, "tenant", destination)
+		Expect(err).To(MatchError(ContainSubstring("is not in namespace tenant")))
+		_, err = NewNotifierForDestination(context.Background(), k8sClient, "tenant", destination)
+		Expect(err).NotTo(HaveOccurred())
+
+		WebhookServiceNamespaces = []string{"default"}
+		DeferCleanup(func() { WebhookServiceNamespaces = nil })
+		_, err = NewTenantNotifierForDestination(context.Background(), k8sClient, "tenant", destination)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should reject unknown Services and ambiguous ports", func() {
+		_, err := ResolveServiceURL(context.Background(), k8sClient, "default", &v1alpha1.WebhookServiceReference{Name: "receiver"}, false)
+		Expect(err).To(MatchError(ContainSubstring("has no port")))
+		_, err = ResolveServiceURL(context.Background(), k8sClient, "default", &v1alpha1.WebhookServiceReference{Name: "missing"}, false)
+		Expect(err).To(HaveOccurred())
+
+		_, err = NewNotifierForDestination(context.Background(), k8sClient, "default", v1alpha1.Destination{
+			Name:    "both",
+			Webhook: &v1alpha1.WebhookDestination{URL: "http://localhost", ServiceRef: &v1alpha1.WebhookServiceReference{Name: "receiver"}},
+		})
+		Expect(err).To(MatchError(ContainSubstring("exactly one of url and serviceRef")))
+	})
+})
@@ -0,0 +1,241 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// SlackActionsPath is the path of the endpoint receiving Slack interactions
+const SlackActionsPath string = "/slack/actions"
+
+// RerunOfAnnotation is set on PipelineRuns created from a Slack re-run button to the name of the original PipelineRun
+var RerunOfAnnotation = DefaultMarkerPrefix + "/rerun-of"
+
+// RerunByAnnotation is set on PipelineRuns created from a Slack re-run button to the Slack user who clicked it
+var RerunByAnnotation = DefaultMarkerPrefix + "/rerun-by"
+
+// slackRequestMaxAge bounds the age of Slack requests to prevent replays
+const slackRequestMaxAge = 5 * time.Minute
+
+// SlackActionHandler handles clicks on the buttons of Slack messages
+type SlackActionHandler struct {
+	Client client.Client
+	Log    logr.Logger
+	// SigningSecret verifies that requests were sent by the Slack app
+	SigningSecret []byte
+	// HTTPClient is used to reply to the user who clicked the button
+	HTTPClient *http.Client
+}
+
+// slackInteraction is the part of a block_actions interaction payload used by the handler
+type slackInteraction struct {
+	Type string `json:"type"`
+	User struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	} `json:"user"`
+	ResponseURL string `json:"response_url"`
+	Actions     []struct {
+		ActionID string `json:"action_id"`
+		Value    string `json:"value"`
+	} `json:"actions"`
+}
+
+// ServeHTTP verifies the Slack signature of the request and performs the clicked actions
+func (h *SlackActionHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(req.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "failed to read request", http.StatusBadRequest)
+		return
+	}
+	err = VerifySlackSignature(h.SigningSecret, req.Header, body, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "malformed request", http.StatusBadRequest)
+		return
+	}
+	interaction := &slackInteraction{}
+	err = json.Unmarshal([]byte(form.Get("payload")), interaction)
+	if err != nil || interaction.Type != "block_actions" {
+		http.Error(w, "unsupported interaction", http.StatusBadRequest)
+		return
+	}
+	for _, action := range interaction.Actions {
+		if action.ActionID != notifier.SlackRerunAction {
+			continue
+		}
+		namespace, name, ok := strings.Cut(action.Value, "/")
+		if !ok {
+			http.Error(w, "malformed action value", http.StatusBadRequest)
+			return
+		}
+		original := types.NamespacedName{Namespace: namespace, Name: name}
+		logger := h.Log.WithValues("pipelinerun", original, "user", interaction.User.ID)
+		rerun, err := RerunPipelineRun(req.Context(), h.Client, original, interaction.User.Username)
+		if err != nil {
+			logger.Error(err, "Failed to re-run pipelinerun")
+			h.reply(req.Context(), logger, interaction.ResponseURL, fmt.Sprintf("Failed to re-run `%s`: %s", original, err))
+			continue
+		}
+		logger.Info("Re-ran pipelinerun", "rerun", rerun.Name)
+		h.reply(req.Context(), logger, interaction.ResponseURL, fmt.Sprintf("Started `%s/%s`", rerun.Namespace, rerun.Name))
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// reply sends an ephemeral message to the user who clicked the button
+func (h *SlackActionHandler) reply(ctx context.Context, logger logr.Logger, responseURL string, text string) {
+	if responseURL == "" {
+		return
+	}
+	body, _ := json.Marshal(map[string]any{"response_type": "ephemeral", "replace_original": false, "text": text})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, responseURL, bytes.NewReader(body))
+	if err != nil {
+		logger.Error(err, "Failed to reply to Slack interaction")
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	httpClient := h.HTTPClient
+	if httpClient == nil {
+		httpClient = notifier.NewHTTPClient(notifier.DefaultWebhookTimeout)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		logger.Error(err, "Failed to reply to Slack interaction")
+		return
+	}
+	resp.Body.Close()
+}
+
+// VerifySlackSignature checks the X-Slack-Signature header of a request against the signing secret
+// Return error if the signature does not match or the request is too old
+func VerifySlackSignature(secret []byte, header http.Header, body []byte, now time.Time) error {
+	timestamp := header.Get("X-Slack-Request-Timestamp")
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("missing Slack request timestamp")
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > slackRequestMaxAge || age < -slackRequestMaxAge {
+		return fmt.Errorf("Slack request timestamp is too old")
+	}
+	signature, err := hex.DecodeString(strings.TrimPrefix(header.Get("X-Slack-Signature"), "v0="))
+	if err != nil || !hmac.Equal(signature, SignSlackRequest(secret, timestamp, body)) {
+		return fmt.Errorf("invalid Slack signature")
+	}
+	return nil
+}
+
+// SignSlackRequest returns the v0 signature of a Slack request body
+func SignSlackRequest(secret []byte, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// RerunPipelineRun creates a new PipelineRun with the spec of the original one.
+// The service account of the original PipelineRun must be allowed to create PipelineRuns in its namespace,
+// so the re-run does not grant more than the pipeline already has.
+// Return error if the original PipelineRun does not exist or the service account is not allowed.
+func RerunPipelineRun(ctx context.Context, c client.Client, original types.NamespacedName, user string) (*tektonv1.PipelineRun, error) {
+	pipelineRun := &tektonv1.PipelineRun{}
+	err := c.Get(ctx, original, pipelineRun)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, fmt.Errorf("pipelinerun %s does not exist anymore", original)
+		}
+		return nil, fmt.Errorf("Failed to get pipelinerun %s: %w", original, err)
+	}
+
+	serviceAccount := "default"
+	if pipelineRun.Spec.TaskRunTemplate.ServiceAccountName != "" {
+		serviceAccount = pipelineRun.Spec.TaskRunTemplate.ServiceAccountName
+	}
+	review := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   "system:serviceaccount:" + original.Namespace + ":" + serviceAccount,
+			Groups: []string{"system:serviceaccounts", "system:serviceaccounts:" + original.Namespace},
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: original.Namespace,
+				Verb:      "create",
+				Group:     tektonv1.SchemeGroupVersion.Group,
+				Resource:  "pipelineruns",
+			},
+		},
+	}
+	err = c.Create(ctx, review)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to review access of service account %s: %w", serviceAccount, err)
+	}
+	if !review.Status.Allowed {
+		return nil, fmt.Errorf("service account %s is not allowed to create pipelineruns in %s", serviceAccount, original.Namespace)
+	}
+
+	rerun := &tektonv1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: pipelineRun.Name + "-rerun-",
+			Namespace:    pipelineRun.Namespace,
+			Labels:       map[string]string{},
+			Annotations: map[string]string{
+				RerunOfAnnotation: pipelineRun.Name,
+				RerunByAnnotation: user,
+			},
+		},
+		Spec: *pipelineRun.Spec.DeepCopy(),
+	}
+	for key, value := range pipelineRun.Labels {
+		if !strings.HasPrefix(key, "tekton.dev/") {
+			rerun.Labels[key] = value
+		}
+	}
+	rerun.Spec.Status = ""
+	err = c.Create(ctx, rerun)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create pipelinerun: %w", err)
+	}
+	return rerun, nil
+}
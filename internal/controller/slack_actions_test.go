@@ -0,0 +1,130 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Slack actions", func() {
+	secret := []byte("signing-secret")
+	var (
+		handler *SlackActionHandler
+		replies []string
+	)
+
+	// click sends a signed click on the re-run button of the pipelinerun
+	click := func(pipelineRun string, timestamp time.Time, signingSecret []byte) int {
+		responder := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			reply := map[string]any{}
+			Expect(json.NewDecoder(req.Body).Decode(&reply)).To(Succeed())
+			replies = append(replies, reply["text"].(string))
+		}))
+		DeferCleanup(responder.Close)
+		payload, err := json.Marshal(map[string]any{
+			"type":         "block_actions",
+			"user":         map[string]string{"id": "U1", "username": "jane"},
+			"response_url": responder.URL,
+			"actions":      []map[string]string{{"action_id": "rerun", "value": "default/" + pipelineRun}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		body := url.Values{"payload": {string(payload)}}.Encode()
+		ts := strconv.FormatInt(timestamp.Unix(), 10)
+		req := httptest.NewRequest(http.MethodPost, SlackActionsPath, strings.NewReader(body))
+		req.Header.Set("X-Slack-Request-Timestamp", ts)
+		req.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(SignSlackRequest(signingSecret, ts, []byte(body))))
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	listReruns := func(original string) []tektonv1.PipelineRun {
+		pipelineRuns := &tektonv1.PipelineRunList{}
+		Expect(k8sClient.List(context.Background(), pipelineRuns, client.InNamespace("default"))).To(Succeed())
+		var reruns []tektonv1.PipelineRun
+		for _, pipelineRun := range pipelineRuns.Items {
+			if pipelineRun.Annotations[RerunOfAnnotation] == original {
+				reruns = append(reruns, pipelineRun)
+				DeferCleanup(k8sClient.Delete, context.Background(), &pipelineRun)
+			}
+		}
+		return reruns
+	}
+
+	BeforeEach(func() {
+		replies = nil
+		handler = &SlackActionHandler{Client: k8sClient, SigningSecret: secret}
+	})
+
+	It("should reject requests that are not signed by Slack", func() {
+		Expect(click("unsigned", time.Now(), []byte("other-secret"))).To(Equal(http.StatusUnauthorized))
+		Expect(click("unsigned", time.Now().Add(-time.Hour), secret)).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("should not re-run pipelineruns whose service account may not create pipelineruns", func() {
+		createPipelineRun("not-allowed", "")
+		Expect(click("not-allowed", time.Now(), secret)).To(Equal(http.StatusOK))
+		Expect(replies).To(HaveLen(1))
+		Expect(replies[0]).To(ContainSubstring("not allowed"))
+		Expect(listReruns("not-allowed")).To(BeEmpty())
+	})
+
+	It("should create a new pipelinerun with the original spec", func() {
+		role := &rbacv1.Role{
+			ObjectMeta: metav1.ObjectMeta{Name: "pipelinerun-creator", Namespace: "default"},
+			Rules: []rbacv1.PolicyRule{{
+				APIGroups: []string{"tekton.dev"},
+				Resources: []string{"pipelineruns"},
+				Verbs:     []string{"create"},
+			}},
+		}
+		Expect(k8sClient.Create(context.Background(), role)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), role)
+		binding := &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "pipelinerun-creator", Namespace: "default"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "Role", Name: role.Name},
+			Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "default", Namespace: "default"}},
+		}
+		Expect(k8sClient.Create(context.Background(), binding)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), binding)
+
+		original := createPipelineRun("allowed", "")
+		Expect(click("allowed", time.Now(), secret)).To(Equal(http.StatusOK))
+		reruns := listReruns("allowed")
+		Expect(reruns).To(HaveLen(1))
+		Expect(reruns[0].Name).To(HavePrefix("allowed-rerun-"))
+		Expect(reruns[0].Annotations).To(HaveKeyWithValue(RerunByAnnotation, "jane"))
+		Expect(reruns[0].Spec.PipelineRef).To(Equal(original.Spec.PipelineRef))
+		Expect(replies).To(Equal([]string{"Started `default/" + reruns[0].Name + "`"}))
+	})
+})
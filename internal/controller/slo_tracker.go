@@ -0,0 +1,257 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DeliverySLOMetCondition reports whether the notifications of a NotificationService are delivered within its SLO
+const DeliverySLOMetCondition string = "DeliverySLOMet"
+
+// DefaultSLOWindow is the window delivery SLOs are evaluated over, unless they set one
+const DefaultSLOWindow = time.Hour
+
+// DefaultSLOEvaluationInterval is how often delivery SLOs are evaluated, unless configured otherwise
+const DefaultSLOEvaluationInterval = time.Minute
+
+// sloMissedRetention is how long notifications accounted for as bad are remembered, so their later
+// successful retries are not accounted for again
+const sloMissedRetention = 24 * time.Hour
+
+// sloDelivery is a delivery accounted for by a delivery SLO
+type sloDelivery struct {
+	time time.Time
+	good bool
+}
+
+// SLOTracker accounts for the deliveries to the destinations of NotificationServices with a delivery SLO.
+// A delivery is good if it succeeded within the latency of the SLO after the completion of the run.
+// Failed deliveries are retried, so they are only bad once the latency is exceeded, and a notification
+// is accounted for at most once per destination. The tracker periodically computes the burn rate of the
+// error budget of every SLO over its window, exports it as a metric and sets the DeliverySLOMet condition
+// of the NotificationService, which is false while the burn rate exceeds 1.
+// Deliveries are kept in memory, so a restart of the controller starts the windows over.
+type SLOTracker struct {
+	Client client.Client
+	Log    logr.Logger
+	// Interval is how often the SLOs are evaluated
+	Interval time.Duration
+
+	mu         sync.Mutex
+	deliveries map[string][]sloDelivery
+	// missed are the notifications already accounted for as bad, by key and destination
+	missed map[string]time.Time
+}
+
+// NeedLeaderElection returns true since only the leader delivers notifications
+func (t *SLOTracker) NeedLeaderElection() bool {
+	return true
+}
+
+// Start evaluates the SLOs every interval until the context is cancelled
+func (t *SLOTracker) Start(ctx context.Context) error {
+	ticker := time.NewTicker(t.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		err := t.RunOnce(ctx)
+		if err != nil {
+			t.Log.Error(err, "Failed to evaluate delivery SLOs")
+		}
+	}
+}
+
+// Record accounts for the outcome of delivering the notification identified by key, e.g. the UID
+// of the run, to the destination, if its NotificationService has a delivery SLO
+func (t *SLOTracker) Record(destination DestinationNotifier, key string, notification *notifier.Notification, err error) {
+	if destination.SLO == nil || notification.CompletionTime == nil {
+		return
+	}
+	now := time.Now()
+	exceeded := now.Sub(*notification.CompletionTime) > destination.SLO.Latency.Duration
+	delivery := key + "/" + destination.Name
+	service := destination.NotificationService.String()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.deliveries == nil {
+		t.deliveries = map[string][]sloDelivery{}
+		t.missed = map[string]time.Time{}
+	}
+	if _, ok := t.missed[delivery]; ok {
+		if err == nil {
+			delete(t.missed, delivery)
+		}
+		return
+	}
+	if err != nil {
+		if !exceeded {
+			return
+		}
+		t.missed[delivery] = now
+	}
+	good := err == nil && !exceeded
+	t.deliveries[service] = append(t.deliveries[service], sloDelivery{time: now, good: good})
+	result := "bad"
+	if good {
+		result = "good"
+	}
+	sloDeliveries.WithLabelValues(service, result).Inc()
+}
+
+// RunOnce evaluates the SLO of every NotificationService over its window and sets its condition
+func (t *SLOTracker) RunOnce(ctx context.Context) error {
+	notificationServices := &v1alpha1.NotificationServiceList{}
+	err := t.Client.List(ctx, notificationServices)
+	if err != nil {
+		return fmt.Errorf("Failed to list NotificationServices: %w", err)
+	}
+	now := time.Now()
+	var errs []error
+	tracked := map[string]bool{}
+	for i := range notificationServices.Items {
+		notificationService := &notificationServices.Items[i]
+		slo := notificationService.Spec.SLO
+		if slo == nil {
+			continue
+		}
+		service := client.ObjectKeyFromObject(notificationService).String()
+		tracked[service] = true
+		window := DefaultSLOWindow
+		if slo.Window != nil {
+			window = slo.Window.Duration
+		}
+		good, bad := t.count(service, now.Add(-window))
+		condition, burnRate, err := evaluateSLO(slo, good, bad)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Invalid SLO of NotificationService %s: %w", service, err))
+			continue
+		}
+		sloBurnRate.WithLabelValues(service).Set(burnRate)
+		condition.ObservedGeneration = notificationService.Generation
+		err = t.setCondition(ctx, notificationService, condition)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	t.prune(tracked, now)
+	return errors.Join(errs...)
+}
+
+// count returns the numbers of good and bad deliveries of the NotificationService since the time,
+// forgetting the older ones
+func (t *SLOTracker) count(service string, since time.Time) (int, int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	deliveries, ok := t.deliveries[service]
+	if !ok {
+		return 0, 0
+	}
+	for len(deliveries) > 0 && deliveries[0].time.Before(since) {
+		deliveries = deliveries[1:]
+	}
+	t.deliveries[service] = deliveries
+	good, bad := 0, 0
+	for _, delivery := range deliveries {
+		if delivery.good {
+			good++
+		} else {
+			bad++
+		}
+	}
+	return good, bad
+}
+
+// prune forgets the deliveries of the NotificationServices that no longer have an SLO, and the missed
+// notifications older than sloMissedRetention
+func (t *SLOTracker) prune(tracked map[string]bool, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for service := range t.deliveries {
+		if !tracked[service] {
+			delete(t.deliveries, service)
+			sloBurnRate.DeleteLabelValues(service)
+		}
+	}
+	for delivery, missed := range t.missed {
+		if now.Sub(missed) > sloMissedRetention {
+			delete(t.missed, delivery)
+		}
+	}
+}
+
+// evaluateSLO returns the DeliverySLOMet condition and the burn rate of the SLO given the numbers of
+// good and bad deliveries in its window. The burn rate is the ratio of bad deliveries to the error budget.
+// Return error if the objective is not a percentage
+func evaluateSLO(slo *v1alpha1.DeliverySLO, good int, bad int) (metav1.Condition, float64, error) {
+	objective, err := strconv.ParseFloat(slo.Objective, 64)
+	if err != nil || objective <= 0 || objective >= 100 {
+		return metav1.Condition{}, 0, fmt.Errorf("Objective %q is not a percentage between 0 and 100", slo.Objective)
+	}
+	total := good + bad
+	if total == 0 {
+		return metav1.Condition{
+			Type:    DeliverySLOMetCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  "NoDeliveries",
+			Message: "No notification was delivered in the window",
+		}, 0, nil
+	}
+	burnRate := float64(bad) * 100 / (float64(total) * (100 - objective))
+	condition := metav1.Condition{
+		Type:   DeliverySLOMetCondition,
+		Status: metav1.ConditionTrue,
+		Reason: "Met",
+	}
+	if burnRate > 1 {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "Violated"
+	}
+	condition.Message = fmt.Sprintf("%d of %d notifications delivered within %s, burn rate %.2f",
+		good, total, slo.Latency.Duration, burnRate)
+	return condition, burnRate, nil
+}
+
+// setCondition sets the DeliverySLOMet condition of the NotificationService
+func (t *SLOTracker) setCondition(ctx context.Context, notificationService *v1alpha1.NotificationService, condition metav1.Condition) error {
+	patch := client.MergeFrom(notificationService.DeepCopy())
+	if !meta.SetStatusCondition(&notificationService.Status.Conditions, condition) {
+		return nil
+	}
+	err := t.Client.Status().Patch(ctx, notificationService, patch)
+	if err != nil {
+		return fmt.Errorf("Failed to set the %s condition of NotificationService %s/%s: %w",
+			DeliverySLOMetCondition, notificationService.Namespace, notificationService.Name, err)
+	}
+	return nil
+}
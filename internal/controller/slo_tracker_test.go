@@ -0,0 +1,101 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/go-logr/logr"
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("SLO tracker", func() {
+	createSLONotificationService := func(name string) (*v1alpha1.NotificationService, DestinationNotifier) {
+		notificationService := &v1alpha1.NotificationService{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: v1alpha1.NotificationServiceSpec{
+				Destinations: []v1alpha1.Destination{{Name: "hook", Webhook: &v1alpha1.WebhookDestination{URL: "http://localhost"}}},
+				SLO:          &v1alpha1.DeliverySLO{Objective: "90", Latency: metav1.Duration{Duration: time.Minute}},
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), notificationService)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), notificationService)
+		destinations := GetNotificationServiceDestinations(context.Background(), k8sClient, logr.Discard(), notificationService)
+		Expect(destinations).To(HaveLen(1))
+		return notificationService, destinations[0]
+	}
+	completedAgo := func(ago time.Duration) *notifier.Notification {
+		completion := time.Now().Add(-ago)
+		return &notifier.Notification{CompletionTime: &completion}
+	}
+	getCondition := func(notificationService *v1alpha1.NotificationService) *metav1.Condition {
+		Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(notificationService), notificationService)).To(Succeed())
+		return meta.FindStatusCondition(notificationService.Status.Conditions, DeliverySLOMetCondition)
+	}
+
+	It("should report the SLO as violated once late deliveries burn the error budget", func() {
+		notificationService, destination := createSLONotificationService("slo-violated")
+		tracker := &SLOTracker{Client: k8sClient}
+		Expect(tracker.RunOnce(context.Background())).To(Succeed())
+		Expect(getCondition(notificationService)).To(HaveField("Reason", "NoDeliveries"))
+
+		for i := 0; i < 9; i++ {
+			tracker.Record(destination, string(rune('a'+i)), completedAgo(time.Second), nil)
+		}
+		tracker.Record(destination, "late", completedAgo(2*time.Minute), nil)
+		Expect(tracker.RunOnce(context.Background())).To(Succeed())
+		Expect(testutil.ToFloat64(sloBurnRate.WithLabelValues("default/slo-violated"))).To(BeNumerically("~", 1, 0.001))
+		condition := getCondition(notificationService)
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Message).To(Equal("9 of 10 notifications delivered within 1m0s, burn rate 1.00"))
+
+		tracker.Record(destination, "later", completedAgo(3*time.Minute), nil)
+		Expect(tracker.RunOnce(context.Background())).To(Succeed())
+		Expect(testutil.ToFloat64(sloBurnRate.WithLabelValues("default/slo-violated"))).To(BeNumerically(">", 1))
+		condition = getCondition(notificationService)
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal("Violated"))
+	})
+
+	It("should account for failed deliveries only once they exceed the latency", func() {
+		notificationService, destination := createSLONotificationService("slo-retried")
+		tracker := &SLOTracker{Client: k8sClient}
+		bad := testutil.ToFloat64(sloDeliveries.WithLabelValues("default/slo-retried", "bad"))
+		unavailable := errors.New("receiver is down")
+
+		tracker.Record(destination, "retried", completedAgo(time.Second), unavailable)
+		tracker.Record(destination, "retried", completedAgo(time.Second), nil)
+		tracker.Record(destination, "missed", completedAgo(2*time.Minute), unavailable)
+		tracker.Record(destination, "missed", completedAgo(2*time.Minute), unavailable)
+		tracker.Record(destination, "missed", completedAgo(2*time.Minute), nil)
+		tracker.Record(DestinationNotifier{Name: "default/other/hook"}, "untracked", completedAgo(time.Hour), nil)
+		Expect(testutil.ToFloat64(sloDeliveries.WithLabelValues("default/slo-retried", "bad"))).To(Equal(bad + 1))
+
+		Expect(tracker.RunOnce(context.Background())).To(Succeed())
+		Expect(getCondition(notificationService).Message).To(Equal("1 of 2 notifications delivered within 1m0s, burn rate 5.00"))
+	})
+})
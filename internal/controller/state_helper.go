@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// MaxStateAnnotationBytes bounds the size of the state annotations of pipelineruns, far below the 256KiB
+// limit of all annotations of an object. Larger state is stored in the NotificationState of the
+// pipelinerun and the annotation holds the sha256 hash of the state instead.
+var MaxStateAnnotationBytes = 16 << 10
+
+// stateReferencePrefix prefixes the state annotations holding the hash of state stored in a NotificationState
+const stateReferencePrefix string = "sha256:"
+
+// stateReference returns the compact encoding of the state, the sha256 hash it is stored under in a NotificationState
+func stateReference(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return stateReferencePrefix + hex.EncodeToString(sum[:])
+}
+
+func isStateReference(value string) bool {
+	return strings.HasPrefix(value, stateReferencePrefix)
+}
+
+// LoadPipelineRunState replaces the state annotations of the pipelineRun that hold the hash of state
+// stored in its NotificationState with that state, so they are read like inline annotations.
+// It must be called on pipelineruns read from the API before their state is read.
+// Return error if the NotificationState cannot be read or lacks the referenced state
+func LoadPipelineRunState(ctx context.Context, c client.Reader, pipelineRun *tektonv1.PipelineRun) error {
+	annotations := pipelineRun.GetAnnotations()
+	var state *v1alpha1.NotificationState
+	for _, annotation := range ownedPipelineRunAnnotations() {
+		reference := annotations[annotation]
+		if !isStateReference(reference) {
+			continue
+		}
+		if state == nil {
+			state = &v1alpha1.NotificationState{}
+			err := c.Get(ctx, client.ObjectKeyFromObject(pipelineRun), state)
+			if err != nil {
+				return fmt.Errorf("Failed to get notification state of pipelinerun %s: %w", pipelineRun.Name, err)
+			}
+		}
+		value, ok := state.Spec.Values[reference]
+		if !ok {
+			return fmt.Errorf("Notification state of pipelinerun %s lacks %s of annotation %s", pipelineRun.Name, reference, annotation)
+		}
+		annotations[annotation] = value
+	}
+	return nil
+}
+
+// NeedsStateMigration returns a boolean indicating whether state annotations of the pipelineRun exceed
+// MaxStateAnnotationBytes, e.g. because they were set by an earlier version of the controller, and have
+// to be moved to its NotificationState by applying them again
+func NeedsStateMigration(pipelineRun *tektonv1.PipelineRun) bool {
+	for _, annotation := range ownedPipelineRunAnnotations() {
+		if value := pipelineRun.GetAnnotations()[annotation]; len(value) > MaxStateAnnotationBytes && !isStateReference(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// storePipelineRunState moves the state annotations of applied exceeding MaxStateAnnotationBytes to the
+// NotificationState of the pipelineRun, created if needed, and replaces them with their hash.
+// It returns the moved state by annotation. The stored state that is neither moved nor still referenced
+// by the pipelineRun, in case applying fails, is pruned.
+// Return error if the NotificationState cannot be written
+func storePipelineRunState(ctx context.Context, c client.Client, pipelineRun *tektonv1.PipelineRun, applied *tektonv1.PipelineRun) (map[string]string, error) {
+	moved := map[string]string{}
+	for _, annotation := range ownedPipelineRunAnnotations() {
+		if value := applied.GetAnnotations()[annotation]; len(value) > MaxStateAnnotationBytes && !isStateReference(value) {
+			moved[annotation] = value
+		}
+	}
+	if len(moved) == 0 {
+		return nil, nil
+	}
+
+	state := &v1alpha1.NotificationState{}
+	err := c.Get(ctx, client.ObjectKeyFromObject(pipelineRun), state)
+	exists := err == nil
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return nil, fmt.Errorf("Failed to get notification state of pipelinerun %s: %w", pipelineRun.Name, err)
+	}
+	referenced := map[string]bool{}
+	for _, annotation := range ownedPipelineRunAnnotations() {
+		value := pipelineRun.GetAnnotations()[annotation]
+		if isStateReference(value) {
+			referenced[value] = true
+		} else if len(value) > MaxStateAnnotationBytes {
+			referenced[stateReference(value)] = true
+		}
+	}
+	values := map[string]string{}
+	for reference, value := range state.Spec.Values {
+		if referenced[reference] {
+			values[reference] = value
+		}
+	}
+	for annotation, value := range moved {
+		reference := stateReference(value)
+		values[reference] = value
+		applied.Annotations[annotation] = reference
+	}
+
+	state.Name = pipelineRun.Name
+	state.Namespace = pipelineRun.Namespace
+	state.OwnerReferences = pipelineRunOwnerReferences(pipelineRun)
+	state.Spec = v1alpha1.NotificationStateSpec{PipelineRun: pipelineRun.Name, Values: values}
+	if exists {
+		err = c.Update(ctx, state)
+	} else {
+		err = c.Create(ctx, state)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to store notification state of pipelinerun %s: %w", pipelineRun.Name, err)
+	}
+	return moved, nil
+}
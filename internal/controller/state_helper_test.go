@@ -0,0 +1,104 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Notification state", func() {
+	delivered := func(count int) map[string]time.Time {
+		destinations := map[string]time.Time{}
+		for i := 0; i < count; i++ {
+			destinations[fmt.Sprintf("default/notificationservice/destination-%d", i)] = time.Unix(1700000000, 0).UTC()
+		}
+		return destinations
+	}
+
+	getState := func(pipelineRun *tektonv1.PipelineRun) *v1alpha1.NotificationState {
+		state := &v1alpha1.NotificationState{}
+		Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(pipelineRun), state)).To(Succeed())
+		return state
+	}
+
+	BeforeEach(func() {
+		previous := MaxStateAnnotationBytes
+		MaxStateAnnotationBytes = 256
+		DeferCleanup(func() { MaxStateAnnotationBytes = previous })
+	})
+
+	It("should keep small state in annotations", func() {
+		pipelineRun := createPipelineRun("small-state", "")
+		Expect(SetDeliveredDestinations(context.Background(), pipelineRun, k8sClient, delivered(1))).To(Succeed())
+		Expect(getPipelineRun(pipelineRun).Annotations[NotificationDeliveredAnnotation]).To(HavePrefix("{"))
+		Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(pipelineRun), &v1alpha1.NotificationState{})).NotTo(Succeed())
+	})
+
+	It("should store large state in a companion NotificationState", func() {
+		pipelineRun := createPipelineRun("large-state", "")
+		Expect(SetDeliveredDestinations(context.Background(), pipelineRun, k8sClient, delivered(10))).To(Succeed())
+		inMemory, err := GetDeliveredDestinations(pipelineRun)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(inMemory).To(HaveLen(10))
+
+		stored := getPipelineRun(pipelineRun)
+		Expect(stored.Annotations[NotificationDeliveredAnnotation]).To(HavePrefix("sha256:"))
+		state := getState(pipelineRun)
+		Expect(state.OwnerReferences).To(HaveLen(1))
+		Expect(state.OwnerReferences[0].UID).To(Equal(pipelineRun.UID))
+		Expect(LoadPipelineRunState(context.Background(), k8sClient, stored)).To(Succeed())
+		loaded, err := GetDeliveredDestinations(stored)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(loaded).To(Equal(delivered(10)))
+
+		Expect(SetDeliveredDestinations(context.Background(), stored, k8sClient, delivered(11))).To(Succeed())
+		Expect(SetDeliveredDestinations(context.Background(), stored, k8sClient, delivered(12))).To(Succeed())
+		Expect(getState(pipelineRun).Spec.Values).To(HaveLen(2))
+		stored = getPipelineRun(pipelineRun)
+		Expect(LoadPipelineRunState(context.Background(), k8sClient, stored)).To(Succeed())
+		Expect(GetDeliveredDestinations(stored)).To(HaveLen(12))
+	})
+
+	It("should fail to load state missing from the NotificationState", func() {
+		pipelineRun := createPipelineRun("missing-state", "")
+		pipelineRun.Annotations = map[string]string{NotificationDeliveredAnnotation: stateReference("{}")}
+		Expect(LoadPipelineRunState(context.Background(), k8sClient, pipelineRun)).To(MatchError(ContainSubstring("Failed to get notification state")))
+	})
+
+	It("should migrate large annotations set by earlier versions", func() {
+		pipelineRun := createPipelineRun("legacy-state", "")
+		encoded := fmt.Sprintf(`{"default/lifecycle/%0300d":"Started"}`, 0)
+		pipelineRun.Annotations = map[string]string{NotificationLifecycleAnnotation: encoded}
+		Expect(k8sClient.Update(context.Background(), pipelineRun)).To(Succeed())
+		Expect(NeedsStateMigration(pipelineRun)).To(BeTrue())
+
+		r := &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Notifier: &fakeNotifier{}}
+		Expect(reconcilePipelineRun(r, pipelineRun)).To(Succeed())
+		stored := getPipelineRun(pipelineRun)
+		Expect(stored.Annotations[NotificationLifecycleAnnotation]).To(Equal(stateReference(encoded)))
+		Expect(getState(pipelineRun).Spec.Values).To(HaveKeyWithValue(stateReference(encoded), encoded))
+	})
+})
@@ -0,0 +1,251 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ReadyCondition reports whether the notifications of a NotificationService are delivered, summarizing its
+// other conditions
+const ReadyCondition string = "Ready"
+
+// DefaultStatusSummaryInterval is how often the status of NotificationServices is summarized, unless
+// configured otherwise
+const DefaultStatusSummaryInterval = time.Minute
+
+// deliveryHours is the number of hourly buckets deliveries are counted in
+const deliveryHours = 24
+
+// readinessConditions are the conditions making a NotificationService not ready while they have the status,
+// with the reason of its Ready condition
+var readinessConditions = []struct {
+	conditionType string
+	status        metav1.ConditionStatus
+	reason        string
+}{
+	{DuplicateControllerCondition, metav1.ConditionTrue, "DuplicateController"},
+	{ResourceWatchedCondition, metav1.ConditionFalse, "ResourceNotWatched"},
+	{DestinationsReachableCondition, metav1.ConditionFalse, "DestinationsUnreachable"},
+	{DeliverySLOMetCondition, metav1.ConditionFalse, "DeliverySLOViolated"},
+}
+
+// deliveryCounts counts the deliveries to the destinations of a NotificationService by hour
+type deliveryCounts struct {
+	// hours are the hours since the epoch each bucket counts the deliveries of
+	hours     [deliveryHours]int64
+	failed    [deliveryHours]int32
+	delivered [deliveryHours]int32
+	// lastDelivery is when a notification was last delivered successfully
+	lastDelivery time.Time
+}
+
+// StatusSummarizer counts the deliveries to the destinations of the NotificationServices and periodically
+// sets their summary in their status, along with their Ready condition, so `kubectl get notificationservices`
+// shows whether they deliver. Deliveries are counted in hourly buckets kept in memory, so the size of the summary
+// does not depend on the number of deliveries, and a restart of the controller starts the counts over.
+type StatusSummarizer struct {
+	Client client.Client
+	Log    logr.Logger
+	// Interval is how often the status is summarized
+	Interval time.Duration
+
+	mu     sync.Mutex
+	counts map[string]*deliveryCounts
+}
+
+// NeedLeaderElection returns true since only the leader delivers notifications
+func (s *StatusSummarizer) NeedLeaderElection() bool {
+	return true
+}
+
+// Start summarizes the status of the NotificationServices every interval until the context is cancelled
+func (s *StatusSummarizer) Start(ctx context.Context) error {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		err := s.RunOnce(ctx)
+		if err != nil {
+			s.Log.Error(err, "Failed to summarize the status of NotificationServices")
+		}
+	}
+}
+
+// Record counts the outcome of a delivery to the destination, if it is declared by a NotificationService
+func (s *StatusSummarizer) Record(destination DestinationNotifier, err error) {
+	if destination.NotificationService.Name == "" {
+		return
+	}
+	s.record(destination.NotificationService.String(), time.Now(), err == nil)
+}
+
+// record counts a delivery to a destination of the NotificationService at the time
+func (s *StatusSummarizer) record(service string, now time.Time, delivered bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.counts == nil {
+		s.counts = map[string]*deliveryCounts{}
+	}
+	counts, ok := s.counts[service]
+	if !ok {
+		counts = &deliveryCounts{}
+		s.counts[service] = counts
+	}
+	hour := now.Unix() / 3600
+	bucket := hour % deliveryHours
+	if counts.hours[bucket] != hour {
+		counts.hours[bucket] = hour
+		counts.failed[bucket] = 0
+		counts.delivered[bucket] = 0
+	}
+	if delivered {
+		counts.delivered[bucket]++
+		counts.lastDelivery = now
+	} else {
+		counts.failed[bucket]++
+	}
+}
+
+// summary returns the delivery summary of the NotificationService at the time
+func (s *StatusSummarizer) summary(notificationService *v1alpha1.NotificationService, now time.Time) *v1alpha1.DeliverySummary {
+	summary := &v1alpha1.DeliverySummary{Destinations: int32(len(notificationService.Spec.Destinations))}
+	if previous := notificationService.Status.Deliveries; previous != nil {
+		summary.LastDeliveryTime = previous.LastDeliveryTime
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts, ok := s.counts[client.ObjectKeyFromObject(notificationService).String()]
+	if !ok {
+		return summary
+	}
+	hour := now.Unix() / 3600
+	for bucket := range counts.hours {
+		if hour-counts.hours[bucket] < deliveryHours {
+			summary.FailedLast24h += counts.failed[bucket]
+			summary.DeliveredLast24h += counts.delivered[bucket]
+		}
+	}
+	if !counts.lastDelivery.IsZero() {
+		summary.LastDeliveryTime = &metav1.Time{Time: counts.lastDelivery.UTC().Truncate(time.Second)}
+	}
+	return summary
+}
+
+// RunOnce sets the delivery summary and the Ready condition of every NotificationService
+func (s *StatusSummarizer) RunOnce(ctx context.Context) error {
+	notificationServices := &v1alpha1.NotificationServiceList{}
+	err := s.Client.List(ctx, notificationServices)
+	if err != nil {
+		return fmt.Errorf("Failed to list NotificationServices: %w", err)
+	}
+	now := time.Now()
+	var errs []error
+	tracked := map[string]bool{}
+	for i := range notificationServices.Items {
+		notificationService := &notificationServices.Items[i]
+		tracked[client.ObjectKeyFromObject(notificationService).String()] = true
+		patch := client.MergeFrom(notificationService.DeepCopy())
+		summary := s.summary(notificationService, now)
+		changed := !equality.Semantic.DeepEqual(summary, notificationService.Status.Deliveries)
+		notificationService.Status.Deliveries = summary
+		condition := readyCondition(notificationService)
+		condition.ObservedGeneration = notificationService.Generation
+		if meta.SetStatusCondition(&notificationService.Status.Conditions, condition) {
+			changed = true
+		}
+		if !changed {
+			continue
+		}
+		err = s.Client.Status().Patch(ctx, notificationService, patch)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Failed to summarize the status of NotificationService %s/%s: %w",
+				notificationService.Namespace, notificationService.Name, err))
+		}
+	}
+	s.prune(tracked)
+	return errors.Join(errs...)
+}
+
+// prune forgets the deliveries of the NotificationServices that no longer exist
+func (s *StatusSummarizer) prune(tracked map[string]bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for service := range s.counts {
+		if !tracked[service] {
+			delete(s.counts, service)
+		}
+	}
+}
+
+// readyCondition returns the Ready condition of the NotificationService, which is false while it is paused,
+// one of its readinessConditions has the status making it not ready or one of its destinations backs off
+func readyCondition(notificationService *v1alpha1.NotificationService) metav1.Condition {
+	if notificationService.Spec.Paused {
+		return metav1.Condition{
+			Type:    ReadyCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  "Paused",
+			Message: "Notifications are not sent while the NotificationService is paused",
+		}
+	}
+	for _, readiness := range readinessConditions {
+		condition := meta.FindStatusCondition(notificationService.Status.Conditions, readiness.conditionType)
+		if condition != nil && condition.Status == readiness.status {
+			return metav1.Condition{
+				Type:    ReadyCondition,
+				Status:  metav1.ConditionFalse,
+				Reason:  readiness.reason,
+				Message: condition.Message,
+			}
+		}
+	}
+	if len(notificationService.Status.Backoffs) > 0 {
+		names := make([]string, 0, len(notificationService.Status.Backoffs))
+		for _, backoff := range notificationService.Status.Backoffs {
+			names = append(names, backoff.Name)
+		}
+		return metav1.Condition{
+			Type:    ReadyCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  "DestinationsBackingOff",
+			Message: "Notifications are not sent to " + strings.Join(names, ", ") + " until their backoff ends",
+		}
+	}
+	return metav1.Condition{
+		Type:    ReadyCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Ready",
+		Message: "Notifications are sent to the destinations",
+	}
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/go-logr/logr"
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Status summarizer", func() {
+	createSummarizedNotificationService := func(name string, paused bool) *v1alpha1.NotificationService {
+		notificationService := &v1alpha1.NotificationService{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: v1alpha1.NotificationServiceSpec{
+				Destinations: []v1alpha1.Destination{
+					{Name: "hook", Webhook: &v1alpha1.WebhookDestination{URL: "http://localhost"}},
+					{Name: "other", Webhook: &v1alpha1.WebhookDestination{URL: "http://localhost/other"}},
+				},
+				Paused: paused,
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), notificationService)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), notificationService)
+		return notificationService
+	}
+	getReady := func(notificationService *v1alpha1.NotificationService) *metav1.Condition {
+		Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(notificationService), notificationService)).To(Succeed())
+		return meta.FindStatusCondition(notificationService.Status.Conditions, ReadyCondition)
+	}
+
+	It("should summarize the deliveries of the last 24 hours", func() {
+		notificationService := createSummarizedNotificationService("summarized", false)
+		destinations := GetNotificationServiceDestinations(context.Background(), k8sClient, logr.Discard(), notificationService)
+		Expect(destinations).To(HaveLen(2))
+		summarizer := &StatusSummarizer{Client: k8sClient}
+		Expect(summarizer.RunOnce(context.Background())).To(Succeed())
+		Expect(getReady(notificationService)).To(HaveField("Status", metav1.ConditionTrue))
+		Expect(notificationService.Status.Deliveries).To(Equal(&v1alpha1.DeliverySummary{Destinations: 2}))
+
+		summarizer.record("default/summarized", time.Now().Add(-25*time.Hour), false)
+		summarizer.Record(destinations[0], nil)
+		summarizer.Record(destinations[1], nil)
+		summarizer.Record(destinations[1], errors.New("receiver is down"))
+		summarizer.Record(DestinationNotifier{Name: "default-destination"}, errors.New("receiver is down"))
+		Expect(summarizer.RunOnce(context.Background())).To(Succeed())
+		Expect(getReady(notificationService)).NotTo(BeNil())
+		deliveries := notificationService.Status.Deliveries
+		Expect(deliveries.Destinations).To(Equal(int32(2)))
+		Expect(deliveries.DeliveredLast24h).To(Equal(int32(2)))
+		Expect(deliveries.FailedLast24h).To(Equal(int32(1)))
+		Expect(deliveries.LastDeliveryTime).NotTo(BeNil())
+		Expect(deliveries.LastDeliveryTime.Time).To(BeTemporally("~", time.Now(), 2*time.Second))
+
+		restarted := &StatusSummarizer{Client: k8sClient}
+		Expect(restarted.RunOnce(context.Background())).To(Succeed())
+		Expect(getReady(notificationService)).NotTo(BeNil())
+		Expect(notificationService.Status.Deliveries.FailedLast24h).To(BeZero())
+		Expect(notificationService.Status.Deliveries.LastDeliveryTime).To(Equal(deliveries.LastDeliveryTime))
+	})
+
+	It("should not be ready while paused or while a condition fails", func() {
+		paused := createSummarizedNotificationService("summarized-paused", true)
+		unreachable := createSummarizedNotificationService("summarized-unreachable", false)
+		patch := client.MergeFrom(unreachable.DeepCopy())
+		meta.SetStatusCondition(&unreachable.Status.Conditions, metav1.Condition{
+			Type:    DestinationsReachableCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  "Unreachable",
+			Message: "Destination hook is unreachable",
+		})
+		Expect(k8sClient.Status().Patch(context.Background(), unreachable, patch)).To(Succeed())
+
+		summarizer := &StatusSummarizer{Client: k8sClient}
+		Expect(summarizer.RunOnce(context.Background())).To(Succeed())
+		Expect(getReady(paused)).To(HaveField("Reason", "Paused"))
+		ready := getReady(unreachable)
+		Expect(ready.Status).To(Equal(metav1.ConditionFalse))
+		Expect(ready.Reason).To(Equal("DestinationsUnreachable"))
+		Expect(ready.Message).To(Equal("Destination hook is unreachable"))
+	})
+})
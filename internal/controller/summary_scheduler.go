@@ -0,0 +1,245 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	"github.com/konflux-ci/notification-service/pkg/schedule"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DefaultSummaryPeriod is the time span covered by summaries that do not set a period
+const DefaultSummaryPeriod = 7 * 24 * time.Hour
+
+// DefaultSummaryInterval is how often the SummaryScheduler checks for summaries that are due
+const DefaultSummaryInterval = time.Minute
+
+// SummaryTopPipelines is the number of pipelines listed as slowest and as top failures in a summary
+const SummaryTopPipelines = 5
+
+// unknownPipeline groups the PipelineRuns with an embedded pipeline spec in summaries
+const unknownPipeline = "<embedded>"
+
+// SummaryScheduler sends the periodic summary reports of NotificationServices
+type SummaryScheduler struct {
+	Client client.Client
+	Log    logr.Logger
+	// Interval is how often the scheduler checks for summaries that are due
+	Interval time.Duration
+}
+
+// NeedLeaderElection returns true so summaries are sent by a single replica
+func (s *SummaryScheduler) NeedLeaderElection() bool {
+	return true
+}
+
+// Start sends the summaries that are due every interval until the context is cancelled
+func (s *SummaryScheduler) Start(ctx context.Context) error {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = DefaultSummaryInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			err := s.RunOnce(ctx, now)
+			if err != nil {
+				s.Log.Error(err, "Failed to send summaries")
+			}
+		}
+	}
+}
+
+// RunOnce sends the summaries of all NotificationServices whose schedule is due at now.
+// A NotificationService whose summary failed is tried again at the next interval.
+func (s *SummaryScheduler) RunOnce(ctx context.Context, now time.Time) error {
+	notificationServices := &v1alpha1.NotificationServiceList{}
+	err := s.Client.List(ctx, notificationServices)
+	if err != nil {
+		return fmt.Errorf("Failed to list NotificationServices: %w", err)
+	}
+	var errs []error
+	for i := range notificationServices.Items {
+		notificationService := &notificationServices.Items[i]
+		if notificationService.Spec.Summary == nil || notificationService.Spec.Paused {
+			continue
+		}
+		summaries, err := ParseSummarySchedule(notificationService.Spec.Summary)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Invalid summary schedule of NotificationService %s/%s: %w",
+				notificationService.Namespace, notificationService.Name, err))
+			continue
+		}
+		last := notificationService.CreationTimestamp.Time
+		if notificationService.Status.LastSummaryTime != nil {
+			last = notificationService.Status.LastSummaryTime.Time
+		}
+		if summaries.Next(last).After(now) {
+			continue
+		}
+		err = s.sendSummaries(ctx, notificationService, now)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		notificationService.Status.LastSummaryTime = &metav1.Time{Time: now}
+		err = s.Client.Status().Update(ctx, notificationService)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Failed to update the last summary time of NotificationService %s/%s: %w",
+				notificationService.Namespace, notificationService.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ParseSummarySchedule returns the schedule of the summary reports, in the time zone of the controller
+// unless the summary sets one
+// Return error if the schedule or its time zone are not valid
+func ParseSummarySchedule(summary *v1alpha1.SummarySpec) (*schedule.Schedule, error) {
+	location, err := schedule.LoadLocation(summary.TimeZone, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	return schedule.Parse(summary.Schedule, location)
+}
+
+// CheckSummaryNamespaces returns an error if the summary of the NotificationService lists other namespaces than
+// its own, so tenants cannot have the summaries of the PipelineRuns of other tenants sent to their destinations
+func CheckSummaryNamespaces(notificationService *v1alpha1.NotificationService) error {
+	if notificationService.Spec.Summary == nil {
+		return nil
+	}
+	for _, namespace := range notificationService.Spec.Summary.Namespaces {
+		if namespace != notificationService.Namespace {
+			return fmt.Errorf("Summary of NotificationService %s/%s may not cover namespace %s",
+				notificationService.Namespace, notificationService.Name, namespace)
+		}
+	}
+	return nil
+}
+
+// sendSummaries compiles the summary of the namespace of the NotificationService and sends it to the
+// destinations that support summaries. Other namespaces listed in the summary are reported in the log.
+func (s *SummaryScheduler) sendSummaries(ctx context.Context, notificationService *v1alpha1.NotificationService, now time.Time) error {
+	period := DefaultSummaryPeriod
+	if notificationService.Spec.Summary.Period != nil {
+		period = notificationService.Spec.Summary.Period.Duration
+	}
+	if err := CheckSummaryNamespaces(notificationService); err != nil {
+		s.Log.Error(err, "Skipping the other namespaces of the summary")
+	}
+	destinations := GetNotificationServiceDestinations(ctx, s.Client, s.Log, notificationService)
+
+	summary, err := CompileSummary(ctx, s.Client, notificationService.Namespace, now.Add(-period), now)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, destination := range destinations {
+		summaryNotifier, ok := destination.Notifier.(notifier.SummaryNotifier)
+		if !ok {
+			continue
+		}
+		err = summaryNotifier.NotifySummary(ctx, summary)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Failed to send summary to %s: %w", destination.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CompileSummary summarizes the PipelineRuns of the namespace that completed between from and to
+// Return error if failed to list the PipelineRuns
+func CompileSummary(ctx context.Context, c client.Reader, namespace string, from time.Time, to time.Time) (*notifier.Summary, error) {
+	pipelineRuns := &tektonv1.PipelineRunList{}
+	err := c.List(ctx, pipelineRuns, client.InNamespace(namespace))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list pipelineruns in %s: %w", namespace, err)
+	}
+
+	summary := &notifier.Summary{Namespace: namespace, From: from, To: to}
+	stats := map[string]*notifier.PipelineStats{}
+	durations := map[string]float64{}
+	for i := range pipelineRuns.Items {
+		pipelineRun := &pipelineRuns.Items[i]
+		completion := pipelineRun.Status.CompletionTime
+		if !IsPipelineRunEnded(pipelineRun) || completion == nil || completion.Time.Before(from) || completion.Time.After(to) {
+			continue
+		}
+		pipeline := PipelineName(pipelineRun)
+		if pipeline == "" {
+			pipeline = unknownPipeline
+		}
+		pipelineStats, ok := stats[pipeline]
+		if !ok {
+			pipelineStats = &notifier.PipelineStats{Pipeline: pipeline}
+			stats[pipeline] = pipelineStats
+		}
+		summary.Total++
+		pipelineStats.Runs++
+		if IsPipelineRunEndedSuccessfully(pipelineRun) {
+			summary.Succeeded++
+		} else {
+			pipelineStats.Failures++
+		}
+		if pipelineRun.Status.StartTime != nil {
+			durations[pipeline] += completion.Sub(pipelineRun.Status.StartTime.Time).Seconds()
+		}
+	}
+	if summary.Total > 0 {
+		summary.SuccessRate = float64(summary.Succeeded) / float64(summary.Total)
+	}
+
+	all := make([]notifier.PipelineStats, 0, len(stats))
+	for pipeline, pipelineStats := range stats {
+		pipelineStats.AverageDurationSeconds = durations[pipeline] / float64(pipelineStats.Runs)
+		all = append(all, *pipelineStats)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].AverageDurationSeconds != all[j].AverageDurationSeconds {
+			return all[i].AverageDurationSeconds > all[j].AverageDurationSeconds
+		}
+		return all[i].Pipeline < all[j].Pipeline
+	})
+	summary.Slowest = append([]notifier.PipelineStats{}, all[:min(len(all), SummaryTopPipelines)]...)
+
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].Failures > all[j].Failures
+	})
+	summary.TopFailures = []notifier.PipelineStats{}
+	for _, pipelineStats := range all {
+		if pipelineStats.Failures == 0 || len(summary.TopFailures) == SummaryTopPipelines {
+			break
+		}
+		summary.TopFailures = append(summary.TopFailures, pipelineStats)
+	}
+	return summary, nil
+}
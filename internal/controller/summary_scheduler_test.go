@@ -0,0 +1,153 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Summary scheduler", func() {
+	const namespace = "summaries"
+	now := time.Date(2024, 5, 8, 9, 0, 0, 0, time.UTC)
+
+	// createCompletedPipelineRun creates a pipelinerun of the pipeline that completed at the given time
+	createCompletedPipelineRun := func(name string, pipeline string, succeeded corev1.ConditionStatus,
+		completion time.Time, duration time.Duration) {
+		pipelineRun := &tektonv1.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec:       tektonv1.PipelineRunSpec{PipelineRef: &tektonv1.PipelineRef{Name: pipeline}},
+		}
+		Expect(k8sClient.Create(context.Background(), pipelineRun)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), pipelineRun)
+		pipelineRun.Status.Conditions = duckv1.Conditions{{Type: apis.ConditionSucceeded, Status: succeeded}}
+		pipelineRun.Status.StartTime = &metav1.Time{Time: completion.Add(-duration)}
+		pipelineRun.Status.CompletionTime = &metav1.Time{Time: completion}
+		Expect(k8sClient.Status().Update(context.Background(), pipelineRun)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+		err := k8sClient.Create(context.Background(), ns)
+		if err != nil {
+			Expect(client.IgnoreAlreadyExists(err)).To(Succeed())
+		}
+		createCompletedPipelineRun("build-1", "build", corev1.ConditionTrue, now.Add(-time.Hour), 10*time.Minute)
+		createCompletedPipelineRun("build-2", "build", corev1.ConditionFalse, now.Add(-2*time.Hour), 20*time.Minute)
+		createCompletedPipelineRun("test-1", "test", corev1.ConditionTrue, now.Add(-3*time.Hour), time.Minute)
+		createCompletedPipelineRun("old-1", "build", corev1.ConditionFalse, now.Add(-30*24*time.Hour), time.Minute)
+	})
+
+	It("should summarize the pipelineruns of the period", func() {
+		summary, err := CompileSummary(context.Background(), k8sClient, namespace, now.Add(-DefaultSummaryPeriod), now)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(summary.Total).To(Equal(3))
+		Expect(summary.Succeeded).To(Equal(2))
+		Expect(summary.SuccessRate).To(BeNumerically("~", 2.0/3.0))
+		Expect(summary.Slowest).To(Equal([]notifier.PipelineStats{
+			{Pipeline: "build", Runs: 2, Failures: 1, AverageDurationSeconds: 900},
+			{Pipeline: "test", Runs: 1, AverageDurationSeconds: 60},
+		}))
+		Expect(summary.TopFailures).To(Equal([]notifier.PipelineStats{
+			{Pipeline: "build", Runs: 2, Failures: 1, AverageDurationSeconds: 900},
+		}))
+	})
+
+	It("should schedule summaries in their time zone", func() {
+		summaries, err := ParseSummarySchedule(&v1alpha1.SummarySpec{Schedule: "0 9 * * *", TimeZone: "Asia/Tokyo"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(summaries.Next(now)).To(BeTemporally("==", now.Add(15*time.Hour)))
+
+		_, err = ParseSummarySchedule(&v1alpha1.SummarySpec{Schedule: "0 9 * * *", TimeZone: "Asia/Nowhere"})
+		Expect(err).To(MatchError(ContainSubstring("Unknown time zone Asia/Nowhere")))
+	})
+
+	It("should send summaries when they are due", func() {
+		var received []notifier.Summary
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			summary := notifier.Summary{}
+			Expect(json.NewDecoder(req.Body).Decode(&summary)).To(Succeed())
+			received = append(received, summary)
+		}))
+		DeferCleanup(receiver.Close)
+		notificationService := &v1alpha1.NotificationService{
+			ObjectMeta: metav1.ObjectMeta{Name: "weekly", Namespace: namespace},
+			Spec: v1alpha1.NotificationServiceSpec{
+				Destinations: []v1alpha1.Destination{{Name: "hook", Webhook: &v1alpha1.WebhookDestination{URL: receiver.URL}}},
+				Summary:      &v1alpha1.SummarySpec{Schedule: "0 9 * * *"},
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), notificationService)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), notificationService)
+		notificationService.Status.LastSummaryTime = &metav1.Time{Time: now.Add(-24 * time.Hour)}
+		Expect(k8sClient.Status().Update(context.Background(), notificationService)).To(Succeed())
+
+		scheduler := &SummaryScheduler{Client: k8sClient}
+		Expect(scheduler.RunOnce(context.Background(), now.Add(-time.Minute))).To(Succeed())
+		Expect(received).To(BeEmpty())
+
+		Expect(scheduler.RunOnce(context.Background(), now)).To(Succeed())
+		Expect(received).To(HaveLen(1))
+		Expect(received[0].Namespace).To(Equal(namespace))
+		Expect(received[0].Total).To(Equal(3))
+
+		Expect(scheduler.RunOnce(context.Background(), now.Add(time.Minute))).To(Succeed())
+		Expect(received).To(HaveLen(1))
+	})
+	It("should only summarize the namespace of the NotificationService", func() {
+		var received []notifier.Summary
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			summary := notifier.Summary{}
+			Expect(json.NewDecoder(req.Body).Decode(&summary)).To(Succeed())
+			received = append(received, summary)
+		}))
+		DeferCleanup(receiver.Close)
+		notificationService := &v1alpha1.NotificationService{
+			ObjectMeta: metav1.ObjectMeta{Name: "other-tenant", Namespace: "default"},
+			Spec: v1alpha1.NotificationServiceSpec{
+				Destinations: []v1alpha1.Destination{{Name: "hook", Webhook: &v1alpha1.WebhookDestination{URL: receiver.URL}}},
+				Summary:      &v1alpha1.SummarySpec{Schedule: "0 9 * * *", Namespaces: []string{namespace}},
+			},
+		}
+		_, err := (&AdmissionValidator{Client: k8sClient}).ValidateCreate(context.Background(), notificationService)
+		Expect(err).To(MatchError(ContainSubstring("may not cover namespace " + namespace)))
+
+		Expect(k8sClient.Create(context.Background(), notificationService)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), notificationService)
+		notificationService.Status.LastSummaryTime = &metav1.Time{Time: now.Add(-24 * time.Hour)}
+		Expect(k8sClient.Status().Update(context.Background(), notificationService)).To(Succeed())
+
+		Expect((&SummaryScheduler{Client: k8sClient}).RunOnce(context.Background(), now)).To(Succeed())
+		Expect(received).To(HaveLen(1))
+		Expect(received[0].Namespace).To(Equal("default"))
+	})
+})
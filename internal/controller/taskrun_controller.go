@@ -0,0 +1,99 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"knative.dev/pkg/apis"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// TaskRunReconciler notifies about the end of standalone TaskRuns, which do not belong to a pipelinerun,
+// with the same lifecycle and destinations as CustomRuns. The TaskRuns of pipelineruns are part of the
+// notification about their pipelinerun and are ignored.
+// Their notifications have the KindTaskRun kind, the name of the TaskRun as PipelineRun, and its
+// status, timing and results.
+type TaskRunReconciler struct {
+	// Reconciler reads the TaskRuns and runs the finalizer and notification lifecycle of the
+	// standalone ones through reconcileRun
+	Reconciler *NotificationServiceReconciler
+}
+
+// +kubebuilder:rbac:groups=tekton.dev,resources=taskruns,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups=tekton.dev,resources=taskruns/finalizers,verbs=update
+
+// Reconcile adds the finalizer to running standalone TaskRuns and, once they are done, sends their notification,
+// marks them as notified and removes the finalizer
+func (r *TaskRunReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	taskRun := &tektonv1.TaskRun{}
+	err := r.Reconciler.Get(ctx, req.NamespacedName, taskRun)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		r.Reconciler.Log.Error(err, "Failed to get taskrun", "taskrun", req.NamespacedName)
+		return ctrl.Result{}, err
+	}
+	if !IsStandaloneTaskRun(taskRun) {
+		return ctrl.Result{}, nil
+	}
+	return r.Reconciler.reconcileRun(ctx, taskRun, tektonv1.SchemeGroupVersion.WithKind("TaskRun"), taskRun.IsDone(),
+		func() *notifier.Notification { return GetNotificationFromTaskRun(taskRun, time.Now()) })
+}
+
+// IsStandaloneTaskRun returns a boolean indicating whether the taskRun does not belong to a pipelinerun
+func IsStandaloneTaskRun(taskRun client.Object) bool {
+	_, ok := taskRun.GetLabels()[pipeline.PipelineRunLabelKey]
+	return !ok
+}
+
+// GetNotificationFromTaskRun builds the notification that is sent for the taskRun.
+// Durations of TaskRuns that did not complete yet are measured up to now.
+func GetNotificationFromTaskRun(taskRun *tektonv1.TaskRun, now time.Time) *notifier.Notification {
+	condition := taskRun.Status.GetCondition(apis.ConditionSucceeded)
+	results := make([]notifier.Result, 0, len(taskRun.Status.Results))
+	for _, result := range taskRun.Status.Results {
+		results = append(results, getResult(result.Name, result.Value))
+	}
+	notification := &notifier.Notification{
+		PipelineRun: taskRun.Name,
+		Namespace:   taskRun.Namespace,
+		Kind:        notifier.KindTaskRun,
+		Status:      getConditionStatus(condition),
+		Event:       getConditionEvent(condition, tektonv1.TaskRunReasonCancelled.String()),
+		Results:     results,
+	}
+	notification.StartTime, notification.CompletionTime, notification.DurationSeconds =
+		getTiming(taskRun.Status.StartTime, taskRun.Status.CompletionTime, now)
+	return notification
+}
+
+// SetupWithManager sets up the controller of standalone TaskRuns with the Manager
+func (r *TaskRunReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&tektonv1.TaskRun{}).
+		WithEventFilter(predicate.NewPredicateFuncs(IsStandaloneTaskRun)).
+		Complete(r)
+}
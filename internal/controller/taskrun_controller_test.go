@@ -0,0 +1,93 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("TaskRun controller", func() {
+	createTaskRun := func(name string, labels map[string]string) *tektonv1.TaskRun {
+		taskRun := &tektonv1.TaskRun{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels},
+			Spec:       tektonv1.TaskRunSpec{TaskRef: &tektonv1.TaskRef{Name: "git-clone"}},
+		}
+		Expect(k8sClient.Create(context.Background(), taskRun)).To(Succeed())
+		DeferCleanup(func() {
+			tr := &tektonv1.TaskRun{}
+			if k8sClient.Get(context.Background(), client.ObjectKeyFromObject(taskRun), tr) == nil {
+				tr.Finalizers = nil
+				_ = k8sClient.Update(context.Background(), tr)
+				_ = k8sClient.Delete(context.Background(), tr)
+			}
+		})
+		return taskRun
+	}
+
+	reconcileTaskRun := func(r *TaskRunReconciler, taskRun *tektonv1.TaskRun) *tektonv1.TaskRun {
+		_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(taskRun)})
+		Expect(err).NotTo(HaveOccurred())
+		reconciled := &tektonv1.TaskRun{}
+		Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(taskRun), reconciled)).To(Succeed())
+		return reconciled
+	}
+
+	It("should hold standalone taskruns until they are notified about", func() {
+		fake := &fakeNotifier{}
+		r := &TaskRunReconciler{Reconciler: &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Notifier: fake}}
+		taskRun := createTaskRun("taskrun-standalone", nil)
+
+		reconciled := reconcileTaskRun(r, taskRun)
+		Expect(reconciled.Finalizers).To(ContainElement(NotificationPipelineRunFinalizer))
+		Expect(fake.notifications).To(BeEmpty())
+
+		reconciled.Status.Conditions = duckv1.Conditions{{Type: apis.ConditionSucceeded, Status: corev1.ConditionFalse, Reason: "Failed"}}
+		reconciled.Status.Results = []tektonv1.TaskRunResult{{Name: "commit", Type: tektonv1.ResultsTypeString, Value: *tektonv1.NewStructuredValues("abc123")}}
+		Expect(k8sClient.Status().Update(context.Background(), reconciled)).To(Succeed())
+		reconciled = reconcileTaskRun(r, taskRun)
+		Expect(reconciled.Finalizers).NotTo(ContainElement(NotificationPipelineRunFinalizer))
+		Expect(reconciled.Annotations).To(HaveKeyWithValue(NotificationPipelineRunAnnotation, NotificationPipelineRunAnnotationValue))
+		Expect(fake.notifications).To(HaveLen(1))
+		Expect(fake.notifications[0].Kind).To(Equal(notifier.KindTaskRun))
+		Expect(fake.notifications[0].PipelineRun).To(Equal("taskrun-standalone"))
+		Expect(fake.notifications[0].Status).To(Equal(notifier.StatusFailed))
+		Expect(fake.notifications[0].Results).To(Equal([]notifier.Result{{Name: "commit", Value: "abc123"}}))
+	})
+
+	It("should ignore the taskruns of pipelineruns", func() {
+		fake := &fakeNotifier{}
+		r := &TaskRunReconciler{Reconciler: &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Notifier: fake}}
+		taskRun := createTaskRun("taskrun-of-pipelinerun", map[string]string{"tekton.dev/pipelineRun": "build"})
+
+		reconciled := reconcileTaskRun(r, taskRun)
+		Expect(reconciled.Finalizers).To(BeEmpty())
+		Expect(IsStandaloneTaskRun(reconciled)).To(BeFalse())
+		Expect(fake.notifications).To(BeEmpty())
+	})
+})
@@ -0,0 +1,196 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	tektonv1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+)
+
+// TektonResultsPageSize is the number of records requested per page from Tekton Results
+const TektonResultsPageSize = 100
+
+// Record types of the pipelineruns archived by Tekton Results
+const (
+	resultsPipelineRunType        = "tekton.dev/v1.PipelineRun"
+	resultsV1beta1PipelineRunType = "tekton.dev/v1beta1.PipelineRun"
+)
+
+// TektonResultsOptions configures the client of the REST API of Tekton Results
+type TektonResultsOptions struct {
+	// URL is the address of the API server, e.g. https://tekton-results-api-service.tekton-pipelines.svc:8080
+	URL string
+	// TokenFile holds the bearer token authenticating the requests, e.g. a service account token
+	TokenFile string
+	// CAFile is a PEM file with the CA certificates used to verify the API server.
+	// If empty, the system pool is used.
+	CAFile string
+	// Timeout is the deadline of a request, 30 seconds by default
+	Timeout time.Duration
+}
+
+// TektonResultsClient reads the pipelineruns archived by Tekton Results
+type TektonResultsClient struct {
+	url        string
+	token      string
+	httpClient *http.Client
+}
+
+// recordList is a page of the records of Tekton Results
+type recordList struct {
+	Records []struct {
+		Name string `json:"name"`
+		Data struct {
+			Type  string `json:"type"`
+			Value []byte `json:"value"`
+		} `json:"data"`
+	} `json:"records"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// NewTektonResultsClient returns a client of the Tekton Results API
+// Return error if the URL is not valid or the token or CA file cannot be read
+func NewTektonResultsClient(opts TektonResultsOptions) (*TektonResultsClient, error) {
+	parsed, err := url.Parse(opts.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("Invalid Tekton Results URL %q", opts.URL)
+	}
+	c := &TektonResultsClient{url: strings.TrimSuffix(opts.URL, "/"), httpClient: &http.Client{Timeout: opts.Timeout}}
+	if c.httpClient.Timeout <= 0 {
+		c.httpClient.Timeout = 30 * time.Second
+	}
+	if opts.TokenFile != "" {
+		token, err := os.ReadFile(opts.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read Tekton Results token file: %w", err)
+		}
+		c.token = strings.TrimSpace(string(token))
+	}
+	if opts.CAFile != "" {
+		ca, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read Tekton Results CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("No certificates found in Tekton Results CA file %s", opts.CAFile)
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: pool}
+		c.httpClient.Transport = transport
+	}
+	return c, nil
+}
+
+// ListPipelineRuns returns the pipelineruns of the namespace, or of all namespaces if it is empty, archived by
+// Tekton Results that completed in [since, until). Pipelineruns archived in v1beta1 are converted to v1.
+// Return error if a page cannot be read or a record is malformed
+func (c *TektonResultsClient) ListPipelineRuns(ctx context.Context, namespace string, since time.Time, until time.Time) ([]tektonv1.PipelineRun, error) {
+	parent := namespace
+	if parent == "" {
+		parent = "-"
+	}
+	filter := fmt.Sprintf(`(data_type == %q || data_type == %q) && data.status.completionTime >= timestamp(%q) && data.status.completionTime < timestamp(%q)`,
+		resultsPipelineRunType, resultsV1beta1PipelineRunType, since.UTC().Format(time.RFC3339), until.UTC().Format(time.RFC3339))
+	var pipelineRuns []tektonv1.PipelineRun
+	pageToken := ""
+	for {
+		query := url.Values{"filter": {filter}, "page_size": {fmt.Sprint(TektonResultsPageSize)}}
+		if pageToken != "" {
+			query.Set("page_token", pageToken)
+		}
+		page, err := c.listRecords(ctx, parent, query)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range page.Records {
+			pipelineRun, err := decodeRecordPipelineRun(ctx, record.Data.Type, record.Data.Value)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to decode Tekton Results record %s: %w", record.Name, err)
+			}
+			if pipelineRun != nil {
+				pipelineRuns = append(pipelineRuns, *pipelineRun)
+			}
+		}
+		if page.NextPageToken == "" {
+			return pipelineRuns, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// listRecords requests a page of the records of the results of the parent
+func (c *TektonResultsClient) listRecords(ctx context.Context, parent string, query url.Values) (*recordList, error) {
+	endpoint := c.url + "/apis/results.tekton.dev/v1alpha2/parents/" + url.PathEscape(parent) + "/results/-/records?" + query.Encode()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create Tekton Results request: %w", err)
+	}
+	request.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		request.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list Tekton Results records: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		excerpt, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return nil, fmt.Errorf("Failed to list Tekton Results records: status %d: %s", response.StatusCode, strings.TrimSpace(string(excerpt)))
+	}
+	page := &recordList{}
+	err = json.NewDecoder(response.Body).Decode(page)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode Tekton Results records: %w", err)
+	}
+	return page, nil
+}
+
+// decodeRecordPipelineRun returns the pipelinerun of a record, or nil if the record holds another type
+func decodeRecordPipelineRun(ctx context.Context, recordType string, value []byte) (*tektonv1.PipelineRun, error) {
+	pipelineRun := &tektonv1.PipelineRun{}
+	switch recordType {
+	case resultsPipelineRunType:
+		if err := json.Unmarshal(value, pipelineRun); err != nil {
+			return nil, err
+		}
+	case resultsV1beta1PipelineRunType:
+		archived := &tektonv1beta1.PipelineRun{}
+		if err := json.Unmarshal(value, archived); err != nil {
+			return nil, err
+		}
+		if err := archived.ConvertTo(ctx, pipelineRun); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, nil
+	}
+	return pipelineRun, nil
+}
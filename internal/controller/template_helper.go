@@ -0,0 +1,210 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Sources of the templates of destinations
+const (
+	// TemplateSourceInline is the inline template of the backend of a destination
+	TemplateSourceInline = "inline"
+	// TemplateSourceRef is the NotificationTemplate referenced by a destination
+	TemplateSourceRef = "templateRef"
+	// TemplateSourceDefault is the default template of the NotificationService
+	TemplateSourceDefault = "defaultTemplate"
+	// TemplateSourceDefaultRef is the NotificationTemplate referenced by the NotificationService
+	TemplateSourceDefaultRef = "defaultTemplateRef"
+	// TemplateSourceBackend is the default format of the backend, which is not a template
+	TemplateSourceBackend = "backend"
+	// TemplateSourceRequest is a template sent in a preview request
+	TemplateSourceRequest = "request"
+)
+
+// TemplatePreview is the output of the template of a destination rendered against a PipelineRun
+type TemplatePreview struct {
+	Destination string `json:"destination,omitempty"`
+	Source      string `json:"source"`
+	Output      string `json:"output,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// TemplatePreviews are the outputs of the templates of the destinations of a NotificationService
+type TemplatePreviews struct {
+	PipelineRun string            `json:"pipelineRun"`
+	Previews    []TemplatePreview `json:"previews"`
+}
+
+// destinationTemplate returns the inline template field of the backend of the destination,
+// or nil if the destination has no backend
+func destinationTemplate(destination *v1alpha1.Destination) *string {
+	switch {
+	case destination.Webhook != nil:
+		return &destination.Webhook.Template
+	case destination.Slack != nil:
+		return &destination.Slack.Template
+	case destination.Matrix != nil:
+		return &destination.Matrix.Template
+	case destination.IRC != nil:
+		return &destination.IRC.Template
+	case destination.XMPP != nil:
+		return &destination.XMPP.Template
+	case destination.WebPush != nil:
+		return &destination.WebPush.Template
+	case destination.FCM != nil:
+		return &destination.FCM.Template
+	case destination.Alertmanager != nil:
+		return &destination.Alertmanager.Template
+	}
+	return nil
+}
+
+// InheritDefaultTemplate returns the destination with the default template of the NotificationService
+// if it sets neither an inline template nor a templateRef
+func InheritDefaultTemplate(destination v1alpha1.Destination, spec *v1alpha1.NotificationServiceSpec) v1alpha1.Destination {
+	template := destinationTemplate(&destination)
+	if template == nil || *template != "" || destination.TemplateRef != nil {
+		return destination
+	}
+	destination = *destination.DeepCopy()
+	if spec.DefaultTemplate != "" {
+		*destinationTemplate(&destination) = spec.DefaultTemplate
+	} else if spec.DefaultTemplateRef != nil {
+		destination.TemplateRef = spec.DefaultTemplateRef.DeepCopy()
+	}
+	return destination
+}
+
+// resolveTemplateRef returns the destination with the template of its templateRef, read from the namespace,
+// set as the inline template of its backend, unless the backend sets one
+// Return error if the NotificationTemplate does not exist
+func resolveTemplateRef(ctx context.Context, c client.Reader, namespace string, destination v1alpha1.Destination) (v1alpha1.Destination, error) {
+	template := destinationTemplate(&destination)
+	if destination.TemplateRef == nil || template == nil || *template != "" {
+		return destination, nil
+	}
+	notificationTemplate := &v1alpha1.NotificationTemplate{}
+	err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: destination.TemplateRef.Name}, notificationTemplate)
+	if err != nil {
+		return destination, fmt.Errorf("Failed to get NotificationTemplate %s of destination %s: %w",
+			destination.TemplateRef.Name, destination.Name, err)
+	}
+	destination = *destination.DeepCopy()
+	*destinationTemplate(&destination) = notificationTemplate.Spec.Template
+	return destination, nil
+}
+
+// PreviewTemplates renders the templates of the destinations of the NotificationService against the
+// pipelineRun, or the most recently completed PipelineRun of the namespace if pipelineRunName is empty.
+// If template is not empty, only it is rendered. Nothing is sent to the destinations.
+// Return a NotFound error if the NotificationService or the PipelineRun does not exist
+func PreviewTemplates(ctx context.Context, r *NotificationServiceReconciler, namespace string, notificationServiceName string,
+	pipelineRunName string, template string) (*TemplatePreviews, error) {
+	notificationService := &v1alpha1.NotificationService{}
+	err := r.Client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: notificationServiceName}, notificationService)
+	if err != nil {
+		return nil, err
+	}
+	pipelineRun, err := previewPipelineRun(ctx, r.Client, namespace, pipelineRunName)
+	if err != nil {
+		return nil, err
+	}
+	notification, err := r.buildNotification(ctx, pipelineRun)
+	if err != nil {
+		return nil, err
+	}
+	// Previews render what the destinations of the NotificationService would receive
+	policies, err := GetPayloadPolicies(ctx, r.Client, namespace)
+	if err != nil {
+		return nil, err
+	}
+	notification, err = RedactNotification(notification, policies)
+	if err != nil {
+		return nil, err
+	}
+	previews := &TemplatePreviews{PipelineRun: pipelineRun.Name, Previews: []TemplatePreview{}}
+	if template != "" {
+		previews.Previews = append(previews.Previews, renderPreview(TemplatePreview{Source: TemplateSourceRequest}, template, notification))
+		return previews, nil
+	}
+	for _, destination := range notificationService.Spec.Destinations {
+		preview := TemplatePreview{Destination: destination.Name}
+		own := destinationTemplate(&destination)
+		switch {
+		case own == nil:
+			continue
+		case *own != "":
+			preview.Source = TemplateSourceInline
+		case destination.TemplateRef != nil:
+			preview.Source = TemplateSourceRef
+		case notificationService.Spec.DefaultTemplate != "":
+			preview.Source = TemplateSourceDefault
+		case notificationService.Spec.DefaultTemplateRef != nil:
+			preview.Source = TemplateSourceDefaultRef
+		default:
+			preview.Source = TemplateSourceBackend
+			previews.Previews = append(previews.Previews, preview)
+			continue
+		}
+		resolved, err := resolveTemplateRef(ctx, r.Client, namespace, InheritDefaultTemplate(destination, &notificationService.Spec))
+		if err != nil {
+			preview.Error = err.Error()
+			previews.Previews = append(previews.Previews, preview)
+			continue
+		}
+		previews.Previews = append(previews.Previews, renderPreview(preview, *destinationTemplate(&resolved), notification))
+	}
+	return previews, nil
+}
+
+// renderPreview sets the output of the template rendered from the notification, or the error, on the preview
+func renderPreview(preview TemplatePreview, template string, notification *notifier.Notification) TemplatePreview {
+	tmpl, err := notifier.NewTemplate(preview.Destination, template)
+	if err == nil {
+		var output []byte
+		output, err = notifier.Render(tmpl, notification)
+		preview.Output = string(output)
+	}
+	if err != nil {
+		preview.Error = err.Error()
+	}
+	return preview
+}
+
+// previewPipelineRun returns the named pipelinerun, or the most recently completed pipelinerun of the namespace
+func previewPipelineRun(ctx context.Context, c client.Reader, namespace string, name string) (*tektonv1.PipelineRun, error) {
+	if name != "" {
+		pipelineRun := &tektonv1.PipelineRun{}
+		err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, pipelineRun)
+		if err != nil {
+			return nil, err
+		}
+		return pipelineRun, nil
+	}
+	pipelineRuns := &tektonv1.PipelineRunList{}
+	err := c.List(ctx, pipelineRuns, client.InNamespace(namespace))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list pipelineruns in %s: %w", namespace, err)
+	}
+	var latest *tektonv1.PipelineRun
+	for i := range pipelineRuns.Items {
+		pipelineRun := &pipelineRuns.Items[i]
+		if !IsPipelineRunEnded(pipelineRun) || pipelineRun.Status.CompletionTime == nil {
+			continue
+		}
+		if latest == nil || latest.Status.CompletionTime.Before(pipelineRun.Status.CompletionTime) {
+			latest = pipelineRun
+		}
+	}
+	if latest == nil {
+		return nil, k8serrors.NewNotFound(tektonv1.Resource("pipelineruns"), namespace+"/<latest completed>")
+	}
+	return latest, nil
+}
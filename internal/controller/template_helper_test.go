@@ -0,0 +1,157 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/go-logr/logr"
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Destination templates", func() {
+	var (
+		receiver *httptest.Server
+		bodies   map[string]string
+	)
+
+	BeforeEach(func() {
+		bodies = map[string]string{}
+		receiver = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			body, _ := io.ReadAll(req.Body)
+			bodies[req.URL.Path] = string(body)
+		}))
+		DeferCleanup(receiver.Close)
+		for name, template := range map[string]string{"minimal": `{"run": "{{ .PipelineRun }}"}`, "status": `{{ .Status }}`} {
+			notificationTemplate := &v1alpha1.NotificationTemplate{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+				Spec:       v1alpha1.NotificationTemplateSpec{Template: template},
+			}
+			Expect(k8sClient.Create(context.Background(), notificationTemplate)).To(Succeed())
+			DeferCleanup(k8sClient.Delete, context.Background(), notificationTemplate)
+		}
+	})
+
+	webhook := func(path string) *v1alpha1.WebhookDestination {
+		return &v1alpha1.WebhookDestination{URL: receiver.URL + path}
+	}
+
+	It("should prefer inline templates, then templateRef, then the NotificationService default", func() {
+		inline := webhook("/inline")
+		inline.Template = "inline {{ .PipelineRun }}"
+		notificationService := &v1alpha1.NotificationService{
+			ObjectMeta: metav1.ObjectMeta{Name: "templates", Namespace: "default"},
+			Spec: v1alpha1.NotificationServiceSpec{
+				DefaultTemplateRef: &corev1.LocalObjectReference{Name: "status"},
+				Destinations: []v1alpha1.Destination{
+					{Name: "inline", Webhook: inline, TemplateRef: &corev1.LocalObjectReference{Name: "minimal"}},
+					{Name: "ref", Webhook: webhook("/ref"), TemplateRef: &corev1.LocalObjectReference{Name: "minimal"}},
+					{Name: "inherited", Webhook: webhook("/inherited")},
+					{Name: "missing", Webhook: webhook("/missing"), TemplateRef: &corev1.LocalObjectReference{Name: "missing"}},
+				},
+			},
+		}
+		destinations := GetNotificationServiceDestinations(context.Background(), k8sClient, logr.Discard(), notificationService)
+		Expect(destinations).To(HaveLen(3))
+		for _, destination := range destinations {
+			Expect(destination.Notify(context.Background(), &notifier.Notification{
+				PipelineRun: "build-1", Namespace: "default", Status: notifier.StatusFailed,
+			})).To(Succeed())
+		}
+		Expect(bodies).To(Equal(map[string]string{
+			"/inline":    "inline build-1",
+			"/ref":       `{"run": "build-1"}`,
+			"/inherited": notifier.StatusFailed,
+		}))
+		Expect(notificationService.Spec.Destinations[2].TemplateRef).To(BeNil())
+	})
+
+	It("should inherit the inline default template over the default templateRef", func() {
+		spec := &v1alpha1.NotificationServiceSpec{
+			DefaultTemplate:    "default {{ .PipelineRun }}",
+			DefaultTemplateRef: &corev1.LocalObjectReference{Name: "status"},
+		}
+		destination := InheritDefaultTemplate(v1alpha1.Destination{Name: "hook", Webhook: webhook("/hook")}, spec)
+		Expect(destination.Webhook.Template).To(Equal("default {{ .PipelineRun }}"))
+		Expect(destination.TemplateRef).To(BeNil())
+
+		destination = InheritDefaultTemplate(v1alpha1.Destination{
+			Name: "chat", Slack: &v1alpha1.SlackDestination{Channel: "C1", Template: "own"},
+		}, spec)
+		Expect(destination.Slack.Template).To(Equal("own"))
+	})
+
+	It("should preview the templates of the destinations against the latest PipelineRun", func() {
+		pipelineRun := createPipelineRun("preview-latest", corev1.ConditionFalse,
+			tektonv1.PipelineRunResult{Name: "IMAGE_URL", Value: *tektonv1.NewStructuredValues("quay.io/tenant/app")})
+		pipelineRun.Status.CompletionTime = &metav1.Time{Time: time.Now().Add(time.Hour)}
+		Expect(k8sClient.Status().Update(context.Background(), pipelineRun)).To(Succeed())
+
+		inline := webhook("/inline")
+		inline.Template = "{{ .PipelineRun }}: {{ (.Result \"IMAGE_URL\").Value }}"
+		broken := webhook("/broken")
+		broken.Template = "{{ .Missing }}"
+		notificationService := &v1alpha1.NotificationService{
+			ObjectMeta: metav1.ObjectMeta{Name: "preview", Namespace: "default"},
+			Spec: v1alpha1.NotificationServiceSpec{
+				DefaultTemplateRef: &corev1.LocalObjectReference{Name: "status"},
+				Destinations: []v1alpha1.Destination{
+					{Name: "inline", Webhook: inline},
+					{Name: "inherited", Webhook: webhook("/inherited")},
+					{Name: "broken", Webhook: broken},
+					{Name: "missing", Webhook: webhook("/missing"), TemplateRef: &corev1.LocalObjectReference{Name: "missing"}},
+				},
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), notificationService)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), notificationService)
+
+		r := &NotificationServiceReconciler{Client: k8sClient}
+		previews, err := PreviewTemplates(context.Background(), r, "default", "preview", "", "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(previews.PipelineRun).To(Equal("preview-latest"))
+		Expect(previews.Previews).To(HaveLen(4))
+		Expect(previews.Previews[0]).To(Equal(TemplatePreview{
+			Destination: "inline", Source: TemplateSourceInline, Output: "preview-latest: quay.io/tenant/app",
+		}))
+		Expect(previews.Previews[1]).To(Equal(TemplatePreview{
+			Destination: "inherited", Source: TemplateSourceDefaultRef, Output: notifier.StatusFailed,
+		}))
+		Expect(previews.Previews[2].Error).To(ContainSubstring("Missing"))
+		Expect(previews.Previews[3].Source).To(Equal(TemplateSourceRef))
+		Expect(previews.Previews[3].Error).To(ContainSubstring("not found"))
+		Expect(bodies).To(BeEmpty())
+
+		previews, err = PreviewTemplates(context.Background(), r, "default", "preview", "preview-latest", "{{ .Namespace }}")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(previews.Previews).To(Equal([]TemplatePreview{{Source: TemplateSourceRequest, Output: "default"}}))
+		_, err = PreviewTemplates(context.Background(), r, "default", "preview", "missing", "")
+		Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+	})
+})
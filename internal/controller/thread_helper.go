@@ -0,0 +1,29 @@
+package controller
+
+import (
+	"context"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NotificationThreadsAnnotation holds a JSON map from destination to the thread that later
+// notifications about the pipelinerun continue, e.g. the first Slack message
+var NotificationThreadsAnnotation = DefaultMarkerPrefix + "/notification-threads"
+
+// GetNotificationThreads returns the threads of the destinations that were already notified about the pipelineRun
+// Return error if the annotation is malformed
+func GetNotificationThreads(pipelineRun *tektonv1.PipelineRun) (map[string]string, error) {
+	threads := map[string]string{}
+	err := getJSONAnnotation(pipelineRun, NotificationThreadsAnnotation, &threads)
+	if err != nil {
+		return map[string]string{}, err
+	}
+	return threads, nil
+}
+
+// SetNotificationThreads stores the threads of the destinations in the pipelineRun
+// If the annotation was not updated successfully, a non-nil error is returned.
+func SetNotificationThreads(ctx context.Context, pipelineRun *tektonv1.PipelineRun, c client.Client, threads map[string]string) error {
+	return setJSONAnnotation(ctx, pipelineRun, c, NotificationThreadsAnnotation, threads)
+}
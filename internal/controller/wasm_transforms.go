@@ -0,0 +1,194 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	"github.com/konflux-ci/notification-service/pkg/transform"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// WasmLayerMediaType is the media type of the layer holding the WebAssembly module of OCI artifacts
+const WasmLayerMediaType types.MediaType = "application/vnd.wasm.content.layer.v1+wasm"
+
+// MaxModuleBytes limits the size of the WebAssembly modules pulled from OCI artifacts or decompressed
+const MaxModuleBytes = 64 << 20
+
+// WasmTransforms runs the WebAssembly modules of the configuration file on notifications before they are delivered
+type WasmTransforms struct {
+	Client  client.Reader
+	Runtime *transform.Runtime
+	// Pull downloads the module of an OCI artifact, PullModule if not set
+	Pull func(ctx context.Context, image string) ([]byte, error)
+
+	mu     sync.Mutex
+	images map[string][]byte
+}
+
+// Apply runs the modules, in order, on the notification and returns the transformed notification,
+// or nil if a module dropped it. Every module is loaded first, and the compiled modules and pulled images
+// the modules no longer use are released.
+// Return error if a module cannot be loaded, fails or does not return a notification
+func (t *WasmTransforms) Apply(ctx context.Context, modules []TransformModule, notification *notifier.Notification) (*notifier.Notification, error) {
+	wasms := make([][]byte, 0, len(modules))
+	digests := make([]string, 0, len(modules))
+	for _, module := range modules {
+		wasm, err := t.load(ctx, module)
+		if err != nil {
+			transformFailures.WithLabelValues(module.Name).Inc()
+			return nil, fmt.Errorf("Failed to load transform %s: %w", module.Name, err)
+		}
+		wasms = append(wasms, wasm)
+		digests = append(digests, transform.Digest(wasm))
+	}
+	t.retainImages(modules)
+	t.Runtime.Retain(ctx, digests...)
+	for i, module := range modules {
+		transformed, err := t.run(ctx, module, wasms[i], notification)
+		if err != nil {
+			transformFailures.WithLabelValues(module.Name).Inc()
+			return nil, err
+		}
+		if transformed == nil {
+			notificationsDropped.WithLabelValues(module.Name).Inc()
+			return nil, nil
+		}
+		notification = transformed
+	}
+	return notification, nil
+}
+
+// run runs the WebAssembly module of the transform on the notification and returns the transformed notification,
+// or nil if the module dropped it
+func (t *WasmTransforms) run(ctx context.Context, module TransformModule, wasm []byte, notification *notifier.Notification) (*notifier.Notification, error) {
+	input, err := json.Marshal(notification)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to encode notification for pipelinerun %s: %w", notification.PipelineRun, err)
+	}
+	timeout := transform.DefaultTimeout
+	if module.Timeout != nil {
+		timeout = module.Timeout.Duration
+	}
+	output, err := t.Runtime.Run(ctx, wasm, input, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("Transform %s failed for pipelinerun %s: %w", module.Name, notification.PipelineRun, err)
+	}
+	if len(bytes.TrimSpace(output)) == 0 {
+		return nil, nil
+	}
+	transformed := &notifier.Notification{}
+	err = json.Unmarshal(output, transformed)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode the output of transform %s for pipelinerun %s: %w", module.Name, notification.PipelineRun, err)
+	}
+	return transformed, nil
+}
+
+// load returns the WebAssembly module of the transform, read from its ConfigMap or from its image, which is only
+// pulled the first time
+func (t *WasmTransforms) load(ctx context.Context, module TransformModule) ([]byte, error) {
+	if module.ConfigMap != nil {
+		configMap := &corev1.ConfigMap{}
+		key := client.ObjectKey{Namespace: module.ConfigMap.Namespace, Name: module.ConfigMap.Name}
+		err := t.Client.Get(ctx, key, configMap)
+		if err != nil {
+			return nil, err
+		}
+		wasm, ok := configMap.BinaryData[module.ConfigMap.Key]
+		if !ok {
+			return nil, fmt.Errorf("ConfigMap %s has no binary data key %s", key, module.ConfigMap.Key)
+		}
+		return decompressLayer(wasm, MaxModuleBytes)
+	}
+	t.mu.Lock()
+	wasm, ok := t.images[module.Image]
+	t.mu.Unlock()
+	if ok {
+		return wasm, nil
+	}
+	pull := t.Pull
+	if pull == nil {
+		pull = PullModule
+	}
+	wasm, err := pull(ctx, module.Image)
+	if err == nil {
+		wasm, err = decompressLayer(wasm, MaxModuleBytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.images == nil {
+		t.images = map[string][]byte{}
+	}
+	t.images[module.Image] = wasm
+	return wasm, nil
+}
+
+// retainImages forgets the modules pulled from the images that are not the image of one of the modules
+func (t *WasmTransforms) retainImages(modules []TransformModule) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for image := range t.images {
+		if !slices.ContainsFunc(modules, func(module TransformModule) bool { return module.Image == image }) {
+			delete(t.images, image)
+		}
+	}
+}
+
+// PullModule downloads the WebAssembly module of the OCI artifact, the content of its layer with the
+// WasmLayerMediaType media type, or of its only layer.
+// Return error if the artifact cannot be pulled or does not hold a single module
+func PullModule(ctx context.Context, image string) ([]byte, error) {
+	wasm, _, err := pullLayer(ctx, image, WasmLayerMediaType, MaxModuleBytes)
+	return wasm, err
+}
+
+// transform runs the transforms of the configuration file on the notification, and returns the transformed
+// notification, or nil if a transform dropped it. The WasmTransforms are applied even without transforms,
+// so the modules removed from the configuration are released.
+// Return error if transforms are configured while the WasmTransforms are not set, or if a transform failed
+func (r *NotificationServiceReconciler) transform(ctx context.Context, notification *notifier.Notification) (*notifier.Notification, error) {
+	modules := r.ConfigFile.Get().Transforms
+	if r.Transforms == nil {
+		if len(modules) == 0 {
+			return notification, nil
+		}
+		return nil, errors.New("WebAssembly transforms are configured but not enabled")
+	}
+	return r.Transforms.Apply(ctx, modules, notification)
+}
+
+// destinationNames returns the names of the destinations
+func destinationNames(destinations []DestinationNotifier) []string {
+	names := make([]string, 0, len(destinations))
+	for _, destination := range destinations {
+		names = append(names, destination.Name)
+	}
+	return names
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/pkg/transform"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("WebAssembly transforms", Ordered, func() {
+	var module []byte
+	var transforms *WasmTransforms
+
+	BeforeAll(func() {
+		path := filepath.Join(GinkgoT().TempDir(), "transform.wasm")
+		build := exec.Command("go", "build", "-ldflags=-s -w", "-o", path, "../../pkg/transform/testdata/transform")
+		build.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+		output, err := build.CombinedOutput()
+		Expect(err).NotTo(HaveOccurred(), string(output))
+		module, err = os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		runtime := transform.NewRuntime(context.Background(), transform.Options{})
+		DeferCleanup(runtime.Close, context.Background())
+		transforms = &WasmTransforms{Client: k8sClient, Runtime: runtime}
+	})
+
+	newReconciler := func(config string) (*NotificationServiceReconciler, *fakeNotifier) {
+		path := filepath.Join(GinkgoT().TempDir(), "config.yaml")
+		Expect(os.WriteFile(path, []byte(config), 0o600)).To(Succeed())
+		file, err := NewConfigFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		fake := &fakeNotifier{}
+		return &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Notifier: fake,
+			ConfigFile: file, Transforms: transforms}, fake
+	}
+
+	It("should transform notifications with a module loaded from a ConfigMap", func() {
+		// ConfigMaps are limited to 1 MiB, so the module is compressed
+		compressed := &bytes.Buffer{}
+		writer, err := gzip.NewWriterLevel(compressed, gzip.BestCompression)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = writer.Write(module)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(writer.Close()).To(Succeed())
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "transforms", Namespace: "default"},
+			BinaryData: map[string][]byte{"team.wasm.gz": compressed.Bytes()},
+		}
+		Expect(k8sClient.Create(context.Background(), configMap)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, context.Background(), configMap)
+		r, fake := newReconciler("transforms:\n- name: team\n  configMap:\n    namespace: default\n    name: transforms\n    key: team.wasm.gz\n")
+
+		pipelineRun := createPipelineRun("transformed", corev1.ConditionTrue)
+		Expect(reconcilePipelineRun(r, pipelineRun)).To(Succeed())
+		Expect(fake.notifications).To(HaveLen(1))
+		Expect(fake.notifications[0].Team).To(Equal("transformed"))
+		Expect(fake.notifications[0].PipelineRun).To(Equal("transformed"))
+	})
+
+	It("should not deliver the notifications dropped by a module pulled once from an image", func() {
+		pulls := 0
+		transforms.Pull = func(context.Context, string) ([]byte, error) {
+			pulls++
+			return module, nil
+		}
+		DeferCleanup(func() { transforms.Pull = nil })
+		r, fake := newReconciler("transforms:\n- name: filter\n  image: registry.example.com/transforms/filter:v1\n")
+
+		pipelineRun := createPipelineRun("ignored", corev1.ConditionTrue)
+		Expect(reconcilePipelineRun(r, pipelineRun)).To(Succeed())
+		Expect(fake.notifications).To(BeEmpty())
+		Expect(getPipelineRun(pipelineRun).Annotations).To(HaveKeyWithValue(NotificationPipelineRunAnnotation, NotificationPipelineRunAnnotationValue))
+
+		Expect(reconcilePipelineRun(r, createPipelineRun("kept", corev1.ConditionTrue))).To(Succeed())
+		Expect(fake.notifications).To(HaveLen(1))
+		Expect(pulls).To(Equal(1))
+	})
+
+	It("should release the modules that are no longer configured", func() {
+		transforms.Pull = func(context.Context, string) ([]byte, error) {
+			return module, nil
+		}
+		DeferCleanup(func() { transforms.Pull = nil })
+		r, fake := newReconciler("transforms:\n- name: filter\n  image: registry.example.com/transforms/filter:v2\n")
+		Expect(reconcilePipelineRun(r, createPipelineRun("released-before", corev1.ConditionTrue))).To(Succeed())
+		Expect(fake.notifications).To(HaveLen(1))
+		Expect(transforms.Runtime.Digests()).To(ConsistOf(transform.Digest(module)))
+		Expect(transforms.images).To(HaveKey("registry.example.com/transforms/filter:v2"))
+
+		r, fake = newReconciler("concurrency: 1\n")
+		Expect(reconcilePipelineRun(r, createPipelineRun("released-after", corev1.ConditionTrue))).To(Succeed())
+		Expect(fake.notifications).To(HaveLen(1))
+		Expect(transforms.Runtime.Digests()).To(BeEmpty())
+		Expect(transforms.images).To(BeEmpty())
+	})
+
+	It("should retry the notifications a module failed for", func() {
+		r, fake := newReconciler("transforms:\n- name: missing\n  configMap:\n    namespace: default\n    name: missing\n    key: transform.wasm\n")
+		pipelineRun := createPipelineRun("untransformed", corev1.ConditionTrue)
+		Expect(reconcilePipelineRun(r, pipelineRun)).To(MatchError(ContainSubstring("Failed to load transform missing")))
+		Expect(fake.notifications).To(BeEmpty())
+		Expect(getPipelineRun(pipelineRun).Annotations).NotTo(HaveKey(NotificationPipelineRunAnnotation))
+	})
+
+	It("should reject transforms without a single source", func() {
+		_, err := ParseControllerConfig([]byte("transforms:\n- name: none\n"))
+		Expect(err).To(HaveOccurred())
+		_, err = ParseControllerConfig([]byte("transforms:\n- name: both\n  image: registry.example.com/t:v1\n" +
+			"  configMap:\n    namespace: default\n    name: transforms\n    key: t.wasm\n"))
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// WebPushSubscriptionsSecret is the Secret holding the push subscriptions of the browsers subscribed
+// to the notifications of its namespace, one subscription per key
+const WebPushSubscriptionsSecret string = "notification-service-webpush-subscriptions"
+
+// MaxWebPushSubscriptions bounds the subscriptions of a namespace, keeping their Secret well below the size limit
+const MaxWebPushSubscriptions int = 1000
+
+// ErrTooManyWebPushSubscriptions is returned when subscribing a browser to a namespace that has MaxWebPushSubscriptions
+var ErrTooManyWebPushSubscriptions = errors.New("too many web push subscriptions")
+
+// WebPushVAPIDKeys identify the controller to push services, web push destinations require them if set
+var WebPushVAPIDKeys *notifier.VAPIDKeys
+
+// GetWebPushSubscriptions returns the push subscriptions of the namespace
+// Return error if the subscriptions Secret cannot be read or holds a malformed subscription
+func GetWebPushSubscriptions(ctx context.Context, c client.Reader, namespace string) ([]notifier.WebPushSubscription, error) {
+	secret := &corev1.Secret{}
+	err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: WebPushSubscriptionsSecret}, secret)
+	if k8serrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get web push subscriptions of %s: %w", namespace, err)
+	}
+	keys := make([]string, 0, len(secret.Data))
+	for key := range secret.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	subscriptions := make([]notifier.WebPushSubscription, 0, len(keys))
+	for _, key := range keys {
+		subscription := notifier.WebPushSubscription{}
+		err = json.Unmarshal(secret.Data[key], &subscription)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to decode web push subscription %s of %s: %w", key, namespace, err)
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	return subscriptions, nil
+}
+
+// AddWebPushSubscription stores the push subscription in the subscriptions Secret of the namespace,
+// replacing the subscription with the same endpoint if any
+// Return error if the subscription is not valid or the Secret cannot be updated
+func AddWebPushSubscription(ctx context.Context, c client.Client, namespace string, subscription notifier.WebPushSubscription) error {
+	if err := subscription.Validate(); err != nil {
+		return err
+	}
+	value, err := json.Marshal(subscription)
+	if err != nil {
+		return err
+	}
+	key := webPushSubscriptionKey(subscription.Endpoint)
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		secret := &corev1.Secret{}
+		err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: WebPushSubscriptionsSecret}, secret)
+		if k8serrors.IsNotFound(err) {
+			secret = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: WebPushSubscriptionsSecret},
+				Data:       map[string][]byte{key: value},
+			}
+			return c.Create(ctx, secret)
+		}
+		if err != nil {
+			return err
+		}
+		if _, ok := secret.Data[key]; !ok && len(secret.Data) >= MaxWebPushSubscriptions {
+			return ErrTooManyWebPushSubscriptions
+		}
+		if secret.Data == nil {
+			secret.Data = map[string][]byte{}
+		}
+		secret.Data[key] = value
+		return c.Update(ctx, secret)
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to add web push subscription to %s: %w", namespace, err)
+	}
+	return nil
+}
+
+// RemoveWebPushSubscription removes the push subscription of the endpoint from the subscriptions Secret of the namespace
+// Return error if the Secret cannot be updated
+func RemoveWebPushSubscription(ctx context.Context, c client.Client, namespace string, endpoint string) error {
+	key := webPushSubscriptionKey(endpoint)
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		secret := &corev1.Secret{}
+		err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: WebPushSubscriptionsSecret}, secret)
+		if err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		if _, ok := secret.Data[key]; !ok {
+			return nil
+		}
+		delete(secret.Data, key)
+		return c.Update(ctx, secret)
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to remove web push subscription from %s: %w", namespace, err)
+	}
+	return nil
+}
+
+// webPushSubscriptionKey returns the Secret key of the subscription of the endpoint
+func webPushSubscriptionKey(endpoint string) string {
+	sum := sha256.Sum256([]byte(endpoint))
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// WorkflowGroupVersionKind is the kind of Argo Workflows
+var WorkflowGroupVersionKind = schema.GroupVersionKind{Group: "argoproj.io", Version: "v1alpha1", Kind: "Workflow"}
+
+// Phases of Argo Workflows
+const (
+	workflowPhaseSucceeded = "Succeeded"
+	workflowPhaseFailed    = "Failed"
+	workflowPhaseError     = "Error"
+)
+
+// WorkflowReconciler notifies about the end of Argo Workflows with the same lifecycle and destinations as
+// CustomRuns, so platforms running both Tekton and Argo use a single notification service. Workflows are
+// read as unstructured objects, the Argo Workflows API is not a dependency of the controller.
+// Their notifications have the KindWorkflow kind, the name of the Workflow as PipelineRun, and its status,
+// timing and output parameters as results.
+type WorkflowReconciler struct {
+	// Reconciler reads the Argo Workflows and runs their finalizer and notification lifecycle
+	// through reconcileRun
+	Reconciler *NotificationServiceReconciler
+}
+
+// +kubebuilder:rbac:groups=argoproj.io,resources=workflows,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups=argoproj.io,resources=workflows/finalizers,verbs=update
+
+// Reconcile adds the finalizer to running Workflows and, once they completed, sends their notification,
+// marks them as notified and removes the finalizer
+func (r *WorkflowReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	workflow := &unstructured.Unstructured{}
+	workflow.SetGroupVersionKind(WorkflowGroupVersionKind)
+	err := r.Reconciler.Get(ctx, req.NamespacedName, workflow)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		r.Reconciler.Log.Error(err, "Failed to get workflow", "workflow", req.NamespacedName)
+		return ctrl.Result{}, err
+	}
+	return r.Reconciler.reconcileRun(ctx, workflow, WorkflowGroupVersionKind, IsWorkflowCompleted(workflow),
+		func() *notifier.Notification { return GetNotificationFromWorkflow(workflow, time.Now()) })
+}
+
+// IsWorkflowCompleted returns a boolean indicating whether the Workflow succeeded, failed or errored
+func IsWorkflowCompleted(workflow *unstructured.Unstructured) bool {
+	phase, _, _ := unstructured.NestedString(workflow.Object, "status", "phase")
+	return phase == workflowPhaseSucceeded || phase == workflowPhaseFailed || phase == workflowPhaseError
+}
+
+// GetNotificationFromWorkflow builds the notification that is sent for the Workflow. Workflows that were
+// stopped or terminated are cancelled. Durations of Workflows that did not complete yet are measured up to now.
+func GetNotificationFromWorkflow(workflow *unstructured.Unstructured, now time.Time) *notifier.Notification {
+	notification := &notifier.Notification{
+		PipelineRun: workflow.GetName(),
+		Namespace:   workflow.GetNamespace(),
+		Kind:        notifier.KindWorkflow,
+		Status:      notifier.StatusStarted,
+		Event:       notifier.EventStarted,
+		Results:     []notifier.Result{},
+	}
+	phase, _, _ := unstructured.NestedString(workflow.Object, "status", "phase")
+	shutdown, _, _ := unstructured.NestedString(workflow.Object, "spec", "shutdown")
+	switch {
+	case phase == workflowPhaseSucceeded:
+		notification.Status, notification.Event = notifier.StatusSucceeded, notifier.EventSucceeded
+	case (phase == workflowPhaseFailed || phase == workflowPhaseError) && shutdown != "":
+		notification.Status, notification.Event = notifier.StatusFailed, notifier.EventCancelled
+	case phase == workflowPhaseFailed || phase == workflowPhaseError:
+		notification.Status, notification.Event = notifier.StatusFailed, notifier.EventFailed
+	}
+
+	parameters, _, _ := unstructured.NestedSlice(workflow.Object, "status", "outputs", "parameters")
+	for _, parameter := range parameters {
+		fields, ok := parameter.(map[string]any)
+		if !ok {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(fields, "name")
+		value, _, _ := unstructured.NestedString(fields, "value")
+		if name != "" {
+			notification.Results = append(notification.Results, notifier.Result{Name: name, Value: value})
+		}
+	}
+
+	notification.StartTime, notification.CompletionTime, notification.DurationSeconds =
+		getTiming(getStatusTime(workflow, "startedAt"), getStatusTime(workflow, "finishedAt"), now)
+	return notification
+}
+
+// SetupWithManager sets up the controller of Argo Workflows with the Manager
+func (r *WorkflowReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	workflow := &unstructured.Unstructured{}
+	workflow.SetGroupVersionKind(WorkflowGroupVersionKind)
+	return ctrl.NewControllerManagedBy(mgr).
+		For(workflow).
+		Complete(r)
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Workflow controller", func() {
+	createWorkflow := func(name string) *unstructured.Unstructured {
+		workflow := &unstructured.Unstructured{}
+		workflow.SetGroupVersionKind(WorkflowGroupVersionKind)
+		workflow.SetName(name)
+		workflow.SetNamespace("default")
+		Expect(unstructured.SetNestedField(workflow.Object, "main", "spec", "entrypoint")).To(Succeed())
+		Expect(k8sClient.Create(context.Background(), workflow)).To(Succeed())
+		DeferCleanup(func() {
+			workflow := workflow.DeepCopy()
+			if k8sClient.Get(context.Background(), client.ObjectKeyFromObject(workflow), workflow) == nil {
+				workflow.SetFinalizers(nil)
+				_ = k8sClient.Update(context.Background(), workflow)
+				_ = k8sClient.Delete(context.Background(), workflow)
+			}
+		})
+		return workflow
+	}
+
+	setStatus := func(workflow *unstructured.Unstructured, status map[string]any) {
+		Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(workflow), workflow)).To(Succeed())
+		workflow.Object["status"] = status
+		Expect(k8sClient.Status().Update(context.Background(), workflow)).To(Succeed())
+	}
+
+	reconcileWorkflow := func(r *WorkflowReconciler, workflow *unstructured.Unstructured) *unstructured.Unstructured {
+		_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(workflow)})
+		Expect(err).NotTo(HaveOccurred())
+		reconciled := &unstructured.Unstructured{}
+		reconciled.SetGroupVersionKind(WorkflowGroupVersionKind)
+		Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(workflow), reconciled)).To(Succeed())
+		return reconciled
+	}
+
+	It("should hold workflows until they are notified about", func() {
+		fake := &fakeNotifier{}
+		r := &WorkflowReconciler{Reconciler: &NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Notifier: fake}}
+		workflow := createWorkflow("workflow-build")
+		setStatus(workflow, map[string]any{"phase": "Running", "startedAt": "2024-05-01T10:00:00Z"})
+
+		reconciled := reconcileWorkflow(r, workflow)
+		Expect(reconciled.GetFinalizers()).To(ContainElement(NotificationPipelineRunFinalizer))
+		Expect(fake.notifications).To(BeEmpty())
+
+		setStatus(workflow, map[string]any{
+			"phase":      "Succeeded",
+			"startedAt":  "2024-05-01T10:00:00Z",
+			"finishedAt": "2024-05-01T10:01:30Z",
+			"outputs": map[string]any{"parameters": []any{
+				map[string]any{"name": "IMAGE_URL", "value": "quay.io/org/app:v1"},
+			}},
+		})
+		reconciled = reconcileWorkflow(r, workflow)
+		Expect(reconciled.GetFinalizers()).NotTo(ContainElement(NotificationPipelineRunFinalizer))
+		Expect(reconciled.GetAnnotations()).To(HaveKeyWithValue(NotificationPipelineRunAnnotation, NotificationPipelineRunAnnotationValue))
+		Expect(fake.notifications).To(HaveLen(1))
+		notification := fake.notifications[0]
+		Expect(notification.Kind).To(Equal(notifier.KindWorkflow))
+		Expect(notification.PipelineRun).To(Equal("workflow-build"))
+		Expect(notification.Status).To(Equal(notifier.StatusSucceeded))
+		Expect(notification.DurationSeconds).To(Equal(90.0))
+		Expect(notification.Results).To(Equal([]notifier.Result{{Name: "IMAGE_URL", Value: "quay.io/org/app:v1"}}))
+
+		reconcileWorkflow(r, workflow)
+		Expect(fake.notifications).To(HaveLen(1))
+	})
+
+	It("should tell stopped workflows from failed ones", func() {
+		workflow := &unstructured.Unstructured{Object: map[string]any{
+			"spec":   map[string]any{"shutdown": "Terminate"},
+			"status": map[string]any{"phase": "Failed"},
+		}}
+		Expect(GetNotificationFromWorkflow(workflow, time.Now()).Event).To(Equal(notifier.EventCancelled))
+		unstructured.RemoveNestedField(workflow.Object, "spec", "shutdown")
+		Expect(GetNotificationFromWorkflow(workflow, time.Now()).Event).To(Equal(notifier.EventFailed))
+		Expect(unstructured.SetNestedField(workflow.Object, "Error", "status", "phase")).To(Succeed())
+		Expect(IsWorkflowCompleted(workflow)).To(BeTrue())
+		Expect(GetNotificationFromWorkflow(workflow, time.Now()).Status).To(Equal(notifier.StatusFailed))
+	})
+})
@@ -0,0 +1,172 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package audit implements an append-only log of outbound notifications.
+// Every entry includes the hash of the previous entry, so modifying or removing
+// an entry breaks the chain and is detected by Verify.
+package audit
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+)
+
+// Entry is a single record of the audit log
+type Entry struct {
+	// Sequence is the position of the entry in the log, starting at 1
+	Sequence uint64 `json:"sequence"`
+	// Time is when the notification was sent
+	Time time.Time `json:"time"`
+	// Destination identifies the destination the notification was sent to
+	Destination string `json:"destination"`
+	// Succeeded is true if the destination accepted the notification
+	Succeeded bool `json:"succeeded"`
+	// Error is the reason the delivery failed
+	Error string `json:"error,omitempty"`
+	// Notification is the notification that was sent
+	Notification *notifier.Notification `json:"notification"`
+	// PreviousHash is the hash of the previous entry, empty for the first entry
+	PreviousHash string `json:"previousHash"`
+	// Hash is the sha256 hash of the entry without this field
+	Hash string `json:"hash,omitempty"`
+}
+
+// computeHash returns the hash of the entry, excluding its Hash field
+func (e Entry) computeHash() (string, error) {
+	e.Hash = ""
+	encoded, err := json.Marshal(e)
+	if err != nil {
+		return "", fmt.Errorf("Failed to encode audit entry %d: %w", e.Sequence, err)
+	}
+	sum := sha256.Sum256(encoded)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// Store persists encoded audit entries
+type Store interface {
+	// Append durably stores a single encoded entry
+	Append(ctx context.Context, line []byte) error
+	// Last returns the last stored entry, or nil if the store is empty
+	Last(ctx context.Context) ([]byte, error)
+}
+
+// Log appends hash chained entries to a Store
+type Log struct {
+	store Store
+
+	mu       sync.Mutex
+	sequence uint64
+	lastHash string
+}
+
+// NewLog creates a Log that continues the chain of the entries already in the store
+func NewLog(ctx context.Context, store Store) (*Log, error) {
+	l := &Log{store: store}
+	last, err := store.Last(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read the last audit entry: %w", err)
+	}
+	if last != nil {
+		entry := Entry{}
+		if err := json.Unmarshal(last, &entry); err != nil {
+			return nil, fmt.Errorf("Failed to decode the last audit entry: %w", err)
+		}
+		l.sequence = entry.Sequence
+		l.lastHash = entry.Hash
+	}
+	return l, nil
+}
+
+// Append records a delivery attempt of the notification to the destination
+func (l *Log) Append(ctx context.Context, destination string, notification *notifier.Notification, deliveryErr error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry := Entry{
+		Sequence:     l.sequence + 1,
+		Time:         time.Now().UTC(),
+		Destination:  destination,
+		Succeeded:    deliveryErr == nil,
+		Notification: notification,
+		PreviousHash: l.lastHash,
+	}
+	if deliveryErr != nil {
+		entry.Error = deliveryErr.Error()
+	}
+	hash, err := entry.computeHash()
+	if err != nil {
+		return err
+	}
+	entry.Hash = hash
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("Failed to encode audit entry %d: %w", entry.Sequence, err)
+	}
+	if err := l.store.Append(ctx, line); err != nil {
+		return fmt.Errorf("Failed to store audit entry %d: %w", entry.Sequence, err)
+	}
+	l.sequence = entry.Sequence
+	l.lastHash = entry.Hash
+	return nil
+}
+
+// ErrBrokenChain is returned by Verify when the log was modified
+var ErrBrokenChain = errors.New("audit log chain is broken")
+
+// Verify reads a log of newline separated entries and checks that every entry
+// matches its hash and references the hash of the entry preceding it.
+// It returns the number of verified entries.
+func Verify(r io.Reader) (uint64, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	var previous Entry
+	var count uint64
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		entry := Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return count, fmt.Errorf("Failed to decode audit entry after sequence %d: %w", previous.Sequence, err)
+		}
+		hash, err := entry.computeHash()
+		if err != nil {
+			return count, err
+		}
+		if hash != entry.Hash {
+			return count, fmt.Errorf("%w: entry %d does not match its hash", ErrBrokenChain, entry.Sequence)
+		}
+		if count > 0 && (entry.PreviousHash != previous.Hash || entry.Sequence != previous.Sequence+1) {
+			return count, fmt.Errorf("%w: entry %d does not follow entry %d", ErrBrokenChain, entry.Sequence, previous.Sequence)
+		}
+		previous = entry
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return count, fmt.Errorf("Failed to read audit log: %w", err)
+	}
+	return count, nil
+}
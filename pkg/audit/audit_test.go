@@ -0,0 +1,111 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/pkg/audit"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+)
+
+var _ = Describe("Audit log", func() {
+	var path string
+
+	notification := &notifier.Notification{
+		PipelineRun: "build-1",
+		Namespace:   "tenant",
+		Results:     []notifier.Result{{Name: "IMAGE_URL", Value: "quay.io/test/image"}},
+	}
+
+	openLog := func() (*audit.Log, *audit.FileStore) {
+		store, err := audit.NewFileStore(path)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(func() {
+			_ = store.Close()
+		})
+		log, err := audit.NewLog(context.Background(), store)
+		Expect(err).NotTo(HaveOccurred())
+		return log, store
+	}
+
+	verify := func() (uint64, error) {
+		content, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		return audit.Verify(bytes.NewReader(content))
+	}
+
+	BeforeEach(func() {
+		path = filepath.Join(GinkgoT().TempDir(), "audit.log")
+	})
+
+	It("should chain the hashes of appended entries", func() {
+		log, _ := openLog()
+		Expect(log.Append(context.Background(), "default", notification, nil)).To(Succeed())
+		Expect(log.Append(context.Background(), "default", notification, errors.New("rejected"))).To(Succeed())
+
+		count, err := verify()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(count).To(BeEquivalentTo(2))
+	})
+
+	It("should continue the chain after a restart", func() {
+		log, store := openLog()
+		Expect(log.Append(context.Background(), "default", notification, nil)).To(Succeed())
+		Expect(store.Close()).To(Succeed())
+
+		log, _ = openLog()
+		Expect(log.Append(context.Background(), "default", notification, nil)).To(Succeed())
+
+		count, err := verify()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(count).To(BeEquivalentTo(2))
+	})
+
+	It("should detect modified entries", func() {
+		log, _ := openLog()
+		Expect(log.Append(context.Background(), "default", notification, nil)).To(Succeed())
+		Expect(log.Append(context.Background(), "default", notification, nil)).To(Succeed())
+
+		content, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		tampered := strings.Replace(string(content), "quay.io/test/image", "quay.io/evil/image", 1)
+		_, err = audit.Verify(strings.NewReader(tampered))
+		Expect(err).To(MatchError(audit.ErrBrokenChain))
+	})
+
+	It("should detect removed entries", func() {
+		log, _ := openLog()
+		for i := 0; i < 3; i++ {
+			Expect(log.Append(context.Background(), "default", notification, nil)).To(Succeed())
+		}
+
+		content, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+		_, err = audit.Verify(strings.NewReader(lines[0] + "\n" + lines[2]))
+		Expect(err).To(MatchError(audit.ErrBrokenChain))
+	})
+})
@@ -0,0 +1,190 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package delivery helps receivers of notification service webhooks process every notification exactly once.
+//
+// The controller delivers notifications at least once: a notification is sent again if the controller
+// cannot record that it was delivered, e.g. when it restarts right after sending it or when the receiver
+// does not respond in time. Every request carries a delivery ID in the Header, which is the same for every
+// attempt to deliver a notification to a destination. Receivers that remember the IDs they processed, and
+// acknowledge the deliveries they already processed without processing them again, process every
+// notification exactly once.
+package delivery
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Header is the header of webhook requests holding the delivery ID
+const Header = "X-Notification-Delivery-ID"
+
+// DefaultRetention is how long a MemoryStore remembers the deliveries it processed, unless configured otherwise.
+// It is longer than the controller retries a notification with its default retry policy.
+const DefaultRetention = 7 * 24 * time.Hour
+
+// NewID returns the delivery ID of the notification about the status of a PipelineRun, identified by its UID,
+// to a destination. It is a 32 characters hex string.
+func NewID(pipelineRunUID string, destination string, status string) string {
+	sum := sha256.Sum256([]byte(pipelineRunUID + "\n" + destination + "\n" + status))
+	return hex.EncodeToString(sum[:16])
+}
+
+// ID returns the delivery ID of the request, empty if it has none
+func ID(r *http.Request) string {
+	return r.Header.Get(Header)
+}
+
+// Status is the processing status of a delivery in a Store
+type Status int
+
+const (
+	// StatusNew is the status of deliveries that were never claimed, or that were released
+	StatusNew Status = iota
+	// StatusProcessing is the status of claimed deliveries that were neither completed nor released
+	StatusProcessing
+	// StatusProcessed is the status of completed deliveries
+	StatusProcessed
+)
+
+// Store records the deliveries a receiver processes. Receivers running several replicas need a
+// Store shared by all of them, e.g. a database table whose primary key is the delivery ID.
+type Store interface {
+	// Claim marks the delivery as processing if it is new, and returns its status before the claim
+	Claim(ctx context.Context, id string) (Status, error)
+	// Complete marks a claimed delivery as processed
+	Complete(ctx context.Context, id string) error
+	// Release forgets a claimed delivery whose processing failed, so it is processed when it is sent again
+	Release(ctx context.Context, id string) error
+}
+
+// MemoryStore is a Store keeping the deliveries in memory, which suits receivers running a single replica.
+// Deliveries are forgotten when the receiver restarts.
+type MemoryStore struct {
+	// Retention is how long processed deliveries are remembered, DefaultRetention if zero
+	Retention time.Duration
+
+	mu         sync.Mutex
+	processing map[string]bool
+	processed  map[string]time.Time
+}
+
+// Claim marks the delivery as processing if it is new, and returns its status before the claim
+func (s *MemoryStore) Claim(_ context.Context, id string) (Status, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.processing == nil {
+		s.processing = map[string]bool{}
+		s.processed = map[string]time.Time{}
+	}
+	s.expire()
+	if s.processing[id] {
+		return StatusProcessing, nil
+	}
+	if _, ok := s.processed[id]; ok {
+		return StatusProcessed, nil
+	}
+	s.processing[id] = true
+	return StatusNew, nil
+}
+
+// Complete marks a claimed delivery as processed
+func (s *MemoryStore) Complete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.processing, id)
+	s.processed[id] = time.Now()
+	return nil
+}
+
+// Release forgets a claimed delivery
+func (s *MemoryStore) Release(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.processing, id)
+	return nil
+}
+
+// expire forgets the processed deliveries older than the retention
+func (s *MemoryStore) expire() {
+	retention := s.Retention
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+	for id, processed := range s.processed {
+		if time.Since(processed) > retention {
+			delete(s.processed, id)
+		}
+	}
+}
+
+// Handler returns a handler calling next once per delivery:
+//   - requests without delivery ID are rejected with 400 Bad Request
+//   - deliveries that were already processed are acknowledged with 200 OK, without calling next
+//   - deliveries that are being processed by a concurrent request are rejected with 409 Conflict,
+//     the controller sends them again later
+//   - other deliveries are passed to next, and are completed if next responds with a 2xx status,
+//     or released otherwise so the controller sends them again
+//
+// Store errors are reported with 503 Service Unavailable.
+func Handler(store Store, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := ID(r)
+		if id == "" {
+			http.Error(w, "missing "+Header+" header", http.StatusBadRequest)
+			return
+		}
+		status, err := store.Claim(r.Context(), id)
+		if err != nil {
+			http.Error(w, "failed to claim delivery", http.StatusServiceUnavailable)
+			return
+		}
+		switch status {
+		case StatusProcessed:
+			w.WriteHeader(http.StatusOK)
+			return
+		case StatusProcessing:
+			http.Error(w, "delivery is being processed", http.StatusConflict)
+			return
+		}
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		// The outcome must be recorded even if the controller stopped waiting for the response
+		ctx := context.WithoutCancel(r.Context())
+		if recorder.status >= 200 && recorder.status <= 299 && store.Complete(ctx, id) == nil {
+			return
+		}
+		// Deliveries that cannot be completed are released rather than left processing, where they
+		// would be rejected forever, at the cost of processing them again
+		_ = store.Release(ctx, id)
+	})
+}
+
+// statusRecorder records the status of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status and writes it
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
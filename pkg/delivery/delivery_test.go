@@ -0,0 +1,116 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package delivery_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/pkg/delivery"
+)
+
+var _ = Describe("Delivery", func() {
+	It("should derive stable IDs from the pipelinerun, destination and status", func() {
+		id := delivery.NewID("uid-1", "tenant/notifications/receiver", "Succeeded")
+		Expect(id).To(HaveLen(32))
+		Expect(delivery.NewID("uid-1", "tenant/notifications/receiver", "Succeeded")).To(Equal(id))
+		Expect(delivery.NewID("uid-1", "tenant/notifications/receiver", "Started")).NotTo(Equal(id))
+		Expect(delivery.NewID("uid-1", "tenant/notifications/other", "Succeeded")).NotTo(Equal(id))
+		Expect(delivery.NewID("uid-2", "tenant/notifications/receiver", "Succeeded")).NotTo(Equal(id))
+	})
+
+	Context("Handler", func() {
+		var (
+			store    *delivery.MemoryStore
+			calls    int
+			status   int
+			started  chan struct{}
+			release  chan struct{}
+			handler  http.Handler
+			sendWith = func(id string) int {
+				req := httptest.NewRequest(http.MethodPost, "/", nil)
+				if id != "" {
+					req.Header.Set(delivery.Header, id)
+				}
+				recorder := httptest.NewRecorder()
+				handler.ServeHTTP(recorder, req)
+				return recorder.Code
+			}
+		)
+
+		BeforeEach(func() {
+			store = &delivery.MemoryStore{}
+			calls = 0
+			status = http.StatusNoContent
+			started, release = nil, nil
+			handler = delivery.Handler(store, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				calls++
+				if release != nil {
+					close(started)
+					<-release
+				}
+				w.WriteHeader(status)
+			}))
+		})
+
+		It("should process every delivery once", func() {
+			Expect(sendWith("a")).To(Equal(http.StatusNoContent))
+			Expect(sendWith("a")).To(Equal(http.StatusOK))
+			Expect(sendWith("b")).To(Equal(http.StatusNoContent))
+			Expect(calls).To(Equal(2))
+		})
+
+		It("should reject requests without delivery ID", func() {
+			Expect(sendWith("")).To(Equal(http.StatusBadRequest))
+			Expect(calls).To(BeZero())
+		})
+
+		It("should process failed deliveries again", func() {
+			status = http.StatusInternalServerError
+			Expect(sendWith("a")).To(Equal(http.StatusInternalServerError))
+			status = http.StatusOK
+			Expect(sendWith("a")).To(Equal(http.StatusOK))
+			Expect(sendWith("a")).To(Equal(http.StatusOK))
+			Expect(calls).To(Equal(2))
+		})
+
+		It("should reject deliveries that are being processed", func() {
+			started, release = make(chan struct{}), make(chan struct{})
+			done := make(chan int)
+			go func() {
+				defer GinkgoRecover()
+				done <- sendWith("a")
+			}()
+			<-started
+			Expect(sendWith("a")).To(Equal(http.StatusConflict))
+			close(release)
+			Expect(<-done).To(Equal(http.StatusNoContent))
+		})
+
+		It("should forget processed deliveries after the retention", func() {
+			store.Retention = time.Millisecond
+			Expect(sendWith("a")).To(Equal(http.StatusNoContent))
+			time.Sleep(5 * time.Millisecond)
+			Expect(sendWith("a")).To(Equal(http.StatusNoContent))
+			Expect(calls).To(Equal(2))
+		})
+	})
+})
@@ -0,0 +1,140 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Feature is the name of a feature gate, see FeatureGates
+type Feature string
+
+// Stage is the maturity of a feature, which sets its default
+type Stage string
+
+const (
+	// Alpha features are experimental and disabled unless their gate enables them
+	Alpha Stage = "ALPHA"
+	// Beta features are enabled unless their gate disables them
+	Beta Stage = "BETA"
+)
+
+const (
+	// TaskRunNotifications notifies about the end of standalone TaskRuns, see controller.TaskRunReconciler
+	TaskRunNotifications Feature = "TaskRunNotifications"
+	// ChatOps serves the Slack interactions, e.g. the re-run button, with --slack-signing-secret-file
+	ChatOps Feature = "ChatOps"
+	// ResourceWatches notifies about the resources watched by NotificationServices with --watch-resources
+	ResourceWatches Feature = "ResourceWatches"
+	// MaintenanceWindows silences notifications during the windows of MaintenanceWindows and ClusterMaintenanceWindows
+	MaintenanceWindows Feature = "MaintenanceWindows"
+)
+
+// FeatureSpec describes a feature gate
+type FeatureSpec struct {
+	// Default is whether the feature is enabled when its gate is not set
+	Default bool
+	Stage   Stage
+}
+
+// KnownFeatures are the features that have a gate. New risky features start in the Alpha stage, so they
+// ship disabled, and move to Beta once they proved themselves. Stable features lose their gate.
+var KnownFeatures = map[Feature]FeatureSpec{
+	TaskRunNotifications: {Default: false, Stage: Alpha},
+	ChatOps:              {Default: true, Stage: Beta},
+	ResourceWatches:      {Default: true, Stage: Beta},
+	MaintenanceWindows:   {Default: false, Stage: Alpha},
+}
+
+// FeatureGates enable or disable KnownFeatures, overriding their default. As a flag, they are set from
+// comma separated Feature=bool pairs, e.g. TaskRunNotifications=true,ChatOps=false.
+type FeatureGates map[Feature]bool
+
+// Enabled returns a boolean indicating whether the feature is enabled
+func (g FeatureGates) Enabled(feature Feature) bool {
+	if enabled, ok := g[feature]; ok {
+		return enabled
+	}
+	return KnownFeatures[feature].Default
+}
+
+// Validate returns an error if a gate is not a known feature
+func (g FeatureGates) Validate() error {
+	for feature := range g {
+		if _, ok := KnownFeatures[feature]; !ok {
+			return fmt.Errorf("Unknown feature gate %s", feature)
+		}
+	}
+	return nil
+}
+
+func (g *FeatureGates) String() string {
+	if g == nil {
+		return ""
+	}
+	pairs := make([]string, 0, len(*g))
+	for feature, enabled := range *g {
+		pairs = append(pairs, fmt.Sprintf("%s=%t", feature, enabled))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Set adds the gates of comma separated Feature=bool pairs, so the flag can be repeated
+func (g *FeatureGates) Set(value string) error {
+	gates := FeatureGates{}
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, enabled, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("Invalid feature gate %s, it must be in the form Feature=bool", pair)
+		}
+		feature := Feature(strings.TrimSpace(name))
+		if _, ok := KnownFeatures[feature]; !ok {
+			return fmt.Errorf("Unknown feature gate %s", feature)
+		}
+		parsed, err := strconv.ParseBool(strings.TrimSpace(enabled))
+		if err != nil {
+			return fmt.Errorf("Invalid value %s of feature gate %s: %w", enabled, feature, err)
+		}
+		gates[feature] = parsed
+	}
+	if *g == nil {
+		*g = FeatureGates{}
+	}
+	for feature, enabled := range gates {
+		(*g)[feature] = enabled
+	}
+	return nil
+}
+
+// featureGatesUsage returns the usage of the --feature-gates flag, listing the known features
+func featureGatesUsage() string {
+	features := make([]string, 0, len(KnownFeatures))
+	for feature, spec := range KnownFeatures {
+		features = append(features, fmt.Sprintf("%s=true|false (%s - default=%t)", feature, spec.Stage, spec.Default))
+	}
+	sort.Strings(features)
+	return "A comma separated list of Feature=bool pairs enabling experimental features or disabling others. " +
+		"Options are:\n" + strings.Join(features, "\n")
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager_test
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/pkg/manager"
+)
+
+var _ = Describe("Feature gates", func() {
+	It("should default to the stage of the features", func() {
+		gates := manager.FeatureGates{}
+		Expect(gates.Enabled(manager.TaskRunNotifications)).To(BeFalse())
+		Expect(gates.Enabled(manager.ChatOps)).To(BeTrue())
+		Expect(gates.Enabled("Unknown")).To(BeFalse())
+	})
+
+	It("should be set from repeated flags and the options file", func() {
+		opts := manager.NewOptions()
+		flags := flag.NewFlagSet("manager", flag.ContinueOnError)
+		opts.BindFlags(flags)
+		Expect(opts.Parse(flags, []string{
+			"--feature-gates=TaskRunNotifications=true, ChatOps=false", "--feature-gates=ChatOps=true",
+		})).To(Succeed())
+		Expect(opts.FeatureGates.Enabled(manager.TaskRunNotifications)).To(BeTrue())
+		Expect(opts.FeatureGates.Enabled(manager.ChatOps)).To(BeTrue())
+		Expect(opts.FeatureGates.String()).To(Equal("ChatOps=true,TaskRunNotifications=true"))
+
+		path := filepath.Join(GinkgoT().TempDir(), "options.yaml")
+		Expect(os.WriteFile(path, []byte("feature-gates:\n  ResourceWatches: false\n  TaskRunNotifications: true\n"), 0o600)).To(Succeed())
+		opts = manager.NewOptions()
+		flags = flag.NewFlagSet("manager", flag.ContinueOnError)
+		opts.BindFlags(flags)
+		Expect(opts.Parse(flags, []string{"--options-file", path})).To(Succeed())
+		Expect(opts.FeatureGates).To(Equal(manager.FeatureGates{manager.ResourceWatches: false, manager.TaskRunNotifications: true}))
+	})
+
+	It("should reject unknown features and invalid values", func() {
+		gates := manager.FeatureGates{}
+		Expect(gates.Set("TaskRunNotification=true")).To(MatchError(ContainSubstring("Unknown feature gate TaskRunNotification")))
+		Expect(gates.Set("TaskRunNotifications")).To(MatchError(ContainSubstring("Feature=bool")))
+		Expect(gates.Set("TaskRunNotifications=maybe")).To(MatchError(ContainSubstring("Invalid value maybe")))
+		Expect(gates).To(BeEmpty())
+
+		opts := manager.NewOptions()
+		opts.FeatureGates = manager.FeatureGates{"Unknown": true}
+		Expect(opts.Validate()).To(MatchError(ContainSubstring("Unknown feature gate Unknown")))
+	})
+})
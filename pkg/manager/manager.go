@@ -0,0 +1,779 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	"github.com/konflux-ci/notification-service/api/v1beta1"
+	"github.com/konflux-ci/notification-service/internal/controller"
+	"github.com/konflux-ci/notification-service/pkg/audit"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	"github.com/konflux-ci/notification-service/pkg/transform"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	tektonv1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	zapraw "go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+var setupLog = ctrl.Log.WithName("setup")
+
+// AddToScheme adds the types the controller reads and writes to the scheme
+func AddToScheme(scheme *runtime.Scheme) error {
+	for _, addToScheme := range []func(*runtime.Scheme) error{
+		clientgoscheme.AddToScheme,
+		tektonv1.AddToScheme,
+		tektonv1beta1.AddToScheme,
+		v1alpha1.AddToScheme,
+		v1beta1.AddToScheme,
+	} {
+		if err := addToScheme(scheme); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run sets the logger of controller-runtime and the notifier globals from the options, then runs the manager
+// until the context is cancelled, or replays notifications from Tekton Results if Replay.Since is set.
+// Return error if the options are not valid, the manager cannot be set up or it fails.
+func Run(ctx context.Context, o Options) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	scheme := o.Scheme
+	if scheme == nil {
+		scheme = runtime.NewScheme()
+		if err := AddToScheme(scheme); err != nil {
+			return fmt.Errorf("Failed to build scheme: %w", err)
+		}
+	}
+	var pipelineRunSelector labels.Selector
+	if o.PipelineRunSelector != "" {
+		// Validated above
+		pipelineRunSelector, _ = labels.Parse(o.PipelineRunSelector)
+	}
+
+	defaultLogLevel := zapcore.DebugLevel
+	if o.Zap.Level != nil {
+		defaultLogLevel = zapcore.LevelOf(o.Zap.Level)
+	}
+	logLevels, err := controller.NewLogLevels(defaultLogLevel, o.LogLevels)
+	if err != nil {
+		ctrl.SetLogger(zap.New(zap.UseFlagOptions(&o.Zap)))
+		return fmt.Errorf("Failed to configure log levels: %w", err)
+	}
+	o.Zap.Level = logLevels
+	o.Zap.ZapOpts = append(o.Zap.ZapOpts, zapraw.WrapCore(logLevels.WrapCore))
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&o.Zap)))
+	logLevels.Log = ctrl.Log.WithName("loglevels")
+	if len(o.FeatureGates) > 0 {
+		setupLog.Info("setting feature gates", "featureGates", o.FeatureGates.String())
+	}
+
+	if err := controller.ConfigureMarkers(o.MarkerPrefix); err != nil {
+		return fmt.Errorf("Failed to configure markers: %w", err)
+	}
+	if o.LeaderElection.ID == "" {
+		o.LeaderElection.ID = controller.LeaderElectionID(o.MarkerPrefix)
+	}
+	var dnsCache *notifier.DNSCache
+	if o.DNSCacheMaxTTL > 0 {
+		dnsCache = notifier.NewDNSCache(notifier.DNSCacheOptions{
+			MaxTTL:      o.DNSCacheMaxTTL,
+			NegativeTTL: o.DNSNegativeTTL,
+			Observe:     controller.ObserveDNSResolution,
+		})
+		notifier.EgressTransport = dnsCache.Transport()
+	}
+	if len(o.DestinationAllowlist) > 0 {
+		allowlist, err := notifier.ParseHostAllowlist(o.DestinationAllowlist)
+		if err != nil {
+			return fmt.Errorf("Failed to parse destination allowlist: %w", err)
+		}
+		notifier.EgressAllowlist = allowlist
+		// Requests relayed to the egress gateway are checked by the host of their URL instead
+		if o.EgressGatewayURL == "" {
+			transport := http.DefaultTransport.(*http.Transport).Clone()
+			if dnsCache != nil {
+				transport = dnsCache.Transport()
+				allowlist.Lookup = dnsCache.LookupIPAddr
+			}
+			transport.DialContext = allowlist.DialContext(transport.DialContext)
+			notifier.EgressTransport = transport
+		}
+	}
+	if o.EgressGatewayURL != "" {
+		gateway, err := notifier.NewGatewayTransport(o.EgressGatewayURL, notifier.EgressTransport)
+		if err != nil {
+			return fmt.Errorf("Failed to configure egress gateway: %w", err)
+		}
+		notifier.EgressTransport = gateway
+	}
+	controller.WebhookServiceNamespaces = o.WebhookServiceNamespaces
+	if o.BlockInternalDestinations {
+		var exceptions *notifier.HostAllowlist
+		if len(o.InternalDestinationExceptions) > 0 {
+			exceptions, err = notifier.ParseHostAllowlist(o.InternalDestinationExceptions)
+			if err != nil {
+				return fmt.Errorf("Failed to parse internal destination exceptions: %w", err)
+			}
+		}
+		guard, err := notifier.NewAddressGuard(
+			append(slices.Clone(notifier.DefaultBlockedNetworks), o.BlockedDestinationNetworks...), exceptions)
+		if err != nil {
+			return fmt.Errorf("Failed to parse blocked destination networks: %w", err)
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		if dnsCache != nil {
+			transport = dnsCache.Transport()
+			guard.Lookup = dnsCache.LookupIPAddr
+		}
+		notifier.InstallTenantGuard(guard, transport)
+	}
+
+	if o.SecretScan != "" {
+		var patterns map[string]string
+		if o.SecretScanPatternsFile != "" {
+			data, err := os.ReadFile(o.SecretScanPatternsFile)
+			if err != nil {
+				return fmt.Errorf("Failed to read secret patterns: %w", err)
+			}
+			patterns, err = controller.ParseSecretPatterns(data)
+			if err != nil {
+				return fmt.Errorf("Failed to load secret patterns: %w", err)
+			}
+		}
+		scanner, err := notifier.NewSecretScanner(notifier.SecretScannerOptions{
+			Action:     o.SecretScan,
+			Patterns:   patterns,
+			MinEntropy: o.SecretScanMinEntropy,
+			Observe:    controller.ObserveSecretDetection,
+		})
+		if err != nil {
+			return fmt.Errorf("Failed to configure secret scanning: %w", err)
+		}
+		notifier.PayloadScanner = scanner
+	}
+	notifier.MaxRenderedBytes = o.MaxRenderedBytes
+
+	// if the enable-http2 flag is false (the default), http/2 should be disabled
+	// due to its vulnerabilities. More specifically, disabling http/2 will
+	// prevent from being vulnerable to the HTTP/2 Stream Cancellation and
+	// Rapid Reset CVEs. For more information see:
+	// - https://github.com/advisories/GHSA-qppj-fm5r-hxr3
+	// - https://github.com/advisories/GHSA-4374-p667-p6c8
+	disableHTTP2 := func(c *tls.Config) {
+		setupLog.Info("disabling http/2")
+		c.NextProtos = []string{"http/1.1"}
+	}
+
+	tlsOpts := []func(*tls.Config){}
+	if !o.EnableHTTP2 {
+		tlsOpts = append(tlsOpts, disableHTTP2)
+	}
+
+	webhookServer := webhook.NewServer(webhook.Options{
+		TLSOpts: tlsOpts,
+	})
+
+	cacheOptions := controller.NewCacheOptions()
+	if o.Features.DisableResync {
+		noResync := time.Duration(0)
+		cacheOptions.SyncPeriod = &noResync
+	}
+	restConfig := o.RestConfig
+	if restConfig == nil {
+		restConfig, err = ctrl.GetConfig()
+		if err != nil {
+			return fmt.Errorf("Failed to load kubeconfig: %w", err)
+		}
+	}
+	mgr, err := ctrl.NewManager(restConfig, ctrl.Options{
+		Scheme: scheme,
+		Cache:  cacheOptions,
+		Metrics: metricsserver.Options{
+			BindAddress:   o.MetricsBindAddress,
+			SecureServing: o.SecureMetrics,
+			TLSOpts:       tlsOpts,
+		},
+		WebhookServer:           webhookServer,
+		HealthProbeBindAddress:  o.HealthProbeBindAddress,
+		LeaderElection:          o.LeaderElection.Enabled,
+		LeaderElectionID:        o.LeaderElection.ID,
+		LeaderElectionNamespace: o.LeaderElection.Namespace,
+		LeaseDuration:           &o.LeaderElection.LeaseDuration,
+		RenewDeadline:           &o.LeaderElection.RenewDeadline,
+		RetryPeriod:             &o.LeaderElection.RetryPeriod,
+		// The program ends right after the manager stops, only closing the notifiers, so the
+		// lease is safely released before the reconciliations of the next leader start.
+		LeaderElectionReleaseOnCancel: o.LeaderElection.ReleaseOnCancel,
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to start manager: %w", err)
+	}
+
+	if o.Sigstore.TokenFile != "" {
+		controller.PayloadSigner, err = notifier.NewSigstoreSigner(o.Sigstore)
+		if err != nil {
+			return fmt.Errorf("Failed to create sigstore signer: %w", err)
+		}
+	}
+
+	if o.WebPushVAPIDKeyFile != "" {
+		key, err := os.ReadFile(o.WebPushVAPIDKeyFile)
+		if err != nil {
+			return fmt.Errorf("Failed to read VAPID private key: %w", err)
+		}
+		controller.WebPushVAPIDKeys, err = notifier.NewVAPIDKeys(string(key), o.WebPushSubject)
+		if err != nil {
+			return fmt.Errorf("Failed to load VAPID keys: %w", err)
+		}
+	}
+
+	if o.StatusStylesFile != "" {
+		data, err := os.ReadFile(o.StatusStylesFile)
+		if err != nil {
+			return fmt.Errorf("Failed to read status styles: %w", err)
+		}
+		controller.StatusStyles, err = controller.ParseStatusStyles(data)
+		if err != nil {
+			return fmt.Errorf("Failed to load status styles: %w", err)
+		}
+	}
+
+	var overflow *controller.PayloadOverflow
+	if o.OverflowThresholdBytes > 0 {
+		credentials, err := os.ReadFile(o.OverflowCredentialsFile)
+		if err != nil {
+			return fmt.Errorf("Failed to read overflow bucket credentials: %w", err)
+		}
+		o.Overflow.AccessKeyID, o.Overflow.SecretAccessKey, _ = strings.Cut(strings.TrimSpace(string(credentials)), ":")
+		store, err := notifier.NewS3Store(o.Overflow)
+		if err != nil {
+			return fmt.Errorf("Failed to create overflow bucket client: %w", err)
+		}
+		overflow = &controller.PayloadOverflow{Store: store, ThresholdBytes: o.OverflowThresholdBytes}
+	}
+
+	var archive *controller.PayloadArchive
+	if o.Archive.BucketURL != "" {
+		credentials, err := os.ReadFile(o.ArchiveCredentialsFile)
+		if err != nil {
+			return fmt.Errorf("Failed to read audit bucket credentials: %w", err)
+		}
+		o.Archive.AccessKeyID, o.Archive.SecretAccessKey, _ = strings.Cut(strings.TrimSpace(string(credentials)), ":")
+		store, err := notifier.NewS3Store(o.Archive)
+		if err != nil {
+			return fmt.Errorf("Failed to create audit bucket client: %w", err)
+		}
+		archive = &controller.PayloadArchive{Store: store}
+	}
+
+	var notifiers notifier.MultiNotifier
+	if o.GRPC.Address != "" {
+		grpcNotifier, err := notifier.NewGRPCNotifier(o.GRPC)
+		if err != nil {
+			return fmt.Errorf("Failed to create gRPC notifier: %w", err)
+		}
+		defer grpcNotifier.Close()
+		notifiers = append(notifiers, grpcNotifier)
+	}
+	if len(o.Kafka.Brokers) > 0 {
+		schemaRegistry := &notifier.SchemaRegistryClient{URL: o.SchemaRegistryURL}
+		if o.SchemaRegistryCredentialsFile != "" {
+			credentials, err := os.ReadFile(o.SchemaRegistryCredentialsFile)
+			if err != nil {
+				return fmt.Errorf("Failed to read schema registry credentials: %w", err)
+			}
+			schemaRegistry.Username, schemaRegistry.Password, _ = strings.Cut(strings.TrimSpace(string(credentials)), ":")
+		}
+		o.Kafka.SchemaRegistry = schemaRegistry
+		kafkaNotifier, err := notifier.NewKafkaNotifier(o.Kafka)
+		if err != nil {
+			return fmt.Errorf("Failed to create Kafka notifier: %w", err)
+		}
+		defer kafkaNotifier.Close()
+		notifiers = append(notifiers, kafkaNotifier)
+	}
+	if o.ReportFormat != "" {
+		var store notifier.ReportStore = &controller.ConfigMapReportStore{Client: mgr.GetClient()}
+		if o.ReportURL != "" {
+			httpStore := &notifier.HTTPReportStore{URL: o.ReportURL}
+			if o.ReportTokenFile != "" {
+				token, err := os.ReadFile(o.ReportTokenFile)
+				if err != nil {
+					return fmt.Errorf("Failed to read report token: %w", err)
+				}
+				httpStore.Token = strings.TrimSpace(string(token))
+			}
+			store = httpStore
+		}
+		clientset, err := kubernetes.NewForConfig(mgr.GetConfig())
+		if err != nil {
+			return fmt.Errorf("Failed to create Kubernetes client: %w", err)
+		}
+		reportNotifier, err := notifier.NewReportNotifier(notifier.ReportOptions{
+			Format: o.ReportFormat,
+			Store:  store,
+			Logs:   &controller.PodLogSource{Client: mgr.GetClient(), Pods: clientset.CoreV1(), Lines: o.ReportLogLines},
+		})
+		if err != nil {
+			return fmt.Errorf("Failed to create report notifier: %w", err)
+		}
+		notifiers = append(notifiers, reportNotifier)
+	}
+	var notify notifier.Notifier
+	if len(notifiers) > 0 {
+		notify = notifiers
+	}
+
+	var auditLog *audit.Log
+	if o.AuditLogFile != "" {
+		auditStore, err := audit.NewFileStore(o.AuditLogFile)
+		if err != nil {
+			return fmt.Errorf("Failed to open audit log: %w", err)
+		}
+		defer auditStore.Close()
+		auditLog, err = audit.NewLog(context.Background(), auditStore)
+		if err != nil {
+			return fmt.Errorf("Failed to read audit log: %w", err)
+		}
+	}
+
+	var callbackSecret []byte
+	if o.CallbackBindAddress != "0" {
+		if o.CallbackSecretFile != "" {
+			callbackSecret, err = os.ReadFile(o.CallbackSecretFile)
+			if err != nil {
+				return fmt.Errorf("Failed to read callback secret: %w", err)
+			}
+			callbackSecret = bytes.TrimSpace(callbackSecret)
+		} else {
+			setupLog.Info("no callback secret file is set, callback tokens are only valid for this replica")
+			callbackSecret = make([]byte, 32)
+			if _, err = rand.Read(callbackSecret); err != nil {
+				return fmt.Errorf("Failed to generate callback secret: %w", err)
+			}
+		}
+		callbackServer := &controller.CallbackServer{
+			Client:      mgr.GetClient(),
+			Log:         ctrl.Log.WithName("callback"),
+			BindAddress: o.CallbackBindAddress,
+			Secret:      callbackSecret,
+		}
+		if o.SlackSigningSecretFile != "" && o.FeatureGates.Enabled(ChatOps) {
+			slackSigningSecret, err := os.ReadFile(o.SlackSigningSecretFile)
+			if err != nil {
+				return fmt.Errorf("Failed to read Slack signing secret: %w", err)
+			}
+			callbackServer.SlackActions = &controller.SlackActionHandler{
+				Client:        mgr.GetClient(),
+				Log:           ctrl.Log.WithName("slack"),
+				SigningSecret: bytes.TrimSpace(slackSigningSecret),
+			}
+		}
+		if err = mgr.Add(callbackServer); err != nil {
+			return fmt.Errorf("Failed to set up callback server: %w", err)
+		}
+	} else {
+		o.CallbackURL = ""
+	}
+
+	if o.FeedBindAddress != "0" {
+		if err = mgr.Add(&controller.FeedServer{
+			Client:      mgr.GetClient(),
+			Log:         ctrl.Log.WithName("feed"),
+			BindAddress: o.FeedBindAddress,
+		}); err != nil {
+			return fmt.Errorf("Failed to set up feed server: %w", err)
+		}
+	}
+
+	var slackToken string
+	if o.SlackTokenFile != "" {
+		token, err := os.ReadFile(o.SlackTokenFile)
+		if err != nil {
+			return fmt.Errorf("Failed to read Slack token: %w", err)
+		}
+		slackToken = strings.TrimSpace(string(token))
+	}
+
+	var mentionDirectoryName types.NamespacedName
+	if o.MentionDirectory != "" {
+		namespace, name, _ := strings.Cut(o.MentionDirectory, "/")
+		mentionDirectoryName = types.NamespacedName{Namespace: namespace, Name: name}
+	}
+	var tenantDirectoryName types.NamespacedName
+	if o.TenantDirectory != "" {
+		namespace, name, _ := strings.Cut(o.TenantDirectory, "/")
+		tenantDirectoryName = types.NamespacedName{Namespace: namespace, Name: name}
+	}
+
+	var controllerConfig *controller.ConfigFile
+	if o.ConfigFile != "" {
+		controllerConfig, err = controller.NewConfigFile(o.ConfigFile)
+		if err != nil {
+			return fmt.Errorf("Failed to load controller configuration: %w", err)
+		}
+		controllerConfig.Log = ctrl.Log.WithName("config")
+		controllerConfig.LogLevels = logLevels
+		if levels := controllerConfig.Get().LogLevels; len(levels) > 0 {
+			if err = logLevels.Set(levels); err != nil {
+				return fmt.Errorf("Failed to set log levels: %w", err)
+			}
+		}
+		if err = mgr.Add(controllerConfig); err != nil {
+			return fmt.Errorf("Failed to set up controller configuration reloading: %w", err)
+		}
+	}
+
+	// WebAssembly transforms are configured in the configuration file and compiled on first use
+	transforms := &controller.WasmTransforms{
+		Client:  mgr.GetClient(),
+		Runtime: transform.NewRuntime(context.Background(), transform.Options{}),
+	}
+	reconciler := &controller.NotificationServiceReconciler{
+		Client:              mgr.GetClient(),
+		Scheme:              mgr.GetScheme(),
+		Notifier:            notify,
+		Recorder:            mgr.GetEventRecorderFor("notification-service"),
+		RecordDeliveries:    o.Features.RecordDeliveries,
+		AuditLog:            auditLog,
+		CallbackURL:         o.CallbackURL,
+		CallbackSecret:      callbackSecret,
+		MentionDirectory:    mentionDirectoryName,
+		NamespaceRouting:    o.Features.NamespaceRouting,
+		TenantDirectory:     tenantDirectoryName,
+		MaintenanceWindows:  o.FeatureGates.Enabled(MaintenanceWindows),
+		SlackToken:          slackToken,
+		History:             controller.NewPipelineHistory(),
+		BestEffort:          o.Features.BestEffort,
+		PrioritizeFailures:  o.Features.PrioritizeFailures,
+		DefaultNamespace:    o.DefaultNamespace,
+		ConfigFile:          controllerConfig,
+		Concurrency:         o.Concurrency,
+		PipelineRunSelector: pipelineRunSelector,
+		WaitForChains:       o.WaitForChains,
+		ProvenanceBuilderID: o.ProvenanceBuilderID,
+		Overflow:            overflow,
+		Archive:             archive,
+		Transforms:          transforms,
+		ListPageSize:        o.ListPageSize,
+		Started:             time.Now(),
+	}
+	if !o.Replay.Since.IsZero() {
+		return replay(ctx, mgr, reconciler, transforms, o.Replay)
+	}
+	if o.SLOEvaluationInterval > 0 {
+		reconciler.SLOTracker = &controller.SLOTracker{
+			Client:   mgr.GetClient(),
+			Log:      ctrl.Log.WithName("slo"),
+			Interval: o.SLOEvaluationInterval,
+		}
+		if err = mgr.Add(reconciler.SLOTracker); err != nil {
+			return fmt.Errorf("Failed to set up SLO tracker: %w", err)
+		}
+	}
+	if o.StatusSummaryInterval > 0 {
+		reconciler.StatusSummarizer = &controller.StatusSummarizer{
+			Client:   mgr.GetClient(),
+			Log:      ctrl.Log.WithName("status"),
+			Interval: o.StatusSummaryInterval,
+		}
+		if err = mgr.Add(reconciler.StatusSummarizer); err != nil {
+			return fmt.Errorf("Failed to set up status summarizer: %w", err)
+		}
+	}
+	if o.Features.MigrateMarkers {
+		migrated := make(chan struct{})
+		reconciler.MarkersMigrated = migrated
+		migration := &controller.MarkerMigration{
+			Client:   mgr.GetClient(),
+			Reader:   mgr.GetAPIReader(),
+			PageSize: o.ListPageSize,
+			Started:  reconciler.Started,
+			Log:      ctrl.Log.WithName("migration"),
+			Done:     migrated,
+		}
+		migration.LegacyPrefixes = o.LegacyMarkerPrefixes
+		if err = mgr.Add(migration); err != nil {
+			return fmt.Errorf("Failed to set up marker migration: %w", err)
+		}
+	}
+	if o.SweepInterval > 0 {
+		sweeps := make(chan event.GenericEvent)
+		reconciler.Sweeps = sweeps
+		if err = mgr.Add(&controller.PipelineRunSweeper{
+			Client:    mgr.GetClient(),
+			Log:       ctrl.Log.WithName("sweeper"),
+			Interval:  o.SweepInterval,
+			Selects:   reconciler.ReconcilesPendingPipelineRun,
+			BatchSize: o.SweepBatchSize,
+			Events:    sweeps,
+		}); err != nil {
+			return fmt.Errorf("Failed to set up pipelinerun sweeper: %w", err)
+		}
+	}
+	if err = reconciler.SetupWithManager(mgr); err != nil {
+		return fmt.Errorf("Failed to create NotificationService controller: %w", err)
+	}
+	if o.FeatureGates.Enabled(TaskRunNotifications) {
+		if err = (&controller.TaskRunReconciler{Reconciler: reconciler}).SetupWithManager(mgr); err != nil {
+			return fmt.Errorf("Failed to create TaskRun controller: %w", err)
+		}
+	}
+	if o.Features.WatchCustomRuns {
+		if err = (&controller.CustomRunReconciler{Reconciler: reconciler}).SetupWithManager(mgr); err != nil {
+			return fmt.Errorf("Failed to create CustomRun controller: %w", err)
+		}
+	}
+	if o.Features.WatchArgoWorkflows {
+		if err = (&controller.WorkflowReconciler{Reconciler: reconciler}).SetupWithManager(mgr); err != nil {
+			return fmt.Errorf("Failed to create Workflow controller: %w", err)
+		}
+	}
+	if o.Features.WatchKonfluxReleases {
+		if err = (&controller.ReleaseReconciler{Reconciler: reconciler}).SetupWithManager(mgr); err != nil {
+			return fmt.Errorf("Failed to create Release controller: %w", err)
+		}
+	}
+	watchableKinds, err := controller.ParseWatchableKinds(o.WatchableKinds)
+	if err != nil {
+		return err
+	}
+	if o.Features.WatchResources && o.FeatureGates.Enabled(ResourceWatches) {
+		if err = (&controller.ResourceWatcher{Reconciler: reconciler, Kinds: watchableKinds}).SetupWithManager(mgr); err != nil {
+			return fmt.Errorf("Failed to create ResourceWatch controller: %w", err)
+		}
+	}
+	if o.Features.AdmissionWebhook {
+		if err = (&controller.AdmissionValidator{
+			Client:         mgr.GetClient(),
+			WatchableKinds: watchableKinds,
+			Allowlist:      notifier.EgressAllowlist,
+			Guard:          notifier.TenantGuard,
+		}).SetupWebhookWithManager(mgr); err != nil {
+			return fmt.Errorf("Failed to create Admission webhook: %w", err)
+		}
+	}
+	if o.Features.ConversionWebhook && !o.Features.AdmissionWebhook {
+		if err = ctrl.NewWebhookManagedBy(mgr).For(&v1alpha1.NotificationService{}).Complete(); err != nil {
+			return fmt.Errorf("Failed to create Conversion webhook: %w", err)
+		}
+	}
+	if err = controller.RegisterBacklogMetric(mgr.GetClient(), reconciler.ReconcilesPendingPipelineRun); err != nil {
+		return fmt.Errorf("Failed to register metrics: %w", err)
+	}
+	if o.APIBindAddress != "0" {
+		if err = mgr.Add(&controller.APIServer{
+			Reconciler:  reconciler,
+			Log:         ctrl.Log.WithName("api"),
+			BindAddress: o.APIBindAddress,
+			CertFile:    o.APICertFile,
+			KeyFile:     o.APIKeyFile,
+		}); err != nil {
+			return fmt.Errorf("Failed to set up API server: %w", err)
+		}
+	}
+	if o.AdminBindAddress != "0" {
+		watcher, err := client.NewWithWatch(mgr.GetConfig(), client.Options{Scheme: mgr.GetScheme()})
+		if err != nil {
+			return fmt.Errorf("Failed to create watch client: %w", err)
+		}
+		if err = mgr.Add(&controller.AdminServer{
+			Reconciler:  reconciler,
+			Watcher:     watcher,
+			Log:         ctrl.Log.WithName("admin"),
+			BindAddress: o.AdminBindAddress,
+			CertFile:    o.APICertFile,
+			KeyFile:     o.APIKeyFile,
+		}); err != nil {
+			return fmt.Errorf("Failed to set up admin service: %w", err)
+		}
+	}
+	if o.Features.RecordDeliveries {
+		if err = (&controller.NotificationDeliveryReconciler{
+			Client: mgr.GetClient(),
+			Log:    ctrl.Log.WithName("controllers").WithName("NotificationDelivery"),
+			Scheme: mgr.GetScheme(),
+			TTL:    o.DeliveryRecordTTL,
+		}).SetupWithManager(mgr); err != nil {
+			return fmt.Errorf("Failed to create NotificationDelivery controller: %w", err)
+		}
+	}
+	if o.DestinationProbeInterval > 0 {
+		prober := &controller.DestinationProber{
+			Client:   mgr.GetClient(),
+			Log:      ctrl.Log.WithName("prober"),
+			Notifier: notify,
+			Interval: o.DestinationProbeInterval,
+			Elected:  mgr.Elected(),
+		}
+		if o.DestinationHealthBindAddress != "0" {
+			prober.BindAddress = o.DestinationHealthBindAddress
+		}
+		if err = mgr.Add(prober); err != nil {
+			return fmt.Errorf("Failed to set up destination prober: %w", err)
+		}
+	}
+	if o.Canary.Interval > 0 {
+		canary := &controller.PipelineRunCanary{
+			Reconciler: reconciler,
+			Log:        ctrl.Log.WithName("canary"),
+			Namespace:  o.Canary.Namespace,
+			Interval:   o.Canary.Interval,
+			Timeout:    o.Canary.Timeout,
+		}
+		if o.Canary.PipelineRunFile != "" {
+			data, err := os.ReadFile(o.Canary.PipelineRunFile)
+			if err != nil {
+				return fmt.Errorf("Failed to read canary pipelinerun: %w", err)
+			}
+			canary.PipelineRun, err = controller.ParseCanaryPipelineRun(data)
+			if err != nil {
+				return fmt.Errorf("Failed to load canary pipelinerun: %w", err)
+			}
+		}
+		if err = mgr.Add(canary); err != nil {
+			return fmt.Errorf("Failed to set up canary: %w", err)
+		}
+	}
+	if o.CompanionCollectionInterval > 0 {
+		if err = mgr.Add(&controller.CompanionCollector{
+			Client:    mgr.GetClient(),
+			APIReader: mgr.GetAPIReader(),
+			PageSize:  o.ListPageSize,
+			Log:       ctrl.Log.WithName("collector"),
+			Interval:  o.CompanionCollectionInterval,
+			ReportTTL: o.ReportTTL,
+			StateTTL:  o.NotificationStateTTL,
+		}); err != nil {
+			return fmt.Errorf("Failed to set up companion collector: %w", err)
+		}
+	}
+	if o.BundleRefreshInterval > 0 && controllerConfig != nil {
+		if err = mgr.Add(&controller.BundleSyncer{
+			Client:     mgr.GetClient(),
+			Log:        ctrl.Log.WithName("bundles"),
+			ConfigFile: controllerConfig,
+			Interval:   o.BundleRefreshInterval,
+		}); err != nil {
+			return fmt.Errorf("Failed to set up bundle syncer: %w", err)
+		}
+	}
+	if o.LogLevelsBindAddress != "0" {
+		logLevels.BindAddress = o.LogLevelsBindAddress
+		if err = mgr.Add(logLevels); err != nil {
+			return fmt.Errorf("Failed to set up log levels endpoint: %w", err)
+		}
+	}
+	if err = mgr.Add(&controller.SummaryScheduler{
+		Client: mgr.GetClient(),
+		Log:    ctrl.Log.WithName("summary"),
+	}); err != nil {
+		return fmt.Errorf("Failed to set up summary scheduler: %w", err)
+	}
+	if o.FeatureGates.Enabled(MaintenanceWindows) {
+		if err = mgr.Add(&controller.MaintenanceWindowSummarizer{
+			Client:     mgr.GetClient(),
+			Log:        ctrl.Log.WithName("maintenance"),
+			Reconciler: reconciler,
+		}); err != nil {
+			return fmt.Errorf("Failed to set up maintenance window summarizer: %w", err)
+		}
+	}
+	if o.Setup != nil {
+		if err = o.Setup(mgr); err != nil {
+			return fmt.Errorf("Failed to set up embedder: %w", err)
+		}
+	}
+
+	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
+		return fmt.Errorf("Failed to set up health check: %w", err)
+	}
+	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
+		return fmt.Errorf("Failed to set up ready check: %w", err)
+	}
+
+	setupLog.Info("starting manager")
+	if err := mgr.Start(ctx); err != nil {
+		return fmt.Errorf("Failed to run manager: %w", err)
+	}
+	return nil
+}
+
+// replay replays the notifications about the pipelineruns archived in Tekton Results that completed in the
+// time range through the destinations of the reconciler. The manager is not started, so the reconciler reads
+// from the API server.
+// Return error if the replay could not run or a delivery failed
+func replay(ctx context.Context, mgr ctrl.Manager, reconciler *controller.NotificationServiceReconciler,
+	transforms *controller.WasmTransforms, o ReplayOptions) error {
+	until := o.Until
+	if until.IsZero() {
+		until = time.Now()
+	}
+	results, err := controller.NewTektonResultsClient(controller.TektonResultsOptions{
+		URL:       o.TektonResultsURL,
+		TokenFile: o.TektonResultsTokenFile,
+		CAFile:    o.TektonResultsCAFile,
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to create Tekton Results client: %w", err)
+	}
+	direct, err := client.New(mgr.GetConfig(), client.Options{Scheme: mgr.GetScheme()})
+	if err != nil {
+		return fmt.Errorf("Failed to create client: %w", err)
+	}
+	reconciler.Client = direct
+	reconciler.Log = ctrl.Log.WithName("replay")
+	transforms.Client = direct
+	pipelineRuns, err := results.ListPipelineRuns(ctx, o.Namespace, o.Since, until)
+	if err != nil {
+		return fmt.Errorf("Failed to list pipelineruns from Tekton Results: %w", err)
+	}
+	summary := controller.ReplayPipelineRuns(ctx, reconciler, pipelineRuns,
+		controller.ReplayOptions{Destinations: o.Destinations, DryRun: o.DryRun})
+	setupLog.Info("replayed notifications", "pipelineRuns", summary.PipelineRuns, "delivered", summary.Delivered,
+		"failed", summary.Failed, "skipped", summary.Skipped, "dryRun", o.DryRun)
+	if summary.Failed > 0 {
+		return fmt.Errorf("Failed to replay %d notifications", summary.Failed)
+	}
+	return nil
+}
@@ -0,0 +1,713 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package manager runs the notification controller. The command of the controller parses its Options from
+// flags, environment variables and an options file, and downstream distributions embedding the controller
+// construct them programmatically, starting from NewOptions, and call Run.
+package manager
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/konflux-ci/notification-service/internal/controller"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/yaml"
+)
+
+// EnvironmentPrefix prefixes the environment variables setting the flags of the controller, see Options.Parse
+const EnvironmentPrefix string = "NOTIFICATION_SERVICE_"
+
+// Options configure the controller manager. The fields are documented by the usage of their flag, see BindFlags.
+// Comma separated flags are lists and Replay.Since and Replay.Until are RFC 3339 times.
+type Options struct {
+	// RestConfig is the configuration of the clients of the manager. If not set, it is loaded from --kubeconfig,
+	// the in-cluster configuration or the default kubeconfig.
+	RestConfig *rest.Config
+	// Scheme holds the types of the manager. If not set, a scheme with the types of AddToScheme is used.
+	Scheme *runtime.Scheme
+	// Setup is called with the manager once the controllers and runnables of the options are set up,
+	// before the manager starts, so embedders add their own
+	Setup func(mgr ctrl.Manager) error
+	// OptionsFile is a YAML file of flag values, see Parse
+	OptionsFile string
+
+	MetricsBindAddress     string
+	HealthProbeBindAddress string
+	SecureMetrics          bool
+	EnableHTTP2            bool
+	LeaderElection         LeaderElectionOptions
+	Zap                    zap.Options
+	LogLevels              string
+	LogLevelsBindAddress   string
+
+	ConfigFile           string
+	Concurrency          int
+	PipelineRunSelector  string
+	Features             Features
+	FeatureGates         FeatureGates
+	MarkerPrefix         string
+	LegacyMarkerPrefixes []string
+	DefaultNamespace     string
+	MentionDirectory     string
+	TenantDirectory      string
+	SlackTokenFile       string
+	StatusStylesFile     string
+	WaitForChains        time.Duration
+	ProvenanceBuilderID  string
+	ListPageSize         int64
+
+	SweepInterval                time.Duration
+	SweepBatchSize               int
+	DeliveryRecordTTL            time.Duration
+	DestinationProbeInterval     time.Duration
+	DestinationHealthBindAddress string
+	SLOEvaluationInterval        time.Duration
+	StatusSummaryInterval        time.Duration
+	CompanionCollectionInterval  time.Duration
+	ReportTTL                    time.Duration
+	NotificationStateTTL         time.Duration
+	BundleRefreshInterval        time.Duration
+	Canary                       CanaryOptions
+
+	GRPC                          notifier.GRPCOptions
+	Kafka                         notifier.KafkaOptions
+	SchemaRegistryURL             string
+	SchemaRegistryCredentialsFile string
+	ReportFormat                  string
+	ReportURL                     string
+	ReportTokenFile               string
+	ReportLogLines                int64
+	Sigstore                      notifier.SigstoreOptions
+	WebPushVAPIDKeyFile           string
+	WebPushSubject                string
+	MaxRenderedBytes              int
+	OverflowThresholdBytes        int
+	Overflow                      notifier.S3Options
+	OverflowCredentialsFile       string
+	AuditLogFile                  string
+	Archive                       notifier.S3Options
+	ArchiveCredentialsFile        string
+
+	EgressGatewayURL              string
+	DestinationAllowlist          []string
+	BlockInternalDestinations     bool
+	BlockedDestinationNetworks    []string
+	InternalDestinationExceptions []string
+	WebhookServiceNamespaces      []string
+	WatchableKinds                []string
+	DNSCacheMaxTTL                time.Duration
+	DNSNegativeTTL                time.Duration
+	SecretScan                    string
+	SecretScanPatternsFile        string
+	SecretScanMinEntropy          float64
+
+	CallbackBindAddress    string
+	CallbackURL            string
+	CallbackSecretFile     string
+	SlackSigningSecretFile string
+	FeedBindAddress        string
+	APIBindAddress         string
+	AdminBindAddress       string
+	APICertFile            string
+	APIKeyFile             string
+
+	Replay ReplayOptions
+}
+
+// LeaderElectionOptions configure the election of the replica running the controllers
+type LeaderElectionOptions struct {
+	Enabled         bool
+	ID              string
+	Namespace       string
+	LeaseDuration   time.Duration
+	RenewDeadline   time.Duration
+	RetryPeriod     time.Duration
+	ReleaseOnCancel bool
+}
+
+// Features enable the optional controllers, webhooks and behaviors of the manager
+type Features struct {
+	WatchCustomRuns      bool
+	WatchArgoWorkflows   bool
+	WatchKonfluxReleases bool
+	WatchResources       bool
+	AdmissionWebhook     bool
+	ConversionWebhook    bool
+	NamespaceRouting     bool
+	RecordDeliveries     bool
+	BestEffort           bool
+	PrioritizeFailures   bool
+	MigrateMarkers       bool
+	DisableResync        bool
+}
+
+// CanaryOptions configure the synthetic canary pipelineruns, which are created if Interval is set
+type CanaryOptions struct {
+	Interval        time.Duration
+	Timeout         time.Duration
+	Namespace       string
+	PipelineRunFile string
+}
+
+// ReplayOptions configure the replay of the notifications about the pipelineruns archived in Tekton Results.
+// If Since is set, Run replays them instead of starting the manager.
+type ReplayOptions struct {
+	TektonResultsURL       string
+	TektonResultsTokenFile string
+	TektonResultsCAFile    string
+	Since                  time.Time
+	Until                  time.Time
+	Namespace              string
+	Destinations           []string
+	DryRun                 bool
+}
+
+// NewOptions returns the options of the controller started without flags
+func NewOptions() Options {
+	return Options{
+		MetricsBindAddress:     "0",
+		HealthProbeBindAddress: ":8081",
+		LeaderElection: LeaderElectionOptions{
+			LeaseDuration:   15 * time.Second,
+			RenewDeadline:   10 * time.Second,
+			RetryPeriod:     2 * time.Second,
+			ReleaseOnCancel: true,
+		},
+		Zap:                  zap.Options{Development: true},
+		LogLevelsBindAddress: "0",
+		Features: Features{
+			PrioritizeFailures: true,
+			MigrateMarkers:     true,
+		},
+		WatchableKinds:               slices.Clone(controller.DefaultWatchableKinds),
+		MarkerPrefix:                 controller.DefaultMarkerPrefix,
+		ProvenanceBuilderID:          controller.DefaultProvenanceBuilderID,
+		ListPageSize:                 controller.DefaultListPageSize,
+		SweepInterval:                controller.DefaultSweepInterval,
+		DeliveryRecordTTL:            controller.DefaultDeliveryRecordTTL,
+		DestinationHealthBindAddress: "0",
+		SLOEvaluationInterval:        controller.DefaultSLOEvaluationInterval,
+		StatusSummaryInterval:        controller.DefaultStatusSummaryInterval,
+		CompanionCollectionInterval:  controller.DefaultCompanionCollectionInterval,
+		BundleRefreshInterval:        controller.DefaultBundleRefreshInterval,
+		Canary:                       CanaryOptions{Timeout: controller.DefaultCanaryTimeout},
+		GRPC: notifier.GRPCOptions{
+			Timeout:        notifier.DefaultGRPCTimeout,
+			MaxAttempts:    notifier.DefaultGRPCMaxAttempts,
+			InitialBackoff: notifier.DefaultGRPCInitialBackoff,
+			MaxBackoff:     notifier.DefaultGRPCMaxBackoff,
+		},
+		Kafka: notifier.KafkaOptions{
+			Encoding: notifier.KafkaEncodingJSON,
+			Timeout:  notifier.DefaultKafkaTimeout,
+		},
+		ReportLogLines: controller.DefaultReportLogLines,
+		Sigstore: notifier.SigstoreOptions{
+			FulcioURL: notifier.DefaultFulcioURL,
+			RekorURL:  notifier.DefaultRekorURL,
+		},
+		MaxRenderedBytes:     notifier.DefaultMaxRenderedBytes,
+		Overflow:             notifier.S3Options{Region: "us-east-1", URLExpiry: notifier.DefaultObjectURLExpiry},
+		Archive:              notifier.S3Options{Region: "us-east-1", URLExpiry: notifier.DefaultObjectURLExpiry},
+		DNSNegativeTTL:       notifier.DefaultDNSNegativeTTL,
+		SecretScanMinEntropy: notifier.DefaultSecretMinEntropy,
+		CallbackBindAddress:  "0",
+		FeedBindAddress:      "0",
+		APIBindAddress:       "0",
+		AdminBindAddress:     "0",
+	}
+}
+
+// BindFlags binds the fields of the options to flags of the flag set, whose defaults are the current values of the fields
+func (o *Options) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.OptionsFile, "options-file", o.OptionsFile,
+		"A YAML file mapping flag names to values, usually mounted from a ConfigMap, setting the flags that are "+
+			"neither on the command line nor set by "+EnvironmentPrefix+"<FLAG> environment variables")
+	fs.StringVar(&o.MetricsBindAddress, "metrics-bind-address", o.MetricsBindAddress, "The address the metric endpoint binds to. "+
+		"Use the port :8080. If not set, it will be 0 in order to disable the metrics server")
+	fs.StringVar(&o.HealthProbeBindAddress, "health-probe-bind-address", o.HealthProbeBindAddress, "The address the probe endpoint binds to.")
+	fs.BoolVar(&o.LeaderElection.Enabled, "leader-elect", o.LeaderElection.Enabled,
+		"Enable leader election for controller manager. "+
+			"Enabling this will ensure there is only one active controller manager.")
+	fs.StringVar(&o.LeaderElection.ID, "leader-election-id", o.LeaderElection.ID,
+		"The name of the leader election lease. If not set, it is derived from --marker-prefix, "+
+			"so instances with different prefixes elect their leaders independently")
+	fs.StringVar(&o.LeaderElection.Namespace, "leader-election-namespace", o.LeaderElection.Namespace,
+		"The namespace of the leader election lease. If not set, it is the namespace the controller runs in")
+	fs.DurationVar(&o.LeaderElection.LeaseDuration, "leader-elect-lease-duration", o.LeaderElection.LeaseDuration,
+		"How long replicas that are not the leader wait before acquiring an expired lease")
+	fs.DurationVar(&o.LeaderElection.RenewDeadline, "leader-elect-renew-deadline", o.LeaderElection.RenewDeadline,
+		"How long the leader retries renewing its lease before stepping down. It must be less than the lease duration")
+	fs.DurationVar(&o.LeaderElection.RetryPeriod, "leader-elect-retry-period", o.LeaderElection.RetryPeriod,
+		"How often replicas try to acquire or renew the lease")
+	fs.BoolVar(&o.LeaderElection.ReleaseOnCancel, "leader-elect-release-on-cancel", o.LeaderElection.ReleaseOnCancel,
+		"If set, the leader releases its lease when it stops, e.g. when its node is drained, "+
+			"so another replica takes over without waiting for the lease to expire")
+	fs.BoolVar(&o.SecureMetrics, "metrics-secure", o.SecureMetrics,
+		"If set the metrics endpoint is served securely")
+	fs.BoolVar(&o.EnableHTTP2, "enable-http2", o.EnableHTTP2,
+		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	fs.StringVar(&o.GRPC.Address, "grpc-address", o.GRPC.Address,
+		"The address of a gRPC notification receiver. If not set, gRPC notifications are disabled")
+	fs.BoolVar(&o.GRPC.Insecure, "grpc-insecure", o.GRPC.Insecure,
+		"If set, notifications are sent to the gRPC receiver without transport security")
+	fs.StringVar(&o.GRPC.CAFile, "grpc-ca-file", o.GRPC.CAFile,
+		"A PEM file with the CA certificates used to verify the gRPC receiver")
+	fs.StringVar(&o.GRPC.CertFile, "grpc-cert-file", o.GRPC.CertFile, "A client certificate file for mutual TLS with the gRPC receiver")
+	fs.StringVar(&o.GRPC.KeyFile, "grpc-key-file", o.GRPC.KeyFile, "A client key file for mutual TLS with the gRPC receiver")
+	fs.StringVar(&o.GRPC.ServerName, "grpc-server-name", o.GRPC.ServerName,
+		"Overrides the server name used to verify the gRPC receiver certificate")
+	fs.DurationVar(&o.GRPC.Timeout, "grpc-timeout", o.GRPC.Timeout,
+		"The deadline of a single gRPC notification, including its retries")
+	fs.IntVar(&o.GRPC.MaxAttempts, "grpc-max-attempts", o.GRPC.MaxAttempts,
+		"The maximum number of attempts of a gRPC notification")
+	fs.DurationVar(&o.GRPC.InitialBackoff, "grpc-initial-backoff", o.GRPC.InitialBackoff,
+		"The initial delay between gRPC notification attempts")
+	fs.DurationVar(&o.GRPC.MaxBackoff, "grpc-max-backoff", o.GRPC.MaxBackoff,
+		"The maximum delay between gRPC notification attempts")
+	fs.Var((*commaSeparated)(&o.Kafka.Brokers), "kafka-brokers",
+		"A comma separated list of Kafka bootstrap brokers. If not set, Kafka notifications are disabled")
+	fs.StringVar(&o.Kafka.Topic, "kafka-topic", o.Kafka.Topic, "The Kafka topic notifications are produced to")
+	fs.StringVar(&o.Kafka.Encoding, "kafka-encoding", o.Kafka.Encoding,
+		"The encoding of Kafka messages: json, avro or protobuf. avro and protobuf require --schema-registry-url")
+	fs.DurationVar(&o.Kafka.Timeout, "kafka-timeout", o.Kafka.Timeout,
+		"The deadline for producing a single Kafka notification")
+	fs.StringVar(&o.SchemaRegistryURL, "schema-registry-url", o.SchemaRegistryURL, "The URL of a Confluent compatible schema registry")
+	fs.StringVar(&o.SchemaRegistryCredentialsFile, "schema-registry-credentials-file", o.SchemaRegistryCredentialsFile,
+		"A file containing basic auth credentials for the schema registry in the form username:password")
+	fs.BoolVar(&o.Features.RecordDeliveries, "record-deliveries", o.Features.RecordDeliveries,
+		"If set, every delivery attempt is recorded as a NotificationDelivery in the namespace of the PipelineRun")
+	fs.DurationVar(&o.DeliveryRecordTTL, "delivery-record-ttl", o.DeliveryRecordTTL,
+		"The time NotificationDelivery records are kept for before they are deleted")
+	fs.DurationVar(&o.SweepInterval, "sweep-interval", o.SweepInterval,
+		"How often PipelineRuns that ended without being handled are reconciled again, after a sweep on startup. "+
+			"If set to 0, PipelineRuns are not swept")
+	fs.IntVar(&o.SweepBatchSize, "sweep-batch-size", o.SweepBatchSize,
+		"The maximum number of PipelineRuns reconciled again per sweep, those that ended first. If set to 0, all of them are")
+	fs.Int64Var(&o.ListPageSize, "list-page-size", o.ListPageSize,
+		"The number of objects read per request when companion objects and watched resources are listed from the API server. "+
+			"If set to 0, they are listed with a single request")
+	fs.BoolVar(&o.Features.DisableResync, "disable-resync", o.Features.DisableResync,
+		"If set, the cached PipelineRuns and other objects are not periodically resynced, which reconciles all of them again "+
+			"every 10 hours by default. Sweeps still reconcile the PipelineRuns that ended without being handled")
+	fs.BoolVar(&o.Features.BestEffort, "best-effort", o.Features.BestEffort,
+		"If set, PipelineRuns are not held with a finalizer until they are handled, unless a NotificationService "+
+			"sets bestEffort to false. Notifications rely on watch events and sweeps")
+	fs.StringVar(&o.MarkerPrefix, "marker-prefix", o.MarkerPrefix,
+		"The prefix of the finalizer and annotations set on PipelineRuns. Instances of the service handling "+
+			"the same PipelineRuns, e.g. staging and production, must use different prefixes")
+	fs.BoolVar(&o.Features.MigrateMarkers, "migrate-markers", o.Features.MigrateMarkers,
+		"If set, the markers set on existing PipelineRuns by earlier versions of the service are rewritten on startup, "+
+			"before PipelineRuns are reconciled")
+	fs.Var((*commaSeparated)(&o.LegacyMarkerPrefixes), "legacy-marker-prefixes",
+		"A comma separated list of marker prefixes used by earlier versions of the service, whose finalizer and "+
+			"annotations are renamed to those of --marker-prefix by the marker migration")
+	fs.BoolVar(&o.Features.PrioritizeFailures, "prioritize-failures", o.Features.PrioritizeFailures,
+		"If set, failed PipelineRuns are notified about before the other queued PipelineRuns")
+	fs.StringVar(&o.DefaultNamespace, "default-namespace", o.DefaultNamespace,
+		"A namespace whose NotificationServices apply to the PipelineRuns of the namespaces without NotificationServices. "+
+			"If not set, only the NotificationServices of the PipelineRun namespace apply")
+	fs.StringVar(&o.Sigstore.TokenFile, "sigstore-token-file", o.Sigstore.TokenFile,
+		"A file containing the OIDC token, e.g. a projected service account token, webhook bodies are signed "+
+			"keyless with. If not set, webhook destinations cannot enable signing")
+	fs.StringVar(&o.Sigstore.FulcioURL, "sigstore-fulcio-url", o.Sigstore.FulcioURL,
+		"The URL of the Fulcio certificate authority issuing signing certificates")
+	fs.StringVar(&o.Sigstore.RekorURL, "sigstore-rekor-url", o.Sigstore.RekorURL,
+		"The URL of the Rekor transparency log signatures are recorded in")
+	fs.DurationVar(&o.WaitForChains, "wait-for-chains", o.WaitForChains,
+		"How long ended PipelineRuns wait for Tekton Chains to sign them before they are notified about. "+
+			"If not set, PipelineRuns are notified about without waiting")
+	fs.StringVar(&o.ProvenanceBuilderID, "provenance-builder-id", o.ProvenanceBuilderID,
+		"The builder ID of the provenance of PipelineRuns whose Tekton Chains attestation is not stored in their annotations")
+	fs.IntVar(&o.MaxRenderedBytes, "max-rendered-bytes", o.MaxRenderedBytes,
+		"The size above which rendering templates, reports and XML payloads fails instead of building them in memory. "+
+			"0 disables the limit")
+	fs.IntVar(&o.OverflowThresholdBytes, "overflow-threshold-bytes", o.OverflowThresholdBytes,
+		"The combined size of results above which the full notification is uploaded to --overflow-bucket-url "+
+			"and sent without its largest results but with a signed URL. If not set, results are not uploaded")
+	fs.StringVar(&o.Overflow.BucketURL, "overflow-bucket-url", o.Overflow.BucketURL,
+		"The URL of the S3 compatible bucket notifications with large results are uploaded to")
+	fs.StringVar(&o.Overflow.Region, "overflow-bucket-region", o.Overflow.Region, "The region of the overflow bucket")
+	fs.StringVar(&o.OverflowCredentialsFile, "overflow-credentials-file", o.OverflowCredentialsFile,
+		"A file containing the credentials of the overflow bucket in the form accessKeyID:secretAccessKey")
+	fs.DurationVar(&o.Overflow.URLExpiry, "overflow-url-expiry", o.Overflow.URLExpiry,
+		"How long the signed URLs of uploaded notifications are valid, at most 7 days")
+	fs.StringVar(&o.WebPushVAPIDKeyFile, "webpush-vapid-key-file", o.WebPushVAPIDKeyFile,
+		"A file containing the base64url encoded VAPID private key identifying the controller to push services. "+
+			"If not set, web push destinations are not supported")
+	fs.StringVar(&o.WebPushSubject, "webpush-subject", o.WebPushSubject,
+		"The mailto: or https: URL push services may use to contact the operator of the controller")
+	fs.StringVar(&o.StatusStylesFile, "status-styles-file", o.StatusStylesFile,
+		"A YAML file mapping statuses to the emoji, icon and color of Slack and Matrix messages, overriding the defaults")
+	fs.StringVar(&o.ConfigFile, "config", o.ConfigFile,
+		"A YAML file, usually mounted from a ConfigMap, configuring the concurrency, the PipelineRun selector, "+
+			"the default destinations and the retry policy. Changes are applied without restarting, except for the concurrency")
+	fs.StringVar(&o.AuditLogFile, "audit-log-file", o.AuditLogFile,
+		"If set, every outbound notification is appended to a hash chained audit log at this path")
+	fs.StringVar(&o.Archive.BucketURL, "audit-bucket-url", o.Archive.BucketURL,
+		"The URL of the S3 compatible bucket every notification is uploaded to in full before it is sent, "+
+			"with a link to it. If not set, notifications are not archived")
+	fs.StringVar(&o.Archive.Region, "audit-bucket-region", o.Archive.Region, "The region of the audit bucket")
+	fs.StringVar(&o.ArchiveCredentialsFile, "audit-credentials-file", o.ArchiveCredentialsFile,
+		"A file containing the credentials of the audit bucket in the form accessKeyID:secretAccessKey")
+	fs.DurationVar(&o.Archive.URLExpiry, "audit-url-expiry", o.Archive.URLExpiry,
+		"How long the signed URLs of archived notifications are valid, at most 7 days")
+	fs.StringVar(&o.CallbackBindAddress, "callback-bind-address", o.CallbackBindAddress, "The address the acknowledgement endpoint binds to. "+
+		"If not set, it will be 0 in order to disable acknowledgements")
+	fs.StringVar(&o.CallbackURL, "callback-url", o.CallbackURL,
+		"The external URL of the acknowledgement endpoint that is sent to destinations with an acknowledgement timeout")
+	fs.StringVar(&o.CallbackSecretFile, "callback-secret-file", o.CallbackSecretFile,
+		"A file containing the secret used to sign callback tokens. It must be shared by all replicas")
+	fs.StringVar(&o.SlackSigningSecretFile, "slack-signing-secret-file", o.SlackSigningSecretFile,
+		"A file containing the signing secret of the Slack app. If set, Slack interactions such as the re-run "+
+			"button are served on the callback endpoint")
+	fs.StringVar(&o.MentionDirectory, "mention-directory", o.MentionDirectory,
+		"The namespace/name of a ConfigMap mapping pipeline authors to their chat handles and emails")
+	fs.BoolVar(&o.Features.NamespaceRouting, "namespace-routing", o.Features.NamespaceRouting,
+		"If set, notifications are also sent to the destinations declared in the annotations of the PipelineRun namespace")
+	fs.StringVar(&o.TenantDirectory, "tenant-directory", o.TenantDirectory,
+		"The namespace/name of a ConfigMap registering the contact channels of Konflux tenants. With --namespace-routing, "+
+			"namespaces without routing annotations are routed to the channels of the tenant owning them")
+	fs.StringVar(&o.EgressGatewayURL, "egress-gateway-url", o.EgressGatewayURL,
+		"If set, all outbound HTTP requests of notifiers, reports and signatures are relayed to this gateway, "+
+			"with their original URL in the "+notifier.GatewayDestinationHeader+" header")
+	fs.Var((*commaSeparated)(&o.DestinationAllowlist), "destination-allowlist",
+		"A comma separated list of domains, wildcard domains (*.example.com), IP addresses and CIDRs. If set, notifiers only "+
+			"connect to hosts that match a domain or resolve to an address in a CIDR, and NotificationServices with other "+
+			"destination hosts are rejected by the admission webhook")
+	fs.BoolVar(&o.BlockInternalDestinations, "block-internal-destinations", o.BlockInternalDestinations,
+		"If set, the destinations of NotificationServices do not connect to hosts resolving to loopback, link-local, "+
+			"metadata service or private addresses, which are checked again on every connection, and NotificationServices "+
+			"with such destination hosts are rejected by the admission webhook")
+	fs.Var((*commaSeparated)(&o.BlockedDestinationNetworks), "blocked-destination-networks",
+		"A comma separated list of IP addresses and CIDRs, e.g. the pod and service CIDRs of the cluster, blocked with "+
+			"--block-internal-destinations in addition to the default internal networks")
+	fs.Var((*commaSeparated)(&o.InternalDestinationExceptions), "internal-destination-exceptions",
+		"A comma separated list of domains, wildcard domains (*.svc), IP addresses and CIDRs that the destinations of "+
+			"NotificationServices may connect to with --block-internal-destinations")
+	fs.Var((*commaSeparated)(&o.WebhookServiceNamespaces), "webhook-service-namespaces",
+		"A comma separated list of namespaces whose Services the webhook destinations of NotificationServices may "+
+			"reference, in addition to the namespace of the NotificationService")
+	fs.DurationVar(&o.DNSCacheMaxTTL, "dns-cache-max-ttl", o.DNSCacheMaxTTL,
+		"If set, the addresses of destination hosts are cached for the TTL of their records, up to this duration. "+
+			"Hosts that do not resolve then fail fast instead of holding the delivery workers")
+	fs.DurationVar(&o.DNSNegativeTTL, "dns-negative-ttl", o.DNSNegativeTTL,
+		"How long failed resolutions of destination hosts are cached for, with --dns-cache-max-ttl")
+	fs.StringVar(&o.SecretScan, "secret-scan", o.SecretScan,
+		"If set, rendered payloads are scanned for tokens and high entropy strings before they are sent, and the "+
+			"secrets found are redacted (redact) or the payload is not sent (block)")
+	fs.StringVar(&o.SecretScanPatternsFile, "secret-scan-patterns-file", o.SecretScanPatternsFile,
+		"A YAML file mapping rule names to regular expressions of secrets detected by --secret-scan, "+
+			"in addition to the tokens of common services")
+	fs.Float64Var(&o.SecretScanMinEntropy, "secret-scan-min-entropy", o.SecretScanMinEntropy,
+		"The entropy in bits per character above which words are detected as secrets by --secret-scan. If negative, "+
+			"secrets are only detected by patterns")
+	fs.StringVar(&o.SlackTokenFile, "slack-token-file", o.SlackTokenFile,
+		"A file containing the Slack bot token used for channels declared in namespace annotations")
+	fs.StringVar(&o.ReportFormat, "report-format", o.ReportFormat,
+		"If set, a report of every completed PipelineRun is rendered in this format: markdown or html")
+	fs.StringVar(&o.ReportURL, "report-url", o.ReportURL,
+		"The object storage URL reports are uploaded to with HTTP PUT. If not set, reports are stored in ConfigMaps")
+	fs.StringVar(&o.ReportTokenFile, "report-token-file", o.ReportTokenFile,
+		"A file containing the bearer token used to upload reports to --report-url")
+	fs.StringVar(&o.FeedBindAddress, "feed-bind-address", o.FeedBindAddress, "The address the Atom feed endpoint binds to. "+
+		"If not set, it will be 0 in order to disable feeds")
+	fs.StringVar(&o.APIBindAddress, "api-bind-address", o.APIBindAddress, "The address the REST API binds to. "+
+		"If not set, it will be 0 in order to disable the API")
+	fs.StringVar(&o.AdminBindAddress, "admin-bind-address", o.AdminBindAddress, "The address the gRPC admin service binds to. "+
+		"If not set, it will be 0 in order to disable the admin service")
+	fs.StringVar(&o.APICertFile, "api-cert-file", o.APICertFile, "A certificate file to serve the REST API and the admin service over TLS")
+	fs.StringVar(&o.APIKeyFile, "api-key-file", o.APIKeyFile, "The key file of --api-cert-file")
+	fs.DurationVar(&o.DestinationProbeInterval, "destination-probe-interval", o.DestinationProbeInterval,
+		"How often the reachability of destinations is probed. If not set, destinations are not probed")
+	fs.DurationVar(&o.SLOEvaluationInterval, "slo-evaluation-interval", o.SLOEvaluationInterval,
+		"How often the delivery SLOs of NotificationServices are evaluated. If 0, deliveries are not accounted for SLOs")
+	fs.DurationVar(&o.StatusSummaryInterval, "status-summary-interval", o.StatusSummaryInterval,
+		"How often the delivery summary and the Ready condition of NotificationServices are updated. If 0, they are not set")
+	fs.StringVar(&o.DestinationHealthBindAddress, "destination-health-bind-address", o.DestinationHealthBindAddress, "The address the "+
+		controller.DestinationHealthPath+" endpoint binds to. If not set, it will be 0 in order to disable the endpoint")
+	fs.DurationVar(&o.Canary.Interval, "canary-interval", o.Canary.Interval,
+		"How often a synthetic canary pipelinerun is created to verify notifications are delivered. If not set, no canary runs")
+	fs.DurationVar(&o.Canary.Timeout, "canary-timeout", o.Canary.Timeout,
+		"How long a canary pipelinerun has to run and be delivered to every destination")
+	fs.StringVar(&o.Canary.Namespace, "canary-namespace", o.Canary.Namespace,
+		"The namespace canary pipelineruns are created in, required by --canary-interval")
+	fs.StringVar(&o.Canary.PipelineRunFile, "canary-pipelinerun-file", o.Canary.PipelineRunFile,
+		"A YAML file of the canary pipelinerun. If not set, a pipelinerun of a single step that succeeds immediately is used")
+	fs.BoolVar(&o.Features.WatchCustomRuns, "watch-customruns", o.Features.WatchCustomRuns,
+		"If set, the end of Tekton CustomRuns is notified about as well as the end of PipelineRuns")
+	fs.BoolVar(&o.Features.WatchArgoWorkflows, "watch-argo-workflows", o.Features.WatchArgoWorkflows,
+		"If set, the end of Argo Workflows is notified about as well as the end of PipelineRuns. The Argo Workflow CRD must be installed")
+	fs.BoolVar(&o.Features.WatchKonfluxReleases, "watch-konflux-releases", o.Features.WatchKonfluxReleases,
+		"If set, the end of Konflux Releases is notified about as well as the end of PipelineRuns. The Konflux Release CRD must be installed")
+	fs.BoolVar(&o.Features.WatchResources, "watch-resources", o.Features.WatchResources,
+		"If set, NotificationServices with a watch notify about the resources they watch. "+
+			"The controller must be granted get, list, watch and patch on these resources")
+	fs.Var((*commaSeparated)(&o.WatchableKinds), "watchable-kinds",
+		"A comma separated list of the kinds of resources, as <kind>.<group> or <kind> for the core group, "+
+			"NotificationServices may watch with --watch-resources. Secrets are not watchable by default")
+	fs.DurationVar(&o.CompanionCollectionInterval, "companion-collection-interval", o.CompanionCollectionInterval,
+		"How often orphaned and expired reports and notification states are deleted. If 0, they are only deleted with their pipelinerun")
+	fs.DurationVar(&o.BundleRefreshInterval, "bundle-refresh-interval", o.BundleRefreshInterval,
+		"How often the configuration bundles of the controller configuration are pulled and applied. If 0, bundles are not applied")
+	fs.DurationVar(&o.ReportTTL, "report-ttl", o.ReportTTL,
+		"How long report ConfigMaps are kept. If not set, they are kept as long as their pipelinerun")
+	fs.DurationVar(&o.NotificationStateTTL, "notification-state-ttl", o.NotificationStateTTL,
+		"How long the notification states of handled pipelineruns are kept. If not set, they are kept as long as their pipelinerun")
+	fs.BoolVar(&o.Features.AdmissionWebhook, "enable-admission-webhook", o.Features.AdmissionWebhook,
+		"If set, the webhook server rejects NotificationServices and NotificationTemplates whose templates reference "+
+			"fields or results denied by PayloadPolicies, and NotificationServices with destination hosts outside "+
+			"--destination-allowlist or blocked by --block-internal-destinations. The webhook server requires a serving certificate")
+	fs.BoolVar(&o.Features.ConversionWebhook, "enable-conversion-webhook", o.Features.ConversionWebhook,
+		"If set, the webhook server converts NotificationServices between the v1alpha1 and v1beta1 versions, "+
+			"as required to serve v1beta1. It is always served along with the admission webhook")
+	fs.Int64Var(&o.ReportLogLines, "report-log-lines", o.ReportLogLines,
+		"The number of log lines of every failed step included in reports")
+	fs.StringVar(&o.Replay.TektonResultsURL, "tekton-results-url", o.Replay.TektonResultsURL,
+		"The address of the Tekton Results API the pipelineruns replayed with --replay-since are read from, "+
+			"e.g. https://tekton-results-api-service.tekton-pipelines.svc:8080")
+	fs.StringVar(&o.Replay.TektonResultsTokenFile, "tekton-results-token-file", o.Replay.TektonResultsTokenFile,
+		"A file with the bearer token authenticating the requests to the Tekton Results API, e.g. a service account token")
+	fs.StringVar(&o.Replay.TektonResultsCAFile, "tekton-results-ca-file", o.Replay.TektonResultsCAFile,
+		"A PEM file with the CA certificates verifying the Tekton Results API. If not set, the system pool is used")
+	fs.Var((*rfc3339Time)(&o.Replay.Since), "replay-since",
+		"If set, the manager does not start: it replays the notifications about the pipelineruns archived in Tekton Results "+
+			"that completed since this RFC 3339 time, through the configured destinations, and exits")
+	fs.Var((*rfc3339Time)(&o.Replay.Until), "replay-until",
+		"The RFC 3339 time up to which completed pipelineruns are replayed. If not set, up to now")
+	fs.StringVar(&o.Replay.Namespace, "replay-namespace", o.Replay.Namespace,
+		"The namespace whose pipelineruns are replayed. If not set, the pipelineruns of all namespaces are")
+	fs.Var((*commaSeparated)(&o.Replay.Destinations), "replay-destinations",
+		"Comma separated names of the destinations notifications are replayed to, as recorded in deliveries. If not set, all of them")
+	fs.BoolVar(&o.Replay.DryRun, "replay-dry-run", o.Replay.DryRun,
+		"If set, the notifications that would be replayed are logged without being sent")
+	fs.StringVar(&o.LogLevels, "log-levels", o.LogLevels,
+		"The log levels of named loggers and their descendants, overriding --zap-log-level, e.g. prober=debug,controller-runtime=error")
+	fs.StringVar(&o.LogLevelsBindAddress, "log-levels-bind-address", o.LogLevelsBindAddress, "The address the "+controller.LogLevelsPath+
+		" endpoint reading and changing the log levels binds to. The endpoint is not authenticated, so it must be a loopback "+
+		"address, e.g. 127.0.0.1:8083. If not set, it will be 0 in order to disable the endpoint")
+	fs.Var(&o.FeatureGates, "feature-gates", featureGatesUsage())
+	fs.IntVar(&o.Concurrency, "concurrency", o.Concurrency,
+		"The number of PipelineRuns reconciled in parallel when the configuration file does not set the concurrency")
+	fs.StringVar(&o.PipelineRunSelector, "pipelinerun-selector", o.PipelineRunSelector,
+		"A label selector restricting the PipelineRuns that are notified about when the configuration file does not set one")
+	o.Zap.BindFlags(fs)
+}
+
+// Parse parses the command line arguments into the flags bound with BindFlags. The flags that are not on the
+// command line are then set from the environment variables named after them, e.g. NOTIFICATION_SERVICE_MARKER_PREFIX
+// for --marker-prefix, and the remaining flags from the options file, so Helm charts and Kustomize overlays
+// configure the controller without rewriting its arguments.
+func (o *Options) Parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := setFlagsFromEnvironment(fs); err != nil {
+		return err
+	}
+	if o.OptionsFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(o.OptionsFile)
+	if err != nil {
+		return fmt.Errorf("Failed to read options file %s: %w", o.OptionsFile, err)
+	}
+	return setFlagsFromFile(fs, data)
+}
+
+// EnvironmentVariable returns the name of the environment variable setting the flag
+func EnvironmentVariable(flagName string) string {
+	return EnvironmentPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// setFlagsFromEnvironment sets the flags that are not set yet from their environment variable, if set
+func setFlagsFromEnvironment(fs *flag.FlagSet) error {
+	set := setFlags(fs)
+	var errs []error
+	fs.VisitAll(func(f *flag.Flag) {
+		value, ok := os.LookupEnv(EnvironmentVariable(f.Name))
+		if !ok || set[f.Name] {
+			return
+		}
+		if err := fs.Set(f.Name, value); err != nil {
+			errs = append(errs, fmt.Errorf("Invalid value of %s: %w", EnvironmentVariable(f.Name), err))
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// setFlagsFromFile sets the flags that are not set yet from a YAML mapping of flag names to values.
+// Lists are joined with commas and mappings, e.g. of feature gates, with commas and equal signs.
+func setFlagsFromFile(fs *flag.FlagSet, data []byte) error {
+	data, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return fmt.Errorf("Failed to parse options file: %w", err)
+	}
+	values := map[string]any{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err = decoder.Decode(&values); err != nil {
+		return fmt.Errorf("Failed to parse options file: %w", err)
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("Unknown flag %s in options file", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	set := setFlags(fs)
+	for _, name := range names {
+		if set[name] || values[name] == nil {
+			continue
+		}
+		value := fmt.Sprint(values[name])
+		switch typed := values[name].(type) {
+		case []any:
+			items := make([]string, 0, len(typed))
+			for _, item := range typed {
+				items = append(items, fmt.Sprint(item))
+			}
+			value = strings.Join(items, ",")
+		case map[string]any:
+			pairs := make([]string, 0, len(typed))
+			for key, item := range typed {
+				pairs = append(pairs, key+"="+fmt.Sprint(item))
+			}
+			sort.Strings(pairs)
+			value = strings.Join(pairs, ",")
+		}
+		if err = fs.Set(name, value); err != nil {
+			return fmt.Errorf("Invalid value of %s in options file: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// setFlags returns the names of the flags of the flag set that are set
+func setFlags(fs *flag.FlagSet) map[string]bool {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	return set
+}
+
+// Validate returns an error if options are invalid or inconsistent
+func (o *Options) Validate() error {
+	if o.LeaderElection.Enabled &&
+		(o.LeaderElection.RenewDeadline >= o.LeaderElection.LeaseDuration || o.LeaderElection.RetryPeriod >= o.LeaderElection.RenewDeadline) {
+		return errors.New("The leader election retry period must be less than the renew deadline, which must be less than the lease duration")
+	}
+	if err := o.FeatureGates.Validate(); err != nil {
+		return err
+	}
+	if o.Concurrency < 0 {
+		return fmt.Errorf("Invalid concurrency %d", o.Concurrency)
+	}
+	if _, err := labels.Parse(o.PipelineRunSelector); err != nil {
+		return fmt.Errorf("Invalid pipelinerun selector %s: %w", o.PipelineRunSelector, err)
+	}
+	for flag, directory := range map[string]string{"mention-directory": o.MentionDirectory, "tenant-directory": o.TenantDirectory} {
+		if _, _, ok := strings.Cut(directory, "/"); directory != "" && !ok {
+			return fmt.Errorf("--%s must be in the form namespace/name", flag)
+		}
+	}
+	if _, err := controller.ParseWatchableKinds(o.WatchableKinds); err != nil {
+		return err
+	}
+	if o.Canary.Interval > 0 && o.Canary.Namespace == "" {
+		return errors.New("--canary-namespace is required by --canary-interval")
+	}
+	if !o.Replay.Since.IsZero() && o.Replay.TektonResultsURL == "" {
+		return errors.New("--tekton-results-url is required by --replay-since")
+	}
+	if o.LogLevelsBindAddress != "0" && !isLoopbackAddress(o.LogLevelsBindAddress) {
+		return fmt.Errorf("--log-levels-bind-address %s must be a loopback address, the endpoint is not authenticated",
+			o.LogLevelsBindAddress)
+	}
+	return nil
+}
+
+// isLoopbackAddress returns a boolean indicating whether the host of the address is localhost or a loopback IP
+func isLoopbackAddress(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// commaSeparated is a list flag value, set from a comma separated string
+type commaSeparated []string
+
+func (c *commaSeparated) String() string {
+	if c == nil {
+		return ""
+	}
+	return strings.Join(*c, ",")
+}
+
+func (c *commaSeparated) Set(value string) error {
+	*c = nil
+	if value != "" {
+		*c = strings.Split(value, ",")
+	}
+	return nil
+}
+
+// rfc3339Time is a time flag value, set from an RFC 3339 string
+type rfc3339Time time.Time
+
+func (t *rfc3339Time) String() string {
+	if t == nil || time.Time(*t).IsZero() {
+		return ""
+	}
+	return time.Time(*t).Format(time.RFC3339)
+}
+
+func (t *rfc3339Time) Set(value string) error {
+	if value == "" {
+		*t = rfc3339Time{}
+		return nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return err
+	}
+	*t = rfc3339Time(parsed)
+	return nil
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager_test
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/pkg/manager"
+)
+
+var _ = Describe("Options", func() {
+	var opts manager.Options
+	var flags *flag.FlagSet
+
+	setenv := func(name string, value string) {
+		Expect(os.Setenv(name, value)).To(Succeed())
+		DeferCleanup(os.Unsetenv, name)
+	}
+
+	writeOptions := func(content string) string {
+		path := filepath.Join(GinkgoT().TempDir(), "options.yaml")
+		Expect(os.WriteFile(path, []byte(content), 0o600)).To(Succeed())
+		return path
+	}
+
+	BeforeEach(func() {
+		opts = manager.NewOptions()
+		flags = flag.NewFlagSet("manager", flag.ContinueOnError)
+		opts.BindFlags(flags)
+	})
+
+	It("should keep the defaults without flags", func() {
+		Expect(opts.Parse(flags, nil)).To(Succeed())
+		Expect(opts.MarkerPrefix).To(Equal("konflux.ci"))
+		Expect(opts.Features.MigrateMarkers).To(BeTrue())
+		Expect(opts.Features.PrioritizeFailures).To(BeTrue())
+		Expect(opts.LeaderElection.LeaseDuration).To(Equal(15 * time.Second))
+		Expect(opts.CallbackBindAddress).To(Equal("0"))
+		Expect(opts.Validate()).To(Succeed())
+	})
+
+	It("should parse lists, times and nested options from flags", func() {
+		Expect(opts.Parse(flags, []string{
+			"--kafka-brokers=a:9092,b:9092", "--grpc-max-attempts=7", "--watch-customruns",
+			"--replay-since=2026-01-02T03:04:05Z", "--replay-destinations=slack", "--tekton-results-url=https://results",
+		})).To(Succeed())
+		Expect(opts.Kafka.Brokers).To(Equal([]string{"a:9092", "b:9092"}))
+		Expect(opts.GRPC.MaxAttempts).To(Equal(7))
+		Expect(opts.Features.WatchCustomRuns).To(BeTrue())
+		Expect(opts.Replay.Since).To(Equal(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)))
+		Expect(opts.Replay.Until.IsZero()).To(BeTrue())
+		Expect(opts.Replay.Destinations).To(Equal([]string{"slack"}))
+		Expect(opts.Validate()).To(Succeed())
+
+		Expect(opts.Parse(flags, []string{"--replay-since=yesterday"})).NotTo(Succeed())
+	})
+
+	It("should set the flags that are not on the command line from the environment and then the options file", func() {
+		setenv("NOTIFICATION_SERVICE_MARKER_PREFIX", "staging.konflux.ci")
+		setenv("NOTIFICATION_SERVICE_SWEEP_INTERVAL", "3m")
+		setenv("NOTIFICATION_SERVICE_OPTIONS_FILE", writeOptions(
+			"sweep-interval: 1m\nconcurrency: 4\nlegacy-marker-prefixes: [konflux.dev, appstudio.openshift.io]\n"+
+				"watch-argo-workflows: true\ndefault-namespace: shared\n"))
+		Expect(opts.Parse(flags, []string{"--default-namespace=ops"})).To(Succeed())
+		Expect(opts.MarkerPrefix).To(Equal("staging.konflux.ci"))
+		Expect(opts.SweepInterval).To(Equal(3 * time.Minute))
+		Expect(opts.Concurrency).To(Equal(4))
+		Expect(opts.LegacyMarkerPrefixes).To(Equal([]string{"konflux.dev", "appstudio.openshift.io"}))
+		Expect(opts.Features.WatchArgoWorkflows).To(BeTrue())
+		Expect(opts.DefaultNamespace).To(Equal("ops"))
+	})
+
+	It("should reject invalid environment variables and options files", func() {
+		setenv("NOTIFICATION_SERVICE_LIST_PAGE_SIZE", "many")
+		Expect(opts.Parse(flags, nil)).To(MatchError(ContainSubstring("NOTIFICATION_SERVICE_LIST_PAGE_SIZE")))
+		Expect(os.Unsetenv("NOTIFICATION_SERVICE_LIST_PAGE_SIZE")).To(Succeed())
+
+		opts = manager.NewOptions()
+		flags = flag.NewFlagSet("manager", flag.ContinueOnError)
+		opts.BindFlags(flags)
+		Expect(opts.Parse(flags, []string{"--options-file", writeOptions("list-page-size: 100\nsweep-intervals: 1m\n")})).
+			To(MatchError(ContainSubstring("Unknown flag sweep-intervals")))
+	})
+
+	It("should reject inconsistent options", func() {
+		opts.LeaderElection.Enabled = true
+		opts.LeaderElection.RenewDeadline = opts.LeaderElection.LeaseDuration
+		Expect(opts.Validate()).To(MatchError(ContainSubstring("renew deadline")))
+
+		opts = manager.NewOptions()
+		opts.PipelineRunSelector = "team in ("
+		Expect(opts.Validate()).To(MatchError(ContainSubstring("Invalid pipelinerun selector")))
+
+		opts = manager.NewOptions()
+		opts.MentionDirectory = "directory"
+		Expect(opts.Validate()).To(MatchError(ContainSubstring("--mention-directory")))
+
+		opts = manager.NewOptions()
+		opts.Canary.Interval = time.Hour
+		Expect(opts.Validate()).To(MatchError(ContainSubstring("--canary-namespace")))
+
+		opts = manager.NewOptions()
+		for _, address := range []string{":8083", "0.0.0.0:8083", "10.0.0.1:8083"} {
+			opts.LogLevelsBindAddress = address
+			Expect(opts.Validate()).To(MatchError(ContainSubstring("--log-levels-bind-address")))
+		}
+		for _, address := range []string{"127.0.0.1:8083", "[::1]:8083", "localhost:8083"} {
+			opts.LogLevelsBindAddress = address
+			Expect(opts.Validate()).To(Succeed())
+		}
+	})
+})
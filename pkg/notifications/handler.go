@@ -0,0 +1,165 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifications
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/konflux-ci/notification-service/pkg/delivery"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// DefaultMaxBodyBytes limits the size of the bodies of webhook requests, unless configured otherwise
+const DefaultMaxBodyBytes = 10 << 20
+
+// HandlerOptions configures the Handler
+type HandlerOptions struct {
+	// Verifier verifies the signatures of requests, unsigned requests are accepted if it is not set
+	Verifier *Verifier
+	// DecryptionKey is the private key of the destination encryption key, e.g. an *rsa.PrivateKey or a
+	// jose.JSONWebKey, which decrypts the bodies of requests that are encrypted. Encrypted requests are
+	// rejected if it is not set.
+	DecryptionKey any
+	// Store records the processed deliveries so every notification is processed once, see delivery.Handler.
+	// Notifications are processed every time they are delivered if it is not set.
+	Store delivery.Store
+	// OnSummary processes the summaries, which are acknowledged without processing if it is not set
+	OnSummary func(ctx context.Context, summary *Summary) error
+	// MaxBodyBytes limits the size of request bodies, DefaultMaxBodyBytes if zero
+	MaxBodyBytes int64
+}
+
+// Handler returns a handler decoding webhook requests and passing their notifications to onNotification.
+// Requests are rejected with a 4xx status if they are not valid, and with 500 Internal Server Error if
+// onNotification fails. The controller sends rejected notifications again.
+func Handler(opts HandlerOptions, onNotification func(ctx context.Context, notification *Notification) error) http.Handler {
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		notification, summary, status, err := opts.decode(r)
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		switch {
+		case notification != nil:
+			err = onNotification(r.Context(), notification)
+		case opts.OnSummary != nil:
+			err = opts.OnSummary(r.Context(), summary)
+		}
+		if err != nil {
+			http.Error(w, "failed to process notification", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	if opts.Store == nil {
+		return handler
+	}
+	// Summaries have no delivery ID, they are processed every time they are delivered
+	deduplicated := delivery.Handler(opts.Store, handler)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if delivery.ID(r) == "" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		deduplicated.ServeHTTP(w, r)
+	})
+}
+
+// decode verifies, decrypts, decompresses and decodes the body of the request, and returns the status
+// the request is rejected with if it fails
+func (opts *HandlerOptions) decode(r *http.Request) (*Notification, *Summary, int, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, opts.MaxBodyBytes))
+	if err != nil {
+		return nil, nil, http.StatusRequestEntityTooLarge, fmt.Errorf("Failed to read body: %w", err)
+	}
+	if opts.Verifier != nil {
+		err = opts.Verifier.Verify(r.Header, body)
+		if err != nil {
+			return nil, nil, http.StatusUnauthorized, err
+		}
+	}
+	contentType := r.Header.Get("Content-Type")
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == notifier.MediaTypeJOSE {
+		body, contentType, err = opts.decrypt(body)
+		if err != nil {
+			return nil, nil, http.StatusBadRequest, err
+		}
+	}
+	if r.Header.Get("Content-Encoding") == notifier.CompressionGzip {
+		body, err = gunzip(body, opts.MaxBodyBytes)
+		if err != nil {
+			return nil, nil, http.StatusBadRequest, err
+		}
+	}
+	notification, summary, err := Decode(contentType, body)
+	if errors.Is(err, ErrUnsupportedMediaType) {
+		return nil, nil, http.StatusUnsupportedMediaType, err
+	}
+	if err != nil {
+		return nil, nil, http.StatusBadRequest, err
+	}
+	return notification, summary, http.StatusOK, nil
+}
+
+// decrypt returns the plaintext of a JWE body and its media type
+func (opts *HandlerOptions) decrypt(body []byte) ([]byte, string, error) {
+	if opts.DecryptionKey == nil {
+		return nil, "", errors.New("encrypted bodies are not supported, no decryption key is configured")
+	}
+	object, err := jose.ParseEncrypted(string(body))
+	if err != nil {
+		return nil, "", fmt.Errorf("Failed to parse encrypted body: %w", err)
+	}
+	plaintext, err := object.Decrypt(opts.DecryptionKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("Failed to decrypt body: %w", err)
+	}
+	contentType, _ := object.Header.ExtraHeaders[jose.HeaderContentType].(string)
+	return plaintext, contentType, nil
+}
+
+// gunzip decompresses a gzip body, up to maxBytes
+func gunzip(body []byte, maxBytes int64) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decompress body: %w", err)
+	}
+	decompressed, err := io.ReadAll(io.LimitReader(reader, maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decompress body: %w", err)
+	}
+	if int64(len(decompressed)) > maxBytes {
+		return nil, fmt.Errorf("Decompressed body exceeds %d bytes", maxBytes)
+	}
+	return decompressed, nil
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package notifications helps Go services receive the webhooks of the notification service.
+// It provides the types of the payloads, verifies the Sigstore signatures and decrypts the bodies of
+// webhook requests, and decodes them in an HTTP handler:
+//
+//	http.Handle("/notifications", notifications.Handler(notifications.HandlerOptions{},
+//		func(ctx context.Context, notification *notifications.Notification) error {
+//			log.Printf("PipelineRun %s/%s %s", notification.Namespace, notification.PipelineRun, notification.Status)
+//			return nil
+//		}))
+package notifications
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"mime"
+
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+)
+
+// Payload types of webhook requests
+type (
+	// Notification describes the outcome of a PipelineRun
+	Notification = notifier.Notification
+	// Result is a result of a PipelineRun
+	Result = notifier.Result
+	// TaskTiming is the timing of a TaskRun of a PipelineRun
+	TaskTiming = notifier.TaskTiming
+	// Contact is the author of a PipelineRun
+	Contact = notifier.Contact
+	// ChainsSignature describes how Tekton Chains signed a PipelineRun
+	ChainsSignature = notifier.ChainsSignature
+	// PolicySummary summarizes the policy check results of a PipelineRun
+	PolicySummary = notifier.PolicySummary
+	// Provenance summarizes what a PipelineRun built and from which materials
+	Provenance = notifier.Provenance
+	// Artifact is a subject or material of a Provenance
+	Artifact = notifier.Artifact
+	// KonfluxContext describes what a Konflux Release or integration test was run for
+	KonfluxContext = notifier.KonfluxContext
+	// MatrixTask groups the results of a pipeline task fanned out by a matrix
+	MatrixTask = notifier.MatrixTask
+	// MatrixCombination is the TaskRun of a combination of the parameters of a MatrixTask
+	MatrixCombination = notifier.MatrixCombination
+	// MatrixParam is the value of a matrix parameter in a MatrixCombination
+	MatrixParam = notifier.MatrixParam
+	// Summary reports the health of the pipelines of a namespace over a period
+	Summary = notifier.Summary
+	// PipelineStats summarizes the PipelineRuns of a single pipeline in a Summary
+	PipelineStats = notifier.PipelineStats
+)
+
+// Statuses of the PipelineRun a notification is sent for
+const (
+	StatusStarted   = notifier.StatusStarted
+	StatusRunning   = notifier.StatusRunning
+	StatusSucceeded = notifier.StatusSucceeded
+	StatusFailed    = notifier.StatusFailed
+)
+
+// Events of the lifecycle of the PipelineRun a notification is sent for
+const (
+	EventStarted   = notifier.EventStarted
+	EventRunning   = notifier.EventRunning
+	EventSucceeded = notifier.EventSucceeded
+	EventFailed    = notifier.EventFailed
+	EventCancelled = notifier.EventCancelled
+	// EventSucceededWithRetries is the subtype of succeeded notifications about PipelineRuns that
+	// succeeded after some of their tasks were retried
+	EventSucceededWithRetries = notifier.EventSucceededWithRetries
+)
+
+// Kinds of the runs notifications are sent for, other than PipelineRuns
+const (
+	// KindCustomRun is the Kind of notifications about Tekton CustomRuns
+	KindCustomRun = notifier.KindCustomRun
+	// KindWorkflow is the Kind of notifications about Argo Workflows
+	KindWorkflow = notifier.KindWorkflow
+	// KindRelease is the Kind of notifications about Konflux Releases
+	KindRelease = notifier.KindRelease
+	// KindTaskRun is the Kind of notifications about standalone Tekton TaskRuns
+	KindTaskRun = notifier.KindTaskRun
+)
+
+// Types of array and object results
+const (
+	ResultTypeArray  = notifier.ResultTypeArray
+	ResultTypeObject = notifier.ResultTypeObject
+)
+
+// ErrUnsupportedMediaType is returned when decoding bodies that are neither JSON nor XML
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+// Decode decodes the JSON or XML body of a webhook request, which is either a notification or a summary.
+// Form encoded and templated bodies cannot be decoded.
+// Return error if the body is malformed or ErrUnsupportedMediaType if its media type is not supported
+func Decode(contentType string, body []byte) (*Notification, *Summary, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w %s", ErrUnsupportedMediaType, contentType)
+	}
+	switch mediaType {
+	case "application/json":
+		var fields map[string]json.RawMessage
+		err = json.Unmarshal(body, &fields)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to decode JSON body: %w", err)
+		}
+		if _, ok := fields["pipelineRun"]; !ok {
+			summary := &Summary{}
+			err = json.Unmarshal(body, summary)
+			if err != nil {
+				return nil, nil, fmt.Errorf("Failed to decode JSON summary: %w", err)
+			}
+			return nil, summary, nil
+		}
+		notification := &Notification{}
+		err = json.Unmarshal(body, notification)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to decode JSON notification: %w", err)
+		}
+		return notification, nil, nil
+	case "application/xml", "text/xml":
+		root, err := xmlRoot(body)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to decode XML body: %w", err)
+		}
+		if root == "summary" {
+			summary := &Summary{}
+			err = xml.Unmarshal(body, summary)
+			if err != nil {
+				return nil, nil, fmt.Errorf("Failed to decode XML summary: %w", err)
+			}
+			return nil, summary, nil
+		}
+		notification := &Notification{}
+		err = xml.Unmarshal(body, notification)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to decode XML notification: %w", err)
+		}
+		return notification, nil, nil
+	default:
+		return nil, nil, fmt.Errorf("%w %s", ErrUnsupportedMediaType, mediaType)
+	}
+}
+
+// xmlRoot returns the local name of the root element of an XML document
+func xmlRoot(body []byte) (string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
@@ -0,0 +1,301 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifications_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+
+	"github.com/konflux-ci/notification-service/pkg/delivery"
+	"github.com/konflux-ci/notification-service/pkg/notifications"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+)
+
+const (
+	signerIdentity = "https://kubernetes.io/namespaces/notification-service/serviceaccounts/controller"
+	signerIssuer   = "https://kubernetes.default.svc"
+)
+
+// testSigner signs bodies like a SigstoreSigner, with a certificate of a test certificate authority
+type testSigner struct {
+	roots *x509.CertPool
+	key   *ecdsa.PrivateKey
+	chain []byte
+}
+
+func newTestSigner(identity string) *testSigner {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fulcio"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	Expect(err).NotTo(HaveOccurred())
+	ca, err := x509.ParseCertificate(caDER)
+	Expect(err).NotTo(HaveOccurred())
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+	uri, err := url.Parse(identity)
+	Expect(err).NotTo(HaveOccurred())
+	issuer, err := asn1.Marshal(signerIssuer)
+	Expect(err).NotTo(HaveOccurred())
+	leafDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber:    big.NewInt(2),
+		NotBefore:       time.Now().Add(-time.Minute),
+		NotAfter:        time.Now().Add(10 * time.Minute),
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		URIs:            []*url.URL{uri},
+		ExtraExtensions: []pkix.Extension{{Id: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}, Value: issuer}},
+	}, ca, &key.PublicKey, caKey)
+	Expect(err).NotTo(HaveOccurred())
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	return &testSigner{
+		roots: roots,
+		key:   key,
+		chain: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER}),
+	}
+}
+
+func (s *testSigner) Sign(_ context.Context, body []byte) (map[string]string, error) {
+	digest := sha256.Sum256(body)
+	signature, err := ecdsa.SignASN1(rand.Reader, s.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	bundle := fmt.Sprintf(`{"SignedEntryTimestamp":"","Payload":{"integratedTime":%d}}`, time.Now().Unix())
+	return map[string]string{
+		notifier.SignatureHeader:   base64.StdEncoding.EncodeToString(signature),
+		notifier.CertificateHeader: base64.StdEncoding.EncodeToString(s.chain),
+		notifier.BundleHeader:      base64.StdEncoding.EncodeToString([]byte(bundle)),
+	}, nil
+}
+
+var _ = Describe("Handler", func() {
+	var (
+		received  []*notifications.Notification
+		summaries []*notifications.Summary
+		fail      error
+	)
+
+	notification := &notifier.Notification{
+		PipelineRun: "build-1",
+		Namespace:   "tenant",
+		Status:      notifier.StatusSucceeded,
+		Results: []notifier.Result{
+			{Name: "IMAGE_URL", Value: "quay.io/tenant/app"},
+			{Name: "TAGS", Type: notifier.ResultTypeArray, Array: []string{"v1", "latest"}},
+		},
+	}
+
+	serve := func(opts notifications.HandlerOptions) string {
+		opts.OnSummary = func(_ context.Context, summary *notifications.Summary) error {
+			summaries = append(summaries, summary)
+			return nil
+		}
+		server := httptest.NewServer(notifications.Handler(opts, func(_ context.Context, notification *notifications.Notification) error {
+			received = append(received, notification)
+			return fail
+		}))
+		DeferCleanup(server.Close)
+		return server.URL
+	}
+
+	send := func(opts notifier.WebhookOptions, notification *notifier.Notification) error {
+		n, err := notifier.NewWebhookNotifier(opts)
+		Expect(err).NotTo(HaveOccurred())
+		return n.Notify(context.Background(), notification)
+	}
+
+	BeforeEach(func() {
+		received, summaries, fail = nil, nil, nil
+	})
+
+	It("should decode JSON notifications", func() {
+		url := serve(notifications.HandlerOptions{})
+		Expect(send(notifier.WebhookOptions{URL: url}, notification)).To(Succeed())
+		Expect(received).To(Equal([]*notifications.Notification{notification}))
+	})
+
+	It("should decode compressed XML notifications", func() {
+		url := serve(notifications.HandlerOptions{})
+		Expect(send(notifier.WebhookOptions{URL: url, ContentType: notifier.ContentTypeXML, Compression: notifier.CompressionGzip},
+			notification)).To(Succeed())
+		Expect(received).To(HaveLen(1))
+		Expect(received[0].PipelineRun).To(Equal("build-1"))
+		Expect(received[0].Result("TAGS").String()).To(Equal(`["v1","latest"]`))
+	})
+
+	It("should decode summaries", func() {
+		url := serve(notifications.HandlerOptions{})
+		n, err := notifier.NewWebhookNotifier(notifier.WebhookOptions{URL: url})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n.NotifySummary(context.Background(), &notifier.Summary{Namespace: "tenant", Total: 4})).To(Succeed())
+		Expect(received).To(BeEmpty())
+		Expect(summaries).To(HaveLen(1))
+		Expect(summaries[0].Total).To(Equal(4))
+	})
+
+	It("should decrypt encrypted notifications", func() {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).NotTo(HaveOccurred())
+		der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		Expect(err).NotTo(HaveOccurred())
+		publicKey := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+		Expect(send(notifier.WebhookOptions{URL: serve(notifications.HandlerOptions{}), EncryptionKey: publicKey},
+			notification)).To(MatchError(ContainSubstring("status 400")))
+		url := serve(notifications.HandlerOptions{DecryptionKey: key})
+		Expect(send(notifier.WebhookOptions{URL: url, EncryptionKey: publicKey}, notification)).To(Succeed())
+		Expect(received).To(Equal([]*notifications.Notification{notification}))
+	})
+
+	It("should verify the signatures of notifications", func() {
+		signer := newTestSigner(signerIdentity)
+		url := serve(notifications.HandlerOptions{
+			Verifier: &notifications.Verifier{Roots: signer.roots, Identity: signerIdentity, Issuer: signerIssuer},
+		})
+		Expect(send(notifier.WebhookOptions{URL: url}, notification)).To(MatchError(ContainSubstring("status 401")))
+		Expect(send(notifier.WebhookOptions{URL: url, Signer: signer}, notification)).To(Succeed())
+		Expect(received).To(HaveLen(1))
+
+		impostor := newTestSigner("https://kubernetes.io/namespaces/tenant/serviceaccounts/default")
+		impostor.roots = signer.roots
+		Expect(send(notifier.WebhookOptions{URL: url, Signer: impostor}, notification)).To(MatchError(ContainSubstring("status 401")))
+		Expect(received).To(HaveLen(1))
+	})
+
+	It("should reject bodies that do not match their signature", func() {
+		signer := newTestSigner(signerIdentity)
+		verifier := &notifications.Verifier{Roots: signer.roots, Identity: signerIdentity}
+		headers, err := signer.Sign(context.Background(), []byte(`{"pipelineRun":"build-1"}`))
+		Expect(err).NotTo(HaveOccurred())
+		header := http.Header{}
+		for name, value := range headers {
+			header.Set(name, value)
+		}
+		Expect(verifier.Verify(header, []byte(`{"pipelineRun":"build-1"}`))).To(Succeed())
+		Expect(verifier.Verify(header, []byte(`{"pipelineRun":"build-2"}`))).To(MatchError(notifications.ErrInvalidSignature))
+	})
+
+	It("should process every delivery once with a store", func() {
+		url := serve(notifications.HandlerOptions{Store: &delivery.MemoryStore{}})
+		delivered := *notification
+		delivered.DeliveryID = delivery.NewID("uid-1", "default", notifier.StatusSucceeded)
+		Expect(send(notifier.WebhookOptions{URL: url}, &delivered)).To(Succeed())
+		Expect(send(notifier.WebhookOptions{URL: url}, &delivered)).To(Succeed())
+		Expect(received).To(HaveLen(1))
+	})
+
+	It("should report processing failures so notifications are sent again", func() {
+		url := serve(notifications.HandlerOptions{Store: &delivery.MemoryStore{}})
+		delivered := *notification
+		delivered.DeliveryID = delivery.NewID("uid-1", "default", notifier.StatusSucceeded)
+		fail = fmt.Errorf("database is down")
+		Expect(send(notifier.WebhookOptions{URL: url}, &delivered)).To(MatchError(ContainSubstring("status 500")))
+		fail = nil
+		Expect(send(notifier.WebhookOptions{URL: url}, &delivered)).To(Succeed())
+		Expect(received).To(HaveLen(2))
+	})
+
+	It("should reject form encoded notifications", func() {
+		url := serve(notifications.HandlerOptions{})
+		Expect(send(notifier.WebhookOptions{URL: url, ContentType: notifier.ContentTypeForm}, notification)).To(
+			MatchError(ContainSubstring("status 415")))
+	})
+})
+
+var _ = Describe("Simulator", func() {
+	notification := &notifier.Notification{PipelineRun: "build-1", Namespace: "tenant", Status: notifier.StatusFailed}
+
+	serve := func(opts notifications.SimulatorOptions) string {
+		server := httptest.NewServer(notifications.NewSimulator(opts))
+		DeferCleanup(server.Close)
+		return server.URL
+	}
+
+	send := func(url string, notification *notifier.Notification, signer notifier.PayloadSigner) error {
+		n, err := notifier.NewWebhookNotifier(notifier.WebhookOptions{URL: url, Signer: signer})
+		Expect(err).NotTo(HaveOccurred())
+		return n.Notify(context.Background(), notification)
+	}
+
+	It("should print the requests and their pretty-printed payloads", func() {
+		output := gbytes.NewBuffer()
+		signer := newTestSigner(signerIdentity)
+		url := serve(notifications.SimulatorOptions{
+			HandlerOptions: notifications.HandlerOptions{
+				Verifier: &notifications.Verifier{Roots: signer.roots, Identity: signerIdentity},
+			},
+			Output: output,
+		})
+		delivered := *notification
+		delivered.DeliveryID = delivery.NewID("uid-1", "default", notifier.StatusFailed)
+		Expect(send(url, &delivered, signer)).To(Succeed())
+		Eventually(output).Should(gbytes.Say(`--- \S+ POST / delivery=` + delivered.DeliveryID + ` attempt=1 signature=verified latency=0s status=204\n`))
+		Eventually(output).Should(gbytes.Say(`\{\n  "pipelineRun": "build-1",\n  "namespace": "tenant",\n  "status": "Failed"`))
+
+		Expect(send(url, notification, nil)).To(MatchError(ContainSubstring("status 401")))
+		Eventually(output).Should(gbytes.Say(`signature=none latency=0s status=401 invalid signature`))
+	})
+
+	It("should fail the first attempts of deliveries and delay responses", func() {
+		output := gbytes.NewBuffer()
+		url := serve(notifications.SimulatorOptions{Output: output, FailFirst: 1, Latency: 50 * time.Millisecond})
+		delivered := *notification
+		delivered.DeliveryID = delivery.NewID("uid-2", "default", notifier.StatusFailed)
+		start := time.Now()
+		Expect(send(url, &delivered, nil)).To(MatchError(ContainSubstring("status 503")))
+		Expect(time.Since(start)).To(BeNumerically(">=", 50*time.Millisecond))
+		Eventually(output).Should(gbytes.Say(`attempt=1 signature=none latency=50ms status=503 simulated failure\n`))
+		Expect(send(url, &delivered, nil)).To(Succeed())
+		Eventually(output).Should(gbytes.Say(`attempt=2 signature=none latency=50ms status=204\n`))
+	})
+
+	It("should fail requests at the failure rate", func() {
+		url := serve(notifications.SimulatorOptions{FailureRate: 1, FailureStatus: http.StatusTooManyRequests})
+		Expect(send(url, notification, nil)).To(MatchError(ContainSubstring("status 429")))
+	})
+})
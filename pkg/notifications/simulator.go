@@ -0,0 +1,171 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/konflux-ci/notification-service/pkg/delivery"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+)
+
+// SimulatorOptions configures a receiver simulator, see NewSimulator
+type SimulatorOptions struct {
+	// HandlerOptions verify, decrypt and deduplicate the requests, their OnSummary callback is replaced
+	HandlerOptions
+	// Output receives a line about every request followed by its pretty-printed notification or summary
+	Output io.Writer
+	// Latency delays every response, by a random duration up to Jitter more
+	Latency time.Duration
+	Jitter  time.Duration
+	// FailureRate is the probability, from 0 to 1, of a request being failed with FailureStatus without being processed
+	FailureRate float64
+	// FailFirst is the number of attempts of every delivery failed with FailureStatus before it is processed,
+	// so its retries can be observed
+	FailFirst int
+	// FailureStatus is the status of failed requests, 503 Service Unavailable by default
+	FailureStatus int
+}
+
+// simulator is the handler returned by NewSimulator
+type simulator struct {
+	opts    SimulatorOptions
+	handler http.Handler
+
+	mu       sync.Mutex
+	attempts map[string]int
+}
+
+// simulatorOutputKey is the key of the request context value in which the payload of the request is printed
+type simulatorOutputKey struct{}
+
+// NewSimulator returns a handler simulating a webhook receiver for local development and demos. It accepts
+// the requests of webhook destinations as Handler does, prints every request and its payload,
+// and delays or fails requests as configured to show how the controller handles slow and failing receivers.
+func NewSimulator(opts SimulatorOptions) http.Handler {
+	if opts.Output == nil {
+		opts.Output = io.Discard
+	}
+	if opts.FailureStatus == 0 {
+		opts.FailureStatus = http.StatusServiceUnavailable
+	}
+	s := &simulator{opts: opts, attempts: map[string]int{}}
+	opts.OnSummary = func(ctx context.Context, summary *Summary) error {
+		return printPayload(ctx, summary)
+	}
+	s.handler = Handler(opts.HandlerOptions, func(ctx context.Context, notification *Notification) error {
+		return printPayload(ctx, notification)
+	})
+	return s
+}
+
+// ServeHTTP delays the request, fails it or processes it, then prints it along with its payload
+func (s *simulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	received := time.Now()
+	delay := s.opts.Latency
+	if s.opts.Jitter > 0 {
+		delay += rand.N(s.opts.Jitter)
+	}
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	id := delivery.ID(r)
+	s.mu.Lock()
+	s.attempts[id]++
+	attempt := s.attempts[id]
+	s.mu.Unlock()
+
+	line := fmt.Sprintf("--- %s %s %s delivery=%s attempt=%d signature=%s latency=%s",
+		received.UTC().Format(time.RFC3339), r.Method, r.URL.Path, id, attempt, s.signature(r), delay)
+	if attempt <= s.opts.FailFirst || (s.opts.FailureRate > 0 && rand.Float64() < s.opts.FailureRate) {
+		http.Error(w, "simulated failure", s.opts.FailureStatus)
+		s.print(fmt.Sprintf("%s status=%d simulated failure\n", line, s.opts.FailureStatus))
+		return
+	}
+	payload := &bytes.Buffer{}
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	s.handler.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), simulatorOutputKey{}, payload)))
+	line = fmt.Sprintf("%s status=%d", line, recorder.status)
+	if recorder.status >= http.StatusBadRequest {
+		line += " " + string(bytes.TrimSpace(recorder.body.Bytes()))
+	}
+	s.print(line + "\n" + payload.String())
+}
+
+// signature returns whether the request was verified, is signed without being verified, or is not signed
+func (s *simulator) signature(r *http.Request) string {
+	switch {
+	case r.Header.Get(notifier.SignatureHeader) == "":
+		return "none"
+	case s.opts.Verifier != nil:
+		return "verified"
+	}
+	return "unverified"
+}
+
+// print writes the output of a request at once, so the output of concurrent requests is not interleaved
+func (s *simulator) print(output string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, _ = io.WriteString(s.opts.Output, output)
+}
+
+// printPayload prints the indented JSON of the payload in the output of the request
+func printPayload(ctx context.Context, payload any) error {
+	output, ok := ctx.Value(simulatorOutputKey{}).(*bytes.Buffer)
+	if !ok {
+		return nil
+	}
+	data, err := json.MarshalIndent(payload, "", "  ")
+	if err != nil {
+		return err
+	}
+	output.Write(data)
+	output.WriteString("\n")
+	return nil
+}
+
+// statusRecorder records the status and the start of the body of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(data []byte) (int, error) {
+	if r.body.Len() < 512 {
+		r.body.Write(data[:min(len(data), 512-r.body.Len())])
+	}
+	return r.ResponseWriter.Write(data)
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifications
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+)
+
+// ErrInvalidSignature is returned when verifying the signature of a request that is not signed, or whose
+// signature, certificate or identity is not valid
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// Fulcio certificate extensions holding the OIDC issuer of the signer, the v2 extension is DER encoded
+var (
+	fulcioIssuerV1 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	fulcioIssuerV2 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+)
+
+// Verifier verifies the Sigstore signatures of webhook request bodies, see notifier.SigstoreSigner.
+// The signing certificate must be issued by one of the Fulcio roots, be valid when the signature was recorded
+// in Rekor and have the identity of the controller.
+type Verifier struct {
+	// Roots are the Fulcio root certificates
+	Roots *x509.CertPool
+	// Identity is the email or URI subject alternative name of the controller, e.g.
+	// https://kubernetes.io/namespaces/notification-service/serviceaccounts/notification-service-controller-manager
+	Identity string
+	// Issuer is the OIDC issuer of the identity of the controller, any issuer is accepted if empty
+	Issuer string
+}
+
+// Verify verifies the signature headers of a request against its body, as received
+// Return error wrapping ErrInvalidSignature if the request is not signed or its signature is not valid
+func (v *Verifier) Verify(header http.Header, body []byte) error {
+	if v.Roots == nil || v.Identity == "" {
+		return errors.New("Fulcio roots and signer identity must be set")
+	}
+	signature, err := base64.StdEncoding.DecodeString(header.Get(notifier.SignatureHeader))
+	if err != nil || len(signature) == 0 {
+		return fmt.Errorf("%w: missing or malformed %s header", ErrInvalidSignature, notifier.SignatureHeader)
+	}
+	chain, err := base64.StdEncoding.DecodeString(header.Get(notifier.CertificateHeader))
+	if err != nil {
+		return fmt.Errorf("%w: malformed %s header", ErrInvalidSignature, notifier.CertificateHeader)
+	}
+	certificates, err := parseChain(chain)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	}
+	signedAt, err := integratedTime(header.Get(notifier.BundleHeader))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	}
+	leaf := certificates[0]
+	intermediates := x509.NewCertPool()
+	for _, certificate := range certificates[1:] {
+		intermediates.AddCert(certificate)
+	}
+	_, err = leaf.Verify(x509.VerifyOptions{
+		Roots:         v.Roots,
+		Intermediates: intermediates,
+		CurrentTime:   signedAt,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	}
+	identities := leaf.EmailAddresses
+	for _, uri := range leaf.URIs {
+		identities = append(identities, uri.String())
+	}
+	if !slices.Contains(identities, v.Identity) {
+		return fmt.Errorf("%w: signed by %v instead of %s", ErrInvalidSignature, identities, v.Identity)
+	}
+	if v.Issuer != "" {
+		if issuer := certificateIssuer(leaf); issuer != v.Issuer {
+			return fmt.Errorf("%w: identity issued by %s instead of %s", ErrInvalidSignature, issuer, v.Issuer)
+		}
+	}
+	key, ok := leaf.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("%w: unsupported signing key %T", ErrInvalidSignature, leaf.PublicKey)
+	}
+	digest := sha256.Sum256(body)
+	if !ecdsa.VerifyASN1(key, digest[:], signature) {
+		return fmt.Errorf("%w: signature does not match the body", ErrInvalidSignature)
+	}
+	return nil
+}
+
+// parseChain parses a PEM certificate chain, starting with the leaf certificate
+func parseChain(chain []byte) ([]*x509.Certificate, error) {
+	var certificates []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, chain = pem.Decode(chain)
+		if block == nil {
+			break
+		}
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse signing certificate: %w", err)
+		}
+		certificates = append(certificates, certificate)
+	}
+	if len(certificates) == 0 {
+		return nil, errors.New("missing signing certificate")
+	}
+	return certificates, nil
+}
+
+// integratedTime returns when the signature was recorded in Rekor, read from the base64 encoded bundle.
+// The signed entry timestamp of the bundle is not verified.
+func integratedTime(encoded string) (time.Time, error) {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(raw) == 0 {
+		return time.Time{}, fmt.Errorf("missing or malformed %s header", notifier.BundleHeader)
+	}
+	bundle := struct {
+		Payload struct {
+			IntegratedTime int64 `json:"integratedTime"`
+		} `json:"Payload"`
+	}{}
+	err = json.Unmarshal(raw, &bundle)
+	if err != nil || bundle.Payload.IntegratedTime == 0 {
+		return time.Time{}, fmt.Errorf("malformed %s header", notifier.BundleHeader)
+	}
+	return time.Unix(bundle.Payload.IntegratedTime, 0), nil
+}
+
+// certificateIssuer returns the OIDC issuer of a Fulcio certificate, or an empty string if it has none
+func certificateIssuer(certificate *x509.Certificate) string {
+	for _, extension := range certificate.Extensions {
+		switch {
+		case extension.Id.Equal(fulcioIssuerV2):
+			var issuer string
+			if _, err := asn1.Unmarshal(extension.Value, &issuer); err == nil {
+				return issuer
+			}
+		case extension.Id.Equal(fulcioIssuerV1):
+			return string(extension.Value)
+		}
+	}
+	return ""
+}
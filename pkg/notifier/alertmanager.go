@@ -0,0 +1,292 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// DefaultAlertResolveAfter is how long alerts fire unless configured otherwise. Alertmanager resolves alerts
+// that are not sent again, and notifications are only sent once.
+const DefaultAlertResolveAfter = 24 * time.Hour
+
+// AlertmanagerReceiver is the receiver named in the Alertmanager webhook messages of webhooks
+const AlertmanagerReceiver = "notification-service"
+
+// Alert is an alert in the format of the Prometheus Alertmanager API v2 and webhook receivers
+type Alert struct {
+	// Status is firing or resolved, only set in webhook messages
+	Status       string            `json:"status,omitempty"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL,omitempty"`
+	// Fingerprint identifies the labels of the alert, only set in webhook messages
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// AlertmanagerMessage is the body of the requests Alertmanager sends to webhook receivers, in version 4
+type AlertmanagerMessage struct {
+	Version           string            `json:"version"`
+	GroupKey          string            `json:"groupKey"`
+	TruncatedAlerts   int               `json:"truncatedAlerts"`
+	Status            string            `json:"status"`
+	Receiver          string            `json:"receiver"`
+	GroupLabels       map[string]string `json:"groupLabels"`
+	CommonLabels      map[string]string `json:"commonLabels"`
+	CommonAnnotations map[string]string `json:"commonAnnotations"`
+	ExternalURL       string            `json:"externalURL"`
+	Alerts            []Alert           `json:"alerts"`
+}
+
+// NewAlert returns the alert of a notification about a failed or long running run, named after the kind of
+// the run, e.g. PipelineRunFailed or PipelineRunLongRunning, which fires from now until resolveAfter.
+// The labels are added to the labels identifying the run, and the description annotation is rendered with
+// the template, or lists the results of the run if it is nil.
+// It returns nil for other notifications, which are not alerts.
+// Return error if the template fails to render
+func NewAlert(notification *Notification, labels map[string]string, tmpl *template.Template, locale *Locale,
+	now time.Time, resolveAfter time.Duration) (*Alert, error) {
+	kind := notification.Kind
+	if kind == "" {
+		// Notifications about PipelineRuns have no kind
+		kind = "PipelineRun"
+	}
+	if !IsAlert(notification) {
+		return nil, nil
+	}
+	alertname := kind + "Failed"
+	if notification.Status == StatusRunning {
+		alertname = kind + "LongRunning"
+	}
+	alert := &Alert{
+		Labels:       map[string]string{},
+		Annotations:  map[string]string{"summary": locale.status(notification, notification.PipelineRun)},
+		StartsAt:     now,
+		EndsAt:       now.Add(resolveAfter),
+		GeneratorURL: notification.PayloadURL,
+	}
+	if notification.Status == StatusFailed && notification.CompletionTime != nil && notification.CompletionTime.Before(now) {
+		alert.StartsAt = *notification.CompletionTime
+	}
+	for name, value := range labels {
+		alert.Labels[name] = value
+	}
+	alert.Labels["alertname"] = alertname
+	alert.Labels["namespace"] = notification.Namespace
+	alert.Labels["pipelinerun"] = notification.PipelineRun
+	if notification.Team != "" {
+		alert.Labels["team"] = notification.Team
+	}
+	if notification.Konflux != nil {
+		if notification.Konflux.Application != "" {
+			alert.Labels["application"] = notification.Konflux.Application
+		}
+		if notification.Konflux.Component != "" {
+			alert.Labels["component"] = notification.Konflux.Component
+		}
+	}
+	if notification.FailureStreak > 0 {
+		alert.Annotations["failureStreak"] = strconv.Itoa(notification.FailureStreak)
+	}
+	if tmpl != nil {
+		description, err := Render(tmpl, notification)
+		if err != nil {
+			return nil, err
+		}
+		alert.Annotations["description"] = string(description)
+	} else if len(notification.Results) > 0 {
+		var lines []string
+		for _, result := range notification.Results {
+			lines = append(lines, result.Name+": "+result.String())
+		}
+		alert.Annotations["description"] = strings.Join(lines, "\n")
+	}
+	return alert, nil
+}
+
+// IsAlert returns a boolean indicating whether the notification is about a failed or long running run,
+// which are the notifications sent as alerts
+func IsAlert(notification *Notification) bool {
+	return notification.Status == StatusFailed || notification.Status == StatusRunning
+}
+
+// NewAlertmanagerMessage returns the firing webhook message of Alertmanager holding the alert, grouped by
+// the alertname and namespace labels
+func NewAlertmanagerMessage(alert *Alert) *AlertmanagerMessage {
+	firing := *alert
+	firing.Status = "firing"
+	firing.Fingerprint = alertFingerprint(alert.Labels)
+	groupLabels := map[string]string{"alertname": alert.Labels["alertname"], "namespace": alert.Labels["namespace"]}
+	return &AlertmanagerMessage{
+		Version:           "4",
+		GroupKey:          fmt.Sprintf("{}:{alertname=%q, namespace=%q}", groupLabels["alertname"], groupLabels["namespace"]),
+		Status:            "firing",
+		Receiver:          AlertmanagerReceiver,
+		GroupLabels:       groupLabels,
+		CommonLabels:      alert.Labels,
+		CommonAnnotations: alert.Annotations,
+		Alerts:            []Alert{firing},
+	}
+}
+
+// alertFingerprint returns the hex encoded FNV-1a hash of the sorted labels, like Alertmanager fingerprints
+func alertFingerprint(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	hash := fnv.New64a()
+	for _, name := range names {
+		_, _ = hash.Write([]byte(name + "\xff" + labels[name] + "\xff"))
+	}
+	return fmt.Sprintf("%016x", hash.Sum64())
+}
+
+// AlertmanagerOptions configures an AlertmanagerNotifier
+type AlertmanagerOptions struct {
+	// URL is the base URL of the Alertmanager, e.g. http://alertmanager-operated.monitoring:9093
+	URL string
+	// BearerToken optionally authenticates requests, e.g. to a proxy in front of the Alertmanager
+	BearerToken string
+	// Labels are added to the labels of the alerts
+	Labels map[string]string
+	// ResolveAfter is how long alerts fire, defaults to DefaultAlertResolveAfter
+	ResolveAfter time.Duration
+	// Template is an optional Go template rendering the description annotation of alerts
+	Template string
+	// Locale is the language of the built-in messages, see Locales. Defaults to DefaultLocale.
+	Locale string
+	// Timeout is the deadline of a single request
+	Timeout time.Duration
+	// HTTPClient is used to send requests, defaults to a client with Timeout
+	HTTPClient *http.Client
+}
+
+// AlertmanagerNotifier pushes the notifications about failed and long running runs as alerts to the API v2
+// of a Prometheus Alertmanager, which routes, groups and silences them like the alerts of Prometheus.
+// Other notifications are not sent.
+type AlertmanagerNotifier struct {
+	url          string
+	bearerToken  string
+	labels       map[string]string
+	resolveAfter time.Duration
+	template     *template.Template
+	locale       *Locale
+	client       *http.Client
+}
+
+// NewAlertmanagerNotifier creates an AlertmanagerNotifier from the given options
+func NewAlertmanagerNotifier(opts AlertmanagerOptions) (*AlertmanagerNotifier, error) {
+	if opts.URL == "" {
+		return nil, errors.New("Alertmanager URL must be set")
+	}
+	locale, err := LookupLocale(opts.Locale)
+	if err != nil {
+		return nil, err
+	}
+	if opts.ResolveAfter <= 0 {
+		opts.ResolveAfter = DefaultAlertResolveAfter
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultWebhookTimeout
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = NewHTTPClient(opts.Timeout)
+	}
+	n := &AlertmanagerNotifier{
+		url:          strings.TrimSuffix(opts.URL, "/"),
+		bearerToken:  opts.BearerToken,
+		labels:       opts.Labels,
+		resolveAfter: opts.ResolveAfter,
+		locale:       locale,
+		client:       opts.HTTPClient,
+	}
+	if opts.Template != "" {
+		tmpl, err := NewTemplate("alertmanager", opts.Template)
+		if err != nil {
+			return nil, err
+		}
+		n.template = tmpl
+	}
+	return n, nil
+}
+
+// Notify posts the alert of the notification to the Alertmanager
+func (n *AlertmanagerNotifier) Notify(ctx context.Context, notification *Notification) error {
+	alert, err := NewAlert(notification, n.labels, n.template, n.locale, time.Now().UTC(), n.resolveAfter)
+	if err != nil || alert == nil {
+		return err
+	}
+	body, err := json.Marshal([]*Alert{alert})
+	if err != nil {
+		return fmt.Errorf("Failed to encode alert for pipelinerun %s: %w", notification.PipelineRun, err)
+	}
+	resp, err := n.do(ctx, http.MethodPost, "/api/v2/alerts", body)
+	if err != nil {
+		return fmt.Errorf("Failed to send alert for pipelinerun %s: %w", notification.PipelineRun, err)
+	}
+	defer resp.Body.Close()
+	excerpt, _ := io.ReadAll(io.LimitReader(resp.Body, MaxResponseExcerptBytes))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("Alertmanager responded with status %d: %s", resp.StatusCode, strings.TrimSpace(string(excerpt)))
+	}
+	return nil
+}
+
+// Probe gets the status of the Alertmanager, which checks it is reachable and accepts the bearer token
+func (n *AlertmanagerNotifier) Probe(ctx context.Context) error {
+	resp, err := n.do(ctx, http.MethodGet, "/api/v2/status", nil)
+	if err != nil {
+		return fmt.Errorf("Failed to reach Alertmanager: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("Alertmanager status failed with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// do sends a request to the path of the Alertmanager API
+func (n *AlertmanagerNotifier) do(ctx context.Context, method string, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, n.url+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if n.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+n.bearerToken)
+	}
+	return n.client.Do(req)
+}
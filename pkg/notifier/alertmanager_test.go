@@ -0,0 +1,132 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AlertmanagerNotifier", func() {
+	var (
+		server *httptest.Server
+		alerts [][]Alert
+		status int
+	)
+
+	BeforeEach(func() {
+		alerts, status = nil, http.StatusOK
+		mux := http.NewServeMux()
+		mux.HandleFunc("POST /api/v2/alerts", func(w http.ResponseWriter, req *http.Request) {
+			Expect(req.Header.Get("Authorization")).To(Equal("Bearer secret"))
+			Expect(req.Header.Get("Content-Type")).To(Equal("application/json"))
+			var posted []Alert
+			Expect(json.NewDecoder(req.Body).Decode(&posted)).To(Succeed())
+			alerts = append(alerts, posted)
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(`{"code": 400, "message": "invalid alert"}`))
+		})
+		mux.HandleFunc("GET /api/v2/status", func(w http.ResponseWriter, req *http.Request) {
+			if req.Header.Get("Authorization") != "Bearer secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		})
+		server = httptest.NewServer(mux)
+		DeferCleanup(server.Close)
+	})
+
+	newNotifier := func(opts AlertmanagerOptions) *AlertmanagerNotifier {
+		opts.URL = server.URL + "/"
+		opts.BearerToken = "secret"
+		n, err := NewAlertmanagerNotifier(opts)
+		Expect(err).NotTo(HaveOccurred())
+		return n
+	}
+
+	It("should push alerts for failed runs", func() {
+		completion := time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+		n := newNotifier(AlertmanagerOptions{Labels: map[string]string{"severity": "critical", "namespace": "other"}})
+		Expect(n.Notify(context.Background(), &Notification{
+			PipelineRun:    "build-1",
+			Namespace:      "tenant",
+			Status:         StatusFailed,
+			CompletionTime: &completion,
+			FailureStreak:  2,
+			Konflux:        &KonfluxContext{Application: "shop", Component: "cart"},
+			PayloadURL:     "https://archive.example.com/build-1.json",
+			Results:        []Result{{Name: "TEST_OUTPUT", Value: "FAILURE"}},
+		})).To(Succeed())
+
+		Expect(alerts).To(HaveLen(1))
+		Expect(alerts[0]).To(HaveLen(1))
+		alert := alerts[0][0]
+		Expect(alert.Labels).To(Equal(map[string]string{
+			"alertname": "PipelineRunFailed", "namespace": "tenant", "pipelinerun": "build-1",
+			"application": "shop", "component": "cart", "severity": "critical",
+		}))
+		Expect(alert.Annotations).To(Equal(map[string]string{
+			"summary": "PipelineRun build-1 failed", "description": "TEST_OUTPUT: FAILURE", "failureStreak": "2",
+		}))
+		Expect(alert.StartsAt).To(BeTemporally("==", completion))
+		Expect(alert.EndsAt).To(BeTemporally("~", time.Now().Add(DefaultAlertResolveAfter), time.Minute))
+		Expect(alert.GeneratorURL).To(Equal("https://archive.example.com/build-1.json"))
+		Expect(alert.Status).To(BeEmpty())
+	})
+
+	It("should push alerts for long running runs of any kind", func() {
+		n := newNotifier(AlertmanagerOptions{ResolveAfter: time.Hour, Template: "Running for {{ .PipelineRun }}"})
+		Expect(n.Notify(context.Background(), &Notification{PipelineRun: "release-1", Namespace: "tenant",
+			Kind: KindRelease, Status: StatusRunning})).To(Succeed())
+		Expect(alerts).To(HaveLen(1))
+		Expect(alerts[0][0].Labels).To(HaveKeyWithValue("alertname", "ReleaseLongRunning"))
+		Expect(alerts[0][0].Annotations).To(HaveKeyWithValue("description", "Running for release-1"))
+		Expect(alerts[0][0].EndsAt.Sub(alerts[0][0].StartsAt)).To(Equal(time.Hour))
+	})
+
+	It("should not push other notifications", func() {
+		n := newNotifier(AlertmanagerOptions{})
+		for _, status := range []string{"", StatusSucceeded, StatusStarted} {
+			Expect(n.Notify(context.Background(), &Notification{PipelineRun: "build-1", Status: status})).To(Succeed())
+		}
+		Expect(alerts).To(BeEmpty())
+	})
+
+	It("should report rejected alerts", func() {
+		status = http.StatusBadRequest
+		n := newNotifier(AlertmanagerOptions{})
+		err := n.Notify(context.Background(), &Notification{PipelineRun: "build-1", Status: StatusFailed})
+		Expect(err).To(MatchError(ContainSubstring("Alertmanager responded with status 400: {\"code\": 400")))
+	})
+
+	It("should probe the status of the Alertmanager", func() {
+		Expect(newNotifier(AlertmanagerOptions{}).Probe(context.Background())).To(Succeed())
+		n, err := NewAlertmanagerNotifier(AlertmanagerOptions{URL: server.URL})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n.Probe(context.Background())).To(MatchError(ContainSubstring("status 401")))
+	})
+
+	It("should require a URL", func() {
+		_, err := NewAlertmanagerNotifier(AlertmanagerOptions{})
+		Expect(err).To(MatchError("Alertmanager URL must be set"))
+	})
+})
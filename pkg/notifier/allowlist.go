@@ -0,0 +1,178 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ErrHostNotAllowed is returned when connecting to a host that the EgressAllowlist does not allow
+var ErrHostNotAllowed = errors.New("Host is not allowed")
+
+// EgressAllowlist restricts the hosts the connections of all notifiers are made to. It is nil, i.e. all
+// hosts are allowed, unless it is set before the controller starts, together with an EgressTransport
+// dialing with it.
+var EgressAllowlist *HostAllowlist
+
+// HostAllowlist is a list of domains and networks. A host is allowed if it matches one of the domains,
+// or if it is or resolves to an address in one of the networks.
+type HostAllowlist struct {
+	// Lookup resolves host names, defaults to the Go resolver
+	Lookup func(ctx context.Context, host string) ([]net.IPAddr, error)
+
+	domains  []string
+	networks []*net.IPNet
+}
+
+// ParseHostAllowlist creates a HostAllowlist from domains, e.g. hooks.example.com, wildcard domains
+// matching their subdomains, e.g. *.example.com, IP addresses and CIDRs, e.g. 10.0.0.0/8
+// Return error if an entry is none of these
+func ParseHostAllowlist(entries []string) (*HostAllowlist, error) {
+	a := &HostAllowlist{}
+	for _, entry := range entries {
+		entry = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(entry), "."))
+		if entry == "" {
+			continue
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			a.networks = append(a.networks, network)
+			continue
+		}
+		if ip := net.ParseIP(entry); ip != nil {
+			a.networks = append(a.networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+			continue
+		}
+		domain := strings.TrimPrefix(entry, "*.")
+		if domain == "" || strings.ContainsAny(domain, "*/: ") {
+			return nil, fmt.Errorf("Invalid allowlist entry %s: must be a domain, a wildcard domain, an IP address or a CIDR", entry)
+		}
+		if strings.HasPrefix(entry, "*.") {
+			domain = "." + domain
+		}
+		a.domains = append(a.domains, domain)
+	}
+	if len(a.domains) == 0 && len(a.networks) == 0 {
+		return nil, errors.New("Allowlist has no entries")
+	}
+	return a, nil
+}
+
+// Check returns an error wrapping ErrHostNotAllowed if the host is not allowed
+func (a *HostAllowlist) Check(ctx context.Context, host string) error {
+	_, err := a.addresses(ctx, host)
+	return err
+}
+
+// DialContext returns a dial function connecting with dial to allowed hosts only. Hosts allowed by their
+// address are connected to at the allowed addresses they resolve to, so they are not resolved again.
+func (a *HostAllowlist) DialContext(dial func(ctx context.Context, network string, address string) (net.Conn, error)) func(
+	ctx context.Context, network string, address string) (net.Conn, error) {
+	return func(ctx context.Context, network string, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		addrs, err := a.addresses(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		if addrs == nil {
+			return dial(ctx, network, address)
+		}
+		var errs []error
+		for _, addr := range addrs {
+			conn, err := dial(ctx, network, net.JoinHostPort(addr.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			errs = append(errs, err)
+		}
+		return nil, errors.Join(errs...)
+	}
+}
+
+// addresses returns the allowed addresses of the host, or nil if it is allowed by its domain
+// Return error wrapping ErrHostNotAllowed if the host has no allowed address
+func (a *HostAllowlist) addresses(ctx context.Context, host string) ([]net.IP, error) {
+	name := strings.ToLower(strings.TrimSuffix(host, "."))
+	if ip := net.ParseIP(strings.Trim(name, "[]")); ip != nil {
+		if a.allowsIP(ip) {
+			return []net.IP{ip}, nil
+		}
+		return nil, fmt.Errorf("%w: %s", ErrHostNotAllowed, host)
+	}
+	if a.allowsDomain(name) {
+		return nil, nil
+	}
+	if len(a.networks) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrHostNotAllowed, host)
+	}
+	lookup := a.Lookup
+	if lookup == nil {
+		lookup = net.DefaultResolver.LookupIPAddr
+	}
+	resolved, err := lookup(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s does not resolve: %w", ErrHostNotAllowed, host, err)
+	}
+	var allowed []net.IP
+	for _, addr := range resolved {
+		if a.allowsIP(addr.IP) {
+			allowed = append(allowed, addr.IP)
+		}
+	}
+	if len(allowed) == 0 {
+		return nil, fmt.Errorf("%w: %s does not resolve to an allowed address", ErrHostNotAllowed, host)
+	}
+	return allowed, nil
+}
+
+// allowsDomain returns whether the lower case host name matches one of the domains
+func (a *HostAllowlist) allowsDomain(name string) bool {
+	for _, domain := range a.domains {
+		if name == domain || (strings.HasPrefix(domain, ".") && strings.HasSuffix(name, domain)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *HostAllowlist) allowsIP(ip net.IP) bool {
+	for _, network := range a.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// dialEgress connects to the address with the dialer, if the EgressAllowlist allows its host and the
+// guard, which may be nil, does not block its addresses
+func dialEgress(ctx context.Context, dialer *net.Dialer, guard *AddressGuard, network string, address string) (net.Conn, error) {
+	dial := dialer.DialContext
+	if guard != nil {
+		dial = guard.DialContext(dial)
+	}
+	if EgressAllowlist != nil {
+		dial = EgressAllowlist.DialContext(dial)
+	}
+	return dial(ctx, network, address)
+}
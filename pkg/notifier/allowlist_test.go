@@ -0,0 +1,99 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HostAllowlist", func() {
+	lookup := func(_ context.Context, host string) ([]net.IPAddr, error) {
+		switch host {
+		case "internal.example.org":
+			return []net.IPAddr{{IP: net.ParseIP("203.0.113.7")}, {IP: net.ParseIP("10.1.2.3")}}, nil
+		case "public.example.org":
+			return []net.IPAddr{{IP: net.ParseIP("203.0.113.8")}}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	It("should allow hosts by domain and by address", func() {
+		allowlist, err := ParseHostAllowlist([]string{"hooks.slack.com", "*.example.com", "10.0.0.0/8", "192.0.2.1"})
+		Expect(err).NotTo(HaveOccurred())
+		allowlist.Lookup = lookup
+		for _, host := range []string{"hooks.slack.com", "HOOKS.SLACK.COM.", "ci.example.com", "a.b.example.com",
+			"10.20.30.40", "192.0.2.1", "internal.example.org"} {
+			Expect(allowlist.Check(context.Background(), host)).To(Succeed(), host)
+		}
+		for _, host := range []string{"slack.com", "example.com", "evil-example.com", "192.0.2.2", "public.example.org", "unknown.example.org"} {
+			Expect(allowlist.Check(context.Background(), host)).To(MatchError(ErrHostNotAllowed), host)
+		}
+	})
+
+	It("should reject invalid entries", func() {
+		_, err := ParseHostAllowlist([]string{"https://example.com"})
+		Expect(err).To(HaveOccurred())
+		_, err = ParseHostAllowlist([]string{" ", ""})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should only dial the allowed addresses of hosts", func() {
+		allowlist, err := ParseHostAllowlist([]string{"10.0.0.0/8", "ci.example.com"})
+		Expect(err).NotTo(HaveOccurred())
+		allowlist.Lookup = lookup
+		var dialed []string
+		dial := allowlist.DialContext(func(_ context.Context, _ string, address string) (net.Conn, error) {
+			dialed = append(dialed, address)
+			return nil, errors.New("refused")
+		})
+		_, err = dial(context.Background(), "tcp", "internal.example.org:443")
+		Expect(err).To(MatchError("refused"))
+		_, err = dial(context.Background(), "tcp", "ci.example.com:443")
+		Expect(err).To(MatchError("refused"))
+		_, err = dial(context.Background(), "tcp", "public.example.org:443")
+		Expect(err).To(MatchError(ErrHostNotAllowed))
+		Expect(dialed).To(Equal([]string{"10.1.2.3:443", "ci.example.com:443"}))
+	})
+
+	It("should check the destinations of requests relayed to the egress gateway", func() {
+		requests := 0
+		gateway := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { requests++ }))
+		defer gateway.Close()
+		transport, err := NewGatewayTransport(gateway.URL, nil)
+		Expect(err).NotTo(HaveOccurred())
+		allowlist, err := ParseHostAllowlist([]string{"*.example.com"})
+		Expect(err).NotTo(HaveOccurred())
+		previous := EgressAllowlist
+		DeferCleanup(func() { EgressAllowlist = previous })
+		EgressAllowlist = allowlist
+
+		client := &http.Client{Transport: transport}
+		_, err = client.Get("https://hooks.example.net/builds")
+		Expect(err).To(MatchError(ErrHostNotAllowed))
+		response, err := client.Get("https://hooks.example.com/builds")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(response.Body.Close()).To(Succeed())
+		Expect(requests).To(Equal(1))
+	})
+})
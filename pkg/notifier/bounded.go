@@ -0,0 +1,85 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultMaxRenderedBytes is the default of MaxRenderedBytes
+const DefaultMaxRenderedBytes = 4 << 20
+
+// MaxRenderedBytes bounds the payloads rendered with templates, reports and the XML encoding of notifications.
+// They are written to a buffer that fails as soon as the bound is exceeded, so pathological results or logs
+// cannot make the controller assemble unbounded payloads in memory. It is DefaultMaxRenderedBytes unless it is
+// set before the controller starts; zero disables the bound.
+var MaxRenderedBytes = DefaultMaxRenderedBytes
+
+// ErrPayloadTooLarge is returned when a payload exceeds MaxRenderedBytes
+var ErrPayloadTooLarge = errors.New("Payload too large")
+
+// boundedBuffer is a buffer failing the writes that would make it exceed its limit, if it is positive
+type boundedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+// newBoundedBuffer returns a buffer bounded by MaxRenderedBytes
+func newBoundedBuffer() *boundedBuffer {
+	return &boundedBuffer{limit: MaxRenderedBytes}
+}
+
+func (b *boundedBuffer) Write(p []byte) (int, error) {
+	if err := b.reserve(len(p)); err != nil {
+		return 0, err
+	}
+	return b.Buffer.Write(p)
+}
+
+// WriteString is used by text/template instead of Write, it must enforce the limit as well
+func (b *boundedBuffer) WriteString(s string) (int, error) {
+	if err := b.reserve(len(s)); err != nil {
+		return 0, err
+	}
+	return b.Buffer.WriteString(s)
+}
+
+func (b *boundedBuffer) reserve(n int) error {
+	if b.limit > 0 && b.Len()+n > b.limit {
+		return fmt.Errorf("%w: exceeds %d bytes", ErrPayloadTooLarge, b.limit)
+	}
+	return nil
+}
+
+// checkRenderedSize returns an error wrapping ErrPayloadTooLarge if the payload exceeds MaxRenderedBytes
+func checkRenderedSize(payload []byte) error {
+	if MaxRenderedBytes > 0 && len(payload) > MaxRenderedBytes {
+		return fmt.Errorf("%w: exceeds %d bytes", ErrPayloadTooLarge, MaxRenderedBytes)
+	}
+	return nil
+}
+
+// repeat is the sprig repeat template function, failing instead of building strings longer than MaxRenderedBytes
+func repeat(count int, value string) (string, error) {
+	if MaxRenderedBytes > 0 && count > 0 && len(value) > MaxRenderedBytes/count {
+		return "", fmt.Errorf("%w: repeat exceeds %d bytes", ErrPayloadTooLarge, MaxRenderedBytes)
+	}
+	return strings.Repeat(value, max(count, 0)), nil
+}
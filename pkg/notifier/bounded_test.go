@@ -0,0 +1,93 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Rendered payload bound", func() {
+	BeforeEach(func() {
+		MaxRenderedBytes = 64
+		DeferCleanup(func() { MaxRenderedBytes = DefaultMaxRenderedBytes })
+	})
+
+	notification := &Notification{
+		PipelineRun: "build-1",
+		Results:     []Result{{Name: "LOG", Value: strings.Repeat("x", 100)}},
+	}
+
+	It("should stop rendering templates exceeding the bound", func() {
+		tmpl, err := NewTemplate("test", `{{ .PipelineRun }} {{ result "LOG" }}`)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = Render(tmpl, notification)
+		Expect(err).To(MatchError(ErrPayloadTooLarge))
+	})
+
+	It("should render templates within the bound", func() {
+		tmpl, err := NewTemplate("test", `{{ .PipelineRun }} {{ repeat 3 "ab" }}`)
+		Expect(err).NotTo(HaveOccurred())
+		rendered, err := Render(tmpl, notification)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(rendered)).To(Equal("build-1 ababab"))
+	})
+
+	It("should not build repeated strings exceeding the bound", func() {
+		tmpl, err := NewTemplate("test", `{{ repeat 1000000000 "abc" }}`)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = Render(tmpl, notification)
+		Expect(err).To(MatchError(ErrPayloadTooLarge))
+	})
+
+	It("should stop rendering reports exceeding the bound", func() {
+		n, err := NewReportNotifier(ReportOptions{Store: &memoryReportStore{}})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = n.Render(&PipelineRunReport{Notification: notification})
+		Expect(err).To(MatchError(ErrPayloadTooLarge))
+	})
+
+	It("should fail encoding payloads exceeding the bound", func() {
+		for _, contentType := range []string{ContentTypeJSON, ContentTypeXML, ContentTypeForm} {
+			n, err := NewWebhookNotifier(WebhookOptions{URL: "http://localhost", ContentType: contentType})
+			Expect(err).NotTo(HaveOccurred())
+			_, err = n.body(notification)
+			Expect(err).To(MatchError(ErrPayloadTooLarge))
+		}
+	})
+
+	It("should drop results of payloads exceeding the bound to fit the webhook limit", func() {
+		MaxRenderedBytes = 200
+		n, err := NewWebhookNotifier(WebhookOptions{URL: "http://localhost", ContentType: ContentTypeXML, MaxPayloadBytes: 1024})
+		Expect(err).NotTo(HaveOccurred())
+		body, err := n.boundedBody(notification)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(body)).To(ContainSubstring("LOG"))
+		Expect(string(body)).NotTo(ContainSubstring("xxx"))
+	})
+
+	It("should not bound payloads when disabled", func() {
+		MaxRenderedBytes = 0
+		tmpl, err := NewTemplate("test", `{{ result "LOG" }}`)
+		Expect(err).NotTo(HaveOccurred())
+		rendered, err := Render(tmpl, notification)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rendered).To(HaveLen(100))
+	})
+})
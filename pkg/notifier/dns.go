@@ -0,0 +1,254 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Defaults of the DNSCache
+const (
+	DefaultDNSMaxTTL      = 5 * time.Minute
+	DefaultDNSNegativeTTL = 30 * time.Second
+	DefaultDNSTimeout     = 5 * time.Second
+)
+
+// DNSCacheOptions configures a DNSCache
+type DNSCacheOptions struct {
+	// MaxTTL caps the TTL of the records, and is used for hosts whose TTL is unknown, e.g. hosts of /etc/hosts
+	MaxTTL time.Duration
+	// NegativeTTL is the time failed resolutions are cached for
+	NegativeTTL time.Duration
+	// Timeout is the deadline of a single resolution
+	Timeout time.Duration
+	// Observe is called with the outcome of every resolution, cached is true if it hit the cache
+	Observe func(host string, cached bool, err error)
+}
+
+// DNSCache caches the addresses of the destination hosts for the TTL of their records, capped to MaxTTL,
+// and caches failed resolutions for NegativeTTL. Resolutions of a host are shared by concurrent requests
+// and bounded by Timeout, so a host that does not resolve fails fast instead of holding the workers that
+// deliver to other destinations.
+type DNSCache struct {
+	opts    DNSCacheOptions
+	mu      sync.Mutex
+	entries map[string]*dnsEntry
+	now     func() time.Time
+	lookup  func(ctx context.Context, host string) ([]net.IPAddr, time.Duration, error)
+	dialer  *net.Dialer
+}
+
+// dnsEntry is the cached or in flight resolution of a host
+type dnsEntry struct {
+	ready   chan struct{}
+	addrs   []net.IPAddr
+	err     error
+	expires time.Time
+}
+
+// NewDNSCache creates a DNSCache from the given options
+func NewDNSCache(opts DNSCacheOptions) *DNSCache {
+	if opts.MaxTTL <= 0 {
+		opts.MaxTTL = DefaultDNSMaxTTL
+	}
+	if opts.NegativeTTL <= 0 {
+		opts.NegativeTTL = DefaultDNSNegativeTTL
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultDNSTimeout
+	}
+	return &DNSCache{
+		opts:    opts,
+		entries: map[string]*dnsEntry{},
+		now:     time.Now,
+		lookup:  lookupWithTTL,
+		dialer:  &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+	}
+}
+
+// LookupIPAddr returns the addresses of the host, from the cache if they did not expire
+// Return error if the host does not resolve, or ctx is done first
+func (c *DNSCache) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IPAddr{{IP: ip}}, nil
+	}
+	c.mu.Lock()
+	entry, ok := c.entries[host]
+	cached := ok
+	if ok && entry.resolved() && !c.now().Before(entry.expires) {
+		ok = false
+	}
+	if !ok {
+		entry = &dnsEntry{ready: make(chan struct{})}
+		c.entries[host] = entry
+		go c.resolve(host, entry)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-entry.ready:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if c.opts.Observe != nil {
+		c.opts.Observe(host, cached && ok, entry.err)
+	}
+	return entry.addrs, entry.err
+}
+
+// resolve resolves the host into the entry, independently of the requests waiting for it
+func (c *DNSCache) resolve(host string, entry *dnsEntry) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.opts.Timeout)
+	defer cancel()
+	addrs, ttl, err := c.lookup(ctx, host)
+	if err != nil {
+		entry.err = fmt.Errorf("Failed to resolve %s: %w", host, err)
+		ttl = c.opts.NegativeTTL
+	} else if ttl <= 0 || ttl > c.opts.MaxTTL {
+		ttl = c.opts.MaxTTL
+	}
+	entry.addrs = addrs
+	entry.expires = c.now().Add(ttl)
+	close(entry.ready)
+}
+
+// resolved returns a boolean indicating whether the resolution of the entry completed
+func (e *dnsEntry) resolved() bool {
+	select {
+	case <-e.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+// DialContext connects to the address, whose host is resolved with the cache, trying its addresses in turn
+func (c *DNSCache) DialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := c.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	for _, addr := range addrs {
+		conn, err := c.dialer.DialContext(ctx, network, net.JoinHostPort(addr.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("Host %s has no address", host)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// Transport returns a clone of http.DefaultTransport resolving hosts with the cache
+func (c *DNSCache) Transport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = c.DialContext
+	return transport
+}
+
+// lookupWithTTL resolves the host with the Go resolver and returns the lowest TTL of the answers it received
+// over UDP, or 0 if it is unknown
+func lookupWithTTL(ctx context.Context, host string) ([]net.IPAddr, time.Duration, error) {
+	var mu sync.Mutex
+	var ttl time.Duration
+	dialer := &net.Dialer{}
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network string, address string) (net.Conn, error) {
+			conn, err := dialer.DialContext(ctx, network, address)
+			if err != nil {
+				return nil, err
+			}
+			udp, ok := conn.(*net.UDPConn)
+			if !ok {
+				return conn, nil
+			}
+			return &ttlConn{UDPConn: udp, observe: func(answerTTL time.Duration) {
+				mu.Lock()
+				defer mu.Unlock()
+				if ttl == 0 || answerTTL < ttl {
+					ttl = answerTTL
+				}
+			}}, nil
+		},
+	}
+	addrs, err := resolver.LookupIPAddr(ctx, host)
+	mu.Lock()
+	defer mu.Unlock()
+	return addrs, ttl, err
+}
+
+// ttlConn reports the TTL of the DNS responses read from the connection
+type ttlConn struct {
+	*net.UDPConn
+	observe func(ttl time.Duration)
+}
+
+// Read reads a DNS response and reports its TTL
+func (c *ttlConn) Read(b []byte) (int, error) {
+	n, err := c.UDPConn.Read(b)
+	if err == nil {
+		if ttl, ok := minAnswerTTL(b[:n]); ok {
+			c.observe(ttl)
+		}
+	}
+	return n, err
+}
+
+// minAnswerTTL returns the lowest TTL of the address and alias answers of the DNS message, and false if it has none
+func minAnswerTTL(message []byte) (time.Duration, bool) {
+	var parser dnsmessage.Parser
+	if _, err := parser.Start(message); err != nil {
+		return 0, false
+	}
+	if err := parser.SkipAllQuestions(); err != nil {
+		return 0, false
+	}
+	var ttl uint32
+	found := false
+	for {
+		header, err := parser.AnswerHeader()
+		if err != nil {
+			break
+		}
+		if header.Type == dnsmessage.TypeA || header.Type == dnsmessage.TypeAAAA || header.Type == dnsmessage.TypeCNAME {
+			if !found || header.TTL < ttl {
+				ttl = header.TTL
+			}
+			found = true
+		}
+		if err := parser.SkipAnswer(); err != nil {
+			break
+		}
+	}
+	return time.Duration(ttl) * time.Second, found
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+var _ = Describe("DNSCache", func() {
+	var (
+		cache   *DNSCache
+		now     time.Time
+		lookups atomic.Int32
+		release chan struct{}
+	)
+
+	BeforeEach(func() {
+		now = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+		lookups.Store(0)
+		release = make(chan struct{})
+		cache = NewDNSCache(DNSCacheOptions{MaxTTL: time.Minute, NegativeTTL: 10 * time.Second})
+		cache.now = func() time.Time { return now }
+		cache.lookup = func(ctx context.Context, host string) ([]net.IPAddr, time.Duration, error) {
+			lookups.Add(1)
+			switch host {
+			case "missing.example.com":
+				return nil, 0, errors.New("no such host")
+			case "slow.example.com":
+				<-release
+			}
+			return []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}}, 30 * time.Second, nil
+		}
+	})
+
+	It("should cache addresses for the TTL of their records", func() {
+		Expect(cache.LookupIPAddr(context.Background(), "hooks.example.com")).To(HaveLen(1))
+		Expect(cache.LookupIPAddr(context.Background(), "hooks.example.com")).To(HaveLen(1))
+		Expect(lookups.Load()).To(BeEquivalentTo(1))
+
+		now = now.Add(31 * time.Second)
+		Expect(cache.LookupIPAddr(context.Background(), "hooks.example.com")).To(HaveLen(1))
+		Expect(lookups.Load()).To(BeEquivalentTo(2))
+	})
+
+	It("should cache failed resolutions for the negative TTL", func() {
+		var observed []error
+		cache.opts.Observe = func(host string, cached bool, err error) { observed = append(observed, err) }
+		_, err := cache.LookupIPAddr(context.Background(), "missing.example.com")
+		Expect(err).To(MatchError(ContainSubstring("Failed to resolve missing.example.com")))
+		_, err = cache.LookupIPAddr(context.Background(), "missing.example.com")
+		Expect(err).To(HaveOccurred())
+		Expect(lookups.Load()).To(BeEquivalentTo(1))
+		Expect(observed).To(HaveLen(2))
+
+		now = now.Add(11 * time.Second)
+		_, _ = cache.LookupIPAddr(context.Background(), "missing.example.com")
+		Expect(lookups.Load()).To(BeEquivalentTo(2))
+	})
+
+	It("should not let a stalled host hold the others", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err := cache.LookupIPAddr(ctx, "slow.example.com")
+		Expect(err).To(MatchError(context.DeadlineExceeded))
+		Expect(cache.LookupIPAddr(context.Background(), "hooks.example.com")).To(HaveLen(1))
+		close(release)
+		Eventually(func() error {
+			_, err := cache.LookupIPAddr(context.Background(), "slow.example.com")
+			return err
+		}).Should(Succeed())
+		Expect(lookups.Load()).To(BeEquivalentTo(2))
+	})
+
+	It("should send requests to the resolved addresses", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+		defer server.Close()
+		client := &http.Client{Transport: cache.Transport()}
+		resp, err := client.Get(strings.Replace(server.URL, "127.0.0.1", "hooks.example.com", 1))
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+	})
+
+	It("should read the lowest TTL of the answers", func() {
+		builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{Response: true})
+		Expect(builder.StartAnswers()).To(Succeed())
+		name := dnsmessage.MustNewName("hooks.example.com.")
+		for _, ttl := range []uint32{300, 60} {
+			Expect(builder.AResource(dnsmessage.ResourceHeader{Name: name, Class: dnsmessage.ClassINET, TTL: ttl},
+				dnsmessage.AResource{A: [4]byte{127, 0, 0, 1}})).To(Succeed())
+		}
+		message, err := builder.Finish()
+		Expect(err).NotTo(HaveOccurred())
+		ttl, ok := minAnswerTTL(message)
+		Expect(ok).To(BeTrue())
+		Expect(ttl).To(Equal(time.Minute))
+	})
+})
@@ -0,0 +1,97 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// MediaTypeJOSE is the content type of request bodies encrypted as a JWE in compact serialization
+const MediaTypeJOSE = "application/jose"
+
+// JWEEncrypter encrypts request bodies as a JWE for a recipient public key. RSA keys are used with
+// RSA-OAEP-256 and EC keys with ECDH-ES+A256KW, the content is encrypted with A256GCM.
+type JWEEncrypter struct {
+	recipient jose.Recipient
+}
+
+// NewJWEEncrypter creates a JWEEncrypter from a PEM encoded or JWK public key.
+// The key ID of a JWK is set as the kid header, so recipients can rotate keys.
+func NewJWEEncrypter(publicKey []byte) (*JWEEncrypter, error) {
+	var key any
+	var keyID string
+	if block, _ := pem.Decode(publicKey); block != nil {
+		var err error
+		switch block.Type {
+		case "PUBLIC KEY":
+			key, err = x509.ParsePKIXPublicKey(block.Bytes)
+		case "RSA PUBLIC KEY":
+			key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		default:
+			return nil, fmt.Errorf("Unsupported encryption key PEM block %s", block.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse encryption key: %w", err)
+		}
+	} else {
+		jwk := &jose.JSONWebKey{}
+		err := jwk.UnmarshalJSON(bytes.TrimSpace(publicKey))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse encryption key, it is neither PEM nor JWK: %w", err)
+		}
+		if !jwk.IsPublic() {
+			return nil, errors.New("encryption key must be a public key")
+		}
+		key, keyID = jwk.Key, jwk.KeyID
+	}
+	recipient := jose.Recipient{Key: key, KeyID: keyID}
+	switch key.(type) {
+	case *rsa.PublicKey:
+		recipient.Algorithm = jose.RSA_OAEP_256
+	case *ecdsa.PublicKey:
+		recipient.Algorithm = jose.ECDH_ES_A256KW
+	default:
+		return nil, fmt.Errorf("Unsupported encryption key type %T", key)
+	}
+	return &JWEEncrypter{recipient: recipient}, nil
+}
+
+// Encrypt returns the JWE of the body, whose cty header is the media type of the body
+func (e *JWEEncrypter) Encrypt(body []byte, mediaType string) ([]byte, error) {
+	opts := (&jose.EncrypterOptions{}).WithContentType(jose.ContentType(mediaType))
+	encrypter, err := jose.NewEncrypter(jose.A256GCM, e.recipient, opts)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create encrypter: %w", err)
+	}
+	object, err := encrypter.Encrypt(body)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to encrypt body: %w", err)
+	}
+	serialized, err := object.CompactSerialize()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to serialize encrypted body: %w", err)
+	}
+	return []byte(serialized), nil
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultEnrichmentTimeout is the deadline of a single request to an enrichment hook
+const DefaultEnrichmentTimeout = 5 * time.Second
+
+// MaxEnrichmentBytes limits the size of the responses of enrichment hooks
+const MaxEnrichmentBytes = 1 << 20
+
+// Enricher adds context to notifications before they are rendered, e.g. ticket links or the owners
+// of a component looked up in a CMDB. The returned values are merged into the Enrichment of the
+// notification, which templates read as {{ .Enrichment.<key> }}.
+type Enricher interface {
+	Enrich(ctx context.Context, notification *Notification) (map[string]any, error)
+}
+
+// EnricherFunc is an in-process Enricher
+type EnricherFunc func(ctx context.Context, notification *Notification) (map[string]any, error)
+
+// Enrich calls the function
+func (f EnricherFunc) Enrich(ctx context.Context, notification *Notification) (map[string]any, error) {
+	return f(ctx, notification)
+}
+
+// EnrichmentOptions configures a WebhookEnricher
+type EnrichmentOptions struct {
+	// URL is the endpoint notifications are posted to
+	URL string
+	// Timeout is the deadline of a single request
+	Timeout time.Duration
+	// HTTPClient is used to send requests, defaults to a client with Timeout
+	HTTPClient *http.Client
+}
+
+// WebhookEnricher posts the JSON encoded notification to an HTTP endpoint, which responds with
+// a JSON object of the values to add to it
+type WebhookEnricher struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookEnricher creates a WebhookEnricher from the given options
+func NewWebhookEnricher(opts EnrichmentOptions) (*WebhookEnricher, error) {
+	if opts.URL == "" {
+		return nil, errors.New("enrichment URL must be set")
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultEnrichmentTimeout
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = NewHTTPClient(opts.Timeout)
+	}
+	return &WebhookEnricher{url: opts.URL, client: opts.HTTPClient}, nil
+}
+
+// Enrich posts the notification to the enrichment URL and returns the JSON object it responds with.
+// Responses with a non 2xx status and responses that are not JSON objects are reported as errors.
+func (e *WebhookEnricher) Enrich(ctx context.Context, notification *Notification) (map[string]any, error) {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to encode notification for pipelinerun %s: %w", notification.PipelineRun, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create enrichment request: %w", err)
+	}
+	req.Header.Set("Content-Type", webhookMediaTypes[ContentTypeJSON])
+	req.Header.Set("Accept", webhookMediaTypes[ContentTypeJSON])
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to enrich notification for pipelinerun %s: %w", notification.PipelineRun, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxEnrichmentBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read enrichment of pipelinerun %s: %w", notification.PipelineRun, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		excerpt := data[:min(len(data), MaxResponseExcerptBytes)]
+		return nil, fmt.Errorf("Enrichment of pipelinerun %s failed with status %d: %s",
+			notification.PipelineRun, resp.StatusCode, strings.TrimSpace(strings.ToValidUTF8(string(excerpt), "")))
+	}
+	if len(data) > MaxEnrichmentBytes {
+		return nil, fmt.Errorf("Enrichment of pipelinerun %s exceeds %d bytes", notification.PipelineRun, MaxEnrichmentBytes)
+	}
+	values := map[string]any{}
+	err = json.Unmarshal(data, &values)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode enrichment of pipelinerun %s: %w", notification.PipelineRun, err)
+	}
+	return values, nil
+}
+
+// Enrich merges the values returned by the enrichers, in order, into the Enrichment of the notification.
+// Objects are merged key by key, and other values returned by later enrichers replace the earlier ones.
+// Enrichers receive the notification as enriched by the previous ones. The values of the enrichers that
+// failed are not merged, and their errors are returned joined.
+func Enrich(ctx context.Context, notification *Notification, enrichers ...Enricher) error {
+	var errs []error
+	for _, enricher := range enrichers {
+		values, err := enricher.Enrich(ctx, notification)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if len(values) == 0 {
+			continue
+		}
+		if notification.Enrichment == nil {
+			notification.Enrichment = map[string]any{}
+		}
+		mergeValues(notification.Enrichment, values)
+	}
+	return errors.Join(errs...)
+}
+
+// mergeValues merges the values into target, recursively for objects
+func mergeValues(target map[string]any, values map[string]any) {
+	for key, value := range values {
+		existing, ok := target[key].(map[string]any)
+		update, isMap := value.(map[string]any)
+		if ok && isMap {
+			merged := make(map[string]any, len(existing))
+			for k, v := range existing {
+				merged[k] = v
+			}
+			mergeValues(merged, update)
+			target[key] = merged
+			continue
+		}
+		target[key] = value
+	}
+}
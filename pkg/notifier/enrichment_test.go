@@ -0,0 +1,94 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Enrich", func() {
+	It("should merge the response of the hook into the template context", func() {
+		var received Notification
+		hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			Expect(json.NewDecoder(req.Body).Decode(&received)).To(Succeed())
+			_, _ = w.Write([]byte(`{"ticket":{"url":"https://jira.example.com/BUILD-1"},"owners":["alice"]}`))
+		}))
+		defer hook.Close()
+		enricher, err := NewWebhookEnricher(EnrichmentOptions{URL: hook.URL})
+		Expect(err).NotTo(HaveOccurred())
+		notification := &Notification{PipelineRun: "build", Namespace: "team-a"}
+		Expect(Enrich(context.Background(), notification, enricher)).To(Succeed())
+		Expect(received.PipelineRun).To(Equal("build"))
+
+		tmpl, err := NewTemplate("test", `{{ .Enrichment.ticket.url }} {{ index .Enrichment.owners 0 }}`)
+		Expect(err).NotTo(HaveOccurred())
+		rendered, err := Render(tmpl, notification)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(rendered)).To(Equal("https://jira.example.com/BUILD-1 alice"))
+	})
+
+	It("should merge objects returned by several enrichers key by key", func() {
+		first := EnricherFunc(func(context.Context, *Notification) (map[string]any, error) {
+			return map[string]any{"cmdb": map[string]any{"owner": "alice", "tier": "1"}, "ticket": "BUILD-1"}, nil
+		})
+		second := EnricherFunc(func(_ context.Context, notification *Notification) (map[string]any, error) {
+			Expect(notification.Enrichment).To(HaveKey("cmdb"))
+			return map[string]any{"cmdb": map[string]any{"owner": "bob"}}, nil
+		})
+		notification := &Notification{PipelineRun: "build"}
+		Expect(Enrich(context.Background(), notification, first, second)).To(Succeed())
+		Expect(notification.Enrichment).To(Equal(map[string]any{
+			"cmdb": map[string]any{"owner": "bob", "tier": "1"}, "ticket": "BUILD-1",
+		}))
+	})
+
+	It("should keep the values of the other enrichers when one fails", func() {
+		failing := EnricherFunc(func(context.Context, *Notification) (map[string]any, error) {
+			return nil, errors.New("unavailable")
+		})
+		working := EnricherFunc(func(context.Context, *Notification) (map[string]any, error) {
+			return map[string]any{"ticket": "BUILD-1"}, nil
+		})
+		notification := &Notification{PipelineRun: "build"}
+		Expect(Enrich(context.Background(), notification, failing, working)).To(MatchError("unavailable"))
+		Expect(notification.Enrichment).To(Equal(map[string]any{"ticket": "BUILD-1"}))
+	})
+
+	It("should report hooks responding with an error or with something else than an object", func() {
+		status := http.StatusBadGateway
+		hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(`["not", "an", "object"]`))
+		}))
+		defer hook.Close()
+		enricher, err := NewWebhookEnricher(EnrichmentOptions{URL: hook.URL})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = enricher.Enrich(context.Background(), &Notification{PipelineRun: "build"})
+		Expect(err).To(MatchError(ContainSubstring("failed with status 502")))
+
+		status = http.StatusOK
+		_, err = enricher.Enrich(context.Background(), &Notification{PipelineRun: "build"})
+		Expect(err).To(MatchError(ContainSubstring("Failed to decode enrichment")))
+	})
+})
@@ -0,0 +1,279 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/jwt"
+)
+
+// DefaultFCMAPIURL is the base URL of the Firebase Cloud Messaging HTTP v1 API
+const DefaultFCMAPIURL = "https://fcm.googleapis.com"
+
+// fcmScope is the OAuth scope of the Firebase Cloud Messaging API
+const fcmScope = "https://www.googleapis.com/auth/firebase.messaging"
+
+// FCMOptions configures an FCMNotifier
+type FCMOptions struct {
+	// ServiceAccountKey is the JSON key of the Google service account sending messages
+	ServiceAccountKey []byte
+	// ProjectID is the Firebase project, defaults to the project of the service account
+	ProjectID string
+	// Topic is the topic messages are sent to, the app subscribes devices to it
+	Topic string
+	// DeviceTokens are the registration tokens of the devices messages are sent to
+	DeviceTokens []string
+	// Template is an optional Go template rendering the body of notifications
+	Template string
+	// Locale is the language of the built-in messages, see Locales. Defaults to DefaultLocale.
+	Locale string
+	// APIURL is the base URL of the FCM API, defaults to DefaultFCMAPIURL
+	APIURL string
+	// Timeout is the deadline of a single request
+	Timeout time.Duration
+	// HTTPClient is used to send requests and to get access tokens, defaults to a client with Timeout
+	HTTPClient *http.Client
+}
+
+// fcmServiceAccountKey is the subset of a Google service account JSON key used to get access tokens
+type fcmServiceAccountKey struct {
+	Type         string `json:"type"`
+	ProjectID    string `json:"project_id"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	ClientEmail  string `json:"client_email"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// fcmMessage is a message of the FCM HTTP v1 API, sent to a single topic or device
+type fcmMessage struct {
+	Topic        string            `json:"topic,omitempty"`
+	Token        string            `json:"token,omitempty"`
+	Notification fcmNotification   `json:"notification"`
+	Data         map[string]string `json:"data"`
+	Android      fcmAndroid        `json:"android"`
+	APNS         fcmAPNS           `json:"apns"`
+}
+
+type fcmNotification struct {
+	Title string `json:"title"`
+	Body  string `json:"body,omitempty"`
+}
+
+type fcmAndroid struct {
+	CollapseKey string `json:"collapse_key"`
+}
+
+type fcmAPNS struct {
+	Headers map[string]string `json:"headers"`
+}
+
+// fcmError is the error response of the FCM API
+type fcmError struct {
+	Error struct {
+		Status  string `json:"status"`
+		Message string `json:"message"`
+		Details []struct {
+			ErrorCode string `json:"errorCode"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+// FCMNotifier sends notifications as Firebase Cloud Messaging push notifications to the devices of
+// a mobile app, through a topic or device registration tokens. Android and iOS collapse the
+// notifications about the same PipelineRun.
+type FCMNotifier struct {
+	sendURL      string
+	topic        string
+	deviceTokens []string
+	template     *template.Template
+	locale       *Locale
+	client       *http.Client
+	tokens       oauth2.TokenSource
+}
+
+// NewFCMNotifier creates an FCMNotifier from the given options
+func NewFCMNotifier(opts FCMOptions) (*FCMNotifier, error) {
+	key := fcmServiceAccountKey{}
+	err := json.Unmarshal(opts.ServiceAccountKey, &key)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode FCM service account key: %w", err)
+	}
+	if key.Type != "service_account" || key.ClientEmail == "" || key.PrivateKey == "" {
+		return nil, errors.New("FCM service account key must be the JSON key of a service account")
+	}
+	if opts.Topic == "" && len(opts.DeviceTokens) == 0 {
+		return nil, errors.New("FCM topic or device tokens must be set")
+	}
+	if opts.ProjectID == "" {
+		opts.ProjectID = key.ProjectID
+	}
+	if opts.ProjectID == "" {
+		return nil, errors.New("FCM project ID must be set")
+	}
+	locale, err := LookupLocale(opts.Locale)
+	if err != nil {
+		return nil, err
+	}
+	if key.TokenURI == "" {
+		key.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	if opts.APIURL == "" {
+		opts.APIURL = DefaultFCMAPIURL
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultWebhookTimeout
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = NewHTTPClient(opts.Timeout)
+	}
+	config := &jwt.Config{
+		Email:        key.ClientEmail,
+		PrivateKey:   []byte(key.PrivateKey),
+		PrivateKeyID: key.PrivateKeyID,
+		Scopes:       []string{fcmScope},
+		TokenURL:     key.TokenURI,
+	}
+	n := &FCMNotifier{
+		sendURL:      strings.TrimSuffix(opts.APIURL, "/") + "/v1/projects/" + opts.ProjectID + "/messages:send",
+		topic:        strings.TrimPrefix(opts.Topic, "/topics/"),
+		deviceTokens: opts.DeviceTokens,
+		locale:       locale,
+		client:       opts.HTTPClient,
+		tokens:       config.TokenSource(context.WithValue(context.Background(), oauth2.HTTPClient, opts.HTTPClient)),
+	}
+	if opts.Template != "" {
+		tmpl, err := NewTemplate("fcm", opts.Template)
+		if err != nil {
+			return nil, err
+		}
+		n.template = tmpl
+	}
+	return n, nil
+}
+
+// Notify sends the notification to the topic and to every device. Device tokens FCM reports as
+// unregistered are skipped, the app has to register the device again.
+func (n *FCMNotifier) Notify(ctx context.Context, notification *Notification) error {
+	status := notification.Status
+	if status == "" {
+		status = StatusSucceeded
+	}
+	collapseKey := notification.Namespace + "/" + notification.PipelineRun
+	message := fcmMessage{
+		Notification: fcmNotification{
+			Title: n.locale.status(notification, notification.PipelineRun),
+		},
+		Data: map[string]string{
+			"namespace":   notification.Namespace,
+			"pipelineRun": notification.PipelineRun,
+			"status":      status,
+		},
+		Android: fcmAndroid{CollapseKey: collapseKey},
+		// APNs collapse identifiers are limited to 64 bytes
+		APNS: fcmAPNS{Headers: map[string]string{"apns-collapse-id": collapseKey[:min(len(collapseKey), 64)]}},
+	}
+	if notification.PayloadURL != "" {
+		message.Data["url"] = notification.PayloadURL
+	}
+	if n.template != nil {
+		body, err := Render(n.template, notification)
+		if err != nil {
+			return err
+		}
+		message.Notification.Body = string(body)
+	} else {
+		var lines []string
+		for _, result := range notification.Results {
+			lines = append(lines, result.Name+": "+result.String())
+		}
+		message.Notification.Body = strings.Join(lines, "\n")
+	}
+	var errs []error
+	if n.topic != "" {
+		message.Topic = n.topic
+		if err := n.send(ctx, message); err != nil {
+			errs = append(errs, err)
+		}
+		message.Topic = ""
+	}
+	for _, token := range n.deviceTokens {
+		message.Token = token
+		if err := n.send(ctx, message); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("Failed to send FCM message for pipelinerun %s: %w", notification.PipelineRun, err)
+	}
+	return nil
+}
+
+// Probe gets an access token, which checks the service account key of the notifier
+func (n *FCMNotifier) Probe(ctx context.Context) error {
+	_, err := n.tokens.Token()
+	if err != nil {
+		return fmt.Errorf("Failed to get FCM access token: %w", err)
+	}
+	return nil
+}
+
+// send sends a message to its topic or device
+func (n *FCMNotifier) send(ctx context.Context, message fcmMessage) error {
+	token, err := n.tokens.Token()
+	if err != nil {
+		return fmt.Errorf("Failed to get FCM access token: %w", err)
+	}
+	body, err := json.Marshal(map[string]fcmMessage{"message": message})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.sendURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	token.SetAuthHeader(req)
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 300 {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+		return nil
+	}
+	response := fcmError{}
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&response)
+	for _, detail := range response.Error.Details {
+		if detail.ErrorCode == "UNREGISTERED" && message.Token != "" {
+			return nil
+		}
+	}
+	return fmt.Errorf("FCM responded with status %d: %s %s", resp.StatusCode, response.Error.Status, response.Error.Message)
+}
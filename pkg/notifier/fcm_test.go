@@ -0,0 +1,131 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FCMNotifier", func() {
+	var (
+		server     *httptest.Server
+		messages   []map[string]any
+		tokenCalls int
+		key        []byte
+	)
+
+	BeforeEach(func() {
+		messages, tokenCalls = nil, 0
+		mux := http.NewServeMux()
+		mux.HandleFunc("POST /token", func(w http.ResponseWriter, req *http.Request) {
+			Expect(req.ParseForm()).To(Succeed())
+			Expect(req.Form.Get("grant_type")).To(Equal("urn:ietf:params:oauth:grant-type:jwt-bearer"))
+			tokenCalls++
+			if strings.Contains(req.Form.Get("assertion"), ".") {
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"access_token": "fcm-token", "token_type": "Bearer", "expires_in": 3600}`))
+				return
+			}
+			http.Error(w, `{"error": "invalid_grant"}`, http.StatusBadRequest)
+		})
+		mux.HandleFunc("POST /v1/projects/dashboard/messages:send", func(w http.ResponseWriter, req *http.Request) {
+			Expect(req.Header.Get("Authorization")).To(Equal("Bearer fcm-token"))
+			body := map[string]map[string]any{}
+			Expect(json.NewDecoder(req.Body).Decode(&body)).To(Succeed())
+			messages = append(messages, body["message"])
+			switch body["message"]["token"] {
+			case "stale":
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`{"error": {"code": 404, "status": "NOT_FOUND", "message": "Requested entity was not found.",
+					"details": [{"@type": "type.googleapis.com/google.firebase.fcm.v1.FcmError", "errorCode": "UNREGISTERED"}]}}`))
+			case "invalid":
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error": {"code": 400, "status": "INVALID_ARGUMENT", "message": "The registration token is not valid"}}`))
+			}
+		})
+		server = httptest.NewServer(mux)
+		DeferCleanup(server.Close)
+
+		privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).NotTo(HaveOccurred())
+		der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+		Expect(err).NotTo(HaveOccurred())
+		key, err = json.Marshal(map[string]string{
+			"type":         "service_account",
+			"project_id":   "dashboard",
+			"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+			"client_email": "ci@dashboard.iam.gserviceaccount.com",
+			"token_uri":    server.URL + "/token",
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should send the notification to the topic and devices", func() {
+		n, err := NewFCMNotifier(FCMOptions{
+			ServiceAccountKey: key, Topic: "/topics/pipelines", DeviceTokens: []string{"phone", "stale"}, APIURL: server.URL,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n.Probe(context.Background())).To(Succeed())
+		Expect(n.Notify(context.Background(), &Notification{
+			PipelineRun: "build-1", Namespace: "tenant", Status: StatusFailed,
+			Results: []Result{{Name: "IMAGE_URL", Value: "quay.io/tenant/app"}},
+		})).To(Succeed())
+		Expect(tokenCalls).To(Equal(1))
+		Expect(messages).To(HaveLen(3))
+		Expect(messages[0]).To(HaveKeyWithValue("topic", "pipelines"))
+		Expect(messages[0]).NotTo(HaveKey("token"))
+		Expect(messages[0]).To(HaveKeyWithValue("notification", map[string]any{
+			"title": "PipelineRun build-1 failed", "body": "IMAGE_URL: quay.io/tenant/app",
+		}))
+		Expect(messages[0]).To(HaveKeyWithValue("data", map[string]any{
+			"namespace": "tenant", "pipelineRun": "build-1", "status": StatusFailed,
+		}))
+		Expect(messages[0]).To(HaveKeyWithValue("android", map[string]any{"collapse_key": "tenant/build-1"}))
+		Expect(messages[1]).To(HaveKeyWithValue("token", "phone"))
+		Expect(messages[1]).NotTo(HaveKey("topic"))
+	})
+
+	It("should fail on errors other than unregistered devices", func() {
+		n, err := NewFCMNotifier(FCMOptions{
+			ServiceAccountKey: key, DeviceTokens: []string{"invalid"}, Template: "{{ .Status }}", APIURL: server.URL,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		err = n.Notify(context.Background(), &Notification{PipelineRun: "build-1", Namespace: "tenant", Status: StatusSucceeded})
+		Expect(err).To(MatchError(ContainSubstring("INVALID_ARGUMENT")))
+		Expect(messages[0]).To(HaveKeyWithValue("notification", map[string]any{
+			"title": "PipelineRun build-1 succeeded", "body": StatusSucceeded,
+		}))
+	})
+
+	It("should reject invalid options", func() {
+		_, err := NewFCMNotifier(FCMOptions{ServiceAccountKey: []byte(`{"type": "authorized_user"}`), Topic: "pipelines"})
+		Expect(err).To(HaveOccurred())
+		_, err = NewFCMNotifier(FCMOptions{ServiceAccountKey: key})
+		Expect(err).To(MatchError(ContainSubstring("topic")))
+	})
+})
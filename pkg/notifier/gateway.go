@@ -0,0 +1,82 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// GatewayDestinationHeader holds the original URL of the requests relayed through an egress gateway
+const GatewayDestinationHeader = "X-Notification-Destination"
+
+// EgressTransport sends the HTTP requests of all notifiers, reports and signatures whose HTTP client
+// is not set in their options. It is nil, i.e. http.DefaultTransport, unless it is set to a
+// GatewayTransport before the controller starts, for clusters where network policies only permit
+// egress through a single gateway.
+var EgressTransport http.RoundTripper
+
+// NewHTTPClient returns a client with the timeout sending its requests with EgressTransport
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: EgressTransport}
+}
+
+// GatewayTransport relays every request to a forwarding gateway, with its original URL in
+// GatewayDestinationHeader. The gateway is responsible for sending it on to the destination.
+type GatewayTransport struct {
+	url  *url.URL
+	base http.RoundTripper
+}
+
+// NewGatewayTransport creates a GatewayTransport relaying requests to the gateway URL with the base
+// transport, which defaults to http.DefaultTransport
+// Return error if the gateway URL is not an absolute http or https URL
+func NewGatewayTransport(gatewayURL string, base http.RoundTripper) (*GatewayTransport, error) {
+	parsed, err := url.Parse(gatewayURL)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid gateway URL %s: %w", gatewayURL, err)
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("Gateway URL %s must be an absolute http or https URL", gatewayURL)
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &GatewayTransport{url: parsed, base: base}, nil
+}
+
+// RoundTrip sends the request to the gateway, with its original URL in GatewayDestinationHeader.
+// The gateway is dialed instead of the destination, so the host of the destination is checked against
+// the EgressAllowlist first.
+func (t *GatewayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if EgressAllowlist != nil {
+		if err := EgressAllowlist.Check(req.Context(), req.URL.Hostname()); err != nil {
+			if req.Body != nil {
+				_ = req.Body.Close()
+			}
+			return nil, err
+		}
+	}
+	relayed := req.Clone(req.Context())
+	relayed.Header.Set(GatewayDestinationHeader, req.URL.String())
+	gateway := *t.url
+	relayed.URL = &gateway
+	relayed.Host = gateway.Host
+	return t.base.RoundTrip(relayed)
+}
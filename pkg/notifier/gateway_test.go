@@ -0,0 +1,53 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GatewayTransport", func() {
+	It("should relay requests to the gateway with their destination", func() {
+		var destination, path string
+		gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			destination = req.Header.Get(GatewayDestinationHeader)
+			path = req.URL.Path
+		}))
+		defer gateway.Close()
+		transport, err := NewGatewayTransport(gateway.URL+"/relay", nil)
+		Expect(err).NotTo(HaveOccurred())
+		previous := EgressTransport
+		DeferCleanup(func() { EgressTransport = previous })
+		EgressTransport = transport
+
+		webhook, err := NewWebhookNotifier(WebhookOptions{URL: "https://hooks.example.com/builds?team=a"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(webhook.Notify(context.Background(), &Notification{PipelineRun: "build"})).To(Succeed())
+		Expect(destination).To(Equal("https://hooks.example.com/builds?team=a"))
+		Expect(path).To(Equal("/relay"))
+	})
+
+	It("should reject relative gateway URLs", func() {
+		_, err := NewGatewayTransport("gateway:8080", nil)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,206 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	notificationv1 "github.com/konflux-ci/notification-service/pkg/proto/notification/v1"
+)
+
+const (
+	DefaultGRPCTimeout        = 10 * time.Second
+	DefaultGRPCMaxAttempts    = 3
+	DefaultGRPCInitialBackoff = 100 * time.Millisecond
+	DefaultGRPCMaxBackoff     = 2 * time.Second
+)
+
+// GRPCOptions configures a GRPCNotifier
+type GRPCOptions struct {
+	// Address is the target of the receiver, e.g. dns:///receiver.example.svc:9090
+	Address string
+	// Insecure disables transport security
+	Insecure bool
+	// CAFile is a PEM file with the CA certificates used to verify the receiver.
+	// If empty, the system pool is used.
+	CAFile string
+	// CertFile and KeyFile are an optional client certificate for mutual TLS
+	CertFile string
+	KeyFile  string
+	// ServerName overrides the name used to verify the receiver certificate
+	ServerName string
+	// Timeout is the deadline of a single Notify call, including its retries
+	Timeout time.Duration
+	// MaxAttempts is the maximum number of attempts of a call, including the original one.
+	// gRPC caps this value at 5.
+	MaxAttempts int
+	// InitialBackoff and MaxBackoff bound the delay between attempts
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// DialOptions are appended to the options used to create the client connection
+	DialOptions []grpc.DialOption
+}
+
+// GRPCNotifier sends notifications to a receiver implementing the
+// notification.v1.NotificationReceiver service
+type GRPCNotifier struct {
+	conn    *grpc.ClientConn
+	client  notificationv1.NotificationReceiverClient
+	timeout time.Duration
+}
+
+// NewGRPCNotifier creates a GRPCNotifier from the given options.
+// The connection is established lazily on the first call.
+func NewGRPCNotifier(opts GRPCOptions) (*GRPCNotifier, error) {
+	if opts.Address == "" {
+		return nil, errors.New("gRPC address must be set")
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultGRPCTimeout
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultGRPCMaxAttempts
+	}
+	if opts.InitialBackoff <= 0 {
+		opts.InitialBackoff = DefaultGRPCInitialBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultGRPCMaxBackoff
+	}
+
+	creds, err := grpcTransportCredentials(opts)
+	if err != nil {
+		return nil, err
+	}
+	dialOptions := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	// gRPC rejects retry policies with less than two attempts
+	if opts.MaxAttempts > 1 {
+		dialOptions = append(dialOptions, grpc.WithDefaultServiceConfig(grpcServiceConfig(opts)))
+	}
+	dialOptions = append(dialOptions, opts.DialOptions...)
+
+	conn, err := grpc.NewClient(opts.Address, dialOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create gRPC client for %s: %w", opts.Address, err)
+	}
+	return &GRPCNotifier{
+		conn:    conn,
+		client:  notificationv1.NewNotificationReceiverClient(conn),
+		timeout: opts.Timeout,
+	}, nil
+}
+
+// Notify sends the notification to the receiver.
+// Transient failures are retried according to the configured retry policy.
+func (g *GRPCNotifier) Notify(ctx context.Context, notification *Notification) error {
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+	_, err := g.client.Notify(ctx, &notificationv1.NotifyRequest{Notification: notification.ToProto()})
+	if err != nil {
+		return fmt.Errorf("Failed to send gRPC notification for pipelinerun %s: %w", notification.PipelineRun, err)
+	}
+	return nil
+}
+
+// Close closes the underlying client connection
+func (g *GRPCNotifier) Close() error {
+	return g.conn.Close()
+}
+
+// ToProto converts the notification to its protobuf representation
+func (n *Notification) ToProto() *notificationv1.Notification {
+	results := make([]*notificationv1.Result, 0, len(n.Results))
+	for _, result := range n.Results {
+		results = append(results, &notificationv1.Result{Name: result.Name, Value: result.String()})
+	}
+	message := &notificationv1.Notification{
+		PipelineRun:     n.PipelineRun,
+		Namespace:       n.Namespace,
+		Results:         results,
+		Status:          n.Status,
+		Event:           n.Event,
+		Subtype:         n.Subtype,
+		DurationSeconds: n.DurationSeconds,
+		Retries:         int32(n.Retries),
+		FailureStreak:   int32(n.FailureStreak),
+		Flaky:           n.Flaky,
+	}
+	if n.StartTime != nil {
+		message.StartTime = timestamppb.New(*n.StartTime)
+	}
+	if n.CompletionTime != nil {
+		message.CompletionTime = timestamppb.New(*n.CompletionTime)
+	}
+	return message
+}
+
+func grpcTransportCredentials(opts GRPCOptions) (credentials.TransportCredentials, error) {
+	if opts.Insecure {
+		return insecure.NewCredentials(), nil
+	}
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: opts.ServerName,
+	}
+	if opts.CAFile != "" {
+		ca, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read gRPC CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("No certificates found in gRPC CA file %s", opts.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load gRPC client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+// grpcServiceConfig returns a service config enabling retries of transient failures
+func grpcServiceConfig(opts GRPCOptions) string {
+	return fmt.Sprintf(`{
+  "methodConfig": [{
+    "name": [{"service": "%s"}],
+    "retryPolicy": {
+      "maxAttempts": %d,
+      "initialBackoff": "%.3fs",
+      "maxBackoff": "%.3fs",
+      "backoffMultiplier": 2,
+      "retryableStatusCodes": ["UNAVAILABLE", "RESOURCE_EXHAUSTED"]
+    }
+  }]
+}`, notificationv1.NotificationReceiver_ServiceDesc.ServiceName, opts.MaxAttempts,
+		opts.InitialBackoff.Seconds(), opts.MaxBackoff.Seconds())
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	notificationv1 "github.com/konflux-ci/notification-service/pkg/proto/notification/v1"
+)
+
+type fakeReceiver struct {
+	notificationv1.UnimplementedNotificationReceiverServer
+	mu       sync.Mutex
+	received []*notificationv1.Notification
+	failures int
+	delay    time.Duration
+}
+
+func (f *fakeReceiver) Notify(ctx context.Context, req *notificationv1.NotifyRequest) (*notificationv1.NotifyResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failures > 0 {
+		f.failures--
+		return nil, status.Error(codes.Unavailable, "try again")
+	}
+	if f.delay > 0 {
+		select {
+		case <-time.After(f.delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	f.received = append(f.received, req.GetNotification())
+	return &notificationv1.NotifyResponse{}, nil
+}
+
+var _ = Describe("GRPCNotifier", func() {
+	var (
+		receiver *fakeReceiver
+		server   *grpc.Server
+		listener *bufconn.Listener
+	)
+
+	newNotifier := func(opts GRPCOptions) *GRPCNotifier {
+		opts.Address = "passthrough:///bufnet"
+		opts.Insecure = true
+		opts.InitialBackoff = time.Millisecond
+		opts.MaxBackoff = time.Millisecond
+		opts.DialOptions = []grpc.DialOption{
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+				return listener.DialContext(ctx)
+			}),
+		}
+		n, err := NewGRPCNotifier(opts)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(n.Close)
+		return n
+	}
+
+	notification := &Notification{
+		PipelineRun: "build-1",
+		Namespace:   "tenant",
+		Results:     []Result{{Name: "IMAGE_URL", Value: "quay.io/test/image"}},
+	}
+
+	BeforeEach(func() {
+		receiver = &fakeReceiver{}
+		listener = bufconn.Listen(1024 * 1024)
+		server = grpc.NewServer()
+		notificationv1.RegisterNotificationReceiverServer(server, receiver)
+		go func() {
+			defer GinkgoRecover()
+			Expect(server.Serve(listener)).To(Succeed())
+		}()
+		DeferCleanup(server.Stop)
+	})
+
+	It("should deliver the notification", func() {
+		n := newNotifier(GRPCOptions{})
+		Expect(n.Notify(context.Background(), notification)).To(Succeed())
+		Expect(receiver.received).To(HaveLen(1))
+		Expect(receiver.received[0].GetPipelineRun()).To(Equal("build-1"))
+		Expect(receiver.received[0].GetNamespace()).To(Equal("tenant"))
+		Expect(receiver.received[0].GetResults()).To(HaveLen(1))
+		Expect(receiver.received[0].GetResults()[0].GetValue()).To(Equal("quay.io/test/image"))
+	})
+
+	It("should deliver the status, event and timing of the pipelinerun", func() {
+		n := newNotifier(GRPCOptions{})
+		startTime := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+		completionTime := startTime.Add(90 * time.Second)
+		Expect(n.Notify(context.Background(), &Notification{
+			PipelineRun:     "build-2",
+			Namespace:       "tenant",
+			Status:          StatusSucceeded,
+			Event:           EventSucceeded,
+			Subtype:         EventSucceededWithRetries,
+			StartTime:       &startTime,
+			CompletionTime:  &completionTime,
+			DurationSeconds: 90,
+			Retries:         2,
+		})).To(Succeed())
+		Expect(receiver.received).To(HaveLen(1))
+		received := receiver.received[0]
+		Expect(received.GetStatus()).To(Equal(StatusSucceeded))
+		Expect(received.GetEvent()).To(Equal(EventSucceeded))
+		Expect(received.GetSubtype()).To(Equal(EventSucceededWithRetries))
+		Expect(received.GetStartTime().AsTime()).To(Equal(startTime))
+		Expect(received.GetCompletionTime().AsTime()).To(Equal(completionTime))
+		Expect(received.GetDurationSeconds()).To(Equal(90.0))
+		Expect(received.GetRetries()).To(BeEquivalentTo(2))
+	})
+
+	It("should retry transient failures", func() {
+		receiver.failures = 2
+		n := newNotifier(GRPCOptions{MaxAttempts: 3})
+		Expect(n.Notify(context.Background(), notification)).To(Succeed())
+		Expect(receiver.received).To(HaveLen(1))
+	})
+
+	It("should fail when the retries are exhausted", func() {
+		receiver.failures = 2
+		n := newNotifier(GRPCOptions{MaxAttempts: 2})
+		err := n.Notify(context.Background(), notification)
+		Expect(status.Code(err)).To(Equal(codes.Unavailable))
+	})
+
+	It("should apply the call deadline", func() {
+		receiver.delay = time.Second
+		n := newNotifier(GRPCOptions{Timeout: 50 * time.Millisecond})
+		err := n.Notify(context.Background(), notification)
+		Expect(status.Code(err)).To(Equal(codes.DeadlineExceeded))
+	})
+})
+
+var _ = Describe("NewGRPCNotifier", func() {
+	It("should require an address", func() {
+		_, err := NewGRPCNotifier(GRPCOptions{})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should fail on a missing CA file", func() {
+		_, err := NewGRPCNotifier(GRPCOptions{Address: "localhost:9090", CAFile: "/does/not/exist"})
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,293 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// DefaultIRCNick is the nick notifications are posted with by default
+const DefaultIRCNick = "konflux-ci"
+
+// maxIRCMessageBytes bounds the text of a PRIVMSG so the line fits in the 512 bytes limit of IRC
+const maxIRCMessageBytes = 400
+
+// IRCOptions configures an IRCNotifier
+type IRCOptions struct {
+	// Server is the host:port of the IRC server
+	Server string
+	// Insecure connects without TLS
+	Insecure bool
+	// Nick is the nick messages are posted with, defaults to DefaultIRCNick
+	Nick string
+	// Channel is the channel messages are posted to, e.g. #builds
+	Channel string
+	// ChannelKey is the key of the channel, if it requires one to join
+	ChannelKey string
+	// SASLUsername and SASLPassword authenticate with SASL PLAIN, if set
+	SASLUsername string
+	SASLPassword string
+	// Template is an optional Go template rendering the message, newlines are replaced by spaces
+	Template string
+	// Locale is the language of the built-in messages, see Locales. Defaults to DefaultLocale.
+	Locale string
+	// Timeout is the deadline of a whole connection, from dialing to quitting
+	Timeout time.Duration
+	// TLSConfig is used for TLS connections, defaults to verifying the server name
+	TLSConfig *tls.Config
+	// Guard blocks connections to the server at the addresses it blocks, if set
+	Guard *AddressGuard
+}
+
+// IRCNotifier posts one line summaries of notifications to an IRC channel.
+// It connects for every message, which suits the low rate of CI notifications and needs no
+// connection to be kept alive.
+type IRCNotifier struct {
+	opts     IRCOptions
+	template *template.Template
+	locale   *Locale
+}
+
+// NewIRCNotifier creates an IRCNotifier from the given options
+func NewIRCNotifier(opts IRCOptions) (*IRCNotifier, error) {
+	if _, _, err := net.SplitHostPort(opts.Server); err != nil {
+		return nil, fmt.Errorf("Invalid IRC server %s: %w", opts.Server, err)
+	}
+	if !strings.HasPrefix(opts.Channel, "#") && !strings.HasPrefix(opts.Channel, "&") {
+		return nil, fmt.Errorf("Invalid IRC channel %s", opts.Channel)
+	}
+	if opts.SASLUsername != "" && opts.Insecure {
+		return nil, errors.New("SASL credentials cannot be sent without TLS")
+	}
+	if opts.Nick == "" {
+		opts.Nick = DefaultIRCNick
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultWebhookTimeout
+	}
+	locale, err := LookupLocale(opts.Locale)
+	if err != nil {
+		return nil, err
+	}
+	n := &IRCNotifier{opts: opts, locale: locale}
+	if opts.Template != "" {
+		tmpl, err := NewTemplate("irc", opts.Template)
+		if err != nil {
+			return nil, err
+		}
+		n.template = tmpl
+	}
+	return n, nil
+}
+
+// Notify posts a one line summary of the notification to the channel
+func (n *IRCNotifier) Notify(ctx context.Context, notification *Notification) error {
+	text, err := n.text(notification)
+	if err != nil {
+		return err
+	}
+	err = n.session(ctx, func(conn *ircConn) error {
+		return conn.privmsg(n.opts.Channel, text)
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to post IRC message for pipelinerun %s: %w", notification.PipelineRun, err)
+	}
+	return nil
+}
+
+// Probe connects to the server and registers, which checks the SASL credentials
+func (n *IRCNotifier) Probe(ctx context.Context) error {
+	return n.session(ctx, func(*ircConn) error { return nil })
+}
+
+// NotifySummary posts a one line summary of the summary to the channel
+func (n *IRCNotifier) NotifySummary(ctx context.Context, summary *Summary) error {
+	text := n.locale.summary(summary, summary.Namespace)
+	if len(summary.TopFailures) > 0 {
+		failures := make([]string, 0, len(summary.TopFailures))
+		for _, stats := range summary.TopFailures {
+			failures = append(failures, fmt.Sprintf("%s (%d/%d)", stats.Pipeline, stats.Failures, stats.Runs))
+		}
+		text += ". " + n.locale.TopFailures + ": " + strings.Join(failures, ", ")
+	}
+	err := n.session(ctx, func(conn *ircConn) error {
+		return conn.privmsg(n.opts.Channel, text)
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to post IRC summary for namespace %s: %w", summary.Namespace, err)
+	}
+	return nil
+}
+
+func (n *IRCNotifier) text(notification *Notification) (string, error) {
+	if n.template != nil {
+		text, err := Render(n.template, notification)
+		return strings.Join(strings.Fields(string(text)), " "), err
+	}
+	text := n.locale.status(notification, notification.Namespace+"/"+notification.PipelineRun)
+	if notification.PayloadURL != "" {
+		text += " " + notification.PayloadURL
+	}
+	return text, nil
+}
+
+// session connects to the server, registers, joins the channel, runs send and quits
+func (n *IRCNotifier) session(ctx context.Context, send func(*ircConn) error) error {
+	ctx, cancel := context.WithTimeout(ctx, n.opts.Timeout)
+	defer cancel()
+	var dialer net.Dialer
+	raw, err := dialEgress(ctx, &dialer, n.opts.Guard, "tcp", n.opts.Server)
+	if err != nil {
+		return err
+	}
+	defer raw.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = raw.SetDeadline(deadline)
+	}
+	if !n.opts.Insecure {
+		config := n.opts.TLSConfig
+		if config == nil {
+			host, _, _ := net.SplitHostPort(n.opts.Server)
+			config = &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+		}
+		tlsConn := tls.Client(raw, config)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return err
+		}
+		raw = tlsConn
+	}
+	conn := &ircConn{conn: raw, reader: bufio.NewReader(raw)}
+	if err := n.register(conn); err != nil {
+		return err
+	}
+	join := "JOIN " + n.opts.Channel
+	if n.opts.ChannelKey != "" {
+		join += " " + n.opts.ChannelKey
+	}
+	if err := conn.send(join); err != nil {
+		return err
+	}
+	_, err = conn.expect("JOIN", "403", "405", "471", "473", "474", "475")
+	if err != nil {
+		return err
+	}
+	if err := send(conn); err != nil {
+		return err
+	}
+	return conn.send("QUIT :done")
+}
+
+// register negotiates SASL PLAIN authentication, if enabled, and waits for the welcome of the server
+func (n *IRCNotifier) register(conn *ircConn) error {
+	if n.opts.SASLUsername != "" {
+		if err := conn.send("CAP REQ :sasl"); err != nil {
+			return err
+		}
+	}
+	if err := conn.send("NICK " + n.opts.Nick); err != nil {
+		return err
+	}
+	if err := conn.send("USER " + n.opts.Nick + " 0 * :" + n.opts.Nick); err != nil {
+		return err
+	}
+	if n.opts.SASLUsername != "" {
+		reply, err := conn.expect("CAP")
+		if err != nil {
+			return err
+		}
+		if len(reply) > 2 && reply[2] == "NAK" {
+			return errors.New("IRC server does not support SASL")
+		}
+		if err := conn.send("AUTHENTICATE PLAIN"); err != nil {
+			return err
+		}
+		if _, err := conn.expect("AUTHENTICATE"); err != nil {
+			return err
+		}
+		credentials := n.opts.SASLUsername + "\x00" + n.opts.SASLUsername + "\x00" + n.opts.SASLPassword
+		if err := conn.send("AUTHENTICATE " + base64.StdEncoding.EncodeToString([]byte(credentials))); err != nil {
+			return err
+		}
+		if _, err := conn.expect("903", "902", "904", "905", "906"); err != nil {
+			return err
+		}
+		if err := conn.send("CAP END"); err != nil {
+			return err
+		}
+	}
+	_, err := conn.expect("001", "431", "432", "433", "465")
+	return err
+}
+
+// ircConn is a connection to an IRC server
+type ircConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func (c *ircConn) send(line string) error {
+	_, err := c.conn.Write([]byte(line + "\r\n"))
+	return err
+}
+
+func (c *ircConn) privmsg(target string, text string) error {
+	text = strings.Join(strings.Fields(text), " ")
+	if len(text) > maxIRCMessageBytes {
+		text = strings.ToValidUTF8(text[:maxIRCMessageBytes-3], "") + "..."
+	}
+	return c.send("PRIVMSG " + target + " :" + text)
+}
+
+// expect reads messages until one with the first command, which is returned, answering pings on the way.
+// The other commands are replies reporting failures.
+func (c *ircConn) expect(success string, failures ...string) ([]string, error) {
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read from IRC server while waiting for %s: %w", success, err)
+		}
+		fields := strings.Fields(strings.TrimRight(line, "\r\n"))
+		if len(fields) > 0 && strings.HasPrefix(fields[0], ":") {
+			fields = fields[1:]
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		switch command := fields[0]; {
+		case command == "PING":
+			if err := c.send("PONG " + strings.Join(fields[1:], " ")); err != nil {
+				return nil, err
+			}
+		case command == "ERROR":
+			return nil, fmt.Errorf("IRC server closed the connection: %s", strings.TrimPrefix(strings.Join(fields[1:], " "), ":"))
+		case command == success:
+			return fields, nil
+		case slices.Contains(failures, command):
+			return nil, fmt.Errorf("IRC server replied %s", strings.TrimPrefix(strings.Join(fields, " "), ":"))
+		}
+	}
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// fakeIRCServer accepts IRC connections and records the lines clients send
+type fakeIRCServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	lines    []string
+	// channelKey is the key required to join channels, if set
+	channelKey string
+}
+
+func (s *fakeIRCServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeIRCServer) handle(conn net.Conn) {
+	defer conn.Close()
+	reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+	reader := bufio.NewReader(conn)
+	capNegotiation := false
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		s.mu.Lock()
+		s.lines = append(s.lines, line)
+		s.mu.Unlock()
+		fields := strings.Fields(line)
+		switch fields[0] {
+		case "CAP":
+			if fields[1] == "REQ" {
+				capNegotiation = true
+				reply(":irc.example.com CAP * ACK :sasl")
+			} else {
+				reply(":irc.example.com 001 konflux-ci :Welcome")
+			}
+		case "USER":
+			reply("PING :irc.example.com")
+			if !capNegotiation {
+				reply(":irc.example.com 001 konflux-ci :Welcome")
+			}
+		case "AUTHENTICATE":
+			if fields[1] == "PLAIN" {
+				reply("AUTHENTICATE +")
+			} else if decoded, _ := base64.StdEncoding.DecodeString(fields[1]); string(decoded) == "bot\x00bot\x00secret" {
+				reply(":irc.example.com 903 konflux-ci :SASL authentication successful")
+			} else {
+				reply(":irc.example.com 904 konflux-ci :SASL authentication failed")
+			}
+		case "JOIN":
+			if s.channelKey != "" && (len(fields) < 3 || fields[2] != s.channelKey) {
+				reply(":irc.example.com 475 konflux-ci " + fields[1] + " :Cannot join channel (+k)")
+				continue
+			}
+			reply(":konflux-ci!bot@example.com JOIN " + fields[1])
+		case "QUIT":
+			return
+		}
+	}
+}
+
+// received returns the lines received by the server, without the registration and pongs
+func (s *fakeIRCServer) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var received []string
+	for _, line := range s.lines {
+		if strings.HasPrefix(line, "JOIN") || strings.HasPrefix(line, "PRIVMSG") || strings.HasPrefix(line, "AUTHENTICATE ") {
+			received = append(received, line)
+		}
+	}
+	return received
+}
+
+var _ = Describe("IRCNotifier", func() {
+	var server *fakeIRCServer
+
+	notification := &Notification{PipelineRun: "build-1", Namespace: "tenant", Status: StatusFailed}
+
+	BeforeEach(func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		server = &fakeIRCServer{listener: listener}
+		go server.serve()
+		DeferCleanup(listener.Close)
+	})
+
+	It("should post a one line summary to the channel", func() {
+		n, err := NewIRCNotifier(IRCOptions{Server: server.listener.Addr().String(), Insecure: true, Channel: "#builds"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n.Notify(context.Background(), notification)).To(Succeed())
+		Eventually(server.received).Should(Equal([]string{
+			"JOIN #builds",
+			"PRIVMSG #builds :PipelineRun tenant/build-1 failed",
+		}))
+	})
+
+	It("should join channels with their key", func() {
+		server.channelKey = "letmein"
+		opts := IRCOptions{Server: server.listener.Addr().String(), Insecure: true, Channel: "#builds"}
+		n, err := NewIRCNotifier(opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n.Notify(context.Background(), notification)).To(MatchError(ContainSubstring("475")))
+
+		opts.ChannelKey = "letmein"
+		opts.Template = "{{ .PipelineRun }}\nfailed"
+		n, err = NewIRCNotifier(opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n.Notify(context.Background(), notification)).To(Succeed())
+		Eventually(server.received).Should(ContainElement("PRIVMSG #builds :build-1 failed"))
+	})
+
+	It("should authenticate with SASL over TLS", func() {
+		certified := httptest.NewUnstartedServer(http.NotFoundHandler())
+		certified.StartTLS()
+		certificates := certified.TLS.Certificates
+		rootCAs := certified.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+		certified.Close()
+		listener := tls.NewListener(server.listener, &tls.Config{Certificates: certificates})
+
+		tlsServer := &fakeIRCServer{listener: listener}
+		go tlsServer.serve()
+		opts := IRCOptions{
+			Server:       server.listener.Addr().String(),
+			Channel:      "#builds",
+			SASLUsername: "bot",
+			SASLPassword: "secret",
+			TLSConfig:    &tls.Config{RootCAs: rootCAs, ServerName: "example.com"},
+		}
+		n, err := NewIRCNotifier(opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n.Probe(context.Background())).To(Succeed())
+		Expect(n.Notify(context.Background(), notification)).To(Succeed())
+		Eventually(tlsServer.received).Should(ContainElement("PRIVMSG #builds :PipelineRun tenant/build-1 failed"))
+
+		opts.SASLPassword = "wrong"
+		n, err = NewIRCNotifier(opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n.Probe(context.Background())).To(MatchError(ContainSubstring("904")))
+	})
+
+	It("should reject invalid options", func() {
+		_, err := NewIRCNotifier(IRCOptions{Server: "irc.example.com", Channel: "#builds"})
+		Expect(err).To(HaveOccurred())
+		_, err = NewIRCNotifier(IRCOptions{Server: "irc.example.com:6697", Channel: "builds"})
+		Expect(err).To(HaveOccurred())
+		_, err = NewIRCNotifier(IRCOptions{Server: "irc.example.com:6697", Channel: "#builds", Insecure: true, SASLUsername: "bot"})
+		Expect(err).To(MatchError(ContainSubstring("TLS")))
+	})
+})
@@ -0,0 +1,127 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const DefaultKafkaTimeout = 10 * time.Second
+
+// KafkaOptions configures a KafkaNotifier
+type KafkaOptions struct {
+	// Brokers are the addresses of the Kafka bootstrap brokers
+	Brokers []string
+	// Topic is the topic notifications are produced to
+	Topic string
+	// Encoding is the encoding of message values: json (default), avro or protobuf.
+	// The avro and protobuf encodings require a schema registry.
+	Encoding string
+	// SchemaRegistry is used to register the notification schema
+	SchemaRegistry *SchemaRegistryClient
+	// Timeout is the deadline for producing a single notification
+	Timeout time.Duration
+}
+
+// messageWriter produces messages to Kafka
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// KafkaNotifier produces notifications to a Kafka topic.
+// Messages are keyed by the namespace and name of the PipelineRun.
+type KafkaNotifier struct {
+	writer  messageWriter
+	encoder messageEncoder
+	timeout time.Duration
+}
+
+// NewKafkaNotifier creates a KafkaNotifier from the given options
+func NewKafkaNotifier(opts KafkaOptions) (*KafkaNotifier, error) {
+	if len(opts.Brokers) == 0 {
+		return nil, errors.New("at least one Kafka broker must be set")
+	}
+	if opts.Topic == "" {
+		return nil, errors.New("Kafka topic must be set")
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultKafkaTimeout
+	}
+	encoder, err := newKafkaEncoder(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &KafkaNotifier{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(opts.Brokers...),
+			Topic:        opts.Topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+			WriteTimeout: opts.Timeout,
+		},
+		encoder: encoder,
+		timeout: opts.Timeout,
+	}, nil
+}
+
+func newKafkaEncoder(opts KafkaOptions) (messageEncoder, error) {
+	// subjects follow the default TopicNameStrategy of Confluent serializers
+	subject := opts.Topic + "-value"
+	switch opts.Encoding {
+	case "", KafkaEncodingJSON:
+		return jsonEncoder{}, nil
+	case KafkaEncodingAvro, KafkaEncodingProtobuf:
+		if opts.SchemaRegistry == nil || opts.SchemaRegistry.URL == "" {
+			return nil, fmt.Errorf("Kafka encoding %s requires a schema registry", opts.Encoding)
+		}
+		if opts.Encoding == KafkaEncodingAvro {
+			return newAvroEncoder(opts.SchemaRegistry, subject), nil
+		}
+		return newProtobufEncoder(opts.SchemaRegistry, subject), nil
+	default:
+		return nil, fmt.Errorf("Unsupported Kafka encoding %s", opts.Encoding)
+	}
+}
+
+// Notify produces the notification to the configured topic
+func (k *KafkaNotifier) Notify(ctx context.Context, notification *Notification) error {
+	ctx, cancel := context.WithTimeout(ctx, k.timeout)
+	defer cancel()
+	value, err := k.encoder.Encode(ctx, notification)
+	if err != nil {
+		return fmt.Errorf("Failed to encode Kafka notification for pipelinerun %s: %w", notification.PipelineRun, err)
+	}
+	err = k.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(notification.Namespace + "/" + notification.PipelineRun),
+		Value: value,
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to send Kafka notification for pipelinerun %s: %w", notification.PipelineRun, err)
+	}
+	return nil
+}
+
+// Close flushes pending messages and closes the writer
+func (k *KafkaNotifier) Close() error {
+	return k.writer.Close()
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	notificationv1 "github.com/konflux-ci/notification-service/pkg/proto/notification/v1"
+)
+
+// Encodings of Kafka message values
+const (
+	KafkaEncodingJSON     = "json"
+	KafkaEncodingAvro     = "avro"
+	KafkaEncodingProtobuf = "protobuf"
+)
+
+// NotificationAvroSchema is the Avro schema of notifications sent with the avro encoding
+const NotificationAvroSchema = `{
+  "type": "record",
+  "name": "Notification",
+  "namespace": "ci.konflux.notification.v1",
+  "fields": [
+    {"name": "pipelineRun", "type": "string"},
+    {"name": "namespace", "type": "string"},
+    {"name": "results", "type": {"type": "array", "items": {
+      "type": "record",
+      "name": "Result",
+      "fields": [
+        {"name": "name", "type": "string"},
+        {"name": "value", "type": "string"}
+      ]
+    }}},
+    {"name": "status", "type": "string", "default": ""},
+    {"name": "event", "type": "string", "default": ""},
+    {"name": "subtype", "type": "string", "default": ""},
+    {"name": "startTime", "type": ["null", {"type": "long", "logicalType": "timestamp-millis"}], "default": null},
+    {"name": "completionTime", "type": ["null", {"type": "long", "logicalType": "timestamp-millis"}], "default": null},
+    {"name": "durationSeconds", "type": "double", "default": 0},
+    {"name": "retries", "type": "int", "default": 0},
+    {"name": "failureStreak", "type": "int", "default": 0},
+    {"name": "flaky", "type": "boolean", "default": false}
+  ]
+}`
+
+// confluentMagicByte prefixes every message framed with the Confluent wire format
+const confluentMagicByte = 0
+
+// messageEncoder serializes notifications into Kafka message values
+type messageEncoder interface {
+	Encode(ctx context.Context, notification *Notification) ([]byte, error)
+}
+
+type jsonEncoder struct{}
+
+func (jsonEncoder) Encode(_ context.Context, notification *Notification) ([]byte, error) {
+	return json.Marshal(notification)
+}
+
+// schemaRegistryEncoder frames serialized notifications with the Confluent wire format:
+// a magic byte and the big-endian schema ID, followed by the serialized message.
+// The schema is registered on first use so the controller can start while the
+// registry is unavailable.
+type schemaRegistryEncoder struct {
+	registry   *SchemaRegistryClient
+	subject    string
+	schemaType string
+	schema     string
+	serialize  func(*Notification) ([]byte, error)
+
+	mu       sync.Mutex
+	schemaID int
+}
+
+func newAvroEncoder(registry *SchemaRegistryClient, subject string) *schemaRegistryEncoder {
+	return &schemaRegistryEncoder{
+		registry:   registry,
+		subject:    subject,
+		schemaType: SchemaTypeAvro,
+		schema:     NotificationAvroSchema,
+		serialize:  encodeAvroNotification,
+	}
+}
+
+func newProtobufEncoder(registry *SchemaRegistryClient, subject string) *schemaRegistryEncoder {
+	return &schemaRegistryEncoder{
+		registry:   registry,
+		subject:    subject,
+		schemaType: SchemaTypeProtobuf,
+		schema:     notificationv1.Schema,
+		serialize:  encodeProtobufNotification,
+	}
+}
+
+func (e *schemaRegistryEncoder) Encode(ctx context.Context, notification *Notification) ([]byte, error) {
+	id, err := e.registeredSchemaID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	serialized, err := e.serialize(notification)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to serialize notification for pipelinerun %s: %w", notification.PipelineRun, err)
+	}
+	value := make([]byte, 5, 5+len(serialized))
+	value[0] = confluentMagicByte
+	binary.BigEndian.PutUint32(value[1:], uint32(id))
+	return append(value, serialized...), nil
+}
+
+func (e *schemaRegistryEncoder) registeredSchemaID(ctx context.Context) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.schemaID != 0 {
+		return e.schemaID, nil
+	}
+	id, err := e.registry.Register(ctx, e.subject, e.schemaType, e.schema)
+	if err != nil {
+		return 0, err
+	}
+	e.schemaID = id
+	return id, nil
+}
+
+// encodeAvroNotification serializes the notification using the Avro binary encoding
+// of NotificationAvroSchema
+func encodeAvroNotification(notification *Notification) ([]byte, error) {
+	buf := appendAvroString(nil, notification.PipelineRun)
+	buf = appendAvroString(buf, notification.Namespace)
+	if len(notification.Results) > 0 {
+		buf = binary.AppendVarint(buf, int64(len(notification.Results)))
+		for _, result := range notification.Results {
+			buf = appendAvroString(buf, result.Name)
+			buf = appendAvroString(buf, result.String())
+		}
+	}
+	// arrays are terminated by an empty block
+	buf = binary.AppendVarint(buf, 0)
+	buf = appendAvroString(buf, notification.Status)
+	buf = appendAvroString(buf, notification.Event)
+	buf = appendAvroString(buf, notification.Subtype)
+	buf = appendAvroTime(buf, notification.StartTime)
+	buf = appendAvroTime(buf, notification.CompletionTime)
+	buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(notification.DurationSeconds))
+	buf = binary.AppendVarint(buf, int64(notification.Retries))
+	buf = binary.AppendVarint(buf, int64(notification.FailureStreak))
+	if notification.Flaky {
+		return append(buf, 1), nil
+	}
+	return append(buf, 0), nil
+}
+
+func appendAvroString(buf []byte, value string) []byte {
+	buf = binary.AppendVarint(buf, int64(len(value)))
+	return append(buf, value...)
+}
+
+// appendAvroTime appends an optional timestamp-millis, as the index of its branch in the union with null
+// followed by the value
+func appendAvroTime(buf []byte, value *time.Time) []byte {
+	if value == nil {
+		return binary.AppendVarint(buf, 0)
+	}
+	buf = binary.AppendVarint(buf, 1)
+	return binary.AppendVarint(buf, value.UnixMilli())
+}
+
+// encodeProtobufNotification serializes the notification as a protobuf message
+// preceded by the message indexes identifying Notification within the registered schema
+func encodeProtobufNotification(notification *Notification) ([]byte, error) {
+	message := notification.ToProto()
+	var buf []byte
+	// the first message of a schema is encoded as a single zero instead of an index array
+	if index := message.ProtoReflect().Descriptor().Index(); index == 0 {
+		buf = binary.AppendVarint(buf, 0)
+	} else {
+		buf = binary.AppendVarint(buf, 1)
+		buf = binary.AppendVarint(buf, int64(index))
+	}
+	return proto.MarshalOptions{Deterministic: true}.MarshalAppend(buf, message)
+}
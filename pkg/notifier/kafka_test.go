@@ -0,0 +1,229 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/segmentio/kafka-go"
+	"google.golang.org/protobuf/proto"
+
+	notificationv1 "github.com/konflux-ci/notification-service/pkg/proto/notification/v1"
+)
+
+type fakeWriter struct {
+	messages []kafka.Message
+}
+
+func (f *fakeWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	f.messages = append(f.messages, msgs...)
+	return nil
+}
+
+func (f *fakeWriter) Close() error {
+	return nil
+}
+
+var _ = Describe("KafkaNotifier", func() {
+	var (
+		registry      *httptest.Server
+		registrations []registerSchemaRequest
+		subjects      []string
+		writer        *fakeWriter
+	)
+
+	notification := &Notification{
+		PipelineRun: "build-1",
+		Namespace:   "tenant",
+		Results:     []Result{{Name: "IMAGE_URL", Value: "quay.io/test/image"}},
+	}
+
+	newNotifier := func(encoding string) *KafkaNotifier {
+		n, err := NewKafkaNotifier(KafkaOptions{
+			Brokers:        []string{"localhost:9092"},
+			Topic:          "pipelines",
+			Encoding:       encoding,
+			SchemaRegistry: &SchemaRegistryClient{URL: registry.URL},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		n.writer = writer
+		return n
+	}
+
+	BeforeEach(func() {
+		registrations = nil
+		subjects = nil
+		writer = &fakeWriter{}
+		registry = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req := registerSchemaRequest{}
+			Expect(json.NewDecoder(r.Body).Decode(&req)).To(Succeed())
+			registrations = append(registrations, req)
+			subjects = append(subjects, r.URL.Path)
+			if req.Schema == "invalid" {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			_, _ = w.Write([]byte(`{"id": 42}`))
+		}))
+		DeferCleanup(registry.Close)
+	})
+
+	It("should key messages by the pipelinerun and use JSON by default", func() {
+		n := newNotifier("")
+		Expect(n.Notify(context.Background(), notification)).To(Succeed())
+		Expect(writer.messages).To(HaveLen(1))
+		Expect(string(writer.messages[0].Key)).To(Equal("tenant/build-1"))
+		Expect(writer.messages[0].Value).To(MatchJSON(
+			`{"pipelineRun":"build-1","namespace":"tenant","results":[{"name":"IMAGE_URL","value":"quay.io/test/image"}]}`))
+		Expect(registrations).To(BeEmpty())
+	})
+
+	It("should frame avro messages with the registered schema ID", func() {
+		n := newNotifier(KafkaEncodingAvro)
+		Expect(n.Notify(context.Background(), notification)).To(Succeed())
+		Expect(n.Notify(context.Background(), notification)).To(Succeed())
+
+		Expect(registrations).To(HaveLen(1))
+		Expect(subjects).To(ConsistOf("/subjects/pipelines-value/versions"))
+		Expect(registrations[0].SchemaType).To(Equal(SchemaTypeAvro))
+
+		value := writer.messages[0].Value
+		Expect(value[0]).To(BeEquivalentTo(0))
+		Expect(binary.BigEndian.Uint32(value[1:5])).To(BeEquivalentTo(42))
+		expected := []byte{14}
+		expected = append(expected, "build-1"...)
+		expected = append(expected, 12)
+		expected = append(expected, "tenant"...)
+		expected = append(expected, 2, 18)
+		expected = append(expected, "IMAGE_URL"...)
+		expected = append(expected, 36)
+		expected = append(expected, "quay.io/test/image"...)
+		// empty results terminator, status, event and subtype, null times, zero duration, retries, streak and flaky
+		expected = append(expected, 0, 0, 0, 0, 0, 0)
+		expected = append(expected, make([]byte, 8)...)
+		expected = append(expected, 0, 0, 0)
+		Expect(value[5:]).To(Equal(expected))
+	})
+
+	It("should encode the status, event and timing of failed pipelineruns in avro messages", func() {
+		n := newNotifier(KafkaEncodingAvro)
+		startTime := time.UnixMilli(1700000000000)
+		completionTime := startTime.Add(90 * time.Second)
+		Expect(n.Notify(context.Background(), &Notification{
+			PipelineRun:     "build-2",
+			Namespace:       "tenant",
+			Status:          StatusFailed,
+			Event:           EventFailed,
+			StartTime:       &startTime,
+			CompletionTime:  &completionTime,
+			DurationSeconds: 90,
+			Retries:         1,
+			FailureStreak:   3,
+			Flaky:           true,
+		})).To(Succeed())
+
+		value := writer.messages[0].Value
+		expected := []byte{14}
+		expected = append(expected, "build-2"...)
+		expected = append(expected, 12)
+		expected = append(expected, "tenant"...)
+		expected = append(expected, 0, 12)
+		expected = append(expected, "Failed"...)
+		expected = append(expected, 12)
+		expected = append(expected, "failed"...)
+		expected = append(expected, 0, 2)
+		expected = binary.AppendVarint(expected, startTime.UnixMilli())
+		expected = append(expected, 2)
+		expected = binary.AppendVarint(expected, completionTime.UnixMilli())
+		expected = binary.LittleEndian.AppendUint64(expected, math.Float64bits(90))
+		expected = append(expected, 2, 6, 1)
+		Expect(value[5:]).To(Equal(expected))
+	})
+
+	It("should frame protobuf messages with the schema ID and message index", func() {
+		n := newNotifier(KafkaEncodingProtobuf)
+		Expect(n.Notify(context.Background(), notification)).To(Succeed())
+
+		Expect(registrations).To(HaveLen(1))
+		Expect(registrations[0].SchemaType).To(Equal(SchemaTypeProtobuf))
+		Expect(registrations[0].Schema).To(ContainSubstring("message Notification"))
+
+		value := writer.messages[0].Value
+		Expect(binary.BigEndian.Uint32(value[1:5])).To(BeEquivalentTo(42))
+		Expect(value[5]).To(BeEquivalentTo(0))
+		decoded := &notificationv1.Notification{}
+		Expect(proto.Unmarshal(value[6:], decoded)).To(Succeed())
+		Expect(decoded.GetPipelineRun()).To(Equal("build-1"))
+		Expect(decoded.GetResults()[0].GetName()).To(Equal("IMAGE_URL"))
+	})
+
+	It("should encode the status, event and timing of failed pipelineruns in protobuf messages", func() {
+		n := newNotifier(KafkaEncodingProtobuf)
+		startTime := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+		Expect(n.Notify(context.Background(), &Notification{
+			PipelineRun:     "build-2",
+			Namespace:       "tenant",
+			Status:          StatusFailed,
+			Event:           EventFailed,
+			StartTime:       &startTime,
+			DurationSeconds: 90,
+			FailureStreak:   3,
+		})).To(Succeed())
+
+		decoded := &notificationv1.Notification{}
+		Expect(proto.Unmarshal(writer.messages[0].Value[6:], decoded)).To(Succeed())
+		Expect(decoded.GetStatus()).To(Equal(StatusFailed))
+		Expect(decoded.GetEvent()).To(Equal(EventFailed))
+		Expect(decoded.GetStartTime().AsTime()).To(Equal(startTime))
+		Expect(decoded.GetCompletionTime()).To(BeNil())
+		Expect(decoded.GetDurationSeconds()).To(Equal(90.0))
+		Expect(decoded.GetFailureStreak()).To(BeEquivalentTo(3))
+	})
+
+	It("should fail when the registry rejects the schema", func() {
+		n := newNotifier(KafkaEncodingAvro)
+		n.encoder.(*schemaRegistryEncoder).schema = "invalid"
+		Expect(n.Notify(context.Background(), notification)).NotTo(Succeed())
+		Expect(writer.messages).To(BeEmpty())
+	})
+
+	It("should require a schema registry for schema based encodings", func() {
+		_, err := NewKafkaNotifier(KafkaOptions{
+			Brokers:  []string{"localhost:9092"},
+			Topic:    "pipelines",
+			Encoding: KafkaEncodingProtobuf,
+		})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should reject unknown encodings", func() {
+		_, err := NewKafkaNotifier(KafkaOptions{
+			Brokers:  []string{"localhost:9092"},
+			Topic:    "pipelines",
+			Encoding: "xml",
+		})
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,198 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultLocale is the locale of the built-in messages unless a destination configures another one
+const DefaultLocale = "en"
+
+// Locale holds the built-in message strings of a language. Formats take the same arguments
+// in every locale and may use explicit argument indexes to reorder them.
+type Locale struct {
+	// Statuses describe a PipelineRun of each status, given its name
+	Statuses map[string]string
+	// PolicyOutcomes name the outcomes of policy checks
+	PolicyOutcomes map[string]string
+	// Policy describes the policy outcome, the number of violations and the number of warnings of a PipelineRun
+	Policy string
+	// FullResults is the text of the link to the full results of a PipelineRun
+	FullResults string
+	// Rerun is the label of the re-run button
+	Rerun string
+	// Summary describes the namespace, the first and last day, the number of runs and the success rate of a summary
+	Summary string
+	// Suppressed describes the maintenance window and the number of notifications it suppressed, and
+	// precedes the summary of the window
+	Suppressed string
+	// Slowest heads the slowest pipelines of a summary
+	Slowest string
+	// AverageDuration describes the average duration of a pipeline
+	AverageDuration string
+	// TopFailures heads the most failing pipelines of a summary
+	TopFailures string
+	// Failures describes the number of failures and runs of a pipeline
+	Failures string
+}
+
+// Locales are the supported locales, by language code
+var Locales = map[string]*Locale{
+	"en": {
+		Statuses: map[string]string{
+			StatusStarted:   "PipelineRun %s started",
+			StatusRunning:   "PipelineRun %s is still running",
+			StatusSucceeded: "PipelineRun %s succeeded",
+			StatusFailed:    "PipelineRun %s failed",
+		},
+		PolicyOutcomes:  map[string]string{PolicyPassed: "passed", PolicyWarning: "warning", PolicyFailed: "failed"},
+		Policy:          "Policy %s: %d violations, %d warnings",
+		FullResults:     "Full results",
+		Rerun:           "Re-run",
+		Summary:         "Pipelines of %s from %s to %s: %d runs, %.0f%% succeeded",
+		Suppressed:      "Maintenance window %s suppressed %d notifications. ",
+		Slowest:         "Slowest pipelines",
+		AverageDuration: "%s on average",
+		TopFailures:     "Top failures",
+		Failures:        "%d of %d runs failed",
+	},
+	"de": {
+		Statuses: map[string]string{
+			StatusStarted:   "PipelineRun %s gestartet",
+			StatusRunning:   "PipelineRun %s läuft noch",
+			StatusSucceeded: "PipelineRun %s erfolgreich",
+			StatusFailed:    "PipelineRun %s fehlgeschlagen",
+		},
+		PolicyOutcomes:  map[string]string{PolicyPassed: "bestanden", PolicyWarning: "Warnung", PolicyFailed: "nicht bestanden"},
+		Policy:          "Richtlinie %s: %d Verstöße, %d Warnungen",
+		FullResults:     "Alle Ergebnisse",
+		Rerun:           "Erneut ausführen",
+		Summary:         "Pipelines von %s vom %s bis %s: %d Läufe, %.0f%% erfolgreich",
+		Suppressed:      "Wartungsfenster %s hat %d Benachrichtigungen unterdrückt. ",
+		Slowest:         "Langsamste Pipelines",
+		AverageDuration: "%s im Durchschnitt",
+		TopFailures:     "Häufigste Fehlschläge",
+		Failures:        "%d von %d Läufen fehlgeschlagen",
+	},
+	"es": {
+		Statuses: map[string]string{
+			StatusStarted:   "PipelineRun %s iniciado",
+			StatusRunning:   "PipelineRun %s sigue en ejecución",
+			StatusSucceeded: "PipelineRun %s completado con éxito",
+			StatusFailed:    "PipelineRun %s falló",
+		},
+		PolicyOutcomes:  map[string]string{PolicyPassed: "superada", PolicyWarning: "advertencia", PolicyFailed: "fallida"},
+		Policy:          "Política %s: %d infracciones, %d advertencias",
+		FullResults:     "Resultados completos",
+		Rerun:           "Volver a ejecutar",
+		Summary:         "Pipelines de %s del %s al %s: %d ejecuciones, %.0f%% con éxito",
+		Suppressed:      "La ventana de mantenimiento %s suprimió %d notificaciones. ",
+		Slowest:         "Pipelines más lentos",
+		AverageDuration: "%s de media",
+		TopFailures:     "Fallos más frecuentes",
+		Failures:        "%d de %d ejecuciones fallidas",
+	},
+	"fr": {
+		Statuses: map[string]string{
+			StatusStarted:   "PipelineRun %s a démarré",
+			StatusRunning:   "PipelineRun %s est toujours en cours",
+			StatusSucceeded: "PipelineRun %s a réussi",
+			StatusFailed:    "PipelineRun %s a échoué",
+		},
+		PolicyOutcomes:  map[string]string{PolicyPassed: "réussie", PolicyWarning: "avertissement", PolicyFailed: "échouée"},
+		Policy:          "Politique %s : %d violations, %d avertissements",
+		FullResults:     "Résultats complets",
+		Rerun:           "Relancer",
+		Summary:         "Pipelines de %s du %s au %s : %d exécutions, %.0f %% réussies",
+		Suppressed:      "La fenêtre de maintenance %s a supprimé %d notifications. ",
+		Slowest:         "Pipelines les plus lents",
+		AverageDuration: "%s en moyenne",
+		TopFailures:     "Échecs les plus fréquents",
+		Failures:        "%d exécutions sur %d en échec",
+	},
+	"ja": {
+		Statuses: map[string]string{
+			StatusStarted:   "PipelineRun %s が開始されました",
+			StatusRunning:   "PipelineRun %s はまだ実行中です",
+			StatusSucceeded: "PipelineRun %s が成功しました",
+			StatusFailed:    "PipelineRun %s が失敗しました",
+		},
+		PolicyOutcomes:  map[string]string{PolicyPassed: "合格", PolicyWarning: "警告", PolicyFailed: "不合格"},
+		Policy:          "ポリシー %s: 違反 %d 件、警告 %d 件",
+		FullResults:     "すべての結果",
+		Rerun:           "再実行",
+		Summary:         "%s のパイプライン (%s〜%s): 実行 %d 回、成功率 %.0f%%",
+		Suppressed:      "メンテナンスウィンドウ %s により通知 %d 件を抑制しました。",
+		Slowest:         "最も遅いパイプライン",
+		AverageDuration: "平均 %s",
+		TopFailures:     "失敗の多いパイプライン",
+		Failures:        "%[2]d 回中 %[1]d 回失敗",
+	},
+	"pt": {
+		Statuses: map[string]string{
+			StatusStarted:   "PipelineRun %s iniciado",
+			StatusRunning:   "PipelineRun %s ainda em execução",
+			StatusSucceeded: "PipelineRun %s concluído com sucesso",
+			StatusFailed:    "PipelineRun %s falhou",
+		},
+		PolicyOutcomes:  map[string]string{PolicyPassed: "aprovada", PolicyWarning: "aviso", PolicyFailed: "reprovada"},
+		Policy:          "Política %s: %d violações, %d avisos",
+		FullResults:     "Resultados completos",
+		Rerun:           "Executar novamente",
+		Summary:         "Pipelines de %s de %s a %s: %d execuções, %.0f%% com sucesso",
+		Suppressed:      "A janela de manutenção %s suprimiu %d notificações. ",
+		Slowest:         "Pipelines mais lentos",
+		AverageDuration: "%s em média",
+		TopFailures:     "Falhas mais frequentes",
+		Failures:        "%d de %d execuções falharam",
+	},
+}
+
+// LookupLocale returns the locale of a language code, or of the language of a language tag such as
+// pt-BR. An empty code returns the DefaultLocale.
+// Return error if the language is not supported
+func LookupLocale(code string) (*Locale, error) {
+	if code == "" {
+		code = DefaultLocale
+	}
+	language, _, _ := strings.Cut(strings.ReplaceAll(code, "_", "-"), "-")
+	locale, ok := Locales[strings.ToLower(language)]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported locale %s", code)
+	}
+	return locale, nil
+}
+
+// status describes the PipelineRun of the notification, given its formatted name.
+// Notifications without status describe a succeeded PipelineRun.
+func (l *Locale) status(notification *Notification, name string) string {
+	return fmt.Sprintf(l.Statuses[notificationStatus(notification)], name)
+}
+
+// summary describes the summary, given its formatted namespace.
+// Summaries of maintenance windows are preceded by the number of notifications they suppressed.
+func (l *Locale) summary(summary *Summary, namespace string) string {
+	var suppressed string
+	if summary.MaintenanceWindow != "" {
+		suppressed = fmt.Sprintf(l.Suppressed, summary.MaintenanceWindow, summary.Suppressed)
+	}
+	return suppressed + fmt.Sprintf(l.Summary, namespace, summary.From.Format(time.DateOnly), summary.To.Format(time.DateOnly),
+		summary.Total, summary.SuccessRate*100)
+}
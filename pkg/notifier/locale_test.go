@@ -0,0 +1,84 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Locales", func() {
+	DescribeTable("should look up locales by language",
+		func(code string, expected string) {
+			locale, err := LookupLocale(code)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(locale).To(BeIdenticalTo(Locales[expected]))
+		},
+		Entry("default", "", DefaultLocale),
+		Entry("language", "de", "de"),
+		Entry("language tag", "pt-BR", "pt"),
+		Entry("POSIX locale", "fr_CA", "fr"),
+		Entry("upper case language", "JA", "ja"),
+	)
+
+	It("should fail on unsupported languages", func() {
+		_, err := LookupLocale("xx")
+		Expect(err).To(MatchError("Unsupported locale xx"))
+	})
+
+	It("should translate every message of every locale", func() {
+		summary := &Summary{Namespace: "tenant", From: time.Now(), To: time.Now(), Total: 4, SuccessRate: 0.5}
+		for code, locale := range Locales {
+			for _, status := range []string{StatusStarted, StatusRunning, StatusSucceeded, StatusFailed} {
+				Expect(locale.status(&Notification{Status: status}, "build-1")).To(ContainSubstring("build-1"), code)
+			}
+			for _, outcome := range []string{PolicyPassed, PolicyWarning, PolicyFailed} {
+				Expect(locale.PolicyOutcomes).To(HaveKey(outcome), code)
+			}
+			messages := []string{
+				locale.summary(summary, "tenant"),
+				fmt.Sprintf(locale.Policy, locale.PolicyOutcomes[PolicyFailed], 2, 1),
+				fmt.Sprintf(locale.AverageDuration, "1m0s"),
+				fmt.Sprintf(locale.Failures, 1, 4),
+				locale.FullResults, locale.Rerun, locale.Slowest, locale.TopFailures,
+			}
+			for _, message := range messages {
+				Expect(message).NotTo(BeEmpty(), code)
+				Expect(strings.Contains(message, "%!")).To(BeFalse(), "%s: %s", code, message)
+			}
+		}
+	})
+
+	It("should describe the notifications suppressed by maintenance windows", func() {
+		summary := &Summary{Namespace: "tenant", From: time.Now(), To: time.Now(), MaintenanceWindow: "tenant/freeze", Suppressed: 3}
+		for code, locale := range Locales {
+			message := locale.summary(summary, "tenant")
+			Expect(message).To(ContainSubstring("tenant/freeze"), code)
+			Expect(message).To(ContainSubstring("3"), code)
+			Expect(strings.Contains(message, "%!")).To(BeFalse(), "%s: %s", code, message)
+		}
+		Expect(Locales["en"].summary(summary, "tenant")).To(HavePrefix("Maintenance window tenant/freeze suppressed 3 notifications. Pipelines of tenant"))
+	})
+
+	It("should reorder arguments of locales with another word order", func() {
+		Expect(fmt.Sprintf(Locales["ja"].Failures, 1, 4)).To(Equal("4 回中 1 回失敗"))
+	})
+})
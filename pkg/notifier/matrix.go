@@ -0,0 +1,278 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// MatrixOptions configures a MatrixNotifier
+type MatrixOptions struct {
+	// HomeserverURL is the base URL of the Matrix homeserver, e.g. https://matrix.example.com
+	HomeserverURL string
+	// AccessToken is the access token of the account posting messages
+	AccessToken string
+	// RoomID is the ID of the room messages are posted to, e.g. !abc:example.com. The account must have joined it.
+	RoomID string
+	// Template is an optional Go template rendering the HTML body of messages
+	Template string
+	// Locale is the language of the built-in messages, see Locales. Defaults to DefaultLocale.
+	Locale string
+	// Styles override the icon and color of statuses, see DefaultStatusStyles
+	Styles map[string]StatusStyle
+	// Timeout is the deadline of a single request
+	Timeout time.Duration
+	// HTTPClient is used to send requests, defaults to a client with Timeout
+	HTTPClient *http.Client
+}
+
+// MatrixNotifier posts notifications as formatted messages to a Matrix room and keeps all
+// notifications about the same PipelineRun on a single message, by editing it
+type MatrixNotifier struct {
+	homeserverURL string
+	accessToken   string
+	roomID        string
+	template      *template.Template
+	locale        *Locale
+	styles        map[string]StatusStyle
+	client        *http.Client
+}
+
+// NewMatrixNotifier creates a MatrixNotifier from the given options
+func NewMatrixNotifier(opts MatrixOptions) (*MatrixNotifier, error) {
+	if opts.HomeserverURL == "" {
+		return nil, errors.New("Matrix homeserver URL must be set")
+	}
+	if opts.AccessToken == "" {
+		return nil, errors.New("Matrix access token must be set")
+	}
+	if opts.RoomID == "" {
+		return nil, errors.New("Matrix room ID must be set")
+	}
+	locale, err := LookupLocale(opts.Locale)
+	if err != nil {
+		return nil, err
+	}
+	styles, err := mergeStatusStyles(opts.Styles)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultWebhookTimeout
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = NewHTTPClient(opts.Timeout)
+	}
+	m := &MatrixNotifier{
+		homeserverURL: strings.TrimSuffix(opts.HomeserverURL, "/"),
+		accessToken:   opts.AccessToken,
+		roomID:        opts.RoomID,
+		locale:        locale,
+		styles:        styles,
+		client:        opts.HTTPClient,
+	}
+	if opts.Template != "" {
+		tmpl, err := NewTemplate("matrix", opts.Template)
+		if err != nil {
+			return nil, err
+		}
+		m.template = tmpl
+	}
+	return m, nil
+}
+
+// Notify posts the notification as a new Matrix message
+func (m *MatrixNotifier) Notify(ctx context.Context, notification *Notification) error {
+	_, err := m.NotifyInThread(ctx, notification, "")
+	return err
+}
+
+// NotifyInThread posts the first notification about a PipelineRun as a new message and edits that
+// message for later notifications. The thread is the event ID of the first message.
+func (m *MatrixNotifier) NotifyInThread(ctx context.Context, notification *Notification, thread string) (string, error) {
+	content, err := m.content(notification)
+	if err != nil {
+		return "", err
+	}
+	if thread != "" {
+		newContent := content
+		content.Body = "* " + newContent.Body
+		content.NewContent = &newContent
+		content.RelatesTo = &matrixRelation{RelType: "m.replace", EventID: thread}
+	}
+	eventID, err := m.send(ctx, content)
+	if err != nil {
+		return "", fmt.Errorf("Failed to post Matrix message for pipelinerun %s: %w", notification.PipelineRun, err)
+	}
+	if thread != "" {
+		return thread, nil
+	}
+	return eventID, nil
+}
+
+// Probe checks the access token of the notifier with the whoami endpoint of the homeserver
+func (m *MatrixNotifier) Probe(ctx context.Context) error {
+	_, err := m.call(ctx, http.MethodGet, "/_matrix/client/v3/account/whoami", nil)
+	return err
+}
+
+// NotifySummary posts the summary as a new Matrix message
+func (m *MatrixNotifier) NotifySummary(ctx context.Context, summary *Summary) error {
+	var text, formatted strings.Builder
+	text.WriteString("📊 " + m.locale.summary(summary, summary.Namespace))
+	formatted.WriteString("📊 " + m.locale.summary(summary, "<code>"+html.EscapeString(summary.Namespace)+"</code>"))
+	if len(summary.Slowest) > 0 {
+		text.WriteString("\n" + m.locale.Slowest)
+		formatted.WriteString("<br><b>" + m.locale.Slowest + "</b><ul>")
+		for _, stats := range summary.Slowest {
+			average := fmt.Sprintf(m.locale.AverageDuration, formatDuration(stats.AverageDurationSeconds))
+			fmt.Fprintf(&text, "\n• %s: %s", stats.Pipeline, average)
+			fmt.Fprintf(&formatted, "<li><code>%s</code>: %s</li>", html.EscapeString(stats.Pipeline), average)
+		}
+		formatted.WriteString("</ul>")
+	}
+	if len(summary.TopFailures) > 0 {
+		text.WriteString("\n" + m.locale.TopFailures)
+		formatted.WriteString("<br><b>" + m.locale.TopFailures + "</b><ul>")
+		for _, stats := range summary.TopFailures {
+			failures := fmt.Sprintf(m.locale.Failures, stats.Failures, stats.Runs)
+			fmt.Fprintf(&text, "\n• %s: %s", stats.Pipeline, failures)
+			fmt.Fprintf(&formatted, "<li><code>%s</code>: %s</li>", html.EscapeString(stats.Pipeline), failures)
+		}
+		formatted.WriteString("</ul>")
+	}
+	_, err := m.send(ctx, matrixContent{
+		MsgType: "m.notice", Body: text.String(), Format: "org.matrix.custom.html", FormattedBody: formatted.String(),
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to post Matrix summary for namespace %s: %w", summary.Namespace, err)
+	}
+	return nil
+}
+
+// content returns the message of the notification, with a plain text body for clients without HTML support
+func (m *MatrixNotifier) content(notification *Notification) (matrixContent, error) {
+	style := m.styles[notificationStatus(notification)]
+	var text, formatted strings.Builder
+	fmt.Fprintf(&text, "%s %s", style.Icon, m.locale.status(notification, notification.Namespace+"/"+notification.PipelineRun))
+	headline := m.locale.status(notification,
+		"<code>"+html.EscapeString(notification.Namespace)+"/"+html.EscapeString(notification.PipelineRun)+"</code>")
+	if style.Color != "" {
+		headline = fmt.Sprintf(`<font data-mx-color="%s">%s</font>`, style.Color, headline)
+	}
+	fmt.Fprintf(&formatted, "%s %s", html.EscapeString(style.Icon), headline)
+	if len(notification.Results) > 0 {
+		formatted.WriteString("<ul>")
+		for _, result := range notification.Results {
+			fmt.Fprintf(&text, "\n• %s: %s", result.Name, result.String())
+			fmt.Fprintf(&formatted, "<li><b>%s</b>: <code>%s</code></li>", html.EscapeString(result.Name), html.EscapeString(result.String()))
+		}
+		formatted.WriteString("</ul>")
+	}
+	if notification.PayloadURL != "" {
+		fmt.Fprintf(&text, "\n%s: %s", m.locale.FullResults, notification.PayloadURL)
+		fmt.Fprintf(&formatted, `<a href="%s">%s</a>`, html.EscapeString(notification.PayloadURL), m.locale.FullResults)
+	}
+	content := matrixContent{MsgType: "m.notice", Body: text.String(), Format: "org.matrix.custom.html", FormattedBody: formatted.String()}
+	if m.template != nil {
+		rendered, err := Render(m.template, notification)
+		if err != nil {
+			return matrixContent{}, err
+		}
+		content.FormattedBody = string(rendered)
+	}
+	return content, nil
+}
+
+// matrixContent is the content of an m.room.message event
+type matrixContent struct {
+	MsgType       string          `json:"msgtype"`
+	Body          string          `json:"body"`
+	Format        string          `json:"format,omitempty"`
+	FormattedBody string          `json:"formatted_body,omitempty"`
+	NewContent    *matrixContent  `json:"m.new_content,omitempty"`
+	RelatesTo     *matrixRelation `json:"m.relates_to,omitempty"`
+}
+
+// matrixRelation relates an event to another one, e.g. an edit to the edited message
+type matrixRelation struct {
+	RelType string `json:"rel_type"`
+	EventID string `json:"event_id"`
+}
+
+// send sends the message to the room and returns its event ID
+func (m *MatrixNotifier) send(ctx context.Context, content matrixContent) (string, error) {
+	txn := make([]byte, 16)
+	if _, err := rand.Read(txn); err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(content)
+	if err != nil {
+		return "", err
+	}
+	path := "/_matrix/client/v3/rooms/" + url.PathEscape(m.roomID) + "/send/m.room.message/" + hex.EncodeToString(txn)
+	response, err := m.call(ctx, http.MethodPut, path, body)
+	if err != nil {
+		return "", err
+	}
+	return response.EventID, nil
+}
+
+// matrixResponse is the common part of Matrix client API responses
+type matrixResponse struct {
+	EventID string `json:"event_id"`
+	ErrCode string `json:"errcode"`
+	Error   string `json:"error"`
+}
+
+// call invokes an endpoint of the Matrix client API
+func (m *MatrixNotifier) call(ctx context.Context, method string, path string, body []byte) (*matrixResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, method, m.homeserverURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+m.accessToken)
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	response := &matrixResponse{}
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, MaxResponseExcerptBytes))
+	_ = json.Unmarshal(raw, response)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if response.ErrCode != "" {
+			return nil, fmt.Errorf("Matrix request failed with status %d: %s: %s", resp.StatusCode, response.ErrCode, response.Error)
+		}
+		return nil, fmt.Errorf("Matrix request failed with status %d", resp.StatusCode)
+	}
+	return response, nil
+}
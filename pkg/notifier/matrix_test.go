@@ -0,0 +1,144 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// matrixCall is a request received by the fake homeserver
+type matrixCall struct {
+	method        string
+	path          string
+	authorization string
+	content       matrixContent
+}
+
+var _ = Describe("MatrixNotifier", func() {
+	var (
+		server *httptest.Server
+		calls  []matrixCall
+		status int
+	)
+
+	succeeded := &Notification{
+		PipelineRun: "build-1",
+		Namespace:   "tenant",
+		Status:      StatusSucceeded,
+		Results:     []Result{{Name: "IMAGE_URL", Value: "quay.io/test/<image>"}},
+	}
+
+	newNotifier := func(opts MatrixOptions) *MatrixNotifier {
+		opts.HomeserverURL = server.URL
+		opts.AccessToken = "syt_test"
+		opts.RoomID = "!builds:example.com"
+		n, err := NewMatrixNotifier(opts)
+		Expect(err).NotTo(HaveOccurred())
+		return n
+	}
+
+	BeforeEach(func() {
+		calls = nil
+		status = http.StatusOK
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			call := matrixCall{method: r.Method, path: r.URL.EscapedPath(), authorization: r.Header.Get("Authorization")}
+			if r.Method == http.MethodPut {
+				Expect(json.NewDecoder(r.Body).Decode(&call.content)).To(Succeed())
+			}
+			calls = append(calls, call)
+			w.WriteHeader(status)
+			if status != http.StatusOK {
+				_, _ = w.Write([]byte(`{"errcode": "M_FORBIDDEN", "error": "not in room"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"event_id": "$event1", "user_id": "@bot:example.com"}`))
+		}))
+		DeferCleanup(server.Close)
+	})
+
+	It("should post the first notification as a formatted message", func() {
+		thread, err := newNotifier(MatrixOptions{}).NotifyInThread(context.Background(), succeeded, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(thread).To(Equal("$event1"))
+		Expect(calls).To(HaveLen(1))
+		Expect(calls[0].method).To(Equal(http.MethodPut))
+		Expect(calls[0].path).To(HavePrefix("/_matrix/client/v3/rooms/%21builds:example.com/send/m.room.message/"))
+		Expect(calls[0].authorization).To(Equal("Bearer syt_test"))
+		Expect(calls[0].content.Body).To(Equal("✅ PipelineRun tenant/build-1 succeeded\n• IMAGE_URL: quay.io/test/<image>"))
+		Expect(calls[0].content.Format).To(Equal("org.matrix.custom.html"))
+		Expect(calls[0].content.FormattedBody).To(ContainSubstring("<li><b>IMAGE_URL</b>: <code>quay.io/test/&lt;image&gt;</code></li>"))
+	})
+
+	It("should decorate messages with the configured status styles", func() {
+		styles := map[string]StatusStyle{StatusSucceeded: {Icon: "🚀", Color: "#2eb886"}}
+		err := newNotifier(MatrixOptions{Styles: styles}).Notify(context.Background(), succeeded)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(calls).To(HaveLen(1))
+		Expect(calls[0].content.Body).To(HavePrefix("🚀 PipelineRun tenant/build-1 succeeded"))
+		Expect(calls[0].content.FormattedBody).To(HavePrefix(
+			`🚀 <font data-mx-color="#2eb886">PipelineRun <code>tenant/build-1</code> succeeded</font>`))
+	})
+
+	It("should edit the first message with later statuses", func() {
+		_, err := newNotifier(MatrixOptions{}).NotifyInThread(context.Background(), succeeded, "$first")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(calls[0].content.Body).To(HavePrefix("* ✅"))
+		Expect(calls[0].content.RelatesTo).To(Equal(&matrixRelation{RelType: "m.replace", EventID: "$first"}))
+		Expect(calls[0].content.NewContent.Body).To(HavePrefix("✅"))
+	})
+
+	It("should render templates", func() {
+		Expect(newNotifier(MatrixOptions{Template: `<b>{{ .PipelineRun }}</b>`}).Notify(context.Background(), succeeded)).To(Succeed())
+		Expect(calls[0].content.FormattedBody).To(Equal("<b>build-1</b>"))
+	})
+
+	It("should probe the token with whoami", func() {
+		Expect(newNotifier(MatrixOptions{}).Probe(context.Background())).To(Succeed())
+		Expect(calls[0].method).To(Equal(http.MethodGet))
+		Expect(calls[0].path).To(Equal("/_matrix/client/v3/account/whoami"))
+	})
+
+	It("should post summaries", func() {
+		summary := &Summary{
+			Namespace: "tenant", From: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2024, 5, 8, 0, 0, 0, 0, time.UTC),
+			Total: 4, SuccessRate: 0.5, TopFailures: []PipelineStats{{Pipeline: "build", Runs: 4, Failures: 2}},
+		}
+		Expect(newNotifier(MatrixOptions{}).NotifySummary(context.Background(), summary)).To(Succeed())
+		Expect(strings.Split(calls[0].content.Body, "\n")).To(Equal([]string{
+			"📊 Pipelines of tenant from 2024-05-01 to 2024-05-08: 4 runs, 50% succeeded", "Top failures", "• build: 2 of 4 runs failed",
+		}))
+	})
+
+	It("should report errors returned by the homeserver", func() {
+		status = http.StatusForbidden
+		err := newNotifier(MatrixOptions{}).Notify(context.Background(), succeeded)
+		Expect(err).To(MatchError(ContainSubstring("status 403: M_FORBIDDEN: not in room")))
+	})
+
+	It("should reject missing options", func() {
+		_, err := NewMatrixNotifier(MatrixOptions{HomeserverURL: server.URL, AccessToken: "syt_test"})
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,440 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Notifier delivers notifications about PipelineRuns to a destination
+type Notifier interface {
+	// Notify sends the notification to the destination.
+	// If the notification was not delivered, a non-nil error is returned.
+	Notify(ctx context.Context, notification *Notification) error
+}
+
+// ResponseNotifier is implemented by notifiers that report how the destination responded
+type ResponseNotifier interface {
+	Notifier
+	// NotifyWithResponse sends the notification and returns the response of the destination.
+	// The response may be returned together with an error, e.g. for rejected notifications.
+	NotifyWithResponse(ctx context.Context, notification *Notification) (*Response, error)
+}
+
+// MaxResponseExcerptBytes bounds the part of a response body kept in a Response
+const MaxResponseExcerptBytes = 512
+
+// Response describes how a destination responded to a notification
+type Response struct {
+	// StatusCode is the status returned by the destination, e.g. the HTTP status code
+	StatusCode int
+	// Excerpt is the beginning of the response body, at most MaxResponseExcerptBytes long
+	Excerpt string
+}
+
+// Deliver sends the notification with the notifier.
+// The response of the destination is returned if the notifier reports it.
+func Deliver(ctx context.Context, n Notifier, notification *Notification) (*Response, error) {
+	if responseNotifier, ok := n.(ResponseNotifier); ok {
+		return responseNotifier.NotifyWithResponse(ctx, notification)
+	}
+	return nil, n.Notify(ctx, notification)
+}
+
+// ProbeNotifier is implemented by notifiers that can check their destination without notifying it
+type ProbeNotifier interface {
+	Notifier
+	// Probe checks that the destination is reachable and accepts the credentials of the notifier.
+	// If the destination is not usable, a non-nil error is returned.
+	Probe(ctx context.Context) error
+}
+
+// Probe checks the destination of the notifier, if it supports probes.
+// It returns false if the notifier does not support probes.
+func Probe(ctx context.Context, n Notifier) (bool, error) {
+	probeNotifier, ok := n.(ProbeNotifier)
+	if !ok {
+		return false, nil
+	}
+	return true, probeNotifier.Probe(ctx)
+}
+
+// ThreadNotifier is implemented by notifiers that continue the message of a previous
+// notification about the same PipelineRun instead of sending a new one
+type ThreadNotifier interface {
+	Notifier
+	// NotifyInThread sends the notification as a continuation of the thread returned for a previous
+	// notification, or starts a new thread if it is empty. It returns the thread to continue next.
+	NotifyInThread(ctx context.Context, notification *Notification, thread string) (string, error)
+}
+
+// Statuses of the PipelineRun a notification is sent for
+const (
+	StatusStarted   = "Started"
+	StatusRunning   = "Running"
+	StatusSucceeded = "Succeeded"
+	StatusFailed    = "Failed"
+)
+
+// Events of the lifecycle of the PipelineRun a notification is sent for. Unlike statuses, they tell
+// cancelled PipelineRuns from the ones that failed.
+const (
+	EventStarted   = "started"
+	EventRunning   = "running"
+	EventSucceeded = "succeeded"
+	EventFailed    = "failed"
+	EventCancelled = "cancelled"
+	// EventSucceededWithRetries is the subtype of succeeded notifications about PipelineRuns that
+	// succeeded after some of their tasks were retried
+	EventSucceededWithRetries = "succeededWithRetries"
+)
+
+// Kinds of the runs notifications are sent for, other than PipelineRuns
+const (
+	// KindCustomRun is the Kind of notifications about Tekton CustomRuns
+	KindCustomRun = "CustomRun"
+	// KindWorkflow is the Kind of notifications about Argo Workflows
+	KindWorkflow = "Workflow"
+	// KindRelease is the Kind of notifications about Konflux Releases
+	KindRelease = "Release"
+	// KindTaskRun is the Kind of notifications about standalone Tekton TaskRuns
+	KindTaskRun = "TaskRun"
+)
+
+// Notification describes the outcome of a PipelineRun
+type Notification struct {
+	// PipelineRun is the name of the PipelineRun
+	PipelineRun string `json:"pipelineRun" xml:"pipelineRun"`
+	// Namespace is the namespace of the PipelineRun
+	Namespace string `json:"namespace" xml:"namespace"`
+	// Kind is the kind of the run, e.g. KindCustomRun, which is then named by PipelineRun, and is empty for PipelineRuns
+	Kind string `json:"kind,omitempty" xml:"kind,omitempty"`
+	// Team is the team owning the namespace of the PipelineRun, if known
+	Team string `json:"team,omitempty" xml:"team,omitempty"`
+	// Status is the status of the PipelineRun when the notification was sent
+	Status string `json:"status,omitempty" xml:"status,omitempty"`
+	// Event is the event of the lifecycle of the PipelineRun the notification is sent for
+	Event string `json:"event,omitempty" xml:"event,omitempty"`
+	// Subtype refines the event, it is EventSucceededWithRetries for PipelineRuns that succeeded after retries
+	Subtype string `json:"subtype,omitempty" xml:"subtype,omitempty"`
+	// Author is the person who triggered the PipelineRun, if known
+	Author *Contact `json:"author,omitempty" xml:"author,omitempty"`
+	// StartTime is when the PipelineRun started
+	StartTime *time.Time `json:"startTime,omitempty" xml:"startTime,omitempty"`
+	// CompletionTime is when the PipelineRun completed
+	CompletionTime *time.Time `json:"completionTime,omitempty" xml:"completionTime,omitempty"`
+	// DurationSeconds is the time the PipelineRun ran for, up to now if it did not complete yet
+	DurationSeconds float64 `json:"durationSeconds,omitempty" xml:"durationSeconds,omitempty"`
+	// Tasks are the timings and retries of the TaskRuns of the PipelineRun
+	Tasks []TaskTiming `json:"tasks,omitempty" xml:"task,omitempty"`
+	// Retries is the number of times the TaskRuns of the PipelineRun were retried in total
+	Retries int `json:"retries,omitempty" xml:"retries,omitempty"`
+	// Matrix groups the results of the TaskRuns of the pipeline tasks fanned out by a matrix by combination
+	Matrix []MatrixTask `json:"matrix,omitempty" xml:"matrix,omitempty"`
+	// FailureStreak is the number of consecutive failed PipelineRuns of the same Pipeline, including this one
+	FailureStreak int `json:"failureStreak,omitempty" xml:"failureStreak,omitempty"`
+	// Flaky is set when the recent PipelineRuns of the same Pipeline alternate between success and failure
+	Flaky bool `json:"flaky,omitempty" xml:"flaky,omitempty"`
+	// Results are the results produced by the PipelineRun
+	Results []Result `json:"results" xml:"results>result"`
+	// TruncatedResults are the names of results that were dropped to respect a payload size limit
+	TruncatedResults []string `json:"truncatedResults,omitempty" xml:"truncatedResults,omitempty"`
+	// PayloadURL is a signed URL the full notification, including the results dropped because they
+	// exceeded the overflow threshold, can be downloaded from, or the URL of the notification in the audit archive
+	PayloadURL string `json:"payloadURL,omitempty" xml:"payloadURL,omitempty"`
+	// PayloadRef is the key of the notification in the audit archive, if one is configured. Unlike PayloadURL,
+	// it does not expire and is the same for every attempt to deliver the notification.
+	PayloadRef string `json:"payloadRef,omitempty" xml:"payloadRef,omitempty"`
+	// Chains is the Tekton Chains signature status of the PipelineRun, if it is handled by Chains
+	Chains *ChainsSignature `json:"chains,omitempty" xml:"chains,omitempty"`
+	// Policy summarizes the Enterprise Contract or policy check results of the PipelineRun, if it produced any
+	Policy *PolicySummary `json:"policy,omitempty" xml:"policy,omitempty"`
+	// Provenance summarizes what the PipelineRun built and from which materials, if it built artifacts
+	Provenance *Provenance `json:"provenance,omitempty" xml:"provenance,omitempty"`
+	// Konflux is the Konflux context of Releases, release PipelineRuns and integration test PipelineRuns
+	Konflux *KonfluxContext `json:"konflux,omitempty" xml:"konflux,omitempty"`
+	// Enrichment holds the values added by enrichment hooks, e.g. ticket links or owners, see Enricher.
+	// XML bodies omit it since XML has no maps.
+	Enrichment map[string]any `json:"enrichment,omitempty" xml:"-"`
+	// CallbackURL is set for destinations that acknowledge notifications asynchronously.
+	// The destination must POST to it once the notification was processed.
+	CallbackURL string `json:"callbackURL,omitempty" xml:"callbackURL,omitempty"`
+	// DeliveryID identifies the delivery of the notification to a destination. It is the same for every
+	// attempt to deliver it, so receivers can skip the notifications they already processed, see package delivery.
+	DeliveryID string `json:"deliveryID,omitempty" xml:"deliveryID,omitempty"`
+}
+
+// Hash returns the sha256 hash of the JSON encoded notification, in the form sha256:<hex>
+func (n *Notification) Hash() (string, error) {
+	encoded, err := json.Marshal(n)
+	if err != nil {
+		return "", fmt.Errorf("Failed to encode notification for pipelinerun %s: %w", n.PipelineRun, err)
+	}
+	sum := sha256.Sum256(encoded)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// Result returns the result of the PipelineRun with the name, e.g. {{ (.Result "SCAN").Object.critical }}
+// in templates, or an empty result if the PipelineRun did not produce it
+func (n *Notification) Result(name string) Result {
+	for _, result := range n.Results {
+		if result.Name == name {
+			return result
+		}
+	}
+	return Result{Name: name}
+}
+
+// Tekton Chains signature statuses of a PipelineRun
+const (
+	ChainsSigned   = "signed"
+	ChainsFailed   = "failed"
+	ChainsUnsigned = "unsigned"
+)
+
+// ChainsSignature describes how Tekton Chains signed the PipelineRun
+type ChainsSignature struct {
+	// Status is signed, failed, or unsigned if Chains did not sign the PipelineRun yet
+	Status string `json:"status" xml:"status"`
+	// Transparency is the transparency log entry of the signature, if it was recorded in one
+	Transparency string `json:"transparency,omitempty" xml:"transparency,omitempty"`
+}
+
+// Outcomes of the policy checks of a PipelineRun
+const (
+	PolicyPassed  = "passed"
+	PolicyWarning = "warning"
+	PolicyFailed  = "failed"
+)
+
+// PolicySummary summarizes the Enterprise Contract or policy check results of a PipelineRun
+type PolicySummary struct {
+	// Outcome is failed if a policy was violated, warning if policies only warned, and passed otherwise
+	Outcome string `json:"outcome" xml:"outcome"`
+	// Violations is the number of policy violations
+	Violations int `json:"violations" xml:"violations"`
+	// Warnings is the number of policy warnings
+	Warnings int `json:"warnings" xml:"warnings"`
+	// Rules are the IDs of the violated rules, followed by the IDs of the rules that warned
+	Rules []string `json:"rules,omitempty" xml:"rule,omitempty"`
+	// Severity is the worst severity of the violations and warnings: critical, high, medium or low
+	Severity string `json:"severity,omitempty" xml:"severity,omitempty"`
+	// Results are the names of the results the summary was parsed from
+	Results []string `json:"results" xml:"result"`
+}
+
+// Provenance is a compact summary of the SLSA provenance of a PipelineRun
+type Provenance struct {
+	// BuilderID identifies the builder of the artifacts
+	BuilderID string `json:"builderID,omitempty" xml:"builderID,omitempty"`
+	// Subjects are the artifacts built by the PipelineRun, e.g. images and their digest
+	Subjects []Artifact `json:"subjects" xml:"subject"`
+	// Materials are the sources the artifacts were built from, e.g. git repositories and their commit
+	Materials []Artifact `json:"materials,omitempty" xml:"material,omitempty"`
+}
+
+// KonfluxContext describes what a Konflux Release or integration test was run for
+type KonfluxContext struct {
+	// Application is the Konflux application
+	Application string `json:"application,omitempty" xml:"application,omitempty"`
+	// Component is the component of the application, for integration tests of a single component
+	Component string `json:"component,omitempty" xml:"component,omitempty"`
+	// Snapshot is the snapshot of the application that is tested or released
+	Snapshot string `json:"snapshot,omitempty" xml:"snapshot,omitempty"`
+	// Scenario is the IntegrationTestScenario of integration tests
+	Scenario string `json:"scenario,omitempty" xml:"scenario,omitempty"`
+	// Release is the Release, in the form <namespace>/<name> for release PipelineRuns
+	Release string `json:"release,omitempty" xml:"release,omitempty"`
+	// ReleasePlan is the ReleasePlan of Releases
+	ReleasePlan string `json:"releasePlan,omitempty" xml:"releasePlan,omitempty"`
+	// Environment is the target of Releases, or the environment integration tests ran in
+	Environment string `json:"environment,omitempty" xml:"environment,omitempty"`
+}
+
+// Artifact is an artifact identified by its URI and digest
+type Artifact struct {
+	// URI locates the artifact
+	URI string `json:"uri" xml:"uri"`
+	// Digest is the digest of the artifact in the form <algorithm>:<hex>
+	Digest string `json:"digest,omitempty" xml:"digest,omitempty"`
+}
+
+// TaskTiming describes how long a task of the PipelineRun ran for and how many times it was retried
+type TaskTiming struct {
+	// Name is the name of the pipeline task
+	Name string `json:"name" xml:"name,attr"`
+	// TaskRun is the name of the TaskRun that ran the task
+	TaskRun string `json:"taskRun" xml:"taskRun"`
+	// StartTime is when the TaskRun started
+	StartTime *time.Time `json:"startTime,omitempty" xml:"startTime,omitempty"`
+	// CompletionTime is when the TaskRun completed
+	CompletionTime *time.Time `json:"completionTime,omitempty" xml:"completionTime,omitempty"`
+	// DurationSeconds is the time the TaskRun ran for, up to now if it did not complete yet
+	DurationSeconds float64 `json:"durationSeconds,omitempty" xml:"durationSeconds,omitempty"`
+	// Retries is the number of times the TaskRun was retried
+	Retries int `json:"retries,omitempty" xml:"retries,omitempty"`
+}
+
+// MatrixTask is a pipeline task fanned out by a matrix into a TaskRun for every combination of its parameters
+type MatrixTask struct {
+	// Name is the name of the pipeline task
+	Name string `json:"name" xml:"name,attr"`
+	// Combinations are the TaskRuns of the task, in the order they were created in
+	Combinations []MatrixCombination `json:"combinations" xml:"combination"`
+}
+
+// MatrixCombination is the TaskRun of a combination of the matrix parameters of a MatrixTask
+type MatrixCombination struct {
+	// TaskRun is the name of the TaskRun that ran the combination
+	TaskRun string `json:"taskRun" xml:"taskRun,attr"`
+	// Params are the values of the matrix parameters of the combination
+	Params []MatrixParam `json:"params" xml:"params>param"`
+	// Status is the status of the TaskRun
+	Status string `json:"status,omitempty" xml:"status,omitempty"`
+	// Results are the results produced by the TaskRun
+	Results []Result `json:"results,omitempty" xml:"result,omitempty"`
+}
+
+// MatrixParam is the value of a matrix parameter in a MatrixCombination
+type MatrixParam struct {
+	// Name is the name of the parameter
+	Name string `json:"name" xml:"name,attr"`
+	// Value is the value of the parameter in the combination
+	Value string `json:"value" xml:",chardata"`
+}
+
+// Contact identifies a person and how to reach them
+type Contact struct {
+	// Name is the git or Kubernetes user name of the person
+	Name string `json:"name" xml:"name"`
+	// Slack is the Slack member ID of the person
+	Slack string `json:"slack,omitempty" xml:"slack,omitempty"`
+	// Email is the email address of the person
+	Email string `json:"email,omitempty" xml:"email,omitempty"`
+}
+
+// Mention returns the chat handle of the contact, falling back to its email and then its name
+func (c *Contact) Mention() string {
+	switch {
+	case c == nil:
+		return ""
+	case c.Slack != "":
+		return "<@" + c.Slack + ">"
+	case c.Email != "":
+		return c.Email
+	default:
+		return c.Name
+	}
+}
+
+// Types of PipelineRun results that are not strings
+const (
+	ResultTypeArray  = "array"
+	ResultTypeObject = "object"
+)
+
+// Formats of array and object results in notifications
+const (
+	// ResultFormatStructured keeps array and object results structured
+	ResultFormatStructured = "structured"
+	// ResultFormatString JSON encodes array and object results in their value
+	ResultFormatString = "string"
+	// ResultFormatFlatten expands array and object results into a string result per item,
+	// named <name>.<index> and <name>.<key>
+	ResultFormatFlatten = "flatten"
+)
+
+// Result is a single PipelineRun result
+type Result struct {
+	// Name is the name of the result
+	Name string `json:"name" xml:"name,attr"`
+	// Type is array or object for results that are not strings
+	Type string `json:"type,omitempty" xml:"type,attr,omitempty"`
+	// Value is the value of string results
+	Value string `json:"value,omitempty" xml:",chardata"`
+	// Array is the value of array results
+	Array []string `json:"array,omitempty" xml:"-"`
+	// Object is the value of object results
+	Object map[string]string `json:"object,omitempty" xml:"-"`
+}
+
+// String returns the value of the result, array and object results are JSON encoded
+func (r Result) String() string {
+	var encoded []byte
+	switch r.Type {
+	case ResultTypeArray:
+		encoded, _ = json.Marshal(r.Array)
+	case ResultTypeObject:
+		encoded, _ = json.Marshal(r.Object)
+	default:
+		return r.Value
+	}
+	return string(encoded)
+}
+
+// FormatResults returns the results in the format, see the ResultFormat constants.
+// Unknown formats keep the results structured.
+func FormatResults(results []Result, format string) []Result {
+	if format != ResultFormatString && format != ResultFormatFlatten {
+		return results
+	}
+	formatted := make([]Result, 0, len(results))
+	for _, result := range results {
+		switch {
+		case result.Type == "":
+			formatted = append(formatted, result)
+		case format == ResultFormatString:
+			formatted = append(formatted, Result{Name: result.Name, Value: result.String()})
+		case result.Type == ResultTypeArray:
+			for i, item := range result.Array {
+				formatted = append(formatted, Result{Name: fmt.Sprintf("%s.%d", result.Name, i), Value: item})
+			}
+		default:
+			keys := make([]string, 0, len(result.Object))
+			for key := range result.Object {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				formatted = append(formatted, Result{Name: result.Name + "." + key, Value: result.Object[key]})
+			}
+		}
+	}
+	return formatted
+}
+
+// MultiNotifier sends each notification to all of its notifiers
+type MultiNotifier []Notifier
+
+// Notify sends the notification to all notifiers, even if some of them fail.
+// The returned error joins the errors of all failed notifiers.
+func (m MultiNotifier) Notify(ctx context.Context, notification *Notification) error {
+	var errs []error
+	for _, n := range m {
+		if err := n.Notify(ctx, notification); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
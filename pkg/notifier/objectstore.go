@@ -0,0 +1,215 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultObjectURLExpiry is how long the signed URLs of stored objects are valid by default
+const DefaultObjectURLExpiry = 24 * time.Hour
+
+// ObjectStore stores payloads that do not fit in notifications
+type ObjectStore interface {
+	// Put stores the body under the key and returns a signed URL it can be downloaded from
+	Put(ctx context.Context, key string, body []byte, contentType string) (string, error)
+}
+
+// S3Options configures an S3Store
+type S3Options struct {
+	// BucketURL is the URL of the bucket, either virtual hosted, e.g. https://<bucket>.s3.<region>.amazonaws.com,
+	// or path style, e.g. https://minio.example.com/<bucket>, objects are stored under it
+	BucketURL string
+	// Region is the region of the bucket, defaults to us-east-1
+	Region string
+	// AccessKeyID and SecretAccessKey are the credentials requests are signed with
+	AccessKeyID     string
+	SecretAccessKey string
+	// URLExpiry is how long the signed URLs are valid, at most 7 days. Defaults to DefaultObjectURLExpiry.
+	URLExpiry time.Duration
+	// HTTPClient is used to send requests, defaults to a client with DefaultWebhookTimeout
+	HTTPClient *http.Client
+}
+
+// S3Store stores objects in an S3 compatible bucket and signs their URLs with AWS Signature Version 4
+type S3Store struct {
+	opts   S3Options
+	bucket *url.URL
+	now    func() time.Time
+}
+
+// NewS3Store creates an S3Store from the given options
+func NewS3Store(opts S3Options) (*S3Store, error) {
+	bucket, err := url.Parse(strings.TrimSuffix(opts.BucketURL, "/"))
+	if err != nil || bucket.Host == "" {
+		return nil, fmt.Errorf("Invalid bucket URL %s", opts.BucketURL)
+	}
+	if opts.AccessKeyID == "" || opts.SecretAccessKey == "" {
+		return nil, errors.New("bucket credentials must be set")
+	}
+	if opts.Region == "" {
+		opts.Region = "us-east-1"
+	}
+	if opts.URLExpiry <= 0 {
+		opts.URLExpiry = DefaultObjectURLExpiry
+	}
+	if opts.URLExpiry > 7*24*time.Hour {
+		return nil, fmt.Errorf("Signed URLs cannot be valid for more than 7 days, got %s", opts.URLExpiry)
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = NewHTTPClient(DefaultWebhookTimeout)
+	}
+	return &S3Store{opts: opts, bucket: bucket, now: time.Now}, nil
+}
+
+// Put uploads the body to the bucket and returns its presigned GET URL
+func (s *S3Store) Put(ctx context.Context, key string, body []byte, contentType string) (string, error) {
+	objectURL := s.objectURL(key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, objectURL.String(), bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("Failed to create upload request for %s: %w", key, err)
+	}
+	now := s.now().UTC()
+	sum := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(sum[:])
+	headers := map[string]string{
+		"content-type":         contentType,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           now.Format("20060102T150405Z"),
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	headers["host"] = objectURL.Host
+	signedHeaders, signature := s.signature(http.MethodPut, objectURL, nil, headers, payloadHash, now)
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.opts.AccessKeyID, s.scope(now), signedHeaders, signature))
+	resp, err := s.opts.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("Failed to upload %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+	excerpt, _ := io.ReadAll(io.LimitReader(resp.Body, MaxResponseExcerptBytes))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("Upload of %s failed with status %d: %s", key, resp.StatusCode, strings.TrimSpace(string(excerpt)))
+	}
+	return s.PresignGet(key, now), nil
+}
+
+// PresignGet returns the URL the object can be downloaded from without credentials until URLExpiry after now
+func (s *S3Store) PresignGet(key string, now time.Time) string {
+	now = now.UTC()
+	objectURL := s.objectURL(key)
+	query := map[string]string{
+		"X-Amz-Algorithm":     "AWS4-HMAC-SHA256",
+		"X-Amz-Credential":    s.opts.AccessKeyID + "/" + s.scope(now),
+		"X-Amz-Date":          now.Format("20060102T150405Z"),
+		"X-Amz-Expires":       strconv.Itoa(int(s.opts.URLExpiry.Seconds())),
+		"X-Amz-SignedHeaders": "host",
+	}
+	_, signature := s.signature(http.MethodGet, objectURL, query, map[string]string{"host": objectURL.Host}, "UNSIGNED-PAYLOAD", now)
+	query["X-Amz-Signature"] = signature
+	objectURL.RawQuery = canonicalQuery(query)
+	return objectURL.String()
+}
+
+func (s *S3Store) objectURL(key string) *url.URL {
+	objectURL := *s.bucket
+	objectURL.Path = s.bucket.Path + "/" + strings.TrimPrefix(key, "/")
+	objectURL.RawPath = awsURIEncode(objectURL.Path, false)
+	return &objectURL
+}
+
+func (s *S3Store) scope(now time.Time) string {
+	return now.Format("20060102") + "/" + s.opts.Region + "/s3/aws4_request"
+}
+
+// signature returns the signed headers and the Signature Version 4 of the request
+func (s *S3Store) signature(method string, objectURL *url.URL, query map[string]string, headers map[string]string, payloadHash string, now time.Time) (string, string) {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonicalRequest := strings.Join([]string{
+		method, awsURIEncode(objectURL.Path, false), canonicalQuery(query), canonicalHeaders.String(), signedHeaders, payloadHash,
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256", now.Format("20060102T150405Z"), s.scope(now), hex.EncodeToString(requestHash[:]),
+	}, "\n")
+	key := []byte("AWS4" + s.opts.SecretAccessKey)
+	for _, part := range []string{now.Format("20060102"), s.opts.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	return signedHeaders, hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery encodes the query parameters sorted by name, as Signature Version 4 requires
+func canonicalQuery(query map[string]string) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, awsURIEncode(name, true)+"="+awsURIEncode(query[name], true))
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsURIEncode percent encodes every byte but the unreserved characters, and slashes unless encodeSlash is set
+func awsURIEncode(value string, encodeSlash bool) string {
+	var buf strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			buf.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			buf.WriteByte(c)
+		default:
+			fmt.Fprintf(&buf, "%%%02X", c)
+		}
+	}
+	return buf.String()
+}
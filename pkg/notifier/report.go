@@ -0,0 +1,330 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Formats of PipelineRun reports
+const (
+	ReportFormatMarkdown = "markdown"
+	ReportFormatHTML     = "html"
+)
+
+// reportExtensions are the file extensions of the report formats
+var reportExtensions = map[string]string{
+	ReportFormatMarkdown: ".md",
+	ReportFormatHTML:     ".html",
+}
+
+var reportMediaTypes = map[string]string{
+	ReportFormatMarkdown: "text/markdown; charset=utf-8",
+	ReportFormatHTML:     "text/html; charset=utf-8",
+}
+
+// PipelineRunReport is the content of a PipelineRun report
+type PipelineRunReport struct {
+	*Notification
+	// Logs are the excerpts of the step logs included in the report
+	Logs []LogExcerpt
+	// GeneratedAt is when the report was rendered
+	GeneratedAt time.Time
+}
+
+// LogExcerpt is the last lines of the log of a step
+type LogExcerpt struct {
+	TaskRun string
+	Step    string
+	Lines   string
+}
+
+// LogSource provides the log excerpts of the TaskRuns of a notification
+type LogSource interface {
+	LogExcerpts(ctx context.Context, notification *Notification) ([]LogExcerpt, error)
+}
+
+// ReportStore persists rendered reports
+type ReportStore interface {
+	// StoreReport saves the report of a PipelineRun and returns where it can be found
+	StoreReport(ctx context.Context, namespace string, name string, file string, mediaType string, body []byte) (string, error)
+}
+
+// ReportOptions configures a ReportNotifier
+type ReportOptions struct {
+	// Format is the format of the reports: markdown (default) or html
+	Format string
+	// Store persists the rendered reports
+	Store ReportStore
+	// Logs provides the log excerpts included in the reports, if set
+	Logs LogSource
+}
+
+// ReportNotifier renders a report for every notification and stores it
+type ReportNotifier struct {
+	format   string
+	store    ReportStore
+	logs     LogSource
+	markdown *template.Template
+	html     *htmltemplate.Template
+}
+
+var reportTemplateFuncs = map[string]any{
+	"duration": formatDuration,
+	"time":     formatReportTime,
+	"fence":    markdownFence,
+	"cell":     markdownCell,
+}
+
+const markdownReportTemplate = `# PipelineRun {{ .Namespace }}/{{ .PipelineRun }}
+
+| | |
+|---|---|
+| Status | {{ .Status }} |
+{{- with .Team }}
+| Team | {{ . }} |
+{{- end }}
+{{- with .Author }}
+| Author | {{ .Name }} |
+{{- end }}
+| Started | {{ time .StartTime }} |
+| Completed | {{ time .CompletionTime }} |
+| Duration | {{ duration .DurationSeconds }} |
+{{- if .Tasks }}
+
+## Tasks
+
+| Task | TaskRun | Duration |
+|---|---|---|
+{{- range .Tasks }}
+| {{ .Name }} | {{ .TaskRun }} | {{ duration .DurationSeconds }} |
+{{- end }}
+{{- end }}
+{{- if .Results }}
+
+## Results
+
+| Name | Value |
+|---|---|
+{{- range .Results }}
+| {{ cell .Name }} | {{ cell .String }} |
+{{- end }}
+{{- end }}
+{{- if .Logs }}
+
+## Logs
+{{- range .Logs }}
+
+### {{ .TaskRun }} / {{ .Step }}
+
+{{ fence .Lines }}
+{{- end }}
+{{- end }}
+
+_Generated at {{ time .GeneratedAt }}_
+`
+
+const htmlReportTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>PipelineRun {{ .Namespace }}/{{ .PipelineRun }}</title>
+</head>
+<body>
+<h1>PipelineRun {{ .Namespace }}/{{ .PipelineRun }}</h1>
+<table>
+<tr><th>Status</th><td>{{ .Status }}</td></tr>
+{{- with .Team }}
+<tr><th>Team</th><td>{{ . }}</td></tr>
+{{- end }}
+{{- with .Author }}
+<tr><th>Author</th><td>{{ .Name }}</td></tr>
+{{- end }}
+<tr><th>Started</th><td>{{ time .StartTime }}</td></tr>
+<tr><th>Completed</th><td>{{ time .CompletionTime }}</td></tr>
+<tr><th>Duration</th><td>{{ duration .DurationSeconds }}</td></tr>
+</table>
+{{- if .Tasks }}
+<h2>Tasks</h2>
+<table>
+<tr><th>Task</th><th>TaskRun</th><th>Duration</th></tr>
+{{- range .Tasks }}
+<tr><td>{{ .Name }}</td><td>{{ .TaskRun }}</td><td>{{ duration .DurationSeconds }}</td></tr>
+{{- end }}
+</table>
+{{- end }}
+{{- if .Results }}
+<h2>Results</h2>
+<table>
+<tr><th>Name</th><th>Value</th></tr>
+{{- range .Results }}
+<tr><td>{{ .Name }}</td><td><code>{{ .String }}</code></td></tr>
+{{- end }}
+</table>
+{{- end }}
+{{- if .Logs }}
+<h2>Logs</h2>
+{{- range .Logs }}
+<h3>{{ .TaskRun }} / {{ .Step }}</h3>
+<pre>{{ .Lines }}</pre>
+{{- end }}
+{{- end }}
+<p><em>Generated at {{ time .GeneratedAt }}</em></p>
+</body>
+</html>
+`
+
+// NewReportNotifier creates a ReportNotifier from the given options
+func NewReportNotifier(opts ReportOptions) (*ReportNotifier, error) {
+	if opts.Store == nil {
+		return nil, errors.New("report store must be set")
+	}
+	if opts.Format == "" {
+		opts.Format = ReportFormatMarkdown
+	}
+	r := &ReportNotifier{format: opts.Format, store: opts.Store, logs: opts.Logs}
+	switch opts.Format {
+	case ReportFormatMarkdown:
+		r.markdown = template.Must(template.New("report").Funcs(reportTemplateFuncs).Parse(markdownReportTemplate))
+	case ReportFormatHTML:
+		r.html = htmltemplate.Must(htmltemplate.New("report").Funcs(reportTemplateFuncs).Parse(htmlReportTemplate))
+	default:
+		return nil, fmt.Errorf("Unsupported report format %s", opts.Format)
+	}
+	return r, nil
+}
+
+// Notify renders the report of the notification and stores it.
+// Failures to get the logs are not fatal, the report is stored without them.
+func (r *ReportNotifier) Notify(ctx context.Context, notification *Notification) error {
+	_, err := r.NotifyWithResponse(ctx, notification)
+	return err
+}
+
+// NotifyWithResponse renders the report of the notification and stores it.
+// The location of the stored report is returned as the response excerpt.
+func (r *ReportNotifier) NotifyWithResponse(ctx context.Context, notification *Notification) (*Response, error) {
+	report := &PipelineRunReport{Notification: notification, GeneratedAt: time.Now()}
+	var logsErr error
+	if r.logs != nil {
+		report.Logs, logsErr = r.logs.LogExcerpts(ctx, notification)
+	}
+	body, err := r.Render(report)
+	if err != nil {
+		return nil, err
+	}
+	location, err := r.store.StoreReport(ctx, notification.Namespace, notification.PipelineRun,
+		notification.PipelineRun+reportExtensions[r.format], reportMediaTypes[r.format], body)
+	if err != nil {
+		return nil, err
+	}
+	if logsErr != nil {
+		location += fmt.Sprintf(" (without logs: %s)", logsErr)
+	}
+	return &Response{Excerpt: location}, nil
+}
+
+// Render renders the report in the format of the notifier, and scans it and its logs with the PayloadScanner.
+// Rendering stops with an error wrapping ErrPayloadTooLarge once the report exceeds MaxRenderedBytes.
+func (r *ReportNotifier) Render(report *PipelineRunReport) ([]byte, error) {
+	buf := newBoundedBuffer()
+	var err error
+	if r.html != nil {
+		err = r.html.Execute(buf, report)
+	} else {
+		err = r.markdown.Execute(buf, report)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to render report for pipelinerun %s: %w", report.PipelineRun, err)
+	}
+	return ScanPayload(buf.Bytes(), report.Notification)
+}
+
+func formatReportTime(t any) string {
+	switch value := t.(type) {
+	case *time.Time:
+		if value == nil {
+			return "-"
+		}
+		return value.UTC().Format(time.RFC3339)
+	case time.Time:
+		return value.UTC().Format(time.RFC3339)
+	}
+	return "-"
+}
+
+// markdownFence wraps text in a code block, using a fence longer than any backtick run in it
+func markdownFence(text string) string {
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	return fence + "\n" + strings.TrimRight(text, "\n") + "\n" + fence
+}
+
+// markdownCell escapes text so it fits in a single table cell
+func markdownCell(text string) string {
+	return strings.NewReplacer("|", "\\|", "\r\n", " ", "\n", " ").Replace(text)
+}
+
+// HTTPReportStore uploads reports to object storage with HTTP PUT requests,
+// e.g. to a bucket that accepts uploads with a bearer token
+type HTTPReportStore struct {
+	// URL is the prefix of the uploaded objects, reports are uploaded to <URL>/<namespace>/<file>
+	URL string
+	// Token is sent as a bearer token, if set
+	Token string
+	// HTTPClient is used to send requests, defaults to a client with DefaultWebhookTimeout
+	HTTPClient *http.Client
+}
+
+// StoreReport uploads the report and returns its URL
+func (s *HTTPReportStore) StoreReport(ctx context.Context, namespace string, name string, file string, mediaType string, body []byte) (string, error) {
+	location := strings.TrimSuffix(s.URL, "/") + "/" + namespace + "/" + file
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, location, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("Failed to create report upload request: %w", err)
+	}
+	req.Header.Set("Content-Type", mediaType)
+	if s.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+	client := s.HTTPClient
+	if client == nil {
+		client = NewHTTPClient(DefaultWebhookTimeout)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("Failed to upload report for pipelinerun %s: %w", name, err)
+	}
+	defer resp.Body.Close()
+	excerpt, _ := io.ReadAll(io.LimitReader(resp.Body, MaxResponseExcerptBytes))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("Report upload for pipelinerun %s failed with status %d: %s",
+			name, resp.StatusCode, strings.TrimSpace(string(excerpt)))
+	}
+	return location, nil
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type memoryReportStore struct {
+	namespace string
+	file      string
+	mediaType string
+	body      string
+}
+
+func (s *memoryReportStore) StoreReport(_ context.Context, namespace string, name string, file string, mediaType string, body []byte) (string, error) {
+	s.namespace, s.file, s.mediaType, s.body = namespace, file, mediaType, string(body)
+	return "memory/" + file, nil
+}
+
+type staticLogSource struct {
+	excerpts []LogExcerpt
+	err      error
+}
+
+func (s *staticLogSource) LogExcerpts(context.Context, *Notification) ([]LogExcerpt, error) {
+	return s.excerpts, s.err
+}
+
+var _ = Describe("ReportNotifier", func() {
+	start := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	completion := start.Add(90 * time.Second)
+	notification := &Notification{
+		PipelineRun:     "build-1",
+		Namespace:       "tenant",
+		Status:          StatusFailed,
+		Author:          &Contact{Name: "jane"},
+		StartTime:       &start,
+		CompletionTime:  &completion,
+		DurationSeconds: 90,
+		Tasks:           []TaskTiming{{Name: "build", TaskRun: "build-1-build", DurationSeconds: 60}},
+		Results:         []Result{{Name: "IMAGE_URL", Value: "quay.io/test/image:<tag>|latest"}},
+	}
+	logs := &staticLogSource{excerpts: []LogExcerpt{{TaskRun: "build-1-build", Step: "step-push", Lines: "denied: <unauthorized>\n"}}}
+
+	It("should store markdown reports", func() {
+		store := &memoryReportStore{}
+		n, err := NewReportNotifier(ReportOptions{Store: store, Logs: logs})
+		Expect(err).NotTo(HaveOccurred())
+		response, err := Deliver(context.Background(), n, notification)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(response.Excerpt).To(Equal("memory/build-1.md"))
+		Expect(store.namespace).To(Equal("tenant"))
+		Expect(store.mediaType).To(HavePrefix("text/markdown"))
+		Expect(store.body).To(HavePrefix("# PipelineRun tenant/build-1\n"))
+		Expect(store.body).To(ContainSubstring("| Status | Failed |\n| Author | jane |\n| Started | 2024-05-01T09:00:00Z |"))
+		Expect(store.body).To(ContainSubstring("| Duration | 1m30s |"))
+		Expect(store.body).To(ContainSubstring("| build | build-1-build | 1m0s |"))
+		Expect(store.body).To(ContainSubstring(`| IMAGE_URL | quay.io/test/image:<tag>\|latest |`))
+		Expect(store.body).To(ContainSubstring("### build-1-build / step-push\n\n```\ndenied: <unauthorized>\n```"))
+	})
+
+	It("should escape HTML reports", func() {
+		store := &memoryReportStore{}
+		n, err := NewReportNotifier(ReportOptions{Format: ReportFormatHTML, Store: store, Logs: logs})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n.Notify(context.Background(), notification)).To(Succeed())
+		Expect(store.file).To(Equal("build-1.html"))
+		Expect(store.body).To(ContainSubstring("<code>quay.io/test/image:&lt;tag&gt;|latest</code>"))
+		Expect(store.body).To(ContainSubstring("<pre>denied: &lt;unauthorized&gt;\n</pre>"))
+	})
+
+	It("should store the report without logs when they are not available", func() {
+		store := &memoryReportStore{}
+		n, err := NewReportNotifier(ReportOptions{Store: store, Logs: &staticLogSource{err: errors.New("forbidden")}})
+		Expect(err).NotTo(HaveOccurred())
+		response, err := Deliver(context.Background(), n, notification)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(response.Excerpt).To(ContainSubstring("without logs: forbidden"))
+		Expect(store.body).NotTo(ContainSubstring("## Logs"))
+	})
+
+	It("should reject unknown formats", func() {
+		_, err := NewReportNotifier(ReportOptions{Format: "pdf", Store: &memoryReportStore{}})
+		Expect(err).To(HaveOccurred())
+	})
+
+	Context("with object storage", func() {
+		var (
+			server        *httptest.Server
+			method        string
+			path          string
+			authorization string
+			body          string
+			status        int
+		)
+
+		BeforeEach(func() {
+			status = http.StatusOK
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				method, path, authorization = r.Method, r.URL.Path, r.Header.Get("Authorization")
+				raw, err := io.ReadAll(r.Body)
+				Expect(err).NotTo(HaveOccurred())
+				body = string(raw)
+				w.WriteHeader(status)
+			}))
+			DeferCleanup(server.Close)
+		})
+
+		It("should upload reports", func() {
+			store := &HTTPReportStore{URL: server.URL + "/reports/", Token: "secret"}
+			location, err := store.StoreReport(context.Background(), "tenant", "build-1", "build-1.md", "text/markdown", []byte("# report"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(location).To(Equal(server.URL + "/reports/tenant/build-1.md"))
+			Expect(method).To(Equal(http.MethodPut))
+			Expect(path).To(Equal("/reports/tenant/build-1.md"))
+			Expect(authorization).To(Equal("Bearer secret"))
+			Expect(body).To(Equal("# report"))
+		})
+
+		It("should fail on non 2xx responses", func() {
+			status = http.StatusForbidden
+			store := &HTTPReportStore{URL: server.URL}
+			_, err := store.StoreReport(context.Background(), "tenant", "build-1", "build-1.md", "text/markdown", []byte("# report"))
+			Expect(err).To(MatchError(ContainSubstring("status 403")))
+		})
+	})
+})
@@ -0,0 +1,93 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Schema types supported by the Confluent Schema Registry
+const (
+	SchemaTypeAvro     = "AVRO"
+	SchemaTypeProtobuf = "PROTOBUF"
+)
+
+// SchemaRegistryClient is a minimal client of the Confluent Schema Registry REST API
+type SchemaRegistryClient struct {
+	// URL is the base URL of the schema registry
+	URL string
+	// Username and Password are optional basic auth credentials
+	Username string
+	Password string
+	// HTTPClient is used to send requests, defaults to a client without timeout sending with EgressTransport
+	HTTPClient *http.Client
+}
+
+type registerSchemaRequest struct {
+	Schema     string `json:"schema"`
+	SchemaType string `json:"schemaType,omitempty"`
+}
+
+type registerSchemaResponse struct {
+	ID int `json:"id"`
+}
+
+// Register registers the schema under the subject and returns its global ID.
+// Registering an already registered schema returns the existing ID, while a schema
+// that is incompatible with the subject's compatibility policy is rejected by the registry.
+func (c *SchemaRegistryClient) Register(ctx context.Context, subject string, schemaType string, schema string) (int, error) {
+	body, err := json.Marshal(registerSchemaRequest{Schema: schema, SchemaType: schemaType})
+	if err != nil {
+		return 0, fmt.Errorf("Failed to encode schema for subject %s: %w", subject, err)
+	}
+	endpoint := strings.TrimSuffix(c.URL, "/") + "/subjects/" + url.PathEscape(subject) + "/versions"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("Failed to create schema registry request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = NewHTTPClient(0)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to register schema for subject %s: %w", subject, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return 0, fmt.Errorf("Schema registry rejected schema for subject %s with status %d: %s",
+			subject, resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	registered := registerSchemaResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&registered); err != nil {
+		return 0, fmt.Errorf("Failed to decode schema registry response for subject %s: %w", subject, err)
+	}
+	return registered.ID, nil
+}
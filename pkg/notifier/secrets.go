@@ -0,0 +1,281 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Actions of a SecretScanner on the payloads it detects secrets in
+const (
+	// SecretActionRedact replaces the secrets with SecretRedaction and sends the payload
+	SecretActionRedact = "redact"
+	// SecretActionBlock does not send the payload
+	SecretActionBlock = "block"
+)
+
+// Defaults of the SecretScanner
+const (
+	// DefaultSecretMinEntropy is above the entropy of hexadecimal digests, which is at most 4 bits per character
+	DefaultSecretMinEntropy = 4.3
+	DefaultSecretMinLength  = 32
+)
+
+// SecretRedaction replaces the secrets detected in redacted payloads
+const SecretRedaction = "[REDACTED]"
+
+// HighEntropyRule is the rule of the secrets detected by their entropy rather than by a pattern
+const HighEntropyRule = "high-entropy"
+
+// ErrSecretDetected is returned when a payload is not sent because it contains a secret
+var ErrSecretDetected = errors.New("Secret detected in payload")
+
+// PayloadScanner scans the payloads rendered by all notifiers for secrets before they are sent.
+// It is nil, i.e. payloads are not scanned, unless it is set before the controller starts.
+var PayloadScanner *SecretScanner
+
+// knownSecretPatterns match the tokens of common services and private keys
+var knownSecretPatterns = map[string]string{
+	"aws-access-key": `\b(?:AKIA|ASIA)[A-Z0-9]{16}\b`,
+	"github-token":   `\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})`,
+	"gitlab-token":   `\bglpat-[A-Za-z0-9_-]{20,}`,
+	"google-api-key": `\bAIza[A-Za-z0-9_-]{35}`,
+	"jwt":            `\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`,
+	"private-key":    `-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?(?:-----END [A-Z ]*PRIVATE KEY-----|$)`,
+	"slack-token":    `\bxox[abposr]-[A-Za-z0-9-]{10,}`,
+}
+
+// entropyCandidate matches the words whose entropy is measured: runs of base64 and base64url characters
+// with their padding, apart from slashes so the segments of paths and URLs are measured separately
+var entropyCandidate = regexp.MustCompile(`[A-Za-z0-9+_-]+=*`)
+
+// SecretScannerOptions configures a SecretScanner
+type SecretScannerOptions struct {
+	// Action is SecretActionRedact (default) or SecretActionBlock
+	Action string
+	// Patterns are regular expressions of secrets by rule name, in addition to the tokens of common services
+	Patterns map[string]string
+	// MinEntropy is the Shannon entropy in bits per character above which words of at least MinLength
+	// characters are considered secrets. If negative, secrets are only detected by patterns.
+	MinEntropy float64
+	// MinLength is the length of the shortest words whose entropy is measured
+	MinLength int
+	// Observe is called with the rule of every payload a secret is detected in, blocked is true if it was not sent
+	Observe func(rule string, blocked bool)
+}
+
+// SecretScanner detects secrets in payloads, by the patterns of known tokens and by their entropy,
+// to keep tokens surfaced in results or logs from leaving the cluster
+type SecretScanner struct {
+	opts  SecretScannerOptions
+	rules []secretRule
+}
+
+type secretRule struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+// NewSecretScanner creates a SecretScanner from the given options
+// Return error if the action is not supported or a pattern is not a valid regular expression
+func NewSecretScanner(opts SecretScannerOptions) (*SecretScanner, error) {
+	if opts.Action == "" {
+		opts.Action = SecretActionRedact
+	}
+	if opts.Action != SecretActionRedact && opts.Action != SecretActionBlock {
+		return nil, fmt.Errorf("Unsupported secret scan action %s", opts.Action)
+	}
+	if opts.MinEntropy == 0 {
+		opts.MinEntropy = DefaultSecretMinEntropy
+	}
+	if opts.MinLength <= 0 {
+		opts.MinLength = DefaultSecretMinLength
+	}
+	patterns := map[string]string{}
+	for name, pattern := range knownSecretPatterns {
+		patterns[name] = pattern
+	}
+	for name, pattern := range opts.Patterns {
+		patterns[name] = pattern
+	}
+	s := &SecretScanner{opts: opts}
+	for name, pattern := range patterns {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid pattern of secret rule %s: %w", name, err)
+		}
+		s.rules = append(s.rules, secretRule{name: name, pattern: compiled})
+	}
+	sort.Slice(s.rules, func(i, j int) bool { return s.rules[i].name < s.rules[j].name })
+	return s, nil
+}
+
+// Scan returns the payload with the detected secrets replaced by SecretRedaction, and the rules that
+// detected them in alphabetical order. Words of the trusted values are not detected by their entropy.
+func (s *SecretScanner) Scan(payload []byte, trusted ...string) ([]byte, []string) {
+	var rules []string
+	for _, rule := range s.rules {
+		if rule.pattern.Match(payload) {
+			rules = append(rules, rule.name)
+			payload = rule.pattern.ReplaceAllLiteral(payload, []byte(SecretRedaction))
+		}
+	}
+	if s.opts.MinEntropy > 0 {
+		detected := false
+		payload = entropyCandidate.ReplaceAllFunc(payload, func(word []byte) []byte {
+			if len(word) < s.opts.MinLength || entropy(word) < s.opts.MinEntropy || isTrusted(string(word), trusted) {
+				return word
+			}
+			detected = true
+			return []byte(SecretRedaction)
+		})
+		if detected {
+			rules = append(rules, HighEntropyRule)
+			sort.Strings(rules)
+		}
+	}
+	return payload, rules
+}
+
+// act observes the rules that detected secrets in a payload about the pipelinerun
+// Return ErrSecretDetected if secrets were detected and the action is SecretActionBlock
+func (s *SecretScanner) act(rules []string, pipelineRun string) error {
+	blocked := s.opts.Action == SecretActionBlock
+	if s.opts.Observe != nil {
+		for _, rule := range rules {
+			s.opts.Observe(rule, blocked)
+		}
+	}
+	if blocked {
+		return fmt.Errorf("%w for pipelinerun %s: %s", ErrSecretDetected, pipelineRun, strings.Join(rules, ", "))
+	}
+	return nil
+}
+
+// ScanPayload scans a payload rendered from the notification with the PayloadScanner, if it is set,
+// and returns it with the detected secrets redacted. The callback and payload URLs the controller
+// issued for the notification are trusted.
+// Return ErrSecretDetected if the payload contains a secret and the scanner blocks such payloads
+func ScanPayload(payload []byte, notification *Notification) ([]byte, error) {
+	if PayloadScanner == nil {
+		return payload, nil
+	}
+	scanned, rules := PayloadScanner.Scan(payload, notification.CallbackURL, notification.PayloadURL)
+	if len(rules) == 0 {
+		return payload, nil
+	}
+	if err := PayloadScanner.act(rules, notification.PipelineRun); err != nil {
+		return nil, err
+	}
+	return scanned, nil
+}
+
+// ScanNotification scans the values of the notification with the PayloadScanner, if it is set, and returns
+// a copy with the detected secrets redacted. Notifiers build their default payloads from these values, so
+// they are scanned before the notification is sent, whichever the format of the payload.
+// Return ErrSecretDetected if the notification contains a secret and the scanner blocks such payloads
+func ScanNotification(notification *Notification) (*Notification, error) {
+	if PayloadScanner == nil {
+		return notification, nil
+	}
+	data, err := json.Marshal(notification)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to scan notification for pipelinerun %s: %w", notification.PipelineRun, err)
+	}
+	var value any
+	err = json.Unmarshal(data, &value)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to scan notification for pipelinerun %s: %w", notification.PipelineRun, err)
+	}
+	// Every string is scanned on its own, so redactions never span JSON syntax
+	detected := map[string]bool{}
+	value = walkStrings(value, func(text string) string {
+		scanned, rules := PayloadScanner.Scan([]byte(text), notification.CallbackURL, notification.PayloadURL)
+		for _, rule := range rules {
+			detected[rule] = true
+		}
+		return string(scanned)
+	})
+	if len(detected) == 0 {
+		return notification, nil
+	}
+	rules := make([]string, 0, len(detected))
+	for rule := range detected {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+	if err := PayloadScanner.act(rules, notification.PipelineRun); err != nil {
+		return nil, err
+	}
+	data, err = json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to scan notification for pipelinerun %s: %w", notification.PipelineRun, err)
+	}
+	scanned := &Notification{}
+	err = json.Unmarshal(data, scanned)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to scan notification for pipelinerun %s: %w", notification.PipelineRun, err)
+	}
+	return scanned, nil
+}
+
+// isTrusted returns a boolean indicating whether the word is part of one of the trusted values
+func isTrusted(word string, trusted []string) bool {
+	for _, value := range trusted {
+		if value != "" && strings.Contains(value, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// walkStrings replaces the strings of a decoded JSON value, apart from object keys, by their mapping
+func walkStrings(value any, mapping func(string) string) any {
+	switch v := value.(type) {
+	case string:
+		return mapping(v)
+	case []any:
+		for i := range v {
+			v[i] = walkStrings(v[i], mapping)
+		}
+	case map[string]any:
+		for key := range v {
+			v[key] = walkStrings(v[key], mapping)
+		}
+	}
+	return value
+}
+
+// entropy returns the Shannon entropy of the word in bits per character
+func entropy(word []byte) float64 {
+	counts := map[byte]int{}
+	for _, c := range word {
+		counts[c]++
+	}
+	var bits float64
+	for _, count := range counts {
+		p := float64(count) / float64(len(word))
+		bits -= p * math.Log2(p)
+	}
+	return bits
+}
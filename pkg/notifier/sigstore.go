@@ -0,0 +1,302 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Headers set on requests whose body is signed by a SigstoreSigner
+const (
+	// SignatureHeader holds the base64 encoded ECDSA signature of the request body
+	SignatureHeader = "X-Notification-Signature"
+	// CertificateHeader holds the base64 encoded PEM chain of the Fulcio signing certificate
+	CertificateHeader = "X-Notification-Certificate"
+	// BundleHeader holds the base64 encoded Rekor bundle of the signature, in the cosign format
+	BundleHeader = "X-Notification-Bundle"
+)
+
+// Public instances of the Sigstore services
+const (
+	DefaultFulcioURL = "https://fulcio.sigstore.dev"
+	DefaultRekorURL  = "https://rekor.sigstore.dev"
+)
+
+// PayloadSigner signs request bodies and returns the headers carrying the signature
+type PayloadSigner interface {
+	Sign(ctx context.Context, body []byte) (map[string]string, error)
+}
+
+// SigstoreOptions configures a SigstoreSigner
+type SigstoreOptions struct {
+	// FulcioURL is the certificate authority issuing the signing certificates
+	FulcioURL string
+	// RekorURL is the transparency log signatures are recorded in
+	RekorURL string
+	// TokenFile holds the OIDC identity token of the signer, e.g. a projected service account
+	// token with the sigstore audience. It is read again for every certificate.
+	TokenFile string
+	// HTTPClient is used to call Fulcio and Rekor, defaults to a client with DefaultWebhookTimeout
+	HTTPClient *http.Client
+}
+
+// SigstoreSigner signs request bodies keyless with cosign: an ephemeral key is certified by Fulcio
+// for the OIDC identity of the controller, and every signature is recorded in Rekor, so consumers
+// can verify the provenance of notifications without managing keys.
+type SigstoreSigner struct {
+	opts SigstoreOptions
+
+	mu          sync.Mutex
+	key         *ecdsa.PrivateKey
+	chain       []byte
+	certExpires time.Time
+}
+
+// NewSigstoreSigner creates a SigstoreSigner from the given options
+func NewSigstoreSigner(opts SigstoreOptions) (*SigstoreSigner, error) {
+	if opts.TokenFile == "" {
+		return nil, errors.New("sigstore token file must be set")
+	}
+	if opts.FulcioURL == "" {
+		opts.FulcioURL = DefaultFulcioURL
+	}
+	if opts.RekorURL == "" {
+		opts.RekorURL = DefaultRekorURL
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = NewHTTPClient(DefaultWebhookTimeout)
+	}
+	return &SigstoreSigner{opts: opts}, nil
+}
+
+// Sign signs the body with the current certificate, renewing it if it expires within a minute,
+// and records the signature in Rekor
+func (s *SigstoreSigner) Sign(ctx context.Context, body []byte) (map[string]string, error) {
+	key, chain, err := s.certificate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(body)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("Failed to sign payload: %w", err)
+	}
+	bundle, err := s.upload(ctx, digest[:], signature, chain)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		SignatureHeader:   base64.StdEncoding.EncodeToString(signature),
+		CertificateHeader: base64.StdEncoding.EncodeToString(chain),
+		BundleHeader:      base64.StdEncoding.EncodeToString(bundle),
+	}, nil
+}
+
+// certificate returns the signing key and its certificate chain, requesting a new certificate from Fulcio if needed
+func (s *SigstoreSigner) certificate(ctx context.Context) (*ecdsa.PrivateKey, []byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.key != nil && time.Until(s.certExpires) > time.Minute {
+		return s.key, s.chain, nil
+	}
+	token, err := os.ReadFile(s.opts.TokenFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to read sigstore token: %w", err)
+	}
+	subject, err := tokenSubject(strings.TrimSpace(string(token)))
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to generate signing key: %w", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to encode signing key: %w", err)
+	}
+	subjectDigest := sha256.Sum256([]byte(subject))
+	proof, err := ecdsa.SignASN1(rand.Reader, key, subjectDigest[:])
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to sign proof of possession: %w", err)
+	}
+	request := map[string]any{
+		"credentials": map[string]string{"oidcIdentityToken": strings.TrimSpace(string(token))},
+		"publicKeyRequest": map[string]any{
+			"publicKey": map[string]string{
+				"algorithm": "ECDSA",
+				"content":   string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+			},
+			"proofOfPossession": base64.StdEncoding.EncodeToString(proof),
+		},
+	}
+	response := struct {
+		Embedded *struct {
+			Chain struct {
+				Certificates []string `json:"certificates"`
+			} `json:"chain"`
+		} `json:"signedCertificateEmbeddedSct"`
+		Detached *struct {
+			Chain struct {
+				Certificates []string `json:"certificates"`
+			} `json:"chain"`
+		} `json:"signedCertificateDetachedSct"`
+	}{}
+	err = s.post(ctx, strings.TrimSuffix(s.opts.FulcioURL, "/")+"/api/v2/signingCert", request, &response)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to get signing certificate from Fulcio: %w", err)
+	}
+	var certificates []string
+	if response.Embedded != nil {
+		certificates = response.Embedded.Chain.Certificates
+	} else if response.Detached != nil {
+		certificates = response.Detached.Chain.Certificates
+	}
+	if len(certificates) == 0 {
+		return nil, nil, errors.New("Fulcio returned no signing certificate")
+	}
+	block, _ := pem.Decode([]byte(certificates[0]))
+	if block == nil {
+		return nil, nil, errors.New("Fulcio returned an invalid signing certificate")
+	}
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to parse signing certificate: %w", err)
+	}
+	s.key = key
+	s.chain = []byte(strings.Join(certificates, ""))
+	s.certExpires = certificate.NotAfter
+	return s.key, s.chain, nil
+}
+
+// upload records the signature in Rekor as a hashedrekord entry and returns its bundle
+func (s *SigstoreSigner) upload(ctx context.Context, digest []byte, signature []byte, chain []byte) ([]byte, error) {
+	leaf := chain
+	if block, _ := pem.Decode(chain); block != nil {
+		leaf = pem.EncodeToMemory(block)
+	}
+	entry := map[string]any{
+		"apiVersion": "0.0.1",
+		"kind":       "hashedrekord",
+		"spec": map[string]any{
+			"signature": map[string]any{
+				"content":   base64.StdEncoding.EncodeToString(signature),
+				"publicKey": map[string]string{"content": base64.StdEncoding.EncodeToString(leaf)},
+			},
+			"data": map[string]any{
+				"hash": map[string]string{"algorithm": "sha256", "value": hex.EncodeToString(digest)},
+			},
+		},
+	}
+	entries := map[string]struct {
+		Body           string `json:"body"`
+		IntegratedTime int64  `json:"integratedTime"`
+		LogID          string `json:"logID"`
+		LogIndex       int64  `json:"logIndex"`
+		Verification   struct {
+			SignedEntryTimestamp string `json:"signedEntryTimestamp"`
+		} `json:"verification"`
+	}{}
+	err := s.post(ctx, strings.TrimSuffix(s.opts.RekorURL, "/")+"/api/v1/log/entries", entry, &entries)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to record signature in Rekor: %w", err)
+	}
+	for _, logEntry := range entries {
+		bundle := map[string]any{
+			"SignedEntryTimestamp": logEntry.Verification.SignedEntryTimestamp,
+			"Payload": map[string]any{
+				"body":           logEntry.Body,
+				"integratedTime": logEntry.IntegratedTime,
+				"logIndex":       logEntry.LogIndex,
+				"logID":          logEntry.LogID,
+			},
+		}
+		return json.Marshal(bundle)
+	}
+	return nil, errors.New("Rekor returned no log entry")
+}
+
+func (s *SigstoreSigner) post(ctx context.Context, url string, request any, response any) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.opts.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(raw)))
+	}
+	return json.Unmarshal(raw, response)
+}
+
+// tokenSubject returns the subject of a JWT, which Fulcio expects the proof of possession to sign
+func tokenSubject(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", errors.New("sigstore token is not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("Failed to decode sigstore token: %w", err)
+	}
+	claims := struct {
+		Subject string `json:"sub"`
+		Email   string `json:"email"`
+	}{}
+	err = json.Unmarshal(payload, &claims)
+	if err != nil {
+		return "", fmt.Errorf("Failed to decode sigstore token: %w", err)
+	}
+	if claims.Email != "" {
+		return claims.Email, nil
+	}
+	if claims.Subject == "" {
+		return "", errors.New("sigstore token has no subject")
+	}
+	return claims.Subject, nil
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SigstoreSigner", func() {
+	var (
+		fulcio        *httptest.Server
+		rekor         *httptest.Server
+		tokenFile     string
+		certificates  int
+		rekorEntries  []map[string]any
+		receivedHead  http.Header
+		receivedBody  []byte
+		receiver      *httptest.Server
+		caKey         *ecdsa.PrivateKey
+		caCertificate *x509.Certificate
+	)
+
+	BeforeEach(func() {
+		certificates = 0
+		rekorEntries = nil
+		var err error
+		caKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		caTemplate := &x509.Certificate{
+			SerialNumber:          big.NewInt(1),
+			Subject:               pkix.Name{CommonName: "test-fulcio"},
+			NotBefore:             time.Now().Add(-time.Minute),
+			NotAfter:              time.Now().Add(time.Hour),
+			IsCA:                  true,
+			BasicConstraintsValid: true,
+			KeyUsage:              x509.KeyUsageCertSign,
+		}
+		caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+		Expect(err).NotTo(HaveOccurred())
+		caCertificate, err = x509.ParseCertificate(caDER)
+		Expect(err).NotTo(HaveOccurred())
+
+		fulcio = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			defer GinkgoRecover()
+			Expect(req.URL.Path).To(Equal("/api/v2/signingCert"))
+			request := struct {
+				PublicKeyRequest struct {
+					PublicKey struct {
+						Content string `json:"content"`
+					} `json:"publicKey"`
+					ProofOfPossession string `json:"proofOfPossession"`
+				} `json:"publicKeyRequest"`
+			}{}
+			Expect(json.NewDecoder(req.Body).Decode(&request)).To(Succeed())
+			block, _ := pem.Decode([]byte(request.PublicKeyRequest.PublicKey.Content))
+			publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+			Expect(err).NotTo(HaveOccurred())
+			proof, err := base64.StdEncoding.DecodeString(request.PublicKeyRequest.ProofOfPossession)
+			Expect(err).NotTo(HaveOccurred())
+			subject := sha256.Sum256([]byte("system:serviceaccount:notification-service:controller"))
+			Expect(ecdsa.VerifyASN1(publicKey.(*ecdsa.PublicKey), subject[:], proof)).To(BeTrue())
+			leafDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+				SerialNumber: big.NewInt(2),
+				NotBefore:    time.Now().Add(-time.Minute),
+				NotAfter:     time.Now().Add(10 * time.Minute),
+				KeyUsage:     x509.KeyUsageDigitalSignature,
+			}, caCertificate, publicKey, caKey)
+			Expect(err).NotTo(HaveOccurred())
+			certificates++
+			leaf := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER}))
+			root := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}))
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"signedCertificateEmbeddedSct": map[string]any{"chain": map[string]any{"certificates": []string{leaf, root}}},
+			})
+		}))
+		DeferCleanup(fulcio.Close)
+		rekor = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			defer GinkgoRecover()
+			Expect(req.URL.Path).To(Equal("/api/v1/log/entries"))
+			entry := map[string]any{}
+			Expect(json.NewDecoder(req.Body).Decode(&entry)).To(Succeed())
+			rekorEntries = append(rekorEntries, entry)
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"24296fb24b8ad77a": map[string]any{
+					"body":           "ZW50cnk=",
+					"integratedTime": 1700000000,
+					"logID":          "c0d23d6ad406973f",
+					"logIndex":       42,
+					"verification":   map[string]any{"signedEntryTimestamp": "c2V0"},
+				},
+			})
+		}))
+		DeferCleanup(rekor.Close)
+		receiver = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			receivedHead = req.Header.Clone()
+			receivedBody, _ = io.ReadAll(req.Body)
+		}))
+		DeferCleanup(receiver.Close)
+
+		claims := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"system:serviceaccount:notification-service:controller"}`))
+		tokenFile = filepath.Join(GinkgoT().TempDir(), "token")
+		Expect(os.WriteFile(tokenFile, []byte("eyJhbGciOiJSUzI1NiJ9."+claims+".c2ln\n"), 0o600)).To(Succeed())
+	})
+
+	It("should sign webhook bodies keyless and record them in Rekor", func() {
+		signer, err := NewSigstoreSigner(SigstoreOptions{FulcioURL: fulcio.URL, RekorURL: rekor.URL, TokenFile: tokenFile})
+		Expect(err).NotTo(HaveOccurred())
+		n, err := NewWebhookNotifier(WebhookOptions{URL: receiver.URL, Signer: signer})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n.Notify(context.Background(), &Notification{PipelineRun: "build-1", Namespace: "tenant"})).To(Succeed())
+		Expect(n.Notify(context.Background(), &Notification{PipelineRun: "build-2", Namespace: "tenant"})).To(Succeed())
+		Expect(certificates).To(Equal(1))
+		Expect(rekorEntries).To(HaveLen(2))
+
+		chain, err := base64.StdEncoding.DecodeString(receivedHead.Get(CertificateHeader))
+		Expect(err).NotTo(HaveOccurred())
+		block, _ := pem.Decode(chain)
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(certificate.CheckSignatureFrom(caCertificate)).To(Succeed())
+		signature, err := base64.StdEncoding.DecodeString(receivedHead.Get(SignatureHeader))
+		Expect(err).NotTo(HaveOccurred())
+		digest := sha256.Sum256(receivedBody)
+		Expect(ecdsa.VerifyASN1(certificate.PublicKey.(*ecdsa.PublicKey), digest[:], signature)).To(BeTrue())
+
+		bundle, err := base64.StdEncoding.DecodeString(receivedHead.Get(BundleHeader))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(bundle)).To(ContainSubstring(`"logIndex":42`))
+		Expect(string(bundle)).To(ContainSubstring(`"SignedEntryTimestamp":"c2V0"`))
+	})
+
+	It("should not send unsigned bodies when signing fails", func() {
+		Expect(os.WriteFile(tokenFile, []byte("not a token"), 0o600)).To(Succeed())
+		signer, err := NewSigstoreSigner(SigstoreOptions{FulcioURL: fulcio.URL, RekorURL: rekor.URL, TokenFile: tokenFile})
+		Expect(err).NotTo(HaveOccurred())
+		n, err := NewWebhookNotifier(WebhookOptions{URL: receiver.URL, Signer: signer})
+		Expect(err).NotTo(HaveOccurred())
+		receivedBody = nil
+		Expect(n.Notify(context.Background(), &Notification{PipelineRun: "build-1"})).NotTo(Succeed())
+		Expect(receivedBody).To(BeNil())
+	})
+})
@@ -0,0 +1,338 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// DefaultSlackAPIURL is the base URL of the Slack Web API
+const DefaultSlackAPIURL = "https://slack.com/api"
+
+// Ways subsequent notifications about a PipelineRun continue the first Slack message
+const (
+	// SlackThreadUpdate edits the first message with the latest status
+	SlackThreadUpdate = "update"
+	// SlackThreadReply posts every later status as a reply in the thread of the first message
+	SlackThreadReply = "reply"
+)
+
+// SlackOptions configures a SlackNotifier
+type SlackOptions struct {
+	// Token is the bot token used to post messages
+	Token string
+	// Channel is the ID or name of the channel messages are posted to
+	Channel string
+	// Template is an optional Go template rendering the message text
+	Template string
+	// ThreadMode is how later notifications continue the first message: update (default) or reply
+	ThreadMode string
+	// RerunButton adds a button to messages about finished PipelineRuns that creates a new
+	// PipelineRun with the same spec. Clicks are sent to the interactivity URL of the Slack app.
+	RerunButton bool
+	// Locale is the language of the built-in messages, see Locales. Defaults to DefaultLocale.
+	Locale string
+	// Styles override the emoji and color of statuses, see DefaultStatusStyles
+	Styles map[string]StatusStyle
+	// APIURL is the base URL of the Slack Web API, defaults to DefaultSlackAPIURL
+	APIURL string
+	// Timeout is the deadline of a single request
+	Timeout time.Duration
+	// HTTPClient is used to send requests, defaults to a client with Timeout
+	HTTPClient *http.Client
+}
+
+// SlackNotifier posts notifications to a Slack channel and keeps all notifications
+// about the same PipelineRun on a single message
+type SlackNotifier struct {
+	token       string
+	channel     string
+	template    *template.Template
+	threadMode  string
+	rerunButton bool
+	locale      *Locale
+	styles      map[string]StatusStyle
+	apiURL      string
+	client      *http.Client
+}
+
+// SlackRerunAction is the action ID of the re-run button.
+// Its value is the namespace and name of the PipelineRun, separated by a slash.
+const SlackRerunAction = "rerun"
+
+// NewSlackNotifier creates a SlackNotifier from the given options
+func NewSlackNotifier(opts SlackOptions) (*SlackNotifier, error) {
+	if opts.Token == "" {
+		return nil, errors.New("Slack token must be set")
+	}
+	if opts.Channel == "" {
+		return nil, errors.New("Slack channel must be set")
+	}
+	if opts.ThreadMode == "" {
+		opts.ThreadMode = SlackThreadUpdate
+	}
+	if opts.ThreadMode != SlackThreadUpdate && opts.ThreadMode != SlackThreadReply {
+		return nil, fmt.Errorf("Unsupported Slack thread mode %s", opts.ThreadMode)
+	}
+	locale, err := LookupLocale(opts.Locale)
+	if err != nil {
+		return nil, err
+	}
+	styles, err := mergeStatusStyles(opts.Styles)
+	if err != nil {
+		return nil, err
+	}
+	if opts.APIURL == "" {
+		opts.APIURL = DefaultSlackAPIURL
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultWebhookTimeout
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = NewHTTPClient(opts.Timeout)
+	}
+	s := &SlackNotifier{
+		token:       opts.Token,
+		channel:     opts.Channel,
+		threadMode:  opts.ThreadMode,
+		rerunButton: opts.RerunButton,
+		locale:      locale,
+		styles:      styles,
+		apiURL:      strings.TrimSuffix(opts.APIURL, "/"),
+		client:      opts.HTTPClient,
+	}
+	if opts.Template != "" {
+		tmpl, err := NewTemplate("slack", opts.Template)
+		if err != nil {
+			return nil, err
+		}
+		s.template = tmpl
+	}
+	return s, nil
+}
+
+// Notify posts the notification as a new Slack message
+func (s *SlackNotifier) Notify(ctx context.Context, notification *Notification) error {
+	_, err := s.NotifyInThread(ctx, notification, "")
+	return err
+}
+
+// NotifyInThread posts the first notification about a PipelineRun as a new message and continues
+// that message for later notifications, by editing it or replying in its thread.
+// The thread is the channel and timestamp of the first message, separated by a slash.
+func (s *SlackNotifier) NotifyInThread(ctx context.Context, notification *Notification, thread string) (string, error) {
+	text, err := s.text(notification)
+	if err != nil {
+		return "", err
+	}
+	message := s.message(notification, text)
+	channel, ts, ok := strings.Cut(thread, "/")
+	if !ok {
+		message.Channel = s.channel
+		response, err := s.call(ctx, "chat.postMessage", message)
+		if err != nil {
+			return "", fmt.Errorf("Failed to post Slack message for pipelinerun %s: %w", notification.PipelineRun, err)
+		}
+		return response.Channel + "/" + response.TS, nil
+	}
+	message.Channel = channel
+	if s.threadMode == SlackThreadReply {
+		message.ThreadTS = ts
+		_, err = s.call(ctx, "chat.postMessage", message)
+	} else {
+		message.TS = ts
+		_, err = s.call(ctx, "chat.update", message)
+	}
+	if err != nil {
+		return "", fmt.Errorf("Failed to continue Slack message for pipelinerun %s: %w", notification.PipelineRun, err)
+	}
+	return thread, nil
+}
+
+func (s *SlackNotifier) text(notification *Notification) (string, error) {
+	if s.template != nil {
+		text, err := Render(s.template, notification)
+		return string(text), err
+	}
+	var buf strings.Builder
+	status := notificationStatus(notification)
+	fmt.Fprintf(&buf, "%s %s", s.styles[status].Emoji,
+		s.locale.status(notification, "`"+notification.Namespace+"/"+notification.PipelineRun+"`"))
+	if status == StatusFailed && notification.Author != nil {
+		fmt.Fprintf(&buf, " cc %s", notification.Author.Mention())
+	}
+	if policy := notification.Policy; policy != nil && policy.Outcome != PolicyPassed {
+		buf.WriteString("\n:scales: ")
+		fmt.Fprintf(&buf, s.locale.Policy, s.locale.PolicyOutcomes[policy.Outcome], policy.Violations, policy.Warnings)
+		if len(policy.Rules) > 0 {
+			fmt.Fprintf(&buf, " (`%s`)", strings.Join(policy.Rules, "`, `"))
+		}
+	}
+	for _, result := range notification.Results {
+		fmt.Fprintf(&buf, "\n• *%s*: `%s`", result.Name, result.String())
+	}
+	if notification.PayloadURL != "" {
+		fmt.Fprintf(&buf, "\n<%s|%s>", notification.PayloadURL, s.locale.FullResults)
+	}
+	return buf.String(), nil
+}
+
+// Probe checks the token of the notifier with the auth.test method of the Slack Web API
+func (s *SlackNotifier) Probe(ctx context.Context) error {
+	_, err := s.call(ctx, "auth.test", slackMessage{})
+	return err
+}
+
+// NotifySummary posts the summary as a new Slack message
+func (s *SlackNotifier) NotifySummary(ctx context.Context, summary *Summary) error {
+	var buf strings.Builder
+	buf.WriteString(":bar_chart: " + s.locale.summary(summary, "`"+summary.Namespace+"`"))
+	if len(summary.Slowest) > 0 {
+		buf.WriteString("\n*" + s.locale.Slowest + "*")
+		for _, stats := range summary.Slowest {
+			fmt.Fprintf(&buf, "\n• `%s`: %s", stats.Pipeline, fmt.Sprintf(s.locale.AverageDuration, formatDuration(stats.AverageDurationSeconds)))
+		}
+	}
+	if len(summary.TopFailures) > 0 {
+		buf.WriteString("\n*" + s.locale.TopFailures + "*")
+		for _, stats := range summary.TopFailures {
+			fmt.Fprintf(&buf, "\n• `%s`: %s", stats.Pipeline, fmt.Sprintf(s.locale.Failures, stats.Failures, stats.Runs))
+		}
+	}
+	_, err := s.call(ctx, "chat.postMessage", slackMessage{Channel: s.channel, Text: buf.String()})
+	if err != nil {
+		return fmt.Errorf("Failed to post Slack summary for namespace %s: %w", summary.Namespace, err)
+	}
+	return nil
+}
+
+// message returns the content of the message: its text, laid out in blocks if needed, in an attachment
+// with the color bar of the status of the notification if the status has a color
+func (s *SlackNotifier) message(notification *Notification, text string) slackMessage {
+	message := slackMessage{Text: text, Blocks: s.blocks(notification, text)}
+	color := s.styles[notificationStatus(notification)].Color
+	if color == "" {
+		return message
+	}
+	// The text of messages with attachments is displayed above them, it is only kept as their fallback
+	blocks := message.Blocks
+	if blocks == nil {
+		blocks = []slackBlock{{Type: "section", Text: &slackText{Type: "mrkdwn", Text: text}}}
+	}
+	return slackMessage{Attachments: []slackAttachment{{Color: color, Fallback: text, Blocks: blocks}}}
+}
+
+// blocks lays the message out with a re-run button for finished PipelineRuns, if enabled.
+// Messages without blocks are rendered from their text.
+func (s *SlackNotifier) blocks(notification *Notification, text string) []slackBlock {
+	if !s.rerunButton || notification.Status == StatusStarted || notification.Status == StatusRunning {
+		return nil
+	}
+	return []slackBlock{
+		{Type: "section", Text: &slackText{Type: "mrkdwn", Text: text}},
+		{Type: "actions", Elements: []slackElement{{
+			Type:     "button",
+			ActionID: SlackRerunAction,
+			Text:     slackText{Type: "plain_text", Text: s.locale.Rerun},
+			Value:    notification.Namespace + "/" + notification.PipelineRun,
+		}}},
+	}
+}
+
+// slackMessage is the request of the chat.postMessage and chat.update methods
+type slackMessage struct {
+	Channel     string            `json:"channel"`
+	Text        string            `json:"text"`
+	Blocks      []slackBlock      `json:"blocks,omitempty"`
+	Attachments []slackAttachment `json:"attachments,omitempty"`
+	TS          string            `json:"ts,omitempty"`
+	ThreadTS    string            `json:"thread_ts,omitempty"`
+}
+
+// slackAttachment is a secondary part of a message, displayed with a color bar
+type slackAttachment struct {
+	Color    string       `json:"color"`
+	Fallback string       `json:"fallback"`
+	Blocks   []slackBlock `json:"blocks"`
+}
+
+// slackBlock is a Block Kit layout block
+type slackBlock struct {
+	Type     string         `json:"type"`
+	Text     *slackText     `json:"text,omitempty"`
+	Elements []slackElement `json:"elements,omitempty"`
+}
+
+// slackElement is an interactive Block Kit element
+type slackElement struct {
+	Type     string    `json:"type"`
+	ActionID string    `json:"action_id"`
+	Text     slackText `json:"text"`
+	Value    string    `json:"value"`
+}
+
+// slackText is a Block Kit text object
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// slackResponse is the common part of Slack Web API responses
+type slackResponse struct {
+	OK      bool   `json:"ok"`
+	Error   string `json:"error"`
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+}
+
+// call invokes a Slack Web API method. Slack reports most failures in the body of 200 responses.
+func (s *SlackNotifier) call(ctx context.Context, method string, message slackMessage) (*slackResponse, error) {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.apiURL+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("%s failed with status %d", method, resp.StatusCode)
+	}
+	response := &slackResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return nil, fmt.Errorf("Failed to decode %s response: %w", method, err)
+	}
+	if !response.OK {
+		return nil, fmt.Errorf("%s failed: %s", method, response.Error)
+	}
+	return response, nil
+}
@@ -0,0 +1,208 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// slackCall is a request received by the fake Slack API
+type slackCall struct {
+	method        string
+	authorization string
+	message       slackMessage
+}
+
+var _ = Describe("SlackNotifier", func() {
+	var (
+		server   *httptest.Server
+		calls    []slackCall
+		response string
+	)
+
+	started := &Notification{PipelineRun: "build-1", Namespace: "tenant", Status: StatusStarted}
+	succeeded := &Notification{
+		PipelineRun: "build-1",
+		Namespace:   "tenant",
+		Status:      StatusSucceeded,
+		Results:     []Result{{Name: "IMAGE_URL", Value: "quay.io/test/image"}},
+	}
+
+	newNotifier := func(opts SlackOptions) *SlackNotifier {
+		opts.APIURL = server.URL
+		opts.Token = "xoxb-test"
+		opts.Channel = "#builds"
+		n, err := NewSlackNotifier(opts)
+		Expect(err).NotTo(HaveOccurred())
+		return n
+	}
+
+	BeforeEach(func() {
+		calls = nil
+		response = `{"ok":true,"channel":"C123","ts":"1700000000.000100"}`
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			call := slackCall{method: r.URL.Path, authorization: r.Header.Get("Authorization")}
+			Expect(json.NewDecoder(r.Body).Decode(&call.message)).To(Succeed())
+			calls = append(calls, call)
+			_, _ = w.Write([]byte(response))
+		}))
+		DeferCleanup(server.Close)
+	})
+
+	It("should post the first notification as a new message", func() {
+		thread, err := newNotifier(SlackOptions{}).NotifyInThread(context.Background(), started, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(thread).To(Equal("C123/1700000000.000100"))
+		Expect(calls).To(HaveLen(1))
+		Expect(calls[0].method).To(Equal("/chat.postMessage"))
+		Expect(calls[0].authorization).To(Equal("Bearer xoxb-test"))
+		Expect(calls[0].message.Channel).To(Equal("#builds"))
+		Expect(calls[0].message.Text).To(Equal(":hourglass_flowing_sand: PipelineRun `tenant/build-1` started"))
+	})
+
+	It("should post the built-in message in the locale of the notifier", func() {
+		err := newNotifier(SlackOptions{Locale: "de"}).Notify(context.Background(), succeeded)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(calls).To(HaveLen(1))
+		Expect(calls[0].message.Text).To(HavePrefix(":white_check_mark: PipelineRun `tenant/build-1` erfolgreich"))
+	})
+
+	It("should decorate messages with the configured status styles", func() {
+		styles := map[string]StatusStyle{StatusSucceeded: {Emoji: ":rocket:", Color: "#2eb886"}}
+		err := newNotifier(SlackOptions{Styles: styles}).Notify(context.Background(), succeeded)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(calls).To(HaveLen(1))
+		Expect(calls[0].message.Text).To(BeEmpty())
+		Expect(calls[0].message.Attachments).To(HaveLen(1))
+		attachment := calls[0].message.Attachments[0]
+		Expect(attachment.Color).To(Equal("#2eb886"))
+		Expect(attachment.Fallback).To(HavePrefix(":rocket: PipelineRun `tenant/build-1` succeeded"))
+		Expect(attachment.Blocks).To(HaveLen(1))
+		Expect(attachment.Blocks[0].Text.Text).To(Equal(attachment.Fallback))
+	})
+
+	It("should keep the default style of statuses without color", func() {
+		styles := map[string]StatusStyle{StatusFailed: {Color: "#e01e5a"}}
+		err := newNotifier(SlackOptions{Styles: styles}).Notify(context.Background(), succeeded)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(calls[0].message.Attachments).To(BeEmpty())
+		Expect(calls[0].message.Text).To(HavePrefix(":white_check_mark: PipelineRun"))
+	})
+
+	It("should reject invalid status styles", func() {
+		_, err := NewSlackNotifier(SlackOptions{Token: "xoxb-test", Channel: "#builds",
+			Styles: map[string]StatusStyle{StatusFailed: {Color: "red"}}})
+		Expect(err).To(MatchError("Invalid color red of status Failed, expected a hex color such as #e01e5a"))
+		_, err = NewSlackNotifier(SlackOptions{Token: "xoxb-test", Channel: "#builds",
+			Styles: map[string]StatusStyle{"cancelled": {Emoji: ":no_entry:"}}})
+		Expect(err).To(MatchError(ContainSubstring("Unknown status cancelled")))
+	})
+
+	It("should probe the token with auth.test", func() {
+		Expect(newNotifier(SlackOptions{}).Probe(context.Background())).To(Succeed())
+		Expect(calls[0].method).To(Equal("/auth.test"))
+		Expect(calls[0].authorization).To(Equal("Bearer xoxb-test"))
+		response = `{"ok":false,"error":"invalid_auth"}`
+		Expect(newNotifier(SlackOptions{}).Probe(context.Background())).To(MatchError(ContainSubstring("invalid_auth")))
+	})
+
+	It("should update the first message with later statuses", func() {
+		thread, err := newNotifier(SlackOptions{}).NotifyInThread(context.Background(), succeeded, "C123/1700000000.000100")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(thread).To(Equal("C123/1700000000.000100"))
+		Expect(calls).To(HaveLen(1))
+		Expect(calls[0].method).To(Equal("/chat.update"))
+		Expect(calls[0].message).To(Equal(slackMessage{
+			Channel: "C123",
+			TS:      "1700000000.000100",
+			Text:    ":white_check_mark: PipelineRun `tenant/build-1` succeeded\n• *IMAGE_URL*: `quay.io/test/image`",
+		}))
+	})
+
+	It("should reply in the thread of the first message", func() {
+		n := newNotifier(SlackOptions{ThreadMode: SlackThreadReply, Template: "{{.PipelineRun}} is {{.Status}}"})
+		_, err := n.NotifyInThread(context.Background(), succeeded, "C123/1700000000.000100")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(calls).To(HaveLen(1))
+		Expect(calls[0].method).To(Equal("/chat.postMessage"))
+		Expect(calls[0].message).To(Equal(slackMessage{
+			Channel:  "C123",
+			ThreadTS: "1700000000.000100",
+			Text:     "build-1 is Succeeded",
+		}))
+	})
+
+	It("should add a re-run button to messages about finished pipelineruns", func() {
+		n := newNotifier(SlackOptions{RerunButton: true})
+		Expect(n.Notify(context.Background(), started)).To(Succeed())
+		Expect(calls[0].message.Blocks).To(BeEmpty())
+
+		Expect(n.Notify(context.Background(), succeeded)).To(Succeed())
+		Expect(calls[1].message.Blocks).To(HaveLen(2))
+		Expect(calls[1].message.Blocks[1].Elements).To(Equal([]slackElement{{
+			Type:     "button",
+			ActionID: SlackRerunAction,
+			Text:     slackText{Type: "plain_text", Text: "Re-run"},
+			Value:    "tenant/build-1",
+		}}))
+	})
+
+	It("should mention the author of failed pipelineruns", func() {
+		failed := &Notification{
+			PipelineRun: "build-1",
+			Namespace:   "tenant",
+			Status:      StatusFailed,
+			Author:      &Contact{Name: "jane", Slack: "U123"},
+		}
+		Expect(newNotifier(SlackOptions{}).Notify(context.Background(), failed)).To(Succeed())
+		Expect(calls[0].message.Text).To(Equal(":x: PipelineRun `tenant/build-1` failed cc <@U123>"))
+	})
+
+	It("should post summaries", func() {
+		summary := &Summary{
+			Namespace:   "tenant",
+			From:        time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC),
+			To:          time.Date(2024, 5, 8, 9, 0, 0, 0, time.UTC),
+			Total:       4,
+			Succeeded:   3,
+			SuccessRate: 0.75,
+			Slowest:     []PipelineStats{{Pipeline: "build", Runs: 4, Failures: 1, AverageDurationSeconds: 600}},
+			TopFailures: []PipelineStats{{Pipeline: "build", Runs: 4, Failures: 1, AverageDurationSeconds: 600}},
+		}
+		Expect(newNotifier(SlackOptions{}).NotifySummary(context.Background(), summary)).To(Succeed())
+		Expect(calls[0].message.Text).To(Equal(":bar_chart: Pipelines of `tenant` from 2024-05-01 to 2024-05-08: 4 runs, 75% succeeded" +
+			"\n*Slowest pipelines*\n• `build`: 10m0s on average\n*Top failures*\n• `build`: 1 of 4 runs failed"))
+	})
+
+	It("should report errors returned by the Slack API", func() {
+		response = `{"ok":false,"error":"channel_not_found"}`
+		err := newNotifier(SlackOptions{}).Notify(context.Background(), started)
+		Expect(err).To(MatchError(ContainSubstring("channel_not_found")))
+	})
+
+	It("should reject unknown thread modes", func() {
+		_, err := NewSlackNotifier(SlackOptions{Token: "xoxb-test", Channel: "#builds", ThreadMode: "fork"})
+		Expect(err).To(HaveOccurred())
+	})
+})
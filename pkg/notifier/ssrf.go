@@ -0,0 +1,216 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultBlockedNetworks are the networks tenant destinations may not connect to: the unspecified,
+// loopback and link-local addresses, which include the metadata services of cloud providers, the private
+// and shared address ranges of cluster pods and services, and their IPv6 equivalents
+var DefaultBlockedNetworks = []string{
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"::/128",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+}
+
+// ErrAddressBlocked is returned when connecting to a host that resolves to an address an AddressGuard blocks
+var ErrAddressBlocked = errors.New("Address is blocked")
+
+// TenantGuard restricts the addresses the destinations tenants declare in NotificationServices connect to.
+// It is nil, i.e. tenant destinations connect like the other destinations, unless it is set with
+// InstallTenantGuard before the controller starts.
+var TenantGuard *AddressGuard
+
+// tenantTransport sends the HTTP requests of tenant destinations when the TenantGuard is set
+var tenantTransport http.RoundTripper
+
+// AddressGuard blocks connections to hosts that are or resolve to addresses in its blocked networks,
+// unless they are allowed by its exceptions. Hosts are resolved again on every connection, which is then
+// made to the checked addresses, so a host cannot pass the check and rebind to a blocked address.
+type AddressGuard struct {
+	// Lookup resolves host names, defaults to the Go resolver
+	Lookup func(ctx context.Context, host string) ([]net.IPAddr, error)
+
+	blocked    []*net.IPNet
+	exceptions *HostAllowlist
+}
+
+// NewAddressGuard creates an AddressGuard blocking the networks, IP addresses or CIDRs, apart from the
+// domains and networks of the exceptions, which may be nil
+// Return error if a blocked network is invalid
+func NewAddressGuard(blocked []string, exceptions *HostAllowlist) (*AddressGuard, error) {
+	g := &AddressGuard{exceptions: exceptions}
+	for _, entry := range blocked {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			g.blocked = append(g.blocked, network)
+			continue
+		}
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			return nil, fmt.Errorf("Invalid blocked network %s: must be an IP address or a CIDR", entry)
+		}
+		g.blocked = append(g.blocked, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+	}
+	return g, nil
+}
+
+// Check returns an error wrapping ErrAddressBlocked if the host is or resolves to a blocked address
+func (g *AddressGuard) Check(ctx context.Context, host string) error {
+	_, err := g.addresses(ctx, host)
+	return err
+}
+
+// DialContext returns a dial function connecting with dial to hosts whose addresses are not blocked.
+// Hosts are connected to at the addresses they resolved to when they were checked.
+func (g *AddressGuard) DialContext(dial func(ctx context.Context, network string, address string) (net.Conn, error)) func(
+	ctx context.Context, network string, address string) (net.Conn, error) {
+	return func(ctx context.Context, network string, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		addrs, err := g.addresses(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		if addrs == nil {
+			return dial(ctx, network, address)
+		}
+		var errs []error
+		for _, addr := range addrs {
+			conn, err := dial(ctx, network, net.JoinHostPort(addr.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			errs = append(errs, err)
+		}
+		return nil, errors.Join(errs...)
+	}
+}
+
+// addresses returns the addresses of the host, or nil if it is an exception by its domain
+// Return error wrapping ErrAddressBlocked if the host does not resolve or any of its addresses is blocked
+func (g *AddressGuard) addresses(ctx context.Context, host string) ([]net.IP, error) {
+	name := strings.ToLower(strings.TrimSuffix(host, "."))
+	if ip := net.ParseIP(strings.Trim(name, "[]")); ip != nil {
+		if g.blocks(ip) {
+			return nil, fmt.Errorf("%w: %s", ErrAddressBlocked, host)
+		}
+		return []net.IP{ip}, nil
+	}
+	if g.exceptions != nil && g.exceptions.allowsDomain(name) {
+		return nil, nil
+	}
+	lookup := g.Lookup
+	if lookup == nil {
+		lookup = net.DefaultResolver.LookupIPAddr
+	}
+	resolved, err := lookup(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s does not resolve: %w", ErrAddressBlocked, host, err)
+	}
+	addrs := make([]net.IP, 0, len(resolved))
+	for _, addr := range resolved {
+		if g.blocks(addr.IP) {
+			return nil, fmt.Errorf("%w: %s resolves to %s", ErrAddressBlocked, host, addr.IP)
+		}
+		addrs = append(addrs, addr.IP)
+	}
+	return addrs, nil
+}
+
+// blocks returns whether the address is in a blocked network and not in the networks of the exceptions
+func (g *AddressGuard) blocks(ip net.IP) bool {
+	if g.exceptions != nil && g.exceptions.allowsIP(ip) {
+		return false
+	}
+	for _, network := range g.blocked {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// InstallTenantGuard sets the TenantGuard and the transport of tenant destinations. Their requests are
+// relayed with EgressTransport if it is a GatewayTransport, once the guard checked the host of their URL,
+// and are otherwise sent with a clone of base dialing through the guard and the EgressAllowlist, so they
+// never share connections with the requests of the other destinations.
+// It must be called after EgressTransport and EgressAllowlist are set.
+func InstallTenantGuard(guard *AddressGuard, base *http.Transport) {
+	TenantGuard = guard
+	if gateway, ok := EgressTransport.(*GatewayTransport); ok {
+		tenantTransport = &guardedTransport{guard: guard, base: gateway}
+		return
+	}
+	transport := base.Clone()
+	dial := transport.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	transport.DialContext = guard.DialContext(dial)
+	if EgressAllowlist != nil {
+		transport.DialContext = EgressAllowlist.DialContext(transport.DialContext)
+	}
+	tenantTransport = transport
+}
+
+// NewTenantHTTPClient returns a client with the timeout for the destinations of tenants, or nil if the
+// TenantGuard is not set, so notifiers default to a client sending with EgressTransport
+func NewTenantHTTPClient(timeout time.Duration) *http.Client {
+	if TenantGuard == nil {
+		return nil
+	}
+	return &http.Client{Timeout: timeout, Transport: tenantTransport}
+}
+
+// guardedTransport checks the host of the URL of requests with the guard before relaying them to the
+// egress gateway, which resolves the host itself
+type guardedTransport struct {
+	guard *AddressGuard
+	base  http.RoundTripper
+}
+
+func (t *guardedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.guard.Check(req.Context(), req.URL.Hostname()); err != nil {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
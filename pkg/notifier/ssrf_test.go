@@ -0,0 +1,105 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AddressGuard", func() {
+	It("should block internal addresses apart from the exceptions", func() {
+		exceptions, err := ParseHostAllowlist([]string{"*.svc", "10.96.0.10"})
+		Expect(err).NotTo(HaveOccurred())
+		guard, err := NewAddressGuard(DefaultBlockedNetworks, exceptions)
+		Expect(err).NotTo(HaveOccurred())
+		guard.Lookup = func(_ context.Context, host string) ([]net.IPAddr, error) {
+			switch host {
+			case "metadata.example.org":
+				return []net.IPAddr{{IP: net.ParseIP("203.0.113.7")}, {IP: net.ParseIP("169.254.169.254")}}, nil
+			case "public.example.org":
+				return []net.IPAddr{{IP: net.ParseIP("203.0.113.8")}}, nil
+			}
+			return nil, errors.New("no such host")
+		}
+		for _, host := range []string{"127.0.0.1", "169.254.169.254", "10.1.2.3", "172.20.0.1", "192.168.1.1", "100.64.0.1",
+			"0.0.0.0", "::1", "[::1]", "fd00:ec2::254", "fe80::1", "::ffff:127.0.0.1", "metadata.example.org", "unknown.example.org"} {
+			Expect(guard.Check(context.Background(), host)).To(MatchError(ErrAddressBlocked), host)
+		}
+		for _, host := range []string{"public.example.org", "203.0.113.9", "hooks.builds.svc", "10.96.0.10"} {
+			Expect(guard.Check(context.Background(), host)).To(Succeed(), host)
+		}
+	})
+
+	It("should reject invalid blocked networks", func() {
+		_, err := NewAddressGuard([]string{"10.0.0.0/8", "internal"}, nil)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should resolve hosts again on every dial", func() {
+		guard, err := NewAddressGuard(DefaultBlockedNetworks, nil)
+		Expect(err).NotTo(HaveOccurred())
+		resolved := []string{"203.0.113.7", "127.0.0.1"}
+		guard.Lookup = func(context.Context, string) ([]net.IPAddr, error) {
+			ip := net.ParseIP(resolved[0])
+			resolved = resolved[1:]
+			return []net.IPAddr{{IP: ip}}, nil
+		}
+		var dialed []string
+		dial := guard.DialContext(func(_ context.Context, _ string, address string) (net.Conn, error) {
+			dialed = append(dialed, address)
+			return nil, errors.New("refused")
+		})
+		_, err = dial(context.Background(), "tcp", "rebind.example.org:443")
+		Expect(err).To(MatchError("refused"))
+		_, err = dial(context.Background(), "tcp", "rebind.example.org:443")
+		Expect(err).To(MatchError(ErrAddressBlocked))
+		Expect(dialed).To(Equal([]string{"203.0.113.7:443"}))
+	})
+
+	It("should only restrict the HTTP clients of tenant destinations", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+		defer server.Close()
+		previousGuard, previousTransport := TenantGuard, tenantTransport
+		DeferCleanup(func() { TenantGuard, tenantTransport = previousGuard, previousTransport })
+		Expect(NewTenantHTTPClient(DefaultWebhookTimeout)).To(BeNil())
+
+		guard, err := NewAddressGuard(DefaultBlockedNetworks, nil)
+		Expect(err).NotTo(HaveOccurred())
+		InstallTenantGuard(guard, http.DefaultTransport.(*http.Transport))
+		_, err = NewTenantHTTPClient(DefaultWebhookTimeout).Get(server.URL)
+		Expect(err).To(MatchError(ErrAddressBlocked))
+		response, err := NewHTTPClient(DefaultWebhookTimeout).Get(server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(response.Body.Close()).To(Succeed())
+
+		exceptions, err := ParseHostAllowlist([]string{"127.0.0.1"})
+		Expect(err).NotTo(HaveOccurred())
+		guard, err = NewAddressGuard(DefaultBlockedNetworks, exceptions)
+		Expect(err).NotTo(HaveOccurred())
+		InstallTenantGuard(guard, http.DefaultTransport.(*http.Transport))
+		response, err = NewTenantHTTPClient(DefaultWebhookTimeout).Get(server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(response.Body.Close()).To(Succeed())
+	})
+})
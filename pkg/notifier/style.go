@@ -0,0 +1,88 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// StatusStyle is how chat backends decorate the messages about PipelineRuns of a status
+type StatusStyle struct {
+	// Emoji is the Slack emoji code prefixing Slack messages, e.g. :x:
+	Emoji string `json:"emoji,omitempty"`
+	// Icon is the text prefixing Matrix messages, e.g. ❌
+	Icon string `json:"icon,omitempty"`
+	// Color is the hex color of the bar of Slack messages and of the status of Matrix messages, e.g. #e01e5a.
+	// Messages have no color unless it is set.
+	Color string `json:"color,omitempty"`
+}
+
+// DefaultStatusStyles are the styles of the statuses, unless they are overridden
+var DefaultStatusStyles = map[string]StatusStyle{
+	StatusStarted:   {Emoji: ":hourglass_flowing_sand:", Icon: "⏳"},
+	StatusRunning:   {Emoji: ":warning:", Icon: "⚠️"},
+	StatusSucceeded: {Emoji: ":white_check_mark:", Icon: "✅"},
+	StatusFailed:    {Emoji: ":x:", Icon: "❌"},
+}
+
+var hexColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// ValidateStatusStyles checks that the styles override known statuses with hex colors
+func ValidateStatusStyles(styles map[string]StatusStyle) error {
+	for status, style := range styles {
+		if _, ok := DefaultStatusStyles[status]; !ok {
+			return fmt.Errorf("Unknown status %s, expected one of %s, %s, %s or %s",
+				status, StatusStarted, StatusRunning, StatusSucceeded, StatusFailed)
+		}
+		if style.Color != "" && !hexColor.MatchString(style.Color) {
+			return fmt.Errorf("Invalid color %s of status %s, expected a hex color such as #e01e5a", style.Color, status)
+		}
+	}
+	return nil
+}
+
+// mergeStatusStyles returns the default styles with the fields set in the overrides replaced
+func mergeStatusStyles(overrides map[string]StatusStyle) (map[string]StatusStyle, error) {
+	if err := ValidateStatusStyles(overrides); err != nil {
+		return nil, err
+	}
+	styles := make(map[string]StatusStyle, len(DefaultStatusStyles))
+	for status, style := range DefaultStatusStyles {
+		override := overrides[status]
+		if override.Emoji != "" {
+			style.Emoji = override.Emoji
+		}
+		if override.Icon != "" {
+			style.Icon = override.Icon
+		}
+		if override.Color != "" {
+			style.Color = override.Color
+		}
+		styles[status] = style
+	}
+	return styles, nil
+}
+
+// notificationStatus returns the status of the notification, notifications without status being about
+// succeeded PipelineRuns
+func notificationStatus(notification *Notification) string {
+	if notification.Status == "" {
+		return StatusSucceeded
+	}
+	return notification.Status
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"context"
+	"time"
+)
+
+// SummaryNotifier is implemented by notifiers that can deliver periodic summary reports
+type SummaryNotifier interface {
+	// NotifySummary sends the summary to the destination.
+	// If the summary was not delivered, a non-nil error is returned.
+	NotifySummary(ctx context.Context, summary *Summary) error
+}
+
+// Summary reports the health of the pipelines of a namespace over a period
+type Summary struct {
+	// Namespace is the namespace the summary covers
+	Namespace string `json:"namespace" xml:"namespace"`
+	// From is the beginning of the period the summary covers
+	From time.Time `json:"from" xml:"from"`
+	// To is the end of the period the summary covers
+	To time.Time `json:"to" xml:"to"`
+	// Total is the number of PipelineRuns that completed in the period
+	Total int `json:"total" xml:"total"`
+	// Succeeded is the number of PipelineRuns that succeeded in the period
+	Succeeded int `json:"succeeded" xml:"succeeded"`
+	// SuccessRate is the ratio of succeeded PipelineRuns, between 0 and 1
+	SuccessRate float64 `json:"successRate" xml:"successRate"`
+	// Slowest are the pipelines with the longest average duration
+	Slowest []PipelineStats `json:"slowest" xml:"slowest>pipeline"`
+	// TopFailures are the pipelines that failed most often
+	TopFailures []PipelineStats `json:"topFailures" xml:"topFailures>pipeline"`
+	// MaintenanceWindow is the maintenance window ending the period, when the summary reports the
+	// notifications it suppressed
+	MaintenanceWindow string `json:"maintenanceWindow,omitempty" xml:"maintenanceWindow,omitempty"`
+	// Suppressed is the number of notifications the maintenance window suppressed for the destination
+	Suppressed int `json:"suppressed,omitempty" xml:"suppressed,omitempty"`
+}
+
+// PipelineStats summarizes the PipelineRuns of a single pipeline
+type PipelineStats struct {
+	// Pipeline is the name of the pipeline
+	Pipeline string `json:"pipeline" xml:"name,attr"`
+	// Runs is the number of PipelineRuns of the pipeline
+	Runs int `json:"runs" xml:"runs"`
+	// Failures is the number of failed PipelineRuns of the pipeline
+	Failures int `json:"failures" xml:"failures"`
+	// AverageDurationSeconds is the average duration of the PipelineRuns of the pipeline
+	AverageDurationSeconds float64 `json:"averageDurationSeconds" xml:"averageDurationSeconds"`
+}
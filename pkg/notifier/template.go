@@ -0,0 +1,199 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	sprig "github.com/go-task/slim-sprig"
+)
+
+// unsafeSprigFuncs read the environment or the network of the controller, which template authors must not access
+var unsafeSprigFuncs = []string{"env", "expandenv", "getHostByName"}
+
+// templateFuncs are available to all notification templates in addition to the
+// text/template builtins, which already include urlquery for form encoded bodies, and
+// the sprig functions. mention and result are bound to the rendered notification by Render.
+var templateFuncs = newTemplateFuncs()
+
+func newTemplateFuncs() template.FuncMap {
+	funcs := sprig.TxtFuncMap()
+	for _, name := range unsafeSprigFuncs {
+		delete(funcs, name)
+	}
+	helpers := template.FuncMap{
+		"json":      toJSON,
+		"xml":       escapeXML,
+		"duration":  templateDuration,
+		"truncate":  truncate,
+		"markdown":  escapeMarkdown,
+		"slack":     escapeSlack,
+		"joinURL":   joinURL,
+		"withQuery": withQuery,
+		"repeat":    repeat,
+		"mention":   func() string { return "" },
+		"result":    func(string) string { return "" },
+	}
+	for name, fn := range helpers {
+		funcs[name] = fn
+	}
+	return funcs
+}
+
+// NewTemplate parses a Go template that renders a notification
+func NewTemplate(name string, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse template %s: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// Render executes the template against the notification, and scans the output with the PayloadScanner.
+// Rendering stops with an error wrapping ErrPayloadTooLarge once the output exceeds MaxRenderedBytes.
+func Render(tmpl *template.Template, notification *Notification) ([]byte, error) {
+	bound, err := tmpl.Clone()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to clone template %s: %w", tmpl.Name(), err)
+	}
+	bound.Funcs(template.FuncMap{
+		"mention": notification.Author.Mention,
+		"result":  func(name string) string { return notification.Result(name).String() },
+	})
+	buf := newBoundedBuffer()
+	if err := bound.Execute(buf, notification); err != nil {
+		return nil, fmt.Errorf("Failed to render template %s for pipelinerun %s: %w", tmpl.Name(), notification.PipelineRun, err)
+	}
+	return ScanPayload(buf.Bytes(), notification)
+}
+
+func toJSON(value any) (string, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return "", err
+	}
+	return string(bytes.TrimSuffix(buf.Bytes(), []byte("\n"))), nil
+}
+
+// formatDuration renders a duration in seconds, e.g. 90.5 as 1m30s
+func formatDuration(seconds float64) string {
+	return (time.Duration(seconds * float64(time.Second))).Round(time.Second).String()
+}
+
+// templateDuration is the duration template function, which formats seconds as formatDuration,
+// given as floats, integers or numeric strings, and time.Duration values
+func templateDuration(value any) (string, error) {
+	var seconds float64
+	switch v := value.(type) {
+	case float64:
+		seconds = v
+	case float32:
+		seconds = float64(v)
+	case int:
+		seconds = float64(v)
+	case int64:
+		seconds = float64(v)
+	case time.Duration:
+		seconds = v.Seconds()
+	case string:
+		parsed, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return "", fmt.Errorf("Invalid duration %q, expected seconds", v)
+		}
+		seconds = parsed
+	default:
+		return "", fmt.Errorf("Invalid duration of type %T, expected seconds", value)
+	}
+	return formatDuration(seconds), nil
+}
+
+// truncate cuts the value to at most length characters, ending with … when it is cut
+func truncate(length int, value string) string {
+	if utf8.RuneCountInString(value) <= length {
+		return value
+	}
+	if length <= 0 {
+		return ""
+	}
+	runes := []rune(value)
+	return string(runes[:length-1]) + "…"
+}
+
+// markdownReplacer escapes the characters with a meaning in Markdown with backslashes
+var markdownReplacer = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "{", `\{`, "}", `\}`, "[", `\[`, "]", `\]`,
+	"(", `\(`, ")", `\)`, "#", `\#`, "+", `\+`, "-", `\-`, ".", `\.`, "!", `\!`, "|", `\|`,
+	"<", `\<`, ">", `\>`, "~", `\~`,
+)
+
+// escapeMarkdown escapes the value for Markdown, e.g. in Matrix templates
+func escapeMarkdown(value string) string {
+	return markdownReplacer.Replace(value)
+}
+
+// slackReplacer escapes the control characters of the Slack mrkdwn format
+var slackReplacer = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// escapeSlack escapes the value for Slack messages, which would otherwise turn <...> into links or mentions
+func escapeSlack(value string) string {
+	return slackReplacer.Replace(value)
+}
+
+// joinURL appends the path segments to the base URL, escaping each segment,
+// e.g. joinURL "https://console.example.com/ns" .Namespace "pipelineruns" .PipelineRun
+func joinURL(base string, segments ...string) (string, error) {
+	parsed, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("Invalid URL %s: %w", base, err)
+	}
+	escaped := make([]string, len(segments))
+	for i, segment := range segments {
+		escaped[i] = url.PathEscape(segment)
+	}
+	return parsed.JoinPath(escaped...).String(), nil
+}
+
+// withQuery sets a query parameter of the URL, e.g. {{ joinURL $base .PipelineRun | withQuery "tab" "logs" }}
+func withQuery(key string, value string, rawURL string) (string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("Invalid URL %s: %w", rawURL, err)
+	}
+	query := parsed.Query()
+	query.Set(key, value)
+	parsed.RawQuery = query.Encode()
+	return parsed.String(), nil
+}
+
+func escapeXML(value string) (string, error) {
+	var buf bytes.Buffer
+	if err := xml.EscapeText(&buf, []byte(value)); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
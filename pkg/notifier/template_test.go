@@ -0,0 +1,90 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Templates", func() {
+	DescribeTable("should mention the author of the pipelinerun",
+		func(author *Contact, expected string) {
+			tmpl, err := NewTemplate("test", `{{ .PipelineRun }} failed {{ mention }}`)
+			Expect(err).NotTo(HaveOccurred())
+			rendered, err := Render(tmpl, &Notification{PipelineRun: "build-1", Author: author})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(rendered)).To(Equal(expected))
+		},
+		Entry("by chat handle", &Contact{Name: "jane", Slack: "U123", Email: "jane@example.com"}, "build-1 failed <@U123>"),
+		Entry("by email", &Contact{Name: "jane", Email: "jane@example.com"}, "build-1 failed jane@example.com"),
+		Entry("by name", &Contact{Name: "jane"}, "build-1 failed jane"),
+		Entry("without an author", nil, "build-1 failed "),
+	)
+
+	It("should format durations", func() {
+		tmpl, err := NewTemplate("test", `{{ .PipelineRun }} took {{ duration .DurationSeconds }}`+
+			`{{ range .Tasks }}, {{ .Name }} took {{ duration .DurationSeconds }}{{ end }}`)
+		Expect(err).NotTo(HaveOccurred())
+		rendered, err := Render(tmpl, &Notification{
+			PipelineRun:     "build-1",
+			DurationSeconds: 90.4,
+			Tasks:           []TaskTiming{{Name: "clone", DurationSeconds: 5}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(rendered)).To(Equal("build-1 took 1m30s, clone took 5s"))
+	})
+
+	It("should bind mention to each rendered notification", func() {
+		tmpl, err := NewTemplate("test", `{{ mention }}`)
+		Expect(err).NotTo(HaveOccurred())
+		first, err := Render(tmpl, &Notification{Author: &Contact{Name: "jane"}})
+		Expect(err).NotTo(HaveOccurred())
+		second, err := Render(tmpl, &Notification{Author: &Contact{Name: "john"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(first)).To(Equal("jane"))
+		Expect(string(second)).To(Equal("john"))
+	})
+
+	DescribeTable("should provide sprig and domain helpers",
+		func(text string, expected string) {
+			tmpl, err := NewTemplate("test", text)
+			Expect(err).NotTo(HaveOccurred())
+			rendered, err := Render(tmpl, &Notification{
+				PipelineRun: "build 1", Namespace: "tenant", Status: StatusFailed,
+				Results: []Result{{Name: "IMAGE_URL", Value: "quay.io/tenant/app"}, {Name: "TAGS", Type: ResultTypeArray, Array: []string{"v1"}}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(rendered)).To(Equal(expected))
+		},
+		Entry("sprig functions", `{{ .Status | upper }} {{ list 1 2 | len }} {{ "" | default "none" }}`, "FAILED 2 none"),
+		Entry("result lookup", `{{ result "IMAGE_URL" }} {{ result "TAGS" }} [{{ result "MISSING" }}]`, `quay.io/tenant/app ["v1"] []`),
+		Entry("durations of any type", `{{ duration 90 }} {{ duration "5.2" }}`, "1m30s 5s"),
+		Entry("truncation", `{{ result "IMAGE_URL" | truncate 8 }} {{ "é" | truncate 1 }}`, "quay.io… é"),
+		Entry("markdown escaping", `{{ "*build_1* [x](y)" | markdown }}`, `\*build\_1\* \[x\]\(y\)`),
+		Entry("slack escaping", `{{ "<!here> & co" | slack }}`, "&lt;!here&gt; &amp; co"),
+		Entry("URL building", `{{ joinURL "https://console.example.com/ns/" .Namespace "pipelineruns" .PipelineRun | withQuery "tab" "logs" }}`,
+			"https://console.example.com/ns/tenant/pipelineruns/build%201?tab=logs"),
+	)
+
+	It("should not expose the environment of the controller", func() {
+		for _, name := range []string{"env", "expandenv", "getHostByName"} {
+			_, err := NewTemplate("test", `{{ `+name+` "HOME" }}`)
+			Expect(err).To(MatchError(ContainSubstring("not defined")))
+		}
+	})
+})
@@ -0,0 +1,457 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/konflux-ci/notification-service/pkg/delivery"
+)
+
+// Content types of webhook request bodies
+const (
+	ContentTypeJSON = "json"
+	ContentTypeForm = "form"
+	ContentTypeXML  = "xml"
+	// ContentTypeAlertmanager sends the webhook messages of Prometheus Alertmanager, see NewAlertmanagerMessage
+	ContentTypeAlertmanager = "alertmanager"
+)
+
+// CompressionGzip compresses webhook request bodies with gzip
+const CompressionGzip = "gzip"
+
+const DefaultWebhookTimeout = 10 * time.Second
+
+var webhookMediaTypes = map[string]string{
+	ContentTypeJSON:         "application/json",
+	ContentTypeForm:         "application/x-www-form-urlencoded",
+	ContentTypeXML:          "application/xml",
+	ContentTypeAlertmanager: "application/json",
+}
+
+// WebhookOptions configures a WebhookNotifier
+type WebhookOptions struct {
+	// URL is the endpoint notifications are posted to
+	URL string
+	// ContentType is the encoding of the request body: json (default), form, xml or alertmanager
+	ContentType string
+	// Template is an optional Go template rendering the request body.
+	// The rendered output is sent as is, so it must match the content type.
+	Template string
+	// Compression is the optional encoding of request bodies, only gzip is supported
+	Compression string
+	// MaxPayloadBytes limits the size of the uncompressed request body.
+	// Results are dropped, largest first, until the body fits. Zero disables the limit.
+	MaxPayloadBytes int
+	// EncryptionKey is an optional PEM or JWK public key request bodies are encrypted for as a JWE,
+	// see NewJWEEncrypter. It cannot be combined with Compression.
+	EncryptionKey []byte
+	// Signer optionally signs the request bodies, as sent, and sets the headers it returns
+	Signer PayloadSigner
+	// ResultFormat is the format of array and object results, see the ResultFormat constants.
+	// Defaults to structured, except for XML bodies that JSON encode them since XML has no maps.
+	ResultFormat string
+	// Timeout is the deadline of a single request
+	Timeout time.Duration
+	// HTTPClient is used to send requests, defaults to a client with Timeout
+	HTTPClient *http.Client
+}
+
+// WebhookNotifier posts notifications to an HTTP endpoint
+type WebhookNotifier struct {
+	url             string
+	contentType     string
+	template        *template.Template
+	compression     string
+	maxPayloadBytes int
+	encrypter       *JWEEncrypter
+	signer          PayloadSigner
+	resultFormat    string
+	client          *http.Client
+}
+
+// NewWebhookNotifier creates a WebhookNotifier from the given options
+func NewWebhookNotifier(opts WebhookOptions) (*WebhookNotifier, error) {
+	if opts.URL == "" {
+		return nil, errors.New("webhook URL must be set")
+	}
+	if opts.ContentType == "" {
+		opts.ContentType = ContentTypeJSON
+	}
+	if _, ok := webhookMediaTypes[opts.ContentType]; !ok {
+		return nil, fmt.Errorf("Unsupported webhook content type %s", opts.ContentType)
+	}
+	if opts.Compression != "" && opts.Compression != CompressionGzip {
+		return nil, fmt.Errorf("Unsupported webhook compression %s", opts.Compression)
+	}
+	switch opts.ResultFormat {
+	case "":
+		opts.ResultFormat = ResultFormatStructured
+		if opts.ContentType == ContentTypeXML {
+			opts.ResultFormat = ResultFormatString
+		}
+	case ResultFormatStructured, ResultFormatString, ResultFormatFlatten:
+	default:
+		return nil, fmt.Errorf("Unsupported webhook result format %s", opts.ResultFormat)
+	}
+	if opts.Compression != "" && len(opts.EncryptionKey) > 0 {
+		return nil, errors.New("webhook bodies cannot be both compressed and encrypted")
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultWebhookTimeout
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = NewHTTPClient(opts.Timeout)
+	}
+	w := &WebhookNotifier{
+		url:             opts.URL,
+		contentType:     opts.ContentType,
+		compression:     opts.Compression,
+		maxPayloadBytes: opts.MaxPayloadBytes,
+		signer:          opts.Signer,
+		resultFormat:    opts.ResultFormat,
+		client:          opts.HTTPClient,
+	}
+	if opts.Template != "" {
+		tmpl, err := NewTemplate("webhook", opts.Template)
+		if err != nil {
+			return nil, err
+		}
+		w.template = tmpl
+	}
+	if len(opts.EncryptionKey) > 0 {
+		encrypter, err := NewJWEEncrypter(opts.EncryptionKey)
+		if err != nil {
+			return nil, err
+		}
+		w.encrypter = encrypter
+	}
+	return w, nil
+}
+
+// Notify posts the notification to the webhook URL.
+// Responses with a non 2xx status are reported as errors.
+func (w *WebhookNotifier) Notify(ctx context.Context, notification *Notification) error {
+	_, err := w.NotifyWithResponse(ctx, notification)
+	return err
+}
+
+// NotifyWithResponse posts the notification to the webhook URL and returns the HTTP status.
+// Responses with a non 2xx status are reported as errors.
+// Alertmanager webhooks are only sent the notifications that are alerts, see NewAlert.
+func (w *WebhookNotifier) NotifyWithResponse(ctx context.Context, notification *Notification) (*Response, error) {
+	if w.contentType == ContentTypeAlertmanager && w.template == nil && !IsAlert(notification) {
+		return nil, nil
+	}
+	formatted := *notification
+	formatted.Results = FormatResults(notification.Results, w.resultFormat)
+	body, err := w.boundedBody(&formatted)
+	if err != nil {
+		return nil, err
+	}
+	if w.compression == CompressionGzip {
+		body, err = gzipBody(body)
+		if err != nil {
+			return nil, err
+		}
+	}
+	body, mediaType, err := w.encrypt(body, webhookMediaTypes[w.contentType])
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", mediaType)
+	if w.compression != "" {
+		req.Header.Set("Content-Encoding", w.compression)
+	}
+	if notification.DeliveryID != "" {
+		req.Header.Set(delivery.Header, notification.DeliveryID)
+	}
+	err = w.sign(ctx, req, body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to send webhook for pipelinerun %s: %w", notification.PipelineRun, err)
+	}
+	defer resp.Body.Close()
+	excerpt, _ := io.ReadAll(io.LimitReader(resp.Body, MaxResponseExcerptBytes))
+	_, _ = io.Copy(io.Discard, resp.Body)
+	response := &Response{
+		StatusCode: resp.StatusCode,
+		Excerpt:    strings.ToValidUTF8(string(excerpt), ""),
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return response, fmt.Errorf("Webhook for pipelinerun %s failed with status %d: %s",
+			notification.PipelineRun, resp.StatusCode, strings.TrimSpace(response.Excerpt))
+	}
+	return response, nil
+}
+
+// Probe sends a HEAD request to the webhook URL. Any response but a server error means the
+// webhook is reachable, endpoints usually only accept POST requests.
+func (w *WebhookNotifier) Probe(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, w.url, nil)
+	if err != nil {
+		return fmt.Errorf("Failed to create webhook probe: %w", err)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Failed to reach webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("Webhook probe failed with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// NotifySummary posts the summary to the webhook URL.
+// Summaries are encoded as XML for XML webhooks and as JSON otherwise, templates do not apply to them.
+// Alertmanager webhooks are not sent summaries, which are not alerts.
+func (w *WebhookNotifier) NotifySummary(ctx context.Context, summary *Summary) error {
+	if w.contentType == ContentTypeAlertmanager {
+		return nil
+	}
+	var body []byte
+	var err error
+	mediaType := webhookMediaTypes[ContentTypeJSON]
+	if w.contentType == ContentTypeXML {
+		mediaType = webhookMediaTypes[ContentTypeXML]
+		body, err = xml.Marshal(struct {
+			XMLName xml.Name `xml:"summary"`
+			*Summary
+		}{Summary: summary})
+		body = append([]byte(xml.Header), body...)
+	} else {
+		body, err = json.Marshal(summary)
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to encode summary for namespace %s: %w", summary.Namespace, err)
+	}
+	if w.compression == CompressionGzip {
+		body, err = gzipBody(body)
+		if err != nil {
+			return err
+		}
+	}
+	body, mediaType, err = w.encrypt(body, mediaType)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("Failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", mediaType)
+	if w.compression != "" {
+		req.Header.Set("Content-Encoding", w.compression)
+	}
+	err = w.sign(ctx, req, body)
+	if err != nil {
+		return err
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Failed to send summary for namespace %s: %w", summary.Namespace, err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("Summary for namespace %s failed with status %d", summary.Namespace, resp.StatusCode)
+	}
+	return nil
+}
+
+// encrypt returns the body encrypted as a JWE and its media type, or the body as is if encryption is not enabled
+func (w *WebhookNotifier) encrypt(body []byte, mediaType string) ([]byte, string, error) {
+	if w.encrypter == nil {
+		return body, mediaType, nil
+	}
+	encrypted, err := w.encrypter.Encrypt(body, mediaType)
+	if err != nil {
+		return nil, "", err
+	}
+	return encrypted, MediaTypeJOSE, nil
+}
+
+// sign sets the signature headers of the request body, if signing is enabled
+func (w *WebhookNotifier) sign(ctx context.Context, req *http.Request, body []byte) error {
+	if w.signer == nil {
+		return nil
+	}
+	headers, err := w.signer.Sign(ctx, body)
+	if err != nil {
+		return fmt.Errorf("Failed to sign webhook body: %w", err)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	return nil
+}
+
+// boundedBody returns the request body, dropping results until it fits in maxPayloadBytes.
+// Results are dropped by decreasing size and then by name, so the outcome is deterministic.
+func (w *WebhookNotifier) boundedBody(notification *Notification) ([]byte, error) {
+	body, err := w.body(notification)
+	if w.maxPayloadBytes <= 0 || (err == nil && len(body) <= w.maxPayloadBytes) {
+		return body, err
+	}
+	if err != nil && !errors.Is(err, ErrPayloadTooLarge) {
+		return nil, err
+	}
+
+	order := make([]Result, len(notification.Results))
+	copy(order, notification.Results)
+	sort.SliceStable(order, func(i, j int) bool {
+		if len(order[i].String()) != len(order[j].String()) {
+			return len(order[i].String()) > len(order[j].String())
+		}
+		return order[i].Name < order[j].Name
+	})
+
+	truncated := *notification
+	dropped := map[string]bool{}
+	for _, result := range order {
+		dropped[result.Name] = true
+		truncated.TruncatedResults = append(truncated.TruncatedResults, result.Name)
+		truncated.Results = nil
+		for _, kept := range notification.Results {
+			if !dropped[kept.Name] {
+				truncated.Results = append(truncated.Results, kept)
+			}
+		}
+		body, err = w.body(&truncated)
+		if errors.Is(err, ErrPayloadTooLarge) {
+			continue
+		}
+		if err != nil || len(body) <= w.maxPayloadBytes {
+			return body, err
+		}
+	}
+	return nil, fmt.Errorf("Webhook payload for pipelinerun %s exceeds %d bytes even without results",
+		notification.PipelineRun, w.maxPayloadBytes)
+}
+
+func (w *WebhookNotifier) body(notification *Notification) ([]byte, error) {
+	if w.template != nil {
+		return Render(w.template, notification)
+	}
+	switch w.contentType {
+	case ContentTypeForm:
+		body := []byte(formValues(notification).Encode())
+		if err := checkRenderedSize(body); err != nil {
+			return nil, fmt.Errorf("Failed to encode notification for pipelinerun %s: %w", notification.PipelineRun, err)
+		}
+		return body, nil
+	case ContentTypeAlertmanager:
+		alert, err := NewAlert(notification, nil, nil, Locales[DefaultLocale], time.Now().UTC(), DefaultAlertResolveAfter)
+		if err != nil {
+			return nil, err
+		}
+		body, err := json.Marshal(NewAlertmanagerMessage(alert))
+		if err == nil {
+			err = checkRenderedSize(body)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to encode notification for pipelinerun %s: %w", notification.PipelineRun, err)
+		}
+		return body, nil
+	case ContentTypeXML:
+		buf := newBoundedBuffer()
+		buf.WriteString(xml.Header)
+		err := xml.NewEncoder(buf).EncodeElement(notification, xml.StartElement{Name: xml.Name{Local: "notification"}})
+		if err != nil {
+			return nil, fmt.Errorf("Failed to encode notification for pipelinerun %s: %w", notification.PipelineRun, err)
+		}
+		return buf.Bytes(), nil
+	default:
+		body, err := json.Marshal(notification)
+		if err == nil {
+			err = checkRenderedSize(body)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to encode notification for pipelinerun %s: %w", notification.PipelineRun, err)
+		}
+		return body, nil
+	}
+}
+
+func gzipBody(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(body); err != nil {
+		return nil, fmt.Errorf("Failed to compress webhook body: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("Failed to compress webhook body: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// formValues flattens the notification into form fields,
+// each result is sent as a results.<name> field
+func formValues(notification *Notification) url.Values {
+	values := url.Values{}
+	values.Set("pipelineRun", notification.PipelineRun)
+	values.Set("namespace", notification.Namespace)
+	if notification.Author != nil {
+		values.Set("author", notification.Author.Name)
+	}
+	if notification.Team != "" {
+		values.Set("team", notification.Team)
+	}
+	if notification.Status != "" {
+		values.Set("status", notification.Status)
+	}
+	if notification.Event != "" {
+		values.Set("event", notification.Event)
+	}
+	if notification.Subtype != "" {
+		values.Set("subtype", notification.Subtype)
+	}
+	for _, result := range notification.Results {
+		values.Set("results."+result.Name, result.String())
+	}
+	for _, name := range notification.TruncatedResults {
+		values.Add("truncatedResults", name)
+	}
+	if notification.PayloadURL != "" {
+		values.Set("payloadURL", notification.PayloadURL)
+	}
+	if notification.CallbackURL != "" {
+		values.Set("callbackURL", notification.CallbackURL)
+	}
+	return values
+}
@@ -0,0 +1,374 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	jose "gopkg.in/square/go-jose.v2"
+
+	"github.com/konflux-ci/notification-service/pkg/delivery"
+)
+
+var _ = Describe("WebhookNotifier", func() {
+	var (
+		server       *httptest.Server
+		contentType  string
+		encoding     string
+		deliveryID   string
+		body         string
+		status       int
+		responseBody string
+	)
+
+	notification := &Notification{
+		PipelineRun: "build-1",
+		Namespace:   "tenant",
+		Results:     []Result{{Name: "IMAGE_URL", Value: "quay.io/test/image:<tag>"}},
+	}
+
+	send := func(opts WebhookOptions) error {
+		opts.URL = server.URL
+		n, err := NewWebhookNotifier(opts)
+		Expect(err).NotTo(HaveOccurred())
+		return n.Notify(context.Background(), notification)
+	}
+
+	BeforeEach(func() {
+		status = http.StatusOK
+		body, responseBody = "", ""
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			contentType = r.Header.Get("Content-Type")
+			encoding = r.Header.Get("Content-Encoding")
+			deliveryID = r.Header.Get(delivery.Header)
+			raw, err := io.ReadAll(r.Body)
+			Expect(err).NotTo(HaveOccurred())
+			body = string(raw)
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(responseBody))
+		}))
+		DeferCleanup(server.Close)
+	})
+
+	It("should probe the webhook with a HEAD request", func() {
+		n, err := NewWebhookNotifier(WebhookOptions{URL: server.URL})
+		Expect(err).NotTo(HaveOccurred())
+		status = http.StatusMethodNotAllowed
+		probed, err := Probe(context.Background(), n)
+		Expect(probed).To(BeTrue())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(body).To(BeEmpty())
+		status = http.StatusBadGateway
+		Expect(n.Probe(context.Background())).To(MatchError(ContainSubstring("status 502")))
+	})
+
+	It("should send JSON by default", func() {
+		Expect(send(WebhookOptions{})).To(Succeed())
+		Expect(contentType).To(Equal("application/json"))
+		Expect(body).To(MatchJSON(
+			`{"pipelineRun":"build-1","namespace":"tenant","results":[{"name":"IMAGE_URL","value":"quay.io/test/image:<tag>"}]}`))
+	})
+
+	It("should identify deliveries in a header and in the body", func() {
+		n, err := NewWebhookNotifier(WebhookOptions{URL: server.URL})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n.Notify(context.Background(), &Notification{PipelineRun: "build-1", DeliveryID: "0123abcd"})).To(Succeed())
+		Expect(deliveryID).To(Equal("0123abcd"))
+		Expect(body).To(ContainSubstring(`"deliveryID":"0123abcd"`))
+
+		Expect(send(WebhookOptions{})).To(Succeed())
+		Expect(deliveryID).To(BeEmpty())
+	})
+
+	It("should send form encoded bodies", func() {
+		Expect(send(WebhookOptions{ContentType: ContentTypeForm})).To(Succeed())
+		Expect(contentType).To(Equal("application/x-www-form-urlencoded"))
+		Expect(body).To(Equal("namespace=tenant&pipelineRun=build-1&results.IMAGE_URL=quay.io%2Ftest%2Fimage%3A%3Ctag%3E"))
+	})
+
+	It("should send the event and subtype in form encoded bodies", func() {
+		n, err := NewWebhookNotifier(WebhookOptions{URL: server.URL, ContentType: ContentTypeForm})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n.Notify(context.Background(), &Notification{
+			PipelineRun: "build-1", Namespace: "tenant", Status: StatusSucceeded, Event: EventSucceeded, Subtype: EventSucceededWithRetries,
+		})).To(Succeed())
+		Expect(body).To(Equal("event=succeeded&namespace=tenant&pipelineRun=build-1&status=Succeeded&subtype=succeededWithRetries"))
+
+		Expect(n.Notify(context.Background(), &Notification{
+			PipelineRun: "build-1", Namespace: "tenant", Status: StatusRunning, Event: EventRunning,
+		})).To(Succeed())
+		Expect(body).To(Equal("event=running&namespace=tenant&pipelineRun=build-1&status=Running"))
+	})
+
+	It("should send XML bodies", func() {
+		Expect(send(WebhookOptions{ContentType: ContentTypeXML})).To(Succeed())
+		Expect(contentType).To(Equal("application/xml"))
+		Expect(body).To(ContainSubstring(
+			`<notification><pipelineRun>build-1</pipelineRun><namespace>tenant</namespace>` +
+				`<results><result name="IMAGE_URL">quay.io/test/image:&lt;tag&gt;</result></results></notification>`))
+	})
+
+	It("should send the webhook messages of Alertmanager for alerts", func() {
+		n, err := NewWebhookNotifier(WebhookOptions{URL: server.URL, ContentType: ContentTypeAlertmanager})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n.Notify(context.Background(), notification)).To(Succeed())
+		Expect(body).To(BeEmpty())
+
+		failed := *notification
+		failed.Status = StatusFailed
+		Expect(n.Notify(context.Background(), &failed)).To(Succeed())
+		Expect(contentType).To(Equal("application/json"))
+		message := AlertmanagerMessage{}
+		Expect(json.Unmarshal([]byte(body), &message)).To(Succeed())
+		Expect(message.Version).To(Equal("4"))
+		Expect(message.Status).To(Equal("firing"))
+		Expect(message.GroupKey).To(Equal(`{}:{alertname="PipelineRunFailed", namespace="tenant"}`))
+		Expect(message.Alerts).To(HaveLen(1))
+		Expect(message.Alerts[0].Labels).To(Equal(map[string]string{
+			"alertname": "PipelineRunFailed", "namespace": "tenant", "pipelinerun": "build-1",
+		}))
+		Expect(message.Alerts[0].Annotations).To(HaveKeyWithValue("description", "IMAGE_URL: quay.io/test/image:<tag>"))
+		Expect(message.Alerts[0].Fingerprint).To(HaveLen(16))
+		Expect(n.NotifySummary(context.Background(), &Summary{Namespace: "tenant"})).To(Succeed())
+		Expect(body).To(ContainSubstring(`"alerts"`))
+	})
+
+	DescribeTable("should render templates for each content type",
+		func(contentType string, template string, expected string) {
+			Expect(send(WebhookOptions{ContentType: contentType, Template: template})).To(Succeed())
+			Expect(body).To(Equal(expected))
+		},
+		Entry("json", ContentTypeJSON,
+			`{"run": {{ json .PipelineRun }}, "image": {{ range .Results }}{{ json .Value }}{{ end }}}`,
+			`{"run": "build-1", "image": "quay.io/test/image:<tag>"}`),
+		Entry("form", ContentTypeForm,
+			`run={{ urlquery .PipelineRun }}{{ range .Results }}&{{ urlquery .Name }}={{ urlquery .Value }}{{ end }}`,
+			"run=build-1&IMAGE_URL=quay.io%2Ftest%2Fimage%3A%3Ctag%3E"),
+		Entry("xml", ContentTypeXML,
+			`<run>{{ xml .PipelineRun }}</run>{{ range .Results }}<image>{{ xml .Value }}</image>{{ end }}`,
+			"<run>build-1</run><image>quay.io/test/image:&lt;tag&gt;</image>"),
+	)
+
+	Context("when results are arrays and objects", func() {
+		structured := &Notification{
+			PipelineRun: "build-1",
+			Namespace:   "tenant",
+			Results: []Result{
+				{Name: "TAGS", Type: ResultTypeArray, Array: []string{"v1", "latest"}},
+				{Name: "SCAN", Type: ResultTypeObject, Object: map[string]string{"critical": "0", "high": "2"}},
+			},
+		}
+
+		sendStructured := func(opts WebhookOptions) {
+			opts.URL = server.URL
+			n, err := NewWebhookNotifier(opts)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n.Notify(context.Background(), structured)).To(Succeed())
+		}
+
+		It("should send them structured by default", func() {
+			sendStructured(WebhookOptions{})
+			Expect(body).To(MatchJSON(`{"pipelineRun":"build-1","namespace":"tenant","results":[
+				{"name":"TAGS","type":"array","array":["v1","latest"]},
+				{"name":"SCAN","type":"object","object":{"critical":"0","high":"2"}}]}`))
+		})
+
+		It("should JSON encode them in their value", func() {
+			sendStructured(WebhookOptions{ResultFormat: ResultFormatString})
+			Expect(body).To(MatchJSON(`{"pipelineRun":"build-1","namespace":"tenant","results":[
+				{"name":"TAGS","value":"[\"v1\",\"latest\"]"},
+				{"name":"SCAN","value":"{\"critical\":\"0\",\"high\":\"2\"}"}]}`))
+
+			sendStructured(WebhookOptions{ContentType: ContentTypeXML})
+			Expect(body).To(ContainSubstring(`<result name="TAGS">[&#34;v1&#34;,&#34;latest&#34;]</result>`))
+		})
+
+		It("should flatten them", func() {
+			sendStructured(WebhookOptions{ContentType: ContentTypeForm, ResultFormat: ResultFormatFlatten})
+			Expect(body).To(Equal("namespace=tenant&pipelineRun=build-1&results.SCAN.critical=0&results.SCAN.high=2" +
+				"&results.TAGS.0=v1&results.TAGS.1=latest"))
+		})
+
+		It("should expose them to templates", func() {
+			sendStructured(WebhookOptions{Template: `{{ (.Result "SCAN").Object.high }} {{ index (.Result "TAGS").Array 1 }} {{ (.Result "MISSING").String }}`})
+			Expect(body).To(Equal("2 latest "))
+		})
+
+		It("should reject unknown formats", func() {
+			_, err := NewWebhookNotifier(WebhookOptions{URL: server.URL, ResultFormat: "yaml"})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	It("should compress bodies with gzip", func() {
+		Expect(send(WebhookOptions{Compression: CompressionGzip})).To(Succeed())
+		Expect(encoding).To(Equal("gzip"))
+		reader, err := gzip.NewReader(bytes.NewReader([]byte(body)))
+		Expect(err).NotTo(HaveOccurred())
+		decompressed, err := io.ReadAll(reader)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(decompressed).To(ContainSubstring(`"pipelineRun":"build-1"`))
+	})
+
+	Context("when encryption is enabled", func() {
+		decrypt := func(key any) (string, string) {
+			object, err := jose.ParseEncrypted(body)
+			Expect(err).NotTo(HaveOccurred())
+			plaintext, err := object.Decrypt(key)
+			Expect(err).NotTo(HaveOccurred())
+			return string(plaintext), fmt.Sprint(object.Header.ExtraHeaders[jose.HeaderContentType])
+		}
+
+		It("should encrypt bodies for PEM encoded RSA keys", func() {
+			key, err := rsa.GenerateKey(rand.Reader, 2048)
+			Expect(err).NotTo(HaveOccurred())
+			der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+			Expect(err).NotTo(HaveOccurred())
+			publicKey := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+			Expect(send(WebhookOptions{ContentType: ContentTypeForm, EncryptionKey: publicKey})).To(Succeed())
+			Expect(contentType).To(Equal(MediaTypeJOSE))
+			plaintext, cty := decrypt(key)
+			Expect(plaintext).To(ContainSubstring("pipelineRun=build-1"))
+			Expect(cty).To(Equal("application/x-www-form-urlencoded"))
+		})
+
+		It("should encrypt bodies for EC JWKs", func() {
+			key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			Expect(err).NotTo(HaveOccurred())
+			publicKey, err := jose.JSONWebKey{Key: &key.PublicKey, KeyID: "receiver-1"}.MarshalJSON()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(send(WebhookOptions{EncryptionKey: publicKey})).To(Succeed())
+			object, err := jose.ParseEncrypted(body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(object.Header.KeyID).To(Equal("receiver-1"))
+			plaintext, _ := decrypt(key)
+			Expect(plaintext).To(ContainSubstring(`"pipelineRun":"build-1"`))
+		})
+
+		It("should reject private, invalid and compressed keys", func() {
+			key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			Expect(err).NotTo(HaveOccurred())
+			privateKey, err := jose.JSONWebKey{Key: key}.MarshalJSON()
+			Expect(err).NotTo(HaveOccurred())
+			_, err = NewWebhookNotifier(WebhookOptions{URL: server.URL, EncryptionKey: privateKey})
+			Expect(err).To(HaveOccurred())
+			_, err = NewWebhookNotifier(WebhookOptions{URL: server.URL, EncryptionKey: []byte("not a key")})
+			Expect(err).To(HaveOccurred())
+			publicKey, err := jose.JSONWebKey{Key: &key.PublicKey}.MarshalJSON()
+			Expect(err).NotTo(HaveOccurred())
+			_, err = NewWebhookNotifier(WebhookOptions{URL: server.URL, EncryptionKey: publicKey, Compression: CompressionGzip})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when the payload exceeds the size limit", func() {
+		large := &Notification{
+			PipelineRun: "build-1",
+			Namespace:   "tenant",
+			Results: []Result{
+				{Name: "B", Value: "0123456789"},
+				{Name: "A", Value: "0123456789"},
+				{Name: "SMALL", Value: "x"},
+				{Name: "LARGEST", Value: "0123456789012345678901234567890123456789"},
+			},
+		}
+
+		sendLarge := func(maxPayloadBytes int) error {
+			n, err := NewWebhookNotifier(WebhookOptions{URL: server.URL, MaxPayloadBytes: maxPayloadBytes})
+			Expect(err).NotTo(HaveOccurred())
+			return n.Notify(context.Background(), large)
+		}
+
+		It("should drop the largest results first", func() {
+			Expect(sendLarge(160)).To(Succeed())
+			Expect(body).To(MatchJSON(`{"pipelineRun":"build-1","namespace":"tenant",` +
+				`"results":[{"name":"B","value":"0123456789"},{"name":"SMALL","value":"x"}],` +
+				`"truncatedResults":["LARGEST","A"]}`))
+			Expect(len(body)).To(BeNumerically("<=", 160))
+		})
+
+		It("should fail when the payload does not fit without results", func() {
+			Expect(sendLarge(10)).NotTo(Succeed())
+		})
+
+		It("should not modify payloads within the limit", func() {
+			Expect(sendLarge(1024)).To(Succeed())
+			Expect(body).NotTo(ContainSubstring("truncatedResults"))
+		})
+	})
+
+	It("should fail on non 2xx responses", func() {
+		status = http.StatusBadRequest
+		Expect(send(WebhookOptions{})).NotTo(Succeed())
+	})
+
+	It("should report the response status", func() {
+		status = http.StatusAccepted
+		n, err := NewWebhookNotifier(WebhookOptions{URL: server.URL})
+		Expect(err).NotTo(HaveOccurred())
+		response, err := Deliver(context.Background(), n, notification)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(response.StatusCode).To(Equal(http.StatusAccepted))
+
+		status = http.StatusBadGateway
+		response, err = Deliver(context.Background(), n, notification)
+		Expect(err).To(HaveOccurred())
+		Expect(response.StatusCode).To(Equal(http.StatusBadGateway))
+	})
+
+	It("should capture a bounded excerpt of the response", func() {
+		status = http.StatusBadRequest
+		responseBody = `{"error": "missing field image"}` + strings.Repeat(" ", MaxResponseExcerptBytes)
+		n, err := NewWebhookNotifier(WebhookOptions{URL: server.URL})
+		Expect(err).NotTo(HaveOccurred())
+		response, err := Deliver(context.Background(), n, notification)
+		Expect(err).To(MatchError(ContainSubstring("missing field image")))
+		Expect(response.Excerpt).To(HavePrefix(`{"error": "missing field image"}`))
+		Expect(response.Excerpt).To(HaveLen(MaxResponseExcerptBytes))
+	})
+
+	It("should reject unknown content types", func() {
+		_, err := NewWebhookNotifier(WebhookOptions{URL: server.URL, ContentType: "yaml"})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should reject unknown compressions", func() {
+		_, err := NewWebhookNotifier(WebhookOptions{URL: server.URL, Compression: "br"})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should reject invalid templates", func() {
+		_, err := NewWebhookNotifier(WebhookOptions{URL: server.URL, Template: "{{ .PipelineRun"})
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,405 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// DefaultWebPushTTL is how long push services keep notifications for browsers that are offline
+const DefaultWebPushTTL = 24 * time.Hour
+
+// webPushRecordSize is the record size of encrypted payloads, which hold a single record
+const webPushRecordSize = 4096
+
+// maxWebPushPayload is the largest payload push services accept, their 4096 bytes limit including the
+// header of the encrypted content, the padding delimiter and the authentication tag
+const maxWebPushPayload = webPushRecordSize - 86 - 1 - 16
+
+// Urgencies of web push notifications, push services deliver low urgency notifications
+// when the device is not saving power
+const (
+	WebPushUrgencyVeryLow = "very-low"
+	WebPushUrgencyLow     = "low"
+	WebPushUrgencyNormal  = "normal"
+	WebPushUrgencyHigh    = "high"
+)
+
+// WebPushSubscription is the push subscription of a browser, as serialized by PushSubscription.toJSON()
+type WebPushSubscription struct {
+	// Endpoint is the URL of the push service notifications are sent to
+	Endpoint string `json:"endpoint"`
+	// Keys are the keys payloads are encrypted with
+	Keys WebPushKeys `json:"keys"`
+}
+
+// WebPushKeys are the base64url encoded keys of a push subscription
+type WebPushKeys struct {
+	// P256dh is the uncompressed P-256 public key of the browser
+	P256dh string `json:"p256dh"`
+	// Auth is the 16 bytes authentication secret of the subscription
+	Auth string `json:"auth"`
+}
+
+// Validate checks that the endpoint is an https URL and the keys are well formed
+func (s *WebPushSubscription) Validate() error {
+	endpoint, err := url.Parse(s.Endpoint)
+	if err != nil || endpoint.Scheme != "https" || endpoint.Host == "" {
+		return fmt.Errorf("Invalid push subscription endpoint %s, expected an https URL", s.Endpoint)
+	}
+	if _, err := s.keys(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// webPushKeys are the decoded keys of a subscription
+type webPushKeys struct {
+	public *ecdh.PublicKey
+	auth   []byte
+}
+
+func (s *WebPushSubscription) keys() (*webPushKeys, error) {
+	public, err := decodeBase64URL(s.Keys.P256dh)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid p256dh key of push subscription: %w", err)
+	}
+	publicKey, err := ecdh.P256().NewPublicKey(public)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid p256dh key of push subscription: %w", err)
+	}
+	auth, err := decodeBase64URL(s.Keys.Auth)
+	if err != nil || len(auth) != 16 {
+		return nil, errors.New("Invalid auth secret of push subscription, expected 16 base64url encoded bytes")
+	}
+	return &webPushKeys{public: publicKey, auth: auth}, nil
+}
+
+// VAPIDKeys identify the application server to push services (RFC 8292)
+type VAPIDKeys struct {
+	// PublicKey is the base64url encoded uncompressed public key, the applicationServerKey browsers subscribe with
+	PublicKey string
+	// Subject is a mailto: or https: URL push services may use to contact the operator of the application server
+	Subject    string
+	privateKey *ecdsa.PrivateKey
+}
+
+// NewVAPIDKeys creates VAPIDKeys from a base64url encoded P-256 private key, as generated by the
+// web-push tools, and the contact of the operator
+func NewVAPIDKeys(privateKey string, subject string) (*VAPIDKeys, error) {
+	if !strings.HasPrefix(subject, "mailto:") && !strings.HasPrefix(subject, "https://") {
+		return nil, fmt.Errorf("Invalid VAPID subject %s, expected a mailto: or https: URL", subject)
+	}
+	scalar, err := decodeBase64URL(strings.TrimSpace(privateKey))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode VAPID private key: %w", err)
+	}
+	key, err := ecdh.P256().NewPrivateKey(scalar)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid VAPID private key: %w", err)
+	}
+	public := key.PublicKey().Bytes()
+	return &VAPIDKeys{
+		PublicKey: base64.RawURLEncoding.EncodeToString(public),
+		Subject:   subject,
+		privateKey: &ecdsa.PrivateKey{
+			PublicKey: ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(public[1:33]),
+				Y:     new(big.Int).SetBytes(public[33:]),
+			},
+			D: new(big.Int).SetBytes(scalar),
+		},
+	}, nil
+}
+
+// authorization returns the vapid Authorization header of requests to the push service of the endpoint
+func (k *VAPIDKeys) authorization(endpoint string, now time.Time) (string, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"aud": parsed.Scheme + "://" + parsed.Host,
+		"exp": now.Add(12 * time.Hour).Unix(),
+		"sub": k.Subject,
+	})
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, err := ecdsa.Sign(rand.Reader, k.privateKey, digest[:])
+	if err != nil {
+		return "", err
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	return "vapid t=" + signingInput + "." + base64.RawURLEncoding.EncodeToString(signature) + ", k=" + k.PublicKey, nil
+}
+
+// WebPushOptions configures a WebPushNotifier
+type WebPushOptions struct {
+	// VAPID identifies the controller to push services
+	VAPID *VAPIDKeys
+	// Subscriptions are the browsers notifications are pushed to
+	Subscriptions []WebPushSubscription
+	// Template is an optional Go template rendering the body of notifications
+	Template string
+	// Locale is the language of the built-in messages, see Locales. Defaults to DefaultLocale.
+	Locale string
+	// Urgency is the urgency of notifications, defaults to normal
+	Urgency string
+	// TTL is how long push services keep notifications for browsers that are offline, defaults to DefaultWebPushTTL
+	TTL time.Duration
+	// Timeout is the deadline of a single request
+	Timeout time.Duration
+	// HTTPClient is used to send requests, defaults to a client with Timeout
+	HTTPClient *http.Client
+}
+
+// webPushMessage is the payload browsers receive, which their service worker displays with
+// showNotification(title, {body, tag, data})
+type webPushMessage struct {
+	Title       string `json:"title"`
+	Body        string `json:"body"`
+	Tag         string `json:"tag"`
+	Namespace   string `json:"namespace"`
+	PipelineRun string `json:"pipelineRun"`
+	Status      string `json:"status"`
+	URL         string `json:"url,omitempty"`
+}
+
+// WebPushNotifier pushes notifications to browsers subscribed with the Push API.
+// Payloads are encrypted for every subscription (RFC 8291) and requests are authenticated with VAPID.
+type WebPushNotifier struct {
+	opts     WebPushOptions
+	template *template.Template
+	locale   *Locale
+}
+
+// NewWebPushNotifier creates a WebPushNotifier from the given options
+func NewWebPushNotifier(opts WebPushOptions) (*WebPushNotifier, error) {
+	if opts.VAPID == nil {
+		return nil, errors.New("VAPID keys must be set")
+	}
+	for i := range opts.Subscriptions {
+		if err := opts.Subscriptions[i].Validate(); err != nil {
+			return nil, err
+		}
+	}
+	switch opts.Urgency {
+	case "":
+		opts.Urgency = WebPushUrgencyNormal
+	case WebPushUrgencyVeryLow, WebPushUrgencyLow, WebPushUrgencyNormal, WebPushUrgencyHigh:
+	default:
+		return nil, fmt.Errorf("Invalid web push urgency %s", opts.Urgency)
+	}
+	locale, err := LookupLocale(opts.Locale)
+	if err != nil {
+		return nil, err
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultWebPushTTL
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultWebhookTimeout
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = NewHTTPClient(opts.Timeout)
+	}
+	n := &WebPushNotifier{opts: opts, locale: locale}
+	if opts.Template != "" {
+		tmpl, err := NewTemplate("webpush", opts.Template)
+		if err != nil {
+			return nil, err
+		}
+		n.template = tmpl
+	}
+	return n, nil
+}
+
+// Notify pushes the notification to every subscription. Subscriptions the push service reports as
+// expired are skipped, the browser has to subscribe again.
+func (n *WebPushNotifier) Notify(ctx context.Context, notification *Notification) error {
+	status := notification.Status
+	if status == "" {
+		status = StatusSucceeded
+	}
+	message := webPushMessage{
+		Title:       n.locale.status(notification, notification.PipelineRun),
+		Tag:         notification.Namespace + "/" + notification.PipelineRun,
+		Namespace:   notification.Namespace,
+		PipelineRun: notification.PipelineRun,
+		Status:      status,
+		URL:         notification.PayloadURL,
+	}
+	if n.template != nil {
+		body, err := Render(n.template, notification)
+		if err != nil {
+			return err
+		}
+		message.Body = string(body)
+	} else {
+		var lines []string
+		for _, result := range notification.Results {
+			lines = append(lines, result.Name+": "+result.String())
+		}
+		message.Body = strings.Join(lines, "\n")
+	}
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	for len(payload) > maxWebPushPayload && message.Body != "" {
+		// Results are only a preview in browser notifications, cut the body until the payload fits
+		body := strings.TrimSuffix(message.Body, "…")
+		body = strings.ToValidUTF8(body[:max(len(body)-(len(payload)-maxWebPushPayload)-len("…"), 0)], "")
+		message.Body = body + "…"
+		if body == "" {
+			message.Body = ""
+		}
+		if payload, err = json.Marshal(message); err != nil {
+			return err
+		}
+	}
+	var errs []error
+	for i := range n.opts.Subscriptions {
+		if err := n.push(ctx, &n.opts.Subscriptions[i], payload); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("Failed to push notification for pipelinerun %s: %w", notification.PipelineRun, err)
+	}
+	return nil
+}
+
+// push sends the encrypted payload to the push service of the subscription
+func (n *WebPushNotifier) push(ctx context.Context, subscription *WebPushSubscription, payload []byte) error {
+	keys, err := subscription.keys()
+	if err != nil {
+		return err
+	}
+	serverKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	body, err := encryptWebPush(keys, serverKey, salt, payload)
+	if err != nil {
+		return err
+	}
+	authorization, err := n.opts.VAPID.authorization(subscription.Endpoint, time.Now())
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(n.opts.TTL.Seconds())))
+	req.Header.Set("Urgency", n.opts.Urgency)
+	resp, err := n.opts.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return nil
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("Push service %s responded with status %d", req.URL.Host, resp.StatusCode)
+	}
+	return nil
+}
+
+// encryptWebPush encrypts the payload for the browser keys as a single aes128gcm record (RFC 8188),
+// with a key derived from an ECDH exchange with the ephemeral server key and the auth secret (RFC 8291)
+func encryptWebPush(keys *webPushKeys, serverKey *ecdh.PrivateKey, salt []byte, payload []byte) ([]byte, error) {
+	shared, err := serverKey.ECDH(keys.public)
+	if err != nil {
+		return nil, err
+	}
+	serverPublic := serverKey.PublicKey().Bytes()
+	keyInfo := append(append([]byte("WebPush: info\x00"), keys.public.Bytes()...), serverPublic...)
+	ikm := hkdfSHA256(keys.auth, shared, keyInfo, 32)
+	cek := hkdfSHA256(salt, ikm, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	nonce := hkdfSHA256(salt, ikm, []byte("Content-Encoding: nonce\x00"), 12)
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, 0, 21+len(serverPublic))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, webPushRecordSize)
+	header = append(header, byte(len(serverPublic)))
+	header = append(header, serverPublic...)
+	// 0x02 delimits the last record, which is not padded
+	plaintext := append(append(make([]byte, 0, len(payload)+1), payload...), 0x02)
+	return gcm.Seal(header, nonce, plaintext, nil), nil
+}
+
+// hkdfSHA256 derives length bytes, at most 32, from the input keying material with HKDF (RFC 5869)
+func hkdfSHA256(salt []byte, ikm []byte, info []byte, length int) []byte {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(ikm)
+	expand := hmac.New(sha256.New, extract.Sum(nil))
+	expand.Write(info)
+	expand.Write([]byte{1})
+	return expand.Sum(nil)[:length]
+}
+
+// decodeBase64URL decodes base64url, with or without padding
+func decodeBase64URL(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
@@ -0,0 +1,206 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// RFC 8291 appendix A
+const (
+	webPushBrowserPrivateKey = "q1dXpw3UpT5VOmu_cf_v6ih07Aems3njxI-JWgLcM94"
+	webPushBrowserPublicKey  = "BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4"
+	webPushAuthSecret        = "BTBZMqHH6r4Tts7J_aSIgg"
+	webPushServerPrivateKey  = "yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw"
+)
+
+// decryptWebPush decrypts a single record aes128gcm body with the browser private key of RFC 8291
+func decryptWebPush(body []byte) []byte {
+	browserKey, err := ecdh.P256().NewPrivateKey(mustDecodeBase64URL(webPushBrowserPrivateKey))
+	Expect(err).NotTo(HaveOccurred())
+	salt, keyLength := body[:16], int(body[20])
+	serverKey, err := ecdh.P256().NewPublicKey(body[21 : 21+keyLength])
+	Expect(err).NotTo(HaveOccurred())
+	shared, err := browserKey.ECDH(serverKey)
+	Expect(err).NotTo(HaveOccurred())
+	keyInfo := append(append([]byte("WebPush: info\x00"), browserKey.PublicKey().Bytes()...), serverKey.Bytes()...)
+	ikm := hkdfSHA256(mustDecodeBase64URL(webPushAuthSecret), shared, keyInfo, 32)
+	block, err := aes.NewCipher(hkdfSHA256(salt, ikm, []byte("Content-Encoding: aes128gcm\x00"), 16))
+	Expect(err).NotTo(HaveOccurred())
+	gcm, err := cipher.NewGCM(block)
+	Expect(err).NotTo(HaveOccurred())
+	plaintext, err := gcm.Open(nil, hkdfSHA256(salt, ikm, []byte("Content-Encoding: nonce\x00"), 12), body[21+keyLength:], nil)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(plaintext[len(plaintext)-1]).To(Equal(byte(2)))
+	return plaintext[:len(plaintext)-1]
+}
+
+func mustDecodeBase64URL(value string) []byte {
+	decoded, err := decodeBase64URL(value)
+	Expect(err).NotTo(HaveOccurred())
+	return decoded
+}
+
+var _ = Describe("WebPushNotifier", func() {
+	var (
+		server   *httptest.Server
+		mu       sync.Mutex
+		requests []*http.Request
+		bodies   [][]byte
+		status   int
+		vapid    *VAPIDKeys
+	)
+
+	subscription := func(path string) WebPushSubscription {
+		return WebPushSubscription{
+			Endpoint: server.URL + path,
+			Keys:     WebPushKeys{P256dh: webPushBrowserPublicKey, Auth: webPushAuthSecret},
+		}
+	}
+
+	BeforeEach(func() {
+		requests, bodies, status = nil, nil, http.StatusCreated
+		server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			body, _ := io.ReadAll(req.Body)
+			mu.Lock()
+			requests, bodies = append(requests, req), append(bodies, body)
+			mu.Unlock()
+			if strings.HasSuffix(req.URL.Path, "/expired") {
+				w.WriteHeader(http.StatusGone)
+				return
+			}
+			w.WriteHeader(status)
+		}))
+		DeferCleanup(server.Close)
+		var err error
+		vapid, err = NewVAPIDKeys(webPushServerPrivateKey, "mailto:ci@example.com")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should encrypt payloads as specified by RFC 8291", func() {
+		sub := WebPushSubscription{Keys: WebPushKeys{P256dh: webPushBrowserPublicKey, Auth: webPushAuthSecret}}
+		keys, err := sub.keys()
+		Expect(err).NotTo(HaveOccurred())
+		serverKey, err := ecdh.P256().NewPrivateKey(mustDecodeBase64URL(webPushServerPrivateKey))
+		Expect(err).NotTo(HaveOccurred())
+		body, err := encryptWebPush(keys, serverKey, mustDecodeBase64URL("DGv6ra1nlYgDCS1FRnbzlw"), []byte("When I grow up, I want to be a watermelon"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(base64.RawURLEncoding.EncodeToString(body)).To(Equal("DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN"))
+	})
+
+	It("should push encrypted notifications authenticated with VAPID", func() {
+		n, err := NewWebPushNotifier(WebPushOptions{
+			VAPID: vapid, Subscriptions: []WebPushSubscription{subscription("/push/1")},
+			Urgency: WebPushUrgencyHigh, HTTPClient: server.Client(),
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n.Notify(context.Background(), &Notification{
+			PipelineRun: "build-1", Namespace: "tenant", Status: StatusFailed,
+			Results: []Result{{Name: "IMAGE_URL", Value: "quay.io/tenant/app"}},
+		})).To(Succeed())
+		Expect(requests).To(HaveLen(1))
+		Expect(requests[0].Header.Get("Content-Encoding")).To(Equal("aes128gcm"))
+		Expect(requests[0].Header.Get("TTL")).To(Equal("86400"))
+		Expect(requests[0].Header.Get("Urgency")).To(Equal("high"))
+
+		message := map[string]string{}
+		Expect(json.Unmarshal(decryptWebPush(bodies[0]), &message)).To(Succeed())
+		Expect(message).To(Equal(map[string]string{
+			"title": "PipelineRun build-1 failed", "body": "IMAGE_URL: quay.io/tenant/app", "tag": "tenant/build-1",
+			"namespace": "tenant", "pipelineRun": "build-1", "status": StatusFailed,
+		}))
+
+		token, ok := strings.CutPrefix(requests[0].Header.Get("Authorization"), "vapid t=")
+		Expect(ok).To(BeTrue())
+		token, key, _ := strings.Cut(token, ", k=")
+		Expect(key).To(Equal(vapid.PublicKey))
+		parts := strings.Split(token, ".")
+		Expect(parts).To(HaveLen(3))
+		claims := map[string]any{}
+		Expect(json.Unmarshal(mustDecodeBase64URL(parts[1]), &claims)).To(Succeed())
+		Expect(claims).To(HaveKeyWithValue("aud", server.URL))
+		Expect(claims).To(HaveKeyWithValue("sub", "mailto:ci@example.com"))
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		signature := mustDecodeBase64URL(parts[2])
+		Expect(ecdsa.Verify(&vapid.privateKey.PublicKey, digest[:],
+			new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:]))).To(BeTrue())
+	})
+
+	It("should skip expired subscriptions and fail on push service errors", func() {
+		n, err := NewWebPushNotifier(WebPushOptions{
+			VAPID:         vapid,
+			Subscriptions: []WebPushSubscription{subscription("/push/expired"), subscription("/push/2")},
+			Template:      "{{ .PipelineRun }} {{ .Status }}",
+			HTTPClient:    server.Client(),
+		})
+		Expect(err).NotTo(HaveOccurred())
+		notification := &Notification{PipelineRun: "build-1", Namespace: "tenant", Status: StatusSucceeded}
+		Expect(n.Notify(context.Background(), notification)).To(Succeed())
+		Expect(requests).To(HaveLen(2))
+		Expect(string(decryptWebPush(bodies[1]))).To(ContainSubstring(`"body":"build-1 Succeeded"`))
+
+		status = http.StatusTooManyRequests
+		Expect(n.Notify(context.Background(), notification)).To(MatchError(ContainSubstring("429")))
+	})
+
+	It("should cut long bodies to fit the payload limit", func() {
+		n, err := NewWebPushNotifier(WebPushOptions{
+			VAPID: vapid, Subscriptions: []WebPushSubscription{subscription("/push/1")}, HTTPClient: server.Client(),
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n.Notify(context.Background(), &Notification{
+			PipelineRun: "build-1", Namespace: "tenant",
+			Results: []Result{{Name: "LOG", Value: strings.Repeat("é\"", 3000)}},
+		})).To(Succeed())
+		Expect(len(bodies[0])).To(BeNumerically("<=", 4096))
+		message := map[string]string{}
+		Expect(json.Unmarshal(decryptWebPush(bodies[0]), &message)).To(Succeed())
+		Expect(message["body"]).To(HavePrefix("LOG: é\""))
+		Expect(message["body"]).To(HaveSuffix("…"))
+	})
+
+	It("should reject invalid subscriptions and keys", func() {
+		_, err := NewWebPushNotifier(WebPushOptions{VAPID: vapid, Subscriptions: []WebPushSubscription{{
+			Endpoint: "http://push.example.com/1", Keys: WebPushKeys{P256dh: webPushBrowserPublicKey, Auth: webPushAuthSecret},
+		}}})
+		Expect(err).To(MatchError(ContainSubstring("https")))
+		_, err = NewWebPushNotifier(WebPushOptions{VAPID: vapid, Subscriptions: []WebPushSubscription{{
+			Endpoint: "https://push.example.com/1", Keys: WebPushKeys{P256dh: webPushAuthSecret, Auth: webPushAuthSecret},
+		}}})
+		Expect(err).To(MatchError(ContainSubstring("p256dh")))
+		_, err = NewWebPushNotifier(WebPushOptions{VAPID: vapid, Urgency: "urgent"})
+		Expect(err).To(HaveOccurred())
+		_, err = NewVAPIDKeys(webPushServerPrivateKey, "ci@example.com")
+		Expect(err).To(HaveOccurred())
+	})
+})
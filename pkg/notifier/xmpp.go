@@ -0,0 +1,454 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// XML namespaces of the XMPP protocol
+const (
+	xmppNSStream   = "http://etherx.jabber.org/streams"
+	xmppNSTLS      = "urn:ietf:params:xml:ns:xmpp-tls"
+	xmppNSSASL     = "urn:ietf:params:xml:ns:xmpp-sasl"
+	xmppNSBind     = "urn:ietf:params:xml:ns:xmpp-bind"
+	xmppNSMUC      = "http://jabber.org/protocol/muc"
+	xmppClientPort = "5222"
+)
+
+// XMPPOptions configures an XMPPNotifier
+type XMPPOptions struct {
+	// JID is the bare JID of the account posting messages, e.g. ci@example.com
+	JID string
+	// Password is the password of the account
+	Password string
+	// Server is the host:port of the XMPP server, defaults to the domain of the JID on port 5222
+	Server string
+	// Rooms are the bare JIDs of the multi-user chat rooms messages are posted to, e.g. builds@conference.example.com
+	Rooms []string
+	// Nick is the nick of the account in the rooms, defaults to the local part of the JID
+	Nick string
+	// Insecure connects without STARTTLS, the password is then sent in clear text
+	Insecure bool
+	// Template is an optional Go template rendering the message body
+	Template string
+	// Locale is the language of the built-in messages, see Locales. Defaults to DefaultLocale.
+	Locale string
+	// Timeout is the deadline of a whole connection, from dialing to closing the stream
+	Timeout time.Duration
+	// TLSConfig is used for STARTTLS, defaults to verifying the domain of the JID
+	TLSConfig *tls.Config
+	// Guard blocks connections to the server at the addresses it blocks, if set
+	Guard *AddressGuard
+}
+
+// XMPPNotifier posts notifications to XMPP multi-user chat rooms.
+// It connects for every message, which suits the low rate of CI notifications and needs no
+// connection to be kept alive.
+type XMPPNotifier struct {
+	opts     XMPPOptions
+	local    string
+	domain   string
+	template *template.Template
+	locale   *Locale
+}
+
+// NewXMPPNotifier creates an XMPPNotifier from the given options
+func NewXMPPNotifier(opts XMPPOptions) (*XMPPNotifier, error) {
+	local, domain, ok := strings.Cut(opts.JID, "@")
+	if !ok || local == "" || domain == "" || strings.Contains(domain, "/") {
+		return nil, fmt.Errorf("Invalid XMPP JID %s, expected a bare JID such as ci@example.com", opts.JID)
+	}
+	if opts.Password == "" {
+		return nil, errors.New("XMPP password must be set")
+	}
+	if len(opts.Rooms) == 0 {
+		return nil, errors.New("At least one XMPP room must be set")
+	}
+	for _, room := range opts.Rooms {
+		if !strings.Contains(room, "@") || strings.Contains(room, "/") {
+			return nil, fmt.Errorf("Invalid XMPP room %s, expected a bare JID such as builds@conference.example.com", room)
+		}
+	}
+	if opts.Server == "" {
+		opts.Server = net.JoinHostPort(domain, xmppClientPort)
+	}
+	if opts.Nick == "" {
+		opts.Nick = local
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultWebhookTimeout
+	}
+	locale, err := LookupLocale(opts.Locale)
+	if err != nil {
+		return nil, err
+	}
+	n := &XMPPNotifier{opts: opts, local: local, domain: domain, locale: locale}
+	if opts.Template != "" {
+		tmpl, err := NewTemplate("xmpp", opts.Template)
+		if err != nil {
+			return nil, err
+		}
+		n.template = tmpl
+	}
+	return n, nil
+}
+
+// Notify posts the notification to the rooms
+func (n *XMPPNotifier) Notify(ctx context.Context, notification *Notification) error {
+	text, err := n.text(notification)
+	if err != nil {
+		return err
+	}
+	err = n.session(ctx, true, func(conn *xmppConn) error {
+		return n.broadcast(conn, text)
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to post XMPP message for pipelinerun %s: %w", notification.PipelineRun, err)
+	}
+	return nil
+}
+
+// Probe connects to the server and authenticates, which checks the credentials of the notifier
+func (n *XMPPNotifier) Probe(ctx context.Context) error {
+	return n.session(ctx, false, func(*xmppConn) error { return nil })
+}
+
+// NotifySummary posts the summary to the rooms
+func (n *XMPPNotifier) NotifySummary(ctx context.Context, summary *Summary) error {
+	var buf strings.Builder
+	buf.WriteString(n.locale.summary(summary, summary.Namespace))
+	for _, stats := range summary.TopFailures {
+		fmt.Fprintf(&buf, "\n• %s: %s", stats.Pipeline, fmt.Sprintf(n.locale.Failures, stats.Failures, stats.Runs))
+	}
+	err := n.session(ctx, true, func(conn *xmppConn) error {
+		return n.broadcast(conn, buf.String())
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to post XMPP summary for namespace %s: %w", summary.Namespace, err)
+	}
+	return nil
+}
+
+func (n *XMPPNotifier) text(notification *Notification) (string, error) {
+	if n.template != nil {
+		text, err := Render(n.template, notification)
+		return string(text), err
+	}
+	var buf strings.Builder
+	buf.WriteString(n.locale.status(notification, notification.Namespace+"/"+notification.PipelineRun))
+	for _, result := range notification.Results {
+		fmt.Fprintf(&buf, "\n• %s: %s", result.Name, result.String())
+	}
+	if notification.PayloadURL != "" {
+		fmt.Fprintf(&buf, "\n%s: %s", n.locale.FullResults, notification.PayloadURL)
+	}
+	return buf.String(), nil
+}
+
+// broadcast sends the text as a groupchat message to every room
+func (n *XMPPNotifier) broadcast(conn *xmppConn, text string) error {
+	for _, room := range n.opts.Rooms {
+		var body strings.Builder
+		_ = xml.EscapeText(&body, []byte(text))
+		err := conn.send(fmt.Sprintf("<message to='%s' type='groupchat' id='%s'><body>%s</body></message>",
+			xmlAttr(room), xmppID(), body.String()))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// session connects to the server, secures the stream, authenticates, binds a resource, joins the rooms
+// if requested, runs send and closes the stream
+func (n *XMPPNotifier) session(ctx context.Context, join bool, send func(*xmppConn) error) error {
+	ctx, cancel := context.WithTimeout(ctx, n.opts.Timeout)
+	defer cancel()
+	var dialer net.Dialer
+	raw, err := dialEgress(ctx, &dialer, n.opts.Guard, "tcp", n.opts.Server)
+	if err != nil {
+		return err
+	}
+	defer raw.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = raw.SetDeadline(deadline)
+	}
+	conn := &xmppConn{conn: raw}
+	features, err := conn.open(n.domain)
+	if err != nil {
+		return err
+	}
+	if !n.opts.Insecure {
+		if features.StartTLS == nil {
+			return errors.New("XMPP server does not support STARTTLS")
+		}
+		if err := conn.send("<starttls xmlns='" + xmppNSTLS + "'/>"); err != nil {
+			return err
+		}
+		if _, err := conn.expect("proceed"); err != nil {
+			return err
+		}
+		config := n.opts.TLSConfig
+		if config == nil {
+			config = &tls.Config{ServerName: n.domain, MinVersion: tls.VersionTLS12}
+		}
+		tlsConn := tls.Client(raw, config)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return err
+		}
+		conn.conn = tlsConn
+		if features, err = conn.open(n.domain); err != nil {
+			return err
+		}
+	}
+	if !slices.Contains(features.Mechanisms.Mechanism, "PLAIN") {
+		return errors.New("XMPP server does not support SASL PLAIN authentication")
+	}
+	credentials := base64.StdEncoding.EncodeToString([]byte("\x00" + n.local + "\x00" + n.opts.Password))
+	if err := conn.send("<auth xmlns='" + xmppNSSASL + "' mechanism='PLAIN'>" + credentials + "</auth>"); err != nil {
+		return err
+	}
+	if _, err := conn.expect("success"); err != nil {
+		return err
+	}
+	if _, err := conn.open(n.domain); err != nil {
+		return err
+	}
+	if err := conn.send("<iq type='set' id='bind'><bind xmlns='" + xmppNSBind + "'><resource>notification-service-" +
+		xmppID() + "</resource></bind></iq>"); err != nil {
+		return err
+	}
+	if _, err := conn.expect("iq"); err != nil {
+		return err
+	}
+	if join {
+		for _, room := range n.opts.Rooms {
+			if err := n.join(conn, room); err != nil {
+				return err
+			}
+		}
+	}
+	if err := send(conn); err != nil {
+		return err
+	}
+	return conn.send("</stream:stream>")
+}
+
+// join enters the room and waits for the presence of the account in it, flagged with status 110
+func (n *XMPPNotifier) join(conn *xmppConn, room string) error {
+	occupant := room + "/" + n.opts.Nick
+	err := conn.send("<presence to='" + xmlAttr(occupant) + "'><x xmlns='" + xmppNSMUC + "'><history maxstanzas='0'/></x></presence>")
+	if err != nil {
+		return err
+	}
+	for {
+		element, err := conn.expect("presence")
+		if err != nil {
+			return err
+		}
+		if element.attr("from") != occupant {
+			continue
+		}
+		if element.attr("type") == "error" {
+			return fmt.Errorf("Failed to join XMPP room %s: %s", room, element.errorCondition())
+		}
+		if element.hasSelfPresence() {
+			return nil
+		}
+	}
+}
+
+// xmppFeatures are the stream features advertised by the server
+type xmppFeatures struct {
+	StartTLS   *struct{} `xml:"urn:ietf:params:xml:ns:xmpp-tls starttls"`
+	Mechanisms struct {
+		Mechanism []string `xml:"mechanism"`
+	} `xml:"urn:ietf:params:xml:ns:xmpp-sasl mechanisms"`
+}
+
+// xmppElement is a top level element of the stream
+type xmppElement struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Inner   []byte     `xml:",innerxml"`
+}
+
+func (e *xmppElement) attr(name string) string {
+	for _, attr := range e.Attrs {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// hasSelfPresence returns whether the presence is the one of the account itself in a room
+func (e *xmppElement) hasSelfPresence() bool {
+	var presence struct {
+		User struct {
+			Statuses []struct {
+				Code string `xml:"code,attr"`
+			} `xml:"status"`
+		} `xml:"http://jabber.org/protocol/muc#user x"`
+	}
+	_ = xml.Unmarshal(append(append([]byte("<presence>"), e.Inner...), "</presence>"...), &presence)
+	for _, status := range presence.User.Statuses {
+		if status.Code == "110" {
+			return true
+		}
+	}
+	return false
+}
+
+// errorCondition returns the name of the defined condition of an error stanza or a SASL failure
+func (e *xmppElement) errorCondition() string {
+	var condition struct {
+		Inner []struct {
+			XMLName  xml.Name
+			Children []struct {
+				XMLName xml.Name
+			} `xml:",any"`
+		} `xml:",any"`
+	}
+	_ = xml.Unmarshal(append(append([]byte("<e>"), e.Inner...), "</e>"...), &condition)
+	for _, child := range condition.Inner {
+		if e.XMLName.Local == "failure" {
+			return child.XMLName.Local
+		}
+		if child.XMLName.Local == "error" && len(child.Children) > 0 {
+			return child.Children[0].XMLName.Local
+		}
+	}
+	return "unknown error"
+}
+
+// xmppConn is an XML stream with an XMPP server
+type xmppConn struct {
+	conn    net.Conn
+	decoder *xml.Decoder
+}
+
+func (c *xmppConn) send(data string) error {
+	_, err := io.WriteString(c.conn, data)
+	return err
+}
+
+// open starts a new stream, as required after STARTTLS and authentication, and returns its features
+func (c *xmppConn) open(domain string) (*xmppFeatures, error) {
+	err := c.send("<?xml version='1.0'?><stream:stream to='" + xmlAttr(domain) +
+		"' version='1.0' xmlns='jabber:client' xmlns:stream='" + xmppNSStream + "'>")
+	if err != nil {
+		return nil, err
+	}
+	c.decoder = xml.NewDecoder(c.conn)
+	for {
+		token, err := c.decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read XMPP stream: %w", err)
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			if start.Name.Space != xmppNSStream || start.Name.Local != "stream" {
+				return nil, fmt.Errorf("Unexpected XMPP stream element %s", start.Name.Local)
+			}
+			break
+		}
+	}
+	element, err := c.expect("features")
+	if err != nil {
+		return nil, err
+	}
+	features := &xmppFeatures{}
+	err = xml.Unmarshal(append(append([]byte("<features>"), element.Inner...), "</features>"...), features)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode XMPP stream features: %w", err)
+	}
+	return features, nil
+}
+
+// expect reads top level elements until one with the name, which is returned.
+// Stream errors, SASL failures and error stanzas other than presences fail.
+func (c *xmppConn) expect(name string) (*xmppElement, error) {
+	for {
+		token, err := c.decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read XMPP stream while waiting for %s: %w", name, err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			if _, closed := token.(xml.EndElement); closed {
+				return nil, errors.New("XMPP server closed the stream")
+			}
+			continue
+		}
+		element := &xmppElement{}
+		if err := c.decoder.DecodeElement(element, &start); err != nil {
+			return nil, fmt.Errorf("Failed to decode XMPP %s: %w", start.Name.Local, err)
+		}
+		switch {
+		case start.Name.Local == "error" && start.Name.Space == xmppNSStream:
+			return nil, fmt.Errorf("XMPP stream error: %s", element.errorConditionOfStream())
+		case start.Name.Local == "failure":
+			return nil, fmt.Errorf("XMPP negotiation failed: %s", element.errorCondition())
+		case start.Name.Local == name && (name == "presence" || element.attr("type") != "error"):
+			return element, nil
+		case element.attr("type") == "error" && start.Name.Local != "presence":
+			return nil, fmt.Errorf("XMPP %s failed: %s", start.Name.Local, element.errorCondition())
+		}
+	}
+}
+
+// errorConditionOfStream returns the name of the condition of a stream error
+func (e *xmppElement) errorConditionOfStream() string {
+	var condition struct {
+		Children []struct {
+			XMLName xml.Name
+		} `xml:",any"`
+	}
+	_ = xml.Unmarshal(append(append([]byte("<e>"), e.Inner...), "</e>"...), &condition)
+	if len(condition.Children) > 0 {
+		return condition.Children[0].XMLName.Local
+	}
+	return "unknown error"
+}
+
+// xmppID returns a random stanza ID
+func xmppID() string {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// xmlAttr escapes a value quoted with single quotes in an attribute
+func xmlAttr(value string) string {
+	var buf strings.Builder
+	_ = xml.EscapeText(&buf, []byte(value))
+	return buf.String()
+}
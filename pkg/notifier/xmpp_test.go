@@ -0,0 +1,222 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// fakeXMPPServer accepts XMPP client connections of ci@example.com and records the messages posted to rooms
+type fakeXMPPServer struct {
+	listener net.Listener
+	// tlsConfig enables STARTTLS, if set
+	tlsConfig *tls.Config
+	// rooms are the rooms clients may join
+	rooms    []string
+	mu       sync.Mutex
+	messages []string
+}
+
+func (s *fakeXMPPServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeXMPPServer) handle(conn net.Conn) {
+	defer func() { conn.Close() }()
+	reply := func(data string) { _, _ = io.WriteString(conn, data) }
+	decoder := xml.NewDecoder(conn)
+	secured, authenticated := false, false
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return
+		}
+		if _, ok := token.(xml.EndElement); ok {
+			reply("</stream:stream>")
+			return
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if start.Name.Local == "stream" {
+			reply("<?xml version='1.0'?><stream:stream xmlns='jabber:client' xmlns:stream='http://etherx.jabber.org/streams' " +
+				"id='1' from='example.com' version='1.0'><stream:features>")
+			switch {
+			case authenticated:
+				reply("<bind xmlns='urn:ietf:params:xml:ns:xmpp-bind'/>")
+			case s.tlsConfig != nil && !secured:
+				reply("<starttls xmlns='urn:ietf:params:xml:ns:xmpp-tls'><required/></starttls>")
+			default:
+				reply("<mechanisms xmlns='urn:ietf:params:xml:ns:xmpp-sasl'><mechanism>SCRAM-SHA-1</mechanism><mechanism>PLAIN</mechanism></mechanisms>")
+			}
+			reply("</stream:features>")
+			continue
+		}
+		element := &xmppElement{}
+		if err := decoder.DecodeElement(element, &start); err != nil {
+			return
+		}
+		switch start.Name.Local {
+		case "starttls":
+			reply("<proceed xmlns='urn:ietf:params:xml:ns:xmpp-tls'/>")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if tlsConn.Handshake() != nil {
+				return
+			}
+			conn, decoder, secured = tlsConn, xml.NewDecoder(tlsConn), true
+		case "auth":
+			if decoded, _ := base64.StdEncoding.DecodeString(string(element.Inner)); string(decoded) != "\x00ci\x00secret" {
+				reply("<failure xmlns='urn:ietf:params:xml:ns:xmpp-sasl'><not-authorized/></failure>")
+				continue
+			}
+			reply("<success xmlns='urn:ietf:params:xml:ns:xmpp-sasl'/>")
+			decoder, authenticated = xml.NewDecoder(conn), true
+		case "iq":
+			reply("<iq type='result' id='" + element.attr("id") + "'><bind xmlns='urn:ietf:params:xml:ns:xmpp-bind'>" +
+				"<jid>ci@example.com/notification-service</jid></bind></iq>")
+		case "presence":
+			to := element.attr("to")
+			room, _, _ := strings.Cut(to, "/")
+			if !slices.Contains(s.rooms, room) {
+				reply("<presence from='" + to + "' type='error'><error type='cancel'>" +
+					"<item-not-found xmlns='urn:ietf:params:xml:ns:xmpp-stanzas'/></error></presence>")
+				continue
+			}
+			reply("<presence from='" + room + "/alice'><x xmlns='http://jabber.org/protocol/muc#user'/></presence>")
+			reply("<presence from='" + to + "'><x xmlns='http://jabber.org/protocol/muc#user'>" +
+				"<status code='110'/></x></presence>")
+		case "message":
+			var message struct {
+				Body string `xml:"body"`
+			}
+			_ = xml.Unmarshal(append(append([]byte("<message>"), element.Inner...), "</message>"...), &message)
+			s.mu.Lock()
+			s.messages = append(s.messages, fmt.Sprintf("%s %s: %s", element.attr("to"), element.attr("type"), message.Body))
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *fakeXMPPServer) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.messages)
+}
+
+var _ = Describe("XMPPNotifier", func() {
+	var server *fakeXMPPServer
+
+	notification := &Notification{
+		PipelineRun: "build-1", Namespace: "tenant", Status: StatusFailed,
+		Results: []Result{{Name: "IMAGE_URL", Value: "quay.io/tenant/app:<latest>"}},
+	}
+
+	BeforeEach(func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		server = &fakeXMPPServer{listener: listener, rooms: []string{"builds@conference.example.com", "ops@conference.example.com"}}
+		go server.serve()
+		DeferCleanup(listener.Close)
+	})
+
+	It("should post the notification to every room", func() {
+		n, err := NewXMPPNotifier(XMPPOptions{
+			JID: "ci@example.com", Password: "secret", Server: server.listener.Addr().String(), Insecure: true,
+			Rooms: []string{"builds@conference.example.com", "ops@conference.example.com"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n.Notify(context.Background(), notification)).To(Succeed())
+		Eventually(server.received).Should(Equal([]string{
+			"builds@conference.example.com groupchat: PipelineRun tenant/build-1 failed\n• IMAGE_URL: quay.io/tenant/app:<latest>",
+			"ops@conference.example.com groupchat: PipelineRun tenant/build-1 failed\n• IMAGE_URL: quay.io/tenant/app:<latest>",
+		}))
+	})
+
+	It("should fail to post to rooms it cannot join", func() {
+		n, err := NewXMPPNotifier(XMPPOptions{
+			JID: "ci@example.com", Password: "secret", Server: server.listener.Addr().String(), Insecure: true,
+			Rooms: []string{"secret@conference.example.com"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n.Notify(context.Background(), notification)).To(MatchError(ContainSubstring("item-not-found")))
+		Expect(server.received()).To(BeEmpty())
+	})
+
+	It("should authenticate over STARTTLS", func() {
+		certified := httptest.NewUnstartedServer(http.NotFoundHandler())
+		certified.StartTLS()
+		server.tlsConfig = &tls.Config{Certificates: certified.TLS.Certificates}
+		rootCAs := certified.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+		certified.Close()
+
+		opts := XMPPOptions{
+			JID: "ci@example.com", Password: "secret", Server: server.listener.Addr().String(),
+			Rooms:     []string{"builds@conference.example.com"},
+			Template:  "{{ .PipelineRun }} is {{ .Status }}",
+			TLSConfig: &tls.Config{RootCAs: rootCAs, ServerName: "example.com"},
+		}
+		n, err := NewXMPPNotifier(opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n.Probe(context.Background())).To(Succeed())
+		Expect(n.Notify(context.Background(), notification)).To(Succeed())
+		Eventually(server.received).Should(Equal([]string{"builds@conference.example.com groupchat: build-1 is Failed"}))
+
+		opts.Password = "wrong"
+		n, err = NewXMPPNotifier(opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n.Probe(context.Background())).To(MatchError(ContainSubstring("not-authorized")))
+	})
+
+	It("should require STARTTLS unless insecure", func() {
+		n, err := NewXMPPNotifier(XMPPOptions{
+			JID: "ci@example.com", Password: "secret", Server: server.listener.Addr().String(),
+			Rooms: []string{"builds@conference.example.com"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n.Probe(context.Background())).To(MatchError(ContainSubstring("STARTTLS")))
+	})
+
+	It("should reject invalid options", func() {
+		_, err := NewXMPPNotifier(XMPPOptions{JID: "example.com", Password: "secret", Rooms: []string{"builds@conference.example.com"}})
+		Expect(err).To(HaveOccurred())
+		_, err = NewXMPPNotifier(XMPPOptions{JID: "ci@example.com", Rooms: []string{"builds@conference.example.com"}})
+		Expect(err).To(HaveOccurred())
+		_, err = NewXMPPNotifier(XMPPOptions{JID: "ci@example.com", Password: "secret", Rooms: []string{"builds@conference.example.com/ci"}})
+		Expect(err).To(HaveOccurred())
+	})
+})
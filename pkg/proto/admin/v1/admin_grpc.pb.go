@@ -0,0 +1,324 @@
+// Copyright 2024.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             (unknown)
+// source: admin/v1/admin.proto
+
+package adminv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	NotificationAdmin_ListDeliveries_FullMethodName       = "/admin.v1.NotificationAdmin/ListDeliveries"
+	NotificationAdmin_GetDelivery_FullMethodName          = "/admin.v1.NotificationAdmin/GetDelivery"
+	NotificationAdmin_ResendDelivery_FullMethodName       = "/admin.v1.NotificationAdmin/ResendDelivery"
+	NotificationAdmin_SendTestNotification_FullMethodName = "/admin.v1.NotificationAdmin/SendTestNotification"
+	NotificationAdmin_WatchDeliveries_FullMethodName      = "/admin.v1.NotificationAdmin/WatchDeliveries"
+)
+
+// NotificationAdminClient is the client API for NotificationAdmin service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// NotificationAdmin is served by the notification-service controller to query
+// and replay deliveries. It mirrors the REST API, calls are authenticated with a
+// Kubernetes bearer token in the "authorization" metadata and authorized against
+// the notificationdeliveries resource of the requested namespace.
+type NotificationAdminClient interface {
+	// ListDeliveries lists the delivery records of a namespace, without payloads.
+	ListDeliveries(ctx context.Context, in *ListDeliveriesRequest, opts ...grpc.CallOption) (*ListDeliveriesResponse, error)
+	// GetDelivery returns a delivery record, including its payload.
+	GetDelivery(ctx context.Context, in *GetDeliveryRequest, opts ...grpc.CallOption) (*Delivery, error)
+	// ResendDelivery sends the payload of a delivery to its destination again.
+	ResendDelivery(ctx context.Context, in *ResendDeliveryRequest, opts ...grpc.CallOption) (*DeliveryResult, error)
+	// SendTestNotification sends a notification to a destination of a NotificationService.
+	SendTestNotification(ctx context.Context, in *SendTestNotificationRequest, opts ...grpc.CallOption) (*DeliveryResult, error)
+	// WatchDeliveries streams the delivery records created in a namespace, without payloads.
+	WatchDeliveries(ctx context.Context, in *WatchDeliveriesRequest, opts ...grpc.CallOption) (NotificationAdmin_WatchDeliveriesClient, error)
+}
+
+type notificationAdminClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewNotificationAdminClient(cc grpc.ClientConnInterface) NotificationAdminClient {
+	return &notificationAdminClient{cc}
+}
+
+func (c *notificationAdminClient) ListDeliveries(ctx context.Context, in *ListDeliveriesRequest, opts ...grpc.CallOption) (*ListDeliveriesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListDeliveriesResponse)
+	err := c.cc.Invoke(ctx, NotificationAdmin_ListDeliveries_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationAdminClient) GetDelivery(ctx context.Context, in *GetDeliveryRequest, opts ...grpc.CallOption) (*Delivery, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Delivery)
+	err := c.cc.Invoke(ctx, NotificationAdmin_GetDelivery_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationAdminClient) ResendDelivery(ctx context.Context, in *ResendDeliveryRequest, opts ...grpc.CallOption) (*DeliveryResult, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeliveryResult)
+	err := c.cc.Invoke(ctx, NotificationAdmin_ResendDelivery_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationAdminClient) SendTestNotification(ctx context.Context, in *SendTestNotificationRequest, opts ...grpc.CallOption) (*DeliveryResult, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeliveryResult)
+	err := c.cc.Invoke(ctx, NotificationAdmin_SendTestNotification_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationAdminClient) WatchDeliveries(ctx context.Context, in *WatchDeliveriesRequest, opts ...grpc.CallOption) (NotificationAdmin_WatchDeliveriesClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &NotificationAdmin_ServiceDesc.Streams[0], NotificationAdmin_WatchDeliveries_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &notificationAdminWatchDeliveriesClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type NotificationAdmin_WatchDeliveriesClient interface {
+	Recv() (*Delivery, error)
+	grpc.ClientStream
+}
+
+type notificationAdminWatchDeliveriesClient struct {
+	grpc.ClientStream
+}
+
+func (x *notificationAdminWatchDeliveriesClient) Recv() (*Delivery, error) {
+	m := new(Delivery)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// NotificationAdminServer is the server API for NotificationAdmin service.
+// All implementations must embed UnimplementedNotificationAdminServer
+// for forward compatibility
+//
+// NotificationAdmin is served by the notification-service controller to query
+// and replay deliveries. It mirrors the REST API, calls are authenticated with a
+// Kubernetes bearer token in the "authorization" metadata and authorized against
+// the notificationdeliveries resource of the requested namespace.
+type NotificationAdminServer interface {
+	// ListDeliveries lists the delivery records of a namespace, without payloads.
+	ListDeliveries(context.Context, *ListDeliveriesRequest) (*ListDeliveriesResponse, error)
+	// GetDelivery returns a delivery record, including its payload.
+	GetDelivery(context.Context, *GetDeliveryRequest) (*Delivery, error)
+	// ResendDelivery sends the payload of a delivery to its destination again.
+	ResendDelivery(context.Context, *ResendDeliveryRequest) (*DeliveryResult, error)
+	// SendTestNotification sends a notification to a destination of a NotificationService.
+	SendTestNotification(context.Context, *SendTestNotificationRequest) (*DeliveryResult, error)
+	// WatchDeliveries streams the delivery records created in a namespace, without payloads.
+	WatchDeliveries(*WatchDeliveriesRequest, NotificationAdmin_WatchDeliveriesServer) error
+	mustEmbedUnimplementedNotificationAdminServer()
+}
+
+// UnimplementedNotificationAdminServer must be embedded to have forward compatible implementations.
+type UnimplementedNotificationAdminServer struct {
+}
+
+func (UnimplementedNotificationAdminServer) ListDeliveries(context.Context, *ListDeliveriesRequest) (*ListDeliveriesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDeliveries not implemented")
+}
+func (UnimplementedNotificationAdminServer) GetDelivery(context.Context, *GetDeliveryRequest) (*Delivery, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDelivery not implemented")
+}
+func (UnimplementedNotificationAdminServer) ResendDelivery(context.Context, *ResendDeliveryRequest) (*DeliveryResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ResendDelivery not implemented")
+}
+func (UnimplementedNotificationAdminServer) SendTestNotification(context.Context, *SendTestNotificationRequest) (*DeliveryResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SendTestNotification not implemented")
+}
+func (UnimplementedNotificationAdminServer) WatchDeliveries(*WatchDeliveriesRequest, NotificationAdmin_WatchDeliveriesServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchDeliveries not implemented")
+}
+func (UnimplementedNotificationAdminServer) mustEmbedUnimplementedNotificationAdminServer() {}
+
+// UnsafeNotificationAdminServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to NotificationAdminServer will
+// result in compilation errors.
+type UnsafeNotificationAdminServer interface {
+	mustEmbedUnimplementedNotificationAdminServer()
+}
+
+func RegisterNotificationAdminServer(s grpc.ServiceRegistrar, srv NotificationAdminServer) {
+	s.RegisterService(&NotificationAdmin_ServiceDesc, srv)
+}
+
+func _NotificationAdmin_ListDeliveries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDeliveriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationAdminServer).ListDeliveries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotificationAdmin_ListDeliveries_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationAdminServer).ListDeliveries(ctx, req.(*ListDeliveriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationAdmin_GetDelivery_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDeliveryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationAdminServer).GetDelivery(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotificationAdmin_GetDelivery_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationAdminServer).GetDelivery(ctx, req.(*GetDeliveryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationAdmin_ResendDelivery_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResendDeliveryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationAdminServer).ResendDelivery(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotificationAdmin_ResendDelivery_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationAdminServer).ResendDelivery(ctx, req.(*ResendDeliveryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationAdmin_SendTestNotification_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SendTestNotificationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationAdminServer).SendTestNotification(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotificationAdmin_SendTestNotification_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationAdminServer).SendTestNotification(ctx, req.(*SendTestNotificationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationAdmin_WatchDeliveries_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchDeliveriesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(NotificationAdminServer).WatchDeliveries(m, &notificationAdminWatchDeliveriesServer{ServerStream: stream})
+}
+
+type NotificationAdmin_WatchDeliveriesServer interface {
+	Send(*Delivery) error
+	grpc.ServerStream
+}
+
+type notificationAdminWatchDeliveriesServer struct {
+	grpc.ServerStream
+}
+
+func (x *notificationAdminWatchDeliveriesServer) Send(m *Delivery) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// NotificationAdmin_ServiceDesc is the grpc.ServiceDesc for NotificationAdmin service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var NotificationAdmin_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "admin.v1.NotificationAdmin",
+	HandlerType: (*NotificationAdminServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListDeliveries",
+			Handler:    _NotificationAdmin_ListDeliveries_Handler,
+		},
+		{
+			MethodName: "GetDelivery",
+			Handler:    _NotificationAdmin_GetDelivery_Handler,
+		},
+		{
+			MethodName: "ResendDelivery",
+			Handler:    _NotificationAdmin_ResendDelivery_Handler,
+		},
+		{
+			MethodName: "SendTestNotification",
+			Handler:    _NotificationAdmin_SendTestNotification_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchDeliveries",
+			Handler:       _NotificationAdmin_WatchDeliveries_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "admin/v1/admin.proto",
+}
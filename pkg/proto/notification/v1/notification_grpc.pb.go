@@ -0,0 +1,134 @@
+// Copyright 2024.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             (unknown)
+// source: notification/v1/notification.proto
+
+package notificationv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	NotificationReceiver_Notify_FullMethodName = "/notification.v1.NotificationReceiver/Notify"
+)
+
+// NotificationReceiverClient is the client API for NotificationReceiver service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// NotificationReceiver is implemented by services that want to receive
+// PipelineRun notifications from the notification-service controller.
+type NotificationReceiverClient interface {
+	// Notify delivers a single notification. Receivers should return an
+	// UNAVAILABLE status for transient failures so the call will be retried.
+	Notify(ctx context.Context, in *NotifyRequest, opts ...grpc.CallOption) (*NotifyResponse, error)
+}
+
+type notificationReceiverClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewNotificationReceiverClient(cc grpc.ClientConnInterface) NotificationReceiverClient {
+	return &notificationReceiverClient{cc}
+}
+
+func (c *notificationReceiverClient) Notify(ctx context.Context, in *NotifyRequest, opts ...grpc.CallOption) (*NotifyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(NotifyResponse)
+	err := c.cc.Invoke(ctx, NotificationReceiver_Notify_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// NotificationReceiverServer is the server API for NotificationReceiver service.
+// All implementations must embed UnimplementedNotificationReceiverServer
+// for forward compatibility
+//
+// NotificationReceiver is implemented by services that want to receive
+// PipelineRun notifications from the notification-service controller.
+type NotificationReceiverServer interface {
+	// Notify delivers a single notification. Receivers should return an
+	// UNAVAILABLE status for transient failures so the call will be retried.
+	Notify(context.Context, *NotifyRequest) (*NotifyResponse, error)
+	mustEmbedUnimplementedNotificationReceiverServer()
+}
+
+// UnimplementedNotificationReceiverServer must be embedded to have forward compatible implementations.
+type UnimplementedNotificationReceiverServer struct {
+}
+
+func (UnimplementedNotificationReceiverServer) Notify(context.Context, *NotifyRequest) (*NotifyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Notify not implemented")
+}
+func (UnimplementedNotificationReceiverServer) mustEmbedUnimplementedNotificationReceiverServer() {}
+
+// UnsafeNotificationReceiverServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to NotificationReceiverServer will
+// result in compilation errors.
+type UnsafeNotificationReceiverServer interface {
+	mustEmbedUnimplementedNotificationReceiverServer()
+}
+
+func RegisterNotificationReceiverServer(s grpc.ServiceRegistrar, srv NotificationReceiverServer) {
+	s.RegisterService(&NotificationReceiver_ServiceDesc, srv)
+}
+
+func _NotificationReceiver_Notify_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NotifyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationReceiverServer).Notify(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotificationReceiver_Notify_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationReceiverServer).Notify(ctx, req.(*NotifyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// NotificationReceiver_ServiceDesc is the grpc.ServiceDesc for NotificationReceiver service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var NotificationReceiver_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "notification.v1.NotificationReceiver",
+	HandlerType: (*NotificationReceiverServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Notify",
+			Handler:    _NotificationReceiver_Notify_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "notification/v1/notification.proto",
+}
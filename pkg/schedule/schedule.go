@@ -0,0 +1,150 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package schedule evaluates cron schedules in IANA time zones, so the schedule-like features of the
+// controller, such as summary reports and maintenance windows, follow the wall clock of the teams they serve.
+//
+// Schedules behave consistently across daylight saving time transitions: a wall clock time skipped when the
+// clocks go forward activates when they jump, and a wall clock time that occurs twice when they go back only
+// activates at its first occurrence.
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// maxActivationCandidates bounds the wall clock times Next skips because they do not exist or already passed,
+// which only happens around daylight saving time transitions
+const maxActivationCandidates = 1000
+
+// Schedule is a cron schedule evaluated in a time zone
+type Schedule struct {
+	// Location is the time zone of the wall clock the schedule follows
+	Location *time.Location
+
+	wallClock cron.Schedule
+}
+
+// LoadLocation returns the IANA time zone of the name, e.g. Europe/Berlin, or fallback if the name is empty
+// Return error if the time zone is unknown
+func LoadLocation(name string, fallback *time.Location) (*time.Location, error) {
+	if name == "" {
+		return fallback, nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("Unknown time zone %s: %w", name, err)
+	}
+	return location, nil
+}
+
+// Parse parses a standard cron expression with five fields, or a descriptor such as @daily, evaluated in
+// the location unless it is prefixed with CRON_TZ=<time zone> or TZ=<time zone>.
+// Return error if the expression or its time zone are not valid
+func Parse(expression string, location *time.Location) (*Schedule, error) {
+	expression = strings.TrimSpace(expression)
+	if strings.HasPrefix(expression, "CRON_TZ=") || strings.HasPrefix(expression, "TZ=") {
+		prefix, rest, _ := strings.Cut(expression, " ")
+		_, name, _ := strings.Cut(prefix, "=")
+		var err error
+		location, err = LoadLocation(name, location)
+		if err != nil {
+			return nil, err
+		}
+		expression = strings.TrimSpace(rest)
+	}
+	if location == nil {
+		location = time.UTC
+	}
+	wallClock, err := cron.ParseStandard(expression)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid schedule %s: %w", expression, err)
+	}
+	// The wall clock schedule is evaluated in UTC, which has no transitions, and mapped to the location by Next
+	if spec, ok := wallClock.(*cron.SpecSchedule); ok {
+		spec.Location = time.UTC
+	}
+	return &Schedule{Location: location, wallClock: wallClock}, nil
+}
+
+// Next returns the first activation of the schedule after t, or the zero time if there is none
+func (s *Schedule) Next(t time.Time) time.Time {
+	wall := wallClock(t.In(s.Location))
+	for i := 0; i < maxActivationCandidates; i++ {
+		wall = s.wallClock.Next(wall)
+		if wall.IsZero() {
+			return time.Time{}
+		}
+		// A wall clock time whose first occurrence is not after t occurs again after t when the clocks go back,
+		// and already activated
+		if activation := s.instant(wall); activation.After(t) {
+			return activation.In(t.Location())
+		}
+	}
+	return time.Time{}
+}
+
+// Window returns the start of the window of the schedule in progress at t, given how long its windows
+// last, and false if none is. Windows last the duration in elapsed time, whatever the transitions they span.
+func (s *Schedule) Window(t time.Time, duration time.Duration) (time.Time, bool) {
+	start := s.Next(t.Add(-duration))
+	if start.IsZero() || start.After(t) {
+		return time.Time{}, false
+	}
+	return start, true
+}
+
+// instant returns the first instant the clock of the location shows the wall clock time, or the instant
+// the clock jumps over it
+func (s *Schedule) instant(wall time.Time) time.Time {
+	candidate := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), 0, s.Location)
+	// Around a transition, the wall clock time may be shown with the offset of the zones before and after it
+	_, offset := candidate.Zone()
+	offsets := []int{offset}
+	start, end := candidate.ZoneBounds()
+	if !start.IsZero() {
+		_, before := start.Add(-time.Nanosecond).Zone()
+		offsets = append(offsets, before)
+	}
+	if !end.IsZero() {
+		_, after := end.Zone()
+		offsets = append(offsets, after)
+	}
+	var first time.Time
+	for _, offset := range offsets {
+		instant := wall.Add(-time.Duration(offset) * time.Second).In(s.Location)
+		if wallClock(instant).Equal(wall) && (first.IsZero() || instant.Before(first)) {
+			first = instant
+		}
+	}
+	if !first.IsZero() {
+		return first
+	}
+	// The clock jumped over the wall clock time, at the bound of the zone of the candidate on its side
+	if wallClock(candidate).After(wall) {
+		return start
+	}
+	return end
+}
+
+// wallClock returns the wall clock time of t in UTC, without its sub-second part
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/pkg/schedule"
+)
+
+var _ = Describe("Schedule", func() {
+	var berlin *time.Location
+
+	BeforeEach(func() {
+		var err error
+		berlin, err = schedule.LoadLocation("Europe/Berlin", time.UTC)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	utc := func(month time.Month, day int, hour int, minute int) time.Time {
+		return time.Date(2024, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	It("should load time zones", func() {
+		location, err := schedule.LoadLocation("", time.Local)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(location).To(Equal(time.Local))
+		Expect(berlin.String()).To(Equal("Europe/Berlin"))
+		_, err = schedule.LoadLocation("Mars/Olympus_Mons", time.UTC)
+		Expect(err).To(MatchError(ContainSubstring("Unknown time zone Mars/Olympus_Mons")))
+	})
+
+	It("should follow the wall clock of the time zone", func() {
+		s, err := schedule.Parse("0 9 * * 1", berlin)
+		Expect(err).NotTo(HaveOccurred())
+		// Mondays at 9:00 are at 7:00 UTC in summer and 8:00 UTC in winter
+		Expect(s.Next(utc(time.October, 20, 0, 0))).To(BeTemporally("==", utc(time.October, 21, 7, 0)))
+		Expect(s.Next(utc(time.October, 27, 0, 0))).To(BeTemporally("==", utc(time.October, 28, 8, 0)))
+	})
+
+	It("should prefer the time zone of the expression", func() {
+		s, err := schedule.Parse("CRON_TZ=Europe/Berlin 0 9 * * *", time.UTC)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s.Location).To(Equal(berlin))
+		s, err = schedule.Parse("TZ=America/New_York @daily", berlin)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s.Next(utc(time.May, 8, 12, 0))).To(BeTemporally("==", utc(time.May, 9, 4, 0)))
+		s, err = schedule.Parse("0 9 * * *", nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s.Location).To(Equal(time.UTC))
+	})
+
+	It("should reject invalid expressions", func() {
+		_, err := schedule.Parse("every monday", time.UTC)
+		Expect(err).To(MatchError(ContainSubstring("Invalid schedule every monday")))
+		_, err = schedule.Parse("CRON_TZ=Nowhere 0 9 * * *", time.UTC)
+		Expect(err).To(MatchError(ContainSubstring("Unknown time zone Nowhere")))
+	})
+
+	It("should activate times skipped when the clocks go forward when they jump", func() {
+		s, err := schedule.Parse("30 2 * * *", berlin)
+		Expect(err).NotTo(HaveOccurred())
+		// On March 31, the clocks jump from 2:00 to 3:00, at 1:00 UTC
+		next := s.Next(utc(time.March, 30, 12, 0))
+		Expect(next).To(BeTemporally("==", utc(time.March, 31, 1, 0)))
+		Expect(s.Next(next)).To(BeTemporally("==", utc(time.April, 1, 0, 30)))
+	})
+
+	It("should activate times occurring twice when the clocks go back once", func() {
+		s, err := schedule.Parse("30 2 * * *", berlin)
+		Expect(err).NotTo(HaveOccurred())
+		// On October 27, the clocks go back from 3:00 to 2:00, at 1:00 UTC
+		next := s.Next(utc(time.October, 26, 12, 0))
+		Expect(next).To(BeTemporally("==", utc(time.October, 27, 0, 30)))
+		Expect(s.Next(next)).To(BeTemporally("==", utc(time.October, 28, 1, 30)))
+		Expect(s.Next(utc(time.October, 27, 1, 15))).To(BeTemporally("==", utc(time.October, 28, 1, 30)))
+	})
+
+	It("should find the window in progress", func() {
+		s, err := schedule.Parse("0 22 * * 5", berlin)
+		Expect(err).NotTo(HaveOccurred())
+		start, ok := s.Window(utc(time.May, 10, 23, 0), 8*time.Hour)
+		Expect(ok).To(BeTrue())
+		Expect(start).To(BeTemporally("==", utc(time.May, 10, 20, 0)))
+		_, ok = s.Window(utc(time.May, 11, 4, 0), 8*time.Hour)
+		Expect(ok).To(BeFalse())
+	})
+})
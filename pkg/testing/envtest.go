@@ -0,0 +1,132 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testing
+
+import (
+	"context"
+	"fmt"
+	"go/build"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/konflux-ci/notification-service/api/v1alpha1"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	tektonv1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+)
+
+// TektonVersion is the version of Tekton Pipelines whose CRDs are installed by NewEnvironment
+const TektonVersion string = "v0.61.0"
+
+// CRDDirectoryPaths returns the paths of the CRDs of the controller and of the Tekton PipelineRun,
+// TaskRun and CustomRun CRDs, read from the module cache
+func CRDDirectoryPaths() []string {
+	_, file, _, _ := runtime.Caller(0)
+	modules := os.Getenv("GOMODCACHE")
+	if modules == "" {
+		modules = filepath.Join(build.Default.GOPATH, "pkg", "mod")
+	}
+	tekton := filepath.Join(modules, "github.com", "tektoncd", "pipeline@"+TektonVersion, "config", "300-crds")
+	return []string{
+		filepath.Join(filepath.Dir(file), "..", "..", "config", "crd", "bases"),
+		filepath.Join(tekton, "300-pipelinerun.yaml"),
+		filepath.Join(tekton, "300-taskrun.yaml"),
+		filepath.Join(tekton, "300-customrun.yaml"),
+	}
+}
+
+// NewEnvironment returns an envtest environment installing the CRDs of CRDDirectoryPaths.
+// The binaries of the test API server are found through the KUBEBUILDER_ASSETS environment variable.
+func NewEnvironment() *envtest.Environment {
+	return &envtest.Environment{
+		CRDDirectoryPaths:     CRDDirectoryPaths(),
+		ErrorIfCRDPathMissing: true,
+	}
+}
+
+// NewScheme returns a scheme of the Kubernetes, notification service, Tekton v1 and Tekton v1beta1 types
+// Return error if a type cannot be registered
+func NewScheme() (*k8sruntime.Scheme, error) {
+	scheme := k8sruntime.NewScheme()
+	for _, addToScheme := range []func(*k8sruntime.Scheme) error{
+		clientgoscheme.AddToScheme, v1alpha1.AddToScheme, tektonv1.AddToScheme, tektonv1beta1.AddToScheme,
+	} {
+		if err := addToScheme(scheme); err != nil {
+			return nil, fmt.Errorf("Failed to register types: %w", err)
+		}
+	}
+	return scheme, nil
+}
+
+// CreatePipelineRun creates a running PipelineRun of the build pipeline
+// Return error if the PipelineRun cannot be created
+func CreatePipelineRun(ctx context.Context, c client.Client, namespace string, name string) (*tektonv1.PipelineRun, error) {
+	pipelineRun := &tektonv1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: tektonv1.PipelineRunSpec{
+			PipelineRef: &tektonv1.PipelineRef{Name: "build"},
+		},
+	}
+	if err := c.Create(ctx, pipelineRun); err != nil {
+		return nil, fmt.Errorf("Failed to create pipelinerun %s: %w", name, err)
+	}
+	return pipelineRun, nil
+}
+
+// CompletePipelineRun ends the PipelineRun with the results, setting its Succeeded condition to
+// succeeded, as Tekton does once its tasks are done
+// Return error if the status cannot be updated
+func CompletePipelineRun(ctx context.Context, c client.Client, pipelineRun *tektonv1.PipelineRun,
+	succeeded corev1.ConditionStatus, results ...tektonv1.PipelineRunResult) error {
+	pipelineRun.Status.Conditions = duckv1.Conditions{{
+		Type:   apis.ConditionSucceeded,
+		Status: succeeded,
+	}}
+	now := metav1.Now()
+	pipelineRun.Status.CompletionTime = &now
+	pipelineRun.Status.Results = results
+	if err := c.Status().Update(ctx, pipelineRun); err != nil {
+		return fmt.Errorf("Failed to update status of pipelinerun %s: %w", pipelineRun.Name, err)
+	}
+	return nil
+}
+
+// DeletePipelineRun removes the finalizers of the PipelineRun and deletes it, e.g. to clean up after a test.
+// PipelineRuns that no longer exist are ignored.
+// Return error if the PipelineRun cannot be deleted
+func DeletePipelineRun(ctx context.Context, c client.Client, pipelineRun *tektonv1.PipelineRun) error {
+	current := &tektonv1.PipelineRun{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(pipelineRun), current); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	current.Finalizers = nil
+	if err := c.Update(ctx, current); err != nil {
+		return fmt.Errorf("Failed to remove finalizers of pipelinerun %s: %w", pipelineRun.Name, err)
+	}
+	if err := c.Delete(ctx, current); client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("Failed to delete pipelinerun %s: %w", pipelineRun.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package testing helps other components write integration tests against the notification service:
+// Notifier is an in-memory fake of the notifiers of the controller, WebhookServer is a mock receiver
+// of webhook notifications that records and validates them, and the envtest helpers start a test
+// API server with the custom resources of the controller and of Tekton and create PipelineRuns in it.
+package testing
+
+import (
+	"context"
+	"sync"
+
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+)
+
+// Notifier records the notifications and summaries it is asked to send, in memory.
+// It is safe for concurrent use.
+type Notifier struct {
+	// Err is returned for every notification and summary once they are recorded, if set
+	Err error
+
+	mu            sync.Mutex
+	notifications []*notifier.Notification
+	summaries     []*notifier.Summary
+}
+
+var _ notifier.SummaryNotifier = &Notifier{}
+var _ notifier.ProbeNotifier = &Notifier{}
+
+// NewNotifier returns a Notifier delivering every notification
+func NewNotifier() *Notifier {
+	return &Notifier{}
+}
+
+// Notify records the notification
+func (n *Notifier) Notify(_ context.Context, notification *notifier.Notification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.notifications = append(n.notifications, notification)
+	return n.Err
+}
+
+// NotifySummary records the summary
+func (n *Notifier) NotifySummary(_ context.Context, summary *notifier.Summary) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.summaries = append(n.summaries, summary)
+	return n.Err
+}
+
+// Probe returns Err
+func (n *Notifier) Probe(_ context.Context) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.Err
+}
+
+// Notifications returns the recorded notifications, in the order they were sent
+func (n *Notifier) Notifications() []*notifier.Notification {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]*notifier.Notification(nil), n.notifications...)
+}
+
+// NotificationsOf returns the recorded notifications about the PipelineRun
+func (n *Notifier) NotificationsOf(namespace string, pipelineRun string) []*notifier.Notification {
+	var matching []*notifier.Notification
+	for _, notification := range n.Notifications() {
+		if notification.Namespace == namespace && notification.PipelineRun == pipelineRun {
+			matching = append(matching, notification)
+		}
+	}
+	return matching
+}
+
+// Summaries returns the recorded summaries, in the order they were sent
+func (n *Notifier) Summaries() []*notifier.Summary {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]*notifier.Summary(nil), n.summaries...)
+}
+
+// Reset forgets the recorded notifications and summaries
+func (n *Notifier) Reset() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.notifications = nil
+	n.summaries = nil
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testing_test
+
+import (
+	"context"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/internal/controller"
+	"github.com/konflux-ci/notification-service/pkg/notifications"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+	notificationtesting "github.com/konflux-ci/notification-service/pkg/testing"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Testing", func() {
+	notification := &notifier.Notification{
+		PipelineRun: "build-1",
+		Namespace:   "tenant",
+		Status:      notifier.StatusFailed,
+		Results:     []notifier.Result{{Name: "IMAGE_URL", Value: "quay.io/tenant/app"}},
+	}
+
+	send := func(url string, notification *notifier.Notification) error {
+		n, err := notifier.NewWebhookNotifier(notifier.WebhookOptions{URL: url})
+		Expect(err).NotTo(HaveOccurred())
+		return n.Notify(context.Background(), notification)
+	}
+
+	It("should record notifications with the fake notifier", func() {
+		fake := notificationtesting.NewNotifier()
+		Expect(fake.Notify(context.Background(), notification)).To(Succeed())
+		Expect(fake.NotifySummary(context.Background(), &notifier.Summary{Namespace: "tenant"})).To(Succeed())
+		Expect(fake.NotificationsOf("tenant", "build-1")).To(Equal([]*notifier.Notification{notification}))
+		Expect(fake.NotificationsOf("tenant", "build-2")).To(BeEmpty())
+		Expect(fake.Summaries()).To(HaveLen(1))
+
+		fake.Reset()
+		fake.Err = http.ErrServerClosed
+		Expect(fake.Notify(context.Background(), notification)).To(MatchError(http.ErrServerClosed))
+		Expect(fake.Notifications()).To(HaveLen(1))
+	})
+
+	It("should record and validate webhook notifications", func() {
+		server := notificationtesting.NewWebhookServer(notifications.HandlerOptions{})
+		defer server.Close()
+
+		Expect(send(server.URL, notification)).To(Succeed())
+		Expect(server.Notifications()).To(Equal([]*notifier.Notification{notification}))
+		Expect(server.Requests()).To(HaveLen(1))
+		Expect(server.Requests()[0].Header.Get("Content-Type")).To(Equal("application/json"))
+
+		Expect(send(server.URL, &notifier.Notification{Status: "Done"})).To(MatchError(ContainSubstring("status 500")))
+		Expect(server.Notifications()).To(HaveLen(1))
+		Expect(server.Errors()).To(HaveLen(1))
+		Expect(server.Errors()[0]).To(MatchError(And(
+			ContainSubstring("Missing pipelineRun"), ContainSubstring("Missing namespace"), ContainSubstring(`Unknown status "Done"`))))
+
+		server.Reset()
+		server.RespondWith(http.StatusServiceUnavailable)
+		Expect(send(server.URL, notification)).To(MatchError(ContainSubstring("status 503")))
+		Expect(server.Requests()).To(HaveLen(1))
+		Expect(server.Notifications()).To(BeEmpty())
+	})
+
+	It("should run the controller against the test environment", func() {
+		ctx := context.Background()
+		pipelineRun, err := notificationtesting.CreatePipelineRun(ctx, k8sClient, "default", "build-1")
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(func() {
+			Expect(notificationtesting.DeletePipelineRun(ctx, k8sClient, pipelineRun)).To(Succeed())
+		})
+		Expect(notificationtesting.CompletePipelineRun(ctx, k8sClient, pipelineRun, corev1.ConditionTrue,
+			tektonv1.PipelineRunResult{Name: "IMAGE_URL", Value: *tektonv1.NewStructuredValues("quay.io/tenant/app")})).To(Succeed())
+
+		fake := notificationtesting.NewNotifier()
+		r := &controller.NotificationServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Notifier: fake}
+		_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pipelineRun)})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fake.NotificationsOf("default", "build-1")).To(HaveLen(1))
+		Expect(fake.Notifications()[0].Status).To(Equal(notifier.StatusSucceeded))
+	})
+})
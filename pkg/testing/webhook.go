@@ -0,0 +1,163 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+
+	"github.com/konflux-ci/notification-service/pkg/notifications"
+	"github.com/konflux-ci/notification-service/pkg/notifier"
+)
+
+// Request is a request received by a WebhookServer
+type Request struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// WebhookServer is a mock webhook receiver. It records every request, decodes and validates the
+// notifications and summaries they send, and responds 204 No Content, or the status set with
+// RespondWith. Requests whose notification is not valid are answered 500 Internal Server Error.
+type WebhookServer struct {
+	*httptest.Server
+
+	mu            sync.Mutex
+	status        int
+	requests      []Request
+	notifications []*notifier.Notification
+	summaries     []*notifier.Summary
+	errors        []error
+}
+
+// NewWebhookServer starts a WebhookServer, which decodes, decrypts and verifies requests as
+// configured by opts. Its OnSummary callback is replaced by the server. Close it once done.
+func NewWebhookServer(opts notifications.HandlerOptions) *WebhookServer {
+	s := &WebhookServer{}
+	opts.OnSummary = func(_ context.Context, summary *notifications.Summary) error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.summaries = append(s.summaries, summary)
+		return nil
+	}
+	handler := notifications.Handler(opts, func(_ context.Context, notification *notifications.Notification) error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if err := ValidateNotification(notification); err != nil {
+			s.errors = append(s.errors, err)
+			return err
+		}
+		s.notifications = append(s.notifications, notification)
+		return nil
+	})
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.mu.Lock()
+		s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path, Header: r.Header.Clone(), Body: body})
+		status := s.status
+		s.mu.Unlock()
+		if status != 0 {
+			w.WriteHeader(status)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		handler.ServeHTTP(w, r)
+	}))
+	return s
+}
+
+// RespondWith makes the server answer every following request with the status without processing it,
+// e.g. http.StatusServiceUnavailable to test retries. Zero restores processing requests.
+func (s *WebhookServer) RespondWith(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = status
+}
+
+// Requests returns the received requests, including the rejected ones
+func (s *WebhookServer) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Notifications returns the valid notifications received, in the order they were received
+func (s *WebhookServer) Notifications() []*notifier.Notification {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*notifier.Notification(nil), s.notifications...)
+}
+
+// Summaries returns the summaries received, in the order they were received
+func (s *WebhookServer) Summaries() []*notifier.Summary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*notifier.Summary(nil), s.summaries...)
+}
+
+// Errors returns why the invalid notifications received were rejected
+func (s *WebhookServer) Errors() []error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]error(nil), s.errors...)
+}
+
+// Reset forgets the received requests, notifications, summaries and errors
+func (s *WebhookServer) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+	s.notifications = nil
+	s.summaries = nil
+	s.errors = nil
+}
+
+// ValidateNotification checks that the notification identifies its PipelineRun, has a known status
+// and only named results
+// Return error describing every problem of the notification
+func ValidateNotification(notification *notifier.Notification) error {
+	var errs []error
+	if notification.PipelineRun == "" {
+		errs = append(errs, errors.New("Missing pipelineRun"))
+	}
+	if notification.Namespace == "" {
+		errs = append(errs, errors.New("Missing namespace"))
+	}
+	statuses := []string{notifier.StatusStarted, notifier.StatusRunning, notifier.StatusSucceeded, notifier.StatusFailed}
+	if !slices.Contains(statuses, notification.Status) {
+		errs = append(errs, fmt.Errorf("Unknown status %q, expected one of %v", notification.Status, statuses))
+	}
+	for i, result := range notification.Results {
+		if result.Name == "" {
+			errs = append(errs, fmt.Errorf("Missing name of result %d", i))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,28 @@
+// Command transform is the WebAssembly module of the transform tests. It drops the notifications about the
+// "ignored" pipelinerun, fails for the "broken" pipelinerun, loops forever for the "stuck" pipelinerun and
+// sets the team of the other notifications. It edits the JSON as text to keep the module small.
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+func main() {
+	notification, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		os.Exit(1)
+	}
+	switch {
+	case bytes.Contains(notification, []byte(`"pipelineRun":"ignored"`)):
+		return
+	case bytes.Contains(notification, []byte(`"pipelineRun":"broken"`)):
+		_, _ = os.Stderr.WriteString("broken pipelinerun\n")
+		os.Exit(2)
+	case bytes.Contains(notification, []byte(`"pipelineRun":"stuck"`)):
+		for {
+		}
+	}
+	_, _ = os.Stdout.Write(bytes.Replace(notification, []byte("{"), []byte(`{"team":"transformed",`), 1))
+}
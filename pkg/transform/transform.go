@@ -0,0 +1,203 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package transform runs user-provided WebAssembly modules transforming or filtering notifications, giving
+// advanced users arbitrary logic without forking the controller.
+//
+// Modules are WASI commands, e.g. built with GOOS=wasip1 GOARCH=wasm, TinyGo's wasi target or Rust's
+// wasm32-wasip1 target. A module reads the JSON encoded notification on its standard input and writes the
+// transformed JSON notification on its standard output. Writing nothing drops the notification. Exiting with
+// a non-zero code fails the transform, with the standard error of the module in the error.
+//
+// Modules run in a sandbox: they have no access to the file system, the network, the clock or the
+// environment, their memory is limited and they are stopped once the timeout expires.
+package transform
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// DefaultTimeout is the deadline of a single run of a module
+const DefaultTimeout = time.Second
+
+// DefaultMemoryLimitPages limits the memory of modules to 64 MiB, in pages of 64 KiB
+const DefaultMemoryLimitPages = 1024
+
+// MaxOutputBytes limits the size of the standard output and standard error of modules
+const MaxOutputBytes = 4 << 20
+
+// ErrOutputTooLarge is returned when a module writes more than MaxOutputBytes
+var ErrOutputTooLarge = errors.New("transform output is too large")
+
+// Options configures a Runtime
+type Options struct {
+	// MemoryLimitPages limits the memory of every module, DefaultMemoryLimitPages by default
+	MemoryLimitPages uint32
+}
+
+// Runtime compiles and runs WebAssembly modules. Compiled modules are cached by digest, so modules
+// loaded again, e.g. when their ConfigMap is read again, are only compiled once, until Retain releases them.
+// It is safe for concurrent use.
+type Runtime struct {
+	runtime wazero.Runtime
+
+	mu       sync.Mutex
+	compiled map[string]wazero.CompiledModule
+	// releasing is held for reading while modules run, and for writing while compiled modules are closed
+	releasing sync.RWMutex
+}
+
+// NewRuntime creates a Runtime from the given options
+func NewRuntime(ctx context.Context, opts Options) *Runtime {
+	if opts.MemoryLimitPages == 0 {
+		opts.MemoryLimitPages = DefaultMemoryLimitPages
+	}
+	config := wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(opts.MemoryLimitPages).
+		WithCloseOnContextDone(true)
+	runtime := wazero.NewRuntimeWithConfig(ctx, config)
+	wasi_snapshot_preview1.MustInstantiate(ctx, runtime)
+	return &Runtime{runtime: runtime, compiled: map[string]wazero.CompiledModule{}}
+}
+
+// Digest returns the sha256 digest of the module, in the form sha256:<hex>
+func Digest(module []byte) string {
+	sum := sha256.Sum256(module)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Run runs the module with the input on its standard input and returns its standard output, which is empty
+// if the module dropped the input. The module is stopped once the timeout, DefaultTimeout if zero, expires.
+// Return error if the module is not valid, exits with a non-zero code, times out or writes too much
+func (r *Runtime) Run(ctx context.Context, module []byte, input []byte, timeout time.Duration) ([]byte, error) {
+	r.releasing.RLock()
+	defer r.releasing.RUnlock()
+	compiled, err := r.compile(ctx, module)
+	if err != nil {
+		return nil, err
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	stdout := &limitedBuffer{}
+	stderr := &limitedBuffer{}
+	// Modules are anonymous so several instances run concurrently
+	config := wazero.NewModuleConfig().
+		WithName("").
+		WithArgs("transform").
+		WithStdin(bytes.NewReader(input)).
+		WithStdout(stdout).
+		WithStderr(stderr)
+	instance, err := r.runtime.InstantiateModule(ctx, compiled, config)
+	if instance != nil {
+		_ = instance.Close(ctx)
+	}
+	if exit := (*sys.ExitError)(nil); errors.As(err, &exit) && exit.ExitCode() == 0 {
+		err = nil
+	}
+	switch {
+	case err != nil && ctx.Err() != nil:
+		return nil, fmt.Errorf("Transform timed out after %s", timeout)
+	case err != nil:
+		return nil, fmt.Errorf("Transform failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	case stdout.overflow:
+		return nil, ErrOutputTooLarge
+	}
+	return stdout.Bytes(), nil
+}
+
+// compile returns the compiled module, compiling it if it is not cached yet
+func (r *Runtime) compile(ctx context.Context, module []byte) (wazero.CompiledModule, error) {
+	digest := Digest(module)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if compiled, ok := r.compiled[digest]; ok {
+		return compiled, nil
+	}
+	compiled, err := r.runtime.CompileModule(ctx, module)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to compile module %s: %w", digest, err)
+	}
+	r.compiled[digest] = compiled
+	return compiled, nil
+}
+
+// Retain closes the compiled modules whose digest is not one of the digests, e.g. of modules that were
+// edited or removed from the configuration, once the modules that are running complete
+func (r *Runtime) Retain(ctx context.Context, digests ...string) {
+	var released []wazero.CompiledModule
+	r.mu.Lock()
+	for digest, compiled := range r.compiled {
+		if !slices.Contains(digests, digest) {
+			released = append(released, compiled)
+			delete(r.compiled, digest)
+		}
+	}
+	r.mu.Unlock()
+	if len(released) == 0 {
+		return
+	}
+	r.releasing.Lock()
+	defer r.releasing.Unlock()
+	for _, compiled := range released {
+		_ = compiled.Close(ctx)
+	}
+}
+
+// Digests returns the digests of the compiled modules that are cached
+func (r *Runtime) Digests() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	digests := make([]string, 0, len(r.compiled))
+	for digest := range r.compiled {
+		digests = append(digests, digest)
+	}
+	return digests
+}
+
+// Close releases the compiled modules and stops the modules that are running
+func (r *Runtime) Close(ctx context.Context) error {
+	return r.runtime.Close(ctx)
+}
+
+// limitedBuffer is a buffer keeping the first MaxOutputBytes written to it
+type limitedBuffer struct {
+	bytes.Buffer
+	overflow bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > MaxOutputBytes {
+		b.overflow = true
+		return 0, ErrOutputTooLarge
+	}
+	return b.Buffer.Write(p)
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package transform_test
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/pkg/transform"
+)
+
+// module is the WebAssembly module built from testdata/transform
+var module []byte
+
+// runtime is shared by the tests, so the module is only compiled once
+var runtime *transform.Runtime
+
+func TestTransform(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Transform Suite")
+}
+
+var _ = BeforeSuite(func() {
+	path := filepath.Join(GinkgoT().TempDir(), "transform.wasm")
+	build := exec.Command("go", "build", "-o", path, "./testdata/transform")
+	build.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+	output, err := build.CombinedOutput()
+	Expect(err).NotTo(HaveOccurred(), string(output))
+	module, err = os.ReadFile(path)
+	Expect(err).NotTo(HaveOccurred())
+	runtime = transform.NewRuntime(context.Background(), transform.Options{})
+	DeferCleanup(runtime.Close, context.Background())
+})
@@ -0,0 +1,77 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package transform_test
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/notification-service/pkg/transform"
+)
+
+var _ = Describe("Runtime", func() {
+	run := func(input string, timeout time.Duration) ([]byte, error) {
+		return runtime.Run(context.Background(), module, []byte(input), timeout)
+	}
+
+	It("should transform the input", func() {
+		output, err := run(`{"pipelineRun":"build","namespace":"team-a"}`, 0)
+		Expect(err).NotTo(HaveOccurred())
+		transformed := map[string]any{}
+		Expect(json.Unmarshal(output, &transformed)).To(Succeed())
+		Expect(transformed).To(HaveKeyWithValue("team", "transformed"))
+		Expect(transformed).To(HaveKeyWithValue("pipelineRun", "build"))
+	})
+
+	It("should return no output for dropped inputs", func() {
+		output, err := run(`{"pipelineRun":"ignored","namespace":"team-a"}`, 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(output).To(BeEmpty())
+	})
+
+	It("should report modules exiting with an error", func() {
+		_, err := run(`{"pipelineRun":"broken","namespace":"team-a"}`, 0)
+		Expect(err).To(MatchError(ContainSubstring("broken pipelinerun")))
+	})
+
+	It("should stop modules once the timeout expires", func() {
+		_, err := run(`{"pipelineRun":"stuck","namespace":"team-a"}`, 100*time.Millisecond)
+		Expect(err).To(MatchError(ContainSubstring("timed out")))
+	})
+
+	It("should close the compiled modules that are not retained", func() {
+		_, err := run(`{"pipelineRun":"build","namespace":"team-a"}`, 0)
+		Expect(err).NotTo(HaveOccurred())
+		runtime.Retain(context.Background(), transform.Digest(module))
+		Expect(runtime.Digests()).To(ConsistOf(transform.Digest(module)))
+
+		runtime.Retain(context.Background())
+		Expect(runtime.Digests()).To(BeEmpty())
+		_, err = run(`{"pipelineRun":"build","namespace":"team-a"}`, 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(runtime.Digests()).To(ConsistOf(transform.Digest(module)))
+	})
+
+	It("should reject invalid modules", func() {
+		_, err := runtime.Run(context.Background(), []byte("not wasm"), nil, 0)
+		Expect(err).To(MatchError(ContainSubstring("Failed to compile module")))
+	})
+})